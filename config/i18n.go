@@ -31,6 +31,8 @@ type Resource struct {
 		MatchingSignature   string `yaml:"MatchingSignature"`   // Подпись письма
 		OrderHeader         string `yaml:"OrderHeader"`         // Заголовок заказа
 		HeaderRequest       string `yaml:"HeaderRequest"`       // Регистрация имени отправителя
+		TwoFactorCode       string `yaml:"TwoFactorCode"`       // SMS с одноразовым кодом входа
+		LedgerDrift         string `yaml:"LedgerDrift"`         // Расхождение остатков счетов с операциями
 	} `yaml:"Messages"` // Общая информация
 
 	Errors struct {
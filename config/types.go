@@ -21,6 +21,19 @@ type MysqlConfiguration struct {
 	Charset  string // Кодировка данных
 }
 
+// Ограничение частоты запросов по алгоритму корзины маркеров
+type RateLimitBucket struct {
+	Rate  float64 `yaml:"Rate"`  // Количество запросов в секунду, 0 - без ограничения
+	Burst int64   `yaml:"Burst"` // Емкость корзины, по умолчанию равна количеству запросов в секунду
+}
+
+// Ограничения группы маршрутов по ключам запроса
+type RateLimitGroup struct {
+	IP   RateLimitBucket `yaml:"IP"`   // По ip адресу клиента
+	User RateLimitBucket `yaml:"User"` // По пользователю сессии
+	Unit RateLimitBucket `yaml:"Unit"` // По объединению пользователя сессии
+}
+
 var Configuration struct {
 	WorkingDirectory string        `yaml:"WorkingDirectory"` // Рабочая директория сервера, сразу после запуска приложение меняет текущую директорию
 	EnablePprof      bool          `yaml:"EnablePprof"`      // Профилирование сервера с помощь go tool pprof
@@ -45,6 +58,7 @@ var Configuration struct {
 		KeepAlive          int           `yaml:"KeepAlive"`          // Режим работы соединения Keep Alive
 		DocumentRoot       string        `yaml:"DocumentRoot"`       // Корень http сервера
 		SessionTimeout     time.Duration `yaml:"SessionTimeout"`     // Время в милисекундах ожидания завершения неактивной сессии
+		SessionLifetime    time.Duration `yaml:"SessionLifetime"`    // Максимальное время жизни сессии независимо от активности
 		DefaultLanguage    string        `yaml:"DefaultLanguage"`    // Язык по умолчанию в формате ISO 639-2
 		AvailableLanguages []string      `yaml:"AvailableLanguages"` // Список языков в формате ISO 639-2
 	} `yaml:"Server"`
@@ -130,4 +144,87 @@ var Configuration struct {
 			Secret  string `yaml:"Secret"`  // Секретный ключ для стандартизации
 		} `yaml:"Dadata"`
 	} `yaml:"Suppliers"`
+
+	Jobs struct { // Очередь заданий на исполнение заказов
+		PollInterval time.Duration  `yaml:"PollInterval"` // Интервал опроса заказов и очереди заданий
+		LeaseTimeout time.Duration  `yaml:"LeaseTimeout"` // Время аренды задания обработчиком, после истечения задание может быть захвачено другим обработчиком
+		RetryDelay   time.Duration  `yaml:"RetryDelay"`   // Задержка перед повторной попыткой исполнения задания
+		MaxAttempts  int            `yaml:"MaxAttempts"`  // Максимальное количество попыток исполнения задания
+		Workers      map[string]int `yaml:"Workers"`      // Количество обработчиков для каждого псевдонима услуги
+	} `yaml:"Jobs"`
+
+	Scheduler struct { // Расписание периодических рассылок
+		CatchUpPolicy   string            `yaml:"CatchUpPolicy"`   // Обработка пропущенных рассылок: skip, once, all
+		CatchUpWindow   time.Duration     `yaml:"CatchUpWindow"`   // Допустимое опоздание рассылки, после которого применяется политика обработки пропущенных рассылок
+		DefaultTimezone string            `yaml:"DefaultTimezone"` // Часовой пояс получателей, для которых его не удалось определить
+		RegionTimezones map[string]string `yaml:"RegionTimezones"` // Часовой пояс по региону номера из плана нумерации
+	} `yaml:"Scheduler"`
+	Gateways struct { // Шлюзы взаимодействия с поставщиками услуг
+		Default   string            `yaml:"Default"`   // Шлюз по умолчанию: remote, simulator
+		Suppliers map[string]string `yaml:"Suppliers"` // Шлюз для поставщика по UUID объединения поставщика
+		Simulator struct {          // Симулятор поставщика услуг
+			Latency        time.Duration  `yaml:"Latency"`        // Задержка ответа на запрос
+			DeliveryDelay  time.Duration  `yaml:"DeliveryDelay"`  // Время до получения окончательного статуса
+			FailureRate    float64        `yaml:"FailureRate"`    // Доля отклоненных запросов от 0 до 1
+			SmsStatuses    map[string]int `yaml:"SmsStatuses"`    // Окончательные статусы смс с весами
+			HlrStatuses    map[string]int `yaml:"HlrStatuses"`    // Окончательные статусы hlr запросов с весами
+			VerifyStatuses map[string]int `yaml:"VerifyStatuses"` // Окончательные статусы проверки данных с весами
+			Operators      []string       `yaml:"Operators"`      // UUID объединений операторов мобильной связи
+		} `yaml:"Simulator"`
+	} `yaml:"Gateways"`
+	Documents struct { // Формирование документов в формате pdf
+		Renderer      string `yaml:"Renderer"`      // Способ формирования документов: native, docker
+		FontDirectory string `yaml:"FontDirectory"` // Директория шрифтов с поддержкой кириллицы, встраиваемых в документы
+		FontRegular   string `yaml:"FontRegular"`   // Файл шрифта обычного начертания
+		FontBold      string `yaml:"FontBold"`      // Файл шрифта полужирного начертания
+	} `yaml:"Documents"`
+	Ledger struct { // Книга учета средств объединений
+		ReconcileInterval time.Duration `yaml:"ReconcileInterval"` // Интервал сверки остатков счетов с журналом операций
+		HoldTimeout       time.Duration `yaml:"HoldTimeout"`       // Время, после которого несписанный резерв средств снимается
+	} `yaml:"Ledger"`
+	Routing struct { // Автоматический выбор поставщиков заказа
+		PriceWeight  float64 `yaml:"PriceWeight"`  // Вес цены поставщика во взвешенной политике выбора
+		RatingWeight float64 `yaml:"RatingWeight"` // Вес рейтинга поставщика во взвешенной политике выбора
+	} `yaml:"Routing"`
+	Suppression struct { // Списки запрета рассылок
+		StopWords   []string      `yaml:"StopWords"`   // Ответы абонентов, по которым номер добавляется в список запрета рассылок
+		ReplyPeriod time.Duration `yaml:"ReplyPeriod"` // Время после отправки сообщения, в течение которого принимается ответ абонента
+	} `yaml:"Suppression"`
+	Delivery struct { // Получение окончательных статусов запросов у поставщиков
+		PollInterval    time.Duration `yaml:"PollInterval"`    // Начальный интервал опроса поставщика
+		PollMaxInterval time.Duration `yaml:"PollMaxInterval"` // Максимальный интервал опроса поставщика
+		Deadline        time.Duration `yaml:"Deadline"`        // Время ожидания статусов, после которого запросы считаются просроченными
+	} `yaml:"Delivery"`
+	Webhooks struct { // Уведомления объединений о событиях
+		Interval      time.Duration `yaml:"Interval"`      // Интервал проверки очереди уведомлений
+		Timeout       time.Duration `yaml:"Timeout"`       // Время ожидания ответа на уведомление
+		RetryInterval time.Duration `yaml:"RetryInterval"` // Начальный интервал повторной доставки, удваивается с каждой попыткой
+		MaxAttempts   int           `yaml:"MaxAttempts"`   // Количество попыток доставки, после которого уведомление не доставляется
+	} `yaml:"Webhooks"`
+	HLR struct { // Hlr запросы
+		CacheTTL time.Duration `yaml:"CacheTTL"` // Время, в течение которого результат hlr запроса используется повторно
+	} `yaml:"HLR"`
+	Numbering struct { // План нумерации для определения оператора номера без обращения к поставщику
+		Country   string              `yaml:"Country"`   // Страна номеров, указанных без кода страны, по умолчанию RU
+		Plans     map[string][]string `yaml:"Plans"`     // Файлы диапазонов плана нумерации по коду страны ISO
+		Ported    []string            `yaml:"Ported"`    // Файлы перенесенных номеров
+		Operators map[string]string   `yaml:"Operators"` // UUID объединения оператора по названию оператора в файлах
+	} `yaml:"Numbering"`
+	Address struct { // Справочник адресов ФИАС для стандартизации адресов без обращения к поставщику
+		Directory string `yaml:"Directory"` // Директория архивов ГАР для загрузки справочника
+	} `yaml:"Address"`
+	RateLimit struct { // Ограничение частоты запросов к api
+		Store          string                    `yaml:"Store"`          // Хранилище счетчиков: memory или mysql для нескольких экземпляров сервера
+		TrustedProxies []string                  `yaml:"TrustedProxies"` // Адреса и подсети прокси, от которых принимается заголовок X-Forwarded-For
+		Default        RateLimitGroup            `yaml:"Default"`        // Ограничения маршрутов, не входящих в группы
+		Groups         map[string]RateLimitGroup `yaml:"Groups"`         // Ограничения групп маршрутов по префиксу адреса
+	} `yaml:"RateLimit"`
+	TwoFactor struct { // Двухфакторная аутентификация пользователей
+		Issuer        string        `yaml:"Issuer"`        // Название сервиса в приложении одноразовых паролей
+		Roles         []int         `yaml:"Roles"`         // Уровни доступа, для которых второй фактор обязателен
+		SmsSupplier   string        `yaml:"SmsSupplier"`   // UUID объединения поставщика SMS с одноразовыми кодами
+		SmsSender     string        `yaml:"SmsSender"`     // Имя отправителя SMS с одноразовыми кодами
+		CodeTimeout   time.Duration `yaml:"CodeTimeout"`   // Время действия одноразового кода и попытки входа
+		TrustDuration time.Duration `yaml:"TrustDuration"` // Время, в течение которого доверенное устройство входит без второго фактора
+	} `yaml:"TwoFactor"`
 }
@@ -0,0 +1,57 @@
+package administration
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"application/workflows"
+	"github.com/martini-contrib/binding"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"types"
+)
+
+// get /api/v1.0/administration/addresses/imports/
+func GetAddressImports(w http.ResponseWriter, r render.Render, addressimportrepository services.AddressImportRepository,
+	session *models.DtoSession) {
+	helpers.GetAddressImports(w, r, addressimportrepository, session.Language)
+}
+
+// post /api/v1.0/administration/addresses/imports/
+func CreateAddressImport(errors binding.Errors, viewaddressimport models.ViewAddressImport, r render.Render,
+	addressworkflow *workflows.AddressWorkflow, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+
+	dtoaddressimport, err := addressworkflow.StartImport(viewaddressimport.File)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, helpers.GetApiAddressImport(dtoaddressimport))
+}
+
+// get /api/v1.0/administration/addresses/?address=
+func StandardizeAddress(request *http.Request, r render.Render, addressworkflow *workflows.AddressWorkflow,
+	session *models.DtoSession) {
+	address := request.URL.Query().Get(helpers.PARAM_QUERY_ADDRESS)
+	if address == "" {
+		log.Error("Address is empty")
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	apistandardaddress, err := addressworkflow.Standardize(address)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	r.JSON(http.StatusOK, apistandardaddress)
+}
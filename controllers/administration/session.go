@@ -0,0 +1,55 @@
+package administration
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"types"
+)
+
+// delete /api/v1.0/users/:userid/sessions/
+func DeleteUserSessions(r render.Render, params martini.Params, userrepository services.UserRepository,
+	sessionrepository services.SessionRepository, session *models.DtoSession) {
+	userid, err := helpers.CheckParameterInt(r, params[helpers.PARAM_NAME_USER_ID], session.Language)
+	if err != nil {
+		return
+	}
+
+	user, err := userrepository.Get(userid)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	err = sessionrepository.DeleteByUser(user.ID, nil)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, types.ResponseOK{Message: config.Localization[session.Language].Messages.OK})
+}
+
+// delete /api/v1.0/administration/units/:unitId/sessions/
+func DeleteUnitSessions(r render.Render, params martini.Params, unitrepository services.UnitRepository,
+	sessionrepository services.SessionRepository, session *models.DtoSession) {
+	dtounit, err := helpers.CheckUnit(r, params, unitrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	err = sessionrepository.DeleteByUnit(dtounit.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, types.ResponseOK{Message: config.Localization[session.Language].Messages.OK})
+}
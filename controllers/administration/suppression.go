@@ -0,0 +1,69 @@
+package administration
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/binding"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"types"
+)
+
+// get /api/v1.0/administration/suppressions/
+func GetSuppressions(w http.ResponseWriter, r render.Render, suppressionrepository services.SuppressionRepository,
+	session *models.DtoSession) {
+	helpers.GetSuppressions(0, w, r, suppressionrepository, session.Language)
+}
+
+// post /api/v1.0/administration/suppressions/
+func CreateSuppression(errors binding.Errors, viewsuppression models.ViewSuppression, r render.Render,
+	columntyperepository services.ColumnTypeRepository, suppressionrepository services.SuppressionRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+
+	apisuppression, err := helpers.CreateSuppression(0, &viewsuppression, r, columntyperepository, suppressionrepository,
+		session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, apisuppression)
+}
+
+// post /api/v1.0/administration/suppressions/import/
+func ImportSuppressions(errors binding.Errors, viewimportsuppressions models.ViewImportSuppressions, r render.Render,
+	customertablerepository services.CustomerTableRepository, columntyperepository services.ColumnTypeRepository,
+	tablecolumnrepository services.TableColumnRepository, tablerowrepository services.TableRowRepository,
+	suppressionrepository services.SuppressionRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+
+	apisuppressionimport, err := helpers.ImportSuppressions(0, &viewimportsuppressions, r, customertablerepository,
+		columntyperepository, tablecolumnrepository, tablerowrepository, suppressionrepository, false, session.UserID, session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, apisuppressionimport)
+}
+
+// delete /api/v1.0/administration/suppressions/:sid/
+func DeleteSuppression(r render.Render, params martini.Params, suppressionrepository services.SuppressionRepository,
+	session *models.DtoSession) {
+	suppression_id, err := helpers.CheckParameterInt(r, params[helpers.PARAM_NAME_SUPPRESSION_ID], session.Language)
+	if err != nil {
+		return
+	}
+
+	err = helpers.DeleteSuppression(0, suppression_id, r, suppressionrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, types.ResponseOK{Message: config.Localization[session.Language].Messages.OK})
+}
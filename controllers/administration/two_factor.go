@@ -0,0 +1,51 @@
+package administration
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/binding"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"types"
+)
+
+// get /api/v1.0/administration/units/:unitId/twofactor/
+func GetUnitTwoFactorPolicy(r render.Render, params martini.Params, unitrepository services.UnitRepository,
+	twofactorrepository services.TwoFactorRepository, session *models.DtoSession) {
+	dtounit, err := helpers.CheckUnit(r, params, unitrepository, session.Language)
+	if err != nil {
+		return
+	}
+	required, err := twofactorrepository.IsRequiredByUnit(dtounit.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiTwoFactorPolicy(required))
+}
+
+// put /api/v1.0/administration/units/:unitId/twofactor/
+func UpdateUnitTwoFactorPolicy(errors binding.Errors, viewpolicy models.ViewTwoFactorPolicy, r render.Render,
+	params martini.Params, unitrepository services.UnitRepository, twofactorrepository services.TwoFactorRepository,
+	session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	dtounit, err := helpers.CheckUnit(r, params, unitrepository, session.Language)
+	if err != nil {
+		return
+	}
+	err = twofactorrepository.SetRequiredByUnit(dtounit.ID, viewpolicy.Required)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiTwoFactorPolicy(viewpolicy.Required))
+}
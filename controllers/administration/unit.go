@@ -390,6 +390,67 @@ func GetUnitInvoices(w http.ResponseWriter, request *http.Request, r render.Rend
 	helpers.RenderJSONArray(invoices, len(*invoices), w, r)
 }
 
+// patch /api/v1.0/administration/units/:unitId/invoices/:iid/
+func PayUnitInvoice(r render.Render, params martini.Params, unitrepository services.UnitRepository,
+	invoicerepository services.InvoiceRepository, invoiceitemrepository services.InvoiceItemRepository,
+	companyrepository services.CompanyRepository, transactiontyperepository services.TransactionTypeRepository,
+	session *models.DtoSession) {
+	dtounit, err := helpers.CheckUnit(r, params, unitrepository, session.Language)
+	if err != nil {
+		return
+	}
+	dtoinvoice, err := helpers.CheckInvoice(r, params, invoicerepository, session.Language)
+	if err != nil {
+		return
+	}
+	dtocompany, err := companyrepository.Get(dtoinvoice.Company_ID)
+	if err != nil || dtocompany.Unit_ID != dtounit.ID {
+		log.Error("Invoice %v doesn't belong to unit %v", dtoinvoice.ID, dtounit.ID)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	if !dtoinvoice.Active || dtoinvoice.Paid {
+		log.Error("Invoice is paid or not active %v", dtoinvoice.ID)
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+	dtosystemunit, err := unitrepository.Get(config.Configuration.SystemAccount)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	dtotransactiontype, err := transactiontyperepository.Get(models.TRANSACTION_TYPE_REFILLING_ACCOUNT)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	dtotransaction := new(models.DtoTransaction)
+	dtotransaction.Source_ID = dtosystemunit.ID
+	dtotransaction.Destination_ID = dtounit.ID
+	dtotransaction.Type_ID = dtotransactiontype.ID
+	err = invoicerepository.PayForRefill(dtoinvoice, dtotransaction, true)
+	if err != nil {
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	invoiceitems, err := invoiceitemrepository.GetByInvoice(dtoinvoice.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiFullInvoice(dtoinvoice.ID, dtoinvoice.Company_ID, dtoinvoice.Created, dtoinvoice.VAT, dtoinvoice.Total,
+		*invoiceitems, dtoinvoice.Paid, dtoinvoice.PaidAt, !dtoinvoice.Active))
+}
+
 // options /api/v1.0/administration/orders/
 func GetOrderMetaData(r render.Render, orderrepository services.OrderRepository, session *models.DtoSession) {
 	ordermeta, err := orderrepository.GetFullMeta()
@@ -0,0 +1,183 @@
+package controllers
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/binding"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"time"
+	"types"
+)
+
+// get /api/v1.0/user/administration/apikeys/
+func GetApiKeys(w http.ResponseWriter, r render.Render, userrepository services.UserRepository,
+	apikeyrepository services.ApiKeyRepository, session *models.DtoSession) {
+	dtouser, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	dtoapikeys, err := apikeyrepository.GetByUnit(dtouser.UnitID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	apikeys := []models.ApiApiKey{}
+	for i := range *dtoapikeys {
+		apikeys = append(apikeys, *newApiApiKey(&(*dtoapikeys)[i]))
+	}
+
+	helpers.RenderJSONArray(apikeys, len(apikeys), w, r)
+}
+
+// post /api/v1.0/user/administration/apikeys/
+func CreateApiKey(errors binding.Errors, viewapikey models.ViewApiKey, r render.Render, userrepository services.UserRepository,
+	apikeyrepository services.ApiKeyRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	allowedips, err := checkApiKey(&viewapikey, r, session)
+	if err != nil {
+		return
+	}
+	dtouser, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	key, prefix, hash, err := helpers.GenerateApiKey()
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	dtoapikey := models.NewDtoApiKey(0, dtouser.UnitID, session.UserID, viewapikey.Name, prefix, hash, viewapikey.Roles,
+		allowedips, viewapikey.Expires, time.Time{}, true, time.Now())
+	err = apikeyrepository.Create(dtoapikey)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiApiKeySecret(dtoapikey.ID, key, dtoapikey.Expires))
+}
+
+// get /api/v1.0/user/administration/apikeys/:keyId/
+func GetApiKey(r render.Render, params martini.Params, userrepository services.UserRepository,
+	apikeyrepository services.ApiKeyRepository, session *models.DtoSession) {
+	dtoapikey, err := helpers.CheckApiKey(r, params, userrepository, apikeyrepository, session)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, newApiApiKey(dtoapikey))
+}
+
+// put /api/v1.0/user/administration/apikeys/:keyId/
+func UpdateApiKey(errors binding.Errors, viewapikey models.ViewApiKey, r render.Render, params martini.Params,
+	userrepository services.UserRepository, apikeyrepository services.ApiKeyRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	dtoapikey, err := helpers.CheckApiKey(r, params, userrepository, apikeyrepository, session)
+	if err != nil {
+		return
+	}
+	if !dtoapikey.Active {
+		r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_DATA_CHANGES_DENIED,
+			Message: config.Localization[session.Language].Errors.Api.Data_Changes_Denied})
+		return
+	}
+	allowedips, err := checkApiKey(&viewapikey, r, session)
+	if err != nil {
+		return
+	}
+
+	dtoapikey.Name = viewapikey.Name
+	dtoapikey.Roles = viewapikey.Roles
+	dtoapikey.Allowed_IPs = allowedips
+	dtoapikey.Expires = viewapikey.Expires
+	err = apikeyrepository.Update(dtoapikey)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, newApiApiKey(dtoapikey))
+}
+
+// post /api/v1.0/user/administration/apikeys/:keyId/rotate/
+func RotateApiKey(r render.Render, params martini.Params, userrepository services.UserRepository,
+	apikeyrepository services.ApiKeyRepository, session *models.DtoSession) {
+	dtoapikey, err := helpers.CheckApiKey(r, params, userrepository, apikeyrepository, session)
+	if err != nil {
+		return
+	}
+	if !dtoapikey.Active {
+		r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_DATA_CHANGES_DENIED,
+			Message: config.Localization[session.Language].Errors.Api.Data_Changes_Denied})
+		return
+	}
+	key, prefix, hash, err := helpers.GenerateApiKey()
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	// Прежний ключ перестает действовать сразу после замены
+	dtoapikey.Prefix = prefix
+	dtoapikey.Hash = hash
+	err = apikeyrepository.Update(dtoapikey)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiApiKeySecret(dtoapikey.ID, key, dtoapikey.Expires))
+}
+
+// delete /api/v1.0/user/administration/apikeys/:keyId/
+func DeleteApiKey(r render.Render, params martini.Params, userrepository services.UserRepository,
+	apikeyrepository services.ApiKeyRepository, session *models.DtoSession) {
+	dtoapikey, err := helpers.CheckApiKey(r, params, userrepository, apikeyrepository, session)
+	if err != nil {
+		return
+	}
+	err = apikeyrepository.Deactivate(dtoapikey.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, types.ResponseOK{Message: config.Localization[session.Language].Messages.OK})
+}
+
+func checkApiKey(viewapikey *models.ViewApiKey, r render.Render, session *models.DtoSession) (allowedips string, err error) {
+	if err = helpers.CheckApiKeyRoles(viewapikey.Roles, session.Roles, session.Language, r); err != nil {
+		return "", err
+	}
+	if err = helpers.CheckApiKeyExpires(viewapikey.Expires, session.Language, r); err != nil {
+		return "", err
+	}
+
+	return helpers.CheckApiKeyAddresses(viewapikey.AllowedIPs, session.Language, r)
+}
+
+func newApiApiKey(dtoapikey *models.DtoApiKey) *models.ApiApiKey {
+	return models.NewApiApiKey(dtoapikey.ID, dtoapikey.User_ID, dtoapikey.Name, dtoapikey.Prefix, dtoapikey.Roles,
+		helpers.GetApiKeyAddresses(dtoapikey), dtoapikey.Expires, dtoapikey.Last_Used, dtoapikey.Active, dtoapikey.Created)
+}
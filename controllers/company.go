@@ -5,10 +5,13 @@ import (
 	"application/helpers"
 	"application/models"
 	"application/services"
+	"fmt"
 	"github.com/go-martini/martini"
 	"github.com/martini-contrib/binding"
 	"github.com/martini-contrib/render"
 	"net/http"
+	"net/url"
+	"path/filepath"
 	"strings"
 	"time"
 	"types"
@@ -432,3 +435,124 @@ func UpdateContracts(errors binding.Errors, changecontract models.ChangeContract
 
 	helpers.RenderJSONArray(apicontracts, len(*apicontracts), w, r)
 }
+
+// get /api/v1.0/unit/contract/:orgid/export/
+func GetExportContract(request *http.Request, r render.Render, params martini.Params, companyrepository services.CompanyRepository,
+	unitrepository services.UnitRepository, contractrepository services.ContractRepository, appendixrepository services.AppendixRepository,
+	companycoderepository services.CompanyCodeRepository, companyaddressrepository services.CompanyAddressRepository,
+	companybankrepository services.CompanyBankRepository, companyemployeerepository services.CompanyEmployeeRepository,
+	filerepository services.FileRepository, session *models.DtoSession) {
+	format, err := url.QueryUnescape(request.URL.Query().Get(helpers.PARAM_QUERY_FORMAT))
+	if err != nil {
+		log.Error("Can't unescape %v url data", err)
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+	if strings.ToLower(format) != models.DATA_FORMAT_EXPORT_PDF {
+		log.Error("Export is not available for format %v", format)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	company_id, err := helpers.CheckParameterInt(r, params[helpers.PARAM_NAME_COMPANY_ID], session.Language)
+	if err != nil {
+		return
+	}
+	dtocompany, err := helpers.CheckCompanyAvailability(company_id, session.UserID, r, companyrepository, session.Language)
+	if err != nil {
+		return
+	}
+	dtocontract, err := helpers.CheckContract(dtocompany, r, contractrepository, session.Language)
+	if err != nil {
+		return
+	}
+	apiappendices, err := appendixrepository.GetByContract(dtocontract.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	dtobank, templateseller, err := helpers.PrepareSellerTemplate(r, unitrepository, companyrepository, companycoderepository,
+		companyaddressrepository, companybankrepository, companyemployeerepository, session.Language)
+	if err != nil {
+		return
+	}
+	_, templatebuyer, err := helpers.PrepareBuyerTemplate(dtocompany.ID, r, companyrepository, companycoderepository,
+		companyaddressrepository, companybankrepository, companyemployeerepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	file := new(models.DtoFile)
+	file.Created = time.Now()
+	file.Name = fmt.Sprintf("contract_%v.pdf", dtocontract.ID)
+	file.Path = "/" + fmt.Sprintf("%04d/%02d/%02d/", file.Created.Year(), file.Created.Month(), file.Created.Day())
+	file.Permanent = false
+	file.Export_Ready = false
+	file.Export_Percentage = 0
+	file.Export_Object_ID = dtocontract.ID
+	file.Export_Error = false
+	file.Export_ErrorDescription = ""
+
+	err = filerepository.Create(file, nil)
+	if err != nil {
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	absfilepath, err := filepath.Abs(config.Configuration.FileStorage)
+	if err != nil {
+		log.Error("Can't make an absolute path for %v, %v", config.Configuration.FileStorage, err)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	go helpers.ExportContractPDF(absfilepath, file, filerepository,
+		models.NewDtoContractTemplate(*dtocontract, *apiappendices, *dtobank, *templateseller, *templatebuyer))
+
+	r.JSON(http.StatusOK, models.ApiFile{ID: file.ID})
+}
+
+// options /api/v1.0/unit/contract/:orgid/export/:fid/
+func GetExportContractStatus(r render.Render, params martini.Params, companyrepository services.CompanyRepository,
+	contractrepository services.ContractRepository, filerepository services.FileRepository, session *models.DtoSession) {
+	company_id, err := helpers.CheckParameterInt(r, params[helpers.PARAM_NAME_COMPANY_ID], session.Language)
+	if err != nil {
+		return
+	}
+	dtocompany, err := helpers.CheckCompanyAvailability(company_id, session.UserID, r, companyrepository, session.Language)
+	if err != nil {
+		return
+	}
+	dtocontract, err := helpers.CheckContract(dtocompany, r, contractrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	fileid, err := helpers.CheckParameterInt(r, params[helpers.PARAM_NAME_FILE_ID], session.Language)
+	if err != nil {
+		return
+	}
+
+	file, err := filerepository.Get(fileid)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	if dtocontract.ID != file.Export_Object_ID {
+		log.Error("Linked file object %v and exported contract %v don't match", file.Export_Object_ID, dtocontract.ID)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiExportStatus(file.Export_Ready, file.Export_Percentage,
+		fmt.Sprintf("%v", file.Created.Add(config.Configuration.FileTimeout)), file.Export_Error, file.Export_ErrorDescription))
+}
@@ -205,6 +205,10 @@ func CreateSessionDevice(errors binding.Errors, viewdevice models.ViewTokenDevic
 	}
 	var dtodevice *models.DtoDevice
 	for i, device := range *dtodevices {
+		// Доверенные устройства второго фактора не заменяют вход по паролю
+		if device.App == models.DEVICE_APP_TWO_FACTOR {
+			continue
+		}
 		if bcrypt.CompareHashAndPassword(encryptedtoken, []byte(device.Token)) == nil {
 			dtodevice = &(*dtodevices)[i]
 			break
@@ -238,6 +242,7 @@ func CreateSessionDevice(errors binding.Errors, viewdevice models.ViewTokenDevic
 	}
 
 	dtosession := models.NewDtoSession(token, user.ID, user.Roles, time.Now(), config.Configuration.Server.DefaultLanguage)
+	helpers.SetSessionMetadata(dtosession, request, dtodevice.OS)
 	err = sessionrepository.Create(dtosession, true)
 	if err != nil {
 		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
@@ -253,5 +258,5 @@ func CreateSessionDevice(errors binding.Errors, viewdevice models.ViewTokenDevic
 		return
 	}
 
-	r.JSON(http.StatusOK, models.NewApiSession(dtosession.LastActivity.Add(config.Configuration.Server.SessionTimeout), dtosession.AccessToken))
+	r.JSON(http.StatusOK, models.NewApiSession(services.GetSessionExpires(dtosession), dtosession.AccessToken))
 }
@@ -10,6 +10,7 @@ import (
 	"github.com/martini-contrib/binding"
 	"github.com/martini-contrib/render"
 	"net/http"
+	"path/filepath"
 	"strings"
 	"time"
 	"types"
@@ -174,14 +175,66 @@ func CreateDocument(errors binding.Errors, viewlongdocument models.ViewLongDocum
 func CreateMatching(errors binding.Errors, viewshortdocument models.ViewShortDocument, r render.Render, emailrepository services.EmailRepository,
 	documentrepository services.DocumentRepository, companyrepository services.CompanyRepository, unitrepository services.UnitRepository,
 	documenttyperepository services.DocumentTypeRepository, filerepository services.FileRepository,
-	templaterepository services.TemplateRepository, session *models.DtoSession) {
+	templaterepository services.TemplateRepository, invoicerepository services.InvoiceRepository,
+	companycoderepository services.CompanyCodeRepository, companyaddressrepository services.CompanyAddressRepository,
+	companybankrepository services.CompanyBankRepository, companyemployeerepository services.CompanyEmployeeRepository,
+	session *models.DtoSession) {
 	if helpers.CheckValidation(errors, r, session.Language) != nil {
 		return
 	}
 
+	// Акт сверки формируется сразу, если период задан распознаваемыми датами
+	var dtomatchingtemplate *models.DtoMatchingTemplate
+	var file *models.DtoFile
+	begin, beginerr := models.ParseDate(viewshortdocument.Begin_Date)
+	end, enderr := models.ParseDate(viewshortdocument.End_Date)
+	if beginerr == nil && enderr == nil && !end.Before(begin) {
+		end = end.Add(24*time.Hour - time.Nanosecond)
+		_, templateseller, err := helpers.PrepareSellerTemplate(r, unitrepository, companyrepository, companycoderepository,
+			companyaddressrepository, companybankrepository, companyemployeerepository, session.Language)
+		if err != nil {
+			return
+		}
+		dtobuyer, templatebuyer, err := helpers.PrepareBuyerTemplate(viewshortdocument.Company_ID, r, companyrepository,
+			companycoderepository, companyaddressrepository, companybankrepository, companyemployeerepository, session.Language)
+		if err != nil {
+			return
+		}
+		invoices, err := invoicerepository.GetByCompany(dtobuyer.ID)
+		if err != nil {
+			r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+				Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+			return
+		}
+		opening, lines := helpers.MatchingLines(invoices, begin, end)
+		dtomatchingtemplate = models.NewDtoMatchingTemplate(begin, end, *templateseller, *templatebuyer, opening, lines)
+
+		file = new(models.DtoFile)
+		file.Created = time.Now()
+		file.Name = fmt.Sprintf("matching_%v.pdf", dtobuyer.ID)
+		file.Path = "/" + fmt.Sprintf("%04d/%02d/%02d/", file.Created.Year(), file.Created.Month(), file.Created.Day())
+		file.Permanent = true
+		file.Export_Ready = false
+		file.Export_Percentage = 0
+		file.Export_Object_ID = dtobuyer.ID
+		file.Export_Error = false
+		file.Export_ErrorDescription = ""
+
+		err = filerepository.Create(file, nil)
+		if err != nil {
+			r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+				Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+			return
+		}
+	}
+	var file_id int64
+	if file != nil {
+		file_id = file.ID
+	}
+
 	subject := config.Localization[session.Language].Messages.MatchingSubject
 	dtodocument, err := helpers.CreateDocumentByType(models.DOCUMENT_TYPE_MATCHING, viewshortdocument.Company_ID,
-		fmt.Sprintf(subject, viewshortdocument.Begin_Date, viewshortdocument.End_Date), 0, true, true, r,
+		fmt.Sprintf(subject, viewshortdocument.Begin_Date, viewshortdocument.End_Date), file_id, true, true, r,
 		documentrepository, companyrepository, unitrepository, documenttyperepository, filerepository, session)
 	if err != nil {
 		return
@@ -202,6 +255,17 @@ func CreateMatching(errors binding.Errors, viewshortdocument models.ViewShortDoc
 		return
 	}
 
+	if dtomatchingtemplate != nil {
+		absfilepath, err := filepath.Abs(config.Configuration.FileStorage)
+		if err != nil {
+			log.Error("Can't make an absolute path for %v, %v", config.Configuration.FileStorage, err)
+			r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+				Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+			return
+		}
+		go helpers.ExportMatchingPDF(absfilepath, file, filerepository, dtomatchingtemplate)
+	}
+
 	r.JSON(http.StatusOK, models.NewApiShortDocument(dtodocument.ID))
 }
 
@@ -46,7 +46,8 @@ func ImportDataFromFile(errors binding.Errors, viewimporttable models.ViewImport
 	filerepository services.FileRepository, unitrepository services.UnitRepository, tabletyperepository services.TableTypeRepository,
 	customertablerepository services.CustomerTableRepository, importsteprepository services.ImportStepRepository,
 	columntyperepository services.ColumnTypeRepository, dataformatrepository services.DataFormatRepository,
-	dataencodingrepository services.DataEncodingRepository, session *models.DtoSession) {
+	dataencodingrepository services.DataEncodingRepository, importrejectionrepository services.ImportRejectionRepository,
+	session *models.DtoSession) {
 	if helpers.CheckValidation(errors, r, session.Language) != nil {
 		return
 	}
@@ -118,7 +119,8 @@ func ImportDataFromFile(errors binding.Errors, viewimporttable models.ViewImport
 		return
 	}
 
-	go helpers.ImportData(viewimporttable, file, dtocustomertable, customertablerepository, importsteprepository, columntyperepository, session.Language)
+	go helpers.ImportData(viewimporttable, file, dtocustomertable, customertablerepository, importsteprepository, columntyperepository,
+		importrejectionrepository, session.Language)
 
 	r.JSON(http.StatusOK, models.NewApiImportTable(dtocustomertable.ID))
 }
@@ -159,7 +161,8 @@ func GetImportDataColumns(w http.ResponseWriter, r render.Render, params martini
 func UpdateImportDataColumns(errors binding.Errors, viewimportcolumns models.ViewImportColumns, r render.Render, params martini.Params,
 	customertablerepository services.CustomerTableRepository, tablecolumnrepository services.TableColumnRepository,
 	columntyperepository services.ColumnTypeRepository, tablerowrepository services.TableRowRepository,
-	importsteprepository services.ImportStepRepository, session *models.DtoSession) {
+	importsteprepository services.ImportStepRepository, webhookdeliveryrepository services.WebhookDeliveryRepository,
+	session *models.DtoSession) {
 	if helpers.CheckValidation(errors, r, session.Language) != nil {
 		return
 	}
@@ -250,7 +253,8 @@ func UpdateImportDataColumns(errors binding.Errors, viewimportcolumns models.Vie
 		return
 	}
 
-	go helpers.CheckTableCells(dtocustomertable, tablecolumnrepository, columntyperepository, tablerowrepository, importsteprepository)
+	go helpers.CheckTableCells(dtocustomertable, tablecolumnrepository, columntyperepository, tablerowrepository, importsteprepository,
+		webhookdeliveryrepository)
 
 	r.JSON(http.StatusOK, models.NewApiLongCustomerTable(dtocustomertable.ID, dtocustomertable.Name, dtocustomertable.TypeID, dtocustomertable.UnitID))
 }
@@ -280,6 +284,28 @@ func GetImportDataStatus(r render.Render, params martini.Params, filerepository
 		dtocustomertable.Import_ErrorDescription))
 }
 
+// get /api/v1.0/tables/import/:tmpid/rejections/
+func GetImportDataRejections(w http.ResponseWriter, r render.Render, params martini.Params, customertablerepository services.CustomerTableRepository,
+	importrejectionrepository services.ImportRejectionRepository, session *models.DtoSession) {
+	tableid, err := helpers.CheckParameterInt(r, params[helpers.PARAM_NAME_TEMPORABLE_TABLE_ID], session.Language)
+	if err != nil {
+		return
+	}
+	_, err = helpers.IsTableActive(r, customertablerepository, tableid, session.Language)
+	if err != nil {
+		return
+	}
+
+	importrejections, err := importrejectionrepository.GetByTable(tableid)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	helpers.RenderJSONArray(importrejections, len(*importrejections), w, r)
+}
+
 // options /api/v1.0/tables/export/
 func GetExportDataMeta(request *http.Request, r render.Render, dataformatrepository services.DataFormatRepository,
 	virtualdirrepository services.VirtualDirRepository, sessionrepository services.SessionRepository, session *models.DtoSession) {
@@ -311,7 +337,8 @@ func GetExportDataMeta(request *http.Request, r render.Render, dataformatreposit
 // get /api/v1.0/tables/:tid/export/
 func ExportDataToFile(request *http.Request, r render.Render, params martini.Params, filerepository services.FileRepository,
 	customertablerepository services.CustomerTableRepository, dataformatrepository services.DataFormatRepository,
-	tablecolumnrepository services.TableColumnRepository, session *models.DtoSession) {
+	tablecolumnrepository services.TableColumnRepository, tablerowrepository services.TableRowRepository,
+	webhookdeliveryrepository services.WebhookDeliveryRepository, session *models.DtoSession) {
 	dtocustomertable, err := helpers.CheckTable(r, params, customertablerepository, session.Language)
 	if err != nil {
 		return
@@ -351,6 +378,12 @@ func ExportDataToFile(request *http.Request, r render.Render, params martini.Par
 			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
 		return
 	}
+	if !helpers.IsExportDataFormat(models.DataFormat(dataformat.ID)) {
+		log.Error("Data format is not supported for export %v", dataformat.ID)
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
 
 	rowtype, err := url.QueryUnescape(request.URL.Query().Get(helpers.PARAM_QUERY_TYPE))
 	if err != nil {
@@ -369,7 +402,7 @@ func ExportDataToFile(request *http.Request, r render.Render, params martini.Par
 
 	file := new(models.DtoFile)
 	file.Created = time.Now()
-	file.Name = dtocustomertable.Name
+	file.Name = dtocustomertable.Name + helpers.GetExportExtension(models.DataFormat(dataformat.ID))
 	file.Path = "/" + fmt.Sprintf("%04d/%02d/%02d/", file.Created.Year(), file.Created.Month(), file.Created.Day())
 	file.Permanent = false
 	file.Export_Ready = false
@@ -388,7 +421,8 @@ func ExportDataToFile(request *http.Request, r render.Render, params martini.Par
 	viewexporttable := new(models.ViewExportTable)
 	viewexporttable.Data_Format_ID = dataformat.ID
 	viewexporttable.Type = rowtype
-	go helpers.ExportData(viewexporttable, file, dtocustomertable, tablecolumns, filerepository, customertablerepository, session.Language)
+	go helpers.ExportData(viewexporttable, file, dtocustomertable, tablecolumns, filerepository, tablerowrepository,
+		webhookdeliveryrepository, session.Language)
 
 	r.JSON(http.StatusOK, models.ApiFile{ID: file.ID})
 }
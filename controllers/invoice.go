@@ -275,50 +275,14 @@ func GetExportInvoice(request *http.Request, r render.Render, params martini.Par
 		return
 	}
 
-	dtounit, err := unitrepository.Get(config.Configuration.SystemAccount)
-	if err != nil {
-		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
-			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
-		return
-	}
-	dtoseller, err := companyrepository.GetPrimaryByUnit(dtounit.ID)
-	if err != nil {
-		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
-			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
-		return
-	}
-	apiseller, err := helpers.LoadCompany(dtoseller, r, companycoderepository, companyaddressrepository, companybankrepository,
-		companyemployeerepository, session.Language)
-	if err != nil {
-		return
-	}
-	found := false
-	var bankindex int
-	for bankindex = range apiseller.CompanyBanks {
-		if apiseller.CompanyBanks[bankindex].Primary && !apiseller.CompanyBanks[bankindex].Deleted {
-			found = true
-			break
-		}
-	}
-	if !found {
-		log.Error("Primary bank is not found for company %v", dtoseller.ID)
-		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
-			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
-		return
-	}
-	templateseller, err := helpers.PrepareCompanyTemplate(dtoseller.ID, apiseller, r, session.Language)
+	dtobank, templateseller, err := helpers.PrepareSellerTemplate(r, unitrepository, companyrepository, companycoderepository,
+		companyaddressrepository, companybankrepository, companyemployeerepository, session.Language)
 	if err != nil {
 		return
 	}
 
-	dtobuyer, err := companyrepository.Get(dtoinvoice.Company_ID)
-	if err != nil {
-		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
-			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
-		return
-	}
-	apibuyer, err := helpers.LoadCompany(dtobuyer, r, companycoderepository, companyaddressrepository, companybankrepository,
-		companyemployeerepository, session.Language)
+	dtobuyer, templatebuyer, err := helpers.PrepareBuyerTemplate(dtoinvoice.Company_ID, r, companyrepository, companycoderepository,
+		companyaddressrepository, companybankrepository, companyemployeerepository, session.Language)
 	if err != nil {
 		return
 	}
@@ -328,7 +292,7 @@ func GetExportInvoice(request *http.Request, r render.Render, params martini.Par
 			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
 		return
 	}
-	found = false
+	found := false
 	var contractindex int
 	for contractindex = range *contracts {
 		if (*contracts)[contractindex].Company_ID == dtobuyer.ID && (*contracts)[contractindex].Signed {
@@ -342,21 +306,11 @@ func GetExportInvoice(request *http.Request, r render.Render, params martini.Par
 			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
 		return
 	}
-	templatebuyer, err := helpers.PrepareCompanyTemplate(dtobuyer.ID, apibuyer, r, session.Language)
-	if err != nil {
-		return
-	}
 
-	buf, err := templaterepository.GenerateText(models.NewDtoInvoiceTemplate(
+	dtoinvoicetemplate := models.NewDtoInvoiceTemplate(
 		*models.NewApiFullInvoice(dtoinvoice.ID, dtoinvoice.Company_ID, dtoinvoice.Created, dtoinvoice.VAT, dtoinvoice.Total,
-			*invoiceitems, dtoinvoice.Paid, dtoinvoice.PaidAt, !dtoinvoice.Active), apiseller.CompanyBanks[bankindex],
-		*templateseller, *templatebuyer, (*contracts)[contractindex]),
-		services.TEMPLATE_INVOICE, "", "")
-	if err != nil {
-		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
-			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
-		return
-	}
+			*invoiceitems, dtoinvoice.Paid, dtoinvoice.PaidAt, !dtoinvoice.Active), *dtobank,
+		*templateseller, *templatebuyer, (*contracts)[contractindex])
 
 	file := new(models.DtoFile)
 	file.Created = time.Now()
@@ -376,6 +330,27 @@ func GetExportInvoice(request *http.Request, r render.Render, params martini.Par
 		return
 	}
 
+	absfilepath, err := filepath.Abs(config.Configuration.FileStorage)
+	if err != nil {
+		log.Error("Can't make an absolute path for %v, %v", config.Configuration.FileStorage, err)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	if helpers.PDFRenderer() == helpers.PDF_RENDERER_NATIVE {
+		go helpers.ExportInvoicePDF(absfilepath, file, filerepository, dtoinvoicetemplate)
+		r.JSON(http.StatusOK, models.ApiFile{ID: file.ID})
+		return
+	}
+
+	buf, err := templaterepository.GenerateText(dtoinvoicetemplate, services.TEMPLATE_INVOICE, "", "")
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
 	filename := filepath.Join(config.Configuration.FileStorage, file.Path, fmt.Sprintf("%08d", file.ID))
 	err = os.Rename(filename, filename+".html")
 	if err != nil {
@@ -393,14 +368,6 @@ func GetExportInvoice(request *http.Request, r render.Render, params martini.Par
 		return
 	}
 
-	absfilepath, err := filepath.Abs(config.Configuration.FileStorage)
-	if err != nil {
-		log.Error("Can't make an absolute path for %v, %v", config.Configuration.FileStorage, err)
-		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
-			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
-		return
-	}
-
 	go helpers.HTMLtoPDF(absfilepath, file, filerepository)
 
 	r.JSON(http.StatusOK, models.ApiFile{ID: file.ID})
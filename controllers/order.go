@@ -5,6 +5,7 @@ import (
 	"application/helpers"
 	"application/models"
 	"application/services"
+	"application/workflows"
 	"github.com/go-martini/martini"
 	"github.com/martini-contrib/binding"
 	"github.com/martini-contrib/render"
@@ -146,6 +147,24 @@ func GetProjectOrder(r render.Render, params martini.Params, projectrepository s
 	r.JSON(http.StatusOK, models.NewApiLongOrderFromDto(dtoorder, dtoorderstatuses))
 }
 
+// get /api/v1.0/projects/:prid/orders/:oid/quote/
+func GetProjectOrderQuote(r render.Render, params martini.Params, projectrepository services.ProjectRepository,
+	orderrepository services.OrderRepository, orderworkflow *workflows.OrderWorkflow, session *models.DtoSession) {
+	_, dtoorder, err := helpers.CheckProjectOrder(r, params, projectrepository, orderrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	quote, err := orderworkflow.Quote(dtoorder)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, quote)
+}
+
 // patch /api/v1.0/projects/:prid/orders/:oid/
 func UpdateProjectOrder(errors binding.Errors, vieworder models.ViewMiddleOrder, r render.Render, params martini.Params,
 	projectrepository services.ProjectRepository, orderrepository services.OrderRepository, unitrepository services.UnitRepository,
@@ -297,6 +316,218 @@ func UpdateProjectSMSOrder(errors binding.Errors, viewsmsfacility models.ViewSMS
 	r.JSON(http.StatusOK, apismsfacility)
 }
 
+// get /api/v1.0/projects/:prid/orders/:oid/service/sms/schedule/
+func GetProjectSMSSchedule(r render.Render, params martini.Params, projectrepository services.ProjectRepository,
+	orderrepository services.OrderRepository, facilityrepository services.FacilityRepository,
+	smsschedulerepository services.SMSScheduleRepository, session *models.DtoSession) {
+	_, dtoorder, err := helpers.CheckProjectOrder(r, params, projectrepository, orderrepository, session.Language)
+	if err != nil {
+		return
+	}
+	err = helpers.CheckFacilityAlias(dtoorder.Facility_ID, models.SERVICE_TYPE_SMS, r, facilityrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	dtosmsschedule, err := smsschedulerepository.Get(dtoorder.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiSMSSchedule(dtosmsschedule.Next_Run, dtosmsschedule.Last_Run, dtosmsschedule.Runs,
+		dtosmsschedule.Paused, dtosmsschedule.Finished))
+}
+
+// put /api/v1.0/projects/:prid/orders/:oid/service/sms/schedule/
+func UpdateProjectSMSSchedule(errors binding.Errors, viewsmsschedule models.ViewSMSSchedule, r render.Render, params martini.Params,
+	projectrepository services.ProjectRepository, orderrepository services.OrderRepository, facilityrepository services.FacilityRepository,
+	smsschedulerepository services.SMSScheduleRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	_, dtoorder, err := helpers.CheckProjectOrder(r, params, projectrepository, orderrepository, session.Language)
+	if err != nil {
+		return
+	}
+	err = helpers.CheckFacilityAlias(dtoorder.Facility_ID, models.SERVICE_TYPE_SMS, r, facilityrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	dtosmsschedule, err := smsschedulerepository.Get(dtoorder.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	if dtosmsschedule.Finished {
+		log.Error("SMS schedule is finished for order %v", dtoorder.ID)
+		r.JSON(http.StatusConflict, types.Error{Code: types.TYPE_ERROR_DATA_CHANGES_DENIED,
+			Message: config.Localization[session.Language].Errors.Api.Data_Changes_Denied})
+		return
+	}
+
+	dtosmsschedule.Paused = viewsmsschedule.Paused
+	dtosmsschedule.Updated = time.Now()
+	err = smsschedulerepository.Update(dtosmsschedule)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiSMSSchedule(dtosmsschedule.Next_Run, dtosmsschedule.Last_Run, dtosmsschedule.Runs,
+		dtosmsschedule.Paused, dtosmsschedule.Finished))
+}
+
+// get /api/v1.0/projects/:prid/orders/:oid/service/sms/zones/
+func GetProjectSMSBatches(r render.Render, params martini.Params, projectrepository services.ProjectRepository,
+	orderrepository services.OrderRepository, facilityrepository services.FacilityRepository,
+	smsbatchrepository services.SMSBatchRepository, session *models.DtoSession) {
+	_, dtoorder, err := helpers.CheckProjectOrder(r, params, projectrepository, orderrepository, session.Language)
+	if err != nil {
+		return
+	}
+	err = helpers.CheckFacilityAlias(dtoorder.Facility_ID, models.SERVICE_TYPE_SMS, r, facilityrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	dtosmsbatches, err := smsbatchrepository.GetByOrder(dtoorder.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	apismsbatches := []models.ApiSMSBatch{}
+	for _, dtosmsbatch := range *dtosmsbatches {
+		apismsbatches = append(apismsbatches, *models.NewApiSMSBatch(helpers.FormatTimezone(dtosmsbatch.Offset), dtosmsbatch.Send_At,
+			dtosmsbatch.Sent_At, dtosmsbatch.Status.String(), dtosmsbatch.Recipients, dtosmsbatch.Sent, dtosmsbatch.Failed))
+	}
+
+	r.JSON(http.StatusOK, apismsbatches)
+}
+
+// get /api/v1.0/projects/:prid/orders/:oid/service/sms/preview/
+func GetProjectSMSPreview(request *http.Request, r render.Render, params martini.Params, projectrepository services.ProjectRepository,
+	orderrepository services.OrderRepository, facilityrepository services.FacilityRepository, smsworkflow *workflows.SMSWorkflow,
+	session *models.DtoSession) {
+	_, dtoorder, err := helpers.CheckProjectOrder(r, params, projectrepository, orderrepository, session.Language)
+	if err != nil {
+		return
+	}
+	err = helpers.CheckFacilityAlias(dtoorder.Facility_ID, models.SERVICE_TYPE_SMS, r, facilityrepository, session.Language)
+	if err != nil {
+		return
+	}
+	var samples int64 = helpers.SMS_PREVIEW_SAMPLES
+	value := request.URL.Query().Get(helpers.PARAM_QUERY_SAMPLES)
+	if value != "" {
+		samples, err = helpers.CheckParameterInt(r, value, session.Language)
+		if err != nil {
+			return
+		}
+		if samples > helpers.SMS_PREVIEW_MAX_SAMPLES {
+			samples = helpers.SMS_PREVIEW_MAX_SAMPLES
+		}
+	}
+
+	preview, err := smsworkflow.Preview(dtoorder, int(samples))
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, preview)
+}
+
+// get /api/v1.0/projects/:prid/orders/:oid/service/sms/routing/
+func GetProjectSMSRouting(r render.Render, params martini.Params, projectrepository services.ProjectRepository,
+	orderrepository services.OrderRepository, facilityrepository services.FacilityRepository,
+	orderroutingrepository services.OrderRoutingRepository, orderrouterepository services.OrderRouteRepository, session *models.DtoSession) {
+	_, dtoorder, err := helpers.CheckProjectOrder(r, params, projectrepository, orderrepository, session.Language)
+	if err != nil {
+		return
+	}
+	err = helpers.CheckFacilityAlias(dtoorder.Facility_ID, models.SERVICE_TYPE_SMS, r, facilityrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	dtoorderrouting, err := orderroutingrepository.Get(dtoorder.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	apiorderrouting, err := helpers.GetOrderRouting(dtoorderrouting, r, orderrouterepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, apiorderrouting)
+}
+
+// put /api/v1.0/projects/:prid/orders/:oid/service/sms/routing/
+func UpdateProjectSMSRouting(errors binding.Errors, vieworderrouting models.ViewOrderRouting, r render.Render, params martini.Params,
+	projectrepository services.ProjectRepository, orderrepository services.OrderRepository, facilityrepository services.FacilityRepository,
+	smsbatchrepository services.SMSBatchRepository, orderroutingrepository services.OrderRoutingRepository,
+	orderrouterepository services.OrderRouteRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	_, dtoorder, err := helpers.CheckProjectOrder(r, params, projectrepository, orderrepository, session.Language)
+	if err != nil {
+		return
+	}
+	err = helpers.CheckFacilityAlias(dtoorder.Facility_ID, models.SERVICE_TYPE_SMS, r, facilityrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	dtosmsbatches, err := smsbatchrepository.GetByOrder(dtoorder.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	for _, dtosmsbatch := range *dtosmsbatches {
+		if dtosmsbatch.Status != models.SMS_BATCH_STATUS_NEW {
+			log.Error("SMS sending is in progress for order %v", dtoorder.ID)
+			r.JSON(http.StatusConflict, types.Error{Code: types.TYPE_ERROR_DATA_CHANGES_DENIED,
+				Message: config.Localization[session.Language].Errors.Api.Data_Changes_Denied})
+			return
+		}
+	}
+
+	dtoorderrouting, err := orderroutingrepository.Get(dtoorder.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	if dtoorderrouting.Created.IsZero() {
+		dtoorderrouting.Created = time.Now()
+	}
+	dtoorderrouting.Policy = models.ParseRoutingPolicy(vieworderrouting.Policy)
+	dtoorderrouting.Updated = time.Now()
+	err = orderroutingrepository.Save(dtoorderrouting)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	apiorderrouting, err := helpers.GetOrderRouting(dtoorderrouting, r, orderrouterepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, apiorderrouting)
+}
+
 // get /api/v1.0/projects/:prid/orders/:oid/service/hlr/
 func GetProjectHLROrder(r render.Render, params martini.Params, projectrepository services.ProjectRepository, orderrepository services.OrderRepository,
 	facilityrepository services.FacilityRepository, hlrfacilityrepository services.HLRFacilityRepository,
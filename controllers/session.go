@@ -43,8 +43,10 @@ func Ping(request *http.Request, r render.Render, params martini.Params, session
 }
 
 // post /api/v1.0/session/user/
-func CreateSession(errors binding.Errors, viewsession models.ViewSession, r render.Render,
-	userrepository services.UserRepository, sessionrepository services.SessionRepository, captcharepository services.CaptchaRepository) {
+func CreateSession(errors binding.Errors, viewsession models.ViewSession, request *http.Request, r render.Render,
+	userrepository services.UserRepository, sessionrepository services.SessionRepository, captcharepository services.CaptchaRepository,
+	twofactorrepository services.TwoFactorRepository, twofactorchallengerepository services.TwoFactorChallengeRepository,
+	mobilephonerepository services.MobilePhoneRepository, devicerepository services.DeviceRepository) {
 	if helpers.CheckValidation(errors, r, config.Configuration.Server.DefaultLanguage) != nil {
 		return
 	}
@@ -89,19 +91,41 @@ func CreateSession(errors binding.Errors, viewsession models.ViewSession, r rend
 		return
 	}
 
+	apichallenge, err := helpers.StartTwoFactorLogin(user, viewsession.DeviceToken, language, r, twofactorrepository,
+		twofactorchallengerepository, mobilephonerepository, devicerepository, sessionrepository)
+	if err != nil {
+		return
+	}
+	if apichallenge != nil {
+		r.JSON(http.StatusAccepted, apichallenge)
+		return
+	}
+
+	apisession, err := createUserSession(user, language, request, viewsession.Device, r, userrepository, sessionrepository)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, apisession)
+}
+
+// Создание сессии пользователя, прошедшего аутентификацию
+func createUserSession(user *models.DtoUser, language string, request *http.Request, device string, r render.Render,
+	userrepository services.UserRepository, sessionrepository services.SessionRepository) (apisession *models.ApiSession, err error) {
 	token, err := sessionrepository.GenerateToken(helpers.TOKEN_LENGTH)
 	if err != nil {
 		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
 			Message: config.Localization[language].Errors.Api.Data_Wrong})
-		return
+		return nil, err
 	}
 	dtosession := models.NewDtoSession(token, user.ID, user.Roles, time.Now(), language)
+	helpers.SetSessionMetadata(dtosession, request, device)
 
 	err = sessionrepository.Create(dtosession, true)
 	if err != nil {
 		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
 			Message: config.Localization[language].Errors.Api.Data_Wrong})
-		return
+		return nil, err
 	}
 
 	user.LastLogin = dtosession.LastActivity
@@ -110,15 +134,15 @@ func CreateSession(errors binding.Errors, viewsession models.ViewSession, r rend
 	if err != nil {
 		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
 			Message: config.Localization[language].Errors.Api.Data_Wrong})
-		return
+		return nil, err
 	}
 
-	r.JSON(http.StatusOK, models.NewApiSession(dtosession.LastActivity.Add(config.Configuration.Server.SessionTimeout), dtosession.AccessToken))
+	return models.NewApiSession(services.GetSessionExpires(dtosession), dtosession.AccessToken), nil
 }
 
 // delete /api/v1.0/session/:token
 func DeleteSession(r render.Render, sessionrepository services.SessionRepository, session *models.DtoSession) {
-	err := sessionrepository.Delete(session.AccessToken, true)
+	err := sessionrepository.Delete(session.Hash, true)
 	if err != nil {
 		middlewares.GeneratingSessionErrorResponse(r, session.AccessToken)
 		return
@@ -5,6 +5,7 @@ import (
 	"application/helpers"
 	"application/models"
 	"application/services"
+	"application/workflows"
 	"github.com/go-martini/martini"
 	"github.com/martini-contrib/binding"
 	"github.com/martini-contrib/render"
@@ -412,3 +413,54 @@ func UpdateHeaderOrder(errors binding.Errors, viewheaderfacility models.ViewHead
 
 	r.JSON(http.StatusOK, apiheaderfacility)
 }
+
+// post /api/v1.0/suppliers/replies/
+func CreateSMSReply(errors binding.Errors, viewsmsreply models.ViewSMSReply, r render.Render, userrepository services.UserRepository,
+	columntyperepository services.ColumnTypeRepository, suppressionworkflow *workflows.SuppressionWorkflow, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	mobilephone, err := helpers.NormalizeMobilePhone(viewsmsreply.MobilePhone, columntyperepository)
+	if err != nil {
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	suppressed, unit_ids, err := suppressionworkflow.HandleReply(user.UnitID, viewsmsreply.Sender, mobilephone, viewsmsreply.Message)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiSMSReply(suppressed, unit_ids))
+}
+
+// post /api/v1.0/suppliers/reports/
+func CreateDeliveryReports(errors binding.Errors, viewdeliveryreports models.ViewDeliveryReports, r render.Render,
+	userrepository services.UserRepository, deliveryreportrepository services.DeliveryReportRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	apideliveryreports, err := helpers.CreateDeliveryReports(user.UnitID, &viewdeliveryreports, r, deliveryreportrepository,
+		session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, apideliveryreports)
+}
@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/binding"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"types"
+)
+
+// get /api/v1.0/unit/suppressions/
+func GetSuppressions(w http.ResponseWriter, r render.Render, userrepository services.UserRepository,
+	suppressionrepository services.SuppressionRepository, session *models.DtoSession) {
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	helpers.GetSuppressions(user.UnitID, w, r, suppressionrepository, session.Language)
+}
+
+// post /api/v1.0/unit/suppressions/
+func CreateSuppression(errors binding.Errors, viewsuppression models.ViewSuppression, r render.Render,
+	userrepository services.UserRepository, columntyperepository services.ColumnTypeRepository,
+	suppressionrepository services.SuppressionRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	apisuppression, err := helpers.CreateSuppression(user.UnitID, &viewsuppression, r, columntyperepository, suppressionrepository,
+		session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, apisuppression)
+}
+
+// post /api/v1.0/unit/suppressions/import/
+func ImportSuppressions(errors binding.Errors, viewimportsuppressions models.ViewImportSuppressions, r render.Render,
+	userrepository services.UserRepository, customertablerepository services.CustomerTableRepository,
+	columntyperepository services.ColumnTypeRepository, tablecolumnrepository services.TableColumnRepository,
+	tablerowrepository services.TableRowRepository, suppressionrepository services.SuppressionRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	apisuppressionimport, err := helpers.ImportSuppressions(user.UnitID, &viewimportsuppressions, r, customertablerepository,
+		columntyperepository, tablecolumnrepository, tablerowrepository, suppressionrepository, true, session.UserID, session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, apisuppressionimport)
+}
+
+// delete /api/v1.0/unit/suppressions/:sid/
+func DeleteSuppression(r render.Render, params martini.Params, userrepository services.UserRepository,
+	suppressionrepository services.SuppressionRepository, session *models.DtoSession) {
+	suppression_id, err := helpers.CheckParameterInt(r, params[helpers.PARAM_NAME_SUPPRESSION_ID], session.Language)
+	if err != nil {
+		return
+	}
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	err = helpers.DeleteSuppression(user.UnitID, suppression_id, r, suppressionrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, types.ResponseOK{Message: config.Localization[session.Language].Messages.OK})
+}
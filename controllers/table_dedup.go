@@ -0,0 +1,90 @@
+package controllers
+
+import (
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/binding"
+	"github.com/martini-contrib/render"
+	"net/http"
+)
+
+// post /api/v1.0/tables/:tid/dedup/
+func CreateTableDedup(errors binding.Errors, viewtablededup models.ViewTableDedup, r render.Render, params martini.Params,
+	customertablerepository services.CustomerTableRepository, tablecolumnrepository services.TableColumnRepository,
+	tablerowrepository services.TableRowRepository, tablededuprepository services.TableDedupRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	dtocustomertable, err := helpers.CheckTable(r, params, customertablerepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	apitablededup, err := helpers.CreateTableDedup(dtocustomertable.ID, &viewtablededup, r, tablecolumnrepository, tablerowrepository,
+		tablededuprepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, apitablededup)
+}
+
+// options /api/v1.0/tables/:tid/dedup/:did/
+func GetTableDedupStatus(r render.Render, params martini.Params, customertablerepository services.CustomerTableRepository,
+	tablededuprepository services.TableDedupRepository, session *models.DtoSession) {
+	dtotablededup, err := checkTableDedup(r, params, customertablerepository, tablededuprepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, helpers.GetApiTableDedup(dtotablededup))
+}
+
+// get /api/v1.0/tables/:tid/dedup/:did/
+func GetTableDedupGroups(w http.ResponseWriter, r render.Render, params martini.Params,
+	customertablerepository services.CustomerTableRepository, tablecolumnrepository services.TableColumnRepository,
+	tablerowrepository services.TableRowRepository, tablededuprepository services.TableDedupRepository, session *models.DtoSession) {
+	dtotablededup, err := checkTableDedup(r, params, customertablerepository, tablededuprepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	helpers.GetTableDedupGroups(dtotablededup, w, r, tablecolumnrepository, tablerowrepository, tablededuprepository, session.Language)
+}
+
+// post /api/v1.0/tables/:tid/dedup/:did/merge/
+func MergeTableDedup(errors binding.Errors, viewtablemerge models.ViewTableMerge, r render.Render, params martini.Params,
+	customertablerepository services.CustomerTableRepository, tablecolumnrepository services.TableColumnRepository,
+	tablerowrepository services.TableRowRepository, tablededuprepository services.TableDedupRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	dtotablededup, err := checkTableDedup(r, params, customertablerepository, tablededuprepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	apitablededup, err := helpers.MergeTableDedup(dtotablededup, &viewtablemerge, r, tablecolumnrepository, tablerowrepository,
+		tablededuprepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, apitablededup)
+}
+
+func checkTableDedup(r render.Render, params martini.Params, customertablerepository services.CustomerTableRepository,
+	tablededuprepository services.TableDedupRepository, language string) (dtotablededup *models.DtoTableDedup, err error) {
+	dtocustomertable, err := helpers.CheckTable(r, params, customertablerepository, language)
+	if err != nil {
+		return nil, err
+	}
+	dedupid, err := helpers.CheckParameterInt(r, params[helpers.PARAM_NAME_TABLE_DEDUP_ID], language)
+	if err != nil {
+		return nil, err
+	}
+
+	return helpers.CheckTableDedup(dtocustomertable.ID, dedupid, r, tablededuprepository, language)
+}
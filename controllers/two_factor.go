@@ -0,0 +1,434 @@
+package controllers
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"errors"
+	"fmt"
+	"github.com/martini-contrib/binding"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"time"
+	"types"
+)
+
+// post /api/v1.0/session/twofactor/
+func VerifySessionTwoFactor(errors binding.Errors, viewtwofactor models.ViewTwoFactorLogin, request *http.Request, r render.Render,
+	userrepository services.UserRepository, sessionrepository services.SessionRepository,
+	twofactorrepository services.TwoFactorRepository, twofactorchallengerepository services.TwoFactorChallengeRepository,
+	mobilephonerepository services.MobilePhoneRepository, devicerepository services.DeviceRepository) {
+	if helpers.CheckValidation(errors, r, config.Configuration.Server.DefaultLanguage) != nil {
+		return
+	}
+	dtochallenge, err := helpers.CheckTwoFactorChallenge(r, viewtwofactor.Token, models.TWO_FACTOR_CHALLENGE_LOGIN,
+		config.Configuration.Server.DefaultLanguage, twofactorchallengerepository)
+	if err != nil {
+		return
+	}
+	user, methods, _, err := getTwoFactorUser(dtochallenge, r, userrepository, twofactorrepository, mobilephonerepository)
+	if err != nil {
+		return
+	}
+	if helpers.VerifyTwoFactorCode(dtochallenge, viewtwofactor.Method, viewtwofactor.Code, methods, r,
+		twofactorrepository, twofactorchallengerepository) != nil {
+		return
+	}
+
+	apisession, err := createUserSession(user, dtochallenge.Language, request, viewtwofactor.OS, r, userrepository, sessionrepository)
+	if err != nil {
+		return
+	}
+	if viewtwofactor.TrustDevice {
+		apisession.DeviceToken, err = helpers.TrustTwoFactorDevice(user.ID, viewtwofactor.OS, devicerepository, sessionrepository)
+		if err != nil {
+			r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+				Message: config.Localization[dtochallenge.Language].Errors.Api.Data_Wrong})
+			return
+		}
+	}
+
+	r.JSON(http.StatusOK, apisession)
+}
+
+// post /api/v1.0/session/twofactor/sms/
+func ResendSessionTwoFactorCode(errors binding.Errors, viewtwofactor models.ViewTwoFactorToken, r render.Render,
+	userrepository services.UserRepository, twofactorrepository services.TwoFactorRepository,
+	twofactorchallengerepository services.TwoFactorChallengeRepository, mobilephonerepository services.MobilePhoneRepository) {
+	if helpers.CheckValidation(errors, r, config.Configuration.Server.DefaultLanguage) != nil {
+		return
+	}
+	dtochallenge, err := helpers.CheckTwoFactorChallenge(r, viewtwofactor.Token, models.TWO_FACTOR_CHALLENGE_LOGIN,
+		config.Configuration.Server.DefaultLanguage, twofactorchallengerepository)
+	if err != nil {
+		return
+	}
+	user, methods, phone, err := getTwoFactorUser(dtochallenge, r, userrepository, twofactorrepository, mobilephonerepository)
+	if err != nil {
+		return
+	}
+	if methods[0] != models.TWO_FACTOR_METHOD_SMS {
+		log.Error("Two factor sms codes are not enabled for user %v", user.ID)
+		r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
+			Message: config.Localization[dtochallenge.Language].Errors.Api.Method_NotAllowed})
+		return
+	}
+	if helpers.ResendTwoFactorCode(dtochallenge, phone, r, twofactorchallengerepository) != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiTwoFactorChallenge(dtochallenge.Token, methods, dtochallenge.Expires))
+}
+
+// get /api/v1.0/user/twofactor/
+func GetTwoFactor(r render.Render, userrepository services.UserRepository, twofactorrepository services.TwoFactorRepository,
+	session *models.DtoSession) {
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	required, err := helpers.IsTwoFactorRequired(user, twofactorrepository)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	dtotwofactor, err := findTwoFactor(session.UserID, r, twofactorrepository, session.Language)
+	if err != nil {
+		return
+	}
+	if dtotwofactor == nil || !dtotwofactor.Enabled {
+		r.JSON(http.StatusOK, models.NewApiTwoFactor(false, "", required, 0))
+		return
+	}
+	count, err := twofactorrepository.CountRecoveryCodes(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiTwoFactor(true, dtotwofactor.Method, required, count))
+}
+
+// post /api/v1.0/user/twofactor/totp/
+func CreateTwoFactorTOTP(r render.Render, userrepository services.UserRepository, twofactorrepository services.TwoFactorRepository,
+	session *models.DtoSession) {
+	if checkTwoFactorDisabled(session, r, twofactorrepository) != nil {
+		return
+	}
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	account := fmt.Sprintf("%v", user.ID)
+	if user.Emails != nil {
+		for _, email := range *user.Emails {
+			if email.Primary {
+				account = email.Email
+			}
+		}
+	}
+
+	secret, err := helpers.GenerateTOTPSecret()
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+	uri := helpers.TOTPURI(account, secret)
+	qrcode, err := helpers.TOTPQRCode(uri)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+	err = twofactorrepository.Save(models.NewDtoTwoFactor(session.UserID, models.TWO_FACTOR_METHOD_TOTP, secret, "", false, 0, time.Now()))
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiTwoFactorTOTP(secret, uri, qrcode))
+}
+
+// post /api/v1.0/user/twofactor/sms/
+func CreateTwoFactorSMS(r render.Render, sessionrepository services.SessionRepository,
+	twofactorrepository services.TwoFactorRepository, twofactorchallengerepository services.TwoFactorChallengeRepository,
+	mobilephonerepository services.MobilePhoneRepository, session *models.DtoSession) {
+	if checkTwoFactorDisabled(session, r, twofactorrepository) != nil {
+		return
+	}
+	phone, err := helpers.GetTwoFactorPhone(session.UserID, mobilephonerepository)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_PRIMARY_MOBILEPHONE_NOTCONFIRMED,
+			Message: config.Localization[session.Language].Errors.Api.PrimaryMobilePhone_NotConfirmed})
+		return
+	}
+	err = twofactorrepository.Save(models.NewDtoTwoFactor(session.UserID, models.TWO_FACTOR_METHOD_SMS, "", phone, false, 0, time.Now()))
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+	dtochallenge, err := helpers.CreateTwoFactorChallenge(session.UserID, models.TWO_FACTOR_CHALLENGE_ENROLMENT, phone,
+		session.Language, sessionrepository, twofactorchallengerepository)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiTwoFactorChallenge(dtochallenge.Token, []string{models.TWO_FACTOR_METHOD_SMS}, dtochallenge.Expires))
+}
+
+// put /api/v1.0/user/twofactor/
+func ConfirmTwoFactor(errors binding.Errors, viewtwofactor models.ViewTwoFactorCode, r render.Render,
+	twofactorrepository services.TwoFactorRepository, twofactorchallengerepository services.TwoFactorChallengeRepository,
+	session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	dtotwofactor, err := findTwoFactor(session.UserID, r, twofactorrepository, session.Language)
+	if err != nil {
+		return
+	}
+	if dtotwofactor == nil || dtotwofactor.Enabled {
+		log.Error("There is no pending two factor for user %v", session.UserID)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	switch dtotwofactor.Method {
+	case models.TWO_FACTOR_METHOD_TOTP:
+		counter, valid := helpers.CheckTOTPCode(dtotwofactor.Secret, viewtwofactor.Code, time.Now())
+		if !valid {
+			log.Error("Wrong two factor code for user %v", session.UserID)
+			r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_CONFIRMATION_CODE_WRONG,
+				Message: config.Localization[session.Language].Errors.Api.Confirmation_Code_Wrong})
+			return
+		}
+		dtotwofactor.Counter = counter
+	case models.TWO_FACTOR_METHOD_SMS:
+		dtochallenge, err := helpers.CheckTwoFactorChallenge(r, viewtwofactor.Token, models.TWO_FACTOR_CHALLENGE_ENROLMENT,
+			session.Language, twofactorchallengerepository)
+		if err != nil {
+			return
+		}
+		if dtochallenge.User_ID != session.UserID {
+			log.Error("Two factor challenge %v belongs to another user", dtochallenge.User_ID)
+			r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+				Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+			return
+		}
+		if helpers.VerifyTwoFactorCode(dtochallenge, models.TWO_FACTOR_METHOD_SMS, viewtwofactor.Code,
+			[]string{models.TWO_FACTOR_METHOD_SMS}, r, twofactorrepository, twofactorchallengerepository) != nil {
+			return
+		}
+	}
+
+	dtotwofactor.Enabled = true
+	err = twofactorrepository.Save(dtotwofactor)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	renderRecoveryCodes(session, r, twofactorrepository)
+}
+
+// post /api/v1.0/user/twofactor/recovery/
+func CreateRecoveryCodes(errors binding.Errors, viewtwofactor models.ViewTwoFactorCode, r render.Render,
+	twofactorrepository services.TwoFactorRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	if checkTwoFactorCode(viewtwofactor.Code, session, r, twofactorrepository) != nil {
+		return
+	}
+
+	renderRecoveryCodes(session, r, twofactorrepository)
+}
+
+// post /api/v1.0/user/twofactor/disable/
+func DisableTwoFactor(errors binding.Errors, viewtwofactor models.ViewTwoFactorCode, r render.Render,
+	twofactorrepository services.TwoFactorRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	if checkTwoFactorCode(viewtwofactor.Code, session, r, twofactorrepository) != nil {
+		return
+	}
+
+	err := twofactorrepository.Delete(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, types.ResponseOK{Message: config.Localization[session.Language].Messages.OK})
+}
+
+// get /api/v1.0/user/administration/twofactor/
+func GetUnitTwoFactorPolicy(r render.Render, userrepository services.UserRepository,
+	twofactorrepository services.TwoFactorRepository, session *models.DtoSession) {
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	required, err := twofactorrepository.IsRequiredByUnit(user.UnitID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiTwoFactorPolicy(required))
+}
+
+// put /api/v1.0/user/administration/twofactor/
+func UpdateUnitTwoFactorPolicy(errors binding.Errors, viewpolicy models.ViewTwoFactorPolicy, r render.Render,
+	userrepository services.UserRepository, twofactorrepository services.TwoFactorRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	err = twofactorrepository.SetRequiredByUnit(user.UnitID, viewpolicy.Required)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiTwoFactorPolicy(viewpolicy.Required))
+}
+
+// Пользователь попытки входа, доступные ему способы подтверждения и телефон для SMS кодов
+func getTwoFactorUser(dtochallenge *models.DtoTwoFactorChallenge, r render.Render, userrepository services.UserRepository,
+	twofactorrepository services.TwoFactorRepository,
+	mobilephonerepository services.MobilePhoneRepository) (user *models.DtoUser, methods []string, phone string, err error) {
+	user, err = userrepository.Get(dtochallenge.User_ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[dtochallenge.Language].Errors.Api.Object_NotExist})
+		return nil, nil, "", err
+	}
+	if !user.Active || !user.Confirmed {
+		log.Error("User is not active or confirmed %v", user.ID)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_USER_BLOCKED,
+			Message: config.Localization[dtochallenge.Language].Errors.Api.User_Blocked})
+		return nil, nil, "", errors.New("User blocked")
+	}
+	methods, phone, err = helpers.GetTwoFactorMethods(user, twofactorrepository, mobilephonerepository)
+	if err == nil && len(methods) == 0 {
+		err = errors.New("Two factor is not required")
+	}
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[dtochallenge.Language].Errors.Api.Data_Wrong})
+		return nil, nil, "", err
+	}
+
+	return user, methods, phone, nil
+}
+
+// Второй фактор пользователя или nil, если он не подключался
+func findTwoFactor(userid int64, r render.Render, twofactorrepository services.TwoFactorRepository,
+	language string) (dtotwofactor *models.DtoTwoFactor, err error) {
+	found, err := twofactorrepository.Exists(userid)
+	if err == nil && found {
+		dtotwofactor, err = twofactorrepository.Get(userid)
+	}
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+
+	return dtotwofactor, nil
+}
+
+// Подключение нового способа возможно только после отключения действующего
+func checkTwoFactorDisabled(session *models.DtoSession, r render.Render, twofactorrepository services.TwoFactorRepository) (err error) {
+	dtotwofactor, err := findTwoFactor(session.UserID, r, twofactorrepository, session.Language)
+	if err != nil {
+		return err
+	}
+	if dtotwofactor != nil && dtotwofactor.Enabled {
+		log.Error("Two factor is already enabled for user %v", session.UserID)
+		r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_DATA_CHANGES_DENIED,
+			Message: config.Localization[session.Language].Errors.Api.Data_Changes_Denied})
+		return errors.New("Two factor enabled")
+	}
+
+	return nil
+}
+
+// Проверка действующего второго фактора одноразовым паролем приложения или кодом восстановления
+func checkTwoFactorCode(code string, session *models.DtoSession, r render.Render,
+	twofactorrepository services.TwoFactorRepository) (err error) {
+	dtotwofactor, err := findTwoFactor(session.UserID, r, twofactorrepository, session.Language)
+	if err != nil {
+		return err
+	}
+	if dtotwofactor == nil || !dtotwofactor.Enabled {
+		log.Error("Two factor is not enabled for user %v", session.UserID)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return errors.New("Two factor disabled")
+	}
+
+	valid := false
+	if dtotwofactor.Method == models.TWO_FACTOR_METHOD_TOTP {
+		if counter, ok := helpers.CheckTOTPCode(dtotwofactor.Secret, code, time.Now()); ok {
+			valid, err = twofactorrepository.UseCounter(session.UserID, counter)
+		}
+	}
+	if err == nil && !valid {
+		valid, err = twofactorrepository.UseRecoveryCode(session.UserID, helpers.HashTwoFactorCode(code))
+	}
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return err
+	}
+	if !valid {
+		log.Error("Wrong two factor code for user %v", session.UserID)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_CONFIRMATION_CODE_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Confirmation_Code_Wrong})
+		return errors.New("Wrong code")
+	}
+
+	return nil
+}
+
+// Выпуск новых кодов восстановления взамен прежних
+func renderRecoveryCodes(session *models.DtoSession, r render.Render, twofactorrepository services.TwoFactorRepository) {
+	codes, hashes, err := helpers.GenerateRecoveryCodes()
+	if err == nil {
+		err = twofactorrepository.SaveRecoveryCodes(session.UserID, hashes)
+	}
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiRecoveryCodes(codes))
+}
@@ -0,0 +1,167 @@
+package controllers
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/binding"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"time"
+	"types"
+)
+
+// options /api/v1.0/user/administration/roles/
+func GetPermissions(r render.Render) {
+	r.JSON(http.StatusOK, models.NewApiPermissions(models.PERMISSIONS))
+}
+
+// get /api/v1.0/user/administration/roles/
+func GetUnitRoles(w http.ResponseWriter, r render.Render, userrepository services.UserRepository,
+	unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
+	dtouser, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+	dtounitroles, err := unitrolerepository.GetByUnit(dtouser.UnitID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	roles := []models.ApiUnitRole{}
+	for _, dtounitrole := range *dtounitroles {
+		roles = append(roles, *models.NewApiUnitRole(dtounitrole.ID, dtounitrole.Name, dtounitrole.Permissions, dtounitrole.Created))
+	}
+
+	helpers.RenderJSONArray(roles, len(roles), w, r)
+}
+
+// post /api/v1.0/user/administration/roles/
+func CreateUnitRole(errors binding.Errors, viewunitrole models.ViewUnitRole, r render.Render,
+	userrepository services.UserRepository, unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	if helpers.CheckPermissions(viewunitrole.Permissions, session.Language, r) != nil {
+		return
+	}
+	dtouser, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	dtounitrole := models.NewDtoUnitRole(0, dtouser.UnitID, viewunitrole.Name, viewunitrole.Permissions, time.Now())
+	err = unitrolerepository.Create(dtounitrole)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiUnitRole(dtounitrole.ID, dtounitrole.Name, dtounitrole.Permissions, dtounitrole.Created))
+}
+
+// get /api/v1.0/user/administration/roles/:roleId/
+func GetUnitRole(r render.Render, params martini.Params, userrepository services.UserRepository,
+	unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
+	dtounitrole, err := helpers.CheckUnitRole(r, params, userrepository, unitrolerepository, session)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiUnitRole(dtounitrole.ID, dtounitrole.Name, dtounitrole.Permissions, dtounitrole.Created))
+}
+
+// put /api/v1.0/user/administration/roles/:roleId/
+func UpdateUnitRole(errors binding.Errors, viewunitrole models.ViewUnitRole, r render.Render, params martini.Params,
+	userrepository services.UserRepository, unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	dtounitrole, err := helpers.CheckUnitRole(r, params, userrepository, unitrolerepository, session)
+	if err != nil {
+		return
+	}
+	if helpers.CheckPermissions(viewunitrole.Permissions, session.Language, r) != nil {
+		return
+	}
+
+	dtounitrole.Name = viewunitrole.Name
+	dtounitrole.Permissions = viewunitrole.Permissions
+	err = unitrolerepository.Update(dtounitrole)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiUnitRole(dtounitrole.ID, dtounitrole.Name, dtounitrole.Permissions, dtounitrole.Created))
+}
+
+// delete /api/v1.0/user/administration/roles/:roleId/
+func DeleteUnitRole(r render.Render, params martini.Params, userrepository services.UserRepository,
+	unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
+	dtounitrole, err := helpers.CheckUnitRole(r, params, userrepository, unitrolerepository, session)
+	if err != nil {
+		return
+	}
+	err = unitrolerepository.Delete(dtounitrole.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, types.ResponseOK{Message: config.Localization[session.Language].Messages.OK})
+}
+
+// get /api/v1.0/user/administration/users/:uid/roles/
+func GetUnitUserRoles(r render.Render, params martini.Params, userrepository services.UserRepository,
+	unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
+	dtouser, err := helpers.CheckUnitUser(session.UserID, r, params, userrepository, session.Language)
+	if err != nil {
+		return
+	}
+	roleids, err := unitrolerepository.GetByUser(dtouser.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiUnitUserRoles(roleids))
+}
+
+// put /api/v1.0/user/administration/users/:uid/roles/
+func UpdateUnitUserRoles(errors binding.Errors, viewroles models.ViewUnitUserRoles, r render.Render, params martini.Params,
+	userrepository services.UserRepository, unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	dtouser, err := helpers.CheckUnitUser(session.UserID, r, params, userrepository, session.Language)
+	if err != nil {
+		return
+	}
+	if helpers.CheckUnitRoles(viewroles.Roles, dtouser.UnitID, unitrolerepository, session.Language, r) != nil {
+		return
+	}
+	if viewroles.Roles == nil {
+		viewroles.Roles = []int64{}
+	}
+	err = unitrolerepository.SetByUser(dtouser.ID, viewroles.Roles)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, models.NewApiUnitUserRoles(viewroles.Roles))
+}
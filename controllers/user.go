@@ -214,7 +214,9 @@ func RestorePassword(errors binding.Errors, viewuser models.ViewUser, request *h
 // put /api/v1.0/users/password/:code/
 func UpdatePassword(errors binding.Errors, password models.PasswordUpdate, request *http.Request, r render.Render, params martini.Params,
 	userrepository services.UserRepository, sessionrepository services.SessionRepository, emailrepository services.EmailRepository,
-	templaterepository services.TemplateRepository, accesslogrepository services.AccessLogRepository) {
+	templaterepository services.TemplateRepository, accesslogrepository services.AccessLogRepository,
+	twofactorrepository services.TwoFactorRepository, twofactorchallengerepository services.TwoFactorChallengeRepository,
+	mobilephonerepository services.MobilePhoneRepository, devicerepository services.DeviceRepository) {
 	code := params[helpers.PARAMETER_NAME_CODE]
 	if len(code) > helpers.PARAM_LENGTH_MAX {
 		log.Error("Wrong parameter length %v", code)
@@ -312,6 +314,25 @@ func UpdatePassword(errors binding.Errors, password models.PasswordUpdate, reque
 		}
 	}
 
+	// После восстановления пароля прежние сессии пользователя перестают действовать
+	err = sessionrepository.DeleteByUser(user.ID, nil)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[user.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	// Код из письма подтверждает только владение почтой, второй фактор проверяется так же, как при входе
+	apichallenge, err := helpers.StartTwoFactorLogin(user, "", user.Language, r, twofactorrepository,
+		twofactorchallengerepository, mobilephonerepository, devicerepository, sessionrepository)
+	if err != nil {
+		return
+	}
+	if apichallenge != nil {
+		r.JSON(http.StatusAccepted, apichallenge)
+		return
+	}
+
 	token, err := sessionrepository.GenerateToken(helpers.TOKEN_LENGTH)
 	if err != nil {
 		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
@@ -320,6 +341,7 @@ func UpdatePassword(errors binding.Errors, password models.PasswordUpdate, reque
 	}
 
 	session := models.NewDtoSession(token, user.ID, user.Roles, user.LastLogin, user.Language)
+	helpers.SetSessionMetadata(session, request, "")
 	err = sessionrepository.Create(session, true)
 	if err != nil {
 		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
@@ -327,7 +349,7 @@ func UpdatePassword(errors binding.Errors, password models.PasswordUpdate, reque
 		return
 	}
 
-	r.JSON(http.StatusOK, models.NewApiSession(session.LastActivity.Add(config.Configuration.Server.SessionTimeout), session.AccessToken))
+	r.JSON(http.StatusOK, models.NewApiSession(services.GetSessionExpires(session), session.AccessToken))
 }
 
 // head /api/v1.0/users/password/:code/
@@ -606,7 +628,7 @@ func UpdateUserEmails(w http.ResponseWriter, errors binding.Errors, updateemails
 
 // patch /api/v1.0/user/password/
 func ChangePassword(errors binding.Errors, changepassword models.ChangePassword, r render.Render,
-	userrepository services.UserRepository, session *models.DtoSession) {
+	userrepository services.UserRepository, sessionrepository services.SessionRepository, session *models.DtoSession) {
 	if helpers.CheckValidation(errors, r, session.Language) != nil {
 		return
 	}
@@ -652,6 +674,13 @@ func ChangePassword(errors binding.Errors, changepassword models.ChangePassword,
 			Message: config.Localization[user.Language].Errors.Api.Data_Wrong})
 		return
 	}
+	// Остальные сессии пользователя завершаются, текущая сохраняется
+	err = sessionrepository.DeleteOthers(user.ID, session.Hash)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[user.Language].Errors.Api.Data_Wrong})
+		return
+	}
 
 	r.JSON(http.StatusOK, types.ResponseOK{Message: config.Localization[session.Language].Messages.OK})
 }
@@ -0,0 +1,78 @@
+package controllers
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"types"
+)
+
+// get /api/v1.0/user/sessions/
+func GetUserSessions(w http.ResponseWriter, r render.Render, sessionrepository services.SessionRepository,
+	session *models.DtoSession) {
+	dtosessions, err := sessionrepository.GetByUser(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	sessions := []models.ApiSessionInfo{}
+	for _, dtosession := range *dtosessions {
+		sessions = append(sessions, *models.NewApiSessionInfo(dtosession.Hash, dtosession.Hash == session.Hash,
+			dtosession.IP_Address, dtosession.User_Agent, dtosession.Device, dtosession.Created, dtosession.LastActivity,
+			services.GetSessionExpires(&dtosession)))
+	}
+
+	helpers.RenderJSONArray(sessions, len(sessions), w, r)
+}
+
+// delete /api/v1.0/user/sessions/
+func DeleteUserSessions(r render.Render, sessionrepository services.SessionRepository, session *models.DtoSession) {
+	err := sessionrepository.DeleteOthers(session.UserID, session.Hash)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, types.ResponseOK{Message: config.Localization[session.Language].Messages.OK})
+}
+
+// delete /api/v1.0/user/sessions/:sessionId/
+func DeleteUserSession(r render.Render, params martini.Params, sessionrepository services.SessionRepository,
+	session *models.DtoSession) {
+	dtosession, err := helpers.CheckUserSession(r, params, sessionrepository, session)
+	if err != nil {
+		return
+	}
+	err = sessionrepository.Delete(dtosession.Hash, true)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, types.ResponseOK{Message: config.Localization[session.Language].Messages.OK})
+}
+
+// delete /api/v1.0/user/administration/users/:uid/sessions/
+func DeleteUnitUserSessions(r render.Render, params martini.Params, userrepository services.UserRepository,
+	sessionrepository services.SessionRepository, session *models.DtoSession) {
+	dtouser, err := helpers.CheckUnitUser(session.UserID, r, params, userrepository, session.Language)
+	if err != nil {
+		return
+	}
+	err = sessionrepository.DeleteByUser(dtouser.ID, nil)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return
+	}
+
+	r.JSON(http.StatusOK, types.ResponseOK{Message: config.Localization[session.Language].Messages.OK})
+}
@@ -0,0 +1,112 @@
+package controllers
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/binding"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"types"
+)
+
+// get /api/v1.0/unit/webhooks/
+func GetWebhooks(w http.ResponseWriter, r render.Render, userrepository services.UserRepository,
+	webhookrepository services.WebhookRepository, session *models.DtoSession) {
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	helpers.GetWebhooks(user.UnitID, w, r, webhookrepository, session.Language)
+}
+
+// post /api/v1.0/unit/webhooks/
+func CreateWebhook(errors binding.Errors, viewwebhook models.ViewWebhook, r render.Render, userrepository services.UserRepository,
+	webhookrepository services.WebhookRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	apiwebhook, err := helpers.CreateWebhook(user.UnitID, &viewwebhook, r, webhookrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, apiwebhook)
+}
+
+// put /api/v1.0/unit/webhooks/:whid/
+func UpdateWebhook(errors binding.Errors, viewwebhook models.ViewWebhook, r render.Render, params martini.Params,
+	userrepository services.UserRepository, webhookrepository services.WebhookRepository, session *models.DtoSession) {
+	if helpers.CheckValidation(errors, r, session.Language) != nil {
+		return
+	}
+	webhook_id, err := helpers.CheckParameterInt(r, params[helpers.PARAM_NAME_WEBHOOK_ID], session.Language)
+	if err != nil {
+		return
+	}
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	apiwebhook, err := helpers.UpdateWebhook(user.UnitID, webhook_id, &viewwebhook, r, webhookrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, apiwebhook)
+}
+
+// delete /api/v1.0/unit/webhooks/:whid/
+func DeleteWebhook(r render.Render, params martini.Params, userrepository services.UserRepository,
+	webhookrepository services.WebhookRepository, session *models.DtoSession) {
+	webhook_id, err := helpers.CheckParameterInt(r, params[helpers.PARAM_NAME_WEBHOOK_ID], session.Language)
+	if err != nil {
+		return
+	}
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	err = helpers.DeleteWebhook(user.UnitID, webhook_id, r, webhookrepository, session.Language)
+	if err != nil {
+		return
+	}
+
+	r.JSON(http.StatusOK, types.ResponseOK{Message: config.Localization[session.Language].Messages.OK})
+}
+
+// get /api/v1.0/unit/webhooks/:whid/deliveries/
+func GetWebhookDeliveries(w http.ResponseWriter, r render.Render, params martini.Params, userrepository services.UserRepository,
+	webhookrepository services.WebhookRepository, webhookdeliveryrepository services.WebhookDeliveryRepository,
+	session *models.DtoSession) {
+	webhook_id, err := helpers.CheckParameterInt(r, params[helpers.PARAM_NAME_WEBHOOK_ID], session.Language)
+	if err != nil {
+		return
+	}
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return
+	}
+
+	helpers.GetWebhookDeliveries(user.UnitID, webhook_id, w, r, webhookrepository, webhookdeliveryrepository, session.Language)
+}
@@ -102,6 +102,27 @@ const (
 	TABLE_TARIFF_PLANS               = "tariff_plans"
 	TABLE_PAYMENTS                   = "payments"
 	TABLE_HEADER_PRODUCTS            = "header_products"
+	TABLE_JOBS                       = "jobs"
+	TABLE_ORDER_CHECKPOINTS          = "order_checkpoints"
+	TABLE_SMS_SCHEDULES              = "sms_schedules"
+	TABLE_SMS_BATCHES                = "sms_batches"
+	TABLE_IMPORT_REJECTIONS          = "import_rejections"
+	TABLE_LEDGER_ACCOUNTS            = "ledger_accounts"
+	TABLE_ORDER_ROUTINGS             = "order_routings"
+	TABLE_ORDER_ROUTES               = "order_routes"
+	TABLE_SUPPRESSIONS               = "suppressions"
+	TABLE_DELIVERY_REPORTS           = "delivery_reports"
+	TABLE_WEBHOOKS                   = "webhooks"
+	TABLE_WEBHOOK_DELIVERIES         = "webhook_deliveries"
+	TABLE_HLR_CACHE                  = "hlr_cache"
+	TABLE_ADDRESS_OBJECTS            = "address_objects"
+	TABLE_ADDRESS_IMPORTS            = "address_imports"
+	TABLE_TABLE_DEDUPS               = "table_dedups"
+	TABLE_RATE_LIMITS                = "rate_limits"
+	TABLE_TWO_FACTORS                = "two_factors"
+	TABLE_TWO_FACTOR_CHALLENGES      = "two_factor_challenges"
+	TABLE_API_KEYS                   = "api_keys"
+	TABLE_UNIT_ROLES                 = "unit_roles"
 )
 
 var (
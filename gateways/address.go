@@ -0,0 +1,157 @@
+package gateways
+
+import (
+	"application/models"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	libTypes "lib/suppliers/types"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	ADDRESS_SUPPLIER_NAME    = "FIAS"
+	ADDRESS_RETENTION        = 24 * time.Hour
+	ADDRESS_STATUS_COMPLETED = "COMPLETED"
+	ADDRESS_STATUS_FAILED    = "FAILED"
+)
+
+// Стандартизация адреса по локальному справочнику
+type AddressStandardizer interface {
+	Standardize(address string) (apistandardaddress *models.ApiStandardAddress, err error)
+}
+
+// Запрос, исполненный шлюзом стандартизации адресов
+type AddressRequest struct {
+	Created time.Time                  // Время приема запроса
+	Address *models.ApiStandardAddress // Стандартизованный адрес
+	Error   string                     // Ошибка исполнения
+}
+
+// Шлюз стандартизации почтовых адресов по справочнику ФИАС без обращения к поставщику.
+// Поддерживает только проверку данных, адреса стандартизуются при приеме запросов
+type AddressGateway struct {
+	mutex        sync.Mutex
+	standardizer AddressStandardizer
+	requests     map[gocql.UUID]AddressRequest
+}
+
+func NewAddressGateway(standardizer AddressStandardizer) *AddressGateway {
+	return &AddressGateway{
+		standardizer: standardizer,
+		requests:     make(map[gocql.UUID]AddressRequest),
+	}
+}
+
+func (addressgateway *AddressGateway) Supplier(uuid string) (supplier *libTypes.Supplier, err error) {
+	supplier = new(libTypes.Supplier)
+	supplier.Name = ADDRESS_SUPPLIER_NAME
+
+	return supplier, nil
+}
+
+func (addressgateway *AddressGateway) SendSms(supplier *libTypes.Supplier, sms *[]libTypes.Sms) (response *libTypes.SmsResponse, err error) {
+	return nil, errors.New("Sms is not supported by address gateway")
+}
+
+func (addressgateway *AddressGateway) StatusSms(id gocql.UUID) (status libTypes.SmsStatus, err error) {
+	return status, errors.New("Sms is not supported by address gateway")
+}
+
+func (addressgateway *AddressGateway) SendHlr(supplier *libTypes.Supplier, hlr *[]libTypes.Hlr) (response *libTypes.HlrResponse, err error) {
+	return nil, errors.New("Hlr is not supported by address gateway")
+}
+
+func (addressgateway *AddressGateway) StatusHlr(id gocql.UUID) (status libTypes.HlrStatus, err error) {
+	return status, errors.New("Hlr is not supported by address gateway")
+}
+
+// Стандартизация адресов запросов. Запрос может содержать только почтовый адрес и игнорируемые данные
+func (addressgateway *AddressGateway) SendVerifyData(supplier *libTypes.Supplier,
+	verify *[]libTypes.VerifyData) (response *libTypes.VerifyDataResponse, err error) {
+	response = new(libTypes.VerifyDataResponse)
+	now := time.Now()
+	requests := []AddressRequest{}
+	for _, verifydata := range *verify {
+		request := AddressRequest{Created: now}
+		for _, body := range verifydata.Data {
+			switch body.Type {
+			case libTypes.CVerifyDataPostalAddress:
+				request.Address, err = addressgateway.standardizer.Standardize(strings.Join(body.Data, ", "))
+				if err != nil {
+					request.Error = err.Error()
+				}
+			case libTypes.CVerifyDataIgnore:
+			default:
+				request.Error = fmt.Sprintf("Data type %v is not supported by address gateway", body.Type)
+			}
+			if request.Error != "" {
+				break
+			}
+		}
+		requests = append(requests, request)
+	}
+
+	addressgateway.mutex.Lock()
+	defer addressgateway.mutex.Unlock()
+	for id, request := range addressgateway.requests {
+		if now.Sub(request.Created) > ADDRESS_RETENTION {
+			delete(addressgateway.requests, id)
+		}
+	}
+	for _, request := range requests {
+		id, err := gocql.RandomUUID()
+		if err != nil {
+			log.Error("Can't generate request id %v", err)
+			return nil, err
+		}
+		addressgateway.requests[id] = request
+		response.Ids = append(response.Ids, id)
+		if request.Error != "" {
+			response.Errors = append(response.Errors, errors.New(request.Error))
+		} else {
+			response.Errors = append(response.Errors, nil)
+		}
+	}
+
+	return response, nil
+}
+
+// Статус запроса всегда окончательный, стандартизованный адрес переносится в поля ответа поставщика по названиям полей
+func (addressgateway *AddressGateway) StatusVerifyData(id gocql.UUID) (status libTypes.VerifyDataStatus, err error) {
+	addressgateway.mutex.Lock()
+	request, ok := addressgateway.requests[id]
+	addressgateway.mutex.Unlock()
+	if !ok {
+		log.Error("Can't find address request %v", id)
+		return status, errors.New("Request not found")
+	}
+
+	value := reflect.ValueOf(&status).Elem()
+	SetField(value, "Id", reflect.ValueOf(id))
+	SetField(value, "Final", reflect.ValueOf(true))
+	if request.Error != "" {
+		SetField(value, "Status", reflect.ValueOf(ADDRESS_STATUS_FAILED))
+		SetField(value, "Error", reflect.ValueOf(request.Error))
+		return status, nil
+	}
+	SetField(value, "Status", reflect.ValueOf(ADDRESS_STATUS_COMPLETED))
+	field := value.FieldByName("DataPostalAddress")
+	if request.Address != nil && field.IsValid() && field.Kind() == reflect.Ptr {
+		address := reflect.New(field.Type().Elem())
+		source := reflect.ValueOf(request.Address).Elem()
+		for i := 0; i < source.NumField(); i++ {
+			SetField(address.Elem(), source.Type().Field(i).Name, reflect.ValueOf(fmt.Sprintf("%v", source.Field(i).Interface())))
+		}
+		SetField(value, "DataPostalAddress", address)
+	}
+
+	return status, nil
+}
+
+func (addressgateway *AddressGateway) MobileOperator(mobilephones []uint64) (operators []string, err error) {
+	return nil, errors.New("Mobile operator is not supported by address gateway")
+}
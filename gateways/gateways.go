@@ -0,0 +1,95 @@
+/* Gateways package provides interfaces and implementations for interaction with service suppliers */
+
+package gateways
+
+import (
+	"application/config"
+	"github.com/gocql/gocql"
+	logging "github.com/op/go-logging"
+	libTypes "lib/suppliers/types"
+	"sync"
+)
+
+const (
+	GATEWAY_REMOTE    = "remote"
+	GATEWAY_SIMULATOR = "simulator"
+	GATEWAY_ADDRESS   = "address"
+)
+
+// Шлюз взаимодействия с поставщиком услуг. Оператор мобильной связи определяется в виде UUID объединения оператора
+type Gateway interface {
+	Supplier(uuid string) (supplier *libTypes.Supplier, err error)
+	SendSms(supplier *libTypes.Supplier, sms *[]libTypes.Sms) (response *libTypes.SmsResponse, err error)
+	StatusSms(id gocql.UUID) (status libTypes.SmsStatus, err error)
+	SendHlr(supplier *libTypes.Supplier, hlr *[]libTypes.Hlr) (response *libTypes.HlrResponse, err error)
+	StatusHlr(id gocql.UUID) (status libTypes.HlrStatus, err error)
+	SendVerifyData(supplier *libTypes.Supplier, verify *[]libTypes.VerifyData) (response *libTypes.VerifyDataResponse, err error)
+	StatusVerifyData(id gocql.UUID) (status libTypes.VerifyDataStatus, err error)
+	MobileOperator(mobilephones []uint64) (operators []string, err error)
+}
+
+var (
+	log config.Logger = logging.MustGetLogger("gateways")
+
+	mutex    sync.Mutex
+	registry = make(map[string]Gateway)
+)
+
+func InitLogger(logger config.Logger) {
+	log = logger
+}
+
+// Название шлюза, выбранного для поставщика в конфигурации
+func Kind(uuid string) string {
+	if kind, ok := config.Configuration.Gateways.Suppliers[uuid]; ok && kind != "" {
+		return kind
+	}
+	if config.Configuration.Gateways.Default != "" {
+		return config.Configuration.Gateways.Default
+	}
+	return GATEWAY_REMOTE
+}
+
+// Необходимость подключения к серверу взаимодействия с поставщиками
+func RemoteRequired() bool {
+	if Kind("") == GATEWAY_REMOTE {
+		return true
+	}
+	for uuid := range config.Configuration.Gateways.Suppliers {
+		if Kind(uuid) == GATEWAY_REMOTE {
+			return true
+		}
+	}
+	return false
+}
+
+// Регистрация шлюза, создаваемого при запуске сервера
+func Register(kind string, gateway Gateway) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	registry[kind] = gateway
+}
+
+// Получение шлюза для поставщика по UUID объединения поставщика
+func Get(uuid string) (gateway Gateway) {
+	kind := Kind(uuid)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	gateway, ok := registry[kind]
+	if ok {
+		return gateway
+	}
+	switch kind {
+	case GATEWAY_SIMULATOR:
+		gateway = NewSimulatorGateway()
+	default:
+		if kind != GATEWAY_REMOTE {
+			log.Error("Unknown gateway %v for supplier %v, remote gateway is used", kind, uuid)
+		}
+		gateway = NewRemoteGateway()
+	}
+	registry[kind] = gateway
+
+	return gateway
+}
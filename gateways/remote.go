@@ -0,0 +1,89 @@
+package gateways
+
+import (
+	"application/communication/suppliers"
+	"github.com/gocql/gocql"
+	libSuppliers "lib/suppliers"
+	libTypes "lib/suppliers/types"
+	"lib/uuid"
+)
+
+// Шлюз к серверу взаимодействия с конечными поставщиками услуг
+type RemoteGateway struct {
+}
+
+func NewRemoteGateway() *RemoteGateway {
+	return &RemoteGateway{}
+}
+
+// Получение поставщика из библиотеки по UUID в виде строки
+func (remotegateway *RemoteGateway) Supplier(uuidstr string) (supplier *libTypes.Supplier, err error) {
+	uuidObj, err := uuid.ParseUUID(uuidstr)
+	if err != nil {
+		log.Error("Can't parse UUID %v, %v", uuidstr, err)
+		return nil, err
+	}
+	supp := libSuppliers.New()
+	found, err := supp.SuppliersByUUID(uuidObj)
+	if err != nil {
+		log.Error("Can't find supplier by UUID %v, %v", uuidstr, err)
+		return nil, err
+	}
+
+	return &found, nil
+}
+
+func (remotegateway *RemoteGateway) SendSms(supplier *libTypes.Supplier, sms *[]libTypes.Sms) (response *libTypes.SmsResponse, err error) {
+	suppliers.WaitClientReady()                       // Функция блокируется до момента пока связь с сервером не будет установлена
+	result, err := suppliers.SendSms(*supplier, *sms) // Функция не блокируется. Если связи с сервером нет, то вернётся ошибка
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (remotegateway *RemoteGateway) StatusSms(id gocql.UUID) (status libTypes.SmsStatus, err error) {
+	return suppliers.StatusSms(id)
+}
+
+func (remotegateway *RemoteGateway) SendHlr(supplier *libTypes.Supplier, hlr *[]libTypes.Hlr) (response *libTypes.HlrResponse, err error) {
+	suppliers.WaitClientReady()
+	result, err := suppliers.SendHlr(*supplier, *hlr)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (remotegateway *RemoteGateway) StatusHlr(id gocql.UUID) (status libTypes.HlrStatus, err error) {
+	return suppliers.StatusHlr(id)
+}
+
+func (remotegateway *RemoteGateway) SendVerifyData(supplier *libTypes.Supplier,
+	verify *[]libTypes.VerifyData) (response *libTypes.VerifyDataResponse, err error) {
+	suppliers.WaitClientReady()
+	result, err := suppliers.SendVerifyData(*supplier, *verify)
+	if err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (remotegateway *RemoteGateway) StatusVerifyData(id gocql.UUID) (status libTypes.VerifyDataStatus, err error) {
+	return suppliers.StatusVerifyData(id)
+}
+
+func (remotegateway *RemoteGateway) MobileOperator(mobilephones []uint64) (operators []string, err error) {
+	results, err := suppliers.MobileOperator(mobilephones)
+	if err != nil {
+		return nil, err
+	}
+	for index := range results {
+		operators = append(operators, results[index].Id.String())
+	}
+
+	return operators, nil
+}
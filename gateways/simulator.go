@@ -0,0 +1,230 @@
+package gateways
+
+import (
+	"application/config"
+	"errors"
+	"github.com/gocql/gocql"
+	libTypes "lib/suppliers/types"
+	"math/rand"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+const (
+	SIMULATOR_SUPPLIER_NAME = "Simulator"
+	SIMULATOR_FAILURE       = "Simulated failure"
+	SIMULATOR_RETENTION     = 24 * time.Hour
+	SIMULATOR_OPERATOR_UUID = "00000000-0000-0000-0000-000000000000" // Незарегистрированный оператор, заменяется оператором по умолчанию
+)
+
+// Запрос, принятый симулятором
+type SimulatedRequest struct {
+	Created time.Time // Время приема запроса
+	Status  string    // Окончательный статус
+	Error   string    // Ошибка исполнения
+}
+
+// Симулятор поставщика услуг для прогона заказов без сервера взаимодействия с поставщиками.
+// Задержка ответа, доля отказов и окончательные статусы задаются в конфигурации
+type SimulatorGateway struct {
+	mutex    sync.Mutex
+	requests map[gocql.UUID]SimulatedRequest
+}
+
+func NewSimulatorGateway() *SimulatorGateway {
+	return &SimulatorGateway{
+		requests: make(map[gocql.UUID]SimulatedRequest),
+	}
+}
+
+func (simulatorgateway *SimulatorGateway) Supplier(uuid string) (supplier *libTypes.Supplier, err error) {
+	supplier = new(libTypes.Supplier)
+	supplier.Name = SIMULATOR_SUPPLIER_NAME
+
+	return supplier, nil
+}
+
+// Прием запросов: каждому запросу назначается идентификатор и окончательный статус, часть запросов отклоняется
+func (simulatorgateway *SimulatorGateway) Accept(count int, statuses map[string]int) (ids []gocql.UUID, errs []error, err error) {
+	time.Sleep(config.Configuration.Gateways.Simulator.Latency)
+
+	simulatorgateway.mutex.Lock()
+	defer simulatorgateway.mutex.Unlock()
+	now := time.Now()
+	for id, request := range simulatorgateway.requests {
+		if now.Sub(request.Created) > SIMULATOR_RETENTION {
+			delete(simulatorgateway.requests, id)
+		}
+	}
+	for i := 0; i < count; i++ {
+		id, err := gocql.RandomUUID()
+		if err != nil {
+			log.Error("Can't generate request id %v", err)
+			return nil, nil, err
+		}
+		request := SimulatedRequest{Created: now, Status: PickStatus(statuses)}
+		if rand.Float64() < config.Configuration.Gateways.Simulator.FailureRate {
+			request.Error = SIMULATOR_FAILURE
+			errs = append(errs, errors.New(SIMULATOR_FAILURE))
+		} else {
+			errs = append(errs, nil)
+		}
+		simulatorgateway.requests[id] = request
+		ids = append(ids, id)
+	}
+
+	return ids, errs, nil
+}
+
+// Состояние запроса: статус становится окончательным по истечении времени доставки
+func (simulatorgateway *SimulatorGateway) Status(id gocql.UUID, status interface{}) (err error) {
+	time.Sleep(config.Configuration.Gateways.Simulator.Latency)
+
+	simulatorgateway.mutex.Lock()
+	request, ok := simulatorgateway.requests[id]
+	simulatorgateway.mutex.Unlock()
+	if !ok {
+		log.Error("Can't find simulated request %v", id)
+		return errors.New("Request not found")
+	}
+
+	value := reflect.ValueOf(status).Elem()
+	SetField(value, "Id", reflect.ValueOf(id))
+	final := request.Error != "" || time.Since(request.Created) >= config.Configuration.Gateways.Simulator.DeliveryDelay
+	SetField(value, "Final", reflect.ValueOf(final))
+	if final {
+		SetField(value, "Status", reflect.ValueOf(request.Status))
+		SetField(value, "Error", reflect.ValueOf(request.Error))
+	}
+
+	return nil
+}
+
+func (simulatorgateway *SimulatorGateway) SendSms(supplier *libTypes.Supplier, sms *[]libTypes.Sms) (response *libTypes.SmsResponse, err error) {
+	response = new(libTypes.SmsResponse)
+	response.Ids, response.Errors, err = simulatorgateway.Accept(len(*sms), config.Configuration.Gateways.Simulator.SmsStatuses)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (simulatorgateway *SimulatorGateway) StatusSms(id gocql.UUID) (status libTypes.SmsStatus, err error) {
+	err = simulatorgateway.Status(id, &status)
+	return status, err
+}
+
+func (simulatorgateway *SimulatorGateway) SendHlr(supplier *libTypes.Supplier, hlr *[]libTypes.Hlr) (response *libTypes.HlrResponse, err error) {
+	response = new(libTypes.HlrResponse)
+	response.Ids, response.Errors, err = simulatorgateway.Accept(len(*hlr), config.Configuration.Gateways.Simulator.HlrStatuses)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (simulatorgateway *SimulatorGateway) StatusHlr(id gocql.UUID) (status libTypes.HlrStatus, err error) {
+	err = simulatorgateway.Status(id, &status)
+	return status, err
+}
+
+func (simulatorgateway *SimulatorGateway) SendVerifyData(supplier *libTypes.Supplier,
+	verify *[]libTypes.VerifyData) (response *libTypes.VerifyDataResponse, err error) {
+	response = new(libTypes.VerifyDataResponse)
+	response.Ids, response.Errors, err = simulatorgateway.Accept(len(*verify), config.Configuration.Gateways.Simulator.VerifyStatuses)
+	if err != nil {
+		return nil, err
+	}
+
+	return response, nil
+}
+
+func (simulatorgateway *SimulatorGateway) StatusVerifyData(id gocql.UUID) (status libTypes.VerifyDataStatus, err error) {
+	err = simulatorgateway.Status(id, &status)
+	return status, err
+}
+
+// Определение оператора: номер закрепляется за одним из операторов конфигурации по остатку от деления
+func (simulatorgateway *SimulatorGateway) MobileOperator(mobilephones []uint64) (operators []string, err error) {
+	time.Sleep(config.Configuration.Gateways.Simulator.Latency)
+
+	choices := config.Configuration.Gateways.Simulator.Operators
+	if len(choices) == 0 {
+		choices = []string{SIMULATOR_OPERATOR_UUID}
+	}
+	for _, mobilephone := range mobilephones {
+		operators = append(operators, choices[mobilephone%uint64(len(choices))])
+	}
+
+	return operators, nil
+}
+
+// Выбор окончательного статуса случайным образом с учетом весов
+func PickStatus(statuses map[string]int) string {
+	keys := []string{}
+	total := 0
+	for key, weight := range statuses {
+		if weight > 0 {
+			keys = append(keys, key)
+			total += weight
+		}
+	}
+	if total == 0 {
+		return ""
+	}
+	sort.Strings(keys)
+	choice := rand.Intn(total)
+	for _, key := range keys {
+		choice -= statuses[key]
+		if choice < 0 {
+			return key
+		}
+	}
+
+	return keys[len(keys)-1]
+}
+
+// Заполнение поля структуры поставщика значением с приведением к типу поля. Отсутствующие поля пропускаются
+func SetField(structure reflect.Value, name string, value reflect.Value) {
+	field := structure.FieldByName(name)
+	if !field.IsValid() || !field.CanSet() {
+		return
+	}
+	if value.Type().AssignableTo(field.Type()) {
+		field.Set(value)
+		return
+	}
+	if value.Kind() != reflect.String {
+		if value.Type().ConvertibleTo(field.Type()) {
+			field.Set(value.Convert(field.Type()))
+		}
+		return
+	}
+
+	text := value.String()
+	switch field.Kind() {
+	case reflect.String:
+		field.SetString(text)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if number, err := strconv.ParseInt(text, 10, 64); err == nil {
+			field.SetInt(number)
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if number, err := strconv.ParseUint(text, 10, 64); err == nil {
+			field.SetUint(number)
+		}
+	case reflect.Bool:
+		if flag, err := strconv.ParseBool(text); err == nil {
+			field.SetBool(flag)
+		}
+	case reflect.Interface:
+		if text != "" && reflect.TypeOf(errors.New(text)).Implements(field.Type()) {
+			field.Set(reflect.ValueOf(errors.New(text)))
+		}
+	}
+}
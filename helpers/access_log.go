@@ -17,18 +17,39 @@ const (
 	REQUEST_HEADER_X_FORWARDED_FOR = "X-Forwarded-For"
 )
 
+// Адрес клиента запроса. Заголовок X-Forwarded-For учитывается, только если запрос пришел от доверенного прокси,
+// адреса в заголовке просматриваются справа до первого недоверенного
+func GetClientIP(request *http.Request) (host string, err error) {
+	host, _, err = net.SplitHostPort(request.RemoteAddr)
+	if err != nil {
+		return "", err
+	}
+	forwarded := strings.Split(request.Header.Get(REQUEST_HEADER_X_FORWARDED_FOR), ",")
+	for i := len(forwarded) - 1; i >= 0 && IsTrustedProxy(host); i-- {
+		address := strings.TrimSpace(forwarded[i])
+		if net.ParseIP(address) == nil {
+			break
+		}
+		host = address
+	}
+
+	return host, nil
+}
+
+// Адрес входит в список доверенных прокси, заданных адресами или подсетями
+func IsTrustedProxy(host string) bool {
+	return IsAddressAllowed(host, config.Configuration.RateLimit.TrustedProxies)
+}
+
 func CreateAccessLog(url string, request *http.Request, r render.Render, accesslogrepository services.AccessLogRepository,
 	language string) (dtoaccesslog *models.DtoAccessLog, err error) {
 	dtoaccesslog = new(models.DtoAccessLog)
-	host := request.Header.Get(REQUEST_HEADER_X_FORWARDED_FOR)
-	if host == "" {
-		host, _, err = net.SplitHostPort(request.RemoteAddr)
-		if err != nil {
-			log.Error("Can't detect ip address %v from %v", err, request.RemoteAddr)
-			r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
-				Message: config.Localization[language].Errors.Api.Object_NotExist})
-			return nil, err
-		}
+	host, err := GetClientIP(request)
+	if err != nil {
+		log.Error("Can't detect ip address %v from %v", err, request.RemoteAddr)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, err
 	}
 	dtoaccesslog.IP_Address = host
 	dnses, err := net.LookupAddr(dtoaccesslog.IP_Address)
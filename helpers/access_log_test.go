@@ -1 +1,50 @@
 package helpers
+
+import (
+	"application/config"
+	"net/http"
+	"testing"
+)
+
+func TestGetClientIP(t *testing.T) {
+	var trustedproxies = config.Configuration.RateLimit.TrustedProxies
+	defer func() {
+		config.Configuration.RateLimit.TrustedProxies = trustedproxies
+	}()
+	config.Configuration.RateLimit.TrustedProxies = []string{"10.0.0.0/8", "192.168.1.1"}
+
+	var cases = []struct {
+		remoteaddr string
+		forwarded  string
+		host       string
+	}{
+		{"203.0.113.5:1234", "", "203.0.113.5"},
+		{"203.0.113.5:1234", "1.2.3.4", "203.0.113.5"},
+		{"10.0.0.1:1234", "", "10.0.0.1"},
+		{"10.0.0.1:1234", "1.2.3.4", "1.2.3.4"},
+		{"192.168.1.1:1234", "1.2.3.4", "1.2.3.4"},
+		{"192.168.1.2:1234", "1.2.3.4", "192.168.1.2"},
+		{"10.0.0.1:1234", "1.2.3.4, 10.1.1.1", "1.2.3.4"},
+		{"10.0.0.1:1234", "6.6.6.6, 1.2.3.4", "1.2.3.4"},
+		{"10.0.0.1:1234", "unknown, 10.0.0.2", "10.0.0.2"},
+		{"[::1]:1234", "1.2.3.4", "::1"},
+	}
+
+	for _, c := range cases {
+		request, _ := http.NewRequest("GET", "/", nil)
+		request.RemoteAddr = c.remoteaddr
+		if c.forwarded != "" {
+			request.Header.Set(REQUEST_HEADER_X_FORWARDED_FOR, c.forwarded)
+		}
+		host, err := GetClientIP(request)
+		if err != nil || host != c.host {
+			t.Error("Client address", c.remoteaddr, c.forwarded, "is not properly detected", host, err)
+		}
+	}
+
+	request, _ := http.NewRequest("GET", "/", nil)
+	request.RemoteAddr = "unknown"
+	if _, err := GetClientIP(request); err == nil {
+		t.Error("Wrong remote address is not properly rejected")
+	}
+}
@@ -0,0 +1,36 @@
+package helpers
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"types"
+)
+
+const (
+	PARAM_QUERY_ADDRESS = "address"
+)
+
+func GetApiAddressImport(dtoaddressimport *models.DtoAddressImport) *models.ApiAddressImport {
+	return models.NewApiAddressImport(dtoaddressimport.ID, dtoaddressimport.File, dtoaddressimport.Status.String(),
+		dtoaddressimport.Objects, dtoaddressimport.Houses, dtoaddressimport.Error, dtoaddressimport.Created, dtoaddressimport.Finished)
+}
+
+// Журнал загрузок справочника адресов, последние загрузки первыми
+func GetAddressImports(w http.ResponseWriter, r render.Render, addressimportrepository services.AddressImportRepository,
+	language string) {
+	dtoaddressimports, err := addressimportrepository.GetAll()
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return
+	}
+	apiaddressimports := []models.ApiAddressImport{}
+	for i := range *dtoaddressimports {
+		apiaddressimports = append(apiaddressimports, *GetApiAddressImport(&(*dtoaddressimports)[i]))
+	}
+
+	RenderJSONArray(apiaddressimports, len(apiaddressimports), w, r)
+}
@@ -0,0 +1,223 @@
+package helpers
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"crypto/rand"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+	"types"
+)
+
+const (
+	API_KEY_HEADER_NAME   = "X-Api-Key"
+	API_KEY_LENGTH        = 32
+	API_KEY_PREFIX_LENGTH = 8
+	PARAM_NAME_API_KEY_ID = "keyId"
+)
+
+// Генерация ключа доступа. В базе хранятся только начало ключа и его хэш
+func GenerateApiKey() (key string, prefix string, hash string, err error) {
+	raw := make([]byte, API_KEY_LENGTH)
+	if _, err = rand.Read(raw); err != nil {
+		log.Error("Error during api key generation %v", err)
+		return "", "", "", err
+	}
+	key = base64.RawURLEncoding.EncodeToString(raw)
+
+	return key, key[:API_KEY_PREFIX_LENGTH], HashApiKey(key), nil
+}
+
+func HashApiKey(key string) string {
+	hash := sha512.Sum512([]byte(key))
+	return hex.EncodeToString(hash[:])
+}
+
+// Адрес входит в список, заданный адресами или подсетями
+func IsAddressAllowed(host string, addresses []string) bool {
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return false
+	}
+	for _, address := range addresses {
+		if _, network, err := net.ParseCIDR(address); err == nil {
+			if network.Contains(ip) {
+				return true
+			}
+		} else if allowed := net.ParseIP(address); allowed != nil && allowed.Equal(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func GetApiKeyAddresses(apikey *models.DtoApiKey) []string {
+	if apikey.Allowed_IPs == "" {
+		return []string{}
+	}
+	return strings.Split(apikey.Allowed_IPs, ",")
+}
+
+func CheckApiKey(r render.Render, params martini.Params, userrepository services.UserRepository,
+	apikeyrepository services.ApiKeyRepository, session *models.DtoSession) (dtoapikey *models.DtoApiKey, err error) {
+	apikey_id, err := CheckParameterInt(r, params[PARAM_NAME_API_KEY_ID], session.Language)
+	if err != nil {
+		return nil, err
+	}
+
+	dtoapikey, err = apikeyrepository.Get(apikey_id)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+
+	dtouser, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+
+	if dtoapikey.Unit_ID != dtouser.UnitID {
+		log.Error("Api key unit %v and user unit %v don't match", dtoapikey.Unit_ID, dtouser.UnitID)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return nil, errors.New("Wrong unit")
+	}
+
+	return dtoapikey, nil
+}
+
+// Ключу выдаются только уровни доступа заказчика и исполнителя, которыми обладает его создатель
+func CheckApiKeyRoles(roles []models.UserRole, owner []models.UserRole, language string, r render.Render) (err error) {
+	if len(roles) == 0 {
+		log.Error("Api key roles are empty")
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return errors.New("Empty roles")
+	}
+	for _, role := range roles {
+		allowed := false
+		if role == models.USER_ROLE_CUSTOMER || role == models.USER_ROLE_SUPPLIER {
+			for _, value := range owner {
+				if value == role {
+					allowed = true
+					break
+				}
+			}
+		}
+		if !allowed {
+			log.Error("Role is not allowed for api key %v", role)
+			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
+				Message: config.Localization[language].Errors.Api.Method_NotAllowed})
+			return errors.New("Role not allowed")
+		}
+	}
+
+	return nil
+}
+
+func CheckApiKeyAddresses(addresses []string, language string, r render.Render) (allowedips string, err error) {
+	var values []string
+	for _, address := range addresses {
+		address = strings.TrimSpace(address)
+		if _, _, err = net.ParseCIDR(address); err != nil && net.ParseIP(address) == nil {
+			log.Error("Api key address is wrong %v", address)
+			r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+				Message: config.Localization[language].Errors.Api.Data_Wrong})
+			return "", errors.New("Wrong address")
+		}
+		values = append(values, address)
+	}
+
+	return strings.Join(values, ","), nil
+}
+
+func CheckApiKeyExpires(expires time.Time, language string, r render.Render) (err error) {
+	if !expires.After(time.Now()) {
+		log.Error("Api key expiration is in the past %v", expires)
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return errors.New("Wrong expiration")
+	}
+
+	return nil
+}
+
+// Сессия запроса по ключу доступа. Уровни доступа ключа ограничиваются текущими уровнями доступа пользователя,
+// каждый запрос записывается в журнал доступа с привязкой к ключу
+func AuthenticateApiKey(key string, request *http.Request, apikeyrepository services.ApiKeyRepository,
+	userrepository services.UserRepository, accesslogrepository services.AccessLogRepository) (session *models.DtoSession, err error) {
+	dtoapikey, err := apikeyrepository.FindByHash(HashApiKey(key))
+	if err != nil {
+		return nil, err
+	}
+	if !dtoapikey.Active || !dtoapikey.Expires.After(time.Now()) {
+		log.Error("Api key is revoked or expired %v", dtoapikey.ID)
+		return nil, errors.New("Api key not active")
+	}
+	host, err := GetClientIP(request)
+	if err != nil {
+		log.Error("Can't detect ip address %v from %v", err, request.RemoteAddr)
+		return nil, err
+	}
+	addresses := GetApiKeyAddresses(dtoapikey)
+	if len(addresses) != 0 && !IsAddressAllowed(host, addresses) {
+		log.Error("Api key %v is not allowed from %v", dtoapikey.ID, host)
+		return nil, errors.New("Address not allowed")
+	}
+
+	dtouser, err := userrepository.Get(dtoapikey.User_ID)
+	if err != nil {
+		return nil, err
+	}
+	if !dtouser.Active || !dtouser.Confirmed || dtouser.UnitID != dtoapikey.Unit_ID {
+		log.Error("Api key user is not active or moved %v", dtouser.ID)
+		return nil, errors.New("User not active")
+	}
+	var roles []models.UserRole
+	for _, role := range dtoapikey.Roles {
+		for _, value := range dtouser.Roles {
+			if value == role {
+				roles = append(roles, role)
+				break
+			}
+		}
+	}
+
+	now := time.Now()
+	dtoaccesslog := models.NewAccessLog(0, host, "", request.UserAgent(), limitAccessLogURL(request.Referer()),
+		limitAccessLogURL(request.RequestURI), now)
+	err = accesslogrepository.Create(dtoaccesslog)
+	if err != nil {
+		return nil, err
+	}
+	err = apikeyrepository.LogAccess(dtoapikey.ID, dtoaccesslog.ID, now)
+	if err != nil {
+		return nil, err
+	}
+
+	session = models.NewDtoSession("", dtouser.ID, roles, now, dtouser.Language)
+	session.ApiKeyID = dtoapikey.ID
+
+	return session, nil
+}
+
+func limitAccessLogURL(url string) string {
+	runes := []rune(url)
+	if len(runes) > URL_LENGTH_MAX {
+		runes = runes[:URL_LENGTH_MAX]
+	}
+	return string(runes)
+}
@@ -0,0 +1,75 @@
+package helpers
+
+import (
+	"application/models"
+	"net/http"
+	"testing"
+	"types"
+)
+
+func TestCheckApiKeyRoles(t *testing.T) {
+	var cases = []struct {
+		roles []models.UserRole
+		owner []models.UserRole
+		valid bool
+		code  int
+	}{
+		{[]models.UserRole{models.USER_ROLE_CUSTOMER}, []models.UserRole{models.USER_ROLE_CUSTOMER}, true, 0},
+		{[]models.UserRole{models.USER_ROLE_CUSTOMER, models.USER_ROLE_SUPPLIER},
+			[]models.UserRole{models.USER_ROLE_SUPPLIER, models.USER_ROLE_CUSTOMER}, true, 0},
+		{[]models.UserRole{}, []models.UserRole{models.USER_ROLE_CUSTOMER}, false, http.StatusBadRequest},
+		{[]models.UserRole{models.USER_ROLE_SUPPLIER}, []models.UserRole{models.USER_ROLE_CUSTOMER}, false, http.StatusForbidden},
+		{[]models.UserRole{models.USER_ROLE_CUSTOMER, models.USER_ROLE_SUPPLIER},
+			[]models.UserRole{models.USER_ROLE_CUSTOMER}, false, http.StatusForbidden},
+		{[]models.UserRole{models.USER_ROLE_ADMINISTRATOR},
+			[]models.UserRole{models.USER_ROLE_ADMINISTRATOR}, false, http.StatusForbidden},
+		{[]models.UserRole{models.USER_ROLE_DEVELOPER}, []models.UserRole{models.USER_ROLE_DEVELOPER}, false, http.StatusForbidden},
+	}
+	var testlogger = new(TestLogger)
+	InitLogger(testlogger)
+
+	for _, c := range cases {
+		var r = new(Renderer)
+		err := CheckApiKeyRoles(c.roles, c.owner, "eng", r)
+		if c.valid && err != nil {
+			t.Error("Api key roles", c.roles, "of owner", c.owner, "should be allowed", err)
+		}
+		if !c.valid && (err == nil || r.StatusValue != c.code) {
+			t.Error("Api key roles", c.roles, "of owner", c.owner, "should be rejected", r.StatusValue)
+		}
+		if c.code == http.StatusForbidden && r.ErrorValue.Code != types.TYPE_ERROR_METHOD_NOTALLOWED {
+			t.Error("Check api key roles wrong error code", r.ErrorValue.Code)
+		}
+	}
+}
+
+func TestIsAddressAllowed(t *testing.T) {
+	var addresses = []string{"192.168.1.10", "10.0.0.0/8", "2001:db8::/32"}
+	var cases = []struct {
+		host    string
+		allowed bool
+	}{
+		{"192.168.1.10", true},
+		{"192.168.1.11", false},
+		{"10.20.30.40", true},
+		{"11.0.0.1", false},
+		{"2001:db8::1", true},
+		{"2001:db9::1", false},
+		{"::ffff:192.168.1.10", true},
+		{"", false},
+		{"localhost", false},
+		{"192.168.1.10:80", false},
+	}
+
+	for _, c := range cases {
+		if IsAddressAllowed(c.host, addresses) != c.allowed {
+			t.Error("Address", c.host, "is not properly checked")
+		}
+	}
+	if IsAddressAllowed("192.168.1.10", []string{}) {
+		t.Error("Empty list should not allow any address")
+	}
+	if IsAddressAllowed("192.168.1.10", []string{"wrong", "192.168.1.0/33"}) {
+		t.Error("Wrong list entries should not allow any address")
+	}
+}
@@ -498,3 +498,92 @@ func PrepareCompanyTemplate(company_id int64, apicompany *models.ApiMiddleCompan
 
 	return dtocompanytemplate, nil
 }
+
+// Подготовка реквизитов системы как поставщика услуг для формирования документов
+func PrepareSellerTemplate(r render.Render, unitrepository services.UnitRepository, companyrepository services.CompanyRepository,
+	companycoderepository services.CompanyCodeRepository, companyaddressrepository services.CompanyAddressRepository,
+	companybankrepository services.CompanyBankRepository, companyemployeerepository services.CompanyEmployeeRepository,
+	language string) (dtobank *models.ViewApiCompanyBank, dtocompanytemplate *models.DtoCompanyTemplate, err error) {
+	dtounit, err := unitrepository.Get(config.Configuration.SystemAccount)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, nil, err
+	}
+	dtoseller, err := companyrepository.GetPrimaryByUnit(dtounit.ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, nil, err
+	}
+	apiseller, err := LoadCompany(dtoseller, r, companycoderepository, companyaddressrepository, companybankrepository,
+		companyemployeerepository, language)
+	if err != nil {
+		return nil, nil, err
+	}
+	found := false
+	var bankindex int
+	for bankindex = range apiseller.CompanyBanks {
+		if apiseller.CompanyBanks[bankindex].Primary && !apiseller.CompanyBanks[bankindex].Deleted {
+			found = true
+			break
+		}
+	}
+	if !found {
+		log.Error("Primary bank is not found for company %v", dtoseller.ID)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, nil, errors.New("Not found primary bank")
+	}
+	dtocompanytemplate, err = PrepareCompanyTemplate(dtoseller.ID, apiseller, r, language)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return &apiseller.CompanyBanks[bankindex], dtocompanytemplate, nil
+}
+
+// Подготовка реквизитов компании покупателя для формирования документов
+func PrepareBuyerTemplate(company_id int64, r render.Render, companyrepository services.CompanyRepository,
+	companycoderepository services.CompanyCodeRepository, companyaddressrepository services.CompanyAddressRepository,
+	companybankrepository services.CompanyBankRepository, companyemployeerepository services.CompanyEmployeeRepository,
+	language string) (dtobuyer *models.DtoCompany, dtocompanytemplate *models.DtoCompanyTemplate, err error) {
+	dtobuyer, err = companyrepository.Get(company_id)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, nil, err
+	}
+	apibuyer, err := LoadCompany(dtobuyer, r, companycoderepository, companyaddressrepository, companybankrepository,
+		companyemployeerepository, language)
+	if err != nil {
+		return nil, nil, err
+	}
+	dtocompanytemplate, err = PrepareCompanyTemplate(dtobuyer.ID, apibuyer, r, language)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return dtobuyer, dtocompanytemplate, nil
+}
+
+// Получение действующего договора компании
+func CheckContract(dtocompany *models.DtoCompany, r render.Render, contractrepository services.ContractRepository,
+	language string) (dtocontract *models.DtoContract, err error) {
+	contracts, err := contractrepository.GetByUnit(dtocompany.Unit_ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+	for i := range *contracts {
+		if (*contracts)[i].Company_ID == dtocompany.ID && (*contracts)[i].Active {
+			return &(*contracts)[i], nil
+		}
+	}
+
+	log.Error("Contract is not found for company %v", dtocompany.ID)
+	r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+		Message: config.Localization[language].Errors.Api.Object_NotExist})
+	return nil, errors.New("Not found contract")
+}
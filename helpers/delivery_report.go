@@ -0,0 +1,44 @@
+package helpers
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"github.com/gocql/gocql"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"time"
+	"types"
+)
+
+// Прием отчетов о доставке от поставщика. Отчет принимается только по запросу, отправленному этому поставщику
+func CreateDeliveryReports(supplier_id int64, viewdeliveryreports *models.ViewDeliveryReports, r render.Render,
+	deliveryreportrepository services.DeliveryReportRepository, language string) (apideliveryreports *models.ApiDeliveryReports, err error) {
+	accepted := 0
+	rejected := []string{}
+	for _, viewdeliveryreport := range viewdeliveryreports.Reports {
+		id, err := gocql.ParseUUID(viewdeliveryreport.Request_ID)
+		if err != nil {
+			log.Error("Can't parse delivery report request id %v, %v", err, viewdeliveryreport.Request_ID)
+			rejected = append(rejected, viewdeliveryreport.Request_ID)
+			continue
+		}
+		dtodeliveryreport := models.NewDtoDeliveryReport(id.String(), 0, supplier_id, models.ParseDeliveryReportType(viewdeliveryreport.Type),
+			viewdeliveryreport.Status, viewdeliveryreport.Error, viewdeliveryreport.Orn, viewdeliveryreport.Roaming,
+			viewdeliveryreport.Final, time.Now(), time.Now())
+		found, err := deliveryreportrepository.Update(dtodeliveryreport)
+		if err != nil {
+			r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+				Message: config.Localization[language].Errors.Api.Object_NotExist})
+			return nil, err
+		}
+		if !found {
+			log.Error("Delivery report request %v is not expected from supplier %v", viewdeliveryreport.Request_ID, supplier_id)
+			rejected = append(rejected, viewdeliveryreport.Request_ID)
+			continue
+		}
+		accepted++
+	}
+
+	return models.NewApiDeliveryReports(accepted, rejected), nil
+}
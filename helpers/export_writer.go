@@ -0,0 +1,312 @@
+package helpers
+
+import (
+	"application/models"
+	"archive/zip"
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const (
+	EXPORT_XLSX_SHEET_NAME = "Data"
+	EXPORT_XLSX_STYLE_DATE = 1
+)
+
+var (
+	// Число без ведущих нулей, чтобы не терять коды и номера телефонов
+	exportNumber = regexp.MustCompile(`^-?(0|[1-9][0-9]{0,9})(\.[0-9]+)?$`)
+)
+
+// Построчная запись выгружаемой таблицы
+type RowWriter interface {
+	Write(record []string) (err error)
+	Close() (err error)
+}
+
+// Поддерживаемые форматы выгрузки
+func IsExportDataFormat(dataformat models.DataFormat) bool {
+	switch dataformat {
+	case models.DATA_FORMAT_TXT, models.DATA_FORMAT_CSV, models.DATA_FORMAT_SSV,
+		models.DATA_FORMAT_XLSX, models.DATA_FORMAT_JSON, models.DATA_FORMAT_NDJSON:
+		return true
+	}
+	return false
+}
+
+func GetExportExtension(dataformat models.DataFormat) string {
+	switch dataformat {
+	case models.DATA_FORMAT_TXT:
+		return ".txt"
+	case models.DATA_FORMAT_XLSX:
+		return ".xlsx"
+	case models.DATA_FORMAT_JSON:
+		return ".json"
+	case models.DATA_FORMAT_NDJSON:
+		return ".ndjson"
+	}
+	return ".csv"
+}
+
+func NewRowWriter(file *os.File, dataformat models.DataFormat, tablecolumns *[]models.DtoTableColumn) (writer RowWriter, err error) {
+	switch dataformat {
+	case models.DATA_FORMAT_TXT, models.DATA_FORMAT_CSV, models.DATA_FORMAT_SSV:
+		return NewCSVWriter(file, models.GetDataSeparator(dataformat), tablecolumns)
+	case models.DATA_FORMAT_XLSX:
+		return NewXLSXWriter(file, tablecolumns)
+	case models.DATA_FORMAT_JSON:
+		return NewJSONWriter(file, tablecolumns, false)
+	case models.DATA_FORMAT_NDJSON:
+		return NewJSONWriter(file, tablecolumns, true)
+	}
+
+	return nil, errors.New("Unsupported data format")
+}
+
+// Запись файла с разделителями со строкой заголовка
+type CSVWriter struct {
+	writer *csv.Writer
+}
+
+func NewCSVWriter(file *os.File, separator rune, tablecolumns *[]models.DtoTableColumn) (csvwriter *CSVWriter, err error) {
+	csvwriter = &CSVWriter{writer: csv.NewWriter(file)}
+	csvwriter.writer.Comma = separator
+	header := []string{}
+	for _, tablecolumn := range *tablecolumns {
+		header = append(header, tablecolumn.Name)
+	}
+	return csvwriter, csvwriter.Write(header)
+}
+
+func (csvwriter *CSVWriter) Write(record []string) (err error) {
+	return csvwriter.writer.Write(record)
+}
+
+func (csvwriter *CSVWriter) Close() (err error) {
+	csvwriter.writer.Flush()
+	return csvwriter.writer.Error()
+}
+
+// Запись объектов с ключами по названиям колонок в массив json или построчно (ndjson)
+type JSONWriter struct {
+	writer *bufio.Writer
+	keys   [][]byte
+	lines  bool
+	count  int64
+}
+
+func NewJSONWriter(file *os.File, tablecolumns *[]models.DtoTableColumn, lines bool) (jsonwriter *JSONWriter, err error) {
+	jsonwriter = &JSONWriter{writer: bufio.NewWriter(file), lines: lines}
+	for _, tablecolumn := range *tablecolumns {
+		key, err := json.Marshal(tablecolumn.Name)
+		if err != nil {
+			return nil, err
+		}
+		jsonwriter.keys = append(jsonwriter.keys, key)
+	}
+	if !lines {
+		_, err = jsonwriter.writer.WriteString("[")
+	}
+	return jsonwriter, err
+}
+
+func (jsonwriter *JSONWriter) Write(record []string) (err error) {
+	if !jsonwriter.lines && jsonwriter.count != 0 {
+		jsonwriter.writer.WriteString(",")
+	}
+	jsonwriter.writer.WriteString("{")
+	for i, key := range jsonwriter.keys {
+		if i != 0 {
+			jsonwriter.writer.WriteString(",")
+		}
+		value := ""
+		if i < len(record) {
+			value = record[i]
+		}
+		buf, err := json.Marshal(value)
+		if err != nil {
+			return err
+		}
+		jsonwriter.writer.Write(key)
+		jsonwriter.writer.WriteString(":")
+		jsonwriter.writer.Write(buf)
+	}
+	jsonwriter.writer.WriteString("}")
+	if jsonwriter.lines {
+		jsonwriter.writer.WriteString("\n")
+	}
+	jsonwriter.count++
+
+	return nil
+}
+
+func (jsonwriter *JSONWriter) Close() (err error) {
+	if !jsonwriter.lines {
+		jsonwriter.writer.WriteString("]")
+	}
+	return jsonwriter.writer.Flush()
+}
+
+// Запись книги Excel из одного листа. Строки пишутся в архив по мере поступления
+type XLSXWriter struct {
+	archive *zip.Writer
+	sheet   *bufio.Writer
+	types   []int
+	line    int64
+}
+
+// Тип значения ячейки по типу колонки
+func IsDateColumnType(columntypeid int) bool {
+	switch columntypeid {
+	case models.COLUMN_TYPE_BIRTHDAY, models.COLUMN_TYPE_SOURCE_DATE, models.COLUMN_TYPE_ANSWER_DATE_RESULT,
+		models.COLUMN_TYPE_ANSWER_PASSPORT_ISSUEDATE:
+		return true
+	}
+	return false
+}
+
+func IsTextColumnType(columntypeid int) bool {
+	switch columntypeid {
+	case models.COLUMN_TYPE_MOBILE_PHONE, models.COLUMN_TYPE_SOURCE_PHONE, models.COLUMN_TYPE_ANSWER_PHONE_RESULT,
+		models.COLUMN_TYPE_ANSWER_PHONE_NUMBER:
+		return true
+	}
+	return false
+}
+
+// Номер колонки в виде адреса Excel (A, B, ..., AA)
+func XLSXColumnName(column int) string {
+	name := ""
+	for column++; column > 0; column = (column - 1) / 26 {
+		name = string(rune('A'+(column-1)%26)) + name
+	}
+	return name
+}
+
+func XLSXEscape(value string) string {
+	buf := new(strings.Builder)
+	xml.EscapeText(buf, []byte(value))
+	return buf.String()
+}
+
+// Дата Excel как число дней с 30.12.1899
+func XLSXDateValue(value string) (serial string, ok bool) {
+	for _, layout := range []string{models.FORMAT_DATE, "02.01.2006"} {
+		date, err := time.Parse(layout, value)
+		if err == nil {
+			days := date.Sub(time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC)).Hours() / 24
+			return fmt.Sprintf("%v", int64(days)), true
+		}
+	}
+	return "", false
+}
+
+func WriteZipEntry(archive *zip.Writer, name string, content string) (err error) {
+	writer, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(writer, content)
+	return err
+}
+
+func NewXLSXWriter(file *os.File, tablecolumns *[]models.DtoTableColumn) (xlsxwriter *XLSXWriter, err error) {
+	xlsxwriter = &XLSXWriter{archive: zip.NewWriter(file)}
+	entries := [][]string{
+		{"[Content_Types].xml", xml.Header + `<Types xmlns="http://schemas.openxmlformats.org/package/2006/content-types">` +
+			`<Default Extension="rels" ContentType="application/vnd.openxmlformats-package.relationships+xml"/>` +
+			`<Default Extension="xml" ContentType="application/xml"/>` +
+			`<Override PartName="/xl/workbook.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.sheet.main+xml"/>` +
+			`<Override PartName="/xl/worksheets/sheet1.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.worksheet+xml"/>` +
+			`<Override PartName="/xl/styles.xml" ContentType="application/vnd.openxmlformats-officedocument.spreadsheetml.styles+xml"/>` +
+			`</Types>`},
+		{"_rels/.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/officeDocument" Target="xl/workbook.xml"/>` +
+			`</Relationships>`},
+		{IMPORT_XLSX_WORKBOOK, xml.Header + `<workbook xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main" ` +
+			`xmlns:r="http://schemas.openxmlformats.org/officeDocument/2006/relationships">` +
+			`<sheets><sheet name="` + EXPORT_XLSX_SHEET_NAME + `" sheetId="1" r:id="rId1"/></sheets></workbook>`},
+		{"xl/_rels/workbook.xml.rels", xml.Header + `<Relationships xmlns="http://schemas.openxmlformats.org/package/2006/relationships">` +
+			`<Relationship Id="rId1" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/worksheet" Target="worksheets/sheet1.xml"/>` +
+			`<Relationship Id="rId2" Type="http://schemas.openxmlformats.org/officeDocument/2006/relationships/styles" Target="styles.xml"/>` +
+			`</Relationships>`},
+		{"xl/styles.xml", xml.Header + `<styleSheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+			`<fonts count="2"><font><sz val="11"/><name val="Calibri"/></font><font><b/><sz val="11"/><name val="Calibri"/></font></fonts>` +
+			`<fills count="1"><fill><patternFill patternType="none"/></fill></fills>` +
+			`<borders count="1"><border><left/><right/><top/><bottom/><diagonal/></border></borders>` +
+			`<cellStyleXfs count="1"><xf numFmtId="0" fontId="0" fillId="0" borderId="0"/></cellStyleXfs>` +
+			`<cellXfs count="3"><xf numFmtId="0" fontId="0" fillId="0" borderId="0" xfId="0"/>` +
+			`<xf numFmtId="14" fontId="0" fillId="0" borderId="0" xfId="0" applyNumberFormat="1"/>` +
+			`<xf numFmtId="0" fontId="1" fillId="0" borderId="0" xfId="0" applyFont="1"/></cellXfs>` +
+			`</styleSheet>`},
+	}
+	for _, entry := range entries {
+		err = WriteZipEntry(xlsxwriter.archive, entry[0], entry[1])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	sheet, err := xlsxwriter.archive.Create(IMPORT_XLSX_SHEET)
+	if err != nil {
+		return nil, err
+	}
+	xlsxwriter.sheet = bufio.NewWriter(sheet)
+	xlsxwriter.sheet.WriteString(xml.Header + `<worksheet xmlns="http://schemas.openxmlformats.org/spreadsheetml/2006/main">` +
+		`<sheetViews><sheetView workbookViewId="0"><pane ySplit="1" topLeftCell="A2" activePane="bottomLeft" state="frozen"/></sheetView></sheetViews>` +
+		`<sheetData><row r="1">`)
+	for i, tablecolumn := range *tablecolumns {
+		xlsxwriter.types = append(xlsxwriter.types, tablecolumn.Column_Type_ID)
+		xlsxwriter.sheet.WriteString(`<c r="` + XLSXColumnName(i) + `1" t="inlineStr" s="2"><is><t>` + XLSXEscape(tablecolumn.Name) + `</t></is></c>`)
+	}
+	_, err = xlsxwriter.sheet.WriteString(`</row>`)
+	xlsxwriter.line = 1
+
+	return xlsxwriter, err
+}
+
+func (xlsxwriter *XLSXWriter) Write(record []string) (err error) {
+	xlsxwriter.line++
+	xlsxwriter.sheet.WriteString(fmt.Sprintf(`<row r="%v">`, xlsxwriter.line))
+	for i, value := range record {
+		if value == "" {
+			continue
+		}
+		reference := fmt.Sprintf("%v%v", XLSXColumnName(i), xlsxwriter.line)
+		columntypeid := models.COLUMN_TYPE_DEFAULT
+		if i < len(xlsxwriter.types) {
+			columntypeid = xlsxwriter.types[i]
+		}
+		if IsDateColumnType(columntypeid) {
+			if serial, ok := XLSXDateValue(value); ok {
+				xlsxwriter.sheet.WriteString(fmt.Sprintf(`<c r="%v" s="%v"><v>%v</v></c>`, reference, EXPORT_XLSX_STYLE_DATE, serial))
+				continue
+			}
+		}
+		if !IsTextColumnType(columntypeid) && exportNumber.MatchString(value) {
+			xlsxwriter.sheet.WriteString(`<c r="` + reference + `"><v>` + value + `</v></c>`)
+			continue
+		}
+		xlsxwriter.sheet.WriteString(`<c r="` + reference + `" t="inlineStr"><is><t xml:space="preserve">` + XLSXEscape(value) + `</t></is></c>`)
+	}
+	_, err = xlsxwriter.sheet.WriteString(`</row>`)
+
+	return err
+}
+
+func (xlsxwriter *XLSXWriter) Close() (err error) {
+	xlsxwriter.sheet.WriteString(`</sheetData></worksheet>`)
+	err = xlsxwriter.sheet.Flush()
+	if err != nil {
+		return err
+	}
+	return xlsxwriter.archive.Close()
+}
@@ -4,22 +4,12 @@ import (
 	"application/config"
 	"application/models"
 	"application/services"
-	"bufio"
-	"encoding/binary"
-	"encoding/csv"
-	"errors"
 	"fmt"
-	"golang.org/x/text/encoding/charmap"
-	"golang.org/x/text/transform"
 	"io"
-	"io/ioutil"
 	"os"
-	"os/exec"
 	"path/filepath"
 	"regexp"
-	"strings"
 	"time"
-	"unicode/utf16"
 )
 
 const (
@@ -33,112 +23,6 @@ const (
 	BLOCK_ROWS_NUMBER = 1000
 )
 
-func ConvertFileToDefault(dataencoding models.DataEncoding, fullpath string) (err error) {
-	data, err := ioutil.ReadFile(fullpath)
-	if err != nil {
-		return err
-	}
-
-	var array []byte
-	var result string
-
-	switch dataencoding {
-	case models.DATA_ENCODING_WINDOWS1251:
-		array, _, err = transform.Bytes(charmap.Windows1251.NewDecoder(), data)
-		result = string(array)
-	case models.DATA_ENCODING_KOI8R:
-		array, _, err = transform.Bytes(charmap.KOI8R.NewDecoder(), data)
-		result = string(array)
-	case models.DATA_ENCODING_MACINTOSH:
-		array, _, err = transform.Bytes(charmap.MacintoshCyrillic.NewDecoder(), data)
-		result = string(array)
-	case models.DATA_ENCODING_UTF16:
-		if len(data)%2 != 0 {
-			err = errors.New("Not Unicode 16")
-			break
-		}
-		var unicode []uint16
-		found := false
-		defaultendian := true
-		if len(data) > 1 {
-			if data[0] == 0xfe && data[1] == 0xff {
-				found = true
-				defaultendian = false
-			}
-			if data[0] == 0xff && data[1] == 0xfe {
-				found = true
-				defaultendian = true
-			}
-		}
-		pos := 0
-		if found {
-			pos = 2
-		}
-		for {
-			if pos+2 > len(data) {
-				break
-			}
-			var symbol uint16
-			if defaultendian {
-				symbol = binary.LittleEndian.Uint16(data[pos : pos+2])
-			} else {
-				symbol = binary.BigEndian.Uint16(data[pos : pos+2])
-			}
-			unicode = append(unicode, symbol)
-			pos = pos + 2
-		}
-		result = string(utf16.Decode(unicode))
-		err = nil
-	case models.DATA_ENCODING_UNKNOWN:
-		fallthrough
-	case models.DATA_ENCODING_UTF8:
-		result = string(data)
-		err = nil
-	default:
-		err = errors.New("Unknown encoding")
-	}
-	if err != nil {
-		return err
-	}
-
-	result = strings.Replace(result, "\r\n", "\n", -1)
-	result = strings.Replace(result, "\r", "\n", -1)
-
-	err = ioutil.WriteFile(fullpath, []byte(result), 0666)
-	if err != nil {
-		return err
-	}
-
-	return nil
-}
-
-func DetectDataFormat(fullpath string) (dataformat models.DataFormat, err error) {
-	file, err := os.Open(fullpath)
-	if err != nil {
-		log.Error("Can't read from file %v with value %v", err, fullpath)
-		return models.DATA_FORMAT_UNKNOWN, err
-	}
-	defer file.Close()
-
-	reader := bufio.NewReader(file)
-	firstline, err := reader.ReadString('\n')
-	if err != nil {
-		if err != io.EOF {
-			log.Error("Can't detect data format for file %v with value %v", err, fullpath)
-			return models.DATA_FORMAT_UNKNOWN, err
-		}
-	}
-	if len(strings.Split(firstline, "\t")) > 1 {
-		return models.DATA_FORMAT_TXT, nil
-	} else if len(strings.Split(firstline, ",")) > 1 {
-		return models.DATA_FORMAT_CSV, nil
-	} else if len(strings.Split(firstline, ";")) > 1 {
-		return models.DATA_FORMAT_SSV, nil
-	}
-
-	return models.DATA_FORMAT_UNKNOWN, nil
-}
-
 func SaveImportError(description string, dtocustomertable *models.DtoCustomerTable, customertablerepository services.CustomerTableRepository) {
 	dtocustomertable.Import_Error = true
 	dtocustomertable.Import_ErrorDescription = description
@@ -159,19 +43,49 @@ func SaveExportError(description string, dtofile *models.DtoFile, filerepository
 	}
 }
 
+// Сохранение пакета строк и отклоненных строк с обновлением прогресса загрузки
+func SaveImportBlock(dtocustomertable *models.DtoCustomerTable, dtotablecolumns *[]models.DtoTableColumn, rows *[]models.DtoImportRow,
+	rejections *[]models.DtoImportRejection, dtoimportstep *models.DtoImportStep, progress byte,
+	customertablerepository services.CustomerTableRepository, importsteprepository services.ImportStepRepository,
+	importrejectionrepository services.ImportRejectionRepository) (err error) {
+	err = customertablerepository.ImportRows(dtocustomertable, dtotablecolumns, rows)
+	if err != nil {
+		return err
+	}
+	err = importrejectionrepository.CreateAll(rejections)
+	if err != nil {
+		return err
+	}
+
+	dtoimportstep.Percentage = progress
+	err = importsteprepository.Save(dtoimportstep)
+	if err != nil {
+		return err
+	}
+	dtocustomertable.Import_Percentage = 50 + progress/4
+	return customertablerepository.Update(dtocustomertable)
+}
+
 func ImportData(viewimporttable models.ViewImportTable, file *models.DtoFile, dtocustomertable *models.DtoCustomerTable,
 	customertablerepository services.CustomerTableRepository, importsteprepository services.ImportStepRepository,
-	columntyperepository services.ColumnTypeRepository, language string) {
+	columntyperepository services.ColumnTypeRepository, importrejectionrepository services.ImportRejectionRepository, language string) {
 	dtoimportstep := models.NewDtoImportStep(dtocustomertable.ID, 2, false, 0, time.Now(), time.Now())
 	err := importsteprepository.Save(dtoimportstep)
 	if err != nil {
 		return
 	}
 
+	var dataencoding models.DataEncoding
+	if viewimporttable.DataEncoding == models.DATA_ENCODING_UNKNOWN {
+		dataencoding = models.DATA_ENCODING_UTF8
+	} else {
+		dataencoding = viewimporttable.DataEncoding
+	}
+
 	fullpath := filepath.Join(config.Configuration.FileStorage, file.Path, fmt.Sprintf("%08d", file.ID))
 	var dataformat models.DataFormat
 	if viewimporttable.DataFormat == models.DATA_FORMAT_UNKNOWN {
-		dataformat, err = DetectDataFormat(fullpath)
+		dataformat, err = DetectDataFormat(fullpath, dataencoding)
 		if err != nil {
 			SaveImportError(config.Localization[language].Errors.Internal.Data_Format, dtocustomertable, customertablerepository)
 			return
@@ -180,48 +94,43 @@ func ImportData(viewimporttable models.ViewImportTable, file *models.DtoFile, dt
 		dataformat = viewimporttable.DataFormat
 	}
 
-	var dataencoding models.DataEncoding
-	if viewimporttable.DataEncoding == models.DATA_ENCODING_UNKNOWN {
-		dataencoding = models.DATA_ENCODING_UTF8
-	} else {
-		dataencoding = viewimporttable.DataEncoding
-	}
-
-	err = ConvertFileToDefault(dataencoding, fullpath)
+	reader, err := NewRowReader(fullpath, dataformat, dataencoding)
 	if err != nil {
-		log.Error("Can't convert file %v to encoding %v with value %v", err, dataencoding, fullpath)
 		SaveImportError(config.Localization[language].Errors.Internal.Data_Format, dtocustomertable, customertablerepository)
 		return
 	}
-
-	csvfile, err := os.Open(fullpath)
-	if err != nil {
-		log.Error("Can't read from file %v with value %v", err, fullpath)
-		SaveImportError(config.Localization[language].Errors.Internal.Data_Reading, dtocustomertable, customertablerepository)
-		return
-	}
-	defer csvfile.Close()
-
-	reader := csv.NewReader(csvfile)
-	reader.FieldsPerRecord = -1
-	reader.Comma = models.GetDataSeparator(dataformat)
-	reader.LazyQuotes = true
-	reader.TrimLeadingSpace = true
+	defer reader.Close()
 
 	log.Info("Detected data format %v", dataformat)
 	log.Info("Start reading file %v", time.Now())
-	rawCSVdata, err := reader.ReadAll()
-	if err != nil {
-		log.Error("Can't read from file %v with value %v", err, fullpath)
-		SaveImportError(config.Localization[language].Errors.Internal.Data_Reading, dtocustomertable, customertablerepository)
-		return
+	rejections := new([]models.DtoImportRejection)
+	rejectioncount := 0
+	reject := func(line int64, reason string, imported bool) {
+		rejectioncount++
+		if rejectioncount > models.MAX_IMPORT_REJECTIONS {
+			return
+		}
+		*rejections = append(*rejections, *models.NewDtoImportRejection(0, dtocustomertable.ID, line, reason, imported, time.Now()))
 	}
-	rowcount := len(rawCSVdata)
-	if viewimporttable.HasHeader && rowcount > 0 {
-		rowcount -= 1
+
+	var first []string
+	for first == nil {
+		first, err = reader.Read()
+		if err != nil {
+			if rowerror, ok := err.(*ImportRowError); ok {
+				reject(rowerror.Line, config.Localization[language].Errors.Internal.Data_Format+": "+rowerror.Reason, false)
+				continue
+			}
+			if err != io.EOF {
+				log.Error("Can't read from file %v with value %v", err, fullpath)
+				SaveImportError(config.Localization[language].Errors.Internal.Data_Reading, dtocustomertable, customertablerepository)
+				return
+			}
+			break
+		}
 	}
-	columncount := len(rawCSVdata[0])
-	log.Info("Stop reading file %v row count %v column count %v", time.Now(), rowcount, columncount)
+	hasheader := viewimporttable.HasHeader || reader.Header()
+	columncount := len(first)
 
 	if columncount == 0 {
 		log.Error("Can't find any data in file %v", fullpath)
@@ -243,12 +152,11 @@ func ImportData(viewimporttable models.ViewImportTable, file *models.DtoFile, dt
 	}
 
 	dtotablecolumns := new([]models.DtoTableColumn)
-	position := 0
-	for _, column := range rawCSVdata[0] {
+	for position, column := range first {
 		dtotablecolumn := new(models.DtoTableColumn)
 		dtotablecolumn.Created = time.Now()
 		dtotablecolumn.Position = int64(position)
-		if viewimporttable.HasHeader {
+		if hasheader {
 			dtotablecolumn.Name = column
 		} else {
 			dtotablecolumn.Name = fmt.Sprintf("Column %v", position)
@@ -259,7 +167,6 @@ func ImportData(viewimporttable models.ViewImportTable, file *models.DtoFile, dt
 		dtotablecolumn.FieldNum = byte(position) + 1
 		dtotablecolumn.Active = true
 		dtotablecolumn.Edition = 0
-		position++
 
 		*dtotablecolumns = append(*dtotablecolumns, *dtotablecolumn)
 	}
@@ -269,39 +176,6 @@ func ImportData(viewimporttable models.ViewImportTable, file *models.DtoFile, dt
 		SaveImportError(config.Localization[language].Errors.Internal.Data_Writing, dtocustomertable, customertablerepository)
 		return
 	}
-
-	wrongrowcount := 0
-	log.Info("Start analazying table data %v", time.Now())
-	position = 0
-	for i, _ := range rawCSVdata {
-		if i == 0 && viewimporttable.HasHeader {
-			rawCSVdata[i] = append(rawCSVdata[i], "position")
-			rawCSVdata[i] = append(rawCSVdata[i], "wrong")
-			continue
-		}
-		wrong := 0
-		current_columncount := len(rawCSVdata[i])
-		if current_columncount != columncount {
-			wrong = 1
-			wrongrowcount++
-			if current_columncount < columncount {
-				for j := current_columncount; j < columncount; j++ {
-					rawCSVdata[i] = append(rawCSVdata[i], "")
-				}
-			} else {
-				for j := columncount; j < current_columncount; j++ {
-					rawCSVdata[i] = append(rawCSVdata[i][:columncount], rawCSVdata[i][columncount+1:]...)
-				}
-			}
-		}
-		rawCSVdata[i] = append(rawCSVdata[i], fmt.Sprintf("%v", position))
-		rawCSVdata[i] = append(rawCSVdata[i], fmt.Sprintf("%v", wrong))
-		position++
-		if i%BLOCK_ROWS_NUMBER == 0 {
-			log.Info("Continue analyzing table data %v at position %v", time.Now(), i)
-		}
-	}
-	log.Info("Stop analyzing table data %v", time.Now())
 	// 2
 	dtoimportstep.Ready = true
 	dtoimportstep.Percentage = 100
@@ -313,8 +187,8 @@ func ImportData(viewimporttable models.ViewImportTable, file *models.DtoFile, dt
 
 	dtocustomertable.Import_Percentage = 50
 	dtocustomertable.Import_Columns = int64(columncount)
-	dtocustomertable.Import_Rows = int64(rowcount)
-	dtocustomertable.Import_WrongRows = int64(wrongrowcount)
+	dtocustomertable.Import_Rows = 0
+	dtocustomertable.Import_WrongRows = 0
 	err = customertablerepository.Update(dtocustomertable)
 	if err != nil {
 		return
@@ -329,37 +203,62 @@ func ImportData(viewimporttable models.ViewImportTable, file *models.DtoFile, dt
 	if err != nil {
 		return
 	}
-	version := fmt.Sprintf(".%v", time.Now().UTC().UnixNano())
-	fullpath += version
-	newcsvfile, err := os.Create(fullpath)
-	if err != nil {
-		log.Error("Can't write to file %v with value %v", err, fullpath)
-		SaveImportError(config.Localization[language].Errors.Internal.Data_Writing, dtocustomertable, customertablerepository)
-		return
-	}
 
-	writer := csv.NewWriter(newcsvfile)
-	writer.Comma = models.GetDataSeparator(dataformat)
-	writer.UseCRLF = false
-
-	log.Info("Start writing file %v", time.Now())
-	err = writer.WriteAll(rawCSVdata)
-	if err != nil {
-		newcsvfile.Close()
-		log.Error("Can't write to file %v with value %v", err, fullpath)
-		SaveImportError(config.Localization[language].Errors.Internal.Data_Writing, dtocustomertable, customertablerepository)
-		return
+	log.Info("Start inserting table rows %v", time.Now())
+	rows := new([]models.DtoImportRow)
+	if !hasheader {
+		*rows = append(*rows, *models.NewDtoImportRow(0, first, false))
 	}
-	log.Info("Stop writing file %v", time.Now())
-	newcsvfile.Close()
+	for {
+		record, err := reader.Read()
+		if err != nil {
+			if rowerror, ok := err.(*ImportRowError); ok {
+				reject(rowerror.Line, config.Localization[language].Errors.Internal.Data_Format+": "+rowerror.Reason, false)
+				continue
+			}
+			if err != io.EOF {
+				log.Error("Can't read from file %v with value %v", err, fullpath)
+				SaveImportError(config.Localization[language].Errors.Internal.Data_Reading, dtocustomertable, customertablerepository)
+				return
+			}
+		}
 
-	err = customertablerepository.ImportData(file, dtocustomertable, dataformat, viewimporttable.HasHeader, dtotablecolumns, version)
-	if err != nil {
-		os.Remove(fullpath)
-		SaveImportError(config.Localization[language].Errors.Internal.Data_Writing, dtocustomertable, customertablerepository)
-		return
+		if record != nil {
+			wrong := len(record) != columncount
+			if wrong {
+				reject(reader.Line(), config.Localization[language].Errors.Internal.Data_Columns, true)
+				for len(record) < columncount {
+					record = append(record, "")
+				}
+				record = record[:columncount]
+			}
+			*rows = append(*rows, *models.NewDtoImportRow(dtocustomertable.Import_Rows+int64(len(*rows)), record, wrong))
+		}
+
+		if len(*rows) == BLOCK_ROWS_NUMBER || (err == io.EOF && len(*rows)+len(*rejections) != 0) {
+			for _, row := range *rows {
+				if row.Wrong {
+					dtocustomertable.Import_WrongRows++
+				}
+			}
+			dtocustomertable.Import_Rows += int64(len(*rows))
+			err = SaveImportBlock(dtocustomertable, dtotablecolumns, rows, rejections, dtoimportstep, reader.Progress(),
+				customertablerepository, importsteprepository, importrejectionrepository)
+			if err != nil {
+				SaveImportError(config.Localization[language].Errors.Internal.Data_Writing, dtocustomertable, customertablerepository)
+				return
+			}
+			log.Info("Continue inserting table rows %v at position %v", time.Now(), dtocustomertable.Import_Rows)
+			*rows = (*rows)[:0]
+			*rejections = (*rejections)[:0]
+			if record == nil {
+				break
+			}
+		} else if record == nil {
+			break
+		}
 	}
-	os.Remove(fullpath)
+	log.Info("Stop inserting table rows %v row count %v column count %v", time.Now(), dtocustomertable.Import_Rows, columncount)
 	// 3
 	dtoimportstep.Ready = true
 	dtoimportstep.Percentage = 100
@@ -377,32 +276,91 @@ func ImportData(viewimporttable models.ViewImportTable, file *models.DtoFile, dt
 }
 
 func ExportData(viewexporttable *models.ViewExportTable, file *models.DtoFile, dtocustomertable *models.DtoCustomerTable,
-	tablecolumns *[]models.DtoTableColumn, filerepository services.FileRepository, customertablerepository services.CustomerTableRepository, language string) {
+	tablecolumns *[]models.DtoTableColumn, filerepository services.FileRepository, tablerowrepository services.TableRowRepository,
+	webhookdeliveryrepository services.WebhookDeliveryRepository, language string) {
+	total, err := tablerowrepository.GetExportCount(dtocustomertable.ID, tablecolumns, viewexporttable.Type)
+	if err != nil {
+		SaveExportError(config.Localization[language].Errors.Internal.Data_Reading, file, filerepository)
+		return
+	}
+
+	fullpath := filepath.Join(config.Configuration.FileStorage, file.Path, fmt.Sprintf("%08d", file.ID))
 	version := fmt.Sprintf(".%v", time.Now().UTC().UnixNano())
-	err := customertablerepository.ExportData(viewexporttable, file, dtocustomertable, tablecolumns, true, version)
+	err = os.MkdirAll(filepath.Dir(fullpath), 0777)
 	if err != nil {
+		log.Error("Can't create directory %v with value %v", err, fullpath)
 		SaveExportError(config.Localization[language].Errors.Internal.Data_Writing, file, filerepository)
 		return
 	}
-
-	abstemppath, err := filepath.Abs(config.Configuration.TempDirectory)
+	exportfile, err := os.Create(fullpath + version)
 	if err != nil {
-		log.Error("Can't make an absolute path for %v, %v", config.Configuration.TempDirectory, err)
-		SaveExportError(config.Localization[language].Errors.Internal.Data_Reading, file, filerepository)
+		log.Error("Can't write to file %v with value %v", err, fullpath+version)
+		SaveExportError(config.Localization[language].Errors.Internal.Data_Writing, file, filerepository)
 		return
 	}
-	absfilepath, err := filepath.Abs(config.Configuration.FileStorage)
+	defer os.Remove(fullpath + version)
+	defer exportfile.Close()
+
+	writer, err := NewRowWriter(exportfile, models.DataFormat(viewexporttable.Data_Format_ID), tablecolumns)
 	if err != nil {
-		log.Error("Can't make an absolute path for %v, %v", config.Configuration.FileStorage, err)
-		SaveExportError(config.Localization[language].Errors.Internal.Data_Reading, file, filerepository)
+		log.Error("Can't write to file %v with value %v", err, fullpath+version)
+		SaveExportError(config.Localization[language].Errors.Internal.Data_Writing, file, filerepository)
 		return
 	}
 
-	mvCmd := exec.Command("mv", "-f", filepath.Join(abstemppath, fmt.Sprintf("%08d", file.ID)+version),
-		filepath.Join(absfilepath, file.Path, fmt.Sprintf("%08d", file.ID)))
-	err = mvCmd.Run()
+	var offset int64 = 0
+	var count int64 = BLOCK_ROWS_NUMBER
+	var exported int64 = 0
+	log.Info("Start exporting rows %v", time.Now())
+	for {
+		dtotablerows, err := tablerowrepository.GetExport(offset, count, dtocustomertable.ID, tablecolumns, viewexporttable.Type)
+		if err != nil {
+			SaveExportError(config.Localization[language].Errors.Internal.Data_Reading, file, filerepository)
+			return
+		}
+		if len(*dtotablerows) == 0 {
+			break
+		}
+		for i, _ := range *dtotablerows {
+			record := []string{}
+			for _, tablecolumn := range *tablecolumns {
+				tablecell, err := (&(*dtotablerows)[i]).TableRowToDtoTableCell(&tablecolumn)
+				if err != nil {
+					SaveExportError(config.Localization[language].Errors.Internal.Data_Reading, file, filerepository)
+					return
+				}
+				record = append(record, tablecell.Value)
+			}
+			err = writer.Write(record)
+			if err != nil {
+				log.Error("Can't write to file %v with value %v", err, fullpath+version)
+				SaveExportError(config.Localization[language].Errors.Internal.Data_Writing, file, filerepository)
+				return
+			}
+		}
+		exported += int64(len(*dtotablerows))
+		offset = (*dtotablerows)[len(*dtotablerows)-1].Position + 1
+
+		if total > 0 && exported < total {
+			file.Export_Percentage = byte(exported * 100 / total)
+			err = filerepository.Update(file)
+			if err != nil {
+				return
+			}
+		}
+		log.Info("Continue exporting rows %v at position %v", time.Now(), offset)
+	}
+	log.Info("Stop exporting rows %v", time.Now())
+
+	err = writer.Close()
+	if err == nil {
+		err = exportfile.Close()
+	}
+	if err == nil {
+		err = os.Rename(fullpath+version, fullpath)
+	}
 	if err != nil {
-		log.Error("Can't move temporable file %v with value %v%v", err, file.ID, version)
+		log.Error("Can't write to file %v with value %v", err, fullpath)
 		SaveExportError(config.Localization[language].Errors.Internal.Data_Writing, file, filerepository)
 		return
 	}
@@ -413,11 +371,14 @@ func ExportData(viewexporttable *models.ViewExportTable, file *models.DtoFile, d
 	if err != nil {
 		return
 	}
+
+	_ = webhookdeliveryrepository.Enqueue(dtocustomertable.UnitID, models.WEBHOOK_EVENT_EXPORT_COMPLETED,
+		models.ApiExportEvent{Table_ID: dtocustomertable.ID, File_ID: file.ID}, nil)
 }
 
 func CheckTableCells(dtocustomertable *models.DtoCustomerTable, tablecolumnrepository services.TableColumnRepository,
 	columntyperepository services.ColumnTypeRepository, tablerowrepository services.TableRowRepository,
-	importsteprepository services.ImportStepRepository) {
+	importsteprepository services.ImportStepRepository, webhookdeliveryrepository services.WebhookDeliveryRepository) {
 
 	tablecolumns, err := tablecolumnrepository.GetByTable(dtocustomertable.ID)
 	if err != nil {
@@ -491,4 +452,8 @@ func CheckTableCells(dtocustomertable *models.DtoCustomerTable, tablecolumnrepos
 	if err != nil {
 		return
 	}
+
+	_ = webhookdeliveryrepository.Enqueue(dtocustomertable.UnitID, models.WEBHOOK_EVENT_IMPORT_COMPLETED,
+		models.ApiImportEvent{Table_ID: dtocustomertable.ID, Rows: dtocustomertable.Import_Rows,
+			WrongRows: dtocustomertable.Import_WrongRows}, nil)
 }
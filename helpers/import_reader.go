@@ -0,0 +1,979 @@
+package helpers
+
+import (
+	"application/models"
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+	"io"
+	"math"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	IMPORT_DETECT_SIZE   = 64 * 1024
+	IMPORT_LINE_SIZE     = 16 * 1024 * 1024
+	IMPORT_ODS_MIMETYPE  = "application/vnd.oasis.opendocument.spreadsheet"
+	IMPORT_XLSX_WORKBOOK = "xl/workbook.xml"
+	IMPORT_XLSX_SHEET    = "xl/worksheets/sheet1.xml"
+)
+
+// Ошибка разбора отдельной строки, после которой чтение можно продолжить
+type ImportRowError struct {
+	Line   int64  // Номер строки в исходном файле
+	Reason string // Причина отклонения
+}
+
+func (importrowerror *ImportRowError) Error() string {
+	return fmt.Sprintf("line %v: %v", importrowerror.Line, importrowerror.Reason)
+}
+
+// Построчное чтение импортируемого файла. Пустые строки пропускаются, в конце файла возвращается io.EOF
+type RowReader interface {
+	Read() (record []string, err error)
+	Line() int64    // Номер строки исходного файла последней прочитанной записи
+	Progress() byte // Процент прочитанных данных
+	Header() bool   // Первая запись всегда является заголовком
+	Close() (err error)
+}
+
+// Счетчик прочитанных байт для оценки прогресса чтения
+type CountingReader struct {
+	Reader io.Reader
+	Count  int64
+	Total  int64
+}
+
+func (countingreader *CountingReader) Read(p []byte) (n int, err error) {
+	n, err = countingreader.Reader.Read(p)
+	countingreader.Count += int64(n)
+	return n, err
+}
+
+func (countingreader *CountingReader) Progress() byte {
+	if countingreader.Total <= 0 || countingreader.Count >= countingreader.Total {
+		return 100
+	}
+	return byte(countingreader.Count * 100 / countingreader.Total)
+}
+
+func IsEmptyRecord(record []string) bool {
+	for _, value := range record {
+		if strings.TrimSpace(value) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// Декодирование текстового потока в utf-8
+func DecodeReader(dataencoding models.DataEncoding, reader io.Reader) (decoded io.Reader, err error) {
+	switch dataencoding {
+	case models.DATA_ENCODING_WINDOWS1251:
+		return transform.NewReader(reader, charmap.Windows1251.NewDecoder()), nil
+	case models.DATA_ENCODING_KOI8R:
+		return transform.NewReader(reader, charmap.KOI8R.NewDecoder()), nil
+	case models.DATA_ENCODING_MACINTOSH:
+		return transform.NewReader(reader, charmap.MacintoshCyrillic.NewDecoder()), nil
+	case models.DATA_ENCODING_UTF16:
+		return transform.NewReader(reader, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM).NewDecoder()), nil
+	case models.DATA_ENCODING_UNKNOWN, models.DATA_ENCODING_UTF8:
+		return transform.NewReader(reader, unicode.UTF8.NewDecoder()), nil
+	}
+
+	return nil, errors.New("Unknown encoding")
+}
+
+// Определение разделителя по первой записи без учета разделителей внутри кавычек
+func DetectSeparator(sample []byte) models.DataFormat {
+	counts := map[byte]int{}
+	quoted := false
+	for _, symbol := range sample {
+		if symbol == '"' {
+			quoted = !quoted
+			continue
+		}
+		if quoted {
+			continue
+		}
+		if symbol == '\n' || symbol == '\r' {
+			break
+		}
+		counts[symbol]++
+	}
+	switch {
+	case counts['\t'] > 0:
+		return models.DATA_FORMAT_TXT
+	case counts[';'] > counts[',']:
+		return models.DATA_FORMAT_SSV
+	case counts[','] > 0:
+		return models.DATA_FORMAT_CSV
+	}
+
+	return models.DATA_FORMAT_UNKNOWN
+}
+
+func DetectDataFormat(fullpath string, dataencoding models.DataEncoding) (dataformat models.DataFormat, err error) {
+	archive, err := zip.OpenReader(fullpath)
+	if err == nil {
+		defer archive.Close()
+		for _, entry := range archive.File {
+			switch entry.Name {
+			case IMPORT_XLSX_WORKBOOK:
+				return models.DATA_FORMAT_XLSX, nil
+			case "mimetype":
+				content, err := ReadZipEntry(entry)
+				if err == nil && strings.TrimSpace(string(content)) == IMPORT_ODS_MIMETYPE {
+					return models.DATA_FORMAT_ODS, nil
+				}
+			}
+		}
+		return models.DATA_FORMAT_UNKNOWN, nil
+	}
+
+	file, err := os.Open(fullpath)
+	if err != nil {
+		log.Error("Can't read from file %v with value %v", err, fullpath)
+		return models.DATA_FORMAT_UNKNOWN, err
+	}
+	defer file.Close()
+
+	decoded, err := DecodeReader(dataencoding, file)
+	if err != nil {
+		return models.DATA_FORMAT_UNKNOWN, err
+	}
+	sample := make([]byte, IMPORT_DETECT_SIZE)
+	n, err := io.ReadFull(decoded, sample)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		log.Error("Can't detect data format for file %v with value %v", err, fullpath)
+		return models.DATA_FORMAT_UNKNOWN, err
+	}
+	sample = bytes.TrimLeft(sample[:n], "\ufeff \t\r\n")
+	if len(sample) != 0 {
+		switch sample[0] {
+		case '[':
+			return models.DATA_FORMAT_JSON, nil
+		case '{':
+			return models.DATA_FORMAT_NDJSON, nil
+		}
+	}
+
+	return DetectSeparator(sample), nil
+}
+
+// Открытие файла для построчного чтения в заданном формате
+func NewRowReader(fullpath string, dataformat models.DataFormat, dataencoding models.DataEncoding) (reader RowReader, err error) {
+	switch dataformat {
+	case models.DATA_FORMAT_XLSX:
+		return NewXLSXReader(fullpath)
+	case models.DATA_FORMAT_ODS:
+		return NewODSReader(fullpath)
+	}
+
+	file, err := os.Open(fullpath)
+	if err != nil {
+		log.Error("Can't read from file %v with value %v", err, fullpath)
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		log.Error("Can't read from file %v with value %v", err, fullpath)
+		return nil, err
+	}
+	counter := &CountingReader{Reader: file, Total: info.Size()}
+	decoded, err := DecodeReader(dataencoding, counter)
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	switch dataformat {
+	case models.DATA_FORMAT_JSON:
+		return NewJSONReader(file, counter, decoded, false), nil
+	case models.DATA_FORMAT_NDJSON:
+		return NewJSONReader(file, counter, decoded, true), nil
+	}
+	return NewCSVReader(file, counter, decoded, models.GetDataSeparator(dataformat)), nil
+}
+
+func ReadZipEntry(entry *zip.File) (content []byte, err error) {
+	reader, err := entry.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	buf := new(bytes.Buffer)
+	_, err = buf.ReadFrom(reader)
+	return buf.Bytes(), err
+}
+
+func FindZipEntry(archive *zip.ReadCloser, name string) *zip.File {
+	for _, entry := range archive.File {
+		if entry.Name == name {
+			return entry
+		}
+	}
+	return nil
+}
+
+func XMLAttr(element xml.StartElement, name string) string {
+	for _, attr := range element.Attr {
+		if attr.Name.Local == name {
+			return attr.Value
+		}
+	}
+	return ""
+}
+
+// Чтение файла с разделителями по RFC 4180
+type CSVReader struct {
+	file    *os.File
+	counter *CountingReader
+	reader  *csv.Reader
+	line    int64
+	first   bool
+}
+
+func NewCSVReader(file *os.File, counter *CountingReader, decoded io.Reader, separator rune) *CSVReader {
+	reader := csv.NewReader(decoded)
+	reader.Comma = separator
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = false
+	return &CSVReader{file: file, counter: counter, reader: reader, first: true}
+}
+
+func (csvreader *CSVReader) Read() (record []string, err error) {
+	for {
+		record, err = csvreader.reader.Read()
+		if err != nil {
+			if parseerror, ok := err.(*csv.ParseError); ok {
+				csvreader.line = int64(parseerror.Line)
+				return nil, &ImportRowError{Line: csvreader.line, Reason: parseerror.Err.Error()}
+			}
+			return nil, err
+		}
+		line, _ := csvreader.reader.FieldPos(0)
+		csvreader.line = int64(line)
+		if csvreader.first && len(record) != 0 {
+			record[0] = strings.TrimPrefix(record[0], "\ufeff")
+		}
+		csvreader.first = false
+		if !IsEmptyRecord(record) {
+			return record, nil
+		}
+	}
+}
+
+func (csvreader *CSVReader) Line() int64 {
+	return csvreader.line
+}
+
+func (csvreader *CSVReader) Progress() byte {
+	return csvreader.counter.Progress()
+}
+
+func (csvreader *CSVReader) Header() bool {
+	return false
+}
+
+func (csvreader *CSVReader) Close() (err error) {
+	return csvreader.file.Close()
+}
+
+// Чтение массива json или json построчно (ndjson). Объекты преобразуются в строки по ключам первого объекта
+type JSONReader struct {
+	file    *os.File
+	counter *CountingReader
+	decoder *json.Decoder
+	scanner *bufio.Scanner
+	line    int64
+	started bool
+	keys    []string
+	pending []string
+}
+
+func NewJSONReader(file *os.File, counter *CountingReader, decoded io.Reader, lines bool) *JSONReader {
+	jsonreader := &JSONReader{file: file, counter: counter}
+	if lines {
+		jsonreader.scanner = bufio.NewScanner(decoded)
+		jsonreader.scanner.Buffer(make([]byte, 64*1024), IMPORT_LINE_SIZE)
+	} else {
+		jsonreader.decoder = json.NewDecoder(decoded)
+		jsonreader.decoder.UseNumber()
+	}
+	return jsonreader
+}
+
+// Получение следующего элемента массива или строки
+func (jsonreader *JSONReader) Next() (raw json.RawMessage, err error) {
+	if jsonreader.scanner != nil {
+		for jsonreader.scanner.Scan() {
+			jsonreader.line++
+			line := bytes.TrimSpace(jsonreader.scanner.Bytes())
+			if jsonreader.line == 1 {
+				line = bytes.TrimPrefix(line, []byte("\ufeff"))
+			}
+			if len(line) != 0 {
+				return json.RawMessage(append([]byte{}, line...)), nil
+			}
+		}
+		if jsonreader.scanner.Err() != nil {
+			return nil, jsonreader.scanner.Err()
+		}
+		return nil, io.EOF
+	}
+
+	if !jsonreader.started {
+		token, err := jsonreader.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		if delim, ok := token.(json.Delim); !ok || delim != '[' {
+			return nil, errors.New("JSON array is expected")
+		}
+		jsonreader.started = true
+	}
+	if !jsonreader.decoder.More() {
+		return nil, io.EOF
+	}
+	jsonreader.line++
+	err = jsonreader.decoder.Decode(&raw)
+	return raw, err
+}
+
+func JSONValue(value interface{}) string {
+	switch typed := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return typed
+	case json.Number:
+		return typed.String()
+	case bool:
+		return strconv.FormatBool(typed)
+	}
+	buf, err := json.Marshal(value)
+	if err != nil {
+		return ""
+	}
+	return string(buf)
+}
+
+// Разбор объекта с сохранением порядка ключей
+func JSONObject(raw json.RawMessage) (keys []string, values map[string]string, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	_, err = decoder.Token()
+	if err != nil {
+		return nil, nil, err
+	}
+	values = make(map[string]string)
+	for decoder.More() {
+		token, err := decoder.Token()
+		if err != nil {
+			return nil, nil, err
+		}
+		key, ok := token.(string)
+		if !ok {
+			return nil, nil, errors.New("JSON object key is expected")
+		}
+		var value interface{}
+		err = decoder.Decode(&value)
+		if err != nil {
+			return nil, nil, err
+		}
+		if _, ok := values[key]; !ok {
+			keys = append(keys, key)
+		}
+		values[key] = JSONValue(value)
+	}
+
+	return keys, values, nil
+}
+
+func (jsonreader *JSONReader) Read() (record []string, err error) {
+	if jsonreader.pending != nil {
+		record, jsonreader.pending = jsonreader.pending, nil
+		return record, nil
+	}
+	for {
+		// Ошибка структуры массива или чтения файла не позволяет продолжить чтение
+		raw, err := jsonreader.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		trimmed := bytes.TrimSpace(raw)
+		switch {
+		case len(trimmed) == 0:
+			continue
+		case trimmed[0] == '{':
+			keys, values, err := JSONObject(trimmed)
+			if err != nil {
+				return nil, &ImportRowError{Line: jsonreader.line, Reason: err.Error()}
+			}
+			header := false
+			if jsonreader.keys == nil {
+				jsonreader.keys = keys
+				header = true
+			}
+			record = make([]string, len(jsonreader.keys))
+			for i, key := range jsonreader.keys {
+				record[i] = values[key]
+				delete(values, key)
+			}
+			// Значения по неизвестным ключам добавляются в конец строки, и строка помечается как неверная
+			for _, key := range keys {
+				if value, ok := values[key]; ok {
+					record = append(record, value)
+				}
+			}
+			if header {
+				jsonreader.pending = record
+				return append([]string{}, jsonreader.keys...), nil
+			}
+		case trimmed[0] == '[':
+			var values []interface{}
+			decoder := json.NewDecoder(bytes.NewReader(trimmed))
+			decoder.UseNumber()
+			err = decoder.Decode(&values)
+			if err != nil {
+				return nil, &ImportRowError{Line: jsonreader.line, Reason: err.Error()}
+			}
+			record = make([]string, len(values))
+			for i := range values {
+				record[i] = JSONValue(values[i])
+			}
+		default:
+			var value interface{}
+			decoder := json.NewDecoder(bytes.NewReader(trimmed))
+			decoder.UseNumber()
+			err = decoder.Decode(&value)
+			if err != nil {
+				return nil, &ImportRowError{Line: jsonreader.line, Reason: err.Error()}
+			}
+			record = []string{JSONValue(value)}
+		}
+		if !IsEmptyRecord(record) {
+			return record, nil
+		}
+	}
+}
+
+func (jsonreader *JSONReader) Line() int64 {
+	return jsonreader.line
+}
+
+func (jsonreader *JSONReader) Progress() byte {
+	return jsonreader.counter.Progress()
+}
+
+func (jsonreader *JSONReader) Header() bool {
+	return jsonreader.keys != nil
+}
+
+func (jsonreader *JSONReader) Close() (err error) {
+	return jsonreader.file.Close()
+}
+
+// Чтение первого листа книги Excel
+type XLSXReader struct {
+	archive *zip.ReadCloser
+	sheet   io.ReadCloser
+	counter *CountingReader
+	decoder *xml.Decoder
+	strings []string
+	dates   map[int]bool
+	line    int64
+}
+
+// Номер колонки по адресу ячейки вида AB12
+func XLSXColumn(reference string) int {
+	column := 0
+	for _, symbol := range reference {
+		if symbol < 'A' || symbol > 'Z' {
+			break
+		}
+		column = column*26 + int(symbol-'A') + 1
+	}
+	return column - 1
+}
+
+// Форматы даты и времени по номеру встроенного формата или по маске пользовательского формата
+func XLSXDateFormat(id int, code string) bool {
+	if (id >= 14 && id <= 22) || (id >= 45 && id <= 47) {
+		return true
+	}
+	if code == "" {
+		return false
+	}
+	quoted := false
+	for _, symbol := range strings.ToLower(code) {
+		switch {
+		case symbol == '"':
+			quoted = !quoted
+		case quoted:
+		case symbol == 'y' || symbol == 'd' || symbol == 'h' || symbol == 's':
+			return true
+		}
+	}
+	return false
+}
+
+// Преобразование даты Excel из числа дней с 30.12.1899
+func XLSXDate(value string) string {
+	number, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return value
+	}
+	days, fraction := math.Modf(number)
+	date := time.Date(1899, 12, 30, 0, 0, 0, 0, time.UTC).AddDate(0, 0, int(days)).
+		Add(time.Duration(math.Floor(fraction*86400+0.5)) * time.Second)
+	if fraction == 0 {
+		return date.Format(models.FORMAT_DATE)
+	}
+	return date.Format("2006-01-02 15:04:05")
+}
+
+func NewXLSXReader(fullpath string) (xlsxreader *XLSXReader, err error) {
+	archive, err := zip.OpenReader(fullpath)
+	if err != nil {
+		log.Error("Can't open xlsx file %v with value %v", err, fullpath)
+		return nil, err
+	}
+	xlsxreader = &XLSXReader{archive: archive, dates: make(map[int]bool)}
+
+	err = xlsxreader.LoadStrings()
+	if err == nil {
+		err = xlsxreader.LoadStyles()
+	}
+	if err != nil {
+		archive.Close()
+		log.Error("Can't read xlsx file %v with value %v", err, fullpath)
+		return nil, err
+	}
+
+	entry := FindZipEntry(archive, xlsxreader.SheetName())
+	if entry == nil {
+		archive.Close()
+		log.Error("Can't find worksheet in xlsx file %v", fullpath)
+		return nil, errors.New("Worksheet not found")
+	}
+	xlsxreader.sheet, err = entry.Open()
+	if err != nil {
+		archive.Close()
+		log.Error("Can't read xlsx file %v with value %v", err, fullpath)
+		return nil, err
+	}
+	xlsxreader.counter = &CountingReader{Reader: xlsxreader.sheet, Total: int64(entry.UncompressedSize64)}
+	xlsxreader.decoder = xml.NewDecoder(xlsxreader.counter)
+
+	return xlsxreader, nil
+}
+
+// Путь к первому листу книги по описанию книги и связям
+func (xlsxreader *XLSXReader) SheetName() string {
+	workbook := FindZipEntry(xlsxreader.archive, IMPORT_XLSX_WORKBOOK)
+	relations := FindZipEntry(xlsxreader.archive, "xl/_rels/workbook.xml.rels")
+	if workbook == nil || relations == nil {
+		return IMPORT_XLSX_SHEET
+	}
+	content, err := ReadZipEntry(workbook)
+	if err != nil {
+		return IMPORT_XLSX_SHEET
+	}
+	relation := ""
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	for relation == "" {
+		token, err := decoder.Token()
+		if err != nil {
+			return IMPORT_XLSX_SHEET
+		}
+		if element, ok := token.(xml.StartElement); ok && element.Name.Local == "sheet" {
+			relation = XMLAttr(element, "id")
+		}
+	}
+	content, err = ReadZipEntry(relations)
+	if err != nil {
+		return IMPORT_XLSX_SHEET
+	}
+	decoder = xml.NewDecoder(bytes.NewReader(content))
+	for {
+		token, err := decoder.Token()
+		if err != nil {
+			return IMPORT_XLSX_SHEET
+		}
+		if element, ok := token.(xml.StartElement); ok && element.Name.Local == "Relationship" && XMLAttr(element, "Id") == relation {
+			target := XMLAttr(element, "Target")
+			if strings.HasPrefix(target, "/") {
+				return strings.TrimPrefix(target, "/")
+			}
+			return path.Join("xl", target)
+		}
+	}
+}
+
+// Загрузка таблицы общих строк
+func (xlsxreader *XLSXReader) LoadStrings() (err error) {
+	entry := FindZipEntry(xlsxreader.archive, "xl/sharedStrings.xml")
+	if entry == nil {
+		return nil
+	}
+	reader, err := entry.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	decoder := xml.NewDecoder(reader)
+	var value bytes.Buffer
+	text, phonetic := false, false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "si":
+				value.Reset()
+			case "t":
+				text = true
+			case "rPh":
+				phonetic = true
+			}
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "si":
+				xlsxreader.strings = append(xlsxreader.strings, value.String())
+			case "t":
+				text = false
+			case "rPh":
+				phonetic = false
+			}
+		case xml.CharData:
+			if text && !phonetic {
+				value.Write(element)
+			}
+		}
+	}
+}
+
+// Загрузка стилей ячеек для распознавания дат
+func (xlsxreader *XLSXReader) LoadStyles() (err error) {
+	entry := FindZipEntry(xlsxreader.archive, "xl/styles.xml")
+	if entry == nil {
+		return nil
+	}
+	content, err := ReadZipEntry(entry)
+	if err != nil {
+		return err
+	}
+
+	formats := make(map[int]string)
+	decoder := xml.NewDecoder(bytes.NewReader(content))
+	cellxfs := false
+	style := 0
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "numFmt":
+				id, _ := strconv.Atoi(XMLAttr(element, "numFmtId"))
+				formats[id] = XMLAttr(element, "formatCode")
+			case "cellXfs":
+				cellxfs = true
+			case "xf":
+				if cellxfs {
+					id, _ := strconv.Atoi(XMLAttr(element, "numFmtId"))
+					if XLSXDateFormat(id, formats[id]) {
+						xlsxreader.dates[style] = true
+					}
+					style++
+				}
+			}
+		case xml.EndElement:
+			if element.Name.Local == "cellXfs" {
+				cellxfs = false
+			}
+		}
+	}
+}
+
+func (xlsxreader *XLSXReader) Read() (record []string, err error) {
+	var value bytes.Buffer
+	column, celltype, cellstyle := 0, "", 0
+	text := false
+	reason := ""
+	for {
+		token, err := xlsxreader.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "row":
+				record = []string{}
+				reason = ""
+				xlsxreader.line++
+				if number, err := strconv.ParseInt(XMLAttr(element, "r"), 10, 64); err == nil {
+					xlsxreader.line = number
+				}
+			case "c":
+				value.Reset()
+				column = len(record)
+				if reference := XMLAttr(element, "r"); reference != "" {
+					column = XLSXColumn(reference)
+				}
+				celltype = XMLAttr(element, "t")
+				cellstyle, _ = strconv.Atoi(XMLAttr(element, "s"))
+			case "v", "t":
+				text = true
+			}
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "v", "t":
+				text = false
+			case "c":
+				cell := value.String()
+				switch celltype {
+				case "s":
+					index, err := strconv.Atoi(cell)
+					if err != nil || index < 0 || index >= len(xlsxreader.strings) {
+						reason = "Wrong shared string index " + cell
+						break
+					}
+					cell = xlsxreader.strings[index]
+				case "b":
+					cell = strconv.FormatBool(cell == "1")
+				case "", "n":
+					if xlsxreader.dates[cellstyle] {
+						cell = XLSXDate(cell)
+					}
+				}
+				for len(record) <= column {
+					record = append(record, "")
+				}
+				record[column] = cell
+			case "row":
+				if reason != "" {
+					return nil, &ImportRowError{Line: xlsxreader.line, Reason: reason}
+				}
+				if !IsEmptyRecord(record) {
+					return record, nil
+				}
+			}
+		case xml.CharData:
+			if text {
+				value.Write(element)
+			}
+		}
+	}
+}
+
+func (xlsxreader *XLSXReader) Line() int64 {
+	return xlsxreader.line
+}
+
+func (xlsxreader *XLSXReader) Progress() byte {
+	return xlsxreader.counter.Progress()
+}
+
+func (xlsxreader *XLSXReader) Header() bool {
+	return false
+}
+
+func (xlsxreader *XLSXReader) Close() (err error) {
+	xlsxreader.sheet.Close()
+	return xlsxreader.archive.Close()
+}
+
+// Чтение первого листа таблицы OpenDocument
+type ODSReader struct {
+	archive  *zip.ReadCloser
+	content  io.ReadCloser
+	counter  *CountingReader
+	decoder  *xml.Decoder
+	line     int64
+	repeated []string
+	repeats  int
+	finished bool
+}
+
+func NewODSReader(fullpath string) (odsreader *ODSReader, err error) {
+	archive, err := zip.OpenReader(fullpath)
+	if err != nil {
+		log.Error("Can't open ods file %v with value %v", err, fullpath)
+		return nil, err
+	}
+	entry := FindZipEntry(archive, "content.xml")
+	if entry == nil {
+		archive.Close()
+		log.Error("Can't find content in ods file %v", fullpath)
+		return nil, errors.New("Content not found")
+	}
+	content, err := entry.Open()
+	if err != nil {
+		archive.Close()
+		log.Error("Can't read ods file %v with value %v", err, fullpath)
+		return nil, err
+	}
+	counter := &CountingReader{Reader: content, Total: int64(entry.UncompressedSize64)}
+
+	return &ODSReader{archive: archive, content: content, counter: counter, decoder: xml.NewDecoder(counter)}, nil
+}
+
+// Значение ячейки по типу значения или по тексту ячейки
+func ODSValue(element xml.StartElement) (value string, typed bool) {
+	switch XMLAttr(element, "value-type") {
+	case "float", "percentage", "currency":
+		return XMLAttr(element, "value"), true
+	case "date":
+		return strings.TrimSuffix(XMLAttr(element, "date-value"), "T00:00:00"), true
+	case "boolean":
+		return XMLAttr(element, "boolean-value"), true
+	}
+	return "", false
+}
+
+func (odsreader *ODSReader) Read() (record []string, err error) {
+	if odsreader.repeats > 0 {
+		odsreader.repeats--
+		odsreader.line++
+		return append([]string{}, odsreader.repeated...), nil
+	}
+	if odsreader.finished {
+		return nil, io.EOF
+	}
+
+	var value bytes.Buffer
+	typedvalue, typed := "", false
+	cellrepeat, rowrepeat := 1, 1
+	empty := 0
+	paragraphs := 0
+	text := false
+	for {
+		token, err := odsreader.decoder.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch element := token.(type) {
+		case xml.StartElement:
+			switch element.Name.Local {
+			case "table-row":
+				record = []string{}
+				empty = 0
+				rowrepeat, _ = strconv.Atoi(XMLAttr(element, "number-rows-repeated"))
+				if rowrepeat < 1 {
+					rowrepeat = 1
+				}
+			case "table-cell", "covered-table-cell":
+				value.Reset()
+				paragraphs = 0
+				typedvalue, typed = ODSValue(element)
+				cellrepeat, _ = strconv.Atoi(XMLAttr(element, "number-columns-repeated"))
+				if cellrepeat < 1 {
+					cellrepeat = 1
+				}
+			case "p":
+				if paragraphs > 0 {
+					value.WriteString("\n")
+				}
+				paragraphs++
+				text = true
+			case "s":
+				count, _ := strconv.Atoi(XMLAttr(element, "c"))
+				if count < 1 {
+					count = 1
+				}
+				value.WriteString(strings.Repeat(" ", count))
+			case "tab":
+				value.WriteString("\t")
+			case "line-break":
+				value.WriteString("\n")
+			}
+		case xml.EndElement:
+			switch element.Name.Local {
+			case "p":
+				text = false
+			case "table-cell", "covered-table-cell":
+				cell := value.String()
+				if typed {
+					cell = typedvalue
+				}
+				// Пустые ячейки добавляются только перед непустыми, чтобы не разворачивать повторы до конца листа
+				if cell == "" {
+					empty += cellrepeat
+					continue
+				}
+				for ; empty > 0; empty-- {
+					record = append(record, "")
+				}
+				for i := 0; i < cellrepeat; i++ {
+					record = append(record, cell)
+				}
+			case "table-row":
+				odsreader.line++
+				if IsEmptyRecord(record) {
+					odsreader.line += int64(rowrepeat - 1)
+					continue
+				}
+				if rowrepeat > 1 {
+					odsreader.repeated = record
+					odsreader.repeats = rowrepeat - 1
+				}
+				return record, nil
+			case "table":
+				odsreader.finished = true
+				return nil, io.EOF
+			}
+		case xml.CharData:
+			if text {
+				value.Write(element)
+			}
+		}
+	}
+}
+
+func (odsreader *ODSReader) Line() int64 {
+	return odsreader.line
+}
+
+func (odsreader *ODSReader) Progress() byte {
+	return odsreader.counter.Progress()
+}
+
+func (odsreader *ODSReader) Header() bool {
+	return false
+}
+
+func (odsreader *ODSReader) Close() (err error) {
+	odsreader.content.Close()
+	return odsreader.archive.Close()
+}
@@ -21,3 +21,13 @@ func CheckMobilePhone(value string, dtocolumntype *models.DtoColumnType, columnt
 
 	return mobilephone, nil
 }
+
+// Проверка и приведение номера мобильного телефона, введенного вне пользовательской таблицы
+func NormalizeMobilePhone(value string, columntyperepository services.ColumnTypeRepository) (mobilephone uint64, err error) {
+	dtocolumntype, err := columntyperepository.Get(models.COLUMN_TYPE_MOBILE_PHONE)
+	if err != nil {
+		return 0, err
+	}
+
+	return CheckMobilePhone(value, dtocolumntype, columntyperepository)
+}
@@ -514,6 +514,12 @@ func UpdateSMSOrder(dtoorder *models.DtoOrder, viewsmsfacility models.ViewSMSFac
 				return nil, err
 			}
 		}
+		if viewsmsfacility.MessageBodyInColumnId == 0 {
+			err = CheckSMSTemplate(viewsmsfacility.DeliveryDataId, viewsmsfacility.MessageBody, r, tablecolumnrepository, language)
+			if err != nil {
+				return nil, err
+			}
+		}
 		dtosmsfacility.MessageBodyInColumnId = viewsmsfacility.MessageBodyInColumnId
 		dtosmsfacility.TimeCorrection = viewsmsfacility.TimeCorrection
 	}
@@ -1266,3 +1272,21 @@ func UpdateHeaderOrder(dtoorder *models.DtoOrder, viewheaderfacility models.View
 		dtoheaderfacility.Name, dtoheaderfacility.Begin.Format(models.FORMAT_DATE), dtoheaderfacility.End.Format(models.FORMAT_DATE),
 		dtoheaderfacility.AutoRenew, dtoheaderfacility.Cost, dtoheaderfacility.CostFactual), nil
 }
+
+func GetOrderRouting(dtoorderrouting *models.DtoOrderRouting, r render.Render, orderrouterepository services.OrderRouteRepository,
+	language string) (apiorderrouting *models.ApiOrderRouting, err error) {
+	dtoorderroutes, err := orderrouterepository.GetByOrder(dtoorderrouting.Order_ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+	apiorderroutes := []models.ApiOrderRoute{}
+	for _, dtoorderroute := range *dtoorderroutes {
+		apiorderroutes = append(apiorderroutes, *models.NewApiOrderRoute(dtoorderroute.Supplier_ID, dtoorderroute.Mobile_Operator_ID,
+			dtoorderroute.Attempt, dtoorderroute.Status.String(), dtoorderroute.Recipients, dtoorderroute.Sent, dtoorderroute.Failed,
+			dtoorderroute.Error, dtoorderroute.Created))
+	}
+
+	return models.NewApiOrderRouting(dtoorderrouting.Policy.String(), apiorderroutes), nil
+}
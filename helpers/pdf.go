@@ -0,0 +1,421 @@
+package helpers
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"fmt"
+	"github.com/jung-kurt/gofpdf"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	PDF_RENDERER_NATIVE = "native" // Формирование документов средствами сервера
+	PDF_RENDERER_DOCKER = "docker" // Преобразование html в контейнере wkhtmltopdf
+
+	PDF_FONT_FAMILY    = "document"
+	PDF_FONT_DIRECTORY = "/usr/share/fonts/truetype/dejavu"
+	PDF_FONT_REGULAR   = "DejaVuSans.ttf"
+	PDF_FONT_BOLD      = "DejaVuSans-Bold.ttf"
+	PDF_FORMAT_DATE    = "02.01.2006"
+	PDF_LINE_HEIGHT    = 5
+)
+
+// Способ формирования документов в формате pdf
+func PDFRenderer() string {
+	if config.Configuration.Documents.Renderer == PDF_RENDERER_DOCKER {
+		return PDF_RENDERER_DOCKER
+	}
+	return PDF_RENDERER_NATIVE
+}
+
+// Создание документа формата A4 со встроенными шрифтами с поддержкой кириллицы
+func NewPDF() (pdf *gofpdf.Fpdf, err error) {
+	directory := config.Configuration.Documents.FontDirectory
+	if directory == "" {
+		directory = PDF_FONT_DIRECTORY
+	}
+	regular := config.Configuration.Documents.FontRegular
+	if regular == "" {
+		regular = PDF_FONT_REGULAR
+	}
+	bold := config.Configuration.Documents.FontBold
+	if bold == "" {
+		bold = PDF_FONT_BOLD
+	}
+	for _, font := range []string{regular, bold} {
+		_, err = os.Stat(filepath.Join(directory, font))
+		if err != nil {
+			log.Error("Can't find font file %v in directory %v", font, directory)
+			return nil, err
+		}
+	}
+
+	pdf = gofpdf.New("P", "mm", "A4", directory)
+	pdf.SetCreator(config.Configuration.Server.PublicAddress, true)
+	pdf.AddUTF8Font(PDF_FONT_FAMILY, "", regular)
+	pdf.AddUTF8Font(PDF_FONT_FAMILY, "B", bold)
+	pdf.SetMargins(15, 15, 15)
+	pdf.SetAutoPageBreak(true, 15)
+	if pdf.Err() {
+		log.Error("Can't load fonts for pdf document %v", pdf.Error())
+		return nil, pdf.Error()
+	}
+
+	return pdf, nil
+}
+
+// Формирование файла pdf с сохранением результата в состоянии экспорта файла
+func ExportPDF(absfilepath string, file *models.DtoFile, filerepository services.FileRepository, draw func(pdf *gofpdf.Fpdf)) {
+	pdf, err := NewPDF()
+	if err == nil {
+		draw(pdf)
+		err = pdf.OutputFileAndClose(filepath.Join(absfilepath, file.Path, fmt.Sprintf("%08d", file.ID)))
+	}
+	if err == nil {
+		file.Export_Ready = true
+		file.Export_Percentage = 100
+	} else {
+		log.Error("Can't render pdf document %v for file %v", err, file.ID)
+		file.Export_Error = true
+		file.Export_ErrorDescription = err.Error()
+	}
+
+	err = filerepository.Update(file)
+	if err != nil {
+		return
+	}
+}
+
+func ExportInvoicePDF(absfilepath string, file *models.DtoFile, filerepository services.FileRepository,
+	dtoinvoicetemplate *models.DtoInvoiceTemplate) {
+	ExportPDF(absfilepath, file, filerepository, func(pdf *gofpdf.Fpdf) { RenderInvoicePDF(pdf, dtoinvoicetemplate) })
+}
+
+func ExportContractPDF(absfilepath string, file *models.DtoFile, filerepository services.FileRepository,
+	dtocontracttemplate *models.DtoContractTemplate) {
+	ExportPDF(absfilepath, file, filerepository, func(pdf *gofpdf.Fpdf) { RenderContractPDF(pdf, dtocontracttemplate) })
+}
+
+func ExportMatchingPDF(absfilepath string, file *models.DtoFile, filerepository services.FileRepository,
+	dtomatchingtemplate *models.DtoMatchingTemplate) {
+	ExportPDF(absfilepath, file, filerepository, func(pdf *gofpdf.Fpdf) { RenderMatchingPDF(pdf, dtomatchingtemplate) })
+}
+
+// Денежная сумма с разделением разрядов пробелами
+func FormatMoney(value float64) string {
+	cents := int64(math.Abs(value)*100 + 0.5)
+	digits := fmt.Sprintf("%d", cents/100)
+	groups := []string{}
+	for len(digits) > 3 {
+		groups = append([]string{digits[len(digits)-3:]}, groups...)
+		digits = digits[:len(digits)-3]
+	}
+	groups = append([]string{digits}, groups...)
+	sign := ""
+	if value < 0 && cents != 0 {
+		sign = "-"
+	}
+
+	return fmt.Sprintf("%v%v,%02d", sign, strings.Join(groups, " "), cents%100)
+}
+
+// Ставка НДС по сумме НДС, включенной в итоговую сумму
+func VATRate(total float64, vat float64) float64 {
+	if vat <= 0 || total <= vat {
+		return 0
+	}
+	return math.Floor(vat/(total-vat)*100 + 0.5)
+}
+
+// Ширина области печати страницы
+func PDFWidth(pdf *gofpdf.Fpdf) float64 {
+	width, _ := pdf.GetPageSize()
+	left, _, right, _ := pdf.GetMargins()
+	return width - left - right
+}
+
+func PDFTitle(pdf *gofpdf.Fpdf, title string, subtitle string) {
+	pdf.SetFont(PDF_FONT_FAMILY, "B", 13)
+	pdf.MultiCell(0, 7, title, "", "C", false)
+	if subtitle != "" {
+		pdf.SetFont(PDF_FONT_FAMILY, "", 10)
+		pdf.MultiCell(0, PDF_LINE_HEIGHT, subtitle, "", "C", false)
+	}
+	pdf.Ln(4)
+}
+
+// Вывод строки реквизитов вида "Название: значение"
+func PDFField(pdf *gofpdf.Fpdf, name string, value string) {
+	pdf.SetFont(PDF_FONT_FAMILY, "B", 9)
+	pdf.CellFormat(35, PDF_LINE_HEIGHT, name, "", 0, "L", false, 0, "")
+	pdf.SetFont(PDF_FONT_FAMILY, "", 9)
+	pdf.MultiCell(0, PDF_LINE_HEIGHT, value, "", "L", false)
+}
+
+// Вывод реквизитов компании
+func PDFCompany(pdf *gofpdf.Fpdf, name string, company *models.DtoCompanyTemplate) {
+	value := company.Name + ", ИНН " + company.INN
+	if company.KPP != "" {
+		value += ", КПП " + company.KPP
+	}
+	value += ", " + company.Address
+	PDFField(pdf, name, value)
+}
+
+// Вывод реквизитов банка для платежа
+func PDFBank(pdf *gofpdf.Fpdf, bank *models.ViewApiCompanyBank, seller *models.DtoCompanyTemplate) {
+	width := PDFWidth(pdf)
+	pdf.SetFont(PDF_FONT_FAMILY, "", 9)
+	pdf.CellFormat(width*0.6, PDF_LINE_HEIGHT, bank.Name, "LTR", 0, "L", false, 0, "")
+	pdf.CellFormat(width*0.1, PDF_LINE_HEIGHT, "БИК", "LTR", 0, "L", false, 0, "")
+	pdf.CellFormat(width*0.3, PDF_LINE_HEIGHT, bank.Bik, "LTR", 1, "L", false, 0, "")
+	pdf.CellFormat(width*0.6, PDF_LINE_HEIGHT, "Банк получателя", "LBR", 0, "L", false, 0, "")
+	pdf.CellFormat(width*0.1, PDF_LINE_HEIGHT, "Сч. №", "LBR", 0, "L", false, 0, "")
+	pdf.CellFormat(width*0.3, PDF_LINE_HEIGHT, bank.CorrespondingAccount, "LBR", 1, "L", false, 0, "")
+	pdf.CellFormat(width*0.3, PDF_LINE_HEIGHT, "ИНН "+seller.INN, "1", 0, "L", false, 0, "")
+	pdf.CellFormat(width*0.3, PDF_LINE_HEIGHT, "КПП "+seller.KPP, "1", 0, "L", false, 0, "")
+	pdf.CellFormat(width*0.1, PDF_LINE_HEIGHT, "Сч. №", "LTR", 0, "L", false, 0, "")
+	pdf.CellFormat(width*0.3, PDF_LINE_HEIGHT, bank.CheckingAccount, "LTR", 1, "L", false, 0, "")
+	pdf.CellFormat(width*0.6, PDF_LINE_HEIGHT, seller.Name, "LTR", 0, "L", false, 0, "")
+	pdf.CellFormat(width*0.1, PDF_LINE_HEIGHT, "", "LR", 0, "L", false, 0, "")
+	pdf.CellFormat(width*0.3, PDF_LINE_HEIGHT, "", "LR", 1, "L", false, 0, "")
+	pdf.CellFormat(width*0.6, PDF_LINE_HEIGHT, "Получатель", "LBR", 0, "L", false, 0, "")
+	pdf.CellFormat(width*0.1, PDF_LINE_HEIGHT, "", "LBR", 0, "L", false, 0, "")
+	pdf.CellFormat(width*0.3, PDF_LINE_HEIGHT, "", "LBR", 1, "L", false, 0, "")
+	pdf.Ln(6)
+}
+
+// Вывод строки таблицы с заданными ширинами и выравниванием колонок
+func PDFRow(pdf *gofpdf.Fpdf, widths []float64, aligns string, values []string, bold bool) {
+	style := ""
+	if bold {
+		style = "B"
+	}
+	pdf.SetFont(PDF_FONT_FAMILY, style, 8)
+	lines := 1
+	for i := range values {
+		count := len(pdf.SplitText(values[i], widths[i]-2))
+		if count > lines {
+			lines = count
+		}
+	}
+	height := float64(lines) * 4
+	_, pageheight := pdf.GetPageSize()
+	_, _, _, bottom := pdf.GetMargins()
+	if pdf.GetY()+height > pageheight-bottom {
+		pdf.AddPage()
+	}
+	x, y := pdf.GetXY()
+	for i := range values {
+		pdf.Rect(x, y, widths[i], height, "D")
+		pdf.SetXY(x, y)
+		pdf.MultiCell(widths[i], 4, values[i], "", string(aligns[i]), false)
+		x += widths[i]
+	}
+	left, _, _, _ := pdf.GetMargins()
+	pdf.SetXY(left, y+height)
+}
+
+// Вывод подписей руководителя и главного бухгалтера
+func PDFSignatures(pdf *gofpdf.Fpdf, company *models.DtoCompanyTemplate) {
+	pdf.Ln(8)
+	pdf.SetFont(PDF_FONT_FAMILY, "", 9)
+	pdf.CellFormat(45, PDF_LINE_HEIGHT, "Руководитель", "", 0, "L", false, 0, "")
+	pdf.CellFormat(40, PDF_LINE_HEIGHT, "", "B", 0, "L", false, 0, "")
+	pdf.CellFormat(0, PDF_LINE_HEIGHT, company.CEO, "", 1, "L", false, 0, "")
+	pdf.Ln(4)
+	pdf.CellFormat(45, PDF_LINE_HEIGHT, "Главный бухгалтер", "", 0, "L", false, 0, "")
+	pdf.CellFormat(40, PDF_LINE_HEIGHT, "", "B", 0, "L", false, 0, "")
+	pdf.CellFormat(0, PDF_LINE_HEIGHT, company.Accountant, "", 1, "L", false, 0, "")
+}
+
+// Счет на оплату
+func RenderInvoicePDF(pdf *gofpdf.Fpdf, dtoinvoicetemplate *models.DtoInvoiceTemplate) {
+	invoice := &dtoinvoicetemplate.Invoice
+	title := fmt.Sprintf("Счет на оплату № %v от %v", invoice.ID, invoice.Created.Format(PDF_FORMAT_DATE))
+	pdf.SetTitle(title, true)
+	pdf.AddPage()
+
+	PDFBank(pdf, &dtoinvoicetemplate.Bank, &dtoinvoicetemplate.Seller)
+	PDFTitle(pdf, title, "")
+	PDFCompany(pdf, "Поставщик:", &dtoinvoicetemplate.Seller)
+	PDFCompany(pdf, "Покупатель:", &dtoinvoicetemplate.Buyer)
+	if dtoinvoicetemplate.Contract.Name != "" {
+		PDFField(pdf, "Основание:", dtoinvoicetemplate.Contract.Name)
+	}
+	pdf.Ln(4)
+
+	width := PDFWidth(pdf)
+	widths := []float64{10, width - 100, 20, 15, 25, 30}
+	PDFRow(pdf, widths, "CCCCCC", []string{"№", "Товары (работы, услуги)", "Кол-во", "Ед.", "Цена", "Сумма"}, true)
+	for i, item := range invoice.InvoiceItems {
+		PDFRow(pdf, widths, "CLRCRR", []string{fmt.Sprintf("%v", i+1), item.Name, fmt.Sprintf("%v", item.Amount), item.Measure,
+			FormatMoney(item.Price), FormatMoney(item.Total)}, false)
+	}
+
+	pdf.Ln(2)
+	vat := "Без НДС:"
+	if rate := VATRate(invoice.Total, invoice.VAT); rate != 0 {
+		vat = fmt.Sprintf("В том числе НДС (%v%%):", rate)
+	}
+	for _, line := range [][]string{{"Итого:", FormatMoney(invoice.Total)}, {vat, FormatMoney(invoice.VAT)},
+		{"Всего к оплате:", FormatMoney(invoice.Total)}} {
+		pdf.SetFont(PDF_FONT_FAMILY, "B", 9)
+		pdf.CellFormat(width-30, PDF_LINE_HEIGHT, line[0], "", 0, "R", false, 0, "")
+		pdf.CellFormat(30, PDF_LINE_HEIGHT, line[1], "", 1, "R", false, 0, "")
+	}
+	pdf.Ln(2)
+	pdf.SetFont(PDF_FONT_FAMILY, "", 9)
+	pdf.MultiCell(0, PDF_LINE_HEIGHT, fmt.Sprintf("Всего наименований %v, на сумму %v руб.", len(invoice.InvoiceItems),
+		FormatMoney(invoice.Total)), "", "L", false)
+
+	PDFSignatures(pdf, &dtoinvoicetemplate.Seller)
+}
+
+// Договор с реквизитами сторон
+func RenderContractPDF(pdf *gofpdf.Fpdf, dtocontracttemplate *models.DtoContractTemplate) {
+	contract := &dtocontracttemplate.Contract
+	title := "Договор " + contract.Name
+	date := contract.Created
+	if contract.Signed {
+		date = contract.SignedDate
+	}
+	pdf.SetTitle(title, true)
+	pdf.AddPage()
+
+	PDFTitle(pdf, title, "от "+date.Format(PDF_FORMAT_DATE))
+	pdf.SetFont(PDF_FONT_FAMILY, "", 10)
+	pdf.MultiCell(0, PDF_LINE_HEIGHT, fmt.Sprintf("%v, именуемое в дальнейшем «Исполнитель», в лице %v, с одной стороны, и %v, "+
+		"именуемое в дальнейшем «Заказчик», в лице %v, с другой стороны, заключили настоящий договор.",
+		dtocontracttemplate.Seller.Name, dtocontracttemplate.Seller.CEO, dtocontracttemplate.Buyer.Name, dtocontracttemplate.Buyer.CEO),
+		"", "J", false)
+	pdf.Ln(4)
+
+	if len(dtocontracttemplate.Appendices) != 0 {
+		pdf.SetFont(PDF_FONT_FAMILY, "B", 10)
+		pdf.MultiCell(0, PDF_LINE_HEIGHT, "Приложения к договору", "", "L", false)
+		pdf.SetFont(PDF_FONT_FAMILY, "", 10)
+		for i, appendix := range dtocontracttemplate.Appendices {
+			pdf.MultiCell(0, PDF_LINE_HEIGHT, fmt.Sprintf("%v. %v от %v", i+1, appendix.Name, appendix.SignedDate.Format(PDF_FORMAT_DATE)),
+				"", "L", false)
+		}
+		pdf.Ln(4)
+	}
+
+	pdf.SetFont(PDF_FONT_FAMILY, "B", 10)
+	pdf.MultiCell(0, PDF_LINE_HEIGHT, "Реквизиты сторон", "", "L", false)
+	pdf.Ln(2)
+	PDFCompany(pdf, "Исполнитель:", &dtocontracttemplate.Seller)
+	bank := &dtocontracttemplate.Bank
+	PDFField(pdf, "Банк:", fmt.Sprintf("%v, БИК %v, к/с %v, р/с %v", bank.Name, bank.Bik, bank.CorrespondingAccount, bank.CheckingAccount))
+	pdf.Ln(2)
+	PDFCompany(pdf, "Заказчик:", &dtocontracttemplate.Buyer)
+
+	y := pdf.GetY() + 12
+	left, _, _, _ := pdf.GetMargins()
+	half := PDFWidth(pdf) / 2
+	for i, company := range []*models.DtoCompanyTemplate{&dtocontracttemplate.Seller, &dtocontracttemplate.Buyer} {
+		pdf.SetXY(left+half*float64(i), y)
+		pdf.SetFont(PDF_FONT_FAMILY, "", 9)
+		pdf.CellFormat(half-10, PDF_LINE_HEIGHT, company.Name, "", 2, "L", false, 0, "")
+		pdf.CellFormat(half-10, PDF_LINE_HEIGHT*2, "", "B", 2, "L", false, 0, "")
+		pdf.CellFormat(half-10, PDF_LINE_HEIGHT, company.CEO, "", 2, "L", false, 0, "")
+	}
+}
+
+// Сальдо акта сверки на конец периода
+func MatchingBalance(dtomatchingtemplate *models.DtoMatchingTemplate) (debit float64, credit float64, balance float64) {
+	for _, line := range dtomatchingtemplate.Lines {
+		debit += line.Debit
+		credit += line.Credit
+	}
+	return debit, credit, dtomatchingtemplate.Opening + debit - credit
+}
+
+// Акт сверки взаимных расчетов
+func RenderMatchingPDF(pdf *gofpdf.Fpdf, dtomatchingtemplate *models.DtoMatchingTemplate) {
+	title := "Акт сверки взаимных расчетов"
+	pdf.SetTitle(title, true)
+	pdf.AddPage()
+
+	PDFTitle(pdf, title, fmt.Sprintf("за период с %v по %v\nмежду %v и %v", dtomatchingtemplate.Begin.Format(PDF_FORMAT_DATE),
+		dtomatchingtemplate.End.Format(PDF_FORMAT_DATE), dtomatchingtemplate.Seller.Name, dtomatchingtemplate.Buyer.Name))
+
+	width := PDFWidth(pdf)
+	widths := []float64{25, width - 85, 30, 30}
+	PDFRow(pdf, widths, "CCCC", []string{"Дата", "Документ", "Дебет", "Кредит"}, true)
+	opening := []string{"", "Сальдо на " + dtomatchingtemplate.Begin.Format(PDF_FORMAT_DATE), "", ""}
+	if dtomatchingtemplate.Opening >= 0 {
+		opening[2] = FormatMoney(dtomatchingtemplate.Opening)
+	} else {
+		opening[3] = FormatMoney(-dtomatchingtemplate.Opening)
+	}
+	PDFRow(pdf, widths, "CLRR", opening, true)
+	for _, line := range dtomatchingtemplate.Lines {
+		debit, credit := "", ""
+		if line.Debit != 0 {
+			debit = FormatMoney(line.Debit)
+		}
+		if line.Credit != 0 {
+			credit = FormatMoney(line.Credit)
+		}
+		PDFRow(pdf, widths, "CLRR", []string{line.Date.Format(PDF_FORMAT_DATE), line.Name, debit, credit}, false)
+	}
+	debit, credit, balance := MatchingBalance(dtomatchingtemplate)
+	PDFRow(pdf, widths, "CLRR", []string{"", "Обороты за период", FormatMoney(debit), FormatMoney(credit)}, true)
+	closing := []string{"", "Сальдо на " + dtomatchingtemplate.End.Format(PDF_FORMAT_DATE), "", ""}
+	if balance >= 0 {
+		closing[2] = FormatMoney(balance)
+	} else {
+		closing[3] = FormatMoney(-balance)
+	}
+	PDFRow(pdf, widths, "CLRR", closing, true)
+
+	pdf.Ln(4)
+	pdf.SetFont(PDF_FONT_FAMILY, "", 9)
+	summary := fmt.Sprintf("На %v задолженность отсутствует.", dtomatchingtemplate.End.Format(PDF_FORMAT_DATE))
+	if balance > 0 {
+		summary = fmt.Sprintf("На %v задолженность в пользу %v составляет %v руб.", dtomatchingtemplate.End.Format(PDF_FORMAT_DATE),
+			dtomatchingtemplate.Seller.Name, FormatMoney(balance))
+	} else if balance < 0 {
+		summary = fmt.Sprintf("На %v задолженность в пользу %v составляет %v руб.", dtomatchingtemplate.End.Format(PDF_FORMAT_DATE),
+			dtomatchingtemplate.Buyer.Name, FormatMoney(-balance))
+	}
+	pdf.MultiCell(0, PDF_LINE_HEIGHT, summary, "", "L", false)
+
+	PDFSignatures(pdf, &dtomatchingtemplate.Seller)
+}
+
+// Формирование строк акта сверки по счетам компании: счета начисляются при выставлении и погашаются при оплате
+func MatchingLines(invoices *[]models.DtoInvoice, begin time.Time, end time.Time) (opening float64, lines []models.DtoMatchingLine) {
+	lines = []models.DtoMatchingLine{}
+	for _, invoice := range *invoices {
+		if !invoice.Active {
+			continue
+		}
+		if invoice.Created.Before(begin) {
+			opening += invoice.Total
+		} else if !invoice.Created.After(end) {
+			lines = append(lines, models.DtoMatchingLine{Date: invoice.Created,
+				Name: fmt.Sprintf("Счет № %v от %v", invoice.ID, invoice.Created.Format(PDF_FORMAT_DATE)), Debit: invoice.Total})
+		}
+		if !invoice.Paid {
+			continue
+		}
+		if invoice.PaidAt.Before(begin) {
+			opening -= invoice.Total
+		} else if !invoice.PaidAt.After(end) {
+			lines = append(lines, models.DtoMatchingLine{Date: invoice.PaidAt,
+				Name: fmt.Sprintf("Оплата счета № %v", invoice.ID), Credit: invoice.Total})
+		}
+	}
+	sort.SliceStable(lines, func(i, j int) bool { return lines[i].Date.Before(lines[j].Date) })
+
+	return opening, lines
+}
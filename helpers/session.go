@@ -0,0 +1,65 @@
+package helpers
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"errors"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"types"
+)
+
+const (
+	PARAM_NAME_SESSION_ID = "sessionId"
+	SESSION_FIELD_MAX     = 255
+)
+
+// Заполнение сведений о месте входа по запросу, создающему сессию
+func SetSessionMetadata(session *models.DtoSession, request *http.Request, device string) {
+	host, err := GetClientIP(request)
+	if err != nil {
+		log.Error("Can't detect ip address %v from %v", err, request.RemoteAddr)
+	}
+	session.IP_Address = host
+	session.User_Agent = limitSessionField(request.UserAgent())
+	session.Device = limitSessionField(device)
+}
+
+// Сессия из списка действующих сессий пользователя по идентификатору в параметрах запроса
+func CheckUserSession(r render.Render, params martini.Params, sessionrepository services.SessionRepository,
+	session *models.DtoSession) (dtosession *models.DtoSession, err error) {
+	hash := params[PARAM_NAME_SESSION_ID]
+	if len(hash) > PARAM_LENGTH_MAX {
+		log.Error("Session id parameter is too long %v", len(hash))
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[session.Language].Errors.Api.Data_Wrong})
+		return nil, errors.New("Wrong parameter")
+	}
+
+	dtosessions, err := sessionrepository.GetByUser(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+	for i := range *dtosessions {
+		if (*dtosessions)[i].Hash == hash {
+			return &(*dtosessions)[i], nil
+		}
+	}
+
+	log.Error("Session %v doesn't belong to user %v", hash, session.UserID)
+	r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+		Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+	return nil, errors.New("Session unknown")
+}
+
+func limitSessionField(value string) string {
+	runes := []rune(value)
+	if len(runes) > SESSION_FIELD_MAX {
+		return string(runes[:SESSION_FIELD_MAX])
+	}
+	return value
+}
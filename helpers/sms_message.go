@@ -0,0 +1,155 @@
+package helpers
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"errors"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"regexp"
+	"strings"
+	"types"
+	"unicode/utf16"
+)
+
+const (
+	PARAM_QUERY_SAMPLES = "samples"
+
+	SMS_PREVIEW_SAMPLES     = 5
+	SMS_PREVIEW_MAX_SAMPLES = 100
+
+	SMS_GSM7_SINGLE_LENGTH = 160
+	SMS_GSM7_PART_LENGTH   = 153
+	SMS_UCS2_SINGLE_LENGTH = 70
+	SMS_UCS2_PART_LENGTH   = 67
+
+	// Основной алфавит GSM 03.38 без символа перехода в таблицу расширения
+	SMS_GSM7_BASIC = "@£$¥èéùìòÇ\nØø\rÅåΔ_ΦΓΛΩΠΨΣΘΞÆæßÉ !\"#¤%&'()*+,-./0123456789:;<=>?" +
+		"¡ABCDEFGHIJKLMNOPQRSTUVWXYZÄÖÑÜ§¿abcdefghijklmnopqrstuvwxyzäöñüà"
+	// Таблица расширения GSM 03.38, символы которой занимают два септета
+	SMS_GSM7_EXTENSION = "\f^{}\\[~]|€"
+)
+
+var (
+	smsPlaceholder   = regexp.MustCompile(`\{\{\s*([^{}]*?)\s*\}\}`)
+	smsGSM7Basic     = make(map[rune]bool)
+	smsGSM7Extension = make(map[rune]bool)
+)
+
+func init() {
+	for _, current_rune := range SMS_GSM7_BASIC {
+		smsGSM7Basic[current_rune] = true
+	}
+	for _, current_rune := range SMS_GSM7_EXTENSION {
+		smsGSM7Extension[current_rune] = true
+	}
+}
+
+// Определение кодировки, длины в символах кодировки и количества частей sms сообщения. Части составного
+// сообщения не разрывают символы таблицы расширения GSM-7 и суррогатные пары UCS-2
+func CalculateSMSSegments(message string) (encoding string, length int, segments int) {
+	sizes := []int{}
+	encoding = models.SMS_ENCODING_GSM7
+	for _, current_rune := range message {
+		if smsGSM7Basic[current_rune] {
+			sizes = append(sizes, 1)
+		} else if smsGSM7Extension[current_rune] {
+			sizes = append(sizes, 2)
+		} else {
+			encoding = models.SMS_ENCODING_UCS2
+			break
+		}
+	}
+	single, part := SMS_GSM7_SINGLE_LENGTH, SMS_GSM7_PART_LENGTH
+	if encoding == models.SMS_ENCODING_UCS2 {
+		sizes = []int{}
+		for _, current_rune := range message {
+			sizes = append(sizes, len(utf16.Encode([]rune{current_rune})))
+		}
+		single, part = SMS_UCS2_SINGLE_LENGTH, SMS_UCS2_PART_LENGTH
+	}
+
+	for _, size := range sizes {
+		length += size
+	}
+	if length <= single {
+		return encoding, length, 1
+	}
+	used := 0
+	segments = 1
+	for _, size := range sizes {
+		if used+size > part {
+			segments++
+			used = 0
+		}
+		used += size
+	}
+
+	return encoding, length, segments
+}
+
+// Названия колонок таблицы, указанные в тексте рассылки в виде {{Название}}, без повторов
+func GetSMSPlaceholders(body string) (names []string) {
+	names = []string{}
+	found := make(map[string]bool)
+	for _, match := range smsPlaceholder.FindAllStringSubmatch(body, -1) {
+		if !found[match[1]] {
+			found[match[1]] = true
+			names = append(names, match[1])
+		}
+	}
+
+	return names
+}
+
+// Подстановка значений колонок строки таблицы в текст рассылки, ключ значения - название колонки
+func RenderSMSMessage(body string, values map[string]string) (message string) {
+	return smsPlaceholder.ReplaceAllStringFunc(body, func(placeholder string) string {
+		return values[smsPlaceholder.FindStringSubmatch(placeholder)[1]]
+	})
+}
+
+// Колонки таблицы, соответствующие подстановкам текста рассылки без учета регистра названий
+func GetSMSTemplateColumns(body string, tablecolumns *[]models.DtoTableColumn) (templatecolumns map[string]models.DtoTableColumn,
+	missed []string) {
+	templatecolumns = make(map[string]models.DtoTableColumn)
+	missed = []string{}
+	for _, name := range GetSMSPlaceholders(body) {
+		found := false
+		for _, tablecolumn := range *tablecolumns {
+			if strings.EqualFold(strings.TrimSpace(tablecolumn.Name), name) {
+				templatecolumns[name] = tablecolumn
+				found = true
+				break
+			}
+		}
+		if !found {
+			missed = append(missed, name)
+		}
+	}
+
+	return templatecolumns, missed
+}
+
+func CheckSMSTemplate(table_id int64, body string, r render.Render, tablecolumnrepository services.TableColumnRepository,
+	language string) (err error) {
+	if len(GetSMSPlaceholders(body)) == 0 {
+		return nil
+	}
+	tablecolumns, err := tablecolumnrepository.GetByTable(table_id)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return err
+	}
+	_, missed := GetSMSTemplateColumns(body, tablecolumns)
+	if len(missed) != 0 {
+		log.Error("Can't find columns %v of message template in table %v", missed, table_id)
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return errors.New("Wrong message template")
+	}
+
+	return nil
+}
@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"application/models"
+	"strings"
+	"testing"
+)
+
+func TestCalculateSMSSegments(t *testing.T) {
+	var cases = []struct {
+		message  string
+		encoding string
+		length   int
+		segments int
+	}{
+		{"", models.SMS_ENCODING_GSM7, 0, 1},
+		{"Hello", models.SMS_ENCODING_GSM7, 5, 1},
+		{strings.Repeat("a", 160), models.SMS_ENCODING_GSM7, 160, 1},
+		{strings.Repeat("a", 161), models.SMS_ENCODING_GSM7, 161, 2},
+		{strings.Repeat("a", 306), models.SMS_ENCODING_GSM7, 306, 2},
+		{strings.Repeat("a", 307), models.SMS_ENCODING_GSM7, 307, 3},
+		{"€", models.SMS_ENCODING_GSM7, 2, 1},
+		{strings.Repeat("a", 80) + strings.Repeat("€", 40), models.SMS_ENCODING_GSM7, 160, 1},
+		{strings.Repeat("a", 152) + "€" + strings.Repeat("a", 152), models.SMS_ENCODING_GSM7, 306, 3},
+		{"Привет", models.SMS_ENCODING_UCS2, 6, 1},
+		{strings.Repeat("я", 70), models.SMS_ENCODING_UCS2, 70, 1},
+		{strings.Repeat("я", 71), models.SMS_ENCODING_UCS2, 71, 2},
+		{"😀", models.SMS_ENCODING_UCS2, 2, 1},
+		{strings.Repeat("я", 66) + "😀" + strings.Repeat("я", 66), models.SMS_ENCODING_UCS2, 134, 3},
+	}
+
+	for _, c := range cases {
+		encoding, length, segments := CalculateSMSSegments(c.message)
+		if encoding != c.encoding {
+			t.Error("Encoding of", c.message, "is not properly detected", encoding)
+		}
+		if length != c.length {
+			t.Error("Length of", c.message, "is not properly calculated", length)
+		}
+		if segments != c.segments {
+			t.Error("Segments of", c.message, "are not properly calculated", segments)
+		}
+	}
+}
+
+func TestGetSMSPlaceholders(t *testing.T) {
+	names := GetSMSPlaceholders("{{Name}}, {{ Date }} {{Name}} {Other}")
+	if len(names) != 2 || names[0] != "Name" || names[1] != "Date" {
+		t.Error("Placeholders are not properly found", names)
+	}
+}
+
+func TestRenderSMSMessage(t *testing.T) {
+	var cases = []struct {
+		body    string
+		values  map[string]string
+		message string
+	}{
+		{"Hello", map[string]string{"Name": "Ivan"}, "Hello"},
+		{"Hello, {{Name}}!", map[string]string{"Name": "Ivan"}, "Hello, Ivan!"},
+		{"{{ Name }} {{Name}}", map[string]string{"Name": "Ivan"}, "Ivan Ivan"},
+		{"Hello, {{Missing}}!", map[string]string{"Name": "Ivan"}, "Hello, !"},
+		{"{Name} {{Name}", map[string]string{"Name": "Ivan"}, "{Name} {{Name}"},
+	}
+
+	for _, c := range cases {
+		if message := RenderSMSMessage(c.body, c.values); message != c.message {
+			t.Error("Message", c.body, "is not properly rendered", message)
+		}
+	}
+}
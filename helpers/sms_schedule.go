@@ -0,0 +1,187 @@
+package helpers
+
+import (
+	"application/models"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	SMS_SCHEDULE_HORIZON_DAYS = 400
+
+	TIMEZONE_OFFSET_MIN      = -12 * 60
+	TIMEZONE_OFFSET_MAX      = 14 * 60
+	TIMEZONE_OFFSET_MSK      = 3 * 60
+	TIMEZONE_OFFSET_EASTMOST = 12 * 60
+)
+
+// Проверка соответствия дня рассылки периоду. Точкой отсчета недельных и месячных периодов служит дата начала рассылки
+func MatchSMSPeriod(period_id int, day time.Time, anchor time.Time) bool {
+	switch period_id {
+	case models.PERIOD_DAILY:
+		return true
+	case models.PERIOD_WEEKLY:
+		return day.Weekday() == anchor.Weekday()
+	case models.PERIOD_MONTHLY:
+		last := time.Date(day.Year(), day.Month()+1, 0, 0, 0, 0, 0, day.Location()).Day()
+		if anchor.Day() > last {
+			return day.Day() == last
+		}
+		return day.Day() == anchor.Day()
+	}
+
+	return false
+}
+
+// Ближайшее время рассылки, не ранее указанного. Время рассылки в течение дня задается базовым временем,
+// дни рассылки определяются типом рассылки, периодами и интервалом рассылки. Если рассылок больше не будет, то возвращается false
+func NextSMSWindow(dtosmsfacility *models.DtoSMSFacility, after time.Time) (next time.Time, ok bool) {
+	after = after.Local()
+	hour, min, _ := dtosmsfacility.DeliveryBaseTime.Local().Clock()
+	anchor := dtosmsfacility.DeliveryBaseTime.Local()
+	if dtosmsfacility.DeliveryTime && !dtosmsfacility.DeliveryTimeStart.IsZero() {
+		anchor = dtosmsfacility.DeliveryTimeStart.Local()
+	}
+
+	for i := 0; i <= SMS_SCHEDULE_HORIZON_DAYS; i++ {
+		next = time.Date(after.Year(), after.Month(), after.Day()+i, hour, min, 0, 0, after.Location())
+		if next.Before(after) {
+			continue
+		}
+		if dtosmsfacility.DeliveryTime {
+			if !dtosmsfacility.DeliveryTimeEnd.IsZero() && !next.Before(dtosmsfacility.DeliveryTimeEnd) {
+				return time.Time{}, false
+			}
+			if !dtosmsfacility.DeliveryTimeStart.IsZero() && next.Before(dtosmsfacility.DeliveryTimeStart) {
+				continue
+			}
+		}
+		if dtosmsfacility.DeliveryType != models.TYPE_DELIVERY_SCHEDULED || len(dtosmsfacility.Periods) == 0 {
+			return next, true
+		}
+		for _, period := range dtosmsfacility.Periods {
+			if MatchSMSPeriod(period.Period_ID, next, anchor) {
+				return next, true
+			}
+		}
+	}
+
+	return time.Time{}, false
+}
+
+// Рассылка по дням рождения
+func IsBirthdayDelivery(dtosmsfacility *models.DtoSMSFacility) bool {
+	if dtosmsfacility.DeliveryType != models.TYPE_DELIVERY_EVENTTRIGGERED {
+		return false
+	}
+	for _, event := range dtosmsfacility.Events {
+		if event.Event_ID == models.EVENT_BIRTHDAY {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Проверка совпадения дня рождения с днем рассылки. Родившиеся 29 февраля в невисокосный год поздравляются 28 февраля
+func IsBirthday(value string, day time.Time) bool {
+	birthday, err := models.ParseDate(value)
+	if err != nil {
+		return false
+	}
+	day = day.Local()
+	if birthday.Month() == day.Month() && birthday.Day() == day.Day() {
+		return true
+	}
+	if birthday.Month() == time.February && birthday.Day() == 29 && day.Month() == time.February && day.Day() == 28 {
+		return time.Date(day.Year(), time.February, 29, 0, 0, 0, 0, day.Location()).Month() != time.February
+	}
+
+	return false
+}
+
+// Разбор часового пояса в форматах UTC+3, GMT+03:00, MSK+4, +5:30. Для нескольких поясов через / берется первый.
+// Возвращается смещение от UTC в минутах
+func ParseTimezone(value string) (offset int, ok bool) {
+	value = strings.ToUpper(strings.TrimSpace(value))
+	if i := strings.IndexAny(value, "/,;"); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	base := 0
+	prefixed := false
+	switch {
+	case strings.HasPrefix(value, "UTC"), strings.HasPrefix(value, "GMT"):
+		value = value[3:]
+		prefixed = true
+	case strings.HasPrefix(value, "MSK"):
+		value = value[3:]
+		base = TIMEZONE_OFFSET_MSK
+		prefixed = true
+	}
+	if value == "" {
+		return base, prefixed
+	}
+
+	sign := 1
+	switch value[0] {
+	case '+':
+		value = value[1:]
+	case '-':
+		sign = -1
+		value = value[1:]
+	}
+	parts := strings.SplitN(value, ":", 2)
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, false
+	}
+	minutes := 0
+	if len(parts) == 2 {
+		minutes, err = strconv.Atoi(parts[1])
+		if err != nil || minutes < 0 || minutes >= 60 {
+			return 0, false
+		}
+	}
+	offset = base + sign*(hours*60+minutes)
+	if offset < TIMEZONE_OFFSET_MIN || offset > TIMEZONE_OFFSET_MAX {
+		return 0, false
+	}
+
+	return offset, true
+}
+
+func FormatTimezone(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	if offset%60 != 0 {
+		return fmt.Sprintf("UTC%v%v:%02d", sign, offset/60, offset%60)
+	}
+
+	return fmt.Sprintf("UTC%v%v", sign, offset/60)
+}
+
+// Время отправки в часовом поясе получателя: базовое время рассылки в день окна рассылки
+func LocalSMSTime(dtosmsfacility *models.DtoSMSFacility, window time.Time, offset int) time.Time {
+	hour, min, _ := dtosmsfacility.DeliveryBaseTime.Local().Clock()
+	year, month, day := window.Local().Date()
+
+	return time.Date(year, month, day, hour, min, 0, 0, time.FixedZone(FormatTimezone(offset), offset*60))
+}
+
+// Упреждение начала рассылки с коррекцией времени: получатели восточных часовых поясов получают сообщения раньше окна рассылки
+func SMSWindowLead(dtosmsfacility *models.DtoSMSFacility, window time.Time) time.Duration {
+	if !dtosmsfacility.TimeCorrection {
+		return 0
+	}
+	lead := window.Sub(LocalSMSTime(dtosmsfacility, window, TIMEZONE_OFFSET_EASTMOST))
+	if lead < 0 {
+		return 0
+	}
+
+	return lead
+}
@@ -0,0 +1,84 @@
+package helpers
+
+import (
+	"application/models"
+	"testing"
+	"time"
+)
+
+func TestNextSMSWindow(t *testing.T) {
+	var base = time.Date(2026, time.January, 1, 10, 0, 0, 0, time.Local)
+	var cases = []struct {
+		name     string
+		facility models.DtoSMSFacility
+		after    time.Time
+		next     time.Time
+		ok       bool
+	}{
+		{"same day", models.DtoSMSFacility{DeliveryType: models.TYPE_DELIVERY_ONCE, DeliveryBaseTime: base},
+			time.Date(2026, time.March, 10, 9, 0, 0, 0, time.Local), time.Date(2026, time.March, 10, 10, 0, 0, 0, time.Local), true},
+		{"next day", models.DtoSMSFacility{DeliveryType: models.TYPE_DELIVERY_ONCE, DeliveryBaseTime: base},
+			time.Date(2026, time.March, 10, 11, 0, 0, 0, time.Local), time.Date(2026, time.March, 11, 10, 0, 0, 0, time.Local), true},
+		{"start", models.DtoSMSFacility{DeliveryType: models.TYPE_DELIVERY_ONCE, DeliveryBaseTime: base, DeliveryTime: true,
+			DeliveryTimeStart: time.Date(2026, time.March, 15, 0, 0, 0, 0, time.Local)},
+			time.Date(2026, time.March, 10, 9, 0, 0, 0, time.Local), time.Date(2026, time.March, 15, 10, 0, 0, 0, time.Local), true},
+		{"end", models.DtoSMSFacility{DeliveryType: models.TYPE_DELIVERY_ONCE, DeliveryBaseTime: base, DeliveryTime: true,
+			DeliveryTimeEnd: time.Date(2026, time.March, 10, 10, 0, 0, 0, time.Local)},
+			time.Date(2026, time.March, 10, 9, 0, 0, 0, time.Local), time.Time{}, false},
+		{"daily", models.DtoSMSFacility{DeliveryType: models.TYPE_DELIVERY_SCHEDULED, DeliveryBaseTime: base,
+			Periods: []models.DtoSMSPeriod{{Period_ID: models.PERIOD_DAILY}}},
+			time.Date(2026, time.March, 10, 9, 0, 0, 0, time.Local), time.Date(2026, time.March, 10, 10, 0, 0, 0, time.Local), true},
+		{"weekly", models.DtoSMSFacility{DeliveryType: models.TYPE_DELIVERY_SCHEDULED, DeliveryBaseTime: base,
+			Periods: []models.DtoSMSPeriod{{Period_ID: models.PERIOD_WEEKLY}}},
+			time.Date(2026, time.March, 10, 9, 0, 0, 0, time.Local), time.Date(2026, time.March, 12, 10, 0, 0, 0, time.Local), true},
+		{"monthly", models.DtoSMSFacility{DeliveryType: models.TYPE_DELIVERY_SCHEDULED, DeliveryBaseTime: base, DeliveryTime: true,
+			DeliveryTimeStart: time.Date(2026, time.January, 31, 0, 0, 0, 0, time.Local),
+			Periods:           []models.DtoSMSPeriod{{Period_ID: models.PERIOD_MONTHLY}}},
+			time.Date(2026, time.February, 1, 9, 0, 0, 0, time.Local), time.Date(2026, time.February, 28, 10, 0, 0, 0, time.Local), true},
+	}
+
+	for _, c := range cases {
+		next, ok := NextSMSWindow(&c.facility, c.after)
+		if ok != c.ok || !next.Equal(c.next) {
+			t.Error("Window", c.name, "is not properly calculated", next, ok)
+		}
+	}
+}
+
+func TestParseTimezone(t *testing.T) {
+	var cases = []struct {
+		value  string
+		offset int
+		ok     bool
+	}{
+		{"UTC+3", 180, true},
+		{"utc-5", -300, true},
+		{"GMT+03:00", 180, true},
+		{"MSK+4", 420, true},
+		{"MSK", 180, true},
+		{"UTC", 0, true},
+		{"+5:30", 330, true},
+		{"3", 180, true},
+		{"UTC+3/UTC+4", 180, true},
+		{"UTC+15", 0, false},
+		{"UTC+3:60", 0, false},
+		{"Europe", 0, false},
+		{"", 0, false},
+	}
+
+	for _, c := range cases {
+		offset, ok := ParseTimezone(c.value)
+		if ok != c.ok || offset != c.offset {
+			t.Error("Timezone", c.value, "is not properly parsed", offset, ok)
+		}
+	}
+}
+
+func TestFormatTimezone(t *testing.T) {
+	if value := FormatTimezone(180); value != "UTC+3" {
+		t.Error("Timezone is not properly formatted", value)
+	}
+	if value := FormatTimezone(-330); value != "UTC-5:30" {
+		t.Error("Timezone is not properly formatted", value)
+	}
+}
@@ -0,0 +1,145 @@
+package helpers
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"errors"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"time"
+	"types"
+)
+
+const (
+	PARAM_NAME_SUPPRESSION_ID = "sid"
+)
+
+func GetSuppressions(unit_id int64, w http.ResponseWriter, r render.Render, suppressionrepository services.SuppressionRepository,
+	language string) {
+	dtosuppressions, err := suppressionrepository.GetByUnit(unit_id)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return
+	}
+	apisuppressions := []models.ApiSuppression{}
+	for _, dtosuppression := range *dtosuppressions {
+		apisuppressions = append(apisuppressions, *models.NewApiSuppression(dtosuppression.ID, dtosuppression.MobilePhone,
+			dtosuppression.Reason, dtosuppression.Source.String(), dtosuppression.Created))
+	}
+
+	RenderJSONArray(apisuppressions, len(apisuppressions), w, r)
+}
+
+func CreateSuppression(unit_id int64, viewsuppression *models.ViewSuppression, r render.Render,
+	columntyperepository services.ColumnTypeRepository, suppressionrepository services.SuppressionRepository,
+	language string) (apisuppression *models.ApiSuppression, err error) {
+	mobilephone, err := NormalizeMobilePhone(viewsuppression.MobilePhone, columntyperepository)
+	if err != nil {
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return nil, err
+	}
+
+	dtosuppression := models.NewDtoSuppression(0, unit_id, mobilephone, viewsuppression.Reason, models.SUPPRESSION_SOURCE_MANUAL, time.Now())
+	err = suppressionrepository.Create(dtosuppression)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+
+	return models.NewApiSuppression(dtosuppression.ID, dtosuppression.MobilePhone, dtosuppression.Reason,
+		dtosuppression.Source.String(), dtosuppression.Created), nil
+}
+
+// Добавление в список запрета рассылок номеров из колонки пользовательской таблицы, загруженной через импорт
+func ImportSuppressions(unit_id int64, viewimportsuppressions *models.ViewImportSuppressions, r render.Render,
+	customertablerepository services.CustomerTableRepository, columntyperepository services.ColumnTypeRepository,
+	tablecolumnrepository services.TableColumnRepository, tablerowrepository services.TableRowRepository,
+	suppressionrepository services.SuppressionRepository, checkaccess bool, userid int64,
+	language string) (apisuppressionimport *models.ApiSuppressionImport, err error) {
+	_, err = IsTableAvailable(r, customertablerepository, viewimportsuppressions.Table_ID, language)
+	if err != nil {
+		return nil, err
+	}
+	if checkaccess {
+		err = IsTableAccessible(viewimportsuppressions.Table_ID, userid, r, customertablerepository, language)
+		if err != nil {
+			return nil, err
+		}
+	}
+	dtotablecolumn, err := CheckColumnValidity(viewimportsuppressions.Table_ID, viewimportsuppressions.Column_ID, r,
+		columntyperepository, tablecolumnrepository, language)
+	if err != nil {
+		return nil, err
+	}
+	dtocolumntype, err := columntyperepository.Get(models.COLUMN_TYPE_MOBILE_PHONE)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+
+	apitablerows, err := tablerowrepository.GetAll("", "", viewimportsuppressions.Table_ID, &[]models.DtoTableColumn{*dtotablecolumn})
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+	rejected := 0
+	dtosuppressions := []models.DtoSuppression{}
+	for _, apitablerow := range *apitablerows {
+		found := false
+		for _, apitablecell := range apitablerow.Cells {
+			if apitablecell.Table_Column_ID != dtotablecolumn.ID {
+				continue
+			}
+			mobilephone, err := CheckMobilePhone(apitablecell.Value, dtocolumntype, columntyperepository)
+			if err != nil {
+				break
+			}
+			dtosuppressions = append(dtosuppressions, *models.NewDtoSuppression(0, unit_id, mobilephone, viewimportsuppressions.Reason,
+				models.SUPPRESSION_SOURCE_IMPORT, time.Now()))
+			found = true
+		}
+		if !found {
+			rejected++
+		}
+	}
+
+	added, err := suppressionrepository.CreateAll(&dtosuppressions)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+
+	return models.NewApiSuppressionImport(added, rejected), nil
+}
+
+func DeleteSuppression(unit_id int64, suppression_id int64, r render.Render, suppressionrepository services.SuppressionRepository,
+	language string) (err error) {
+	dtosuppression, err := suppressionrepository.Get(suppression_id)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return err
+	}
+	if dtosuppression.Unit_ID != unit_id {
+		log.Error("Suppression %v doesn't belong to unit %v", suppression_id, unit_id)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return errors.New("Wrong suppression unit")
+	}
+
+	err = suppressionrepository.Delete(suppression_id)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,556 @@
+package helpers
+
+import (
+	"application/config"
+	"application/models"
+	"application/numbering"
+	"application/services"
+	"errors"
+	"fmt"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"strings"
+	"time"
+	"types"
+	"unicode/utf8"
+)
+
+const (
+	PARAM_NAME_TABLE_DEDUP_ID = "did"
+
+	TABLE_DEDUP_GROUPS_LIMIT = 100
+)
+
+// Колонка, по которой сравниваются строки при поиске дубликатов
+type DedupColumn struct {
+	Column   models.DtoTableColumn // Колонка таблицы
+	Fuzzy    bool                  // Нечеткое сравнение
+	Distance int                   // Допустимое расстояние Левенштейна
+	Phone    bool                  // Значения сравниваются как телефонные номера
+}
+
+// Группа похожих строк: значения первой строки, с которыми сравниваются остальные строки
+type dedupCluster struct {
+	values []string
+	rows   []models.DtoTableDedupRow
+}
+
+// Группы похожих строк. Строка сравнивается только с первыми строками групп своего блока: блок задается значениями
+// колонок точного сравнения и первыми символами колонок нечеткого сравнения
+type dedupIndex struct {
+	columns  []DedupColumn
+	blocks   map[string][]*dedupCluster
+	clusters []*dedupCluster
+}
+
+func newDedupIndex(columns []DedupColumn) *dedupIndex {
+	return &dedupIndex{
+		columns:  columns,
+		blocks:   make(map[string][]*dedupCluster),
+		clusters: []*dedupCluster{},
+	}
+}
+
+// Добавление строки в первую подходящую группу или в новую группу
+func (dedupindex *dedupIndex) Add(values []string, row models.DtoTableDedupRow) {
+	keys := []string{}
+	for i, column := range dedupindex.columns {
+		if column.Fuzzy {
+			first, _ := utf8.DecodeRuneInString(values[i])
+			keys = append(keys, string(first))
+		} else {
+			keys = append(keys, values[i])
+		}
+	}
+	key := strings.Join(keys, "\x00")
+	for _, cluster := range dedupindex.blocks[key] {
+		if IsDuplicate(dedupindex.columns, cluster.values, values) {
+			cluster.rows = append(cluster.rows, row)
+			return
+		}
+	}
+	cluster := &dedupCluster{values: values, rows: []models.DtoTableDedupRow{row}}
+	dedupindex.blocks[key] = append(dedupindex.blocks[key], cluster)
+	dedupindex.clusters = append(dedupindex.clusters, cluster)
+}
+
+// Строки групп дубликатов с номерами групп в порядке появления. Группы из одной строки пропускаются
+func (dedupindex *dedupIndex) Groups() (rows []models.DtoTableDedupRow, groups int64, duplicates int64) {
+	rows = []models.DtoTableDedupRow{}
+	for _, cluster := range dedupindex.clusters {
+		if len(cluster.rows) < 2 {
+			continue
+		}
+		groups++
+		duplicates += int64(len(cluster.rows) - 1)
+		for _, row := range cluster.rows {
+			row.Group_ID = groups
+			rows = append(rows, row)
+		}
+	}
+
+	return rows, groups, duplicates
+}
+
+// Приведение значения к виду для сравнения: номера телефонов к формату E.164, остальные значения
+// к нижнему регистру с единичными пробелами
+func NormalizeDedupValue(value string, phone bool) string {
+	if phone {
+		return numbering.Normalize(value)
+	}
+	return strings.Replace(strings.ToLower(strings.Join(strings.Fields(value), " ")), "ё", "е", -1)
+}
+
+// Расстояние Левенштейна между строками в символах
+func Levenshtein(first string, second string) int {
+	a := []rune(first)
+	b := []rune(second)
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = previous[j-1] + cost
+			if previous[j]+1 < current[j] {
+				current[j] = previous[j] + 1
+			}
+			if current[j-1]+1 < current[j] {
+				current[j] = current[j-1] + 1
+			}
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+// Строки считаются дубликатами, если совпадают все колонки сравнения. Пустое значение похоже только на пустое
+func IsDuplicate(columns []DedupColumn, first []string, second []string) bool {
+	for i, column := range columns {
+		if first[i] == second[i] {
+			continue
+		}
+		if !column.Fuzzy || first[i] == "" || second[i] == "" || Levenshtein(first[i], second[i]) > column.Distance {
+			return false
+		}
+	}
+	return true
+}
+
+func GetApiTableDedup(dtotablededup *models.DtoTableDedup) *models.ApiTableDedup {
+	return models.NewApiTableDedup(dtotablededup.ID, dtotablededup.Step, dtotablededup.Ready, dtotablededup.Percentage,
+		dtotablededup.Groups, dtotablededup.Duplicates, dtotablededup.Error, dtotablededup.ErrorDescription, dtotablededup.Created,
+		dtotablededup.Completed)
+}
+
+func SaveTableDedupError(description string, dtotablededup *models.DtoTableDedup, tablededuprepository services.TableDedupRepository) {
+	dtotablededup.Error = true
+	dtotablededup.ErrorDescription = description
+	dtotablededup.Completed = time.Now()
+	err := tablededuprepository.Update(dtotablededup)
+	if err != nil {
+		log.Error("Can't save error information %v for table dedup %v", err, dtotablededup.ID)
+		return
+	}
+}
+
+// Проверка принадлежности поиска дубликатов таблице
+func CheckTableDedup(tableid int64, dedupid int64, r render.Render, tablededuprepository services.TableDedupRepository,
+	language string) (dtotablededup *models.DtoTableDedup, err error) {
+	dtotablededup, err = tablededuprepository.Get(dedupid)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+	if dtotablededup.Customer_Table_ID != tableid {
+		log.Error("Table dedup %v doesn't belong to table %v", dedupid, tableid)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, errors.New("Wrong table dedup table")
+	}
+
+	return dtotablededup, nil
+}
+
+// Проверка принадлежности колонок таблице, колонки возвращаются по идентификатору
+func CheckDedupColumns(tableid int64, columnids []int64, r render.Render, tablecolumnrepository services.TableColumnRepository,
+	language string) (tablecolumns map[int64]models.DtoTableColumn, err error) {
+	dtotablecolumns, err := tablecolumnrepository.GetByTable(tableid)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+	available := make(map[int64]models.DtoTableColumn)
+	for _, dtotablecolumn := range *dtotablecolumns {
+		available[dtotablecolumn.ID] = dtotablecolumn
+	}
+	tablecolumns = make(map[int64]models.DtoTableColumn)
+	for _, columnid := range columnids {
+		dtotablecolumn, ok := available[columnid]
+		if !ok {
+			log.Error("Column %v doesn't belong to table %v", columnid, tableid)
+			r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+				Message: config.Localization[language].Errors.Api.Object_NotExist})
+			return nil, errors.New("Wrong column table")
+		}
+		tablecolumns[columnid] = dtotablecolumn
+	}
+
+	return tablecolumns, nil
+}
+
+// Запуск поиска дубликатов в таблице
+func CreateTableDedup(tableid int64, viewtablededup *models.ViewTableDedup, r render.Render,
+	tablecolumnrepository services.TableColumnRepository, tablerowrepository services.TableRowRepository,
+	tablededuprepository services.TableDedupRepository, language string) (apitablededup *models.ApiTableDedup, err error) {
+	columnids := []int64{}
+	for _, viewdedupcolumn := range viewtablededup.Columns {
+		if viewdedupcolumn.Distance > models.TABLE_DEDUP_MAX_DISTANCE {
+			log.Error("Levenshtein distance %v is too big for column %v", viewdedupcolumn.Distance, viewdedupcolumn.Table_Column_ID)
+			r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+				Message: config.Localization[language].Errors.Api.Data_Wrong})
+			return nil, errors.New("Wrong distance")
+		}
+		columnids = append(columnids, viewdedupcolumn.Table_Column_ID)
+	}
+	tablecolumns, err := CheckDedupColumns(tableid, columnids, r, tablecolumnrepository, language)
+	if err != nil {
+		return nil, err
+	}
+	columns := []DedupColumn{}
+	for _, viewdedupcolumn := range viewtablededup.Columns {
+		tablecolumn := tablecolumns[viewdedupcolumn.Table_Column_ID]
+		columns = append(columns, DedupColumn{
+			Column:   tablecolumn,
+			Fuzzy:    viewdedupcolumn.Fuzzy,
+			Distance: viewdedupcolumn.Distance,
+			Phone: tablecolumn.Column_Type_ID == models.COLUMN_TYPE_MOBILE_PHONE ||
+				tablecolumn.Column_Type_ID == models.COLUMN_TYPE_SOURCE_PHONE,
+		})
+	}
+
+	dtotablededup := models.NewDtoTableDedup(0, tableid, models.TABLE_DEDUP_STEP_SEARCH, false, 0, time.Now(), time.Time{})
+	err = tablededuprepository.Create(dtotablededup)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return nil, err
+	}
+
+	apitablededup = GetApiTableDedup(dtotablededup)
+	go FindDuplicates(dtotablededup, columns, tablerowrepository, tablededuprepository, language)
+
+	return apitablededup, nil
+}
+
+// Поиск групп дубликатов. Строки с совпадающими значениями колонок точного сравнения и первыми символами
+// колонок нечеткого сравнения сравниваются с первыми строками найденных групп
+func FindDuplicates(dtotablededup *models.DtoTableDedup, columns []DedupColumn, tablerowrepository services.TableRowRepository,
+	tablededuprepository services.TableDedupRepository, language string) {
+	total, err := tablerowrepository.GetDefaultPosition(dtotablededup.Customer_Table_ID)
+	if err != nil {
+		SaveTableDedupError(config.Localization[language].Errors.Internal.Data_Reading, dtotablededup, tablededuprepository)
+		return
+	}
+	tablecolumns := new([]models.DtoTableColumn)
+	for _, column := range columns {
+		*tablecolumns = append(*tablecolumns, column.Column)
+	}
+
+	dedupindex := newDedupIndex(columns)
+	var offset int64 = 0
+	var count int64 = BLOCK_ROWS_NUMBER
+	log.Info("Start searching duplicates %v in table %v", time.Now(), dtotablededup.Customer_Table_ID)
+	for {
+		dtotablerows, err := tablerowrepository.GetValidation(offset, count, dtotablededup.Customer_Table_ID, tablecolumns)
+		if err != nil {
+			SaveTableDedupError(config.Localization[language].Errors.Internal.Data_Reading, dtotablededup, tablededuprepository)
+			return
+		}
+		if len(*dtotablerows) == 0 {
+			break
+		}
+		for i := range *dtotablerows {
+			values := []string{}
+			empty := true
+			for _, column := range columns {
+				tablecell, err := (&(*dtotablerows)[i]).TableRowToDtoTableCell(&column.Column)
+				if err != nil {
+					SaveTableDedupError(config.Localization[language].Errors.Internal.Data_Reading, dtotablededup, tablededuprepository)
+					return
+				}
+				value := NormalizeDedupValue(tablecell.Value, column.Phone)
+				empty = empty && value == ""
+				values = append(values, value)
+			}
+			if empty {
+				continue
+			}
+			dedupindex.Add(values, *models.NewDtoTableDedupRow(dtotablededup.ID, 0, (*dtotablerows)[i].ID, offset+int64(i)))
+		}
+		offset += count
+		if total > 0 && offset < total {
+			dtotablededup.Percentage = byte(offset * 90 / total)
+			_ = tablededuprepository.Update(dtotablededup)
+		}
+	}
+
+	rows, groups, duplicates := dedupindex.Groups()
+	dtotablededup.Groups = groups
+	dtotablededup.Duplicates = duplicates
+	err = tablededuprepository.CreateRows(&rows)
+	if err != nil {
+		SaveTableDedupError(config.Localization[language].Errors.Internal.Data_Reading, dtotablededup, tablededuprepository)
+		return
+	}
+	log.Info("Stop searching duplicates %v in table %v", time.Now(), dtotablededup.Customer_Table_ID)
+
+	dtotablededup.Ready = true
+	dtotablededup.Percentage = 100
+	dtotablededup.Completed = time.Now()
+	_ = tablededuprepository.Update(dtotablededup)
+}
+
+// Первые группы дубликатов со строками таблицы
+func GetTableDedupGroups(dtotablededup *models.DtoTableDedup, w http.ResponseWriter, r render.Render,
+	tablecolumnrepository services.TableColumnRepository, tablerowrepository services.TableRowRepository,
+	tablededuprepository services.TableDedupRepository, language string) {
+	if !dtotablededup.Ready || dtotablededup.Error || dtotablededup.Step != models.TABLE_DEDUP_STEP_SEARCH {
+		log.Error("Table dedup %v is not ready", dtotablededup.ID)
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return
+	}
+	dtotablededuprows, err := tablededuprepository.GetRows(dtotablededup.ID, TABLE_DEDUP_GROUPS_LIMIT)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return
+	}
+	apidedupgroups := []models.ApiDedupGroup{}
+	if len(*dtotablededuprows) == 0 {
+		RenderJSONArray(apidedupgroups, len(apidedupgroups), w, r)
+		return
+	}
+	tablecolumns, err := tablecolumnrepository.GetByTable(dtotablededup.Customer_Table_ID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return
+	}
+	ids := []string{}
+	for _, dtotablededuprow := range *dtotablededuprows {
+		ids = append(ids, fmt.Sprintf("%v", dtotablededuprow.Table_Row_ID))
+	}
+	apitablerows, err := tablerowrepository.GetAll(" id in ("+strings.Join(ids, ", ")+") and", "",
+		dtotablededup.Customer_Table_ID, tablecolumns)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return
+	}
+	rows := make(map[int64]models.ApiInfoTableRow)
+	for _, apitablerow := range *apitablerows {
+		rows[apitablerow.ID] = apitablerow
+	}
+
+	for _, dtotablededuprow := range *dtotablededuprows {
+		apitablerow, ok := rows[dtotablededuprow.Table_Row_ID]
+		if !ok {
+			continue
+		}
+		if len(apidedupgroups) == 0 || apidedupgroups[len(apidedupgroups)-1].Group != dtotablededuprow.Group_ID {
+			apidedupgroups = append(apidedupgroups, *models.NewApiDedupGroup(dtotablededuprow.Group_ID, []models.ApiInfoTableRow{}))
+		}
+		group := &apidedupgroups[len(apidedupgroups)-1]
+		group.Rows = append(group.Rows, apitablerow)
+	}
+
+	RenderJSONArray(apidedupgroups, len(apidedupgroups), w, r)
+}
+
+// Запуск объединения найденных групп дубликатов
+func MergeTableDedup(dtotablededup *models.DtoTableDedup, viewtablemerge *models.ViewTableMerge, r render.Render,
+	tablecolumnrepository services.TableColumnRepository, tablerowrepository services.TableRowRepository,
+	tablededuprepository services.TableDedupRepository, language string) (apitablededup *models.ApiTableDedup, err error) {
+	if !dtotablededup.Ready || dtotablededup.Error || dtotablededup.Step != models.TABLE_DEDUP_STEP_SEARCH {
+		log.Error("Table dedup %v can't be merged", dtotablededup.ID)
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return nil, errors.New("Table dedup is not ready")
+	}
+	columnids := []int64{}
+	for _, viewmergerule := range viewtablemerge.Rules {
+		columnids = append(columnids, viewmergerule.Table_Column_ID)
+	}
+	tablecolumns, err := CheckDedupColumns(dtotablededup.Customer_Table_ID, columnids, r, tablecolumnrepository, language)
+	if err != nil {
+		return nil, err
+	}
+
+	dtotablededup.Step = models.TABLE_DEDUP_STEP_MERGE
+	dtotablededup.Ready = false
+	dtotablededup.Percentage = 0
+	err = tablededuprepository.Update(dtotablededup)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return nil, err
+	}
+
+	apitablededup = GetApiTableDedup(dtotablededup)
+	go MergeDuplicates(dtotablededup, viewtablemerge, tablecolumns, tablerowrepository, tablededuprepository, language)
+
+	return apitablededup, nil
+}
+
+// Объединение групп дубликатов: значения колонок переносятся в первую строку группы по правилам,
+// остальные строки группы отключаются
+func MergeDuplicates(dtotablededup *models.DtoTableDedup, viewtablemerge *models.ViewTableMerge,
+	tablecolumns map[int64]models.DtoTableColumn, tablerowrepository services.TableRowRepository,
+	tablededuprepository services.TableDedupRepository, language string) {
+	dtotablededuprows, err := tablededuprepository.GetRows(dtotablededup.ID, 0)
+	if err != nil {
+		SaveTableDedupError(config.Localization[language].Errors.Internal.Data_Reading, dtotablededup, tablededuprepository)
+		return
+	}
+
+	log.Info("Start merging duplicates %v in table %v", time.Now(), dtotablededup.Customer_Table_ID)
+	for begin := 0; begin < len(*dtotablededuprows); {
+		end := begin + 1
+		for end < len(*dtotablededuprows) && (*dtotablededuprows)[end].Group_ID == (*dtotablededuprows)[begin].Group_ID {
+			end++
+		}
+		err = MergeDuplicateGroup((*dtotablededuprows)[begin:end], viewtablemerge, tablecolumns, tablerowrepository)
+		if err != nil {
+			SaveTableDedupError(config.Localization[language].Errors.Internal.Data_Reading, dtotablededup, tablededuprepository)
+			return
+		}
+		begin = end
+		if group := (*dtotablededuprows)[begin-1].Group_ID; group%TABLE_DEDUP_GROUPS_LIMIT == 0 && dtotablededup.Groups > 0 {
+			dtotablededup.Percentage = byte(group * 100 / dtotablededup.Groups)
+			_ = tablededuprepository.Update(dtotablededup)
+		}
+	}
+	log.Info("Stop merging duplicates %v in table %v", time.Now(), dtotablededup.Customer_Table_ID)
+
+	dtotablededup.Ready = true
+	dtotablededup.Percentage = 100
+	dtotablededup.Completed = time.Now()
+	_ = tablededuprepository.Update(dtotablededup)
+}
+
+func MergeDuplicateGroup(dtotablededuprows []models.DtoTableDedupRow, viewtablemerge *models.ViewTableMerge,
+	tablecolumns map[int64]models.DtoTableColumn, tablerowrepository services.TableRowRepository) (err error) {
+	tablerows := []models.DtoTableRow{}
+	for _, dtotablededuprow := range dtotablededuprows {
+		tablerow, err := tablerowrepository.Get(dtotablededuprow.Table_Row_ID)
+		if err != nil {
+			return err
+		}
+		// Строки, удаленные или измененные после поиска, не объединяются
+		if tablerow.Active {
+			tablerows = append(tablerows, *tablerow)
+		}
+	}
+	if len(tablerows) < 2 {
+		return nil
+	}
+
+	if !viewtablemerge.Deactivate && len(viewtablemerge.Rules) != 0 {
+		newtablerow := new(models.DtoTableRow)
+		*newtablerow = tablerows[0]
+		changed := false
+		for _, viewmergerule := range viewtablemerge.Rules {
+			tablecolumn := tablecolumns[viewmergerule.Table_Column_ID]
+			cells := []models.DtoTableCell{}
+			for i := range tablerows {
+				tablecell, err := (&tablerows[i]).TableRowToDtoTableCell(&tablecolumn)
+				if err != nil {
+					return err
+				}
+				cells = append(cells, *tablecell)
+			}
+			tablecell := PickMergeCell(cells, viewmergerule.Rule)
+			if tablecell.Value != cells[0].Value {
+				changed = true
+				err = newtablerow.DtoTableCellToTableRow(&tablecell, &tablecolumn)
+				if err != nil {
+					return err
+				}
+			}
+		}
+		if changed {
+			oldtablerow := new(models.DtoTableRow)
+			*oldtablerow = tablerows[0]
+			newtablerow.Created = time.Now()
+			newtablerow.Edition += 1
+			oldtablerow.ID = 0
+			oldtablerow.Active = false
+			oldtablerow.Original_ID = newtablerow.ID
+			err = tablerowrepository.Update(newtablerow, oldtablerow, false, true)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	// Позиции строк сдвигаются при каждом отключении, поэтому строка перечитывается перед отключением
+	for _, tablerow := range tablerows[1:] {
+		current, err := tablerowrepository.Get(tablerow.ID)
+		if err != nil {
+			return err
+		}
+		err = tablerowrepository.Deactivate(current, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Выбор ячейки группы дубликатов по правилу объединения
+func PickMergeCell(cells []models.DtoTableCell, rule string) models.DtoTableCell {
+	picked := cells[0]
+	switch rule {
+	case models.MERGE_RULE_LAST:
+		picked = cells[len(cells)-1]
+	case models.MERGE_RULE_NONEMPTY:
+		for _, cell := range cells {
+			if strings.TrimSpace(cell.Value) != "" {
+				return cell
+			}
+		}
+	case models.MERGE_RULE_LONGEST:
+		for _, cell := range cells {
+			if utf8.RuneCountInString(cell.Value) > utf8.RuneCountInString(picked.Value) {
+				picked = cell
+			}
+		}
+	case models.MERGE_RULE_FREQUENT:
+		counts := make(map[string]int)
+		best := 0
+		for _, cell := range cells {
+			if strings.TrimSpace(cell.Value) == "" {
+				continue
+			}
+			counts[cell.Value]++
+			if counts[cell.Value] > best {
+				best = counts[cell.Value]
+				picked = cell
+			}
+		}
+	}
+
+	return picked
+}
@@ -0,0 +1,103 @@
+package helpers
+
+import (
+	"application/models"
+	"testing"
+)
+
+func TestLevenshtein(t *testing.T) {
+	var cases = []struct {
+		first    string
+		second   string
+		distance int
+	}{
+		{"", "", 0},
+		{"abc", "", 3},
+		{"", "abc", 3},
+		{"kitten", "sitting", 3},
+		{"flaw", "lawn", 2},
+		{"иванов", "иваново", 1},
+		{"петров", "петрова", 1},
+		{"сидоров", "сидоров", 0},
+		{"ab", "ba", 2},
+	}
+
+	for _, c := range cases {
+		if distance := Levenshtein(c.first, c.second); distance != c.distance {
+			t.Error("Distance between", c.first, "and", c.second, "is not properly calculated", distance)
+		}
+	}
+}
+
+func TestNormalizeDedupValue(t *testing.T) {
+	var cases = []struct {
+		value      string
+		phone      bool
+		normalized string
+	}{
+		{"  Иван   Петрович ", false, "иван петрович"},
+		{"Семёнов", false, "семенов"},
+		{"+7 (916) 123-45-67", false, "+7 (916) 123-45-67"},
+		{"+7 (916) 123-45-67", true, "79161234567"},
+		{"8 916 123 45 67", true, "79161234567"},
+	}
+
+	for _, c := range cases {
+		if normalized := NormalizeDedupValue(c.value, c.phone); normalized != c.normalized {
+			t.Error("Value", c.value, "is not properly normalized", normalized)
+		}
+	}
+}
+
+func TestIsDuplicate(t *testing.T) {
+	var columns = []DedupColumn{{Fuzzy: false}, {Fuzzy: true, Distance: 1}}
+	var cases = []struct {
+		first     []string
+		second    []string
+		duplicate bool
+	}{
+		{[]string{"79161234567", "иванов"}, []string{"79161234567", "иванов"}, true},
+		{[]string{"79161234567", "иванов"}, []string{"79161234567", "иваново"}, true},
+		{[]string{"79161234567", "иванов"}, []string{"79161234567", "ивановой"}, false},
+		{[]string{"79161234567", "иванов"}, []string{"79161234568", "иванов"}, false},
+		{[]string{"79161234567", "и"}, []string{"79161234567", ""}, false},
+		{[]string{"79161234567", ""}, []string{"79161234567", ""}, true},
+	}
+
+	for _, c := range cases {
+		if IsDuplicate(columns, c.first, c.second) != c.duplicate {
+			t.Error("Rows", c.first, "and", c.second, "are not properly compared")
+		}
+	}
+}
+
+func TestDedupIndexGroups(t *testing.T) {
+	var columns = []DedupColumn{{Fuzzy: false, Phone: true}, {Fuzzy: true, Distance: 2}}
+	var values = [][]string{
+		{"79161234567", "иванов иван"},
+		{"79031234567", "петров петр"},
+		{"79161234567", "иванов иван"},
+		{"79161234567", "иваново иван"},
+		{"79031234567", "сидоров петр"},
+		{"79161234567", "жванов иван"},
+		{"79031234567", "петров пётр"},
+	}
+	var groups = map[int64]int64{1: 1, 3: 1, 4: 1, 2: 2, 7: 2}
+
+	dedupindex := newDedupIndex(columns)
+	for i, value := range values {
+		dedupindex.Add(value, *models.NewDtoTableDedupRow(1, 0, int64(i+1), int64(i)))
+	}
+	rows, groupcount, duplicates := dedupindex.Groups()
+	if groupcount != 2 || duplicates != 3 {
+		t.Error("Groups are not properly counted", groupcount, duplicates)
+	}
+	if len(rows) != len(groups) {
+		t.Error("Group rows are not properly collected", rows)
+	}
+	for _, row := range rows {
+		if groups[row.Table_Row_ID] != row.Group_ID {
+			t.Error("Row", row.Table_Row_ID, "is not properly grouped", row.Group_ID)
+		}
+	}
+}
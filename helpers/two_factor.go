@@ -0,0 +1,471 @@
+package helpers
+
+import (
+	"application/config"
+	"application/gateways"
+	"application/models"
+	"application/services"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base32"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/martini-contrib/render"
+	qrcode "github.com/skip2/go-qrcode"
+	libTypes "lib/suppliers/types"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+	"types"
+)
+
+const (
+	TWO_FACTOR_SECRET_LENGTH   = 20
+	TWO_FACTOR_TOTP_PERIOD     = 30
+	TWO_FACTOR_TOTP_DIGITS     = 6
+	TWO_FACTOR_TOTP_SKEW       = 1
+	TWO_FACTOR_SMS_DIGITS      = 6
+	TWO_FACTOR_RECOVERY_CODES  = 10
+	TWO_FACTOR_RECOVERY_LENGTH = 10
+	TWO_FACTOR_MAX_ATTEMPTS    = 5
+	TWO_FACTOR_QRCODE_SIZE     = 256
+	TWO_FACTOR_ISSUER          = "Application"
+	TWO_FACTOR_RESEND_TIMEOUT  = time.Minute
+	TWO_FACTOR_CODE_TIMEOUT    = 5 * time.Minute
+	TWO_FACTOR_TRUST_DURATION  = 30 * 24 * time.Hour
+)
+
+var errTwoFactorPhone = errors.New("Confirmed mobile phone not found")
+
+func TwoFactorCodeTimeout() time.Duration {
+	if config.Configuration.TwoFactor.CodeTimeout > 0 {
+		return config.Configuration.TwoFactor.CodeTimeout
+	}
+	return TWO_FACTOR_CODE_TIMEOUT
+}
+
+func TwoFactorTrustDuration() time.Duration {
+	if config.Configuration.TwoFactor.TrustDuration > 0 {
+		return config.Configuration.TwoFactor.TrustDuration
+	}
+	return TWO_FACTOR_TRUST_DURATION
+}
+
+func TwoFactorIssuer() string {
+	if config.Configuration.TwoFactor.Issuer != "" {
+		return config.Configuration.TwoFactor.Issuer
+	}
+	return TWO_FACTOR_ISSUER
+}
+
+// Секрет одноразовых паролей в кодировке base32 без выравнивания
+func GenerateTOTPSecret() (secret string, err error) {
+	raw := make([]byte, TWO_FACTOR_SECRET_LENGTH)
+	if _, err = rand.Read(raw); err != nil {
+		log.Error("Error during secret generation %v", err)
+		return "", err
+	}
+
+	return strings.TrimRight(base32.StdEncoding.EncodeToString(raw), "="), nil
+}
+
+// Одноразовый пароль по RFC 6238 для указанного шага времени
+func TOTPCode(secret string, counter int64) (code string, err error) {
+	secret = strings.ToUpper(strings.TrimSpace(secret))
+	if padding := len(secret) % 8; padding != 0 {
+		secret += strings.Repeat("=", 8-padding)
+	}
+	key, err := base32.StdEncoding.DecodeString(secret)
+	if err != nil {
+		return "", err
+	}
+	message := make([]byte, 8)
+	binary.BigEndian.PutUint64(message, uint64(counter))
+	mac := hmac.New(sha1.New, key)
+	mac.Write(message)
+	sum := mac.Sum(nil)
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+	modulo := uint32(1)
+	for i := 0; i < TWO_FACTOR_TOTP_DIGITS; i++ {
+		modulo *= 10
+	}
+
+	return fmt.Sprintf("%0*d", TWO_FACTOR_TOTP_DIGITS, value%modulo), nil
+}
+
+// Проверка одноразового пароля с допуском на расхождение часов в один шаг. Возвращает шаг совпавшего пароля
+func CheckTOTPCode(secret string, code string, now time.Time) (counter int64, valid bool) {
+	current := now.Unix() / TWO_FACTOR_TOTP_PERIOD
+	for counter = current - TWO_FACTOR_TOTP_SKEW; counter <= current+TWO_FACTOR_TOTP_SKEW; counter++ {
+		expected, err := TOTPCode(secret, counter)
+		if err != nil {
+			return 0, false
+		}
+		if subtle.ConstantTimeCompare([]byte(expected), []byte(strings.TrimSpace(code))) == 1 {
+			return counter, true
+		}
+	}
+
+	return 0, false
+}
+
+// Адрес otpauth:// для подключения приложения одноразовых паролей
+func TOTPURI(account string, secret string) string {
+	issuer := TwoFactorIssuer()
+	values := url.Values{}
+	values.Set("secret", secret)
+	values.Set("issuer", issuer)
+	values.Set("algorithm", "SHA1")
+	values.Set("digits", strconv.Itoa(TWO_FACTOR_TOTP_DIGITS))
+	values.Set("period", strconv.Itoa(TWO_FACTOR_TOTP_PERIOD))
+
+	return "otpauth://totp/" + url.QueryEscape(issuer+":"+account) + "?" + values.Encode()
+}
+
+// Изображение QR-кода в формате png, base64
+func TOTPQRCode(uri string) (image string, err error) {
+	png, err := qrcode.Encode(uri, qrcode.Medium, TWO_FACTOR_QRCODE_SIZE)
+	if err != nil {
+		log.Error("Error during qr code generation %v", err)
+		return "", err
+	}
+
+	return base64.StdEncoding.EncodeToString(png), nil
+}
+
+func GenerateNumericCode(digits int) (code string, err error) {
+	max := big.NewInt(1)
+	for i := 0; i < digits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+	value, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		log.Error("Error during code generation %v", err)
+		return "", err
+	}
+
+	return fmt.Sprintf("%0*d", digits, value), nil
+}
+
+// Коды восстановления и их хэши для хранения в бд
+func GenerateRecoveryCodes() (codes []string, hashes []string, err error) {
+	for i := 0; i < TWO_FACTOR_RECOVERY_CODES; i++ {
+		raw := make([]byte, TWO_FACTOR_RECOVERY_LENGTH)
+		if _, err = rand.Read(raw); err != nil {
+			log.Error("Error during recovery code generation %v", err)
+			return nil, nil, err
+		}
+		code := strings.ToLower(base32.StdEncoding.EncodeToString(raw)[:TWO_FACTOR_RECOVERY_LENGTH])
+		code = code[:TWO_FACTOR_RECOVERY_LENGTH/2] + "-" + code[TWO_FACTOR_RECOVERY_LENGTH/2:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashTwoFactorCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// Хэш одноразового кода или кода восстановления без учета регистра, пробелов и дефисов
+func HashTwoFactorCode(code string) string {
+	code = strings.Map(func(r rune) rune {
+		if r == '-' || r == ' ' {
+			return -1
+		}
+		return r
+	}, strings.ToLower(code))
+	hash := sha256.Sum256([]byte(code))
+
+	return hex.EncodeToString(hash[:])
+}
+
+// Второй фактор обязателен для уровня доступа пользователя или для его объединения
+func IsTwoFactorRequired(user *models.DtoUser, twofactorrepository services.TwoFactorRepository) (required bool, err error) {
+	for _, role := range user.Roles {
+		for _, required := range config.Configuration.TwoFactor.Roles {
+			if int(role) == required {
+				return true, nil
+			}
+		}
+	}
+
+	return twofactorrepository.IsRequiredByUnit(user.UnitID)
+}
+
+// Основной подтвержденный мобильный телефон пользователя
+func GetTwoFactorPhone(userid int64, mobilephonerepository services.MobilePhoneRepository) (phone string, err error) {
+	mobilephones, err := mobilephonerepository.GetByUser(userid)
+	if err != nil {
+		return "", err
+	}
+	for _, mobilephone := range *mobilephones {
+		if mobilephone.Primary && mobilephone.Confirmed {
+			return mobilephone.Phone, nil
+		}
+	}
+
+	return "", errTwoFactorPhone
+}
+
+// Способы подтверждения входа пользователя и телефон для SMS кодов. Пользователи без подключенного второго фактора,
+// для которых он обязателен, подтверждают вход кодом на основной подтвержденный мобильный телефон
+func GetTwoFactorMethods(user *models.DtoUser, twofactorrepository services.TwoFactorRepository,
+	mobilephonerepository services.MobilePhoneRepository) (methods []string, phone string, err error) {
+	found, err := twofactorrepository.Exists(user.ID)
+	if err != nil {
+		return nil, "", err
+	}
+	if found {
+		dtotwofactor, err := twofactorrepository.Get(user.ID)
+		if err != nil {
+			return nil, "", err
+		}
+		if dtotwofactor.Enabled {
+			return []string{dtotwofactor.Method, models.TWO_FACTOR_METHOD_RECOVERY}, dtotwofactor.Phone, nil
+		}
+	}
+
+	required, err := IsTwoFactorRequired(user, twofactorrepository)
+	if err != nil || !required {
+		return nil, "", err
+	}
+	phone, err = GetTwoFactorPhone(user.ID, mobilephonerepository)
+	if err != nil {
+		log.Error("Two factor is required but there is no confirmed mobile phone for user %v", user.ID)
+		return nil, "", err
+	}
+
+	return []string{models.TWO_FACTOR_METHOD_SMS}, phone, nil
+}
+
+// Отправка одноразового кода через поставщика SMS, указанного в конфигурации
+func SendTwoFactorCode(phone string, code string, language string) (err error) {
+	uuid := config.Configuration.TwoFactor.SmsSupplier
+	if uuid == "" {
+		log.Error("Supplier of two factor sms is not configured")
+		return errors.New("Two factor sms supplier is not configured")
+	}
+	recipient, err := strconv.ParseUint(phone, 10, 64)
+	if err != nil {
+		log.Error("Mobile phone is not valid %v", phone)
+		return err
+	}
+	gateway := gateways.Get(uuid)
+	supplier, err := gateway.Supplier(uuid)
+	if err != nil {
+		return err
+	}
+	sms := []libTypes.Sms{{
+		Recipient: recipient,
+		Sender:    config.Configuration.TwoFactor.SmsSender,
+		Message:   []byte(fmt.Sprintf(config.Localization[language].Messages.TwoFactorCode, code)),
+	}}
+	_, err = gateway.SendSms(supplier, &sms)
+	if err != nil {
+		log.Error("Can't send two factor code %v to %v", err, phone)
+		return err
+	}
+
+	return nil
+}
+
+// Создание попытки входа или подключения с отправкой SMS кода, если указан телефон
+func CreateTwoFactorChallenge(userid int64, purpose byte, phone string, language string,
+	sessionrepository services.SessionRepository,
+	twofactorchallengerepository services.TwoFactorChallengeRepository) (dtochallenge *models.DtoTwoFactorChallenge, err error) {
+	token, err := sessionrepository.GenerateToken(TOKEN_LENGTH)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	dtochallenge = models.NewDtoTwoFactorChallenge(token, userid, purpose, "", language, 0, time.Time{},
+		now.Add(TwoFactorCodeTimeout()), now)
+	if phone != "" {
+		code, err := GenerateNumericCode(TWO_FACTOR_SMS_DIGITS)
+		if err != nil {
+			return nil, err
+		}
+		if err = SendTwoFactorCode(phone, code, language); err != nil {
+			return nil, err
+		}
+		dtochallenge.Code = HashTwoFactorCode(code)
+		dtochallenge.Sent = now
+	}
+	err = twofactorchallengerepository.Create(dtochallenge)
+	if err != nil {
+		return nil, err
+	}
+
+	return dtochallenge, nil
+}
+
+// Повторная отправка SMS кода попытки не чаще одного раза в минуту, прежний код перестает действовать
+func ResendTwoFactorCode(dtochallenge *models.DtoTwoFactorChallenge, phone string, r render.Render,
+	twofactorchallengerepository services.TwoFactorChallengeRepository) (err error) {
+	now := time.Now()
+	if now.Sub(dtochallenge.Sent) < TWO_FACTOR_RESEND_TIMEOUT {
+		log.Error("Two factor codes are too frequent for user %v", dtochallenge.User_ID)
+		r.JSON(http.StatusTooManyRequests, types.Error{Code: types.TYPE_ERROR_REQUEST_TOOFREQUENT,
+			Message: config.Localization[dtochallenge.Language].Errors.Api.Request_Too_Often})
+		return errors.New("Frequent requests")
+	}
+	code, err := GenerateNumericCode(TWO_FACTOR_SMS_DIGITS)
+	if err == nil {
+		err = SendTwoFactorCode(phone, code, dtochallenge.Language)
+	}
+	if err == nil {
+		dtochallenge.Code = HashTwoFactorCode(code)
+		dtochallenge.Sent = now
+		dtochallenge.Expires = now.Add(TwoFactorCodeTimeout())
+		err = twofactorchallengerepository.Update(dtochallenge)
+	}
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[dtochallenge.Language].Errors.Api.Data_Wrong})
+		return err
+	}
+
+	return nil
+}
+
+// Проверка действия попытки входа или подключения по токену
+func CheckTwoFactorChallenge(r render.Render, token string, purpose byte, language string,
+	twofactorchallengerepository services.TwoFactorChallengeRepository) (dtochallenge *models.DtoTwoFactorChallenge, err error) {
+	dtochallenge, err = twofactorchallengerepository.Get(token)
+	if err == nil && (dtochallenge.Purpose != purpose || time.Now().After(dtochallenge.Expires) ||
+		dtochallenge.Attempts >= TWO_FACTOR_MAX_ATTEMPTS) {
+		log.Error("Two factor challenge has been expired for user %v", dtochallenge.User_ID)
+		err = errors.New("Challenge expired")
+	}
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+
+	return dtochallenge, nil
+}
+
+// Проверка кода второго фактора попытки. Неверный код увеличивает счетчик попыток
+func VerifyTwoFactorCode(dtochallenge *models.DtoTwoFactorChallenge, method string, code string, methods []string,
+	r render.Render, twofactorrepository services.TwoFactorRepository,
+	twofactorchallengerepository services.TwoFactorChallengeRepository) (err error) {
+	allowed := false
+	for _, available := range methods {
+		allowed = allowed || available == method
+	}
+
+	valid := false
+	if allowed {
+		switch method {
+		case models.TWO_FACTOR_METHOD_TOTP:
+			var dtotwofactor *models.DtoTwoFactor
+			dtotwofactor, err = twofactorrepository.Get(dtochallenge.User_ID)
+			if err == nil {
+				counter, ok := CheckTOTPCode(dtotwofactor.Secret, code, time.Now())
+				if ok {
+					valid, err = twofactorrepository.UseCounter(dtochallenge.User_ID, counter)
+				}
+			}
+		case models.TWO_FACTOR_METHOD_SMS:
+			valid = dtochallenge.Code != "" &&
+				subtle.ConstantTimeCompare([]byte(dtochallenge.Code), []byte(HashTwoFactorCode(code))) == 1
+		case models.TWO_FACTOR_METHOD_RECOVERY:
+			valid, err = twofactorrepository.UseRecoveryCode(dtochallenge.User_ID, HashTwoFactorCode(code))
+		}
+	}
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[dtochallenge.Language].Errors.Api.Data_Wrong})
+		return err
+	}
+	if !valid {
+		log.Error("Wrong two factor code for user %v", dtochallenge.User_ID)
+		dtochallenge.Attempts++
+		_ = twofactorchallengerepository.Update(dtochallenge)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_CONFIRMATION_CODE_WRONG,
+			Message: config.Localization[dtochallenge.Language].Errors.Api.Confirmation_Code_Wrong})
+		return errors.New("Wrong code")
+	}
+
+	return twofactorchallengerepository.Delete(dtochallenge.Token)
+}
+
+// Хэш токена доверенного устройства, в бд токен в открытом виде не хранится
+func hashTwoFactorDeviceToken(token string) string {
+	hash := sha512.Sum512([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+// Регистрация доверенного устройства, входящего без второго фактора до окончания срока доверия
+func TrustTwoFactorDevice(userid int64, os string, devicerepository services.DeviceRepository,
+	sessionrepository services.SessionRepository) (token string, err error) {
+	token, err = sessionrepository.GenerateToken(TOKEN_LENGTH)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	dtodevice := models.NewDtoDevice(0, userid, os, models.DEVICE_APP_TWO_FACTOR, "", hashTwoFactorDeviceToken(token), "", "",
+		now.Add(TwoFactorTrustDuration()), now, true)
+	err = devicerepository.Create(dtodevice)
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+func IsTrustedTwoFactorDevice(userid int64, token string, devicerepository services.DeviceRepository) bool {
+	if token == "" {
+		return false
+	}
+	dtodevice, err := devicerepository.FindByToken(hashTwoFactorDeviceToken(token))
+	if err != nil {
+		return false
+	}
+
+	return dtodevice.User_ID == userid && dtodevice.App == models.DEVICE_APP_TWO_FACTOR && time.Now().Before(dtodevice.Valid_Till)
+}
+
+// Начало входа со вторым фактором. Возвращает попытку входа, если второй фактор нужен, и nil, если сессия создается сразу
+func StartTwoFactorLogin(user *models.DtoUser, devicetoken string, language string, r render.Render,
+	twofactorrepository services.TwoFactorRepository, twofactorchallengerepository services.TwoFactorChallengeRepository,
+	mobilephonerepository services.MobilePhoneRepository, devicerepository services.DeviceRepository,
+	sessionrepository services.SessionRepository) (apichallenge *models.ApiTwoFactorChallenge, err error) {
+	methods, phone, err := GetTwoFactorMethods(user, twofactorrepository, mobilephonerepository)
+	if err != nil && err != errTwoFactorPhone {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return nil, err
+	}
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_PRIMARY_MOBILEPHONE_NOTCONFIRMED,
+			Message: config.Localization[language].Errors.Api.PrimaryMobilePhone_NotConfirmed})
+		return nil, err
+	}
+	if len(methods) == 0 || IsTrustedTwoFactorDevice(user.ID, devicetoken, devicerepository) {
+		return nil, nil
+	}
+	if methods[0] != models.TWO_FACTOR_METHOD_SMS {
+		phone = ""
+	}
+
+	dtochallenge, err := CreateTwoFactorChallenge(user.ID, models.TWO_FACTOR_CHALLENGE_LOGIN, phone, language,
+		sessionrepository, twofactorchallengerepository)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return nil, err
+	}
+
+	return models.NewApiTwoFactorChallenge(dtochallenge.Token, methods, dtochallenge.Expires), nil
+}
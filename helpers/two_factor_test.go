@@ -0,0 +1,85 @@
+package helpers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+// Секрет "12345678901234567890" тестовых векторов RFC 6238 в кодировке base32
+const testTOTPSecret = "GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ"
+
+func TestTOTPCode(t *testing.T) {
+	var cases = []struct {
+		seconds int64
+		code    string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+
+	for _, c := range cases {
+		code, err := TOTPCode(testTOTPSecret, c.seconds/TWO_FACTOR_TOTP_PERIOD)
+		if err != nil || code != c.code {
+			t.Error("Code at", c.seconds, "is not properly generated", code, err)
+		}
+	}
+
+	code, err := TOTPCode(" "+strings.ToLower(testTOTPSecret)+" ", 1111111111/TWO_FACTOR_TOTP_PERIOD)
+	if err != nil || code != "050471" {
+		t.Error("Secret in lower case is not properly decoded", code, err)
+	}
+	code, err = TOTPCode("GEZDGNBVGY3TQOJQGEZDGNBVGY", 1)
+	if err != nil || code != "970934" {
+		t.Error("Secret without padding is not properly decoded", code, err)
+	}
+	if _, err = TOTPCode("1!", 1); err == nil {
+		t.Error("Wrong secret is not properly rejected")
+	}
+}
+
+func TestCheckTOTPCode(t *testing.T) {
+	var now = time.Unix(1111111111, 0)
+	var cases = []struct {
+		code    string
+		counter int64
+		valid   bool
+	}{
+		{"050471", 1111111111 / TWO_FACTOR_TOTP_PERIOD, true},
+		{" 050471 ", 1111111111 / TWO_FACTOR_TOTP_PERIOD, true},
+		{"081804", 1111111109 / TWO_FACTOR_TOTP_PERIOD, true},
+		{"005924", 0, false},
+		{"", 0, false},
+	}
+
+	for _, c := range cases {
+		counter, valid := CheckTOTPCode(testTOTPSecret, c.code, now)
+		if valid != c.valid || counter != c.counter {
+			t.Error("Code", c.code, "is not properly checked", counter, valid)
+		}
+	}
+
+	code, _ := TOTPCode(testTOTPSecret, 1111111111/TWO_FACTOR_TOTP_PERIOD+TWO_FACTOR_TOTP_SKEW+1)
+	if _, valid := CheckTOTPCode(testTOTPSecret, code, now); valid {
+		t.Error("Code outside of allowed skew is not properly rejected")
+	}
+}
+
+func TestHashTwoFactorCode(t *testing.T) {
+	hash := sha256.Sum256([]byte("abcdefghij"))
+	expected := hex.EncodeToString(hash[:])
+	for _, code := range []string{"abcdefghij", "abcde-fghij", "ABCDE-FGHIJ", " abcde fghij "} {
+		if value := HashTwoFactorCode(code); value != expected {
+			t.Error("Code", code, "is not properly hashed", value)
+		}
+	}
+	if HashTwoFactorCode("abcde-fghik") == expected {
+		t.Error("Different codes have the same hash")
+	}
+}
@@ -0,0 +1,98 @@
+package helpers
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"errors"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"types"
+)
+
+const (
+	PARAM_NAME_UNIT_ROLE_ID = "roleId"
+)
+
+func CheckUnitRole(r render.Render, params martini.Params, userrepository services.UserRepository,
+	unitrolerepository services.UnitRoleRepository, session *models.DtoSession) (dtounitrole *models.DtoUnitRole, err error) {
+	role_id, err := CheckParameterInt(r, params[PARAM_NAME_UNIT_ROLE_ID], session.Language)
+	if err != nil {
+		return nil, err
+	}
+
+	dtounitrole, err = unitrolerepository.Get(role_id)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+
+	dtouser, err := userrepository.Get(session.UserID)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+
+	if dtounitrole.Unit_ID != dtouser.UnitID {
+		log.Error("Role unit %v and user unit %v don't match", dtounitrole.Unit_ID, dtouser.UnitID)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[session.Language].Errors.Api.Object_NotExist})
+		return nil, errors.New("Wrong unit")
+	}
+
+	return dtounitrole, nil
+}
+
+// Разрешения роли должны входить в перечень известных разрешений и не повторяться
+func CheckPermissions(permissions []string, language string, r render.Render) (err error) {
+	used := make(map[string]bool)
+	for _, permission := range permissions {
+		known := false
+		for _, value := range models.PERMISSIONS {
+			if value == permission {
+				known = true
+				break
+			}
+		}
+		if !known || used[permission] {
+			log.Error("Permission is unknown or repeated %v", permission)
+			r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+				Message: config.Localization[language].Errors.Api.Data_Wrong})
+			return errors.New("Wrong permission")
+		}
+		used[permission] = true
+	}
+
+	return nil
+}
+
+// Назначаемые пользователю роли должны принадлежать его объединению
+func CheckUnitRoles(roleids []int64, unitid int64, unitrolerepository services.UnitRoleRepository,
+	language string, r render.Render) (err error) {
+	roles, err := unitrolerepository.GetByUnit(unitid)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return err
+	}
+	for _, roleid := range roleids {
+		found := false
+		for _, role := range *roles {
+			if role.ID == roleid {
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Error("Role %v doesn't belong to unit %v", roleid, unitid)
+			r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+				Message: config.Localization[language].Errors.Api.Object_NotExist})
+			return errors.New("Role unknown")
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package helpers
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"github.com/martini-contrib/render"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"types"
+)
+
+const (
+	PARAM_NAME_WEBHOOK_ID = "whid"
+
+	WEBHOOK_SECRET_LENGTH = 32
+)
+
+var (
+	// Частные, локальные и зарезервированные сети, на адреса которых уведомления не отправляются
+	WEBHOOK_DENIED_NETWORKS = []string{"0.0.0.0/8", "10.0.0.0/8", "100.64.0.0/10", "127.0.0.0/8", "169.254.0.0/16",
+		"172.16.0.0/12", "192.0.0.0/24", "192.0.2.0/24", "192.88.99.0/24", "192.168.0.0/16", "198.18.0.0/15",
+		"198.51.100.0/24", "203.0.113.0/24", "224.0.0.0/4", "240.0.0.0/4", "::/128", "::1/128", "64:ff9b::/96",
+		"100::/64", "2001:db8::/32", "fc00::/7", "fe80::/10", "ff00::/8"}
+)
+
+// Адрес не принадлежит частным, локальным и зарезервированным сетям
+func IsPublicAddress(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, network := range WEBHOOK_DENIED_NETWORKS {
+		_, ipnet, err := net.ParseCIDR(network)
+		if err == nil && ipnet.Contains(ip) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Адреса узла уведомлений. Узел отклоняется, если хотя бы один из его адресов не является публичным
+func ResolveWebhookHost(host string) (ips []net.IP, err error) {
+	ips, err = net.LookupIP(host)
+	if err != nil {
+		log.Error("Can't resolve webhook host %v, %v", host, err)
+		return nil, err
+	}
+	if len(ips) == 0 {
+		log.Error("Webhook host %v has no addresses", host)
+		return nil, errors.New("Unknown webhook host")
+	}
+	for _, ip := range ips {
+		if !IsPublicAddress(ip) {
+			log.Error("Webhook host %v resolves to not public address %v", host, ip)
+			return nil, errors.New("Webhook host is not public")
+		}
+	}
+
+	return ips, nil
+}
+
+// Проверка адреса уведомлений: допускаются только http и https адреса публичных узлов
+func CheckWebhookURL(value string, r render.Render, language string) (err error) {
+	webhookurl, err := url.Parse(value)
+	if err == nil && webhookurl.Scheme != "http" && webhookurl.Scheme != "https" {
+		err = errors.New("Wrong webhook scheme")
+	}
+	if err == nil && webhookurl.Hostname() == "" {
+		err = errors.New("Empty webhook host")
+	}
+	if err == nil {
+		_, err = ResolveWebhookHost(webhookurl.Hostname())
+	}
+	if err != nil {
+		log.Error("Webhook url %v is not allowed, %v", value, err)
+		r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return err
+	}
+
+	return nil
+}
+
+func GetApiWebhook(dtowebhook *models.DtoWebhook, secret string) *models.ApiWebhook {
+	events := []string{}
+	if dtowebhook.Events != "" {
+		events = strings.Split(dtowebhook.Events, ",")
+	}
+	return models.NewApiWebhook(dtowebhook.ID, dtowebhook.URL, events, secret, dtowebhook.Active, dtowebhook.Created)
+}
+
+// Проверка списка событий, на которые подписывается адрес уведомлений
+func CheckWebhookEvents(events []string, r render.Render, language string) (value string, err error) {
+	for _, event := range events {
+		if !models.IsWebhookEvent(event) {
+			log.Error("Unknown webhook event %v", event)
+			r.JSON(http.StatusBadRequest, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+				Message: config.Localization[language].Errors.Api.Data_Wrong})
+			return "", errors.New("Unknown webhook event")
+		}
+	}
+
+	return strings.Join(events, ","), nil
+}
+
+// Проверка принадлежности адреса уведомлений объединению
+func CheckWebhook(unit_id int64, webhook_id int64, r render.Render, webhookrepository services.WebhookRepository,
+	language string) (dtowebhook *models.DtoWebhook, err error) {
+	dtowebhook, err = webhookrepository.Get(webhook_id)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, err
+	}
+	if dtowebhook.Unit_ID != unit_id {
+		log.Error("Webhook %v doesn't belong to unit %v", webhook_id, unit_id)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return nil, errors.New("Wrong webhook unit")
+	}
+
+	return dtowebhook, nil
+}
+
+func GetWebhooks(unit_id int64, w http.ResponseWriter, r render.Render, webhookrepository services.WebhookRepository, language string) {
+	dtowebhooks, err := webhookrepository.GetByUnit(unit_id)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return
+	}
+	apiwebhooks := []models.ApiWebhook{}
+	for i := range *dtowebhooks {
+		apiwebhooks = append(apiwebhooks, *GetApiWebhook(&(*dtowebhooks)[i], ""))
+	}
+
+	RenderJSONArray(apiwebhooks, len(apiwebhooks), w, r)
+}
+
+// Создание адреса уведомлений. Ключ подписи генерируется сервером и возвращается только при создании
+func CreateWebhook(unit_id int64, viewwebhook *models.ViewWebhook, r render.Render, webhookrepository services.WebhookRepository,
+	language string) (apiwebhook *models.ApiWebhook, err error) {
+	events, err := CheckWebhookEvents(viewwebhook.Events, r, language)
+	if err != nil {
+		return nil, err
+	}
+	err = CheckWebhookURL(viewwebhook.URL, r, language)
+	if err != nil {
+		return nil, err
+	}
+	secretRaw := make([]byte, WEBHOOK_SECRET_LENGTH)
+	if _, err = rand.Read(secretRaw); err != nil {
+		log.Error("Error during webhook secret generation %v", err)
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return nil, err
+	}
+
+	dtowebhook := models.NewDtoWebhook(0, unit_id, viewwebhook.URL, events, hex.EncodeToString(secretRaw), viewwebhook.Active, time.Now())
+	err = webhookrepository.Create(dtowebhook)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return nil, err
+	}
+
+	return GetApiWebhook(dtowebhook, dtowebhook.Secret), nil
+}
+
+func UpdateWebhook(unit_id int64, webhook_id int64, viewwebhook *models.ViewWebhook, r render.Render,
+	webhookrepository services.WebhookRepository, language string) (apiwebhook *models.ApiWebhook, err error) {
+	dtowebhook, err := CheckWebhook(unit_id, webhook_id, r, webhookrepository, language)
+	if err != nil {
+		return nil, err
+	}
+	events, err := CheckWebhookEvents(viewwebhook.Events, r, language)
+	if err != nil {
+		return nil, err
+	}
+	err = CheckWebhookURL(viewwebhook.URL, r, language)
+	if err != nil {
+		return nil, err
+	}
+
+	dtowebhook.URL = viewwebhook.URL
+	dtowebhook.Events = events
+	dtowebhook.Active = viewwebhook.Active
+	err = webhookrepository.Update(dtowebhook)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_DATA_WRONG,
+			Message: config.Localization[language].Errors.Api.Data_Wrong})
+		return nil, err
+	}
+
+	return GetApiWebhook(dtowebhook, ""), nil
+}
+
+func DeleteWebhook(unit_id int64, webhook_id int64, r render.Render, webhookrepository services.WebhookRepository,
+	language string) (err error) {
+	_, err = CheckWebhook(unit_id, webhook_id, r, webhookrepository, language)
+	if err != nil {
+		return err
+	}
+
+	err = webhookrepository.Delete(webhook_id)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return err
+	}
+
+	return nil
+}
+
+// Журнал доставки уведомлений адреса
+func GetWebhookDeliveries(unit_id int64, webhook_id int64, w http.ResponseWriter, r render.Render,
+	webhookrepository services.WebhookRepository, webhookdeliveryrepository services.WebhookDeliveryRepository, language string) {
+	_, err := CheckWebhook(unit_id, webhook_id, r, webhookrepository, language)
+	if err != nil {
+		return
+	}
+	dtowebhookdeliveries, err := webhookdeliveryrepository.GetByWebhook(webhook_id)
+	if err != nil {
+		r.JSON(http.StatusNotFound, types.Error{Code: types.TYPE_ERROR_OBJECT_NOTEXIST,
+			Message: config.Localization[language].Errors.Api.Object_NotExist})
+		return
+	}
+	apiwebhookdeliveries := []models.ApiWebhookDelivery{}
+	for _, dtowebhookdelivery := range *dtowebhookdeliveries {
+		apiwebhookdeliveries = append(apiwebhookdeliveries, *models.NewApiWebhookDelivery(dtowebhookdelivery.ID,
+			dtowebhookdelivery.Event, dtowebhookdelivery.Status.String(), dtowebhookdelivery.Attempts, dtowebhookdelivery.ResponseCode,
+			dtowebhookdelivery.Error, dtowebhookdelivery.Next_Attempt, dtowebhookdelivery.Created, dtowebhookdelivery.Delivered))
+	}
+
+	RenderJSONArray(apiwebhookdeliveries, len(apiwebhookdeliveries), w, r)
+}
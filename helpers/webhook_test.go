@@ -0,0 +1,71 @@
+package helpers
+
+import (
+	"net"
+	"net/http"
+	"testing"
+	"types"
+)
+
+func TestIsPublicAddress(t *testing.T) {
+	var cases = []struct {
+		address string
+		public  bool
+	}{
+		{"8.8.8.8", true},
+		{"93.184.216.34", true},
+		{"2a00:1450:4010:c05::64", true},
+		{"127.0.0.1", false},
+		{"10.1.2.3", false},
+		{"172.16.5.4", false},
+		{"192.168.1.1", false},
+		{"169.254.169.254", false},
+		{"100.64.0.1", false},
+		{"0.0.0.0", false},
+		{"224.0.0.1", false},
+		{"::1", false},
+		{"::", false},
+		{"::ffff:127.0.0.1", false},
+		{"fd00::1", false},
+		{"fe80::1", false},
+	}
+
+	for _, c := range cases {
+		if IsPublicAddress(net.ParseIP(c.address)) != c.public {
+			t.Error("Address", c.address, "is not properly checked")
+		}
+	}
+}
+
+func TestCheckWebhookURL(t *testing.T) {
+	var language = "eng"
+	var testlogger = new(TestLogger)
+	InitLogger(testlogger)
+
+	var cases = []struct {
+		value   string
+		allowed bool
+	}{
+		{"https://8.8.8.8/hook", true},
+		{"http://8.8.8.8:8080/hook?a=1", true},
+		{"ftp://8.8.8.8/hook", false},
+		{"/hook", false},
+		{"http:///hook", false},
+		{"http://127.0.0.1/hook", false},
+		{"http://10.0.0.1/hook", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://[::1]:8080/hook", false},
+		{"http://%zz/hook", false},
+	}
+
+	for _, c := range cases {
+		var r = new(Renderer)
+		err := CheckWebhookURL(c.value, r, language)
+		if c.allowed && err != nil {
+			t.Error("Webhook url", c.value, "should be allowed")
+		}
+		if !c.allowed && (err == nil || r.StatusValue != http.StatusBadRequest || r.ErrorValue.Code != types.TYPE_ERROR_DATA_WRONG) {
+			t.Error("Webhook url", c.value, "should be rejected with wrong data error")
+		}
+	}
+}
@@ -0,0 +1,228 @@
+package models
+
+import (
+	"github.com/martini-contrib/binding"
+	"net/http"
+	"time"
+)
+
+type AddressImportStatus int
+
+const (
+	ADDRESS_IMPORT_STATUS_RUNNING AddressImportStatus = iota + 1
+	ADDRESS_IMPORT_STATUS_COMPLETED
+	ADDRESS_IMPORT_STATUS_FAILED
+)
+
+const (
+	ADDRESS_IMPORT_STATUS_RUNNING_VALUE   = "running"
+	ADDRESS_IMPORT_STATUS_COMPLETED_VALUE = "completed"
+	ADDRESS_IMPORT_STATUS_FAILED_VALUE    = "failed"
+
+	// Уровни адресных объектов ФИАС
+	ADDRESS_LEVEL_REGION              = 1
+	ADDRESS_LEVEL_AREA                = 2
+	ADDRESS_LEVEL_MUNICIPAL_AREA      = 3
+	ADDRESS_LEVEL_SETTLEMENT_DISTRICT = 4
+	ADDRESS_LEVEL_CITY                = 5
+	ADDRESS_LEVEL_SETTLEMENT          = 6
+	ADDRESS_LEVEL_PLANNING_STRUCTURE  = 7
+	ADDRESS_LEVEL_STREET              = 8
+
+	// Типы параметров адресных объектов ФИАС
+	ADDRESS_PARAM_TAXOFFICE       = 1
+	ADDRESS_PARAM_TAXOFFICE_LEGAL = 2
+	ADDRESS_PARAM_POSTALCODE      = 5
+	ADDRESS_PARAM_OKATO           = 6
+	ADDRESS_PARAM_OKTMO           = 7
+	ADDRESS_PARAM_KLADR           = 10
+
+	ADDRESS_COUNTRY = "Россия"
+
+	// Код качества разбора адреса
+	ADDRESS_QUALITY_CODE_GOOD      = 0 // Адрес разобран полностью
+	ADDRESS_QUALITY_CODE_UNPARSED  = 1 // Остались нераспознанные части
+	ADDRESS_QUALITY_CODE_EMPTY     = 2 // Адрес пустой или не распознан
+	ADDRESS_QUALITY_CODE_AMBIGUOUS = 3 // Адресу соответствует несколько объектов
+
+	// Полнота адреса
+	ADDRESS_QC_COMPLETE_GOOD      = 0  // Адрес пригоден для доставки
+	ADDRESS_QC_COMPLETE_NOREGION  = 1  // Нет региона
+	ADDRESS_QC_COMPLETE_NOCITY    = 2  // Нет города или населенного пункта
+	ADDRESS_QC_COMPLETE_NOSTREET  = 3  // Нет улицы
+	ADDRESS_QC_COMPLETE_NOHOUSE   = 4  // Нет дома
+	ADDRESS_QC_COMPLETE_HOUSEFIAS = 10 // Дом не найден в ФИАС
+	ADDRESS_QC_HOUSE_FOUND        = 2  // Дом найден в ФИАС
+	ADDRESS_QC_HOUSE_NOTFOUND     = 10 // Дом не найден в ФИАС
+)
+
+// Структура для организации хранения загрузки справочника адресов ФИАС
+type ViewAddressImport struct {
+	File string `json:"file" validate:"nonzero,max=255,regexp=^[^/\\\\]+[.]zip$"` // Имя архива ГАР в директории справочника адресов
+}
+
+type ApiAddressImport struct {
+	ID       int64     `json:"id"`       // Уникальный идентификатор загрузки
+	File     string    `json:"file"`     // Имя архива
+	Status   string    `json:"status"`   // Статус загрузки
+	Objects  int64     `json:"objects"`  // Количество загруженных адресных объектов
+	Houses   int64     `json:"houses"`   // Количество загруженных домов
+	Error    string    `json:"error"`    // Ошибка загрузки
+	Created  time.Time `json:"created"`  // Время начала загрузки
+	Finished time.Time `json:"finished"` // Время окончания загрузки
+}
+
+// Стандартизованный адрес. Названия полей совпадают с полями ответа поставщика по проверке почтового адреса
+type ApiStandardAddress struct {
+	Source             string `json:"source"`             // Исходный адрес
+	Result             string `json:"result"`             // Стандартизованный адрес
+	PostalCode         string `json:"postalCode"`         // Индекс
+	Country            string `json:"country"`            // Страна
+	RegionType         string `json:"regionType"`         // Тип региона
+	RegionTypeFull     string `json:"regionTypeFull"`     // Полный тип региона
+	Region             string `json:"region"`             // Регион
+	AreaType           string `json:"areaType"`           // Тип района
+	AreaTypeFull       string `json:"areaTypeFull"`       // Полный тип района
+	Area               string `json:"area"`               // Район
+	CityType           string `json:"cityType"`           // Тип города
+	CityTypeFull       string `json:"cityTypeFull"`       // Полный тип города
+	City               string `json:"city"`               // Город
+	SettlementType     string `json:"settlementType"`     // Тип населенного пункта
+	SettlementTypeFull string `json:"settlementTypeFull"` // Полный тип населенного пункта
+	Settlement         string `json:"settlement"`         // Населенный пункт
+	StreetType         string `json:"streetType"`         // Тип улицы
+	StreetTypeFull     string `json:"streetTypeFull"`     // Полный тип улицы
+	Street             string `json:"street"`             // Улица
+	HouseType          string `json:"houseType"`          // Тип дома
+	HouseTypeFull      string `json:"houseTypeFull"`      // Полный тип дома
+	House              string `json:"house"`              // Дом
+	BlockType          string `json:"blockType"`          // Тип корпуса
+	BlockTypeFull      string `json:"blockTypeFull"`      // Полный тип корпуса
+	Block              string `json:"block"`              // Корпус
+	FlatType           string `json:"flatType"`           // Тип квартиры
+	Flat               string `json:"flat"`               // Квартира
+	FiasId             string `json:"fiasId"`             // Идентификатор ФИАС
+	KladrId            string `json:"kladrId"`            // Код КЛАДР
+	Okato              string `json:"okato"`              // Код ОКАТО
+	Oktmo              string `json:"oktmo"`              // Код ОКТМО
+	TaxOffice          string `json:"taxOffice"`          // Код ИФНС для физических лиц
+	TaxOfficeLegal     string `json:"taxOfficeLegal"`     // Код ИФНС для организаций
+	QcComplete         int    `json:"qcComplete"`         // Полнота адреса
+	QcHouse            int    `json:"qcHouse"`            // Наличие дома в ФИАС
+	QualityCode        int    `json:"qualityCode"`        // Код качества разбора
+	UnparsedParts      string `json:"unparsedParts"`      // Нераспознанные части адреса
+}
+
+type DtoAddressImport struct {
+	ID       int64               `db:"id"`       // Уникальный идентификатор загрузки
+	File     string              `db:"file"`     // Имя архива
+	Status   AddressImportStatus `db:"status"`   // Статус загрузки
+	Objects  int64               `db:"objects"`  // Количество загруженных адресных объектов
+	Houses   int64               `db:"houses"`   // Количество загруженных домов
+	Error    string              `db:"error"`    // Ошибка загрузки
+	Created  time.Time           `db:"created"`  // Время начала загрузки
+	Finished time.Time           `db:"finished"` // Время окончания загрузки
+}
+
+// Адресный объект ФИАС: регион, район, город, населенный пункт, улица
+type DtoAddressObject struct {
+	ID        int64  `db:"id"`         // Глобальный идентификатор объекта ГАР
+	GUID      string `db:"guid"`       // Идентификатор ФИАС
+	Name      string `db:"name"`       // Название
+	TypeShort string `db:"type_short"` // Краткий тип
+	TypeFull  string `db:"type_full"`  // Полный тип
+	Level     int    `db:"level"`      // Уровень
+}
+
+type DtoAddressHouse struct {
+	ID             int64  `db:"id"`               // Глобальный идентификатор дома ГАР
+	GUID           string `db:"guid"`             // Идентификатор ФИАС
+	Number         string `db:"number"`           // Номер дома
+	TypeShort      string `db:"type_short"`       // Краткий тип
+	TypeFull       string `db:"type_full"`        // Полный тип
+	Block          string `db:"block"`            // Номер корпуса или строения
+	BlockTypeShort string `db:"block_type_short"` // Краткий тип корпуса
+	BlockTypeFull  string `db:"block_type_full"`  // Полный тип корпуса
+}
+
+// Связь объекта с родительским объектом в административном делении
+type DtoAddressHierarchy struct {
+	Object_ID int64 `db:"object_id"` // Идентификатор объекта
+	Parent_ID int64 `db:"parent_id"` // Идентификатор родительского объекта
+}
+
+type DtoAddressParam struct {
+	Object_ID int64  `db:"object_id"` // Идентификатор объекта или дома
+	Type_ID   int    `db:"type_id"`   // Тип параметра
+	Value     string `db:"value"`     // Значение
+}
+
+// Конструктор создания объекта загрузки справочника адресов в api
+func NewApiAddressImport(id int64, file string, status string, objects int64, houses int64, importerror string,
+	created time.Time, finished time.Time) *ApiAddressImport {
+	return &ApiAddressImport{
+		ID:       id,
+		File:     file,
+		Status:   status,
+		Objects:  objects,
+		Houses:   houses,
+		Error:    importerror,
+		Created:  created,
+		Finished: finished,
+	}
+}
+
+// Конструктор создания объекта загрузки справочника адресов в бд
+func NewDtoAddressImport(id int64, file string, status AddressImportStatus, objects int64, houses int64, importerror string,
+	created time.Time, finished time.Time) *DtoAddressImport {
+	return &DtoAddressImport{
+		ID:       id,
+		File:     file,
+		Status:   status,
+		Objects:  objects,
+		Houses:   houses,
+		Error:    importerror,
+		Created:  created,
+		Finished: finished,
+	}
+}
+
+func NewDtoAddressObject(id int64, guid string, name string, typeshort string, typefull string, level int) *DtoAddressObject {
+	return &DtoAddressObject{
+		ID:        id,
+		GUID:      guid,
+		Name:      name,
+		TypeShort: typeshort,
+		TypeFull:  typefull,
+		Level:     level,
+	}
+}
+
+func NewDtoAddressHouse(id int64, guid string, number string, typeshort string, typefull string, block string,
+	blocktypeshort string, blocktypefull string) *DtoAddressHouse {
+	return &DtoAddressHouse{
+		ID:             id,
+		GUID:           guid,
+		Number:         number,
+		TypeShort:      typeshort,
+		TypeFull:       typefull,
+		Block:          block,
+		BlockTypeShort: blocktypeshort,
+		BlockTypeFull:  blocktypefull,
+	}
+}
+
+func (status AddressImportStatus) String() string {
+	switch status {
+	case ADDRESS_IMPORT_STATUS_COMPLETED:
+		return ADDRESS_IMPORT_STATUS_COMPLETED_VALUE
+	case ADDRESS_IMPORT_STATUS_FAILED:
+		return ADDRESS_IMPORT_STATUS_FAILED_VALUE
+	default:
+		return ADDRESS_IMPORT_STATUS_RUNNING_VALUE
+	}
+}
+
+func (addressimport *ViewAddressImport) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(addressimport, errors, req)
+}
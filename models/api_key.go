@@ -0,0 +1,98 @@
+package models
+
+import (
+	"github.com/martini-contrib/binding"
+	"net/http"
+	"time"
+)
+
+// Структура для организации ключей доступа внешних систем
+type ViewApiKey struct {
+	Name       string     `json:"name" validate:"min=1,max=255"` // Название ключа
+	Roles      []UserRole `json:"groups"`                        // Уровни доступа ключа, только заказчик или исполнитель
+	Expires    time.Time  `json:"expires"`                       // Время окончания действия ключа
+	AllowedIPs []string   `json:"allowedIps"`                    // Адреса или подсети, с которых разрешено использование ключа
+}
+
+type ApiApiKey struct {
+	ID         int64      `json:"id"`         // Уникальный идентификатор ключа
+	User_ID    int64      `json:"userId"`     // Пользователь, от имени которого выполняются запросы
+	Name       string     `json:"name"`       // Название ключа
+	Prefix     string     `json:"prefix"`     // Начало ключа для его опознания
+	Roles      []UserRole `json:"groups"`     // Уровни доступа ключа
+	AllowedIPs []string   `json:"allowedIps"` // Разрешенные адреса или подсети
+	Expires    time.Time  `json:"expires"`    // Время окончания действия ключа
+	Last_Used  time.Time  `json:"lastUsed"`   // Время последнего использования
+	Active     bool       `json:"active"`     // Ключ не отозван
+	Created    time.Time  `json:"created"`    // Время создания ключа
+}
+
+// Ключ показывается один раз при создании или замене
+type ApiApiKeySecret struct {
+	ID      int64     `json:"id"`      // Уникальный идентификатор ключа
+	Key     string    `json:"key"`     // Ключ для заголовка X-Api-Key
+	Expires time.Time `json:"expires"` // Время окончания действия ключа
+}
+
+type DtoApiKey struct {
+	ID          int64      `db:"id"`          // Уникальный идентификатор ключа
+	Unit_ID     int64      `db:"unit_id"`     // Идентификатор объединения
+	User_ID     int64      `db:"user_id"`     // Пользователь, от имени которого выполняются запросы
+	Name        string     `db:"name"`        // Название ключа
+	Prefix      string     `db:"prefix"`      // Начало ключа для его опознания
+	Hash        string     `db:"hash"`        // Хэш ключа
+	Roles       []UserRole `db:"-"`           // Уровни доступа ключа
+	Allowed_IPs string     `db:"allowed_ips"` // Разрешенные адреса или подсети через запятую
+	Expires     time.Time  `db:"expires"`     // Время окончания действия ключа
+	Last_Used   time.Time  `db:"last_used"`   // Время последнего использования
+	Active      bool       `db:"active"`      // Ключ не отозван
+	Created     time.Time  `db:"created"`     // Время создания ключа
+}
+
+// Конструктор создания объекта ключа доступа в api
+func NewApiApiKey(id int64, user_id int64, name string, prefix string, roles []UserRole, allowedips []string,
+	expires time.Time, last_used time.Time, active bool, created time.Time) *ApiApiKey {
+	return &ApiApiKey{
+		ID:         id,
+		User_ID:    user_id,
+		Name:       name,
+		Prefix:     prefix,
+		Roles:      roles,
+		AllowedIPs: allowedips,
+		Expires:    expires,
+		Last_Used:  last_used,
+		Active:     active,
+		Created:    created,
+	}
+}
+
+func NewApiApiKeySecret(id int64, key string, expires time.Time) *ApiApiKeySecret {
+	return &ApiApiKeySecret{
+		ID:      id,
+		Key:     key,
+		Expires: expires,
+	}
+}
+
+// Конструктор создания объекта ключа доступа в бд
+func NewDtoApiKey(id int64, unit_id int64, user_id int64, name string, prefix string, hash string, roles []UserRole,
+	allowed_ips string, expires time.Time, last_used time.Time, active bool, created time.Time) *DtoApiKey {
+	return &DtoApiKey{
+		ID:          id,
+		Unit_ID:     unit_id,
+		User_ID:     user_id,
+		Name:        name,
+		Prefix:      prefix,
+		Hash:        hash,
+		Roles:       roles,
+		Allowed_IPs: allowed_ips,
+		Expires:     expires,
+		Last_Used:   last_used,
+		Active:      active,
+		Created:     created,
+	}
+}
+
+func (apikey *ViewApiKey) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(apikey, errors, req)
+}
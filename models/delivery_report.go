@@ -0,0 +1,105 @@
+package models
+
+import (
+	"github.com/martini-contrib/binding"
+	"net/http"
+	"time"
+)
+
+type DeliveryReportType int
+
+const (
+	DELIVERY_REPORT_TYPE_SMS DeliveryReportType = iota + 1
+	DELIVERY_REPORT_TYPE_HLR
+)
+
+const (
+	DELIVERY_REPORT_TYPE_SMS_VALUE = "sms"
+	DELIVERY_REPORT_TYPE_HLR_VALUE = "hlr"
+
+	DELIVERY_STATUS_EXPIRED = "EXPIRED"
+	DELIVERY_ERROR_EXPIRED  = "Delivery report deadline expired"
+)
+
+// Структура для организации хранения отчетов о доставке, переданных поставщиком
+type ViewDeliveryReports struct {
+	Reports []ViewDeliveryReport `json:"reports" validate:"min=1,max=1000"` // Отчеты о доставке
+}
+
+type ViewDeliveryReport struct {
+	Type       string `json:"type" validate:"regexp=^(sms|hlr)$"` // Тип запроса
+	Request_ID string `json:"id" validate:"min=1,max=36"`         // UUID запроса, выданный поставщиком при отправке
+	Status     string `json:"status" validate:"max=255"`          // Статус запроса
+	Error      string `json:"error" validate:"max=255"`           // Ошибка исполнения запроса
+	Orn        string `json:"orn" validate:"max=255"`             // Оператор абонента по результату hlr запроса
+	Roaming    bool   `json:"roaming"`                            // Абонент находится в роуминге по результату hlr запроса
+	Final      bool   `json:"final"`                              // Статус является окончательным
+}
+
+type ApiDeliveryReports struct {
+	Accepted int      `json:"accepted"` // Количество принятых отчетов
+	Rejected []string `json:"rejected"` // UUID запросов, отчеты по которым не приняты
+}
+
+type DtoDeliveryReport struct {
+	Request_ID  string             `db:"request_id"`  // UUID запроса, выданный поставщиком при отправке
+	Order_ID    int64              `db:"order_id"`    // Идентификатор заказа
+	Supplier_ID int64              `db:"supplier_id"` // Идентификатор поставщика, которому отправлен запрос
+	Type        DeliveryReportType `db:"type"`        // Тип запроса
+	Status      string             `db:"status"`      // Статус запроса
+	Error       string             `db:"error"`       // Ошибка исполнения запроса
+	Orn         string             `db:"orn"`         // Оператор абонента по результату hlr запроса
+	Roaming     bool               `db:"roaming"`     // Абонент находится в роуминге по результату hlr запроса
+	Final       bool               `db:"final"`       // Статус является окончательным
+	MobilePhone uint64             `db:"mobilephone"` // Номер абонента, которому отправлено сообщение
+	Created     time.Time          `db:"created"`     // Время отправки запроса
+	Updated     time.Time          `db:"updated"`     // Время получения отчета
+}
+
+// Конструктор создания объекта результата приема отчетов о доставке в api
+func NewApiDeliveryReports(accepted int, rejected []string) *ApiDeliveryReports {
+	return &ApiDeliveryReports{
+		Accepted: accepted,
+		Rejected: rejected,
+	}
+}
+
+// Конструктор создания объекта отчета о доставке в бд
+func NewDtoDeliveryReport(request_id string, order_id int64, supplier_id int64, reporttype DeliveryReportType, status string,
+	reporterror string, orn string, roaming bool, final bool, created time.Time, updated time.Time) *DtoDeliveryReport {
+	return &DtoDeliveryReport{
+		Request_ID:  request_id,
+		Order_ID:    order_id,
+		Supplier_ID: supplier_id,
+		Type:        reporttype,
+		Status:      status,
+		Error:       reporterror,
+		Orn:         orn,
+		Roaming:     roaming,
+		Final:       final,
+		Created:     created,
+		Updated:     updated,
+	}
+}
+
+func ParseDeliveryReportType(value string) (reporttype DeliveryReportType) {
+	switch value {
+	case DELIVERY_REPORT_TYPE_HLR_VALUE:
+		return DELIVERY_REPORT_TYPE_HLR
+	default:
+		return DELIVERY_REPORT_TYPE_SMS
+	}
+}
+
+func (reporttype DeliveryReportType) String() string {
+	switch reporttype {
+	case DELIVERY_REPORT_TYPE_HLR:
+		return DELIVERY_REPORT_TYPE_HLR_VALUE
+	default:
+		return DELIVERY_REPORT_TYPE_SMS_VALUE
+	}
+}
+
+func (reports *ViewDeliveryReports) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(reports, errors, req)
+}
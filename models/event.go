@@ -4,6 +4,10 @@ import (
 	"time"
 )
 
+const (
+	EVENT_BIRTHDAY = iota + 1
+)
+
 // Структура для организации хранения события
 type ApiEvent struct {
 	ID       int    `json:"id" db:"id"`             // Уникальный идентификатор события
@@ -3,6 +3,7 @@ package models
 // Структура для организации хранения финансов
 type ApiFinance struct {
 	Balance              float64 `json:"amountTotal"`           // Сумма неизрасходованных средств
+	Held                 float64 `json:"amountHeld"`            // Сумма зарезервированных под заказы средств
 	TotalInvoiceAll      float64 `json:"amountInvoicesCreated"` // Сумма счетов
 	TotalInvoicePaid     float64 `json:"amountInvoicesPaid"`    // Сумма оплаченных счетов
 	TotalOrderExecuted   float64 `json:"amountOrdersPerformed"` // Сумма выполненных счетов
@@ -10,9 +11,10 @@ type ApiFinance struct {
 }
 
 // Конструктор создания объекта финансов в api
-func NewApiFinance(balance float64, totalinvoiceall float64, totalinvoicepaid float64, totalorderexecuted float64, totalorderprocessing float64) *ApiFinance {
+func NewApiFinance(balance float64, held float64, totalinvoiceall float64, totalinvoicepaid float64, totalorderexecuted float64, totalorderprocessing float64) *ApiFinance {
 	return &ApiFinance{
 		Balance:              balance,
+		Held:                 held,
 		TotalInvoiceAll:      totalinvoiceall,
 		TotalInvoicePaid:     totalinvoicepaid,
 		TotalOrderExecuted:   totalorderexecuted,
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// Структура для организации хранения результата hlr запроса, повторно используемого в заказах объединения
+type DtoHLRCache struct {
+	Unit_ID     int64     `db:"unit_id"`     // Идентификатор объединения
+	MobilePhone uint64    `db:"mobilephone"` // Номер мобильного телефона
+	Status      string    `db:"status"`      // Статус запроса
+	Orn         string    `db:"orn"`         // Оператор абонента
+	Roaming     bool      `db:"roaming"`     // Абонент находится в роуминге
+	Created     time.Time `db:"created"`     // Время получения результата
+}
+
+// Конструктор создания объекта результата hlr запроса в бд
+func NewDtoHLRCache(unit_id int64, mobilephone uint64, status string, orn string, roaming bool, created time.Time) *DtoHLRCache {
+	return &DtoHLRCache{
+		Unit_ID:     unit_id,
+		MobilePhone: mobilephone,
+		Status:      status,
+		Orn:         orn,
+		Roaming:     roaming,
+		Created:     created,
+	}
+}
@@ -13,6 +13,10 @@ const (
 	DATA_FORMAT_TXT
 	DATA_FORMAT_CSV
 	DATA_FORMAT_SSV
+	DATA_FORMAT_XLSX
+	DATA_FORMAT_ODS
+	DATA_FORMAT_JSON
+	DATA_FORMAT_NDJSON
 )
 
 const (
@@ -53,6 +57,13 @@ type ViewExportTable struct {
 	Type           string `json:"rows" validate:"min=1,max=255"` // Тип экспортируемых данных
 }
 
+// Строка импортируемых данных
+type DtoImportRow struct {
+	Position int64    // Позиция строки в таблице
+	Values   []string // Значения колонок
+	Wrong    bool     // Неверное число столбцов
+}
+
 type ApiMetaImportTable struct {
 	Formats   []ApiDataFormat   `json:"formats,omitempty" `   // Список форматов для загрузки данных
 	Encodings []ApiDataEncoding `json:"codepages,omitempty" ` // Список кодировок для загрузки данных
@@ -128,6 +139,14 @@ func NewApiImportColumn(id int64, name string, position int64) *ApiImportColumn
 	}
 }
 
+func NewDtoImportRow(position int64, values []string, wrong bool) *DtoImportRow {
+	return &DtoImportRow{
+		Position: position,
+		Values:   values,
+		Wrong:    wrong,
+	}
+}
+
 func NewApiMetaExportTable(formats []ApiDataFormat, url string) *ApiMetaExportTable {
 	return &ApiMetaExportTable{
 		Formats: formats,
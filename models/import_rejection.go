@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+const (
+	MAX_IMPORT_REJECTIONS = 10000
+)
+
+// Структура для организации хранения отклоненных при импорте строк
+type ApiImportRejection struct {
+	Line     int64  `json:"line" db:"line"`         // Номер строки в исходном файле
+	Reason   string `json:"reason" db:"reason"`     // Причина отклонения
+	Imported bool   `json:"imported" db:"imported"` // Строка загружена с пометкой о неверном числе столбцов
+}
+
+type DtoImportRejection struct {
+	ID                int64     `db:"id"`                // Уникальный идентификатор записи
+	Customer_Table_ID int64     `db:"customer_table_id"` // Идентификатор таблицы
+	Line              int64     `db:"line"`              // Номер строки в исходном файле
+	Reason            string    `db:"reason"`            // Причина отклонения
+	Imported          bool      `db:"imported"`          // Строка загружена с пометкой о неверном числе столбцов
+	Created           time.Time `db:"created"`           // Время создания
+}
+
+// Конструктор создания объекта отклоненной строки в api
+func NewApiImportRejection(line int64, reason string, imported bool) *ApiImportRejection {
+	return &ApiImportRejection{
+		Line:     line,
+		Reason:   reason,
+		Imported: imported,
+	}
+}
+
+// Конструктор создания объекта отклоненной строки в бд
+func NewDtoImportRejection(id int64, customer_table_id int64, line int64, reason string, imported bool, created time.Time) *DtoImportRejection {
+	return &DtoImportRejection{
+		ID:                id,
+		Customer_Table_ID: customer_table_id,
+		Line:              line,
+		Reason:            reason,
+		Imported:          imported,
+		Created:           created,
+	}
+}
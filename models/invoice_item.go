@@ -3,6 +3,7 @@ package models
 const (
 	INVOICE_ITEM_TYPE_ROUBLE  = "руб"
 	INVOICE_ITEM_NAME_DEFAULT = "Оплата по договору"
+	INVOICE_ITEM_NAME_SAVINGS = "Скидка за повторные и ранее проверенные номера"
 )
 
 // Структура для организации позиции счета
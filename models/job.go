@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+)
+
+type JobStatus int
+
+const (
+	JOB_STATUS_NEW JobStatus = iota + 1
+	JOB_STATUS_RUNNING
+	JOB_STATUS_DONE
+	JOB_STATUS_FAILED
+)
+
+// Структура для организации хранения задания на исполнение заказа
+type DtoJob struct {
+	Order_ID     int64     `db:"order_id"`     // Идентификатор заказа
+	Alias        string    `db:"alias"`        // Псевдоним услуги
+	Status       JobStatus `db:"status"`       // Статус задания
+	Attempts     int       `db:"attempts"`     // Количество попыток исполнения
+	Owner        string    `db:"owner"`        // Идентификатор обработчика, захватившего задание
+	Lease        string    `db:"lease"`        // Токен аренды задания
+	Lease_Till   time.Time `db:"lease_till"`   // Время окончания аренды задания
+	Available_At time.Time `db:"available_at"` // Время, с которого задание доступно для захвата
+	Error        string    `db:"error"`        // Описание последней ошибки
+	Created      time.Time `db:"created"`      // Время создания
+	Updated      time.Time `db:"updated"`      // Время последнего изменения
+}
+
+// Конструктор создания объекта задания в бд
+func NewDtoJob(order_id int64, alias string, status JobStatus, attempts int, owner string, lease string, lease_till time.Time,
+	available_at time.Time, error string, created time.Time, updated time.Time) *DtoJob {
+	return &DtoJob{
+		Order_ID:     order_id,
+		Alias:        alias,
+		Status:       status,
+		Attempts:     attempts,
+		Owner:        owner,
+		Lease:        lease,
+		Lease_Till:   lease_till,
+		Available_At: available_at,
+		Error:        error,
+		Created:      created,
+		Updated:      updated,
+	}
+}
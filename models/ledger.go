@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+)
+
+type LedgerHoldStatus int
+
+const (
+	LEDGER_HOLD_STATUS_ACTIVE LedgerHoldStatus = iota + 1
+	LEDGER_HOLD_STATUS_CAPTURED
+	LEDGER_HOLD_STATUS_RELEASED
+)
+
+// Структура для организации хранения счета объединения в книге учета
+type DtoLedgerAccount struct {
+	Unit_ID    int64     `db:"unit_id"`    // Идентификатор объединения
+	Balance    float64   `db:"balance"`    // Остаток средств
+	Held       float64   `db:"held"`       // Зарезервированные средства
+	Drift      float64   `db:"drift"`      // Расхождение остатка с операциями при последней сверке
+	Reconciled time.Time `db:"reconciled"` // Время последней сверки
+	Updated    time.Time `db:"updated"`    // Время последнего изменения
+}
+
+// Структура для организации хранения резерва средств под заказ
+type DtoLedgerHold struct {
+	ID       int64            `db:"id"`       // Уникальный идентификатор резерва
+	Unit_ID  int64            `db:"unit_id"`  // Идентификатор объединения
+	Order_ID int64            `db:"order_id"` // Идентификатор заказа
+	Amount   float64          `db:"amount"`   // Зарезервированная сумма
+	Captured float64          `db:"captured"` // Списанная сумма
+	Status   LedgerHoldStatus `db:"status"`   // Статус резерва
+	Created  time.Time        `db:"created"`  // Время создания
+	Updated  time.Time        `db:"updated"`  // Время последнего изменения
+}
+
+// Конструктор создания объекта счета в бд
+func NewDtoLedgerAccount(unit_id int64, balance float64, held float64, drift float64, reconciled time.Time, updated time.Time) *DtoLedgerAccount {
+	return &DtoLedgerAccount{
+		Unit_ID:    unit_id,
+		Balance:    balance,
+		Held:       held,
+		Drift:      drift,
+		Reconciled: reconciled,
+		Updated:    updated,
+	}
+}
+
+// Конструктор создания объекта резерва в бд
+func NewDtoLedgerHold(id int64, unit_id int64, order_id int64, amount float64, captured float64, status LedgerHoldStatus,
+	created time.Time, updated time.Time) *DtoLedgerHold {
+	return &DtoLedgerHold{
+		ID:       id,
+		Unit_ID:  unit_id,
+		Order_ID: order_id,
+		Amount:   amount,
+		Captured: captured,
+		Status:   status,
+		Created:  created,
+		Updated:  updated,
+	}
+}
+
+// Доступные для резервирования средства
+func (account *DtoLedgerAccount) Available() float64 {
+	return Round(account.Balance-account.Held, 0.5, 2)
+}
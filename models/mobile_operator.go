@@ -63,3 +63,12 @@ func NewDtoMobileOperator(id int, shortname string, longname string, created tim
 		HLRBillingModel: hlrbillingmodel,
 	}
 }
+
+func (billingmodel BillingModel) String() string {
+	switch billingmodel {
+	case BILLING_MODEL_CUMULATIVE_PERHEADER:
+		return "cumulativePerHeader"
+	default:
+		return "range"
+	}
+}
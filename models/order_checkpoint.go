@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+)
+
+// Структура для организации хранения контрольной точки исполнения заказа
+type DtoOrderCheckpoint struct {
+	Order_ID int64     `db:"order_id"` // Идентификатор заказа
+	Step     int       `db:"step"`     // Номер шага исполнения
+	Done     bool      `db:"done"`     // Шаг завершен
+	Data     string    `db:"data"`     // Результат шага, необходимый для продолжения исполнения
+	Created  time.Time `db:"created"`  // Время начала шага
+	Updated  time.Time `db:"updated"`  // Время последнего изменения
+}
+
+// Конструктор создания объекта контрольной точки исполнения заказа в бд
+func NewDtoOrderCheckpoint(order_id int64, step int, done bool, data string, created time.Time, updated time.Time) *DtoOrderCheckpoint {
+	return &DtoOrderCheckpoint{
+		Order_ID: order_id,
+		Step:     step,
+		Done:     done,
+		Data:     data,
+		Created:  created,
+		Updated:  updated,
+	}
+}
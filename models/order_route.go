@@ -0,0 +1,172 @@
+package models
+
+import (
+	"github.com/martini-contrib/binding"
+	"net/http"
+	"time"
+)
+
+type RoutingPolicy int
+
+const (
+	ROUTING_POLICY_MANUAL RoutingPolicy = iota + 1
+	ROUTING_POLICY_CHEAPEST
+	ROUTING_POLICY_RATING
+	ROUTING_POLICY_WEIGHTED
+)
+
+const (
+	ROUTING_POLICY_MANUAL_VALUE   = "manual"
+	ROUTING_POLICY_CHEAPEST_VALUE = "cheapest"
+	ROUTING_POLICY_RATING_VALUE   = "rating"
+	ROUTING_POLICY_WEIGHTED_VALUE = "weighted"
+)
+
+type OrderRouteStatus int
+
+const (
+	ORDER_ROUTE_STATUS_SENT OrderRouteStatus = iota + 1
+	ORDER_ROUTE_STATUS_FAILED
+)
+
+const (
+	ORDER_ROUTE_STATUS_SENT_VALUE   = "sent"
+	ORDER_ROUTE_STATUS_FAILED_VALUE = "failed"
+)
+
+// Структура для организации хранения маршрутизации заказа по поставщикам
+type ViewOrderRouting struct {
+	Policy string `json:"policy" validate:"regexp=^(manual|cheapest|rating|weighted)$"` // Политика выбора поставщика
+}
+
+type ApiOrderRouting struct {
+	Policy string          `json:"policy"` // Политика выбора поставщика
+	Routes []ApiOrderRoute `json:"routes"` // Отправки заказа поставщикам
+}
+
+type ApiOrderRoute struct {
+	Supplier_ID        int64     `json:"supplierId"`       // Идентификатор поставщика
+	Mobile_Operator_ID int       `json:"mobileOperatorId"` // Идентификатор мобильного оператора
+	Attempt            int       `json:"attempt"`          // Номер попытки отправки строк
+	Status             string    `json:"status"`           // Статус отправки
+	Recipients         int       `json:"recipients"`       // Количество получателей
+	Sent               int       `json:"sent"`             // Количество принятых поставщиком сообщений
+	Failed             int       `json:"failed"`           // Количество отклоненных поставщиком сообщений
+	Error              string    `json:"error,omitempty"`  // Ошибка отправки
+	Created            time.Time `json:"created"`          // Время отправки
+}
+
+type DtoOrderRouting struct {
+	Order_ID int64         `db:"order_id"` // Идентификатор заказа
+	Policy   RoutingPolicy `db:"policy"`   // Политика выбора поставщика
+	Created  time.Time     `db:"created"`  // Время создания
+	Updated  time.Time     `db:"updated"`  // Время последнего изменения
+}
+
+type DtoOrderRoute struct {
+	ID                 int64            `db:"id"`                 // Уникальный идентификатор отправки
+	Order_ID           int64            `db:"order_id"`           // Идентификатор заказа
+	Supplier_ID        int64            `db:"supplier_id"`        // Идентификатор поставщика
+	Mobile_Operator_ID int              `db:"mobile_operator_id"` // Идентификатор мобильного оператора
+	Attempt            int              `db:"attempt"`            // Номер попытки отправки строк
+	Status             OrderRouteStatus `db:"status"`             // Статус отправки
+	Recipients         int              `db:"recipients"`         // Количество получателей
+	Sent               int              `db:"sent"`               // Количество принятых поставщиком сообщений
+	Failed             int              `db:"failed"`             // Количество отклоненных поставщиком сообщений
+	Response           string           `db:"response"`           // Ответ поставщика
+	Error              string           `db:"error"`              // Ошибка отправки
+	Created            time.Time        `db:"created"`            // Время создания
+	Updated            time.Time        `db:"updated"`            // Время последнего изменения
+}
+
+// Конструктор создания объекта маршрутизации заказа в api
+func NewApiOrderRouting(policy string, routes []ApiOrderRoute) *ApiOrderRouting {
+	return &ApiOrderRouting{
+		Policy: policy,
+		Routes: routes,
+	}
+}
+
+func NewApiOrderRoute(supplier_id int64, mobile_operator_id int, attempt int, status string, recipients int, sent int, failed int,
+	err string, created time.Time) *ApiOrderRoute {
+	return &ApiOrderRoute{
+		Supplier_ID:        supplier_id,
+		Mobile_Operator_ID: mobile_operator_id,
+		Attempt:            attempt,
+		Status:             status,
+		Recipients:         recipients,
+		Sent:               sent,
+		Failed:             failed,
+		Error:              err,
+		Created:            created,
+	}
+}
+
+// Конструктор создания объекта маршрутизации заказа в бд
+func NewDtoOrderRouting(order_id int64, policy RoutingPolicy, created time.Time, updated time.Time) *DtoOrderRouting {
+	return &DtoOrderRouting{
+		Order_ID: order_id,
+		Policy:   policy,
+		Created:  created,
+		Updated:  updated,
+	}
+}
+
+func NewDtoOrderRoute(id int64, order_id int64, supplier_id int64, mobile_operator_id int, attempt int, status OrderRouteStatus,
+	recipients int, sent int, failed int, response string, err string, created time.Time, updated time.Time) *DtoOrderRoute {
+	return &DtoOrderRoute{
+		ID:                 id,
+		Order_ID:           order_id,
+		Supplier_ID:        supplier_id,
+		Mobile_Operator_ID: mobile_operator_id,
+		Attempt:            attempt,
+		Status:             status,
+		Recipients:         recipients,
+		Sent:               sent,
+		Failed:             failed,
+		Response:           response,
+		Error:              err,
+		Created:            created,
+		Updated:            updated,
+	}
+}
+
+// Разбор политики выбора поставщика
+func ParseRoutingPolicy(value string) (policy RoutingPolicy) {
+	switch value {
+	case ROUTING_POLICY_CHEAPEST_VALUE:
+		return ROUTING_POLICY_CHEAPEST
+	case ROUTING_POLICY_RATING_VALUE:
+		return ROUTING_POLICY_RATING
+	case ROUTING_POLICY_WEIGHTED_VALUE:
+		return ROUTING_POLICY_WEIGHTED
+	default:
+		return ROUTING_POLICY_MANUAL
+	}
+}
+
+func (policy RoutingPolicy) String() string {
+	switch policy {
+	case ROUTING_POLICY_CHEAPEST:
+		return ROUTING_POLICY_CHEAPEST_VALUE
+	case ROUTING_POLICY_RATING:
+		return ROUTING_POLICY_RATING_VALUE
+	case ROUTING_POLICY_WEIGHTED:
+		return ROUTING_POLICY_WEIGHTED_VALUE
+	default:
+		return ROUTING_POLICY_MANUAL_VALUE
+	}
+}
+
+func (status OrderRouteStatus) String() string {
+	switch status {
+	case ORDER_ROUTE_STATUS_FAILED:
+		return ORDER_ROUTE_STATUS_FAILED_VALUE
+	default:
+		return ORDER_ROUTE_STATUS_SENT_VALUE
+	}
+}
+
+func (routing *ViewOrderRouting) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(routing, errors, req)
+}
@@ -4,6 +4,12 @@ import (
 	"time"
 )
 
+const (
+	PERIOD_DAILY = iota + 1
+	PERIOD_WEEKLY
+	PERIOD_MONTHLY
+)
+
 // Структура для организации хранения периода
 type ApiPeriod struct {
 	ID       int    `json:"id" db:"id"`             // Уникальный идентификатор периода
@@ -0,0 +1,146 @@
+package models
+
+const (
+	QUOTE_REASON_WRONG_MOBILE_PHONE      = "wrongMobilePhone"
+	QUOTE_REASON_UNKNOWN_MOBILE_OPERATOR = "unknownMobileOperator"
+	QUOTE_REASON_NOT_HLR_MOBILE_OPERATOR = "notHLRMobileOperator"
+	QUOTE_REASON_MISSED_PRICE            = "missedPrice"
+	QUOTE_REASON_SUPPRESSED              = "suppressed"
+	QUOTE_REASON_DUPLICATE               = "duplicate"
+	QUOTE_REASON_CACHED                  = "cached"
+)
+
+// Структура для организации хранения предварительного расчета стоимости заказа
+type ApiOrderQuote struct {
+	Service    string         `json:"service"`    // Псевдоним услуги
+	Rows       int64          `json:"rows"`       // Число строк таблицы
+	Units      int64          `json:"units"`      // Число тарифицированных единиц
+	Cost       float64        `json:"cost"`       // Стоимость заказа
+	VATRate    byte           `json:"vatRate"`    // Ставка НДС компании
+	VAT        float64        `json:"vat"`        // НДС в стоимости заказа
+	Items      []ApiQuoteItem `json:"items"`      // Позиции расчета
+	Unpriced   []ApiQuoteRow  `json:"unpriced"`   // Строки, стоимость которых не удалось определить
+	Suppressed []ApiQuoteRow  `json:"suppressed"` // Строки получателей из списков запрета рассылок
+	Duplicates []ApiQuoteRow  `json:"duplicates"` // Строки с номерами, повторяющимися в заказе
+	Cached     []ApiQuoteRow  `json:"cached"`     // Строки с номерами, результат по которым получен ранее
+	Savings    float64        `json:"savings"`    // Экономия за счет повторных и ранее проверенных номеров
+}
+
+type ApiQuoteItem struct {
+	Mobile_Operator_ID int      `json:"mobileOperatorId,omitempty"` // Идентификатор мобильного оператора
+	MobileOperator     string   `json:"mobileOperator,omitempty"`   // Название мобильного оператора
+	Sender             string   `json:"sender,omitempty"`           // Имя отправителя
+	Product_ID         int      `json:"productId,omitempty"`        // Идентификатор позиции прайса
+	BillingModel       string   `json:"billingModel,omitempty"`     // Модель биллинга мобильного оператора
+	AmountRange        ApiRange `json:"range"`                      // Диапазон количества прайса
+	Count              int      `json:"count"`                      // Количество
+	Price              float64  `json:"price"`                      // Цена за единицу
+	Increase           float64  `json:"increase,omitempty"`         // Наценка
+	Cost               float64  `json:"cost"`                       // Стоимость позиции
+}
+
+type ApiQuoteRow struct {
+	ID     int64  `json:"id"`     // Идентификатор строки таблицы
+	Value  string `json:"value"`  // Значение, по которому не удалось определить стоимость
+	Reason string `json:"reason"` // Причина
+}
+
+// Получатель заказа, тарифицируемый по мобильному оператору
+type DtoQuoteRecipient struct {
+	Row_ID      int64  // Идентификатор строки таблицы
+	Value       string // Номер мобильного телефона из таблицы
+	MobilePhone uint64 // Проверенный номер мобильного телефона
+	Sender      string // Имя отправителя
+	Units       int    // Число тарифицируемых единиц
+	Reason      string // Причина, по которой получатель не тарифицируется
+}
+
+// Конструктор создания объекта предварительного расчета в api
+func NewApiOrderQuote(service string, rows int64) *ApiOrderQuote {
+	return &ApiOrderQuote{
+		Service:    service,
+		Rows:       rows,
+		Items:      []ApiQuoteItem{},
+		Unpriced:   []ApiQuoteRow{},
+		Suppressed: []ApiQuoteRow{},
+		Duplicates: []ApiQuoteRow{},
+		Cached:     []ApiQuoteRow{},
+	}
+}
+
+func NewApiQuoteItem(mobile_operator_id int, mobileoperator string, sender string, product_id int, billingmodel string,
+	amountrange ApiRange, count int, price float64, increase float64, cost float64) *ApiQuoteItem {
+	return &ApiQuoteItem{
+		Mobile_Operator_ID: mobile_operator_id,
+		MobileOperator:     mobileoperator,
+		Sender:             sender,
+		Product_ID:         product_id,
+		BillingModel:       billingmodel,
+		AmountRange:        amountrange,
+		Count:              count,
+		Price:              price,
+		Increase:           increase,
+		Cost:               cost,
+	}
+}
+
+func NewApiQuoteRow(id int64, value string, reason string) *ApiQuoteRow {
+	return &ApiQuoteRow{
+		ID:     id,
+		Value:  value,
+		Reason: reason,
+	}
+}
+
+func NewDtoQuoteRecipient(row_id int64, value string, mobilephone uint64, sender string, units int, reason string) *DtoQuoteRecipient {
+	return &DtoQuoteRecipient{
+		Row_ID:      row_id,
+		Value:       value,
+		MobilePhone: mobilephone,
+		Sender:      sender,
+		Units:       units,
+		Reason:      reason,
+	}
+}
+
+// Добавление позиции расчета с пересчетом стоимости
+func (quote *ApiOrderQuote) AddItem(item *ApiQuoteItem) {
+	quote.Items = append(quote.Items, *item)
+	quote.Units += int64(item.Count)
+	quote.Cost += item.Cost
+}
+
+// Применение наценки ко всей рассчитанной стоимости
+func (quote *ApiOrderQuote) AddIncrease(product_id int, increase float64) {
+	cost := quote.Cost * increase
+	quote.Items = append(quote.Items, *NewApiQuoteItem(0, "", "", product_id, "", ApiRange{}, 0, 0, increase, cost-quote.Cost))
+	quote.Cost = cost
+}
+
+func (quote *ApiOrderQuote) AddUnpriced(id int64, value string, reason string) {
+	quote.Unpriced = append(quote.Unpriced, *NewApiQuoteRow(id, value, reason))
+}
+
+// Строка получателя из списка запрета рассылок не тарифицируется и не отправляется
+func (quote *ApiOrderQuote) AddSuppressed(id int64, value string) {
+	quote.Suppressed = append(quote.Suppressed, *NewApiQuoteRow(id, value, QUOTE_REASON_SUPPRESSED))
+}
+
+// Строка с повторяющимся или ранее проверенным номером не тарифицируется, результат берется из другой строки или кэша
+func (quote *ApiOrderQuote) AddDuplicate(id int64, value string) {
+	quote.Duplicates = append(quote.Duplicates, *NewApiQuoteRow(id, value, QUOTE_REASON_DUPLICATE))
+}
+
+func (quote *ApiOrderQuote) AddCached(id int64, value string) {
+	quote.Cached = append(quote.Cached, *NewApiQuoteRow(id, value, QUOTE_REASON_CACHED))
+}
+
+// Расчет НДС, включенного в стоимость, по ставке компании
+func (quote *ApiOrderQuote) SetVAT(vatrate byte) {
+	quote.Cost = Round(quote.Cost, 0.5, 2)
+	quote.VATRate = vatrate
+	quote.VAT = 0
+	if vatrate != 0 {
+		quote.VAT = Round((quote.Cost/(1+float64(vatrate)/100))*float64(vatrate)/100, 0.5, 2)
+	}
+}
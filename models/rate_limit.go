@@ -0,0 +1,60 @@
+package models
+
+import (
+	"math"
+	"time"
+)
+
+// Корзина маркеров ограничения частоты запросов
+type DtoRateLimit struct {
+	Bucket  string    `db:"bucket"`  // Ключ корзины
+	Tokens  float64   `db:"tokens"`  // Количество маркеров на момент последнего запроса
+	Updated time.Time `db:"updated"` // Время последнего запроса
+	Expires time.Time `db:"expires"` // Время полного пополнения корзины, после которого корзина удаляется
+}
+
+// Конструктор создания объекта корзины в бд
+func NewDtoRateLimit(bucket string, tokens float64, updated time.Time, expires time.Time) *DtoRateLimit {
+	return &DtoRateLimit{
+		Bucket:  bucket,
+		Tokens:  tokens,
+		Updated: updated,
+		Expires: expires,
+	}
+}
+
+// Пополнение корзины на момент запроса и списание одного маркера, если он есть
+func (ratelimit *DtoRateLimit) Take(rate float64, burst int64, now time.Time) (allowed bool) {
+	if now.After(ratelimit.Updated) {
+		ratelimit.Tokens = math.Min(float64(burst), ratelimit.Tokens+now.Sub(ratelimit.Updated).Seconds()*rate)
+		ratelimit.Updated = now
+	}
+	if ratelimit.Tokens >= 1 {
+		ratelimit.Tokens--
+		allowed = true
+	}
+	ratelimit.Expires = ratelimit.Updated.Add(ratelimit.Reset(rate, burst))
+
+	return allowed
+}
+
+// Количество целых маркеров в корзине
+func (ratelimit *DtoRateLimit) Remaining() int64 {
+	return int64(math.Floor(ratelimit.Tokens))
+}
+
+// Время до появления следующего маркера
+func (ratelimit *DtoRateLimit) RetryAfter(rate float64) time.Duration {
+	if ratelimit.Tokens >= 1 {
+		return 0
+	}
+	return time.Duration((1 - ratelimit.Tokens) / rate * float64(time.Second))
+}
+
+// Время до полного пополнения корзины
+func (ratelimit *DtoRateLimit) Reset(rate float64, burst int64) time.Duration {
+	if ratelimit.Tokens >= float64(burst) {
+		return 0
+	}
+	return time.Duration((float64(burst) - ratelimit.Tokens) / rate * float64(time.Second))
+}
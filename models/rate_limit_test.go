@@ -0,0 +1,80 @@
+package models
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNewDtoRateLimit(t *testing.T) {
+	var bucket = "127.0.0.1"
+	var tokens = 5.0
+	var updated = time.Now()
+	var expires = updated.Add(time.Minute)
+	var dtoRateLimit *DtoRateLimit
+
+	dtoRateLimit = NewDtoRateLimit(bucket, tokens, updated, expires)
+	if dtoRateLimit.Bucket != bucket {
+		t.Error("Bucket field is not properly initialized")
+	}
+	if dtoRateLimit.Tokens != tokens {
+		t.Error("Tokens field is not properly initialized")
+	}
+	if dtoRateLimit.Updated != updated {
+		t.Error("Updated field is not properly initialized")
+	}
+	if dtoRateLimit.Expires != expires {
+		t.Error("Expires field is not properly initialized")
+	}
+}
+
+func TestDtoRateLimitTake(t *testing.T) {
+	var rate = 1.0
+	var burst int64 = 2
+	var now = time.Now()
+	var dtoRateLimit = NewDtoRateLimit("127.0.0.1", float64(burst), now, now)
+
+	var cases = []struct {
+		elapsed   time.Duration
+		allowed   bool
+		remaining int64
+	}{
+		{0, true, 1},
+		{0, true, 0},
+		{0, false, 0},
+		{500 * time.Millisecond, false, 0},
+		{time.Second, true, 0},
+		{time.Hour, true, 1},
+		{-time.Minute, true, 0},
+		{-time.Minute, false, 0},
+	}
+
+	for index, c := range cases {
+		allowed := dtoRateLimit.Take(rate, burst, now.Add(c.elapsed))
+		if allowed != c.allowed {
+			t.Error("Request", index, "is not properly limited")
+		}
+		if dtoRateLimit.Remaining() != c.remaining {
+			t.Error("Remaining tokens of request", index, "are not properly calculated", dtoRateLimit.Tokens)
+		}
+		if !dtoRateLimit.Expires.Equal(dtoRateLimit.Updated.Add(dtoRateLimit.Reset(rate, burst))) {
+			t.Error("Expires field of request", index, "is not properly updated")
+		}
+	}
+	if dtoRateLimit.Updated != now.Add(time.Hour) {
+		t.Error("Updated field should not move back in time")
+	}
+}
+
+func TestDtoRateLimitRetryAfter(t *testing.T) {
+	var dtoRateLimit = NewDtoRateLimit("127.0.0.1", 0.5, time.Now(), time.Now())
+	if value := dtoRateLimit.RetryAfter(2); value != 250*time.Millisecond {
+		t.Error("Retry after is not properly calculated", value)
+	}
+	if value := dtoRateLimit.Reset(2, 10); value != 4750*time.Millisecond {
+		t.Error("Reset is not properly calculated", value)
+	}
+	dtoRateLimit.Tokens = 1
+	if value := dtoRateLimit.RetryAfter(2); value != 0 {
+		t.Error("Retry after of available token is not properly calculated", value)
+	}
+}
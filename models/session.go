@@ -13,23 +13,44 @@ type ViewSession struct {
 	CaptchaValue string `json:"captchaValue" validate:"max=255"`                          // Значение капчи
 	CaptchaHash  string `json:"captchaHash" validate:"max=255"`                           // Хэш капчи
 	Language     string `json:"language" validate:"max=10"`                               // Язык пользователя
+	DeviceToken  string `json:"deviceToken" validate:"max=255"`                           // Токен доверенного устройства, входящего без второго фактора
+	Device       string `json:"device" validate:"max=255"`                                // Название устройства входа
 }
 
 type ApiSession struct {
-	Timeout     time.Time `json:"timeout"`      // Таймаут сессии
-	AccessToken string    `json:"access-token"` // Токен доступа сессии
+	Timeout     time.Time `json:"timeout"`               // Таймаут сессии
+	AccessToken string    `json:"access-token"`          // Токен доступа сессии
+	DeviceToken string    `json:"deviceToken,omitempty"` // Токен устройства, отмеченного доверенным при вводе второго фактора
 }
 
 type ApiSessionToken struct {
 	Token string `json:"token"` // Токен доступа сессии
 }
 
+// Информация о сессии пользователя для списка мест входа
+type ApiSessionInfo struct {
+	ID           string    `json:"id"`           // Идентификатор сессии, хэш токена
+	Current      bool      `json:"current"`      // Сессия текущего запроса
+	IPAddress    string    `json:"ipAddress"`    // IP адрес входа
+	UserAgent    string    `json:"userAgent"`    // User Agent входа
+	Device       string    `json:"device"`       // Устройство входа
+	Created      time.Time `json:"created"`      // Время входа
+	LastActivity time.Time `json:"lastActivity"` // Время последнего использования сессии
+	Expires      time.Time `json:"expires"`      // Время окончания действия сессии
+}
+
 type DtoSession struct {
-	AccessToken  string     `db:"token"`        // Ключ сессии
+	AccessToken  string     `db:"-"`            // Ключ сессии, известен только при создании сессии и в запросе
+	Hash         string     `db:"token"`        // Хэш ключа сессии
 	UserID       int64      `db:"user_id"`      // Идентификатор пользователя сессии
 	Roles        []UserRole `db:"-"`            // Массив значений уровней доступа пользователя сессии
 	LastActivity time.Time  `db:"lastActivity"` // Время последнего использования сессии
 	Language     string     `db:"language"`     // Язык пользователя сессии
+	Created      time.Time  `db:"created"`      // Время создания сессии
+	IP_Address   string     `db:"ip_address"`   // IP адрес создания сессии
+	User_Agent   string     `db:"user_agent"`   // User Agent создания сессии
+	Device       string     `db:"device"`       // Устройство создания сессии
+	ApiKeyID     int64      `db:"-"`            // Идентификатор ключа доступа, если запрос выполнен по ключу
 }
 
 // Конструктор создания объекта сессии в api
@@ -40,6 +61,20 @@ func NewApiSession(timeout time.Time, accesstoken string) *ApiSession {
 	}
 }
 
+func NewApiSessionInfo(id string, current bool, ipaddress string, useragent string, device string, created time.Time,
+	lastactivity time.Time, expires time.Time) *ApiSessionInfo {
+	return &ApiSessionInfo{
+		ID:           id,
+		Current:      current,
+		IPAddress:    ipaddress,
+		UserAgent:    useragent,
+		Device:       device,
+		Created:      created,
+		LastActivity: lastactivity,
+		Expires:      expires,
+	}
+}
+
 func NewApiSessionToken(token string) *ApiSessionToken {
 	return &ApiSessionToken{
 		Token: token,
@@ -54,6 +89,7 @@ func NewDtoSession(accesstoken string, userid int64, roles []UserRole, lastactiv
 		Roles:        roles,
 		LastActivity: lastactivity,
 		Language:     language,
+		Created:      lastactivity,
 	}
 }
 
@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+)
+
+type SMSBatchStatus int
+
+const (
+	SMS_BATCH_STATUS_NEW SMSBatchStatus = iota + 1
+	SMS_BATCH_STATUS_SENDING
+	SMS_BATCH_STATUS_SENT
+)
+
+const (
+	SMS_BATCH_STATUS_NEW_VALUE     = "waiting"
+	SMS_BATCH_STATUS_SENDING_VALUE = "sending"
+	SMS_BATCH_STATUS_SENT_VALUE    = "sent"
+)
+
+// Структура для организации хранения пакета sms рассылки по часовому поясу получателей
+type ApiSMSBatch struct {
+	Timezone   string    `json:"timezone"`   // Часовой пояс
+	Send_At    time.Time `json:"sendAt"`     // Время отправки пакета
+	Sent_At    time.Time `json:"sentAt"`     // Время фактической отправки пакета
+	Status     string    `json:"status"`     // Статус пакета
+	Recipients int       `json:"recipients"` // Количество получателей
+	Sent       int       `json:"sent"`       // Количество принятых поставщиком сообщений
+	Failed     int       `json:"failed"`     // Количество отклоненных поставщиком сообщений
+}
+
+type DtoSMSBatch struct {
+	Order_ID   int64          `db:"order_id"`   // Идентификатор заказа
+	Offset     int            `db:"offset"`     // Смещение часового пояса от UTC в минутах
+	Send_At    time.Time      `db:"send_at"`    // Время отправки пакета
+	Sent_At    time.Time      `db:"sent_at"`    // Время фактической отправки пакета
+	Status     SMSBatchStatus `db:"status"`     // Статус пакета
+	Recipients int            `db:"recipients"` // Количество получателей
+	Rows       string         `db:"rows"`       // Номера строк таблицы данных, входящих в пакет
+	Response   string         `db:"response"`   // Ответ поставщика
+	Sent       int            `db:"sent"`       // Количество принятых поставщиком сообщений
+	Failed     int            `db:"failed"`     // Количество отклоненных поставщиком сообщений
+	Created    time.Time      `db:"created"`    // Время создания
+	Updated    time.Time      `db:"updated"`    // Время последнего изменения
+}
+
+// Конструктор создания объекта пакета sms рассылки в api
+func NewApiSMSBatch(timezone string, send_at time.Time, sent_at time.Time, status string, recipients int, sent int, failed int) *ApiSMSBatch {
+	return &ApiSMSBatch{
+		Timezone:   timezone,
+		Send_At:    send_at,
+		Sent_At:    sent_at,
+		Status:     status,
+		Recipients: recipients,
+		Sent:       sent,
+		Failed:     failed,
+	}
+}
+
+// Конструктор создания объекта пакета sms рассылки в бд
+func NewDtoSMSBatch(order_id int64, offset int, send_at time.Time, sent_at time.Time, status SMSBatchStatus, recipients int,
+	rows string, response string, sent int, failed int, created time.Time, updated time.Time) *DtoSMSBatch {
+	return &DtoSMSBatch{
+		Order_ID:   order_id,
+		Offset:     offset,
+		Send_At:    send_at,
+		Sent_At:    sent_at,
+		Status:     status,
+		Recipients: recipients,
+		Rows:       rows,
+		Response:   response,
+		Sent:       sent,
+		Failed:     failed,
+		Created:    created,
+		Updated:    updated,
+	}
+}
+
+func (status SMSBatchStatus) String() string {
+	switch status {
+	case SMS_BATCH_STATUS_SENDING:
+		return SMS_BATCH_STATUS_SENDING_VALUE
+	case SMS_BATCH_STATUS_SENT:
+		return SMS_BATCH_STATUS_SENT_VALUE
+	default:
+		return SMS_BATCH_STATUS_NEW_VALUE
+	}
+}
@@ -0,0 +1,71 @@
+package models
+
+const (
+	SMS_ENCODING_GSM7 = "gsm7"
+	SMS_ENCODING_UCS2 = "ucs2"
+)
+
+// Структура для организации хранения предварительного просмотра sms рассылки
+type ApiSMSPreview struct {
+	Messages  int                `json:"messages"`  // Количество сообщений
+	Segments  int                `json:"segments"`  // Количество частей сообщений
+	GSM7      int                `json:"gsm7"`      // Количество сообщений в кодировке GSM-7
+	UCS2      int                `json:"ucs2"`      // Количество сообщений в кодировке UCS-2
+	Samples   []ApiSMSSample     `json:"samples"`   // Примеры сообщений
+	Histogram []ApiSMSSegmentBin `json:"histogram"` // Распределение сообщений по количеству частей
+}
+
+type ApiSMSSample struct {
+	Row_ID   int64  `json:"rowId"`    // Идентификатор строки таблицы
+	Message  string `json:"message"`  // Текст сообщения
+	Encoding string `json:"encoding"` // Кодировка сообщения
+	Length   int    `json:"length"`   // Длина сообщения в символах кодировки
+	Segments int    `json:"segments"` // Количество частей сообщения
+}
+
+type ApiSMSSegmentBin struct {
+	Segments int `json:"segments"` // Количество частей сообщения
+	Messages int `json:"messages"` // Количество сообщений
+}
+
+// Конструктор создания объекта предварительного просмотра sms рассылки в api
+func NewApiSMSPreview() *ApiSMSPreview {
+	return &ApiSMSPreview{
+		Samples:   []ApiSMSSample{},
+		Histogram: []ApiSMSSegmentBin{},
+	}
+}
+
+func NewApiSMSSample(row_id int64, message string, encoding string, length int, segments int) *ApiSMSSample {
+	return &ApiSMSSample{
+		Row_ID:   row_id,
+		Message:  message,
+		Encoding: encoding,
+		Length:   length,
+		Segments: segments,
+	}
+}
+
+// Учет сообщения в итогах и распределении по количеству частей
+func (preview *ApiSMSPreview) AddMessage(encoding string, segments int) {
+	preview.Messages++
+	preview.Segments += segments
+	if encoding == SMS_ENCODING_UCS2 {
+		preview.UCS2++
+	} else {
+		preview.GSM7++
+	}
+	for index := range preview.Histogram {
+		if preview.Histogram[index].Segments == segments {
+			preview.Histogram[index].Messages++
+			return
+		}
+	}
+	index := len(preview.Histogram)
+	for index > 0 && preview.Histogram[index-1].Segments > segments {
+		index--
+	}
+	preview.Histogram = append(preview.Histogram, ApiSMSSegmentBin{})
+	copy(preview.Histogram[index+1:], preview.Histogram[index:])
+	preview.Histogram[index] = ApiSMSSegmentBin{Segments: segments, Messages: 1}
+}
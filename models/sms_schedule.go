@@ -0,0 +1,87 @@
+package models
+
+import (
+	"github.com/martini-contrib/binding"
+	"net/http"
+	"time"
+)
+
+type CatchUpPolicy int
+
+const (
+	CATCHUP_POLICY_SKIP CatchUpPolicy = iota + 1
+	CATCHUP_POLICY_ONCE
+	CATCHUP_POLICY_ALL
+)
+
+const (
+	CATCHUP_POLICY_SKIP_VALUE = "skip"
+	CATCHUP_POLICY_ONCE_VALUE = "once"
+	CATCHUP_POLICY_ALL_VALUE  = "all"
+)
+
+// Структура для организации хранения расписания sms рассылки
+type ViewSMSSchedule struct {
+	Paused bool `json:"paused"` // Рассылка приостановлена
+}
+
+type ApiSMSSchedule struct {
+	Next_Run time.Time `json:"nextRun" db:"next_run"`  // Время следующей рассылки
+	Last_Run time.Time `json:"lastRun" db:"last_run"`  // Время последней рассылки
+	Runs     int       `json:"runs" db:"runs"`         // Количество выполненных рассылок
+	Paused   bool      `json:"paused" db:"paused"`     // Рассылка приостановлена
+	Finished bool      `json:"finished" db:"finished"` // Рассылка завершена
+}
+
+type DtoSMSSchedule struct {
+	Order_ID int64     `db:"order_id"` // Идентификатор заказа
+	Next_Run time.Time `db:"next_run"` // Время следующей рассылки
+	Last_Run time.Time `db:"last_run"` // Время последней рассылки
+	Runs     int       `db:"runs"`     // Количество выполненных рассылок
+	Paused   bool      `db:"paused"`   // Рассылка приостановлена
+	Finished bool      `db:"finished"` // Рассылка завершена
+	Created  time.Time `db:"created"`  // Время создания
+	Updated  time.Time `db:"updated"`  // Время последнего изменения
+}
+
+// Конструктор создания объекта расписания sms рассылки в api
+func NewApiSMSSchedule(next_run time.Time, last_run time.Time, runs int, paused bool, finished bool) *ApiSMSSchedule {
+	return &ApiSMSSchedule{
+		Next_Run: next_run,
+		Last_Run: last_run,
+		Runs:     runs,
+		Paused:   paused,
+		Finished: finished,
+	}
+}
+
+// Конструктор создания объекта расписания sms рассылки в бд
+func NewDtoSMSSchedule(order_id int64, next_run time.Time, last_run time.Time, runs int, paused bool, finished bool,
+	created time.Time, updated time.Time) *DtoSMSSchedule {
+	return &DtoSMSSchedule{
+		Order_ID: order_id,
+		Next_Run: next_run,
+		Last_Run: last_run,
+		Runs:     runs,
+		Paused:   paused,
+		Finished: finished,
+		Created:  created,
+		Updated:  updated,
+	}
+}
+
+// Разбор политики обработки пропущенных рассылок
+func ParseCatchUpPolicy(value string) (policy CatchUpPolicy) {
+	switch value {
+	case CATCHUP_POLICY_ONCE_VALUE:
+		return CATCHUP_POLICY_ONCE
+	case CATCHUP_POLICY_ALL_VALUE:
+		return CATCHUP_POLICY_ALL
+	default:
+		return CATCHUP_POLICY_SKIP
+	}
+}
+
+func (schedule *ViewSMSSchedule) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(schedule, errors, req)
+}
@@ -0,0 +1,129 @@
+package models
+
+import (
+	"github.com/martini-contrib/binding"
+	"net/http"
+	"time"
+)
+
+type SuppressionSource int
+
+const (
+	SUPPRESSION_SOURCE_MANUAL SuppressionSource = iota + 1
+	SUPPRESSION_SOURCE_IMPORT
+	SUPPRESSION_SOURCE_STOP
+)
+
+const (
+	SUPPRESSION_SOURCE_MANUAL_VALUE = "manual"
+	SUPPRESSION_SOURCE_IMPORT_VALUE = "import"
+	SUPPRESSION_SOURCE_STOP_VALUE   = "stop"
+
+	SUPPRESSION_REASON_STOP = "STOP"
+	SUPPRESSION_ERROR       = "Recipient suppressed"
+)
+
+// Структура для организации хранения номера в списке запрета рассылок
+type ViewSuppression struct {
+	MobilePhone string `json:"mobilePhone" validate:"min=1,max=50"` // Номер мобильного телефона
+	Reason      string `json:"reason" validate:"max=255"`           // Причина запрета
+}
+
+type ViewImportSuppressions struct {
+	Table_ID  int64  `json:"tableId" validate:"nonzero"`  // Идентификатор пользовательской таблицы
+	Column_ID int64  `json:"columnId" validate:"nonzero"` // Идентификатор колонки с номерами мобильных телефонов
+	Reason    string `json:"reason" validate:"max=255"`   // Причина запрета
+}
+
+type ViewSMSReply struct {
+	Sender      string `json:"sender" validate:"min=1,max=255"`     // Имя отправителя, на которое получен ответ
+	MobilePhone string `json:"mobilePhone" validate:"min=1,max=50"` // Номер мобильного телефона абонента
+	Message     string `json:"message" validate:"max=1000"`         // Текст ответа абонента
+}
+
+type ApiSuppression struct {
+	ID          int64     `json:"id"`          // Уникальный идентификатор записи
+	MobilePhone uint64    `json:"mobilePhone"` // Номер мобильного телефона
+	Reason      string    `json:"reason"`      // Причина запрета
+	Source      string    `json:"source"`      // Источник записи
+	Created     time.Time `json:"created"`     // Время создания
+}
+
+type ApiSuppressionImport struct {
+	Added    int `json:"added"`    // Количество добавленных номеров
+	Rejected int `json:"rejected"` // Количество строк с неверными номерами
+}
+
+type ApiSMSReply struct {
+	Suppressed bool    `json:"suppressed"` // Номер добавлен в списки запрета рассылок
+	Units      []int64 `json:"units"`      // Идентификаторы объединений, в списки которых добавлен номер
+}
+
+type DtoSuppression struct {
+	ID          int64             `db:"id"`          // Уникальный идентификатор записи
+	Unit_ID     int64             `db:"unit_id"`     // Идентификатор объединения, 0 - общий список
+	MobilePhone uint64            `db:"mobilephone"` // Номер мобильного телефона
+	Reason      string            `db:"reason"`      // Причина запрета
+	Source      SuppressionSource `db:"source"`      // Источник записи
+	Created     time.Time         `db:"created"`     // Время создания
+}
+
+// Конструктор создания объекта записи списка запрета рассылок в api
+func NewApiSuppression(id int64, mobilephone uint64, reason string, source string, created time.Time) *ApiSuppression {
+	return &ApiSuppression{
+		ID:          id,
+		MobilePhone: mobilephone,
+		Reason:      reason,
+		Source:      source,
+		Created:     created,
+	}
+}
+
+func NewApiSuppressionImport(added int, rejected int) *ApiSuppressionImport {
+	return &ApiSuppressionImport{
+		Added:    added,
+		Rejected: rejected,
+	}
+}
+
+func NewApiSMSReply(suppressed bool, units []int64) *ApiSMSReply {
+	return &ApiSMSReply{
+		Suppressed: suppressed,
+		Units:      units,
+	}
+}
+
+// Конструктор создания объекта записи списка запрета рассылок в бд
+func NewDtoSuppression(id int64, unit_id int64, mobilephone uint64, reason string, source SuppressionSource, created time.Time) *DtoSuppression {
+	return &DtoSuppression{
+		ID:          id,
+		Unit_ID:     unit_id,
+		MobilePhone: mobilephone,
+		Reason:      reason,
+		Source:      source,
+		Created:     created,
+	}
+}
+
+func (source SuppressionSource) String() string {
+	switch source {
+	case SUPPRESSION_SOURCE_IMPORT:
+		return SUPPRESSION_SOURCE_IMPORT_VALUE
+	case SUPPRESSION_SOURCE_STOP:
+		return SUPPRESSION_SOURCE_STOP_VALUE
+	default:
+		return SUPPRESSION_SOURCE_MANUAL_VALUE
+	}
+}
+
+func (suppression *ViewSuppression) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(suppression, errors, req)
+}
+
+func (suppressions *ViewImportSuppressions) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(suppressions, errors, req)
+}
+
+func (reply *ViewSMSReply) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(reply, errors, req)
+}
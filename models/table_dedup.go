@@ -0,0 +1,138 @@
+package models
+
+import (
+	"github.com/martini-contrib/binding"
+	"net/http"
+	"time"
+)
+
+const (
+	// Шаги поиска и объединения дубликатов
+	TABLE_DEDUP_STEP_SEARCH = 1
+	TABLE_DEDUP_STEP_MERGE  = 2
+
+	// Правила выбора значения колонки при объединении строк группы
+	MERGE_RULE_FIRST    = "first"    // Значение первой строки
+	MERGE_RULE_LAST     = "last"     // Значение последней строки
+	MERGE_RULE_NONEMPTY = "nonempty" // Первое непустое значение
+	MERGE_RULE_LONGEST  = "longest"  // Самое длинное значение
+	MERGE_RULE_FREQUENT = "frequent" // Наиболее частое непустое значение
+
+	TABLE_DEDUP_MAX_DISTANCE = 10
+)
+
+// Структура для организации хранения поиска дубликатов в пользовательской таблице
+type ViewTableDedup struct {
+	Columns []ViewDedupColumn `json:"columns" validate:"min=1"` // Колонки, по которым сравниваются строки
+}
+
+type ViewDedupColumn struct {
+	Table_Column_ID int64 `json:"columnId" validate:"nonzero"` // Идентификатор колонки таблицы
+	Fuzzy           bool  `json:"fuzzy"`                       // Нечеткое сравнение
+	Distance        int   `json:"distance" validate:"min=0"`   // Допустимое расстояние Левенштейна для нечеткого сравнения
+}
+
+type ViewTableMerge struct {
+	Deactivate bool            `json:"deactivate"` // Только отключение лишних строк группы без переноса значений
+	Rules      []ViewMergeRule `json:"rules"`      // Правила выбора значений колонок, остальные колонки берутся из первой строки
+}
+
+type ViewMergeRule struct {
+	Table_Column_ID int64  `json:"columnId" validate:"nonzero"`                                             // Идентификатор колонки таблицы
+	Rule            string `json:"rule" validate:"nonzero,regexp=^(first|last|nonempty|longest|frequent)$"` // Правило выбора значения
+}
+
+type ApiTableDedup struct {
+	ID               int64     `json:"id"`               // Уникальный идентификатор поиска дубликатов
+	Step             byte      `json:"step"`             // Текущий шаг: поиск или объединение
+	Ready            bool      `json:"ready"`            // Шаг завершен
+	Percentage       byte      `json:"percent"`          // Процент готовности шага
+	Groups           int64     `json:"groups"`           // Количество групп дубликатов
+	Duplicates       int64     `json:"duplicates"`       // Количество лишних строк в группах
+	Error            bool      `json:"error"`            // Ошибка
+	ErrorDescription string    `json:"errorDescription"` // Описание ошибки
+	Created          time.Time `json:"created"`          // Время запуска поиска
+	Completed        time.Time `json:"completed"`        // Время завершения шага
+}
+
+// Группа строк, признанных дубликатами. Первая строка группы сохраняется при объединении
+type ApiDedupGroup struct {
+	Group int64             `json:"group"` // Номер группы
+	Rows  []ApiInfoTableRow `json:"rows"`  // Строки группы в порядке расположения в таблице
+}
+
+type DtoTableDedup struct {
+	ID                int64     `db:"id"`                // Уникальный идентификатор поиска дубликатов
+	Customer_Table_ID int64     `db:"customer_table_id"` // Идентификатор пользовательской таблицы
+	Step              byte      `db:"step"`              // Текущий шаг
+	Ready             bool      `db:"ready"`             // Шаг завершен
+	Percentage        byte      `db:"percentage"`        // Процент готовности шага
+	Groups            int64     `db:"groups"`            // Количество групп дубликатов
+	Duplicates        int64     `db:"duplicates"`        // Количество лишних строк в группах
+	Error             bool      `db:"error"`             // Ошибка
+	ErrorDescription  string    `db:"error_description"` // Описание ошибки
+	Created           time.Time `db:"created"`           // Время запуска поиска
+	Completed         time.Time `db:"completed"`         // Время завершения шага
+}
+
+type DtoTableDedupRow struct {
+	Table_Dedup_ID int64 `db:"table_dedup_id"` // Идентификатор поиска дубликатов
+	Group_ID       int64 `db:"group_id"`       // Номер группы
+	Table_Row_ID   int64 `db:"table_row_id"`   // Идентификатор строки таблицы
+	Position       int64 `db:"position"`       // Позиция строки на момент поиска
+}
+
+// Конструктор создания объекта поиска дубликатов в api
+func NewApiTableDedup(id int64, step byte, ready bool, percentage byte, groups int64, duplicates int64, errorflag bool,
+	errordescription string, created time.Time, completed time.Time) *ApiTableDedup {
+	return &ApiTableDedup{
+		ID:               id,
+		Step:             step,
+		Ready:            ready,
+		Percentage:       percentage,
+		Groups:           groups,
+		Duplicates:       duplicates,
+		Error:            errorflag,
+		ErrorDescription: errordescription,
+		Created:          created,
+		Completed:        completed,
+	}
+}
+
+func NewApiDedupGroup(group int64, rows []ApiInfoTableRow) *ApiDedupGroup {
+	return &ApiDedupGroup{
+		Group: group,
+		Rows:  rows,
+	}
+}
+
+// Конструктор создания объекта поиска дубликатов в бд
+func NewDtoTableDedup(id int64, customer_table_id int64, step byte, ready bool, percentage byte, created time.Time,
+	completed time.Time) *DtoTableDedup {
+	return &DtoTableDedup{
+		ID:                id,
+		Customer_Table_ID: customer_table_id,
+		Step:              step,
+		Ready:             ready,
+		Percentage:        percentage,
+		Created:           created,
+		Completed:         completed,
+	}
+}
+
+func NewDtoTableDedupRow(table_dedup_id int64, group_id int64, table_row_id int64, position int64) *DtoTableDedupRow {
+	return &DtoTableDedupRow{
+		Table_Dedup_ID: table_dedup_id,
+		Group_ID:       group_id,
+		Table_Row_ID:   table_row_id,
+		Position:       position,
+	}
+}
+
+func (tablededup *ViewTableDedup) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(tablededup, errors, req)
+}
+
+func (tablemerge *ViewTableMerge) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(tablemerge, errors, req)
+}
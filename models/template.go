@@ -48,6 +48,30 @@ type DtoInvoiceTemplate struct {
 	Contract DtoContract        // Договор
 }
 
+type DtoContractTemplate struct {
+	Contract   DtoContract        // Договор
+	Appendices []ApiAppendix      // Приложения договора
+	Bank       ViewApiCompanyBank // Банк для платежа
+	Seller     DtoCompanyTemplate // Поставщик
+	Buyer      DtoCompanyTemplate // Покупатель
+}
+
+type DtoMatchingLine struct {
+	Date   time.Time // Дата операции
+	Name   string    // Содержание операции
+	Debit  float64   // Начислено
+	Credit float64   // Оплачено
+}
+
+type DtoMatchingTemplate struct {
+	Begin   time.Time          // Начало периода
+	End     time.Time          // Окончание периода
+	Seller  DtoCompanyTemplate // Поставщик
+	Buyer   DtoCompanyTemplate // Покупатель
+	Opening float64            // Задолженность на начало периода
+	Lines   []DtoMatchingLine  // Операции за период
+}
+
 // Конструктор создания объекта шаблона
 func NewDtoTemplate(email string, language string, host string, created time.Time, ip_address string) *DtoTemplate {
 	return &DtoTemplate{
@@ -102,6 +126,29 @@ func NewDtoInvoiceTemplate(invoice ApiFullInvoice, bank ViewApiCompanyBank, sell
 		Contract: contract,
 	}
 }
+
+func NewDtoContractTemplate(contract DtoContract, appendices []ApiAppendix, bank ViewApiCompanyBank, seller DtoCompanyTemplate,
+	buyer DtoCompanyTemplate) *DtoContractTemplate {
+	return &DtoContractTemplate{
+		Contract:   contract,
+		Appendices: appendices,
+		Bank:       bank,
+		Seller:     seller,
+		Buyer:      buyer,
+	}
+}
+
+func NewDtoMatchingTemplate(begin time.Time, end time.Time, seller DtoCompanyTemplate, buyer DtoCompanyTemplate, opening float64,
+	lines []DtoMatchingLine) *DtoMatchingTemplate {
+	return &DtoMatchingTemplate{
+		Begin:   begin,
+		End:     end,
+		Seller:  seller,
+		Buyer:   buyer,
+		Opening: opening,
+		Lines:   lines,
+	}
+}
 func (template *DtoTemplate) GetResource() (Resource config.Resource) {
 	return config.Localization[template.Language]
 }
@@ -0,0 +1,177 @@
+package models
+
+import (
+	"github.com/martini-contrib/binding"
+	"net/http"
+	"time"
+)
+
+const (
+	// Способы подтверждения входа вторым фактором
+	TWO_FACTOR_METHOD_TOTP     = "totp"     // Одноразовый пароль из приложения
+	TWO_FACTOR_METHOD_SMS      = "sms"      // Одноразовый код в SMS на подтвержденный мобильный телефон
+	TWO_FACTOR_METHOD_RECOVERY = "recovery" // Код восстановления
+
+	// Назначение одноразового кода
+	TWO_FACTOR_CHALLENGE_LOGIN     = 1 // Вход пользователя
+	TWO_FACTOR_CHALLENGE_ENROLMENT = 2 // Подключение второго фактора
+
+	// Приложение устройств, отмеченных доверенными при вводе второго фактора
+	DEVICE_APP_TWO_FACTOR = "twofactor"
+)
+
+// Структура для организации хранения второго фактора аутентификации
+type ViewTwoFactorLogin struct {
+	Token       string `json:"token" validate:"min=1,max=255"`                         // Токен попытки входа
+	Method      string `json:"method" validate:"nonzero,regexp=^(totp|sms|recovery)$"` // Способ подтверждения
+	Code        string `json:"code" validate:"min=1,max=32"`                           // Одноразовый код
+	TrustDevice bool   `json:"trustDevice"`                                            // Отметить устройство доверенным
+	OS          string `json:"nameOs" validate:"max=255"`                              // Операционная система или браузер доверенного устройства
+}
+
+type ViewTwoFactorToken struct {
+	Token string `json:"token" validate:"min=1,max=255"` // Токен попытки входа или подключения
+}
+
+type ViewTwoFactorCode struct {
+	Token string `json:"token" validate:"max=255"`     // Токен подключения SMS кодов
+	Code  string `json:"code" validate:"min=1,max=32"` // Одноразовый код или код восстановления
+}
+
+type ViewTwoFactorPolicy struct {
+	Required bool `json:"required"` // Второй фактор обязателен для пользователей объединения
+}
+
+type ApiTwoFactor struct {
+	Enabled       bool   `json:"enabled"`       // Второй фактор подключен
+	Method        string `json:"method"`        // Подключенный способ подтверждения
+	Required      bool   `json:"required"`      // Второй фактор обязателен по уровню доступа или объединению
+	RecoveryCodes int64  `json:"recoveryCodes"` // Количество неиспользованных кодов восстановления
+}
+
+// Попытка входа, ожидающая ввода второго фактора
+type ApiTwoFactorChallenge struct {
+	Token   string    `json:"token"`   // Токен попытки входа или подключения
+	Methods []string  `json:"methods"` // Доступные способы подтверждения
+	Expires time.Time `json:"expires"` // Время окончания действия попытки
+}
+
+// Секрет для подключения приложения одноразовых паролей
+type ApiTwoFactorTOTP struct {
+	Secret string `json:"secret"` // Секрет в кодировке base32
+	URI    string `json:"uri"`    // Адрес otpauth:// для приложения
+	QRCode string `json:"qrCode"` // Изображение QR-кода адреса в формате png, base64
+}
+
+type ApiRecoveryCodes struct {
+	Codes []string `json:"codes"` // Коды восстановления, показываются один раз
+}
+
+type ApiTwoFactorPolicy struct {
+	Required bool `json:"required"` // Второй фактор обязателен для пользователей объединения
+}
+
+type DtoTwoFactor struct {
+	User_ID int64     `db:"user_id"` // Идентификатор пользователя
+	Method  string    `db:"method"`  // Способ подтверждения
+	Secret  string    `db:"secret"`  // Секрет одноразовых паролей в кодировке base32
+	Phone   string    `db:"phone"`   // Мобильный телефон для одноразовых кодов
+	Enabled bool      `db:"enabled"` // Подключение подтверждено кодом
+	Counter int64     `db:"counter"` // Шаг последнего принятого одноразового пароля, повторно не принимается
+	Created time.Time `db:"created"` // Время подключения
+}
+
+type DtoTwoFactorChallenge struct {
+	Token    string    `db:"token"`    // Токен попытки
+	User_ID  int64     `db:"user_id"`  // Идентификатор пользователя
+	Purpose  byte      `db:"purpose"`  // Назначение попытки
+	Code     string    `db:"code"`     // Хэш отправленного в SMS кода
+	Language string    `db:"language"` // Язык пользователя сессии
+	Attempts int       `db:"attempts"` // Количество неверных вводов кода
+	Sent     time.Time `db:"sent"`     // Время отправки SMS
+	Expires  time.Time `db:"expires"`  // Время окончания действия попытки
+	Created  time.Time `db:"created"`  // Время создания попытки
+}
+
+// Конструктор создания объекта второго фактора в api
+func NewApiTwoFactor(enabled bool, method string, required bool, recoverycodes int64) *ApiTwoFactor {
+	return &ApiTwoFactor{
+		Enabled:       enabled,
+		Method:        method,
+		Required:      required,
+		RecoveryCodes: recoverycodes,
+	}
+}
+
+func NewApiTwoFactorChallenge(token string, methods []string, expires time.Time) *ApiTwoFactorChallenge {
+	return &ApiTwoFactorChallenge{
+		Token:   token,
+		Methods: methods,
+		Expires: expires,
+	}
+}
+
+func NewApiTwoFactorTOTP(secret string, uri string, qrcode string) *ApiTwoFactorTOTP {
+	return &ApiTwoFactorTOTP{
+		Secret: secret,
+		URI:    uri,
+		QRCode: qrcode,
+	}
+}
+
+func NewApiRecoveryCodes(codes []string) *ApiRecoveryCodes {
+	return &ApiRecoveryCodes{
+		Codes: codes,
+	}
+}
+
+func NewApiTwoFactorPolicy(required bool) *ApiTwoFactorPolicy {
+	return &ApiTwoFactorPolicy{
+		Required: required,
+	}
+}
+
+// Конструктор создания объекта второго фактора в бд
+func NewDtoTwoFactor(user_id int64, method string, secret string, phone string, enabled bool, counter int64,
+	created time.Time) *DtoTwoFactor {
+	return &DtoTwoFactor{
+		User_ID: user_id,
+		Method:  method,
+		Secret:  secret,
+		Phone:   phone,
+		Enabled: enabled,
+		Counter: counter,
+		Created: created,
+	}
+}
+
+func NewDtoTwoFactorChallenge(token string, user_id int64, purpose byte, code string, language string, attempts int,
+	sent time.Time, expires time.Time, created time.Time) *DtoTwoFactorChallenge {
+	return &DtoTwoFactorChallenge{
+		Token:    token,
+		User_ID:  user_id,
+		Purpose:  purpose,
+		Code:     code,
+		Language: language,
+		Attempts: attempts,
+		Sent:     sent,
+		Expires:  expires,
+		Created:  created,
+	}
+}
+
+func (twofactor *ViewTwoFactorLogin) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(twofactor, errors, req)
+}
+
+func (twofactor *ViewTwoFactorToken) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(twofactor, errors, req)
+}
+
+func (twofactor *ViewTwoFactorCode) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(twofactor, errors, req)
+}
+
+func (policy *ViewTwoFactorPolicy) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(policy, errors, req)
+}
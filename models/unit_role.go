@@ -0,0 +1,113 @@
+package models
+
+import (
+	"github.com/martini-contrib/binding"
+	"net/http"
+	"time"
+)
+
+const (
+	// Разрешения ролей объединения
+	PERMISSION_TABLES_READ       = "tables.read"       // Просмотр таблиц
+	PERMISSION_TABLES_WRITE      = "tables.write"      // Изменение таблиц
+	PERMISSION_ORDERS_VIEW       = "orders.view"       // Просмотр заказов исполнителя
+	PERMISSION_ORDERS_CONFIRM    = "orders.confirm"    // Создание, изменение и подтверждение заказов
+	PERMISSION_PROJECTS_VIEW     = "projects.view"     // Просмотр проектов и их заказов
+	PERMISSION_PROJECTS_MANAGE   = "projects.manage"   // Управление проектами
+	PERMISSION_INVOICES_VIEW     = "invoices.view"     // Просмотр счетов
+	PERMISSION_INVOICES_PAY      = "invoices.pay"      // Выставление, изменение и отказ от оплаты счетов
+	PERMISSION_REPORTS_VIEW      = "reports.view"      // Просмотр отчетов
+	PERMISSION_COMPANIES_MANAGE  = "companies.manage"  // Управление компаниями
+	PERMISSION_SMSSENDERS_MANAGE = "smssenders.manage" // Управление именами отправителя
+	PERMISSION_DOCUMENTS_VIEW    = "documents.view"    // Просмотр документов
+)
+
+// Перечень разрешений, доступных для ролей объединения
+var PERMISSIONS = []string{
+	PERMISSION_TABLES_READ,
+	PERMISSION_TABLES_WRITE,
+	PERMISSION_ORDERS_VIEW,
+	PERMISSION_ORDERS_CONFIRM,
+	PERMISSION_PROJECTS_VIEW,
+	PERMISSION_PROJECTS_MANAGE,
+	PERMISSION_INVOICES_VIEW,
+	PERMISSION_INVOICES_PAY,
+	PERMISSION_REPORTS_VIEW,
+	PERMISSION_COMPANIES_MANAGE,
+	PERMISSION_SMSSENDERS_MANAGE,
+	PERMISSION_DOCUMENTS_VIEW,
+}
+
+// Структура для организации ролей объединения
+type ViewUnitRole struct {
+	Name        string   `json:"name" validate:"min=1,max=255"` // Название роли
+	Permissions []string `json:"permissions"`                   // Разрешения роли
+}
+
+type ViewUnitUserRoles struct {
+	Roles []int64 `json:"roles"` // Идентификаторы ролей объединения пользователя
+}
+
+type ApiUnitRole struct {
+	ID          int64     `json:"id"`          // Уникальный идентификатор роли
+	Name        string    `json:"name"`        // Название роли
+	Permissions []string  `json:"permissions"` // Разрешения роли
+	Created     time.Time `json:"created"`     // Время создания роли
+}
+
+type ApiUnitUserRoles struct {
+	Roles []int64 `json:"roles"` // Идентификаторы ролей объединения пользователя
+}
+
+type ApiPermissions struct {
+	Permissions []string `json:"permissions"` // Перечень разрешений
+}
+
+type DtoUnitRole struct {
+	ID          int64     `db:"id"`      // Уникальный идентификатор роли
+	Unit_ID     int64     `db:"unit_id"` // Идентификатор объединения
+	Name        string    `db:"name"`    // Название роли
+	Permissions []string  `db:"-"`       // Разрешения роли
+	Created     time.Time `db:"created"` // Время создания роли
+}
+
+// Конструктор создания объекта роли объединения в api
+func NewApiUnitRole(id int64, name string, permissions []string, created time.Time) *ApiUnitRole {
+	return &ApiUnitRole{
+		ID:          id,
+		Name:        name,
+		Permissions: permissions,
+		Created:     created,
+	}
+}
+
+func NewApiUnitUserRoles(roles []int64) *ApiUnitUserRoles {
+	return &ApiUnitUserRoles{
+		Roles: roles,
+	}
+}
+
+func NewApiPermissions(permissions []string) *ApiPermissions {
+	return &ApiPermissions{
+		Permissions: permissions,
+	}
+}
+
+// Конструктор создания объекта роли объединения в бд
+func NewDtoUnitRole(id int64, unit_id int64, name string, permissions []string, created time.Time) *DtoUnitRole {
+	return &DtoUnitRole{
+		ID:          id,
+		Unit_ID:     unit_id,
+		Name:        name,
+		Permissions: permissions,
+		Created:     created,
+	}
+}
+
+func (role *ViewUnitRole) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(role, errors, req)
+}
+
+func (roles *ViewUnitUserRoles) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(roles, errors, req)
+}
@@ -0,0 +1,189 @@
+package models
+
+import (
+	"github.com/martini-contrib/binding"
+	"net/http"
+	"time"
+)
+
+type WebhookDeliveryStatus int
+
+const (
+	WEBHOOK_DELIVERY_STATUS_PENDING WebhookDeliveryStatus = iota + 1
+	WEBHOOK_DELIVERY_STATUS_DELIVERED
+	WEBHOOK_DELIVERY_STATUS_FAILED
+)
+
+const (
+	WEBHOOK_DELIVERY_STATUS_PENDING_VALUE   = "pending"
+	WEBHOOK_DELIVERY_STATUS_DELIVERED_VALUE = "delivered"
+	WEBHOOK_DELIVERY_STATUS_FAILED_VALUE    = "failed"
+
+	WEBHOOK_EVENT_ORDER_STATUS     = "order.status"
+	WEBHOOK_EVENT_INVOICE_CREATED  = "invoice.created"
+	WEBHOOK_EVENT_IMPORT_COMPLETED = "import.completed"
+	WEBHOOK_EVENT_EXPORT_COMPLETED = "export.completed"
+)
+
+var (
+	WEBHOOK_EVENTS = []string{WEBHOOK_EVENT_ORDER_STATUS, WEBHOOK_EVENT_INVOICE_CREATED, WEBHOOK_EVENT_IMPORT_COMPLETED,
+		WEBHOOK_EVENT_EXPORT_COMPLETED}
+)
+
+// Структура для организации хранения адреса уведомлений объединения о событиях
+type ViewWebhook struct {
+	URL    string   `json:"url" validate:"max=255,regexp=^https?://[^ ]+$"` // Адрес, на который отправляются уведомления
+	Events []string `json:"events"`                                         // События, о которых отправляются уведомления, пусто - все
+	Active bool     `json:"active"`                                         // Активен
+}
+
+type ApiWebhook struct {
+	ID      int64     `json:"id"`               // Уникальный идентификатор адреса уведомлений
+	URL     string    `json:"url"`              // Адрес, на который отправляются уведомления
+	Events  []string  `json:"events"`           // События, о которых отправляются уведомления
+	Secret  string    `json:"secret,omitempty"` // Ключ подписи уведомлений, возвращается только при создании
+	Active  bool      `json:"active"`           // Активен
+	Created time.Time `json:"created"`          // Время создания
+}
+
+type ApiWebhookDelivery struct {
+	ID           int64     `json:"id"`           // Уникальный идентификатор уведомления
+	Event        string    `json:"event"`        // Событие
+	Status       string    `json:"status"`       // Статус доставки
+	Attempts     int       `json:"attempts"`     // Количество попыток доставки
+	ResponseCode int       `json:"responseCode"` // Код ответа на последнюю попытку
+	Error        string    `json:"error"`        // Ошибка последней попытки
+	Next_Attempt time.Time `json:"nextAttempt"`  // Время следующей попытки
+	Created      time.Time `json:"created"`      // Время создания
+	Delivered    time.Time `json:"delivered"`    // Время доставки
+}
+
+// Тело уведомления, подписываемое ключом адреса уведомлений
+type ApiWebhookEvent struct {
+	Event   string      `json:"event"`   // Событие
+	Created time.Time   `json:"created"` // Время события
+	Data    interface{} `json:"data"`    // Данные события
+}
+
+type ApiOrderStatusEvent struct {
+	Order_ID  int64       `json:"orderId"`  // Идентификатор заказа
+	Status_ID OrderStatus `json:"statusId"` // Идентификатор статуса
+	Value     bool        `json:"value"`    // Значение
+	Comments  string      `json:"comments"` // Комментарий
+}
+
+type ApiInvoiceEvent struct {
+	Invoice_ID int64   `json:"invoiceId"` // Идентификатор счета
+	Company_ID int64   `json:"companyId"` // Идентификатор компании
+	Total      float64 `json:"total"`     // Всего
+	VAT        float64 `json:"vat"`       // НДС
+}
+
+type ApiImportEvent struct {
+	Table_ID  int64 `json:"tableId"`   // Идентификатор таблицы
+	Rows      int64 `json:"rows"`      // Количество загруженных строк
+	WrongRows int64 `json:"wrongRows"` // Количество строк с ошибками
+}
+
+type ApiExportEvent struct {
+	Table_ID int64 `json:"tableId"` // Идентификатор таблицы
+	File_ID  int64 `json:"fileId"`  // Идентификатор файла выгрузки
+}
+
+type DtoWebhook struct {
+	ID      int64     `db:"id"`      // Уникальный идентификатор адреса уведомлений
+	Unit_ID int64     `db:"unit_id"` // Идентификатор объединения
+	URL     string    `db:"url"`     // Адрес, на который отправляются уведомления
+	Events  string    `db:"events"`  // События через запятую, пусто - все
+	Secret  string    `db:"secret"`  // Ключ подписи уведомлений
+	Active  bool      `db:"active"`  // Активен
+	Created time.Time `db:"created"` // Время создания
+}
+
+type DtoWebhookDelivery struct {
+	ID           int64                 `db:"id"`            // Уникальный идентификатор уведомления
+	Webhook_ID   int64                 `db:"webhook_id"`    // Идентификатор адреса уведомлений
+	Event        string                `db:"event"`         // Событие
+	Payload      string                `db:"payload"`       // Тело уведомления
+	Status       WebhookDeliveryStatus `db:"status"`        // Статус доставки
+	Attempts     int                   `db:"attempts"`      // Количество попыток доставки
+	ResponseCode int                   `db:"response_code"` // Код ответа на последнюю попытку
+	Error        string                `db:"error"`         // Ошибка последней попытки
+	Next_Attempt time.Time             `db:"next_attempt"`  // Время следующей попытки
+	Lease        string                `db:"lease"`         // Токен аренды уведомления обработчиком
+	Lease_Till   time.Time             `db:"lease_till"`    // Время окончания аренды
+	Created      time.Time             `db:"created"`       // Время создания
+	Delivered    time.Time             `db:"delivered"`     // Время доставки
+}
+
+// Конструктор создания объекта адреса уведомлений в api
+func NewApiWebhook(id int64, url string, events []string, secret string, active bool, created time.Time) *ApiWebhook {
+	return &ApiWebhook{
+		ID:      id,
+		URL:     url,
+		Events:  events,
+		Secret:  secret,
+		Active:  active,
+		Created: created,
+	}
+}
+
+func NewApiWebhookDelivery(id int64, event string, status string, attempts int, responsecode int, deliveryerror string,
+	next_attempt time.Time, created time.Time, delivered time.Time) *ApiWebhookDelivery {
+	return &ApiWebhookDelivery{
+		ID:           id,
+		Event:        event,
+		Status:       status,
+		Attempts:     attempts,
+		ResponseCode: responsecode,
+		Error:        deliveryerror,
+		Next_Attempt: next_attempt,
+		Created:      created,
+		Delivered:    delivered,
+	}
+}
+
+func NewApiWebhookEvent(event string, created time.Time, data interface{}) *ApiWebhookEvent {
+	return &ApiWebhookEvent{
+		Event:   event,
+		Created: created,
+		Data:    data,
+	}
+}
+
+// Конструктор создания объекта адреса уведомлений в бд
+func NewDtoWebhook(id int64, unit_id int64, url string, events string, secret string, active bool, created time.Time) *DtoWebhook {
+	return &DtoWebhook{
+		ID:      id,
+		Unit_ID: unit_id,
+		URL:     url,
+		Events:  events,
+		Secret:  secret,
+		Active:  active,
+		Created: created,
+	}
+}
+
+func (status WebhookDeliveryStatus) String() string {
+	switch status {
+	case WEBHOOK_DELIVERY_STATUS_DELIVERED:
+		return WEBHOOK_DELIVERY_STATUS_DELIVERED_VALUE
+	case WEBHOOK_DELIVERY_STATUS_FAILED:
+		return WEBHOOK_DELIVERY_STATUS_FAILED_VALUE
+	default:
+		return WEBHOOK_DELIVERY_STATUS_PENDING_VALUE
+	}
+}
+
+func IsWebhookEvent(event string) bool {
+	for _, webhookevent := range WEBHOOK_EVENTS {
+		if webhookevent == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (webhook *ViewWebhook) Validate(errors binding.Errors, req *http.Request) binding.Errors {
+	return Validate(webhook, errors, req)
+}
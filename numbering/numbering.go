@@ -0,0 +1,126 @@
+/* Numbering package provides offline normalisation of phone numbers and detection of mobile operators by numbering plans */
+
+package numbering
+
+import (
+	"application/config"
+	logging "github.com/op/go-logging"
+	"regexp"
+	"strings"
+)
+
+const (
+	COUNTRY_DEFAULT = "RU"
+)
+
+// Правила нумерации страны: код страны, префикс выхода на междугороднюю связь и длина национального номера.
+// Зоны ограничивают страну национальными кодами, если код страны общий с другой страной
+type Country struct {
+	ISO      string   // Код страны ISO 3166
+	Code     string   // Телефонный код страны
+	Trunk    string   // Префикс набора национального номера
+	Length   int      // Длина национального номера
+	Zones    []string // Начальные цифры национальных номеров страны
+	Timezone string   // Часовой пояс страны, пусто - в стране несколько часовых поясов
+}
+
+var (
+	log config.Logger = logging.MustGetLogger("numbering")
+
+	NumberRegExp = regexp.MustCompile("[0-9]+")
+
+	// Страны с общим кодом указаны перед страной без ограничения зонами
+	COUNTRIES = []Country{
+		{ISO: "KZ", Code: "7", Trunk: "8", Length: 10, Zones: []string{"6", "7"}, Timezone: "UTC+5"},
+		{ISO: "RU", Code: "7", Trunk: "8", Length: 10},
+		{ISO: "BY", Code: "375", Trunk: "80", Length: 9, Timezone: "UTC+3"},
+		{ISO: "UA", Code: "380", Trunk: "0", Length: 9, Timezone: "UTC+2"},
+		{ISO: "UZ", Code: "998", Trunk: "", Length: 9, Timezone: "UTC+5"},
+		{ISO: "KG", Code: "996", Trunk: "0", Length: 9, Timezone: "UTC+6"},
+		{ISO: "TJ", Code: "992", Trunk: "", Length: 9, Timezone: "UTC+5"},
+		{ISO: "AZ", Code: "994", Trunk: "0", Length: 9, Timezone: "UTC+4"},
+		{ISO: "GE", Code: "995", Trunk: "0", Length: 9, Timezone: "UTC+4"},
+		{ISO: "AM", Code: "374", Trunk: "0", Length: 8, Timezone: "UTC+4"},
+		{ISO: "MD", Code: "373", Trunk: "0", Length: 8, Timezone: "UTC+2"},
+	}
+
+	// Международные префиксы набора, после которых следует код страны
+	INTERNATIONAL_PREFIXES = []string{"810", "00"}
+)
+
+func InitLogger(logger config.Logger) {
+	log = logger
+}
+
+// Страна номеров, указанных без кода страны
+func DefaultCountry() *Country {
+	iso := config.Configuration.Numbering.Country
+	if iso == "" {
+		iso = COUNTRY_DEFAULT
+	}
+	if country := FindCountry(iso); country != nil {
+		return country
+	}
+	log.Error("Unknown numbering country %v, %v is used", iso, COUNTRY_DEFAULT)
+	return FindCountry(COUNTRY_DEFAULT)
+}
+
+func FindCountry(iso string) *Country {
+	for i := range COUNTRIES {
+		if COUNTRIES[i].ISO == iso {
+			return &COUNTRIES[i]
+		}
+	}
+	return nil
+}
+
+// Страна номера в формате E.164 без знака +
+func CountryOf(number string) *Country {
+	for i := range COUNTRIES {
+		if COUNTRIES[i].Match(number) {
+			return &COUNTRIES[i]
+		}
+	}
+	return nil
+}
+
+// Соответствие номера в формате E.164 коду, длине и зонам страны
+func (country *Country) Match(number string) bool {
+	if len(number) != len(country.Code)+country.Length || !strings.HasPrefix(number, country.Code) {
+		return false
+	}
+	if len(country.Zones) == 0 {
+		return true
+	}
+	for _, zone := range country.Zones {
+		if strings.HasPrefix(number[len(country.Code):], zone) {
+			return true
+		}
+	}
+	return false
+}
+
+// Приведение номера к формату E.164 без знака +. Номер без кода страны считается номером страны по умолчанию,
+// номер, который не удалось распознать, возвращается в виде цифр
+func Normalize(value string) string {
+	international := strings.HasPrefix(strings.TrimSpace(value), "+")
+	number := strings.Join(NumberRegExp.FindAllString(value, -1), "")
+	if international {
+		return number
+	}
+	for _, prefix := range INTERNATIONAL_PREFIXES {
+		if strings.HasPrefix(number, prefix) && CountryOf(number[len(prefix):]) != nil {
+			return number[len(prefix):]
+		}
+	}
+
+	country := DefaultCountry()
+	switch {
+	case len(number) == country.Length:
+		return country.Code + number
+	case country.Trunk != "" && len(number) == len(country.Trunk)+country.Length && strings.HasPrefix(number, country.Trunk):
+		return country.Code + number[len(country.Trunk):]
+	}
+
+	return number
+}
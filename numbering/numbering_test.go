@@ -0,0 +1,77 @@
+package numbering
+
+import (
+	"application/config"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+	var cases = []struct {
+		value  string
+		number string
+	}{
+		{"+7 (916) 123-45-67", "79161234567"},
+		{"8 916 123 45 67", "79161234567"},
+		{"9161234567", "79161234567"},
+		{"79161234567", "79161234567"},
+		{"+375 29 123-45-67", "375291234567"},
+		{"810375291234567", "375291234567"},
+		{"00380501234567", "380501234567"},
+		{"+77011234567", "77011234567"},
+		{"12345", "12345"},
+		{"phone", ""},
+	}
+
+	for _, c := range cases {
+		if number := Normalize(c.value); number != c.number {
+			t.Error("Number", c.value, "is not properly normalized", number)
+		}
+	}
+}
+
+func TestNormalizeDefaultCountry(t *testing.T) {
+	var country = config.Configuration.Numbering.Country
+	defer func() {
+		config.Configuration.Numbering.Country = country
+	}()
+	config.Configuration.Numbering.Country = "BY"
+
+	if number := Normalize("80291234567"); number != "375291234567" {
+		t.Error("Number with trunk prefix is not properly normalized", number)
+	}
+	if number := Normalize("291234567"); number != "375291234567" {
+		t.Error("National number is not properly normalized", number)
+	}
+
+	config.Configuration.Numbering.Country = "XX"
+	if DefaultCountry().ISO != COUNTRY_DEFAULT {
+		t.Error("Unknown default country is not properly replaced")
+	}
+}
+
+func TestCountryOf(t *testing.T) {
+	var cases = []struct {
+		number string
+		iso    string
+	}{
+		{"79161234567", "RU"},
+		{"77011234567", "KZ"},
+		{"76011234567", "KZ"},
+		{"375291234567", "BY"},
+		{"380501234567", "UA"},
+		{"37491234567", "AM"},
+		{"7916123456", ""},
+		{"3752912345678", ""},
+		{"12345", ""},
+	}
+
+	for _, c := range cases {
+		iso := ""
+		if country := CountryOf(c.number); country != nil {
+			iso = country.ISO
+		}
+		if iso != c.iso {
+			t.Error("Country of", c.number, "is not properly detected", iso)
+		}
+	}
+}
@@ -0,0 +1,260 @@
+package numbering
+
+import (
+	"application/config"
+	"application/models"
+	"bufio"
+	"encoding/csv"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+const (
+	PLAN_COLUMN_CODE     = 0
+	PLAN_COLUMN_FROM     = 1
+	PLAN_COLUMN_TO       = 2
+	PLAN_COLUMN_OPERATOR = 4
+	PLAN_COLUMN_REGION   = 5
+
+	PORTED_COLUMN_NUMBER   = 0
+	PORTED_COLUMN_OPERATOR = 1
+)
+
+// Диапазон номеров плана нумерации в формате E.164
+type Range struct {
+	From     uint64 // Начало диапазона
+	To       uint64 // Конец диапазона
+	Operator int    // Индекс оператора в списке операторов плана
+	Region   string // Регион
+}
+
+// План нумерации с перенесенными номерами. Операторы хранятся в виде UUID объединений операторов,
+// оператор, не сопоставленный с объединением, хранится пустой строкой
+type Plan struct {
+	Countries map[string]bool   // Страны, для которых загружен план нумерации
+	Operators []string          // UUID объединений операторов
+	Ranges    []Range           // Диапазоны, упорядоченные по началу
+	Ported    map[uint64]int    // Индекс оператора перенесенного номера
+	operators map[string]int    // Индекс оператора по названию в файлах
+	uuids     map[string]string // UUID объединения оператора по названию
+}
+
+var (
+	mutex   sync.RWMutex
+	current *Plan
+)
+
+// Загрузка плана нумерации и перенесенных номеров из файлов конфигурации. Названия операторов из файлов
+// сопоставляются с объединениями по конфигурации, затем по короткому и длинному названию мобильного оператора
+func Init(dtomobileoperators *[]models.DtoMobileOperator) (err error) {
+	plan := NewPlan(dtomobileoperators)
+	for iso, files := range config.Configuration.Numbering.Plans {
+		country := FindCountry(iso)
+		if country == nil {
+			log.Error("Unknown numbering plan country %v", iso)
+			continue
+		}
+		for _, file := range files {
+			err = plan.LoadRanges(country, file)
+			if err != nil {
+				return err
+			}
+		}
+		plan.Countries[country.ISO] = true
+	}
+	for _, file := range config.Configuration.Numbering.Ported {
+		err = plan.LoadPorted(file)
+		if err != nil {
+			return err
+		}
+	}
+	sort.Sort(plan)
+	log.Info("Numbering plan is loaded: %v ranges, %v ported numbers", len(plan.Ranges), len(plan.Ported))
+
+	mutex.Lock()
+	current = plan
+	mutex.Unlock()
+
+	return nil
+}
+
+func NewPlan(dtomobileoperators *[]models.DtoMobileOperator) *Plan {
+	plan := &Plan{
+		Countries: make(map[string]bool),
+		Operators: []string{},
+		Ranges:    []Range{},
+		Ported:    make(map[uint64]int),
+		operators: make(map[string]int),
+		uuids:     make(map[string]string),
+	}
+	if dtomobileoperators != nil {
+		for _, dtomobileoperator := range *dtomobileoperators {
+			plan.uuids[strings.ToLower(dtomobileoperator.ShortName)] = dtomobileoperator.UUID
+			plan.uuids[strings.ToLower(dtomobileoperator.LongName)] = dtomobileoperator.UUID
+		}
+	}
+	for name, uuid := range config.Configuration.Numbering.Operators {
+		plan.uuids[strings.ToLower(name)] = uuid
+	}
+
+	return plan
+}
+
+// Индекс оператора по названию из файла
+func (plan *Plan) operator(name string) int {
+	name = strings.ToLower(strings.TrimSpace(name))
+	index, ok := plan.operators[name]
+	if !ok {
+		index = len(plan.Operators)
+		uuid, found := plan.uuids[name]
+		if !found {
+			log.Warning("Numbering operator %v isn't matched to mobile operator", name)
+		}
+		plan.Operators = append(plan.Operators, uuid)
+		plan.operators[name] = index
+	}
+
+	return index
+}
+
+// Чтение файла с разделителем ; или , по первой строке. Строки, в которых не удалось разобрать номер, пропускаются
+func readRecords(file string, record func(fields []string)) (err error) {
+	handle, err := os.Open(file)
+	if err != nil {
+		log.Error("Can't open numbering file %v, %v", file, err)
+		return err
+	}
+	defer handle.Close()
+
+	reader := bufio.NewReader(handle)
+	head, err := reader.Peek(1024)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		log.Error("Can't read numbering file %v, %v", file, err)
+		return err
+	}
+	csvreader := csv.NewReader(reader)
+	csvreader.Comma = ','
+	if strings.Contains(strings.SplitN(string(head), "\n", 2)[0], ";") {
+		csvreader.Comma = ';'
+	}
+	csvreader.FieldsPerRecord = -1
+	csvreader.LazyQuotes = true
+	for {
+		fields, err := csvreader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			log.Error("Can't read numbering file %v, %v", file, err)
+			return err
+		}
+		record(fields)
+	}
+
+	return nil
+}
+
+// Загрузка диапазонов плана нумерации страны: код, начало, конец, емкость, оператор, регион
+func (plan *Plan) LoadRanges(country *Country, file string) (err error) {
+	return readRecords(file, func(fields []string) {
+		if len(fields) <= PLAN_COLUMN_OPERATOR {
+			return
+		}
+		code := strings.TrimSpace(fields[PLAN_COLUMN_CODE])
+		from, errfrom := strconv.ParseUint(country.Code+code+strings.TrimSpace(fields[PLAN_COLUMN_FROM]), 10, 64)
+		to, errto := strconv.ParseUint(country.Code+code+strings.TrimSpace(fields[PLAN_COLUMN_TO]), 10, 64)
+		if errfrom != nil || errto != nil || from > to {
+			return
+		}
+		region := ""
+		if len(fields) > PLAN_COLUMN_REGION {
+			region = strings.TrimSpace(fields[PLAN_COLUMN_REGION])
+		}
+		plan.Ranges = append(plan.Ranges, Range{From: from, To: to, Operator: plan.operator(fields[PLAN_COLUMN_OPERATOR]), Region: region})
+	})
+}
+
+// Загрузка перенесенных номеров: номер в формате E.164, оператор
+func (plan *Plan) LoadPorted(file string) (err error) {
+	return readRecords(file, func(fields []string) {
+		if len(fields) <= PORTED_COLUMN_OPERATOR {
+			return
+		}
+		number, err := strconv.ParseUint(Normalize(fields[PORTED_COLUMN_NUMBER]), 10, 64)
+		if err != nil {
+			return
+		}
+		plan.Ported[number] = plan.operator(fields[PORTED_COLUMN_OPERATOR])
+	})
+}
+
+func (plan *Plan) Len() int {
+	return len(plan.Ranges)
+}
+
+func (plan *Plan) Less(i, j int) bool {
+	return plan.Ranges[i].From < plan.Ranges[j].From
+}
+
+func (plan *Plan) Swap(i, j int) {
+	plan.Ranges[i], plan.Ranges[j] = plan.Ranges[j], plan.Ranges[i]
+}
+
+// Поиск диапазона номера
+func (plan *Plan) Find(mobilephone uint64) *Range {
+	index := sort.Search(len(plan.Ranges), func(i int) bool {
+		return plan.Ranges[i].From > mobilephone
+	}) - 1
+	if index >= 0 && plan.Ranges[index].To >= mobilephone {
+		return &plan.Ranges[index]
+	}
+	return nil
+}
+
+// Определение оператора и региона номера. Оператор перенесенного номера берется из списка перенесенных номеров,
+// регион - из плана нумерации. Если номер не найден, found возвращается false
+func Lookup(mobilephone uint64) (operator string, region string, found bool) {
+	mutex.RLock()
+	plan := current
+	mutex.RUnlock()
+	if plan == nil {
+		return "", "", false
+	}
+
+	numberrange := plan.Find(mobilephone)
+	if numberrange != nil {
+		operator = plan.Operators[numberrange.Operator]
+		region = numberrange.Region
+		found = true
+	}
+	if index, ok := plan.Ported[mobilephone]; ok {
+		operator = plan.Operators[index]
+		found = true
+	}
+
+	return operator, region, found
+}
+
+// Проверка номера по плану нумерации. Номер страны, для которой план загружен, должен входить в один из диапазонов
+func Valid(number string) bool {
+	mutex.RLock()
+	plan := current
+	mutex.RUnlock()
+	if plan == nil {
+		return true
+	}
+	country := CountryOf(number)
+	if country == nil || !plan.Countries[country.ISO] {
+		return true
+	}
+	mobilephone, err := strconv.ParseUint(number, 10, 64)
+	if err != nil {
+		return false
+	}
+
+	return plan.Find(mobilephone) != nil
+}
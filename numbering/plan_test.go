@@ -0,0 +1,76 @@
+package numbering
+
+import (
+	"application/config"
+	"application/models"
+	"io/ioutil"
+	"os"
+	"testing"
+)
+
+func writeTestFile(t *testing.T, content string) string {
+	file, err := ioutil.TempFile("", "numbering")
+	if err != nil {
+		t.Error("Can't create test file", err)
+		return ""
+	}
+	defer file.Close()
+	if _, err = file.WriteString(content); err != nil {
+		t.Error("Can't write test file", err)
+	}
+	return file.Name()
+}
+
+func TestLookup(t *testing.T) {
+	var numbering = config.Configuration.Numbering
+	defer func() {
+		config.Configuration.Numbering = numbering
+		mutex.Lock()
+		current = nil
+		mutex.Unlock()
+	}()
+
+	var plan = writeTestFile(t, "916;0000000;9999999;10000000;MTS;Moscow\n903;0000000;4999999;5000000;Beeline;Moscow region\n")
+	defer os.Remove(plan)
+	var ported = writeTestFile(t, "+7 903 123-45-67,MTS\nnumber,MTS\n")
+	defer os.Remove(ported)
+	config.Configuration.Numbering.Plans = map[string][]string{"RU": {plan}}
+	config.Configuration.Numbering.Ported = []string{ported}
+	config.Configuration.Numbering.Operators = nil
+
+	err := Init(&[]models.DtoMobileOperator{{ShortName: "MTS", UUID: "mts"}})
+	if err != nil {
+		t.Error("Numbering plan is not properly loaded", err)
+		return
+	}
+
+	var cases = []struct {
+		mobilephone uint64
+		operator    string
+		region      string
+		found       bool
+	}{
+		{79161234567, "mts", "Moscow", true},
+		{79031000000, "", "Moscow region", true},
+		{79031234567, "mts", "Moscow region", true},
+		{79036000000, "", "", false},
+		{375291234567, "", "", false},
+	}
+
+	for _, c := range cases {
+		operator, region, found := Lookup(c.mobilephone)
+		if operator != c.operator || region != c.region || found != c.found {
+			t.Error("Number", c.mobilephone, "is not properly found", operator, region, found)
+		}
+	}
+
+	if !Valid("79161234567") || Valid("79991234567") || !Valid("375291234567") {
+		t.Error("Numbers are not properly validated by the plan")
+	}
+}
+
+func TestLoadRangesMissingFile(t *testing.T) {
+	if err := NewPlan(nil).LoadRanges(FindCountry("RU"), "/nonexistent/plan.csv"); err == nil {
+		t.Error("Missing plan file should return error")
+	}
+}
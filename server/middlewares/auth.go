@@ -65,7 +65,8 @@ func RequireUserRights(r render.Render, session *models.DtoSession) {
 	}
 }
 
-func RequireTableRights(r render.Render, params martini.Params, customertablerepository services.CustomerTableRepository,
+func RequireTableRights(request *http.Request, r render.Render, params martini.Params,
+	customertablerepository services.CustomerTableRepository, unitrolerepository services.UnitRoleRepository,
 	session *models.DtoSession) {
 	if !IsAdmin(session.Roles) {
 		var allowed bool = false
@@ -92,6 +93,10 @@ func RequireTableRights(r render.Render, params martini.Params, customertablerep
 		} else {
 			log.Error("There is no required role for user %v", session.UserID)
 		}
+		if allowed {
+			allowed = HasPermission(session,
+				permissionByMethod(request, models.PERMISSION_TABLES_READ, models.PERMISSION_TABLES_WRITE), unitrolerepository)
+		}
 		if !allowed {
 			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
 				Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
@@ -101,7 +106,8 @@ func RequireTableRights(r render.Render, params martini.Params, customertablerep
 }
 
 func RequireEditableTable(r render.Render, params martini.Params, customertablerepository services.CustomerTableRepository,
-	pricepropertiesrepository services.PricePropertiesRepository, session *models.DtoSession) {
+	pricepropertiesrepository services.PricePropertiesRepository, unitrolerepository services.UnitRoleRepository,
+	session *models.DtoSession) {
 	if !IsAdmin(session.Roles) {
 		var allowed bool = false
 		param := params[helpers.PARAM_NAME_TABLE_ID]
@@ -144,6 +150,9 @@ func RequireEditableTable(r render.Render, params martini.Params, customertabler
 		} else {
 			log.Error("Parameter value is wrong %v", param)
 		}
+		if allowed {
+			allowed = HasPermission(session, models.PERMISSION_TABLES_WRITE, unitrolerepository)
+		}
 		if !allowed {
 			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
 				Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
@@ -152,7 +161,8 @@ func RequireEditableTable(r render.Render, params martini.Params, customertabler
 	}
 }
 
-func RequireOrderRights(r render.Render, params martini.Params, orderrepository services.OrderRepository, session *models.DtoSession) {
+func RequireOrderRights(request *http.Request, r render.Render, params martini.Params, orderrepository services.OrderRepository,
+	unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
 	if !IsAdmin(session.Roles) {
 		var allowed bool = false
 		if IsSupplier(session.Roles) {
@@ -175,6 +185,10 @@ func RequireOrderRights(r render.Render, params martini.Params, orderrepository
 		} else {
 			log.Error("There is no required role for user %v", session.UserID)
 		}
+		if allowed {
+			allowed = HasPermission(session,
+				permissionByMethod(request, models.PERMISSION_ORDERS_VIEW, models.PERMISSION_ORDERS_CONFIRM), unitrolerepository)
+		}
 		if !allowed {
 			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
 				Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
@@ -183,7 +197,8 @@ func RequireOrderRights(r render.Render, params martini.Params, orderrepository
 	}
 }
 
-func RequireMessageRights(r render.Render, params martini.Params, orderrepository services.OrderRepository, session *models.DtoSession) {
+func RequireMessageRights(r render.Render, params martini.Params, orderrepository services.OrderRepository,
+	unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
 	if !IsAdmin(session.Roles) {
 		var allowed bool = false
 		if IsUser(session.Roles) {
@@ -206,6 +221,9 @@ func RequireMessageRights(r render.Render, params martini.Params, orderrepositor
 		} else {
 			log.Error("There is no required role for user %v", session.UserID)
 		}
+		if allowed {
+			allowed = HasPermission(session, models.PERMISSION_ORDERS_VIEW, unitrolerepository)
+		}
 		if !allowed {
 			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
 				Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
@@ -214,7 +232,8 @@ func RequireMessageRights(r render.Render, params martini.Params, orderrepositor
 	}
 }
 
-func RequireProjectRights(r render.Render, params martini.Params, projectrepository services.ProjectRepository, session *models.DtoSession) {
+func RequireProjectRights(request *http.Request, r render.Render, params martini.Params,
+	projectrepository services.ProjectRepository, unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
 	if !IsAdmin(session.Roles) {
 		var allowed bool = false
 		if IsCustomer(session.Roles) {
@@ -237,6 +256,10 @@ func RequireProjectRights(r render.Render, params martini.Params, projectreposit
 		} else {
 			log.Error("There is no required role for user %v", session.UserID)
 		}
+		if allowed {
+			allowed = HasPermission(session,
+				permissionByMethod(request, models.PERMISSION_PROJECTS_VIEW, models.PERMISSION_PROJECTS_MANAGE), unitrolerepository)
+		}
 		if !allowed {
 			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
 				Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
@@ -245,7 +268,8 @@ func RequireProjectRights(r render.Render, params martini.Params, projectreposit
 	}
 }
 
-func RequireSMSSenderRights(r render.Render, params martini.Params, smssenderrepository services.SMSSenderRepository, session *models.DtoSession) {
+func RequireSMSSenderRights(r render.Render, params martini.Params, smssenderrepository services.SMSSenderRepository,
+	unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
 	if !IsAdmin(session.Roles) {
 		var allowed bool = false
 		if IsCustomer(session.Roles) {
@@ -268,6 +292,9 @@ func RequireSMSSenderRights(r render.Render, params martini.Params, smssenderrep
 		} else {
 			log.Error("There is no required role for user %v", session.UserID)
 		}
+		if allowed {
+			allowed = HasPermission(session, models.PERMISSION_SMSSENDERS_MANAGE, unitrolerepository)
+		}
 		if !allowed {
 			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
 				Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
@@ -276,7 +303,8 @@ func RequireSMSSenderRights(r render.Render, params martini.Params, smssenderrep
 	}
 }
 
-func RequireCompanyRights(r render.Render, params martini.Params, companyrepository services.CompanyRepository, session *models.DtoSession) {
+func RequireCompanyRights(r render.Render, params martini.Params, companyrepository services.CompanyRepository,
+	unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
 	if !IsAdmin(session.Roles) {
 		var allowed bool = false
 		if IsUser(session.Roles) {
@@ -299,6 +327,9 @@ func RequireCompanyRights(r render.Render, params martini.Params, companyreposit
 		} else {
 			log.Error("There is no required role for user %v", session.UserID)
 		}
+		if allowed {
+			allowed = HasPermission(session, models.PERMISSION_COMPANIES_MANAGE, unitrolerepository)
+		}
 		if !allowed {
 			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
 				Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
@@ -307,7 +338,8 @@ func RequireCompanyRights(r render.Render, params martini.Params, companyreposit
 	}
 }
 
-func RequireInvoiceRights(r render.Render, params martini.Params, invoicerepository services.InvoiceRepository, session *models.DtoSession) {
+func RequireInvoiceRights(request *http.Request, r render.Render, params martini.Params,
+	invoicerepository services.InvoiceRepository, unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
 	if !IsAdmin(session.Roles) {
 		var allowed bool = false
 		if IsCustomer(session.Roles) {
@@ -330,6 +362,10 @@ func RequireInvoiceRights(r render.Render, params martini.Params, invoicereposit
 		} else {
 			log.Error("There is no required role for user %v", session.UserID)
 		}
+		if allowed {
+			allowed = HasPermission(session,
+				permissionByMethod(request, models.PERMISSION_INVOICES_VIEW, models.PERMISSION_INVOICES_PAY), unitrolerepository)
+		}
 		if !allowed {
 			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
 				Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
@@ -338,7 +374,14 @@ func RequireInvoiceRights(r render.Render, params martini.Params, invoicereposit
 	}
 }
 
+// Администрирование объединения доступно только из сессии пользователя, ключ доступа не дает этих прав
 func RequireUnitRights(r render.Render, userrepository services.UserRepository, session *models.DtoSession) {
+	if session.ApiKeyID != 0 {
+		log.Error("Unit administration is not allowed for api key %v", session.ApiKeyID)
+		r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
+			Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
+		return
+	}
 	if !IsAdmin(session.Roles) {
 		var allowed bool = false
 		if IsUser(session.Roles) {
@@ -360,7 +403,8 @@ func RequireUnitRights(r render.Render, userrepository services.UserRepository,
 	}
 }
 
-func RequireReportAccessRights(r render.Render, userrepository services.UserRepository, session *models.DtoSession) {
+func RequireReportAccessRights(r render.Render, userrepository services.UserRepository,
+	unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
 	if !IsAdmin(session.Roles) {
 		var allowed bool = false
 		if IsCustomer(session.Roles) {
@@ -374,6 +418,9 @@ func RequireReportAccessRights(r render.Render, userrepository services.UserRepo
 		} else {
 			log.Error("There is no required role for user %v", session.UserID)
 		}
+		if allowed {
+			allowed = HasPermission(session, models.PERMISSION_REPORTS_VIEW, unitrolerepository)
+		}
 		if !allowed {
 			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
 				Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
@@ -383,7 +430,7 @@ func RequireReportAccessRights(r render.Render, userrepository services.UserRepo
 }
 
 func RequireReportRights(r render.Render, params martini.Params, userrepository services.UserRepository,
-	reportrepository services.ReportRepository, session *models.DtoSession) {
+	reportrepository services.ReportRepository, unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
 	if !IsAdmin(session.Roles) {
 		var allowed bool = false
 		if IsCustomer(session.Roles) {
@@ -413,6 +460,9 @@ func RequireReportRights(r render.Render, params martini.Params, userrepository
 		} else {
 			log.Error("There is no required role for user %v", session.UserID)
 		}
+		if allowed {
+			allowed = HasPermission(session, models.PERMISSION_REPORTS_VIEW, unitrolerepository)
+		}
 		if !allowed {
 			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
 				Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
@@ -421,7 +471,8 @@ func RequireReportRights(r render.Render, params martini.Params, userrepository
 	}
 }
 
-func RequireDocumentRights(r render.Render, params martini.Params, documentrepository services.DocumentRepository, session *models.DtoSession) {
+func RequireDocumentRights(r render.Render, params martini.Params, documentrepository services.DocumentRepository,
+	unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
 	if !IsAdmin(session.Roles) {
 		var allowed bool = false
 		if IsUser(session.Roles) {
@@ -444,6 +495,9 @@ func RequireDocumentRights(r render.Render, params martini.Params, documentrepos
 		} else {
 			log.Error("There is no required role for user %v", session.UserID)
 		}
+		if allowed {
+			allowed = HasPermission(session, models.PERMISSION_DOCUMENTS_VIEW, unitrolerepository)
+		}
 		if !allowed {
 			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
 				Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
@@ -475,33 +529,68 @@ func GeneratingSessionErrorResponse(r render.Render, token string) {
 	return
 }
 
-func RequireSession(request *http.Request, r render.Render, sessionrepository services.SessionRepository,
-	context martini.Context, params martini.Params, updateSession bool, takeParamFromURI bool) {
-	session, token, err := sessionrepository.GetAndSaveSession(request, r, params, updateSession, takeParamFromURI, false)
+// Запрос аутентифицируется ключом доступа из заголовка X-Api-Key, при его отсутствии - токеном сессии
+func RequireSession(request *http.Request, w http.ResponseWriter, r render.Render, sessionrepository services.SessionRepository,
+	ratelimitrepository services.RateLimitRepository, userrepository services.UserRepository,
+	apikeyrepository services.ApiKeyRepository, accesslogrepository services.AccessLogRepository, context martini.Context,
+	params martini.Params, updateSession bool, takeParamFromURI bool) {
+	var session *models.DtoSession
+	var token string
+	var err error
+	if key := request.Header.Get(helpers.API_KEY_HEADER_NAME); key != "" {
+		token = key
+		session, err = helpers.AuthenticateApiKey(key, request, apikeyrepository, userrepository, accesslogrepository)
+	} else {
+		session, token, err = sessionrepository.GetAndSaveSession(request, r, params, updateSession, takeParamFromURI, false)
+	}
 	if err != nil {
 		GeneratingSessionErrorResponse(r, token)
+	} else if !RateLimitSession(request, w, r, session, ratelimitrepository, userrepository) {
+		return
 	}
 	context.Map(session)
 }
 
-func RequireSessionCheckWithRoute(request *http.Request, r render.Render, sessionrepository services.SessionRepository,
-	context martini.Context, params martini.Params) {
-	RequireSession(request, r, sessionrepository, context, params, false, true)
+func RequireSessionCheckWithRoute(request *http.Request, w http.ResponseWriter, r render.Render,
+	sessionrepository services.SessionRepository, ratelimitrepository services.RateLimitRepository,
+	userrepository services.UserRepository, apikeyrepository services.ApiKeyRepository,
+	accesslogrepository services.AccessLogRepository, context martini.Context, params martini.Params) {
+	RequireSession(request, w, r, sessionrepository, ratelimitrepository, userrepository, apikeyrepository, accesslogrepository,
+		context, params, false, true)
 }
 
-func RequireSessionCheckWithoutRoute(request *http.Request, r render.Render, sessionrepository services.SessionRepository,
-	context martini.Context, params martini.Params) {
-	RequireSession(request, r, sessionrepository, context, params, false, false)
+func RequireSessionCheckWithoutRoute(request *http.Request, w http.ResponseWriter, r render.Render,
+	sessionrepository services.SessionRepository, ratelimitrepository services.RateLimitRepository,
+	userrepository services.UserRepository, apikeyrepository services.ApiKeyRepository,
+	accesslogrepository services.AccessLogRepository, context martini.Context, params martini.Params) {
+	RequireSession(request, w, r, sessionrepository, ratelimitrepository, userrepository, apikeyrepository, accesslogrepository,
+		context, params, false, false)
 }
 
-func RequireSessionKeepWithRoute(request *http.Request, r render.Render, sessionrepository services.SessionRepository,
-	context martini.Context, params martini.Params) {
-	RequireSession(request, r, sessionrepository, context, params, true, true)
+func RequireSessionKeepWithRoute(request *http.Request, w http.ResponseWriter, r render.Render,
+	sessionrepository services.SessionRepository, ratelimitrepository services.RateLimitRepository,
+	userrepository services.UserRepository, apikeyrepository services.ApiKeyRepository,
+	accesslogrepository services.AccessLogRepository, context martini.Context, params martini.Params) {
+	RequireSession(request, w, r, sessionrepository, ratelimitrepository, userrepository, apikeyrepository, accesslogrepository,
+		context, params, true, true)
 }
 
-func RequireSessionKeepWithoutRoute(request *http.Request, r render.Render, sessionrepository services.SessionRepository,
-	context martini.Context, params martini.Params) {
-	RequireSession(request, r, sessionrepository, context, params, true, false)
+func RequireSessionKeepWithoutRoute(request *http.Request, w http.ResponseWriter, r render.Render,
+	sessionrepository services.SessionRepository, ratelimitrepository services.RateLimitRepository,
+	userrepository services.UserRepository, apikeyrepository services.ApiKeyRepository,
+	accesslogrepository services.AccessLogRepository, context martini.Context, params martini.Params) {
+	RequireSession(request, w, r, sessionrepository, ratelimitrepository, userrepository, apikeyrepository, accesslogrepository,
+		context, params, true, false)
+}
+
+// Управление ключами доступа, аккаунтом и вторым фактором возможно только из сессии пользователя, но не по ключу
+func RequirePersonalSession(r render.Render, session *models.DtoSession) {
+	if session.ApiKeyID != 0 {
+		log.Error("Method is not allowed for api key %v", session.ApiKeyID)
+		r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
+			Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
+		return
+	}
 }
 
 func UtcNow() time.Time {
@@ -0,0 +1,51 @@
+package middlewares
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"github.com/go-martini/martini"
+	"github.com/martini-contrib/render"
+	"net/http"
+	"types"
+)
+
+// Проверка разрешения для маршрутов без проверки доступа к объекту
+func RequirePermission(permission string) martini.Handler {
+	return func(r render.Render, unitrolerepository services.UnitRoleRepository, session *models.DtoSession) {
+		if !IsAdmin(session.Roles) && !HasPermission(session, permission, unitrolerepository) {
+			r.JSON(http.StatusForbidden, types.Error{Code: types.TYPE_ERROR_METHOD_NOTALLOWED,
+				Message: config.Localization[session.Language].Errors.Api.Method_NotAllowed})
+			return
+		}
+	}
+}
+
+// Разрешение пользователя сессии по ролям объединения. Пользователям без назначенных ролей объединения
+// доступно все, что разрешено их уровнем доступа
+func HasPermission(session *models.DtoSession, permission string, unitrolerepository services.UnitRoleRepository) bool {
+	assigned, permissions, err := unitrolerepository.GetPermissionsByUser(session.UserID)
+	if err != nil {
+		return false
+	}
+	if !assigned {
+		return true
+	}
+	for _, value := range permissions {
+		if value == permission {
+			return true
+		}
+	}
+	log.Error("Permission %v is not granted to user %v", permission, session.UserID)
+
+	return false
+}
+
+// Разрешение на чтение или изменение в зависимости от метода запроса
+func permissionByMethod(request *http.Request, read string, write string) string {
+	switch request.Method {
+	case "GET", "HEAD", "OPTIONS":
+		return read
+	}
+	return write
+}
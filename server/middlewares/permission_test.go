@@ -0,0 +1,97 @@
+package middlewares
+
+import (
+	"application/models"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+type TestUnitRoleRepository struct {
+	Assigned    bool
+	Permissions []string
+	Err         error
+}
+
+func (testUnitRoleRepository *TestUnitRoleRepository) Get(id int64) (role *models.DtoUnitRole, err error) {
+	return nil, nil
+}
+
+func (testUnitRoleRepository *TestUnitRoleRepository) GetByUnit(unitid int64) (roles *[]models.DtoUnitRole, err error) {
+	return nil, nil
+}
+
+func (testUnitRoleRepository *TestUnitRoleRepository) Create(role *models.DtoUnitRole) (err error) {
+	return nil
+}
+
+func (testUnitRoleRepository *TestUnitRoleRepository) Update(role *models.DtoUnitRole) (err error) {
+	return nil
+}
+
+func (testUnitRoleRepository *TestUnitRoleRepository) Delete(id int64) (err error) {
+	return nil
+}
+
+func (testUnitRoleRepository *TestUnitRoleRepository) GetByUser(userid int64) (roleids []int64, err error) {
+	return nil, nil
+}
+
+func (testUnitRoleRepository *TestUnitRoleRepository) SetByUser(userid int64, roleids []int64) (err error) {
+	return nil
+}
+
+func (testUnitRoleRepository *TestUnitRoleRepository) GetPermissionsByUser(userid int64) (assigned bool, permissions []string, err error) {
+	return testUnitRoleRepository.Assigned, testUnitRoleRepository.Permissions, testUnitRoleRepository.Err
+}
+
+func TestHasPermission(t *testing.T) {
+	var session = &models.DtoSession{UserID: 1, Roles: []models.UserRole{models.USER_ROLE_CUSTOMER}}
+	var cases = []struct {
+		assigned    bool
+		permissions []string
+		err         error
+		permission  string
+		allowed     bool
+	}{
+		// Пользователю без ролей объединения доступно все, что разрешено уровнем доступа
+		{false, nil, nil, models.PERMISSION_ORDERS_CONFIRM, true},
+		{false, nil, nil, models.PERMISSION_INVOICES_PAY, true},
+		// Назначенные роли ограничивают пользователя их разрешениями
+		{true, []string{models.PERMISSION_ORDERS_VIEW, models.PERMISSION_ORDERS_CONFIRM}, nil, models.PERMISSION_ORDERS_CONFIRM, true},
+		{true, []string{models.PERMISSION_ORDERS_VIEW}, nil, models.PERMISSION_ORDERS_CONFIRM, false},
+		{true, []string{models.PERMISSION_PROJECTS_MANAGE}, nil, models.PERMISSION_ORDERS_CONFIRM, false},
+		{true, []string{}, nil, models.PERMISSION_PROJECTS_VIEW, false},
+		// Ошибка получения ролей запрещает доступ
+		{false, nil, errors.New("Roles error"), models.PERMISSION_ORDERS_VIEW, false},
+	}
+
+	for _, c := range cases {
+		var unitrolerepository = &TestUnitRoleRepository{Assigned: c.assigned, Permissions: c.permissions, Err: c.err}
+		if HasPermission(session, c.permission, unitrolerepository) != c.allowed {
+			t.Error("Permission", c.permission, "is not properly checked for", c.assigned, c.permissions, c.err)
+		}
+	}
+}
+
+func TestPermissionByMethod(t *testing.T) {
+	var cases = []struct {
+		method     string
+		permission string
+	}{
+		{"GET", models.PERMISSION_PROJECTS_VIEW},
+		{"HEAD", models.PERMISSION_PROJECTS_VIEW},
+		{"OPTIONS", models.PERMISSION_PROJECTS_VIEW},
+		{"POST", models.PERMISSION_PROJECTS_MANAGE},
+		{"PUT", models.PERMISSION_PROJECTS_MANAGE},
+		{"PATCH", models.PERMISSION_PROJECTS_MANAGE},
+		{"DELETE", models.PERMISSION_PROJECTS_MANAGE},
+	}
+
+	for _, c := range cases {
+		request, _ := http.NewRequest(c.method, "/api/v1.0/projects/1/", nil)
+		if value := permissionByMethod(request, models.PERMISSION_PROJECTS_VIEW, models.PERMISSION_PROJECTS_MANAGE); value != c.permission {
+			t.Error("Permission of method", c.method, "is not properly selected", value)
+		}
+	}
+}
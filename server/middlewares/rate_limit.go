@@ -0,0 +1,113 @@
+package middlewares
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"fmt"
+	"github.com/martini-contrib/render"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"types"
+)
+
+const (
+	RATE_LIMIT_STORE_MEMORY = "memory"
+	RATE_LIMIT_STORE_MYSQL  = "mysql"
+
+	RATE_LIMIT_PATH_PREFIX = "/api/"
+
+	HEADER_RATELIMIT_LIMIT     = "RateLimit-Limit"
+	HEADER_RATELIMIT_REMAINING = "RateLimit-Remaining"
+	HEADER_RATELIMIT_RESET     = "RateLimit-Reset"
+	HEADER_RETRY_AFTER         = "Retry-After"
+)
+
+// Ограничение частоты запросов к api по ip адресу клиента
+func RateLimit(request *http.Request, w http.ResponseWriter, r render.Render, ratelimitrepository services.RateLimitRepository) {
+	if !strings.HasPrefix(request.URL.Path, RATE_LIMIT_PATH_PREFIX) {
+		return
+	}
+	prefix, group := rateLimitGroup(request.URL.Path)
+	host, err := helpers.GetClientIP(request)
+	if err != nil {
+		log.Error("Can't detect ip address %v from %v", err, request.RemoteAddr)
+		return
+	}
+	takeRateLimit(fmt.Sprintf("ip:%v:%v", prefix, host), group.IP, w, r, ratelimitrepository,
+		config.Configuration.Server.DefaultLanguage)
+}
+
+// Ограничение частоты запросов по пользователю и объединению сессии, вызывается после проверки сессии
+func RateLimitSession(request *http.Request, w http.ResponseWriter, r render.Render, session *models.DtoSession,
+	ratelimitrepository services.RateLimitRepository, userrepository services.UserRepository) bool {
+	prefix, group := rateLimitGroup(request.URL.Path)
+	if !takeRateLimit(fmt.Sprintf("user:%v:%v", prefix, session.UserID), group.User, w, r, ratelimitrepository, session.Language) {
+		return false
+	}
+	if group.Unit.Rate <= 0 {
+		return true
+	}
+	user, err := userrepository.Get(session.UserID)
+	if err != nil {
+		return true
+	}
+
+	return takeRateLimit(fmt.Sprintf("unit:%v:%v", prefix, user.UnitID), group.Unit, w, r, ratelimitrepository, session.Language)
+}
+
+// Ограничения группы маршрутов с наиболее длинным совпадающим префиксом адреса
+func rateLimitGroup(path string) (prefix string, group config.RateLimitGroup) {
+	group = config.Configuration.RateLimit.Default
+	for name, limits := range config.Configuration.RateLimit.Groups {
+		if strings.HasPrefix(path, name) && len(name) > len(prefix) {
+			prefix = name
+			group = limits
+		}
+	}
+
+	return prefix, group
+}
+
+// Списание маркера из корзины ключа запроса. Заголовки RateLimit-* описывают наиболее исчерпанную корзину запроса,
+// при отсутствии маркеров отправляется ответ с заголовком Retry-After
+func takeRateLimit(bucket string, limit config.RateLimitBucket, w http.ResponseWriter, r render.Render,
+	ratelimitrepository services.RateLimitRepository, language string) bool {
+	if limit.Rate <= 0 {
+		return true
+	}
+	burst := limit.Burst
+	if burst <= 0 {
+		burst = int64(math.Max(1, math.Ceil(limit.Rate)))
+	}
+	// Недоступность хранилища счетчиков не блокирует запросы
+	ratelimit, allowed, err := ratelimitrepository.Take(bucket, limit.Rate, burst, time.Now())
+	if err != nil {
+		return true
+	}
+
+	header := w.Header()
+	remaining, err := strconv.ParseInt(header.Get(HEADER_RATELIMIT_REMAINING), 10, 64)
+	if err != nil || ratelimit.Remaining() <= remaining {
+		header.Set(HEADER_RATELIMIT_LIMIT, strconv.FormatInt(burst, 10))
+		header.Set(HEADER_RATELIMIT_REMAINING, strconv.FormatInt(ratelimit.Remaining(), 10))
+		header.Set(HEADER_RATELIMIT_RESET, strconv.FormatInt(rateLimitSeconds(ratelimit.Reset(limit.Rate, burst)), 10))
+	}
+	if !allowed {
+		header.Set(HEADER_RETRY_AFTER, strconv.FormatInt(rateLimitSeconds(ratelimit.RetryAfter(limit.Rate)), 10))
+		log.Error("Requests are too frequent for bucket %v", bucket)
+		r.JSON(http.StatusTooManyRequests, types.Error{Code: types.TYPE_ERROR_REQUEST_TOOFREQUENT,
+			Message: config.Localization[language].Errors.Api.Request_Too_Often})
+		return false
+	}
+
+	return true
+}
+
+func rateLimitSeconds(duration time.Duration) int64 {
+	return int64(math.Ceil(duration.Seconds()))
+}
@@ -76,6 +76,12 @@ func Routes() martini.Router {
 			Name("Завершение сеанса пользователя")
 		a.Delete("/", middlewares.RequireSessionKeepWithoutRoute, controllers.DeleteSession).
 			Name("Завершение сеанса пользователя")
+		// Подтверждение входа вторым фактором +
+		a.Post("/twofactor/", binding.Json(models.ViewTwoFactorLogin{}), controllers.VerifySessionTwoFactor).
+			Name("Подтверждение входа вторым фактором")
+		// Повторная отправка кода подтверждения входа +
+		a.Post("/twofactor/sms/", binding.Json(models.ViewTwoFactorToken{}), controllers.ResendSessionTwoFactorCode).
+			Name("Повторная отправка кода подтверждения входа")
 	})
 
 	router.Group("/api/v1.0/files", func(a martini.Router) {
@@ -128,22 +134,26 @@ func Routes() martini.Router {
 		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, controllers.GetUserInfo).
 			Name("Получение информации о пользователе")
 		// Изменение информации о пользователе +
-		a.Patch("/", middlewares.RequireSessionKeepWithoutRoute, binding.Json(models.ChangeUser{}), controllers.UpdateUserInfo).
+		a.Patch("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			binding.Json(models.ChangeUser{}), controllers.UpdateUserInfo).
 			Name("Изменение информации о пользователе")
 		// Получение информации о e-mail пользователя +
 		a.Get("/emails/", middlewares.RequireSessionKeepWithoutRoute, controllers.GetUserEmails).
 			Name("Получение информации о e-mail пользователя")
 		// Изменение информации о e-mail пользователя +
-		a.Put("/emails/", middlewares.RequireSessionKeepWithoutRoute, binding.Json(models.UpdateEmails{}), controllers.UpdateUserEmails).
+		a.Put("/emails/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			binding.Json(models.UpdateEmails{}), controllers.UpdateUserEmails).
 			Name("Изменение информации о e-mail пользователя")
 		// Изменение пароля пользователя на новый +
-		a.Patch("/password/", middlewares.RequireSessionKeepWithoutRoute, binding.Json(models.ChangePassword{}), controllers.ChangePassword).
+		a.Patch("/password/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			binding.Json(models.ChangePassword{}), controllers.ChangePassword).
 			Name("Изменение пароля пользователя на новый")
 		// Получение информации о мобильных телефонах пользователя +
 		a.Get("/mobilephones/", middlewares.RequireSessionKeepWithoutRoute, controllers.GetUserMobilePhones).
 			Name("Получение информации о мобильных телефонах пользователя")
 		// Изменение информации о мобильных телефонах пользователя +
-		a.Put("/mobilephones/", middlewares.RequireSessionKeepWithoutRoute, binding.Json(models.UpdateMobilePhones{}), controllers.UpdateUserMobilePhones).
+		a.Put("/mobilephones/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			binding.Json(models.UpdateMobilePhones{}), controllers.UpdateUserMobilePhones).
 			Name("Изменение информации о мобильных телефонах пользователя")
 	})
 
@@ -152,7 +162,8 @@ func Routes() martini.Router {
 		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, controllers.GetUserUnit).
 			Name("Получение информации об объединении")
 		// Внесение изменений в информацию об объединении +
-		a.Patch("/", middlewares.RequireSessionKeepWithoutRoute, binding.Json(models.ViewShortUnit{}), controllers.UpdateUserUnit).
+		a.Patch("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			binding.Json(models.ViewShortUnit{}), controllers.UpdateUserUnit).
 			Name("Внесение изменений в информацию об объединении")
 	})
 
@@ -164,14 +175,23 @@ func Routes() martini.Router {
 		a.Patch("/", middlewares.RequireSessionKeepWithoutRoute, binding.Json(models.ChangeContract{}), middlewares.RequireUserRights,
 			controllers.UpdateContracts).
 			Name("Изменения в договорных отношениях")
+		// Печать/экспорт договора +
+		a.Get("/:orgid/export/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights, controllers.GetExportContract).
+			Name("Печать/экспорт договора")
+		// Проверка статуса готовности экспортируемого договора +
+		a.Options("/:orgid/export/:fid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights,
+			controllers.GetExportContractStatus).
+			Name("Проверка статуса готовности экспортируемого договора")
 	})
 
 	router.Group("/api/v1.0/unit/documents", func(a martini.Router) {
 		// Сводная информация о документах объединения +
-		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights, controllers.GetMetaDocuments).
+		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights,
+			middlewares.RequirePermission(models.PERMISSION_DOCUMENTS_VIEW), controllers.GetMetaDocuments).
 			Name("Сводная информация о документах объединения")
 		// Получение списка документов объединения +
-		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights, controllers.GetDocuments).
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights,
+			middlewares.RequirePermission(models.PERMISSION_DOCUMENTS_VIEW), controllers.GetDocuments).
 			Name("Получение списка документов объединения")
 		// Добавление документа +
 		a.Post("/", middlewares.RequireSessionKeepWithoutRoute, binding.Json(models.ViewLongDocument{}), middlewares.RequireUserRights,
@@ -211,10 +231,12 @@ func Routes() martini.Router {
 
 	router.Group("/api/v1.0/unit/header", func(a martini.Router) {
 		// Сводная информация о header объединения +
-		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights, controllers.GetMetaSMSSenders).
+		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			middlewares.RequirePermission(models.PERMISSION_SMSSENDERS_MANAGE), controllers.GetMetaSMSSenders).
 			Name("Сводная информация о header объединения")
 		// Получение header объединения +
-		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights, controllers.GetSMSSenders).
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			middlewares.RequirePermission(models.PERMISSION_SMSSENDERS_MANAGE), controllers.GetSMSSenders).
 			Name("Получение header объединения")
 		// Внесение изменений в настройки header +
 		a.Patch("/:hdrid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireSMSSenderRights,
@@ -235,6 +257,44 @@ func Routes() martini.Router {
 			Name("Изменение настроек оплаты доступа в систему")
 	})
 
+	router.Group("/api/v1.0/unit/suppressions", func(a martini.Router) {
+		// Получение списка запрета рассылок объединения +
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights, controllers.GetSuppressions).
+			Name("Получение списка запрета рассылок объединения")
+		// Добавление номера в список запрета рассылок объединения +
+		a.Post("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			binding.Json(models.ViewSuppression{}), controllers.CreateSuppression).
+			Name("Добавление номера в список запрета рассылок объединения")
+		// Добавление номеров из пользовательской таблицы в список запрета рассылок объединения +
+		a.Post("/import/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			binding.Json(models.ViewImportSuppressions{}), controllers.ImportSuppressions).
+			Name("Добавление номеров из пользовательской таблицы в список запрета рассылок объединения")
+		// Удаление номера из списка запрета рассылок объединения +
+		a.Delete("/:sid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights, controllers.DeleteSuppression).
+			Name("Удаление номера из списка запрета рассылок объединения")
+	})
+
+	router.Group("/api/v1.0/unit/webhooks", func(a martini.Router) {
+		// Получение списка адресов уведомлений объединения +
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights, controllers.GetWebhooks).
+			Name("Получение списка адресов уведомлений объединения")
+		// Создание адреса уведомлений объединения +
+		a.Post("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			binding.Json(models.ViewWebhook{}), controllers.CreateWebhook).
+			Name("Создание адреса уведомлений объединения")
+		// Изменение адреса уведомлений объединения +
+		a.Put("/:whid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			binding.Json(models.ViewWebhook{}), controllers.UpdateWebhook).
+			Name("Изменение адреса уведомлений объединения")
+		// Удаление адреса уведомлений объединения +
+		a.Delete("/:whid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights, controllers.DeleteWebhook).
+			Name("Удаление адреса уведомлений объединения")
+		// Получение журнала доставки уведомлений +
+		a.Get("/:whid/deliveries/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			controllers.GetWebhookDeliveries).
+			Name("Получение журнала доставки уведомлений")
+	})
+
 	router.Group("/api/v1.0/user/devices", func(a martini.Router) {
 		// Получение устройством кодов привязки к аккаунту пользователя +
 		a.Post("/link/", binding.Json(models.ViewLongDevice{}), controllers.CreateDevice).
@@ -243,10 +303,109 @@ func Routes() martini.Router {
 		a.Post("/", binding.Json(models.ViewHashDevice{}), controllers.UpdateDevice).
 			Name("Привязка устройства к аккаунту пользователя")
 		// Ввод кода привязки устройства к аккаунту пользователя +
-		a.Post("/code/", middlewares.RequireSessionKeepWithoutRoute, binding.Json(models.ViewCodeDevice{}), controllers.LinkDevice).
+		a.Post("/code/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			binding.Json(models.ViewCodeDevice{}), controllers.LinkDevice).
 			Name("Ввод кода привязки устройства к аккаунту пользователя")
 	})
 
+	router.Group("/api/v1.0/user/sessions", func(a martini.Router) {
+		// Получение списка действующих сессий пользователя +
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession, controllers.GetUserSessions).
+			Name("Получение списка действующих сессий пользователя")
+		// Завершение всех сессий пользователя, кроме текущей +
+		a.Delete("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession, controllers.DeleteUserSessions).
+			Name("Завершение всех сессий пользователя, кроме текущей")
+		// Завершение сессии пользователя +
+		a.Delete("/:sessionId/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			controllers.DeleteUserSession).
+			Name("Завершение сессии пользователя")
+	})
+
+	router.Group("/api/v1.0/user/twofactor", func(a martini.Router) {
+		// Получение состояния второго фактора +
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession, controllers.GetTwoFactor).
+			Name("Получение состояния второго фактора")
+		// Подключение приложения одноразовых паролей +
+		a.Post("/totp/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession, controllers.CreateTwoFactorTOTP).
+			Name("Подключение приложения одноразовых паролей")
+		// Подключение одноразовых кодов в SMS +
+		a.Post("/sms/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession, controllers.CreateTwoFactorSMS).
+			Name("Подключение одноразовых кодов в SMS")
+		// Подтверждение подключения второго фактора +
+		a.Put("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			binding.Json(models.ViewTwoFactorCode{}), controllers.ConfirmTwoFactor).
+			Name("Подтверждение подключения второго фактора")
+		// Выпуск новых кодов восстановления +
+		a.Post("/recovery/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			binding.Json(models.ViewTwoFactorCode{}), controllers.CreateRecoveryCodes).
+			Name("Выпуск новых кодов восстановления")
+		// Отключение второго фактора +
+		a.Post("/disable/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			binding.Json(models.ViewTwoFactorCode{}), controllers.DisableTwoFactor).
+			Name("Отключение второго фактора")
+	})
+
+	router.Group("/api/v1.0/user/administration/twofactor", func(a martini.Router) {
+		// Получение политики второго фактора объединения +
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUnitRights, controllers.GetUnitTwoFactorPolicy).
+			Name("Получение политики второго фактора объединения")
+		// Изменение политики второго фактора объединения +
+		a.Put("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUnitRights,
+			binding.Json(models.ViewTwoFactorPolicy{}), controllers.UpdateUnitTwoFactorPolicy).
+			Name("Изменение политики второго фактора объединения")
+	})
+
+	router.Group("/api/v1.0/user/administration/apikeys", func(a martini.Router) {
+		// Получение списка ключей доступа объединения +
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession, middlewares.RequireUnitRights,
+			controllers.GetApiKeys).
+			Name("Получение списка ключей доступа объединения")
+		// Создание ключа доступа +
+		a.Post("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession, middlewares.RequireUnitRights,
+			binding.Json(models.ViewApiKey{}), controllers.CreateApiKey).
+			Name("Создание ключа доступа")
+		// Получение информации о ключе доступа +
+		a.Get("/:keyId/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession, middlewares.RequireUnitRights,
+			controllers.GetApiKey).
+			Name("Получение информации о ключе доступа")
+		// Изменение ключа доступа +
+		a.Put("/:keyId/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession, middlewares.RequireUnitRights,
+			binding.Json(models.ViewApiKey{}), controllers.UpdateApiKey).
+			Name("Изменение ключа доступа")
+		// Замена ключа доступа +
+		a.Post("/:keyId/rotate/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			middlewares.RequireUnitRights, controllers.RotateApiKey).
+			Name("Замена ключа доступа")
+		// Отзыв ключа доступа +
+		a.Delete("/:keyId/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			middlewares.RequireUnitRights, controllers.DeleteApiKey).
+			Name("Отзыв ключа доступа")
+	})
+
+	router.Group("/api/v1.0/user/administration/roles", func(a martini.Router) {
+		// Получение перечня разрешений для ролей объединения +
+		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUnitRights, controllers.GetPermissions).
+			Name("Получение перечня разрешений для ролей объединения")
+		// Получение списка ролей объединения +
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUnitRights, controllers.GetUnitRoles).
+			Name("Получение списка ролей объединения")
+		// Создание роли объединения +
+		a.Post("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession, middlewares.RequireUnitRights,
+			binding.Json(models.ViewUnitRole{}), controllers.CreateUnitRole).
+			Name("Создание роли объединения")
+		// Получение информации о роли объединения +
+		a.Get("/:roleId/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUnitRights, controllers.GetUnitRole).
+			Name("Получение информации о роли объединения")
+		// Изменение роли объединения +
+		a.Put("/:roleId/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession, middlewares.RequireUnitRights,
+			binding.Json(models.ViewUnitRole{}), controllers.UpdateUnitRole).
+			Name("Изменение роли объединения")
+		// Удаление роли объединения +
+		a.Delete("/:roleId/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			middlewares.RequireUnitRights, controllers.DeleteUnitRole).
+			Name("Удаление роли объединения")
+	})
+
 	router.Group("/api/v1.0/user/administration/users", func(a martini.Router) {
 		// Сводная информация о пользователях юнита +
 		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUnitRights, controllers.GetMetaUnitUser).
@@ -268,6 +427,17 @@ func Routes() martini.Router {
 		// Удаление пользователя объединения +
 		a.Delete("/:uid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUnitRights, controllers.DeleteUnitUser).
 			Name("Удаление пользователя объединения")
+		// Получение ролей пользователя объединения +
+		a.Get("/:uid/roles/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUnitRights, controllers.GetUnitUserRoles).
+			Name("Получение ролей пользователя объединения")
+		// Назначение ролей пользователю объединения +
+		a.Put("/:uid/roles/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			middlewares.RequireUnitRights, binding.Json(models.ViewUnitUserRoles{}), controllers.UpdateUnitUserRoles).
+			Name("Назначение ролей пользователю объединения")
+		// Завершение всех сессий пользователя объединения +
+		a.Delete("/:uid/sessions/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequirePersonalSession,
+			middlewares.RequireUnitRights, controllers.DeleteUnitUserSessions).
+			Name("Завершение всех сессий пользователя объединения")
 	})
 
 	router.Group("/api/v1.0/users", func(a martini.Router) {
@@ -319,6 +489,10 @@ func Routes() martini.Router {
 		// Удаление пользователя +
 		a.Delete("/:userid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights, administration.DeleteUser).
 			Name("Удаление пользователя")
+		// Завершение всех сессий пользователя +
+		a.Delete("/:userid/sessions/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights,
+			administration.DeleteUserSessions).
+			Name("Завершение всех сессий пользователя")
 	})
 
 	router.Group("/api/v1.0/administration/units", func(a martini.Router) {
@@ -348,6 +522,18 @@ func Routes() martini.Router {
 		// Получение списка пользователей объединения +
 		a.Get("/:unitId/users/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights, administration.GetUnitUsers).
 			Name("Получение списка пользователей объединения")
+		// Получение политики второго фактора объединения +
+		a.Get("/:unitId/twofactor/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights,
+			administration.GetUnitTwoFactorPolicy).
+			Name("Получение политики второго фактора объединения")
+		// Изменение политики второго фактора объединения +
+		a.Put("/:unitId/twofactor/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights,
+			binding.Json(models.ViewTwoFactorPolicy{}), administration.UpdateUnitTwoFactorPolicy).
+			Name("Изменение политики второго фактора объединения")
+		// Завершение всех сессий пользователей объединения +
+		a.Delete("/:unitId/sessions/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights,
+			administration.DeleteUnitSessions).
+			Name("Завершение всех сессий пользователей объединения")
 		// Получение списка таблиц объединения +
 		a.Get("/:unitId/tables/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights, administration.GetUnitTables).
 			Name("Получение списка таблиц объединения")
@@ -369,6 +555,10 @@ func Routes() martini.Router {
 		// Получение списка счетов организаций объединения +
 		a.Get("/:unitId/invoices/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights, administration.GetUnitInvoices).
 			Name("Получение списка счетов организаций объединения")
+		// Оплата счета на пополнение баланса объединения
+		a.Patch("/:unitId/invoices/:iid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights,
+			administration.PayUnitInvoice).
+			Name("Оплата счета на пополнение баланса объединения")
 	})
 
 	router.Group("/api/v1.0/administration/orders", func(a martini.Router) {
@@ -390,6 +580,37 @@ func Routes() martini.Router {
 			Name("Удаление заказа")
 	})
 
+	router.Group("/api/v1.0/administration/suppressions", func(a martini.Router) {
+		// Получение общего списка запрета рассылок +
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights, administration.GetSuppressions).
+			Name("Получение общего списка запрета рассылок")
+		// Добавление номера в общий список запрета рассылок +
+		a.Post("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights,
+			binding.Json(models.ViewSuppression{}), administration.CreateSuppression).
+			Name("Добавление номера в общий список запрета рассылок")
+		// Добавление номеров из пользовательской таблицы в общий список запрета рассылок +
+		a.Post("/import/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights,
+			binding.Json(models.ViewImportSuppressions{}), administration.ImportSuppressions).
+			Name("Добавление номеров из пользовательской таблицы в общий список запрета рассылок")
+		// Удаление номера из общего списка запрета рассылок +
+		a.Delete("/:sid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights, administration.DeleteSuppression).
+			Name("Удаление номера из общего списка запрета рассылок")
+	})
+
+	router.Group("/api/v1.0/administration/addresses", func(a martini.Router) {
+		// Стандартизация адреса по справочнику ФИАС +
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights, administration.StandardizeAddress).
+			Name("Стандартизация адреса по справочнику ФИАС")
+		// Получение журнала загрузок справочника адресов +
+		a.Get("/imports/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights,
+			administration.GetAddressImports).
+			Name("Получение журнала загрузок справочника адресов")
+		// Загрузка архива ГАР в справочник адресов +
+		a.Post("/imports/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireAdminRights,
+			binding.Json(models.ViewAddressImport{}), administration.CreateAddressImport).
+			Name("Загрузка архива ГАР в справочник адресов")
+	})
+
 	router.Group("/api/v1.0/classification", func(a martini.Router) {
 		// Получение справочника классификации контактов  +
 		a.Get("/contacts/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights, controllers.GetAvailableContacts).
@@ -507,12 +728,28 @@ func Routes() martini.Router {
 			Name("Изменение списка оказываемых услуг поставщиком услуг")
 	})
 
+	router.Group("/api/v1.0/suppliers/replies", func(a martini.Router) {
+		// Передача поставщиком ответа абонента на sms рассылку +
+		a.Post("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireSupplierRights,
+			binding.Json(models.ViewSMSReply{}), controllers.CreateSMSReply).
+			Name("Передача поставщиком ответа абонента на sms рассылку")
+	})
+
+	router.Group("/api/v1.0/suppliers/reports", func(a martini.Router) {
+		// Передача поставщиком отчетов о доставке sms сообщений и hlr запросов +
+		a.Post("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireSupplierRights,
+			binding.Json(models.ViewDeliveryReports{}), controllers.CreateDeliveryReports).
+			Name("Передача поставщиком отчетов о доставке sms сообщений и hlr запросов")
+	})
+
 	router.Group("/api/v1.0/suppliers/orders", func(a martini.Router) {
 		// Получение общей информации о заказах +
-		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireSupplierRights, controllers.GetMetaOrders).
+		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireSupplierRights,
+			middlewares.RequirePermission(models.PERMISSION_ORDERS_VIEW), controllers.GetMetaOrders).
 			Name("Получение общей информации о заказах")
 		// Получение списка заказов +
-		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireSupplierRights, controllers.GetOrders).
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireSupplierRights,
+			middlewares.RequirePermission(models.PERMISSION_ORDERS_VIEW), controllers.GetOrders).
 			Name("Получение списка заказов")
 		// Получение полной информации о заказе +
 		a.Get("/:oid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireOrderRights, controllers.GetOrder).
@@ -568,17 +805,20 @@ func Routes() martini.Router {
 
 	router.Group("/api/v1.0/tables", func(a martini.Router) {
 		// Сводная информация о списке таблиц +
-		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights, controllers.GetMetaUnitTables).
+		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights,
+			middlewares.RequirePermission(models.PERMISSION_TABLES_READ), controllers.GetMetaUnitTables).
 			Name("Сводная информация о списке таблиц")
 		// Получение списка типов таблиц +
 		a.Get("/types", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights, controllers.GetTableTypes).
 			Name("Получение списка типов таблиц")
 		// Создание таблицы +
 		a.Post("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights,
+			middlewares.RequirePermission(models.PERMISSION_TABLES_WRITE),
 			binding.Json(models.ViewShortCustomerTable{}), controllers.CreateTable).
 			Name("Создание таблицы")
 		// Получение списка таблиц +
-		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights, controllers.GetUnitTables).
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights,
+			middlewares.RequirePermission(models.PERMISSION_TABLES_READ), controllers.GetUnitTables).
 			Name("Получение списка таблиц")
 		// Получение списка типов колонок +
 		a.Get("/fieldtypes/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights, controllers.GetColumnTypes).
@@ -594,6 +834,10 @@ func Routes() martini.Router {
 		a.Options("/import/:tmpid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireTableRights,
 			controllers.GetImportDataStatus).
 			Name("Проверка статуса импорта таблицы")
+		// Получение списка отклоненных при импорте строк +
+		a.Get("/import/:tmpid/rejections/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireTableRights,
+			controllers.GetImportDataRejections).
+			Name("Получение списка отклоненных при импорте строк")
 		// Получение списка колонок импортируемой таблицы +
 		a.Get("/import/:tmpid/columns/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireTableRights,
 			controllers.GetImportDataColumns).
@@ -691,6 +935,22 @@ func Routes() martini.Router {
 		// Проверка статуса готовности экспортируемого файла +
 		a.Options("/:tid/export/:fid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireTableRights, controllers.GetExportDataStatus).
 			Name("Проверка статуса готовности экспортируемого файла")
+		// Запуск поиска дубликатов в таблице +
+		a.Post("/:tid/dedup/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireTableRights,
+			middlewares.RequireEditableTable, binding.Json(models.ViewTableDedup{}), controllers.CreateTableDedup).
+			Name("Запуск поиска дубликатов в таблице")
+		// Проверка статуса поиска и объединения дубликатов +
+		a.Options("/:tid/dedup/:did/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireTableRights,
+			controllers.GetTableDedupStatus).
+			Name("Проверка статуса поиска и объединения дубликатов")
+		// Получение групп дубликатов +
+		a.Get("/:tid/dedup/:did/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireTableRights,
+			controllers.GetTableDedupGroups).
+			Name("Получение групп дубликатов")
+		// Объединение групп дубликатов или отключение лишних строк +
+		a.Post("/:tid/dedup/:did/merge/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireTableRights,
+			middlewares.RequireEditableTable, binding.Json(models.ViewTableMerge{}), controllers.MergeTableDedup).
+			Name("Объединение групп дубликатов или отключение лишних строк")
 		// Изменение настроек для таблицы являющейся прайс-листом  +
 		a.Put("/:tid/price/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireSupplierRights, middlewares.RequireTableRights,
 			binding.Json(models.ViewApiPriceProperties{}), controllers.UpdatePriceTable).
@@ -734,14 +994,16 @@ func Routes() martini.Router {
 
 	router.Group("/api/v1.0/customers/invoices", func(a martini.Router) {
 		// Получение общей информации о счетах +
-		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights, controllers.GetMetaInvoices).
+		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			middlewares.RequirePermission(models.PERMISSION_INVOICES_VIEW), controllers.GetMetaInvoices).
 			Name("Получение общей информации о счетах")
 		// Получение списка счетов +
-		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights, controllers.GetInvoices).
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			middlewares.RequirePermission(models.PERMISSION_INVOICES_VIEW), controllers.GetInvoices).
 			Name("Получение списка счетов")
 		// Создание счёта на оплату +
 		a.Post("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
-			binding.Json(models.ViewInvoice{}), controllers.CreateInvoice).
+			middlewares.RequirePermission(models.PERMISSION_INVOICES_PAY), binding.Json(models.ViewInvoice{}), controllers.CreateInvoice).
 			Name("Создание счёта на оплату")
 		// Получение подробной информации о счёте +
 		a.Get("/:iid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireInvoiceRights, controllers.GetInvoice).
@@ -763,10 +1025,12 @@ func Routes() martini.Router {
 
 	router.Group("/api/v1.0/projects", func(a martini.Router) {
 		// Получение общей информации о проектах +
-		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights, controllers.GetMetaProjects).
+		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			middlewares.RequirePermission(models.PERMISSION_PROJECTS_VIEW), controllers.GetMetaProjects).
 			Name("Получение общей информации о проектах")
 		// Получение списка проектов +
-		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights, controllers.GetAllProjects).
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			middlewares.RequirePermission(models.PERMISSION_PROJECTS_VIEW), controllers.GetAllProjects).
 			Name("Получение списка проектов")
 		// Получение списка проектов находящихся в работе +
 		a.Get("/onthego/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights, controllers.GetActiveProjects).
@@ -776,6 +1040,7 @@ func Routes() martini.Router {
 			Name("Получение списка проектов находящихся в архиве")
 		// Создание проекта +
 		a.Post("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			middlewares.RequirePermission(models.PERMISSION_PROJECTS_MANAGE),
 			binding.Json(models.ViewProject{}), controllers.CreateProject).
 			Name("Создание проекта")
 		// Получение проекта +
@@ -796,17 +1061,24 @@ func Routes() martini.Router {
 			Name("Получение списка заказов проекта")
 		// Создание нового заказа проекта +
 		a.Post("/:prid/orders/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireProjectRights,
+			middlewares.RequirePermission(models.PERMISSION_ORDERS_CONFIRM),
 			binding.Json(models.ViewShortOrder{}), controllers.CreateProjectOrder).
 			Name("Создание нового заказа проекта")
 		// Получение полной информации о заказе проекта +
 		a.Get("/:prid/orders/:oid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireProjectRights, controllers.GetProjectOrder).
 			Name("Получение полной информации о заказе проекта")
+		// Предварительный расчет стоимости заказа проекта +
+		a.Get("/:prid/orders/:oid/quote/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireProjectRights,
+			controllers.GetProjectOrderQuote).
+			Name("Предварительный расчет стоимости заказа проекта")
 		// Изменение информации о заказе проекта +
 		a.Patch("/:prid/orders/:oid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireProjectRights,
+			middlewares.RequirePermission(models.PERMISSION_ORDERS_CONFIRM),
 			binding.Json(models.ViewMiddleOrder{}), controllers.UpdateProjectOrder).
 			Name("Изменение информации о заказе проекта")
 		// Удаление заказа проекта +
-		a.Delete("/:prid/orders/:oid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireProjectRights, controllers.DeleteProjectOrder).
+		a.Delete("/:prid/orders/:oid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireProjectRights,
+			middlewares.RequirePermission(models.PERMISSION_ORDERS_CONFIRM), controllers.DeleteProjectOrder).
 			Name("Удаление заказа проекта")
 		// Получение расширенной информации заказа - SMS рассылка +
 		a.Get("/:prid/orders/:oid/service/sms/", middlewares.RequireSessionKeepWithoutRoute,
@@ -814,15 +1086,43 @@ func Routes() martini.Router {
 			Name("Получение расширенной информации заказа - SMS рассылка")
 		// Внесение изменений в расширенную информацию заказа - SMS рассылка +
 		a.Put("/:prid/orders/:oid/service/sms/", middlewares.RequireSessionKeepWithoutRoute,
-			middlewares.RequireProjectRights, binding.Json(models.ViewSMSFacility{}), controllers.UpdateProjectSMSOrder).
+			middlewares.RequireProjectRights, middlewares.RequirePermission(models.PERMISSION_ORDERS_CONFIRM),
+			binding.Json(models.ViewSMSFacility{}), controllers.UpdateProjectSMSOrder).
 			Name("Внесение изменений в расширенную информацию заказа - SMS рассылка")
+		// Получение расписания заказа - SMS рассылка +
+		a.Get("/:prid/orders/:oid/service/sms/schedule/", middlewares.RequireSessionKeepWithoutRoute,
+			middlewares.RequireProjectRights, controllers.GetProjectSMSSchedule).
+			Name("Получение расписания заказа - SMS рассылка")
+		// Приостановка и возобновление расписания заказа - SMS рассылка +
+		a.Put("/:prid/orders/:oid/service/sms/schedule/", middlewares.RequireSessionKeepWithoutRoute,
+			middlewares.RequireProjectRights, middlewares.RequirePermission(models.PERMISSION_ORDERS_CONFIRM),
+			binding.Json(models.ViewSMSSchedule{}), controllers.UpdateProjectSMSSchedule).
+			Name("Приостановка и возобновление расписания заказа - SMS рассылка")
+		// Получение хода рассылки по часовым поясам заказа - SMS рассылка +
+		a.Get("/:prid/orders/:oid/service/sms/zones/", middlewares.RequireSessionKeepWithoutRoute,
+			middlewares.RequireProjectRights, controllers.GetProjectSMSBatches).
+			Name("Получение хода рассылки по часовым поясам заказа - SMS рассылка")
+		// Предварительный просмотр текстов и количества частей сообщений заказа - SMS рассылка +
+		a.Get("/:prid/orders/:oid/service/sms/preview/", middlewares.RequireSessionKeepWithoutRoute,
+			middlewares.RequireProjectRights, controllers.GetProjectSMSPreview).
+			Name("Предварительный просмотр текстов и количества частей сообщений заказа - SMS рассылка")
+		// Получение маршрутизации заказа по поставщикам - SMS рассылка +
+		a.Get("/:prid/orders/:oid/service/sms/routing/", middlewares.RequireSessionKeepWithoutRoute,
+			middlewares.RequireProjectRights, controllers.GetProjectSMSRouting).
+			Name("Получение маршрутизации заказа по поставщикам - SMS рассылка")
+		// Изменение политики выбора поставщиков заказа - SMS рассылка +
+		a.Put("/:prid/orders/:oid/service/sms/routing/", middlewares.RequireSessionKeepWithoutRoute,
+			middlewares.RequireProjectRights, middlewares.RequirePermission(models.PERMISSION_ORDERS_CONFIRM),
+			binding.Json(models.ViewOrderRouting{}), controllers.UpdateProjectSMSRouting).
+			Name("Изменение политики выбора поставщиков заказа - SMS рассылка")
 		// Получение расширенной информации заказа - HLR запросы +
 		a.Get("/:prid/orders/:oid/service/hlr/", middlewares.RequireSessionKeepWithoutRoute,
 			middlewares.RequireProjectRights, controllers.GetProjectHLROrder).
 			Name("Получение расширенной информации заказа - HLR запросы")
 		// Внесение изменений в расширенную информацию заказа - HLR запросы +
 		a.Put("/:prid/orders/:oid/service/hlr/", middlewares.RequireSessionKeepWithoutRoute,
-			middlewares.RequireProjectRights, binding.Json(models.ViewHLRFacility{}), controllers.UpdateProjectHLROrder).
+			middlewares.RequireProjectRights, middlewares.RequirePermission(models.PERMISSION_ORDERS_CONFIRM),
+			binding.Json(models.ViewHLRFacility{}), controllers.UpdateProjectHLROrder).
 			Name("Внесение изменений в расширенную информацию заказа - HLR запросы")
 		// Получение расширенной информации заказа - Ввод данных +
 		a.Get("/:prid/orders/:oid/service/recognize/", middlewares.RequireSessionKeepWithoutRoute,
@@ -830,7 +1130,8 @@ func Routes() martini.Router {
 			Name("Получение расширенной информации заказа - Ввод данных")
 		// Внесение изменений в расширенную информацию заказа - Ввод данных +
 		a.Put("/:prid/orders/:oid/service/recognize/", middlewares.RequireSessionKeepWithoutRoute,
-			middlewares.RequireProjectRights, binding.Json(models.ViewRecognizeFacility{}), controllers.UpdateProjectRecognizeOrder).
+			middlewares.RequireProjectRights, middlewares.RequirePermission(models.PERMISSION_ORDERS_CONFIRM),
+			binding.Json(models.ViewRecognizeFacility{}), controllers.UpdateProjectRecognizeOrder).
 			Name("Внесение изменений в расширенную информацию заказа - Ввод данных")
 		// Получение расширенной информации заказа - Верификация базы данных +
 		a.Get("/:prid/orders/:oid/service/verification/", middlewares.RequireSessionKeepWithoutRoute,
@@ -838,7 +1139,8 @@ func Routes() martini.Router {
 			Name("Получение расширенной информации заказа - Верификация базы данных")
 		// Внесение изменений в расширенную информацию заказа - Верификация базы данных +
 		a.Put("/:prid/orders/:oid/service/verification/", middlewares.RequireSessionKeepWithoutRoute,
-			middlewares.RequireProjectRights, binding.Json(models.ViewVerifyFacility{}), controllers.UpdateProjectVerifyOrder).
+			middlewares.RequireProjectRights, middlewares.RequirePermission(models.PERMISSION_ORDERS_CONFIRM),
+			binding.Json(models.ViewVerifyFacility{}), controllers.UpdateProjectVerifyOrder).
 			Name("Внесение изменений в расширенную информацию заказа - Верификация базы данных")
 		// Получение расширенной информации заказа – Регистрация header +
 		a.Get("/:prid/orders/:oid/service/header/", middlewares.RequireSessionKeepWithoutRoute,
@@ -846,22 +1148,26 @@ func Routes() martini.Router {
 			Name("Получение расширенной информации заказа – Регистрация header")
 		// Внесение изменений в расширенную информацию заказа - Регистрация header +
 		a.Put("/:prid/orders/:oid/service/header/", middlewares.RequireSessionKeepWithoutRoute,
-			middlewares.RequireProjectRights, binding.Json(models.ViewHeaderFacility{}), controllers.UpdateProjectHeaderOrder).
+			middlewares.RequireProjectRights, middlewares.RequirePermission(models.PERMISSION_ORDERS_CONFIRM),
+			binding.Json(models.ViewHeaderFacility{}), controllers.UpdateProjectHeaderOrder).
 			Name("Внесение изменений в расширенную информацию заказа - Регистрация header")
 	})
 
 	router.Group("/api/v1.0/organisations", func(a martini.Router) {
 		// Получение общей информации о компаниях объединения +
-		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights, controllers.GetMetaCompanies).
+		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights,
+			middlewares.RequirePermission(models.PERMISSION_COMPANIES_MANAGE), controllers.GetMetaCompanies).
 			Name("Получение общей информации о компаниях объединения")
 		// Получение списка компаний объединения +
-		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights, controllers.GetCompanies).
+		a.Get("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights,
+			middlewares.RequirePermission(models.PERMISSION_COMPANIES_MANAGE), controllers.GetCompanies).
 			Name("Получение списка компаний объединения")
 		// Получение подробной информации об организации объединения +
 		a.Get("/:orgid/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCompanyRights, controllers.GetCompany).
 			Name("Получение подробной информации об организации объединения")
 		// Добавление новой организации объединения +
 		a.Post("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireUserRights,
+			middlewares.RequirePermission(models.PERMISSION_COMPANIES_MANAGE),
 			binding.Json(models.ViewCompany{}), controllers.CreateCompany).
 			Name("Добавление новой организации объединения")
 		// Изменение информации об организации объединения +
@@ -890,7 +1196,8 @@ func Routes() martini.Router {
 
 	router.Group("/api/v1.0/reports", func(a martini.Router) {
 		// Сводная информация об отчётах +
-		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights, controllers.GetMetaReports).
+		a.Options("/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireCustomerRights,
+			middlewares.RequirePermission(models.PERMISSION_REPORTS_VIEW), controllers.GetMetaReports).
 			Name("Сводная информация об отчётах")
 		// Создание отчёта «Сводные показатели» +
 		a.Post("/aggregates/", middlewares.RequireSessionKeepWithoutRoute, middlewares.RequireReportAccessRights,
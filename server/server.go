@@ -8,6 +8,9 @@ import (
 	"application/communication"
 	"application/config"
 	"application/db"
+	"application/gateways"
+	"application/numbering"
+	"application/server/middlewares"
 	"application/services"
 	"application/workflows"
 
@@ -111,11 +114,35 @@ var (
 	tariffplanservice              *services.TariffPlanService
 	paymentservice                 *services.PaymentService
 	headerproductservice           *services.HeaderProductService
+	jobservice                     *services.JobService
+	ordercheckpointservice         *services.OrderCheckpointService
+	smsscheduleservice             *services.SMSScheduleService
+	smsbatchservice                *services.SMSBatchService
+	importrejectionservice         *services.ImportRejectionService
+	ledgerservice                  *services.LedgerService
+	orderroutingservice            *services.OrderRoutingService
+	orderrouteservice              *services.OrderRouteService
+	suppressionservice             *services.SuppressionService
+	deliveryreportservice          *services.DeliveryReportService
+	webhookservice                 *services.WebhookService
+	webhookdeliveryservice         *services.WebhookDeliveryService
+	hlrcacheservice                *services.HLRCacheService
+	addressservice                 *services.AddressService
+	addressimportservice           *services.AddressImportService
+	tablededupservice              *services.TableDedupService
+	ratelimitservice               services.RateLimitRepository
+	twofactorservice               *services.TwoFactorService
+	twofactorchallengeservice      *services.TwoFactorChallengeService
+	apikeyservice                  *services.ApiKeyService
+	unitroleservice                *services.UnitRoleService
 	headerworkflow                 *workflows.HeaderWorkflow
 	smsworkflow                    *workflows.SMSWorkflow
 	hlrworkflow                    *workflows.HLRWorkflow
 	verifyworkflow                 *workflows.VerifyWorkflow
+	recognizeworkflow              *workflows.RecognizeWorkflow
+	suppressionworkflow            *workflows.SuppressionWorkflow
 	orderworkflow                  *workflows.OrderWorkflow
+	addressworkflow                *workflows.AddressWorkflow
 )
 
 func Start() {
@@ -136,8 +163,10 @@ func Start() {
 	if db.InitDB() != nil {
 		return
 	}
-	if err = communication.Init(communication.ModeClient); err != nil {
-		return
+	if gateways.RemoteRequired() {
+		if err = communication.Init(communication.ModeClient); err != nil {
+			return
+		}
 	}
 	if config.InitDbCassandra() != nil {
 		return
@@ -246,23 +275,58 @@ func Start() {
 	tariffplanservice = services.NewTariffPlanService(services.NewRepository(db.DbMap, db.TABLE_TARIFF_PLANS))
 	paymentservice = services.NewPaymentService(services.NewRepository(db.DbMap, db.TABLE_PAYMENTS))
 	headerproductservice = services.NewHeaderProductService(services.NewRepository(db.DbMap, db.TABLE_HEADER_PRODUCTS))
+	jobservice = services.NewJobService(services.NewRepository(db.DbMap, db.TABLE_JOBS))
+	ordercheckpointservice = services.NewOrderCheckpointService(services.NewRepository(db.DbMap, db.TABLE_ORDER_CHECKPOINTS))
+	smsscheduleservice = services.NewSMSScheduleService(services.NewRepository(db.DbMap, db.TABLE_SMS_SCHEDULES))
+	smsbatchservice = services.NewSMSBatchService(services.NewRepository(db.DbMap, db.TABLE_SMS_BATCHES))
+	importrejectionservice = services.NewImportRejectionService(services.NewRepository(db.DbMap, db.TABLE_IMPORT_REJECTIONS))
+	ledgerservice = services.NewLedgerService(services.NewRepository(db.DbMap, db.TABLE_LEDGER_ACCOUNTS))
+	orderroutingservice = services.NewOrderRoutingService(services.NewRepository(db.DbMap, db.TABLE_ORDER_ROUTINGS))
+	orderrouteservice = services.NewOrderRouteService(services.NewRepository(db.DbMap, db.TABLE_ORDER_ROUTES))
+	suppressionservice = services.NewSuppressionService(services.NewRepository(db.DbMap, db.TABLE_SUPPRESSIONS))
+	deliveryreportservice = services.NewDeliveryReportService(services.NewRepository(db.DbMap, db.TABLE_DELIVERY_REPORTS))
+	webhookservice = services.NewWebhookService(services.NewRepository(db.DbMap, db.TABLE_WEBHOOKS))
+	webhookdeliveryservice = services.NewWebhookDeliveryService(services.NewRepository(db.DbMap, db.TABLE_WEBHOOK_DELIVERIES))
+	hlrcacheservice = services.NewHLRCacheService(services.NewRepository(db.DbMap, db.TABLE_HLR_CACHE))
+	addressservice = services.NewAddressService(services.NewRepository(db.DbMap, db.TABLE_ADDRESS_OBJECTS))
+	addressimportservice = services.NewAddressImportService(services.NewRepository(db.DbMap, db.TABLE_ADDRESS_IMPORTS))
+	tablededupservice = services.NewTableDedupService(services.NewRepository(db.DbMap, db.TABLE_TABLE_DEDUPS))
+	if config.Configuration.RateLimit.Store == middlewares.RATE_LIMIT_STORE_MYSQL {
+		ratelimitservice = services.NewRateLimitService(services.NewRepository(db.DbMap, db.TABLE_RATE_LIMITS))
+	} else {
+		ratelimitservice = services.NewRateLimitMemoryService()
+	}
+	twofactorservice = services.NewTwoFactorService(services.NewRepository(db.DbMap, db.TABLE_TWO_FACTORS))
+	twofactorchallengeservice = services.NewTwoFactorChallengeService(services.NewRepository(db.DbMap, db.TABLE_TWO_FACTOR_CHALLENGES))
+	apikeyservice = services.NewApiKeyService(services.NewRepository(db.DbMap, db.TABLE_API_KEYS))
+	unitroleservice = services.NewUnitRoleService(services.NewRepository(db.DbMap, db.TABLE_UNIT_ROLES))
 
 	headerworkflow = workflows.NewHeaderWorkflow(orderservice, facilityservice, headerfacilityservice, orderstatusservice,
-		invoiceservice, companyservice, operationservice, transactiontypeservice, tablecolumnservice, unitservice,
+		invoiceservice, companyservice, ledgerservice, transactiontypeservice, tablecolumnservice, unitservice,
 		tablerowservice, priceservice, headerproductservice, templateservice, emailservice)
 	smsworkflow = workflows.NewSMSWorkflow(orderservice, facilityservice, smsfacilityservice, orderstatusservice,
 		customertableservice, smstableservice, smssenderservice, resulttableservice, worktableservice, invoiceservice,
-		companyservice, operationservice, transactiontypeservice, tablecolumnservice, unitservice, tablerowservice,
-		priceservice, mobileoperatorservice, columntypeservice)
+		companyservice, ledgerservice, transactiontypeservice, tablecolumnservice, unitservice, tablerowservice,
+		priceservice, mobileoperatorservice, columntypeservice, ordercheckpointservice, smsperiodservice,
+		smseventservice, smsscheduleservice, smsbatchservice, supplierfacilityservice, orderroutingservice, orderrouteservice,
+		suppressionservice, deliveryreportservice)
+	suppressionworkflow = workflows.NewSuppressionWorkflow(suppressionservice, smssenderservice, deliveryreportservice)
+	addressworkflow = workflows.NewAddressWorkflow(addressservice, addressimportservice)
+	gateways.Register(gateways.GATEWAY_ADDRESS, gateways.NewAddressGateway(addressworkflow))
 	hlrworkflow = workflows.NewHLRWorkflow(orderservice, facilityservice, hlrfacilityservice, orderstatusservice,
 		customertableservice, hlrtableservice, resulttableservice, worktableservice, invoiceservice, companyservice,
-		operationservice, transactiontypeservice, tablecolumnservice, unitservice, tablerowservice, priceservice,
-		mobileoperatorservice, columntypeservice)
+		ledgerservice, transactiontypeservice, tablecolumnservice, unitservice, tablerowservice, priceservice,
+		mobileoperatorservice, columntypeservice, deliveryreportservice, hlrcacheservice)
 	verifyworkflow = workflows.NewVerifyWorkflow(orderservice, facilityservice, verifyfacilityservice, orderstatusservice,
 		customertableservice, verifytableservice, resulttableservice, worktableservice, invoiceservice, companyservice,
-		operationservice, transactiontypeservice, tablecolumnservice, unitservice, tablerowservice, priceservice,
+		ledgerservice, transactiontypeservice, tablecolumnservice, unitservice, tablerowservice, priceservice,
 		verifyproductservice, datacolumnservice)
-	orderworkflow = workflows.NewOrderWorkflow(orderservice, facilityservice, headerworkflow, smsworkflow, hlrworkflow, verifyworkflow)
+	recognizeworkflow = workflows.NewRecognizeWorkflow(orderservice, facilityservice, recognizefacilityservice, orderstatusservice,
+		customertableservice, resulttableservice, worktableservice, invoiceservice, companyservice, ledgerservice,
+		transactiontypeservice, tablecolumnservice, unitservice, tablerowservice, priceservice, recognizeproductservice,
+		inputfieldservice, inputproductservice, supplierrequestservice, inputfileservice, inputftpservice, fileservice)
+	orderworkflow = workflows.NewOrderWorkflow(orderservice, facilityservice, orderstatusservice, jobservice, ledgerservice,
+		headerworkflow, smsworkflow, hlrworkflow, verifyworkflow, recognizeworkflow)
 
 	userservice.SessionRepository = sessionservice
 	userservice.EmailRepository = emailservice
@@ -279,6 +343,8 @@ func Start() {
 
 	orderservice.OrderStatusRepository = orderstatusservice
 
+	orderstatusservice.WebhookDeliveryRepository = webhookdeliveryservice
+
 	smsfacilityservice.MobileOperatorOperationRepository = mobileoperatoroperationservice
 	smsfacilityservice.SMSPeriodRepository = smsperiodservice
 	smsfacilityservice.SMSEventRepository = smseventservice
@@ -316,8 +382,10 @@ func Start() {
 	invoiceservice.InvoiceItemRepository = invoiceitemservice
 	invoiceservice.TransactionRepository = transactionservice
 	invoiceservice.OperationRepository = operationservice
+	invoiceservice.LedgerRepository = ledgerservice
 	invoiceservice.OrderInvoiceRepository = orderinvoiceservice
 	invoiceservice.OrderRepository = orderservice
+	invoiceservice.WebhookDeliveryRepository = webhookdeliveryservice
 
 	reportservice.UserRepository = userservice
 	reportservice.ReportPeriodRepository = reportperiodservice
@@ -330,16 +398,24 @@ func Start() {
 
 	contractservice.AppendixRepository = appendixservice
 
-	financeservice.OperationRepository = operationservice
+	financeservice.LedgerRepository = ledgerservice
+
+	dtomobileoperators, err := mobileoperatorservice.FindAll()
+	if err != nil {
+		return
+	}
+	if numbering.Init(dtomobileoperators) != nil {
+		return
+	}
 
 	go workflows.NewFileWorkflow(fileservice).ClearExpired()
 	go workflows.NewCustomerTableWorkflow(customertableservice).ClearExpired()
+	go workflows.NewLedgerWorkflow(ledgerservice, emailservice).Reconcile()
+	go workflows.NewWebhookWorkflow(webhookservice, webhookdeliveryservice).Deliver()
 	go orderworkflow.Execute()
-
-	_, err = sessionservice.Get("beypiIA2mG_4OwJe5kiHJ1RxLdzrI79YCRog_VEAGRLc-cIQpokqLiVCCNm6tOQ-zwE5CYtwfVKtIPWBeYblxw==")
-	if err != nil {
-		log.Error("%v", err)
-	}
+	go workflows.NewRateLimitWorkflow(ratelimitservice).ClearExpired()
+	go workflows.NewTwoFactorWorkflow(twofactorchallengeservice).ClearExpired()
+	go workflows.NewSessionWorkflow(sessionservice).ClearExpired()
 
 	routes := Routes()
 	mrt := martini.New()
@@ -349,6 +425,7 @@ func Start() {
 		bootstrap(),
 		martini.Recovery(),
 		render.Renderer(render.Options{}),
+		middlewares.RateLimit,
 	)
 
 	// File server
@@ -472,5 +549,30 @@ func bootstrap() martini.Handler {
 		context.Map(tariffplanservice)
 		context.Map(paymentservice)
 		context.Map(headerproductservice)
+		context.Map(jobservice)
+		context.Map(ordercheckpointservice)
+		context.Map(smsscheduleservice)
+		context.Map(smsbatchservice)
+		context.Map(importrejectionservice)
+		context.Map(ledgerservice)
+		context.Map(orderroutingservice)
+		context.Map(orderrouteservice)
+		context.Map(suppressionservice)
+		context.Map(deliveryreportservice)
+		context.Map(webhookservice)
+		context.Map(webhookdeliveryservice)
+		context.Map(hlrcacheservice)
+		context.Map(addressservice)
+		context.Map(addressimportservice)
+		context.Map(tablededupservice)
+		context.MapTo(ratelimitservice, (*services.RateLimitRepository)(nil))
+		context.Map(twofactorservice)
+		context.Map(twofactorchallengeservice)
+		context.Map(apikeyservice)
+		context.Map(unitroleservice)
+		context.Map(smsworkflow)
+		context.Map(suppressionworkflow)
+		context.Map(orderworkflow)
+		context.Map(addressworkflow)
 	}
 }
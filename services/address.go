@@ -0,0 +1,202 @@
+package services
+
+import (
+	"application/models"
+	"fmt"
+	"strings"
+)
+
+const (
+	ADDRESS_SEARCH_LIMIT = 100
+	ADDRESS_SEARCH_DEPTH = 3
+	ADDRESS_CHUNK_SIZE   = 1000
+)
+
+type AddressRepository interface {
+	GetObject(id int64) (object *models.DtoAddressObject, err error)
+	GetParent(object_id int64) (parent_id int64, err error)
+	FindObjects(name string) (objects *[]models.DtoAddressObject, err error)
+	FindChildren(parent_id int64, name string) (objects *[]models.DtoAddressObject, err error)
+	FindHouses(parent_id int64, number string) (houses *[]models.DtoAddressHouse, err error)
+	GetParams(object_ids []int64) (params map[int64]map[int]string, err error)
+	SaveObjects(objects *[]models.DtoAddressObject) (err error)
+	SaveHouses(houses *[]models.DtoAddressHouse) (err error)
+	SaveHierarchy(hierarchy *[]models.DtoAddressHierarchy) (err error)
+	SaveParams(params *[]models.DtoAddressParam) (err error)
+}
+
+// Справочник адресов ФИАС. Адресные объекты хранятся в основной таблице, дома, иерархия и параметры - в таблицах
+// address_houses, address_hierarchy и address_params
+type AddressService struct {
+	*Repository
+}
+
+func NewAddressService(repository *Repository) *AddressService {
+	repository.DbContext.AddTableWithName(models.DtoAddressObject{}, repository.Table).SetKeys(false, "id")
+	return &AddressService{Repository: repository}
+}
+
+func (addressservice *AddressService) GetObject(id int64) (object *models.DtoAddressObject, err error) {
+	object = new(models.DtoAddressObject)
+	err = addressservice.DbContext.SelectOne(object, "select * from "+addressservice.Table+" where id = ?", id)
+	if err != nil {
+		log.Error("Error during getting address object from database %v with value %v", err, id)
+		return nil, err
+	}
+
+	return object, nil
+}
+
+// Родительский объект, 0 - объект верхнего уровня
+func (addressservice *AddressService) GetParent(object_id int64) (parent_id int64, err error) {
+	parents := new([]models.DtoAddressHierarchy)
+	_, err = addressservice.DbContext.Select(parents, "select * from address_hierarchy where object_id = ?", object_id)
+	if err != nil {
+		log.Error("Error during getting address hierarchy object from database %v with value %v", err, object_id)
+		return 0, err
+	}
+	if len(*parents) == 0 {
+		return 0, nil
+	}
+
+	return (*parents)[0].Parent_ID, nil
+}
+
+// Объекты с указанным названием, объекты верхних уровней первыми
+func (addressservice *AddressService) FindObjects(name string) (objects *[]models.DtoAddressObject, err error) {
+	objects = new([]models.DtoAddressObject)
+	_, err = addressservice.DbContext.Select(objects, "select * from "+addressservice.Table+
+		" where name = ? order by level limit ?", name, ADDRESS_SEARCH_LIMIT)
+	if err != nil {
+		log.Error("Error during finding address object in database %v with value %v", err, name)
+		return nil, err
+	}
+
+	return objects, nil
+}
+
+// Подчиненные объекты с указанным названием не глубже ADDRESS_SEARCH_DEPTH уровней иерархии, ближайшие первыми
+func (addressservice *AddressService) FindChildren(parent_id int64, name string) (objects *[]models.DtoAddressObject, err error) {
+	objects = new([]models.DtoAddressObject)
+	joins := ""
+	for depth := 1; depth <= ADDRESS_SEARCH_DEPTH; depth++ {
+		if depth == 1 {
+			joins = " inner join address_hierarchy h1 on h1.object_id = o.id"
+		} else {
+			joins += fmt.Sprintf(" inner join address_hierarchy h%v on h%v.object_id = h%v.parent_id", depth, depth, depth-1)
+		}
+		_, err = addressservice.DbContext.Select(objects, "select o.* from "+addressservice.Table+" o"+joins+
+			fmt.Sprintf(" where o.name = ? and h%v.parent_id = ? limit ?", depth), name, parent_id, ADDRESS_SEARCH_LIMIT)
+		if err != nil {
+			log.Error("Error during finding address object in database %v with value %v, %v", err, name, parent_id)
+			return nil, err
+		}
+		if len(*objects) != 0 {
+			break
+		}
+	}
+
+	return objects, nil
+}
+
+func (addressservice *AddressService) FindHouses(parent_id int64, number string) (houses *[]models.DtoAddressHouse, err error) {
+	houses = new([]models.DtoAddressHouse)
+	_, err = addressservice.DbContext.Select(houses, "select d.* from address_houses d inner join address_hierarchy h on h.object_id = d.id"+
+		" where h.parent_id = ? and d.number = ? limit ?", parent_id, number, ADDRESS_SEARCH_LIMIT)
+	if err != nil {
+		log.Error("Error during finding address house object in database %v with value %v, %v", err, parent_id, number)
+		return nil, err
+	}
+
+	return houses, nil
+}
+
+// Параметры объектов в разрезе типов параметров
+func (addressservice *AddressService) GetParams(object_ids []int64) (params map[int64]map[int]string, err error) {
+	params = make(map[int64]map[int]string)
+	if len(object_ids) == 0 {
+		return params, nil
+	}
+	marks := []string{}
+	args := []interface{}{}
+	for _, object_id := range object_ids {
+		marks = append(marks, "?")
+		args = append(args, object_id)
+	}
+	dtoaddressparams := new([]models.DtoAddressParam)
+	_, err = addressservice.DbContext.Select(dtoaddressparams, "select * from address_params where object_id in ("+
+		strings.Join(marks, ", ")+")", args...)
+	if err != nil {
+		log.Error("Error during getting address param object from database %v with value %v", err, object_ids)
+		return nil, err
+	}
+	for _, dtoaddressparam := range *dtoaddressparams {
+		if _, ok := params[dtoaddressparam.Object_ID]; !ok {
+			params[dtoaddressparam.Object_ID] = make(map[int]string)
+		}
+		params[dtoaddressparam.Object_ID][dtoaddressparam.Type_ID] = dtoaddressparam.Value
+	}
+
+	return params, nil
+}
+
+// Сохранение записей пакетами с заменой ранее загруженных записей
+func (addressservice *AddressService) save(table string, columns []string, count int, values func(index int) []interface{}) (err error) {
+	updates := []string{}
+	for _, column := range columns {
+		updates = append(updates, column+" = values("+column+")")
+	}
+	row := "(" + strings.TrimSuffix(strings.Repeat("?, ", len(columns)), ", ") + ")"
+	for begin := 0; begin < count; begin += ADDRESS_CHUNK_SIZE {
+		end := begin + ADDRESS_CHUNK_SIZE
+		if end > count {
+			end = count
+		}
+		rows := []string{}
+		args := []interface{}{}
+		for index := begin; index < end; index++ {
+			rows = append(rows, row)
+			args = append(args, values(index)...)
+		}
+		_, err = addressservice.DbContext.Exec("insert into "+table+" ("+strings.Join(columns, ", ")+") values "+strings.Join(rows, ", ")+
+			" on duplicate key update "+strings.Join(updates, ", "), args...)
+		if err != nil {
+			log.Error("Error during saving address object in database %v with value %v", err, table)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (addressservice *AddressService) SaveObjects(objects *[]models.DtoAddressObject) (err error) {
+	return addressservice.save(addressservice.Table, []string{"id", "guid", "name", "type_short", "type_full", "level"}, len(*objects),
+		func(index int) []interface{} {
+			object := (*objects)[index]
+			return []interface{}{object.ID, object.GUID, object.Name, object.TypeShort, object.TypeFull, object.Level}
+		})
+}
+
+func (addressservice *AddressService) SaveHouses(houses *[]models.DtoAddressHouse) (err error) {
+	return addressservice.save("address_houses", []string{"id", "guid", "number", "type_short", "type_full", "block", "block_type_short",
+		"block_type_full"}, len(*houses),
+		func(index int) []interface{} {
+			house := (*houses)[index]
+			return []interface{}{house.ID, house.GUID, house.Number, house.TypeShort, house.TypeFull, house.Block, house.BlockTypeShort,
+				house.BlockTypeFull}
+		})
+}
+
+func (addressservice *AddressService) SaveHierarchy(hierarchy *[]models.DtoAddressHierarchy) (err error) {
+	return addressservice.save("address_hierarchy", []string{"object_id", "parent_id"}, len(*hierarchy),
+		func(index int) []interface{} {
+			return []interface{}{(*hierarchy)[index].Object_ID, (*hierarchy)[index].Parent_ID}
+		})
+}
+
+func (addressservice *AddressService) SaveParams(params *[]models.DtoAddressParam) (err error) {
+	return addressservice.save("address_params", []string{"object_id", "type_id", "value"}, len(*params),
+		func(index int) []interface{} {
+			return []interface{}{(*params)[index].Object_ID, (*params)[index].Type_ID, (*params)[index].Value}
+		})
+}
@@ -0,0 +1,63 @@
+package services
+
+import (
+	"application/models"
+)
+
+type AddressImportRepository interface {
+	Get(id int64) (addressimport *models.DtoAddressImport, err error)
+	GetAll() (addressimports *[]models.DtoAddressImport, err error)
+	Create(addressimport *models.DtoAddressImport) (err error)
+	Update(addressimport *models.DtoAddressImport) (err error)
+}
+
+type AddressImportService struct {
+	*Repository
+}
+
+func NewAddressImportService(repository *Repository) *AddressImportService {
+	repository.DbContext.AddTableWithName(models.DtoAddressImport{}, repository.Table).SetKeys(true, "id")
+	return &AddressImportService{Repository: repository}
+}
+
+func (addressimportservice *AddressImportService) Get(id int64) (addressimport *models.DtoAddressImport, err error) {
+	addressimport = new(models.DtoAddressImport)
+	err = addressimportservice.DbContext.SelectOne(addressimport, "select * from "+addressimportservice.Table+" where id = ?", id)
+	if err != nil {
+		log.Error("Error during getting address import object from database %v with value %v", err, id)
+		return nil, err
+	}
+
+	return addressimport, nil
+}
+
+func (addressimportservice *AddressImportService) GetAll() (addressimports *[]models.DtoAddressImport, err error) {
+	addressimports = new([]models.DtoAddressImport)
+	_, err = addressimportservice.DbContext.Select(addressimports, "select * from "+addressimportservice.Table+" order by id desc")
+	if err != nil {
+		log.Error("Error during getting all address import object from database %v", err)
+		return nil, err
+	}
+
+	return addressimports, nil
+}
+
+func (addressimportservice *AddressImportService) Create(addressimport *models.DtoAddressImport) (err error) {
+	err = addressimportservice.DbContext.Insert(addressimport)
+	if err != nil {
+		log.Error("Error during creating address import object in database %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (addressimportservice *AddressImportService) Update(addressimport *models.DtoAddressImport) (err error) {
+	_, err = addressimportservice.DbContext.Update(addressimport)
+	if err != nil {
+		log.Error("Error during updating address import object in database %v with value %v", err, addressimport.ID)
+		return err
+	}
+
+	return nil
+}
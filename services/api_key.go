@@ -0,0 +1,177 @@
+package services
+
+import (
+	"application/models"
+	"github.com/coopernurse/gorp"
+	"time"
+)
+
+type ApiKeyRepository interface {
+	Get(id int64) (apikey *models.DtoApiKey, err error)
+	FindByHash(hash string) (apikey *models.DtoApiKey, err error)
+	GetByUnit(unitid int64) (apikeys *[]models.DtoApiKey, err error)
+	Create(apikey *models.DtoApiKey) (err error)
+	Update(apikey *models.DtoApiKey) (err error)
+	Deactivate(id int64) (err error)
+	LogAccess(id int64, accesslogid int64, used time.Time) (err error)
+}
+
+type ApiKeyService struct {
+	*Repository
+}
+
+func NewApiKeyService(repository *Repository) *ApiKeyService {
+	repository.DbContext.AddTableWithName(models.DtoApiKey{}, repository.Table).SetKeys(true, "id")
+	return &ApiKeyService{Repository: repository}
+}
+
+func (apikeyservice *ApiKeyService) Get(id int64) (apikey *models.DtoApiKey, err error) {
+	apikey = new(models.DtoApiKey)
+	err = apikeyservice.DbContext.SelectOne(apikey, "select * from "+apikeyservice.Table+" where id = ?", id)
+	if err != nil {
+		log.Error("Error during getting api key object from database %v with value %v", err, id)
+		return nil, err
+	}
+
+	return apikeyservice.getRoles(apikey)
+}
+
+func (apikeyservice *ApiKeyService) FindByHash(hash string) (apikey *models.DtoApiKey, err error) {
+	apikey = new(models.DtoApiKey)
+	err = apikeyservice.DbContext.SelectOne(apikey, "select * from "+apikeyservice.Table+" where hash = ?", hash)
+	if err != nil {
+		log.Error("Error during getting api key object from database %v", err)
+		return nil, err
+	}
+
+	return apikeyservice.getRoles(apikey)
+}
+
+func (apikeyservice *ApiKeyService) GetByUnit(unitid int64) (apikeys *[]models.DtoApiKey, err error) {
+	apikeys = new([]models.DtoApiKey)
+	_, err = apikeyservice.DbContext.Select(apikeys, "select * from "+apikeyservice.Table+" where unit_id = ? order by id", unitid)
+	if err != nil {
+		log.Error("Error during getting api key objects from database %v with value %v", err, unitid)
+		return nil, err
+	}
+	for i := range *apikeys {
+		_, err = apikeyservice.getRoles(&(*apikeys)[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return apikeys, nil
+}
+
+func (apikeyservice *ApiKeyService) Create(apikey *models.DtoApiKey) (err error) {
+	trans, err := apikeyservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during creating api key object in database %v", err)
+		return err
+	}
+
+	err = trans.Insert(apikey)
+	if err != nil {
+		log.Error("Error during creating api key object in database %v", err)
+		_ = trans.Rollback()
+		return err
+	}
+	err = apikeyservice.setRoles(apikey, trans)
+	if err != nil {
+		_ = trans.Rollback()
+		return err
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during creating api key object in database %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (apikeyservice *ApiKeyService) Update(apikey *models.DtoApiKey) (err error) {
+	trans, err := apikeyservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during updating api key object in database %v with value %v", err, apikey.ID)
+		return err
+	}
+
+	_, err = trans.Update(apikey)
+	if err != nil {
+		log.Error("Error during updating api key object in database %v with value %v", err, apikey.ID)
+		_ = trans.Rollback()
+		return err
+	}
+	err = apikeyservice.setRoles(apikey, trans)
+	if err != nil {
+		_ = trans.Rollback()
+		return err
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during updating api key object in database %v with value %v", err, apikey.ID)
+		return err
+	}
+
+	return nil
+}
+
+// Отозванный ключ не удаляется, чтобы сохранить связь с журналом доступа
+func (apikeyservice *ApiKeyService) Deactivate(id int64) (err error) {
+	_, err = apikeyservice.DbContext.Exec("update "+apikeyservice.Table+" set active = 0 where id = ?", id)
+	if err != nil {
+		log.Error("Error during updating api key object in database %v with value %v", err, id)
+		return err
+	}
+
+	return nil
+}
+
+// Привязка записи журнала доступа к ключу и отметка времени его использования
+func (apikeyservice *ApiKeyService) LogAccess(id int64, accesslogid int64, used time.Time) (err error) {
+	_, err = apikeyservice.DbContext.Exec("insert into api_key_access_logs (api_key_id, access_log_id) values (?, ?)", id, accesslogid)
+	if err != nil {
+		log.Error("Error during creating api key access log object in database %v with value %v", err, id)
+		return err
+	}
+	_, err = apikeyservice.DbContext.Exec("update "+apikeyservice.Table+" set last_used = ? where id = ?", used, id)
+	if err != nil {
+		log.Error("Error during updating api key object in database %v with value %v", err, id)
+		return err
+	}
+
+	return nil
+}
+
+func (apikeyservice *ApiKeyService) getRoles(apikey *models.DtoApiKey) (*models.DtoApiKey, error) {
+	roles := new([]models.UserRole)
+	_, err := apikeyservice.DbContext.Select(roles, "select group_id from api_key_groups where api_key_id = ?", apikey.ID)
+	if err != nil {
+		log.Error("Error during getting api key object from database %v with value %v", err, apikey.ID)
+		return nil, err
+	}
+	apikey.Roles = *roles
+
+	return apikey, nil
+}
+
+func (apikeyservice *ApiKeyService) setRoles(apikey *models.DtoApiKey, trans *gorp.Transaction) (err error) {
+	_, err = trans.Exec("delete from api_key_groups where api_key_id = ?", apikey.ID)
+	if err != nil {
+		log.Error("Error during setting api key groups in database %v with value %v", err, apikey.ID)
+		return err
+	}
+	for _, role := range apikey.Roles {
+		_, err = trans.Exec("insert into api_key_groups (api_key_id, group_id) values (?, ?)", apikey.ID, role)
+		if err != nil {
+			log.Error("Error during setting api key groups in database %v with value %v", err, apikey.ID)
+			return err
+		}
+	}
+
+	return nil
+}
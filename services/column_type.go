@@ -2,13 +2,9 @@ package services
 
 import (
 	"regexp"
-	"strings"
 
 	"application/models"
-)
-
-var (
-	NumberRegExp = regexp.MustCompile("[0-9]+")
+	"application/numbering"
 )
 
 type ColumnTypeRepository interface {
@@ -42,15 +38,7 @@ func (columntypeservice *ColumnTypeService) Validate(dtocolumntype *models.DtoCo
 			valid = false
 		}
 	case models.COLUMN_TYPE_MOBILE_PHONE:
-		numbers := NumberRegExp.FindAllString(value, -1)
-		value = strings.Join(numbers, "")
-		runes := []rune(value)
-		if len(runes) != 0 {
-			if runes[0] == '8' {
-				runes[0] = '7'
-			}
-		}
-		value = string(runes)
+		value = numbering.Normalize(value)
 		fallthrough
 	default:
 		if dtocolumntype.Regexp != "" {
@@ -65,6 +53,9 @@ func (columntypeservice *ColumnTypeService) Validate(dtocolumntype *models.DtoCo
 			}
 		}
 	}
+	if valid && dtocolumntype.ID == models.COLUMN_TYPE_MOBILE_PHONE && value != "" {
+		valid = numbering.Valid(value)
+	}
 	if dtocolumntype.Required {
 		if value == "" {
 			valid = false
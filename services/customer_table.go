@@ -1,12 +1,10 @@
 package services
 
 import (
-	"application/config"
 	"application/models"
 	"errors"
 	"fmt"
 	"github.com/coopernurse/gorp"
-	"path/filepath"
 	"strings"
 	"time"
 )
@@ -15,10 +13,7 @@ type CustomerTableRepository interface {
 	Copy(srccustomertable *models.DtoCustomerTable, inTrans bool) (destcustomertable *models.DtoCustomerTable, err error)
 	ImportDataStructure(dtotablecolumns *[]models.DtoTableColumn, inTrans bool) (err error)
 	UpdateImportStructure(customertable *models.DtoCustomerTable, dtotablecolumns *[]models.DtoTableColumn, inTrans bool) (err error)
-	ImportData(file *models.DtoFile, dtocustomertable *models.DtoCustomerTable, dataformat models.DataFormat,
-		hasheader bool, dtotablecolumns *[]models.DtoTableColumn, version string) (err error)
-	ExportData(viewexporttable *models.ViewExportTable, file *models.DtoFile, customertable *models.DtoCustomerTable,
-		tablecolumns *[]models.DtoTableColumn, hasheader bool, version string) (err error)
+	ImportRows(dtocustomertable *models.DtoCustomerTable, dtotablecolumns *[]models.DtoTableColumn, rows *[]models.DtoImportRow) (err error)
 	CheckUserAccess(user_id int64, id int64) (allowed bool, err error)
 	Get(id int64) (customertable *models.DtoCustomerTable, err error)
 	GetEx(id int64) (customertable *models.ApiLongCustomerTable, err error)
@@ -210,108 +205,45 @@ func (customertableservice *CustomerTableService) UpdateImportStructure(customer
 	return nil
 }
 
-func (customertableservice *CustomerTableService) ImportData(file *models.DtoFile, dtocustomertable *models.DtoCustomerTable,
-	dataformat models.DataFormat, hasheader bool, dtotablecolumns *[]models.DtoTableColumn, version string) (err error) {
+func (customertableservice *CustomerTableService) ImportRows(dtocustomertable *models.DtoCustomerTable,
+	dtotablecolumns *[]models.DtoTableColumn, rows *[]models.DtoImportRow) (err error) {
 	if len(*dtotablecolumns) == 0 {
 		log.Error("Can't find any data in customer table object %v for importing with value %v", err, dtocustomertable.ID)
 		return errors.New("Empty table")
 	}
-
-	absfilepath, err := filepath.Abs(config.Configuration.FileStorage)
-	if err != nil {
-		log.Error("Can't make an absolute path for %v, %v", config.Configuration.FileStorage, err)
-		return err
+	if len(*rows) == 0 {
+		return nil
 	}
 
-	query := "load data infile '" + filepath.Join(absfilepath, file.Path, fmt.Sprintf("%08d", file.ID)+version) +
-		"' into table table_data fields terminated by '" +
-		string(models.GetDataSeparator(models.DataFormat(dataformat))) +
-		"'  escaped by '' lines terminated by '\n'"
-	if hasheader {
-		query += " ignore 1 lines"
-	}
-	query += " ("
+	fields := ""
+	placeholders := "(?, ?, ?, 1, ?, 0, 0"
 	for _, tablecolumn := range *dtotablecolumns {
-		query += fmt.Sprintf(" field%v", tablecolumn.FieldNum) + ","
-	}
-	query += " position, wrong) set customer_table_id = " + fmt.Sprintf("%v", dtocustomertable.ID)
-
-	log.Info("Starting inserting table rows %v", time.Now())
-	_, err = customertableservice.DbContext.Exec(query)
-	if err != nil {
-		log.Error("Error during importing customer table object in database %v with value %v", err, dtocustomertable.ID)
-		return err
-	}
-	log.Info("Ending inserting table rows %v", time.Now())
-
-	return nil
-}
-
-func (customertableservice *CustomerTableService) ExportData(viewexporttable *models.ViewExportTable, file *models.DtoFile,
-	customertable *models.DtoCustomerTable, tablecolumns *[]models.DtoTableColumn, hasheader bool, version string) (err error) {
-	if len(*tablecolumns) == 0 {
-		log.Error("Can't find any data in customer table object %v for exporting with value %v", err, customertable.ID)
-		return errors.New("Empty table")
-	}
-
-	log.Info("Starting downloading table rows %v", time.Now())
-	query := ""
-	if hasheader {
-		query = "select"
-		for i, tablecolumn := range *tablecolumns {
-			columnname := ""
-			if !strings.Contains(tablecolumn.Name, "'") {
-				columnname = tablecolumn.Name
-			}
-			query += " '" + columnname + "'"
-			if i != len(*tablecolumns)-1 {
-				query += ","
-			}
-		}
-		query += " union all "
-	}
-	query += "( select"
-	for i, tablecolumn := range *tablecolumns {
-		query += fmt.Sprintf(" field%v", tablecolumn.FieldNum)
-		if i != len(*tablecolumns)-1 {
-			query += ","
-		}
-	}
-	query += " from table_data where active = 1 and customer_table_id = " + fmt.Sprintf("%v", customertable.ID)
-	if viewexporttable.Type != models.EXPORT_DATA_ALL {
-		query += " and ("
-		for i, tablecolumn := range *tablecolumns {
-			if viewexporttable.Type == models.EXPORT_DATA_VALID {
-				query += " " + fmt.Sprintf(" valid%v", tablecolumn.FieldNum) + " = 1"
-				if i != len(*tablecolumns)-1 {
-					query += " and"
-				}
-			}
-			if viewexporttable.Type == models.EXPORT_DATA_INVALID {
-				query += " " + fmt.Sprintf(" valid%v", tablecolumn.FieldNum) + " = 0"
-				if i != len(*tablecolumns)-1 {
-					query += " or"
-				}
+		fields += fmt.Sprintf(", field%v", tablecolumn.FieldNum)
+		placeholders += ", ?"
+	}
+	placeholders += ")"
+
+	created := time.Now()
+	values := make([]string, 0, len(*rows))
+	args := make([]interface{}, 0, len(*rows)*(len(*dtotablecolumns)+5))
+	for _, row := range *rows {
+		values = append(values, placeholders)
+		args = append(args, dtocustomertable.ID, row.Position, created, row.Wrong)
+		for i := range *dtotablecolumns {
+			value := ""
+			if i < len(row.Values) {
+				value = row.Values[i]
 			}
+			args = append(args, value)
 		}
-		query += ")"
-	}
-	abstemppath, err := filepath.Abs(config.Configuration.TempDirectory)
-	if err != nil {
-		log.Error("Can't make an absolute path for %v, %v", config.Configuration.TempDirectory, err)
-		return err
 	}
 
-	query += " into outfile '" + filepath.Join(abstemppath, fmt.Sprintf("%08d", file.ID)+version) + "' fields terminated by '" +
-		string(models.GetDataSeparator(models.DataFormat(viewexporttable.Data_Format_ID))) + "' lines terminated by '\n'"
-	query += ")"
-
-	_, err = customertableservice.DbContext.Exec(query)
+	_, err = customertableservice.DbContext.Exec("insert into table_data (customer_table_id, position, created, active, wrong, edition, original_id"+
+		fields+") values "+strings.Join(values, ", "), args...)
 	if err != nil {
-		log.Error("Error during exporting customer table object in database %v with value %v", err, customertable.ID)
+		log.Error("Error during importing customer table object in database %v with value %v", err, dtocustomertable.ID)
 		return err
 	}
-	log.Info("Ending downloading table rows %v", time.Now())
 
 	return nil
 }
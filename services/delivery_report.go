@@ -0,0 +1,140 @@
+package services
+
+import (
+	"application/models"
+	"strings"
+	"time"
+)
+
+const (
+	DELIVERY_REPORT_CHUNK_SIZE = 1000
+)
+
+type DeliveryReportRepository interface {
+	GetByOrder(order_id int64, reporttype models.DeliveryReportType) (reports *[]models.DtoDeliveryReport, err error)
+	CreateAll(reports *[]models.DtoDeliveryReport) (err error)
+	Update(report *models.DtoDeliveryReport) (found bool, err error)
+	Expire(order_id int64, reporttype models.DeliveryReportType, request_ids []string) (err error)
+	DeleteByOrder(order_id int64) (err error)
+	GetUnitsByMobilePhone(supplier_id int64, mobilephone uint64, since time.Time) (unit_ids []int64, err error)
+}
+
+type DeliveryReportService struct {
+	*Repository
+}
+
+func NewDeliveryReportService(repository *Repository) *DeliveryReportService {
+	repository.DbContext.AddTableWithName(models.DtoDeliveryReport{}, repository.Table).SetKeys(false, "request_id")
+	return &DeliveryReportService{Repository: repository}
+}
+
+func (deliveryreportservice *DeliveryReportService) GetByOrder(order_id int64,
+	reporttype models.DeliveryReportType) (reports *[]models.DtoDeliveryReport, err error) {
+	reports = new([]models.DtoDeliveryReport)
+	_, err = deliveryreportservice.DbContext.Select(reports, "select * from "+deliveryreportservice.Table+
+		" where order_id = ? and type = ?", order_id, reporttype)
+	if err != nil {
+		log.Error("Error during getting all delivery report object from database %v with value %v", err, order_id)
+		return nil, err
+	}
+
+	return reports, nil
+}
+
+// Регистрация отправленных запросов, по которым ожидаются отчеты о доставке. Уже зарегистрированные запросы не изменяются
+func (deliveryreportservice *DeliveryReportService) CreateAll(reports *[]models.DtoDeliveryReport) (err error) {
+	for begin := 0; begin < len(*reports); begin += DELIVERY_REPORT_CHUNK_SIZE {
+		end := begin + DELIVERY_REPORT_CHUNK_SIZE
+		if end > len(*reports) {
+			end = len(*reports)
+		}
+		values := []string{}
+		args := []interface{}{}
+		for _, report := range (*reports)[begin:end] {
+			values = append(values, "(?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)")
+			args = append(args, report.Request_ID, report.Order_ID, report.Supplier_ID, report.Type, report.Status, report.Error,
+				report.Orn, report.Roaming, report.Final, report.MobilePhone, report.Created, report.Updated)
+		}
+		_, err = deliveryreportservice.DbContext.Exec("insert ignore into "+deliveryreportservice.Table+
+			" (request_id, order_id, supplier_id, type, status, error, orn, roaming, final, mobilephone, created, updated) values "+
+			strings.Join(values, ", "), args...)
+		if err != nil {
+			log.Error("Error during creating delivery report object in database %v with value %v", err, (*reports)[begin].Order_ID)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Сохранение отчета поставщика. Отчет принимается только по запросу, отправленному этому поставщику и еще не получившему
+// окончательного статуса
+func (deliveryreportservice *DeliveryReportService) Update(report *models.DtoDeliveryReport) (found bool, err error) {
+	result, err := deliveryreportservice.DbContext.Exec("update "+deliveryreportservice.Table+
+		" set status = ?, error = ?, orn = ?, roaming = ?, final = ?, updated = ?"+
+		" where request_id = ? and supplier_id = ? and type = ? and final = 0",
+		report.Status, report.Error, report.Orn, report.Roaming, report.Final, report.Updated,
+		report.Request_ID, report.Supplier_ID, report.Type)
+	if err != nil {
+		log.Error("Error during updating delivery report object in database %v with value %v", err, report.Request_ID)
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		log.Error("Error during updating delivery report object in database %v with value %v", err, report.Request_ID)
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+// Отметка запросов без окончательного статуса просроченными
+func (deliveryreportservice *DeliveryReportService) Expire(order_id int64, reporttype models.DeliveryReportType,
+	request_ids []string) (err error) {
+	for begin := 0; begin < len(request_ids); begin += DELIVERY_REPORT_CHUNK_SIZE {
+		end := begin + DELIVERY_REPORT_CHUNK_SIZE
+		if end > len(request_ids) {
+			end = len(request_ids)
+		}
+		marks := []string{}
+		args := []interface{}{models.DELIVERY_STATUS_EXPIRED, models.DELIVERY_ERROR_EXPIRED, time.Now(), order_id, reporttype}
+		for _, request_id := range request_ids[begin:end] {
+			marks = append(marks, "?")
+			args = append(args, request_id)
+		}
+		_, err = deliveryreportservice.DbContext.Exec("update "+deliveryreportservice.Table+
+			" set status = ?, error = ?, final = 1, updated = ? where order_id = ? and type = ? and final = 0"+
+			" and request_id in ("+strings.Join(marks, ", ")+")", args...)
+		if err != nil {
+			log.Error("Error during updating delivery report object in database %v with value %v", err, order_id)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (deliveryreportservice *DeliveryReportService) DeleteByOrder(order_id int64) (err error) {
+	_, err = deliveryreportservice.DbContext.Exec("delete from "+deliveryreportservice.Table+" where order_id = ?", order_id)
+	if err != nil {
+		log.Error("Error during deleting delivery report object in database %v with value %v", err, order_id)
+		return err
+	}
+
+	return nil
+}
+
+// Объединения, по заказам которых поставщик отправлял сообщения на номер абонента после указанного времени
+func (deliveryreportservice *DeliveryReportService) GetUnitsByMobilePhone(supplier_id int64, mobilephone uint64,
+	since time.Time) (unit_ids []int64, err error) {
+	unit_ids = []int64{}
+	_, err = deliveryreportservice.DbContext.Select(&unit_ids, "select distinct o.unit_id from "+deliveryreportservice.Table+
+		" d inner join orders o on o.id = d.order_id where d.supplier_id = ? and d.type = ? and d.mobilephone = ? and d.created >= ?",
+		supplier_id, models.DELIVERY_REPORT_TYPE_SMS, mobilephone, since)
+	if err != nil {
+		log.Error("Error during getting delivery report units from database %v with value %v", err, mobilephone)
+		return nil, err
+	}
+
+	return unit_ids, nil
+}
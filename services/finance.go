@@ -9,7 +9,7 @@ type FinanceRepository interface {
 }
 
 type FinanceService struct {
-	OperationRepository OperationRepository
+	LedgerRepository LedgerRepository
 	*Repository
 }
 
@@ -19,11 +19,12 @@ func NewFinanceService(repository *Repository) *FinanceService {
 
 func (financeservice *FinanceService) Get(unit_id int64) (finance *models.ApiFinance, err error) {
 	finance = new(models.ApiFinance)
-	finance.Balance, err = financeservice.OperationRepository.CalculateBalance(unit_id)
+	account, err := financeservice.LedgerRepository.Get(unit_id)
 	if err != nil {
 		return nil, err
 	}
-	finance.Balance = models.Round(finance.Balance, 0.5, 2)
+	finance.Balance = models.Round(account.Balance, 0.5, 2)
+	finance.Held = models.Round(account.Held, 0.5, 2)
 	finance.TotalInvoiceAll, err = financeservice.DbContext.SelectFloat(
 		"select coalesce(sum(total), 0) from invoices where company_id in (select id from companies where unit_id = ?) and active = 1", unit_id)
 	if err != nil {
@@ -0,0 +1,92 @@
+package services
+
+import (
+	"application/models"
+	"strings"
+	"time"
+)
+
+const (
+	HLR_CACHE_CHUNK_SIZE = 1000
+)
+
+type HLRCacheRepository interface {
+	FindValid(unit_id int64, mobilephones []uint64, since time.Time) (caches map[uint64]models.DtoHLRCache, err error)
+	SaveAll(caches *[]models.DtoHLRCache) (err error)
+	DeleteExpired(unit_id int64, before time.Time) (err error)
+}
+
+type HLRCacheService struct {
+	*Repository
+}
+
+func NewHLRCacheService(repository *Repository) *HLRCacheService {
+	repository.DbContext.AddTableWithName(models.DtoHLRCache{}, repository.Table).SetKeys(false, "unit_id", "mobilephone")
+	return &HLRCacheService{Repository: repository}
+}
+
+// Результаты hlr запросов объединения по номерам, полученные не ранее указанного времени
+func (hlrcacheservice *HLRCacheService) FindValid(unit_id int64, mobilephones []uint64,
+	since time.Time) (caches map[uint64]models.DtoHLRCache, err error) {
+	caches = make(map[uint64]models.DtoHLRCache)
+	for begin := 0; begin < len(mobilephones); begin += HLR_CACHE_CHUNK_SIZE {
+		end := begin + HLR_CACHE_CHUNK_SIZE
+		if end > len(mobilephones) {
+			end = len(mobilephones)
+		}
+		marks := []string{}
+		args := []interface{}{unit_id, since}
+		for _, mobilephone := range mobilephones[begin:end] {
+			marks = append(marks, "?")
+			args = append(args, mobilephone)
+		}
+		dtohlrcaches := new([]models.DtoHLRCache)
+		_, err = hlrcacheservice.DbContext.Select(dtohlrcaches, "select * from "+hlrcacheservice.Table+
+			" where unit_id = ? and created >= ? and mobilephone in ("+strings.Join(marks, ", ")+")", args...)
+		if err != nil {
+			log.Error("Error during finding hlr cache object in database %v with value %v", err, unit_id)
+			return nil, err
+		}
+		for _, dtohlrcache := range *dtohlrcaches {
+			caches[dtohlrcache.MobilePhone] = dtohlrcache
+		}
+	}
+
+	return caches, nil
+}
+
+// Сохранение результатов hlr запросов с заменой ранее полученных результатов по тем же номерам
+func (hlrcacheservice *HLRCacheService) SaveAll(caches *[]models.DtoHLRCache) (err error) {
+	for begin := 0; begin < len(*caches); begin += HLR_CACHE_CHUNK_SIZE {
+		end := begin + HLR_CACHE_CHUNK_SIZE
+		if end > len(*caches) {
+			end = len(*caches)
+		}
+		values := []string{}
+		args := []interface{}{}
+		for _, cache := range (*caches)[begin:end] {
+			values = append(values, "(?, ?, ?, ?, ?, ?)")
+			args = append(args, cache.Unit_ID, cache.MobilePhone, cache.Status, cache.Orn, cache.Roaming, cache.Created)
+		}
+		_, err = hlrcacheservice.DbContext.Exec("insert into "+hlrcacheservice.Table+
+			" (unit_id, mobilephone, status, orn, roaming, created) values "+strings.Join(values, ", ")+
+			" on duplicate key update status = values(status), orn = values(orn), roaming = values(roaming), created = values(created)",
+			args...)
+		if err != nil {
+			log.Error("Error during saving hlr cache object in database %v with value %v", err, (*caches)[begin].Unit_ID)
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (hlrcacheservice *HLRCacheService) DeleteExpired(unit_id int64, before time.Time) (err error) {
+	_, err = hlrcacheservice.DbContext.Exec("delete from "+hlrcacheservice.Table+" where unit_id = ? and created < ?", unit_id, before)
+	if err != nil {
+		log.Error("Error during deleting hlr cache object in database %v with value %v", err, unit_id)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,65 @@
+package services
+
+import (
+	"application/models"
+	"strings"
+)
+
+type ImportRejectionRepository interface {
+	GetByTable(tableid int64) (importrejections *[]models.ApiImportRejection, err error)
+	CreateAll(importrejections *[]models.DtoImportRejection) (err error)
+	DeleteByTable(tableid int64) (err error)
+}
+
+type ImportRejectionService struct {
+	*Repository
+}
+
+func NewImportRejectionService(repository *Repository) *ImportRejectionService {
+	repository.DbContext.AddTableWithName(models.DtoImportRejection{}, repository.Table).SetKeys(true, "id")
+	return &ImportRejectionService{Repository: repository}
+}
+
+func (importrejectionservice *ImportRejectionService) GetByTable(tableid int64) (importrejections *[]models.ApiImportRejection, err error) {
+	importrejections = new([]models.ApiImportRejection)
+	_, err = importrejectionservice.DbContext.Select(importrejections, "select line, reason, imported from "+importrejectionservice.Table+
+		" where customer_table_id = ? order by line", tableid)
+	if err != nil {
+		log.Error("Error during getting all import rejection object from database %v with value %v", err, tableid)
+		return nil, err
+	}
+
+	return importrejections, nil
+}
+
+func (importrejectionservice *ImportRejectionService) CreateAll(importrejections *[]models.DtoImportRejection) (err error) {
+	if len(*importrejections) == 0 {
+		return nil
+	}
+
+	values := []string{}
+	args := []interface{}{}
+	for _, importrejection := range *importrejections {
+		values = append(values, "(?, ?, ?, ?, ?)")
+		args = append(args, importrejection.Customer_Table_ID, importrejection.Line, importrejection.Reason,
+			importrejection.Imported, importrejection.Created)
+	}
+	_, err = importrejectionservice.DbContext.Exec("insert into "+importrejectionservice.Table+
+		" (customer_table_id, line, reason, imported, created) values "+strings.Join(values, ", "), args...)
+	if err != nil {
+		log.Error("Error during creating import rejection object in database %v with value %v", err, (*importrejections)[0].Customer_Table_ID)
+		return err
+	}
+
+	return nil
+}
+
+func (importrejectionservice *ImportRejectionService) DeleteByTable(tableid int64) (err error) {
+	_, err = importrejectionservice.DbContext.Exec("delete from "+importrejectionservice.Table+" where customer_table_id = ?", tableid)
+	if err != nil {
+		log.Error("Error during deleting import rejection object in database %v with value %v", err, tableid)
+		return err
+	}
+
+	return nil
+}
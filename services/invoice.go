@@ -2,6 +2,7 @@ package services
 
 import (
 	"application/models"
+	"errors"
 	"github.com/coopernurse/gorp"
 	"time"
 )
@@ -12,19 +13,24 @@ type InvoiceRepository interface {
 	GetMeta(user_id int64, filter string) (invoice *models.ApiMetaInvoice, err error)
 	GetByUser(userid int64, filter string) (invoices *[]models.ApiShortInvoice, err error)
 	GetByUnit(unitid int64, filter string) (invoices *[]models.ApiShortInvoice, err error)
+	GetByCompany(company_id int64) (invoices *[]models.DtoInvoice, err error)
 	SetArrays(invoice *models.DtoInvoice, trans *gorp.Transaction) (err error)
 	PayForOrder(dtoorder *models.DtoOrder, dtoinvoice *models.DtoInvoice, dtotransaction *models.DtoTransaction, inTrans bool) (err error)
+	RefundForOrder(dtoorder *models.DtoOrder, dtotransaction *models.DtoTransaction, inTrans bool) (err error)
+	PayForRefill(dtoinvoice *models.DtoInvoice, dtotransaction *models.DtoTransaction, inTrans bool) (err error)
 	Create(invoice *models.DtoInvoice, trans *gorp.Transaction, inTrans bool) (err error)
 	Update(invoice *models.DtoInvoice, trans *gorp.Transaction, inTrans bool) (err error)
 	Deactivate(invoice *models.DtoInvoice) (err error)
 }
 
 type InvoiceService struct {
-	InvoiceItemRepository  InvoiceItemRepository
-	TransactionRepository  TransactionRepository
-	OperationRepository    OperationRepository
-	OrderInvoiceRepository OrderInvoiceRepository
-	OrderRepository        OrderRepository
+	InvoiceItemRepository     InvoiceItemRepository
+	TransactionRepository     TransactionRepository
+	OperationRepository       OperationRepository
+	LedgerRepository          LedgerRepository
+	OrderInvoiceRepository    OrderInvoiceRepository
+	OrderRepository           OrderRepository
+	WebhookDeliveryRepository WebhookDeliveryRepository
 
 	*Repository
 }
@@ -112,6 +118,17 @@ func (invoiceservice *InvoiceService) GetByUnit(unitid int64, filter string) (in
 	return invoices, nil
 }
 
+func (invoiceservice *InvoiceService) GetByCompany(company_id int64) (invoices *[]models.DtoInvoice, err error) {
+	invoices = new([]models.DtoInvoice)
+	_, err = invoiceservice.DbContext.Select(invoices, "select * from "+invoiceservice.Table+" where company_id = ? order by created", company_id)
+	if err != nil {
+		log.Error("Error during getting company invoice object from database %v with value %v", err, company_id)
+		return nil, err
+	}
+
+	return invoices, nil
+}
+
 func (invoiceservice *InvoiceService) SetArrays(invoice *models.DtoInvoice, trans *gorp.Transaction) (err error) {
 	err = invoiceservice.InvoiceItemRepository.DeleteByInvoice(invoice.ID, trans)
 	if err != nil {
@@ -169,6 +186,14 @@ func (invoiceservice *InvoiceService) PayForOrder(dtoorder *models.DtoOrder, dto
 		return err
 	}
 
+	err = invoiceservice.LedgerRepository.Capture(dtoorder.ID, dtotransaction.Source_ID, dtotransaction.Destination_ID, dtoinvoice.Total, trans)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+
 	dtocredit := new(models.DtoOperation)
 	dtocredit.Transaction_ID = dtotransaction.ID
 	dtocredit.Invoice_ID = dtoinvoice.ID
@@ -217,6 +242,225 @@ func (invoiceservice *InvoiceService) PayForOrder(dtoorder *models.DtoOrder, dto
 	return nil
 }
 
+// Возврат оплаты за заказ. Повторный возврат для уже возвращенной оплаты не выполняется
+func (invoiceservice *InvoiceService) RefundForOrder(dtoorder *models.DtoOrder, dtotransaction *models.DtoTransaction, inTrans bool) (err error) {
+	var trans *gorp.Transaction
+	var count int64
+
+	if inTrans {
+		trans, err = invoiceservice.DbContext.Begin()
+		if err != nil {
+			log.Error("Error during refunding invoice in database %v", err)
+			return err
+		}
+	}
+
+	query := "select count(*) from order_statuses where order_id = ? and status_id = ? and value = 1 for update"
+	if trans != nil {
+		count, err = trans.SelectInt(query, dtoorder.ID, models.ORDER_STATUS_PAID)
+	} else {
+		count, err = invoiceservice.DbContext.SelectInt(query, dtoorder.ID, models.ORDER_STATUS_PAID)
+	}
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		log.Error("Error during refunding invoice in database %v with value %v", err, dtoorder.ID)
+		return err
+	}
+	if count == 0 {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		log.Info("Order %v has been already refunded", dtoorder.ID)
+		return nil
+	}
+
+	orderinvoices, err := invoiceservice.OrderInvoiceRepository.GetByOrder(dtoorder.ID)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+	if len(*orderinvoices) == 0 {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		log.Error("Can't find invoice for paid order %v", dtoorder.ID)
+		return errors.New("Missed order invoice")
+	}
+	invoice_id := (*orderinvoices)[len(*orderinvoices)-1].Invoice_ID
+
+	err = invoiceservice.TransactionRepository.Create(dtotransaction, trans)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+
+	err = invoiceservice.LedgerRepository.Transfer(dtotransaction.Source_ID, dtotransaction.Destination_ID, dtoorder.Charged_Fee, trans)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+
+	dtocredit := new(models.DtoOperation)
+	dtocredit.Transaction_ID = dtotransaction.ID
+	dtocredit.Invoice_ID = invoice_id
+	dtocredit.Money = dtoorder.Charged_Fee
+	dtocredit.Type_ID = models.OPERATION_TYPE_WITHDRAW
+	dtocredit.Created = time.Now()
+	err = invoiceservice.OperationRepository.Create(dtocredit, trans)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+	dtodebet := new(models.DtoOperation)
+	dtodebet.Transaction_ID = dtotransaction.ID
+	dtodebet.Invoice_ID = invoice_id
+	dtodebet.Money = dtoorder.Charged_Fee
+	dtodebet.Type_ID = models.OPERATION_TYPE_RECEIVE
+	dtodebet.Created = time.Now()
+	err = invoiceservice.OperationRepository.Create(dtodebet, trans)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+
+	dtoorder.Charged_Fee = 0
+	err = invoiceservice.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
+		*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_PAID, false, "", time.Now())}, trans, false)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+
+	if inTrans {
+		err = trans.Commit()
+		if err != nil {
+			log.Error("Error during refunding invoice in database %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Оплата счета на пополнение баланса объединения. Зачисление проходит через книгу учета средств в той же транзакции,
+// что и операции, повторная оплата счета не выполняется
+func (invoiceservice *InvoiceService) PayForRefill(dtoinvoice *models.DtoInvoice, dtotransaction *models.DtoTransaction, inTrans bool) (err error) {
+	var trans *gorp.Transaction
+	var count int64
+
+	if inTrans {
+		trans, err = invoiceservice.DbContext.Begin()
+		if err != nil {
+			log.Error("Error during paying invoice in database %v", err)
+			return err
+		}
+	}
+
+	query := "select count(*) from " + invoiceservice.Table + " where id = ? and paid = 0 and active = 1 for update"
+	if trans != nil {
+		count, err = trans.SelectInt(query, dtoinvoice.ID)
+	} else {
+		count, err = invoiceservice.DbContext.SelectInt(query, dtoinvoice.ID)
+	}
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		log.Error("Error during paying invoice in database %v with value %v", err, dtoinvoice.ID)
+		return err
+	}
+	if count == 0 {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		log.Error("Invoice %v is already paid or not active", dtoinvoice.ID)
+		return errors.New("Invoice is paid")
+	}
+
+	dtoinvoice.Paid = true
+	dtoinvoice.PaidAt = time.Now()
+	query = "update " + invoiceservice.Table + " set paid = 1, paid_at = ? where id = ?"
+	if trans != nil {
+		_, err = trans.Exec(query, dtoinvoice.PaidAt, dtoinvoice.ID)
+	} else {
+		_, err = invoiceservice.DbContext.Exec(query, dtoinvoice.PaidAt, dtoinvoice.ID)
+	}
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		log.Error("Error during paying invoice in database %v with value %v", err, dtoinvoice.ID)
+		return err
+	}
+
+	err = invoiceservice.TransactionRepository.Create(dtotransaction, trans)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+
+	err = invoiceservice.LedgerRepository.Transfer(dtotransaction.Source_ID, dtotransaction.Destination_ID, dtoinvoice.Total, trans)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+
+	dtocredit := new(models.DtoOperation)
+	dtocredit.Transaction_ID = dtotransaction.ID
+	dtocredit.Invoice_ID = dtoinvoice.ID
+	dtocredit.Money = dtoinvoice.Total
+	dtocredit.Type_ID = models.OPERATION_TYPE_WITHDRAW
+	dtocredit.Created = time.Now()
+	err = invoiceservice.OperationRepository.Create(dtocredit, trans)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+	dtodebet := new(models.DtoOperation)
+	dtodebet.Transaction_ID = dtotransaction.ID
+	dtodebet.Invoice_ID = dtoinvoice.ID
+	dtodebet.Money = dtoinvoice.Total
+	dtodebet.Type_ID = models.OPERATION_TYPE_RECEIVE
+	dtodebet.Created = time.Now()
+	err = invoiceservice.OperationRepository.Create(dtodebet, trans)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+
+	if inTrans {
+		err = trans.Commit()
+		if err != nil {
+			log.Error("Error during paying invoice in database %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
 func (invoiceservice *InvoiceService) Create(invoice *models.DtoInvoice, trans *gorp.Transaction, inTrans bool) (err error) {
 	if inTrans {
 		trans, err = invoiceservice.DbContext.Begin()
@@ -247,6 +491,17 @@ func (invoiceservice *InvoiceService) Create(invoice *models.DtoInvoice, trans *
 		return err
 	}
 
+	if invoiceservice.WebhookDeliveryRepository != nil {
+		err = invoiceservice.WebhookDeliveryRepository.EnqueueByCompany(invoice.Company_ID, models.WEBHOOK_EVENT_INVOICE_CREATED,
+			models.ApiInvoiceEvent{Invoice_ID: invoice.ID, Company_ID: invoice.Company_ID, Total: invoice.Total, VAT: invoice.VAT}, trans)
+		if err != nil {
+			if inTrans {
+				_ = trans.Rollback()
+			}
+			return err
+		}
+	}
+
 	if inTrans {
 		err = trans.Commit()
 		if err != nil {
@@ -0,0 +1,177 @@
+package services
+
+import (
+	"application/models"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"time"
+)
+
+const (
+	JOB_LEASE_LENGTH = 16
+)
+
+// Аренда задания перехвачена другим обработчиком
+var ErrLeaseLost = errors.New("Lease lost")
+
+type JobRepository interface {
+	Get(order_id int64) (job *models.DtoJob, err error)
+	Enqueue(order_id int64, alias string, rearm bool) (err error)
+	Claim(alias string, owner string, lease time.Duration) (job *models.DtoJob, err error)
+	Extend(job *models.DtoJob, lease time.Duration) (err error)
+	Complete(job *models.DtoJob) (err error)
+	Fail(job *models.DtoJob, message string, retry time.Duration, maxattempts int) (err error)
+	Defer(job *models.DtoJob, until time.Time) (err error)
+}
+
+type JobService struct {
+	*Repository
+}
+
+func NewJobService(repository *Repository) *JobService {
+	repository.DbContext.AddTableWithName(models.DtoJob{}, repository.Table).SetKeys(false, "order_id")
+	return &JobService{Repository: repository}
+}
+
+func (jobservice *JobService) Get(order_id int64) (job *models.DtoJob, err error) {
+	job = new(models.DtoJob)
+	err = jobservice.DbContext.SelectOne(job, "select * from "+jobservice.Table+" where order_id = ?", order_id)
+	if err != nil {
+		log.Error("Error during getting job object from database %v with value %v", err, order_id)
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Постановка заказа в очередь. Повторная постановка не создает дубликатов: для уже существующего задания
+// в статусе выполненного оно снова становится доступным только при rearm, в остальных случаях запись не меняется
+func (jobservice *JobService) Enqueue(order_id int64, alias string, rearm bool) (err error) {
+	now := time.Now()
+	if rearm {
+		_, err = jobservice.DbContext.Exec("insert into "+jobservice.Table+
+			" (order_id, alias, status, attempts, owner, lease, lease_till, available_at, error, created, updated)"+
+			" values (?, ?, ?, 0, '', '', ?, ?, '', ?, ?)"+
+			" on duplicate key update available_at = if(status = ?, values(available_at), available_at),"+
+			" updated = if(status = ?, values(updated), updated), status = if(status = ?, ?, status)",
+			order_id, alias, models.JOB_STATUS_NEW, now, now, now, now,
+			models.JOB_STATUS_DONE, models.JOB_STATUS_DONE, models.JOB_STATUS_DONE, models.JOB_STATUS_NEW)
+	} else {
+		_, err = jobservice.DbContext.Exec("insert ignore into "+jobservice.Table+
+			" (order_id, alias, status, attempts, owner, lease, lease_till, available_at, error, created, updated)"+
+			" values (?, ?, ?, 0, '', '', ?, ?, '', ?, ?)",
+			order_id, alias, models.JOB_STATUS_NEW, now, now, now, now)
+	}
+	if err != nil {
+		log.Error("Error during enqueuing job object in database %v with value %v", err, order_id)
+		return err
+	}
+
+	return nil
+}
+
+// Захват задания обработчиком. Захватываются новые задания и задания, аренда которых истекла.
+// Если доступных заданий нет, то возвращается пустое задание без ошибки
+func (jobservice *JobService) Claim(alias string, owner string, lease time.Duration) (job *models.DtoJob, err error) {
+	tokenRaw := make([]byte, JOB_LEASE_LENGTH)
+	if _, err = rand.Read(tokenRaw); err != nil {
+		log.Error("Error during lease token generation %v", err)
+		return nil, err
+	}
+	token := hex.EncodeToString(tokenRaw)
+
+	now := time.Now()
+	result, err := jobservice.DbContext.Exec("update "+jobservice.Table+
+		" set status = ?, owner = ?, lease = ?, lease_till = ?, attempts = attempts + 1, updated = ?"+
+		" where alias = ? and available_at <= ? and (status = ? or (status = ? and lease_till < ?))"+
+		" order by available_at, order_id limit 1",
+		models.JOB_STATUS_RUNNING, owner, token, now.Add(lease), now,
+		alias, now, models.JOB_STATUS_NEW, models.JOB_STATUS_RUNNING, now)
+	if err != nil {
+		log.Error("Error during claiming job object in database %v with value %v", err, alias)
+		return nil, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		log.Error("Error during claiming job object in database %v with value %v", err, alias)
+		return nil, err
+	}
+	if count == 0 {
+		return nil, nil
+	}
+
+	job = new(models.DtoJob)
+	err = jobservice.DbContext.SelectOne(job, "select * from "+jobservice.Table+" where lease = ?", token)
+	if err != nil {
+		log.Error("Error during getting job object from database %v with value %v", err, token)
+		return nil, err
+	}
+
+	return job, nil
+}
+
+// Продление аренды задания. Если задание было перехвачено другим обработчиком, то возвращается ошибка
+func (jobservice *JobService) Extend(job *models.DtoJob, lease time.Duration) (err error) {
+	now := time.Now()
+	result, err := jobservice.DbContext.Exec("update "+jobservice.Table+" set lease_till = ?, updated = ?"+
+		" where order_id = ? and lease = ? and status = ?", now.Add(lease), now, job.Order_ID, job.Lease, models.JOB_STATUS_RUNNING)
+	if err != nil {
+		log.Error("Error during extending job object in database %v with value %v", err, job.Order_ID)
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		log.Error("Error during extending job object in database %v with value %v", err, job.Order_ID)
+		return err
+	}
+	if count == 0 {
+		log.Error("Job lease has been lost %v by owner %v", job.Order_ID, job.Owner)
+		return ErrLeaseLost
+	}
+	job.Lease_Till = now.Add(lease)
+	job.Updated = now
+
+	return nil
+}
+
+func (jobservice *JobService) Complete(job *models.DtoJob) (err error) {
+	_, err = jobservice.DbContext.Exec("update "+jobservice.Table+" set status = ?, lease = '', error = '', updated = ?"+
+		" where order_id = ? and lease = ?", models.JOB_STATUS_DONE, time.Now(), job.Order_ID, job.Lease)
+	if err != nil {
+		log.Error("Error during completing job object in database %v with value %v", err, job.Order_ID)
+		return err
+	}
+
+	return nil
+}
+
+// Откладывание задания до указанного времени. Отложенный запуск не расходует попытки исполнения
+func (jobservice *JobService) Defer(job *models.DtoJob, until time.Time) (err error) {
+	_, err = jobservice.DbContext.Exec("update "+jobservice.Table+" set status = ?, lease = '', error = '', available_at = ?,"+
+		" attempts = if(attempts > 0, attempts - 1, 0), updated = ? where order_id = ? and lease = ?",
+		models.JOB_STATUS_NEW, until, time.Now(), job.Order_ID, job.Lease)
+	if err != nil {
+		log.Error("Error during deferring job object in database %v with value %v", err, job.Order_ID)
+		return err
+	}
+
+	return nil
+}
+
+// Регистрация ошибки исполнения. Задание возвращается в очередь с задержкой до исчерпания количества попыток
+func (jobservice *JobService) Fail(job *models.DtoJob, message string, retry time.Duration, maxattempts int) (err error) {
+	status := models.JOB_STATUS_NEW
+	if job.Attempts >= maxattempts {
+		status = models.JOB_STATUS_FAILED
+	}
+	now := time.Now()
+	_, err = jobservice.DbContext.Exec("update "+jobservice.Table+" set status = ?, lease = '', error = ?, available_at = ?, updated = ?"+
+		" where order_id = ? and lease = ?", status, message, now.Add(retry), now, job.Order_ID, job.Lease)
+	if err != nil {
+		log.Error("Error during failing job object in database %v with value %v", err, job.Order_ID)
+		return err
+	}
+
+	return nil
+}
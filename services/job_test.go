@@ -0,0 +1,203 @@
+package services
+
+import (
+	"application/db"
+	"application/models"
+	"database/sql"
+	"errors"
+	"github.com/coopernurse/gorp"
+	"strings"
+	"testing"
+	"time"
+)
+
+type TestJobResult struct {
+	Affected int64
+}
+
+func (testJobResult *TestJobResult) LastInsertId() (int64, error) {
+	return 0, nil
+}
+
+func (testJobResult *TestJobResult) RowsAffected() (int64, error) {
+	return testJobResult.Affected, nil
+}
+
+type TestJobDBMap struct {
+	Job      *models.DtoJob
+	Affected int64
+	Err      error
+	Query    string
+	Args     []interface{}
+}
+
+func (testJobDBMap *TestJobDBMap) AddTableWithName(i interface{}, name string) *gorp.TableMap {
+	return nil
+}
+
+func (testJobDBMap *TestJobDBMap) Begin() (*gorp.Transaction, error) {
+	return nil, nil
+}
+
+func (testJobDBMap *TestJobDBMap) Get(i interface{}, keys ...interface{}) (interface{}, error) {
+	return nil, nil
+}
+
+func (testJobDBMap *TestJobDBMap) Insert(list ...interface{}) error {
+	return testJobDBMap.Err
+}
+
+func (testJobDBMap *TestJobDBMap) Update(list ...interface{}) (int64, error) {
+	return 0, testJobDBMap.Err
+}
+
+func (testJobDBMap *TestJobDBMap) Delete(list ...interface{}) (int64, error) {
+	return 0, testJobDBMap.Err
+}
+
+func (testJobDBMap *TestJobDBMap) Exec(query string, args ...interface{}) (sql.Result, error) {
+	testJobDBMap.Query = query
+	testJobDBMap.Args = args
+	if testJobDBMap.Err != nil {
+		return nil, testJobDBMap.Err
+	}
+	return &TestJobResult{Affected: testJobDBMap.Affected}, nil
+}
+
+func (testJobDBMap *TestJobDBMap) Select(i interface{}, query string, args ...interface{}) ([]interface{}, error) {
+	return nil, testJobDBMap.Err
+}
+
+func (testJobDBMap *TestJobDBMap) SelectInt(query string, args ...interface{}) (int64, error) {
+	return 0, nil
+}
+
+func (testJobDBMap *TestJobDBMap) SelectStr(query string, args ...interface{}) (string, error) {
+	return "", nil
+}
+
+func (testJobDBMap *TestJobDBMap) SelectOne(holder interface{}, query string, args ...interface{}) error {
+	job, _ := holder.(*models.DtoJob)
+	(*job) = (*testJobDBMap.Job)
+	return testJobDBMap.Err
+}
+
+func (testJobDBMap *TestJobDBMap) SelectFloat(query string, args ...interface{}) (float64, error) {
+	return 0, nil
+}
+
+func TestJobClaim(t *testing.T) {
+	dbmap := new(TestJobDBMap)
+	jobService := new(JobService)
+	jobService.Repository = NewRepository(dbmap, db.TABLE_JOBS)
+
+	job, err := jobService.Claim("sms", "host:1", time.Minute)
+	if job != nil || err != nil {
+		t.Error("Claim without available jobs should return nothing", job, err)
+	}
+
+	dbmap.Affected = 1
+	dbmap.Job = models.NewDtoJob(1, "sms", models.JOB_STATUS_RUNNING, 1, "host:1", "lease", time.Now().Add(time.Minute),
+		time.Now(), "", time.Now(), time.Now())
+	before := time.Now()
+	job, err = jobService.Claim("sms", "host:1", time.Minute)
+	if job == nil || err != nil || job.Order_ID != 1 {
+		t.Error("Claim should return claimed job", job, err)
+		return
+	}
+	token, _ := dbmap.Args[2].(string)
+	if len(token) != 2*JOB_LEASE_LENGTH || dbmap.Args[1] != "host:1" {
+		t.Error("Claim should take job with a new lease token", dbmap.Args[1], token)
+	}
+	leasetill, _ := dbmap.Args[3].(time.Time)
+	if leasetill.Before(before.Add(time.Minute)) || leasetill.After(time.Now().Add(time.Minute)) {
+		t.Error("Claim should lease job for lease timeout", leasetill.Sub(before))
+	}
+	if !strings.Contains(dbmap.Query, "attempts = attempts + 1") || !strings.Contains(dbmap.Query, "lease_till < ?") {
+		t.Error("Claim should count attempts and take over expired leases", dbmap.Query)
+	}
+}
+
+func TestJobExtend(t *testing.T) {
+	dbmap := new(TestJobDBMap)
+	jobService := new(JobService)
+	jobService.Repository = NewRepository(dbmap, db.TABLE_JOBS)
+	var testlogger = new(TestLogger)
+	InitLogger(testlogger)
+	leasetill := time.Now()
+	job := models.NewDtoJob(1, "sms", models.JOB_STATUS_RUNNING, 1, "host:1", "lease", leasetill, time.Now(), "", time.Now(), time.Now())
+
+	dbmap.Affected = 1
+	if err := jobService.Extend(job, time.Hour); err != nil || !job.Lease_Till.After(leasetill.Add(59*time.Minute)) {
+		t.Error("Extend should prolong job lease", job.Lease_Till.Sub(leasetill), err)
+	}
+	if dbmap.Args[3] != "lease" || dbmap.Args[4] != models.JOB_STATUS_RUNNING {
+		t.Error("Extend should update only running job with the same lease", dbmap.Args)
+	}
+
+	leasetill = job.Lease_Till
+	dbmap.Affected = 0
+	if err := jobService.Extend(job, time.Hour); err != ErrLeaseLost || !job.Lease_Till.Equal(leasetill) {
+		t.Error("Extend of taken over job should report lost lease", err)
+	}
+
+	dbmap.Err = errors.New("Job error")
+	if err := jobService.Extend(job, time.Hour); err == nil || err == ErrLeaseLost || !job.Lease_Till.Equal(leasetill) {
+		t.Error("Extend should report database error without losing lease", err)
+	}
+}
+
+func TestJobFail(t *testing.T) {
+	var cases = []struct {
+		attempts    int
+		maxattempts int
+		status      models.JobStatus
+	}{
+		{1, 3, models.JOB_STATUS_NEW},
+		{2, 3, models.JOB_STATUS_NEW},
+		{3, 3, models.JOB_STATUS_FAILED},
+		{4, 3, models.JOB_STATUS_FAILED},
+		{1, 0, models.JOB_STATUS_FAILED},
+	}
+	dbmap := new(TestJobDBMap)
+	jobService := new(JobService)
+	jobService.Repository = NewRepository(dbmap, db.TABLE_JOBS)
+
+	for _, c := range cases {
+		job := models.NewDtoJob(1, "sms", models.JOB_STATUS_RUNNING, c.attempts, "host:1", "lease", time.Now(),
+			time.Now(), "", time.Now(), time.Now())
+		before := time.Now()
+		if err := jobService.Fail(job, "Job error", time.Minute, c.maxattempts); err != nil {
+			t.Error("Fail should not return error", err)
+			continue
+		}
+		if dbmap.Args[0] != c.status || dbmap.Args[1] != "Job error" || dbmap.Args[5] != "lease" {
+			t.Error("Attempt", c.attempts, "of", c.maxattempts, "is not properly failed", dbmap.Args)
+		}
+		availableat, _ := dbmap.Args[2].(time.Time)
+		if availableat.Before(before.Add(time.Minute)) || availableat.After(time.Now().Add(time.Minute)) {
+			t.Error("Failed job should be retried after delay", availableat.Sub(before))
+		}
+		if !strings.Contains(dbmap.Query, "lease = ''") {
+			t.Error("Failed job should release lease", dbmap.Query)
+		}
+	}
+}
+
+func TestJobDefer(t *testing.T) {
+	dbmap := new(TestJobDBMap)
+	jobService := new(JobService)
+	jobService.Repository = NewRepository(dbmap, db.TABLE_JOBS)
+	until := time.Now().Add(time.Hour)
+	job := models.NewDtoJob(1, "sms", models.JOB_STATUS_RUNNING, 2, "host:1", "lease", time.Now(), time.Now(), "", time.Now(), time.Now())
+
+	if err := jobService.Defer(job, until); err != nil {
+		t.Error("Defer should not return error", err)
+	}
+	if dbmap.Args[0] != models.JOB_STATUS_NEW || dbmap.Args[1] != until || dbmap.Args[4] != "lease" {
+		t.Error("Job is not properly deferred", dbmap.Args)
+	}
+	if !strings.Contains(dbmap.Query, "attempts = if(attempts > 0, attempts - 1, 0)") || !strings.Contains(dbmap.Query, "lease = ''") {
+		t.Error("Deferred job should keep attempts and release lease", dbmap.Query)
+	}
+}
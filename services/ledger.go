@@ -0,0 +1,439 @@
+package services
+
+import (
+	"application/models"
+	"errors"
+	"github.com/coopernurse/gorp"
+	"math"
+	"sort"
+	"time"
+)
+
+const (
+	LEDGER_TABLE_HOLDS = "ledger_holds"
+	// Остаток объединения по журналу операций
+	LEDGER_OPERATIONS_BALANCE = "(select coalesce(sum(money), 0) from debet where unit_id = ?) - (select coalesce(sum(money), 0) from credit where unit_id = ?)"
+	LEDGER_DRIFT_PRECISION    = 0.005
+)
+
+var (
+	ErrNotEnoughMoney = errors.New("Not enough money")
+)
+
+type LedgerRepository interface {
+	Get(unit_id int64) (account *models.DtoLedgerAccount, err error)
+	Hold(unit_id int64, order_id int64, amount float64) (hold *models.DtoLedgerHold, err error)
+	Capture(order_id int64, source_id int64, destination_id int64, amount float64, trans *gorp.Transaction) (err error)
+	Release(order_id int64) (err error)
+	IsHeld(order_id int64) (held bool, err error)
+	ReleaseExpired(timeout time.Duration) (err error)
+	Transfer(source_id int64, destination_id int64, amount float64, trans *gorp.Transaction) (err error)
+	Reconcile() (drifted *[]models.DtoLedgerAccount, err error)
+}
+
+type LedgerService struct {
+	*Repository
+}
+
+// Сумма активных резервов заказа. Резервы должны принадлежать объединению, с которого снимаются
+func SumHolds(unit_id int64, order_id int64, holds *[]models.DtoLedgerHold) (amount float64, err error) {
+	for _, hold := range *holds {
+		if hold.Unit_ID != unit_id {
+			log.Error("Ledger hold %v of order %v belongs to another unit %v", hold.ID, order_id, hold.Unit_ID)
+			return 0, errors.New("Wrong unit")
+		}
+		amount += hold.Amount
+	}
+
+	return amount, nil
+}
+
+// Проверка достаточности доступных средств счета для суммы после снятия прежних резервов заказа
+func CheckAvailable(account *models.DtoLedgerAccount, released float64, amount float64) (err error) {
+	account.Held -= released
+	if account.Available() < models.Round(amount, 0.5, 2) {
+		return ErrNotEnoughMoney
+	}
+
+	return nil
+}
+
+func NewLedgerService(repository *Repository) *LedgerService {
+	repository.DbContext.AddTableWithName(models.DtoLedgerAccount{}, repository.Table).SetKeys(false, "unit_id")
+	repository.DbContext.AddTableWithName(models.DtoLedgerHold{}, LEDGER_TABLE_HOLDS).SetKeys(true, "id")
+	return &LedgerService{Repository: repository}
+}
+
+// Открытие счета с остатком по журналу операций, если счета еще нет
+func (ledgerservice *LedgerService) Open(unit_id int64, trans *gorp.Transaction) (err error) {
+	query := "insert ignore into " + ledgerservice.Table + " (unit_id, balance, held, drift, reconciled, updated)" +
+		" select ?, " + LEDGER_OPERATIONS_BALANCE + ", 0, 0, ?, ?"
+	if trans != nil {
+		_, err = trans.Exec(query, unit_id, unit_id, unit_id, time.Now(), time.Now())
+	} else {
+		_, err = ledgerservice.DbContext.Exec(query, unit_id, unit_id, unit_id, time.Now(), time.Now())
+	}
+	if err != nil {
+		log.Error("Error during opening ledger account object in database %v with value %v", err, unit_id)
+		return err
+	}
+
+	return nil
+}
+
+// Блокировка счетов до завершения транзакции. Счета блокируются в порядке возрастания идентификаторов
+func (ledgerservice *LedgerService) Lock(trans *gorp.Transaction, unit_ids ...int64) (accounts map[int64]*models.DtoLedgerAccount, err error) {
+	sort.Slice(unit_ids, func(i, j int) bool { return unit_ids[i] < unit_ids[j] })
+	accounts = make(map[int64]*models.DtoLedgerAccount)
+	for _, unit_id := range unit_ids {
+		if _, ok := accounts[unit_id]; ok {
+			continue
+		}
+		err = ledgerservice.Open(unit_id, trans)
+		if err != nil {
+			return nil, err
+		}
+		account := new(models.DtoLedgerAccount)
+		err = trans.SelectOne(account, "select * from "+ledgerservice.Table+" where unit_id = ? for update", unit_id)
+		if err != nil {
+			log.Error("Error during locking ledger account object in database %v with value %v", err, unit_id)
+			return nil, err
+		}
+		accounts[unit_id] = account
+	}
+
+	return accounts, nil
+}
+
+func (ledgerservice *LedgerService) Change(unit_id int64, balance float64, held float64, trans *gorp.Transaction) (err error) {
+	_, err = trans.Exec("update "+ledgerservice.Table+" set balance = balance + ?, held = held + ?, updated = ? where unit_id = ?",
+		balance, held, time.Now(), unit_id)
+	if err != nil {
+		log.Error("Error during updating ledger account object in database %v with value %v", err, unit_id)
+		return err
+	}
+
+	return nil
+}
+
+func (ledgerservice *LedgerService) Get(unit_id int64) (account *models.DtoLedgerAccount, err error) {
+	trans, err := ledgerservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during getting ledger account object from database %v with value %v", err, unit_id)
+		return nil, err
+	}
+
+	accounts, err := ledgerservice.Lock(trans, unit_id)
+	if err != nil {
+		_ = trans.Rollback()
+		return nil, err
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during getting ledger account object from database %v with value %v", err, unit_id)
+		return nil, err
+	}
+
+	return accounts[unit_id], nil
+}
+
+// Активные резервы заказа с блокировкой до завершения транзакции
+func (ledgerservice *LedgerService) GetActiveHolds(order_id int64, trans *gorp.Transaction) (holds *[]models.DtoLedgerHold, err error) {
+	holds = new([]models.DtoLedgerHold)
+	_, err = trans.Select(holds, "select * from "+LEDGER_TABLE_HOLDS+" where order_id = ? and status = ? for update",
+		order_id, models.LEDGER_HOLD_STATUS_ACTIVE)
+	if err != nil {
+		log.Error("Error during getting all ledger hold object from database %v with value %v", err, order_id)
+		return nil, err
+	}
+
+	return holds, nil
+}
+
+// Снятие активных резервов заказа внутри транзакции, возвращается снятая сумма
+func (ledgerservice *LedgerService) ReleaseHolds(unit_id int64, order_id int64, status models.LedgerHoldStatus, captured float64,
+	trans *gorp.Transaction) (amount float64, err error) {
+	holds, err := ledgerservice.GetActiveHolds(order_id, trans)
+	if err != nil {
+		return 0, err
+	}
+	amount, err = SumHolds(unit_id, order_id, holds)
+	if err != nil || len(*holds) == 0 {
+		return 0, err
+	}
+
+	_, err = trans.Exec("update "+LEDGER_TABLE_HOLDS+" set status = ?, captured = ?, updated = ? where order_id = ? and status = ?",
+		status, captured, time.Now(), order_id, models.LEDGER_HOLD_STATUS_ACTIVE)
+	if err != nil {
+		log.Error("Error during updating ledger hold object in database %v with value %v", err, order_id)
+		return 0, err
+	}
+	err = ledgerservice.Change(unit_id, 0, -amount, trans)
+	if err != nil {
+		return 0, err
+	}
+
+	return amount, nil
+}
+
+// Резервирование средств под заказ. Прежний активный резерв заказа заменяется новым
+func (ledgerservice *LedgerService) Hold(unit_id int64, order_id int64, amount float64) (hold *models.DtoLedgerHold, err error) {
+	trans, err := ledgerservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during holding money in database %v", err)
+		return nil, err
+	}
+
+	accounts, err := ledgerservice.Lock(trans, unit_id)
+	if err != nil {
+		_ = trans.Rollback()
+		return nil, err
+	}
+	released, err := ledgerservice.ReleaseHolds(unit_id, order_id, models.LEDGER_HOLD_STATUS_RELEASED, 0, trans)
+	if err != nil {
+		_ = trans.Rollback()
+		return nil, err
+	}
+	err = CheckAvailable(accounts[unit_id], released, amount)
+	if err != nil {
+		_ = trans.Rollback()
+		log.Error("Not enough money at unit balance %v to hold %v for order %v", unit_id, amount, order_id)
+		return nil, err
+	}
+
+	hold = models.NewDtoLedgerHold(0, unit_id, order_id, amount, 0, models.LEDGER_HOLD_STATUS_ACTIVE, time.Now(), time.Now())
+	err = trans.Insert(hold)
+	if err != nil {
+		_ = trans.Rollback()
+		log.Error("Error during creating ledger hold object in database %v with value %v", err, order_id)
+		return nil, err
+	}
+	err = ledgerservice.Change(unit_id, 0, amount, trans)
+	if err != nil {
+		_ = trans.Rollback()
+		return nil, err
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during holding money in database %v", err)
+		return nil, err
+	}
+
+	return hold, nil
+}
+
+// Списание фактической стоимости заказа с закрытием резерва. Без резерва списание выполняется в пределах доступных средств
+func (ledgerservice *LedgerService) Capture(order_id int64, source_id int64, destination_id int64, amount float64,
+	trans *gorp.Transaction) (err error) {
+	inTrans := trans == nil
+	if inTrans {
+		trans, err = ledgerservice.DbContext.Begin()
+		if err != nil {
+			log.Error("Error during capturing money in database %v", err)
+			return err
+		}
+	}
+
+	accounts, err := ledgerservice.Lock(trans, source_id, destination_id)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+	released, err := ledgerservice.ReleaseHolds(source_id, order_id, models.LEDGER_HOLD_STATUS_CAPTURED, amount, trans)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+	err = CheckAvailable(accounts[source_id], released, amount)
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		log.Error("Not enough money at unit balance %v to pay %v for order %v", source_id, amount, order_id)
+		return err
+	}
+
+	err = ledgerservice.Change(source_id, -amount, 0, trans)
+	if err == nil {
+		err = ledgerservice.Change(destination_id, amount, 0, trans)
+	}
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+
+	if inTrans {
+		err = trans.Commit()
+		if err != nil {
+			log.Error("Error during capturing money in database %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Снятие резерва заказа без списания средств
+func (ledgerservice *LedgerService) Release(order_id int64) (err error) {
+	unit_id, err := ledgerservice.DbContext.SelectInt("select coalesce(max(unit_id), 0) from "+LEDGER_TABLE_HOLDS+
+		" where order_id = ? and status = ?", order_id, models.LEDGER_HOLD_STATUS_ACTIVE)
+	if err != nil {
+		log.Error("Error during releasing ledger hold object in database %v with value %v", err, order_id)
+		return err
+	}
+	if unit_id == 0 {
+		return nil
+	}
+
+	trans, err := ledgerservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during releasing money in database %v", err)
+		return err
+	}
+	_, err = ledgerservice.Lock(trans, unit_id)
+	if err == nil {
+		_, err = ledgerservice.ReleaseHolds(unit_id, order_id, models.LEDGER_HOLD_STATUS_RELEASED, 0, trans)
+	}
+	if err != nil {
+		_ = trans.Rollback()
+		return err
+	}
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during releasing money in database %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// Наличие активного резерва заказа, то есть заказ принят к исполнению, но еще не оплачен
+func (ledgerservice *LedgerService) IsHeld(order_id int64) (held bool, err error) {
+	count, err := ledgerservice.DbContext.SelectInt("select count(*) from "+LEDGER_TABLE_HOLDS+" where order_id = ? and status = ?",
+		order_id, models.LEDGER_HOLD_STATUS_ACTIVE)
+	if err != nil {
+		log.Error("Error during getting ledger hold object from database %v with value %v", err, order_id)
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+// Снятие резервов, не списанных за отведенное время
+func (ledgerservice *LedgerService) ReleaseExpired(timeout time.Duration) (err error) {
+	holds := new([]models.DtoLedgerHold)
+	_, err = ledgerservice.DbContext.Select(holds, "select * from "+LEDGER_TABLE_HOLDS+
+		" where status = ? and created < ?", models.LEDGER_HOLD_STATUS_ACTIVE, time.Now().Add(-timeout))
+	if err != nil {
+		log.Error("Error during getting expired ledger hold object from database %v", err)
+		return err
+	}
+	for _, hold := range *holds {
+		log.Info("Releasing expired ledger hold of order %v", hold.Order_ID)
+		err = ledgerservice.Release(hold.Order_ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Перевод средств между счетами, например, при возврате оплаты
+func (ledgerservice *LedgerService) Transfer(source_id int64, destination_id int64, amount float64, trans *gorp.Transaction) (err error) {
+	inTrans := trans == nil
+	if inTrans {
+		trans, err = ledgerservice.DbContext.Begin()
+		if err != nil {
+			log.Error("Error during transferring money in database %v", err)
+			return err
+		}
+	}
+
+	_, err = ledgerservice.Lock(trans, source_id, destination_id)
+	if err == nil {
+		err = ledgerservice.Change(source_id, -amount, 0, trans)
+	}
+	if err == nil {
+		err = ledgerservice.Change(destination_id, amount, 0, trans)
+	}
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
+
+	if inTrans {
+		err = trans.Commit()
+		if err != nil {
+			log.Error("Error during transferring money in database %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Сверка остатков счетов с журналом операций. Остаток счета не изменяется: расхождение сохраняется в счете
+// и возвращается для оповещения
+func (ledgerservice *LedgerService) Reconcile() (drifted *[]models.DtoLedgerAccount, err error) {
+	accounts := new([]models.DtoLedgerAccount)
+	_, err = ledgerservice.DbContext.Select(accounts, "select * from "+ledgerservice.Table)
+	if err != nil {
+		log.Error("Error during getting all ledger account object from database %v", err)
+		return nil, err
+	}
+
+	drifted = new([]models.DtoLedgerAccount)
+	for _, dtoaccount := range *accounts {
+		unit_id := dtoaccount.Unit_ID
+		trans, err := ledgerservice.DbContext.Begin()
+		if err != nil {
+			log.Error("Error during reconciling ledger in database %v", err)
+			return nil, err
+		}
+		locked, err := ledgerservice.Lock(trans, unit_id)
+		if err != nil {
+			_ = trans.Rollback()
+			return nil, err
+		}
+		balance, err := trans.SelectFloat("select "+LEDGER_OPERATIONS_BALANCE, unit_id, unit_id)
+		if err != nil {
+			_ = trans.Rollback()
+			log.Error("Error during reconciling ledger in database %v with value %v", err, unit_id)
+			return nil, err
+		}
+		account := locked[unit_id]
+		account.Drift = models.Round(account.Balance-balance, 0.5, 2)
+		if math.Abs(account.Drift) < LEDGER_DRIFT_PRECISION {
+			account.Drift = 0
+		}
+		_, err = trans.Exec("update "+ledgerservice.Table+" set drift = ?, reconciled = ? where unit_id = ?",
+			account.Drift, time.Now(), unit_id)
+		if err != nil {
+			_ = trans.Rollback()
+			log.Error("Error during reconciling ledger in database %v with value %v", err, unit_id)
+			return nil, err
+		}
+		err = trans.Commit()
+		if err != nil {
+			log.Error("Error during reconciling ledger in database %v", err)
+			return nil, err
+		}
+		if account.Drift != 0 {
+			log.Error("Ledger account of unit %v drifts from operations by %v", unit_id, account.Drift)
+			*drifted = append(*drifted, *account)
+		}
+	}
+
+	return drifted, nil
+}
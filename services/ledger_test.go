@@ -0,0 +1,100 @@
+package services
+
+import (
+	"application/models"
+	"testing"
+	"time"
+)
+
+func TestSumHolds(t *testing.T) {
+	var holds = []models.DtoLedgerHold{
+		{ID: 1, Unit_ID: 10, Order_ID: 100, Amount: 12.5},
+		{ID: 2, Unit_ID: 10, Order_ID: 100, Amount: 7.25},
+	}
+
+	amount, err := SumHolds(10, 100, &holds)
+	if err != nil || amount != 19.75 {
+		t.Error("Order holds are not properly summed", amount, err)
+	}
+	amount, err = SumHolds(10, 100, &[]models.DtoLedgerHold{})
+	if err != nil || amount != 0 {
+		t.Error("Order without holds should release nothing", amount, err)
+	}
+	amount, err = SumHolds(20, 100, &holds)
+	if err == nil || amount != 0 {
+		t.Error("Holds of another unit should not be released", amount, err)
+	}
+}
+
+func TestCheckAvailable(t *testing.T) {
+	var cases = []struct {
+		balance  float64
+		held     float64
+		released float64
+		amount   float64
+		rest     float64
+		err      error
+	}{
+		// Резервирование из свободных средств
+		{100, 0, 0, 40, 0, nil},
+		{100, 40, 0, 60, 40, nil},
+		{100, 40, 0, 60.01, 40, ErrNotEnoughMoney},
+		// Замена прежнего резерва заказа и списание с закрытием резерва
+		{100, 40, 40, 100, 0, nil},
+		{100, 70, 40, 70, 30, nil},
+		{100, 70, 40, 70.01, 30, ErrNotEnoughMoney},
+		// Списание без резерва
+		{0, 0, 0, 0.01, 0, ErrNotEnoughMoney},
+		{10, 0, 0, 10.004, 0, nil},
+		{10, 0, 0, 10.005, 0, ErrNotEnoughMoney},
+		{-5, 0, 0, 0, 0, ErrNotEnoughMoney},
+	}
+
+	for _, c := range cases {
+		account := models.NewDtoLedgerAccount(1, c.balance, c.held, 0, time.Time{}, time.Time{})
+		if err := CheckAvailable(account, c.released, c.amount); err != c.err {
+			t.Error("Available money of", c.balance, c.held, c.released, "is not properly checked for", c.amount, err)
+		}
+		if account.Held != c.rest {
+			t.Error("Released holds are not properly subtracted", c.held, c.released, account.Held)
+		}
+	}
+}
+
+func TestLedgerHoldLifecycle(t *testing.T) {
+	account := models.NewDtoLedgerAccount(1, 100, 0, 0, time.Time{}, time.Time{})
+
+	// Резерв под первый заказ
+	if err := CheckAvailable(account, 0, 40); err != nil {
+		t.Error("Hold should be accepted", err)
+		return
+	}
+	account.Held += 40
+	// Второй заказ не помещается в оставшиеся средства
+	if err := CheckAvailable(account, 0, 60.5); err != ErrNotEnoughMoney {
+		t.Error("Hold over available money should be rejected", err)
+	}
+	if account.Held != 40 || account.Available() != 60 {
+		t.Error("Rejected hold should not change the account", account.Held, account.Available())
+	}
+	// Списание фактической стоимости первого заказа с закрытием резерва
+	holds := []models.DtoLedgerHold{{Unit_ID: 1, Order_ID: 100, Amount: 40}}
+	released, err := SumHolds(1, 100, &holds)
+	if err != nil {
+		t.Error("Holds should be summed", err)
+		return
+	}
+	if err = CheckAvailable(account, released, 35.5); err != nil {
+		t.Error("Capture within the hold should be accepted", err)
+		return
+	}
+	account.Balance -= 35.5
+	if account.Balance != 64.5 || account.Held != 0 || account.Available() != 64.5 {
+		t.Error("Capture is not properly booked", account.Balance, account.Held)
+	}
+	// Снятие резерва без списания возвращает средства в доступные
+	account.Held += 20
+	if err = CheckAvailable(account, 20, 0); err != nil || account.Available() != 64.5 {
+		t.Error("Release is not properly booked", account.Held, err)
+	}
+}
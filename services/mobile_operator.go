@@ -2,6 +2,8 @@ package services
 
 import (
 	"application/models"
+	"application/numbering"
+	"errors"
 )
 
 type MobileOperatorRepository interface {
@@ -9,6 +11,7 @@ type MobileOperatorRepository interface {
 	GetDefault() (mobileoperator *models.DtoMobileOperator, err error)
 	GetAll() (mobileoperators *[]models.ApiMobileOperator, err error)
 	FindAll() (mobileoperators *[]models.DtoMobileOperator, err error)
+	FindByMobilePhone(mobilephone uint64) (mobileoperator *models.DtoMobileOperator, region string, err error)
 }
 
 type MobileOperatorService struct {
@@ -66,3 +69,21 @@ func (mobileoperatorservice *MobileOperatorService) FindAll() (mobileoperators *
 
 	return mobileoperators, nil
 }
+
+// Определение мобильного оператора и региона номера по плану нумерации
+func (mobileoperatorservice *MobileOperatorService) FindByMobilePhone(mobilephone uint64) (mobileoperator *models.DtoMobileOperator,
+	region string, err error) {
+	uuid, region, found := numbering.Lookup(mobilephone)
+	if !found || uuid == "" {
+		log.Error("Can't find mobile operator in numbering plan with value %v", mobilephone)
+		return nil, region, errors.New("Mobile operator not found")
+	}
+	mobileoperator = new(models.DtoMobileOperator)
+	err = mobileoperatorservice.DbContext.SelectOne(mobileoperator, "select * from "+mobileoperatorservice.Table+" where uuid = ?", uuid)
+	if err != nil {
+		log.Error("Error during finding mobile operator object from database %v with value %v", err, uuid)
+		return nil, region, err
+	}
+
+	return mobileoperator, region, nil
+}
@@ -21,6 +21,7 @@ type OrderRepository interface {
 	GetByUnit(unit_id int64) (orders *[]models.ApiBriefOrder, err error)
 	GetAll(filter string) (orders *[]models.ApiListOrder, err error)
 	Get4Processing() (orders *[]models.ApiTinyOrder, err error)
+	Get4Resumption() (orders *[]models.ApiTinyOrder, err error)
 	GetFinance(unit_id int64, filter string) (orders *[]models.ApiFinanceOrder, err error)
 	GetResult(unit_id int64, filter string) (order *models.ApiResultOrder, err error)
 	GetMeta(user_id int64) (order *models.ApiMetaOrder, err error)
@@ -200,8 +201,8 @@ func (orderservice *OrderService) Get4Processing() (orders *[]models.ApiTinyOrde
 	orders = new([]models.ApiTinyOrder)
 	_, err = orderservice.DbContext.Select(orders, "select id, service_id from "+orderservice.Table+
 		" where (id not in (select order_id from order_statuses where status_id in ("+
-		fmt.Sprintf("%v, %v, %v, %v, %v", models.ORDER_STATUS_OPEN, models.ORDER_STATUS_CANCEL, models.ORDER_STATUS_MODERATOR_CLOSE,
-			models.ORDER_STATUS_ARCHIVE, models.ORDER_STATUS_DEL)+") and value = 1))"+
+		fmt.Sprintf("%v, %v, %v, %v, %v, %v", models.ORDER_STATUS_OPEN, models.ORDER_STATUS_CANCEL, models.ORDER_STATUS_SUPPLIER_CLOSE,
+			models.ORDER_STATUS_MODERATOR_CLOSE, models.ORDER_STATUS_ARCHIVE, models.ORDER_STATUS_DEL)+") and value = 1))"+
 		" and (id in (select order_id from order_statuses where status_id in ("+
 		fmt.Sprintf("%v, %v", models.ORDER_STATUS_COMPLETED, models.ORDER_STATUS_MODERATOR_CONFIRMED)+") and value = 1))")
 	if err != nil {
@@ -212,6 +213,25 @@ func (orderservice *OrderService) Get4Processing() (orders *[]models.ApiTinyOrde
 	return orders, nil
 }
 
+// Заказы, исполнение которых было начато, но не завершено
+func (orderservice *OrderService) Get4Resumption() (orders *[]models.ApiTinyOrder, err error) {
+	orders = new([]models.ApiTinyOrder)
+	_, err = orderservice.DbContext.Select(orders, "select id, service_id from "+orderservice.Table+
+		" where (id not in (select order_id from order_statuses where status_id in ("+
+		fmt.Sprintf("%v, %v, %v, %v, %v", models.ORDER_STATUS_SUPPLIER_CLOSE, models.ORDER_STATUS_CANCEL, models.ORDER_STATUS_MODERATOR_CLOSE,
+			models.ORDER_STATUS_ARCHIVE, models.ORDER_STATUS_DEL)+") and value = 1))"+
+		" and (id in (select order_id from order_statuses where status_id = "+
+		fmt.Sprintf("%v", models.ORDER_STATUS_OPEN)+" and value = 1))"+
+		" and (id in (select order_id from order_statuses where status_id = "+
+		fmt.Sprintf("%v", models.ORDER_STATUS_COMPLETED)+" and value = 1))")
+	if err != nil {
+		log.Error("Error during getting all order object from database %v ", err)
+		return nil, err
+	}
+
+	return orders, nil
+}
+
 func (orderservice *OrderService) GetFinance(unit_id int64, filter string) (orders *[]models.ApiFinanceOrder, err error) {
 	orders = new([]models.ApiFinanceOrder)
 	_, err = orderservice.DbContext.Select(orders, "select o.id as orderId, o.project_id as projectId, o.begin_date as beginDate,"+
@@ -0,0 +1,68 @@
+package services
+
+import (
+	"application/models"
+)
+
+type OrderCheckpointRepository interface {
+	Get(order_id int64, step int) (checkpoint *models.DtoOrderCheckpoint, err error)
+	GetByOrder(order_id int64) (checkpoints *[]models.DtoOrderCheckpoint, err error)
+	Save(checkpoint *models.DtoOrderCheckpoint) (err error)
+	DeleteByOrder(order_id int64) (err error)
+}
+
+type OrderCheckpointService struct {
+	*Repository
+}
+
+func NewOrderCheckpointService(repository *Repository) *OrderCheckpointService {
+	repository.DbContext.AddTableWithName(models.DtoOrderCheckpoint{}, repository.Table).SetKeys(false, "order_id", "step")
+	return &OrderCheckpointService{Repository: repository}
+}
+
+func (ordercheckpointservice *OrderCheckpointService) Get(order_id int64, step int) (checkpoint *models.DtoOrderCheckpoint, err error) {
+	checkpoint = new(models.DtoOrderCheckpoint)
+	err = ordercheckpointservice.DbContext.SelectOne(checkpoint, "select * from "+ordercheckpointservice.Table+
+		" where order_id = ? and step = ?", order_id, step)
+	if err != nil {
+		log.Error("Error during getting order checkpoint object from database %v with value %v, %v", err, order_id, step)
+		return nil, err
+	}
+
+	return checkpoint, nil
+}
+
+func (ordercheckpointservice *OrderCheckpointService) GetByOrder(order_id int64) (checkpoints *[]models.DtoOrderCheckpoint, err error) {
+	checkpoints = new([]models.DtoOrderCheckpoint)
+	_, err = ordercheckpointservice.DbContext.Select(checkpoints, "select * from "+ordercheckpointservice.Table+
+		" where order_id = ? order by step", order_id)
+	if err != nil {
+		log.Error("Error during getting all order checkpoint object from database %v with value %v", err, order_id)
+		return nil, err
+	}
+
+	return checkpoints, nil
+}
+
+func (ordercheckpointservice *OrderCheckpointService) Save(checkpoint *models.DtoOrderCheckpoint) (err error) {
+	_, err = ordercheckpointservice.DbContext.Exec("insert into "+ordercheckpointservice.Table+
+		" (order_id, step, done, data, created, updated) values (?, ?, ?, ?, ?, ?)"+
+		" on duplicate key update done = values(done), data = values(data), updated = values(updated)",
+		checkpoint.Order_ID, checkpoint.Step, checkpoint.Done, checkpoint.Data, checkpoint.Created, checkpoint.Updated)
+	if err != nil {
+		log.Error("Error during saving order checkpoint object in database %v with value %v, %v", err, checkpoint.Order_ID, checkpoint.Step)
+		return err
+	}
+
+	return nil
+}
+
+func (ordercheckpointservice *OrderCheckpointService) DeleteByOrder(order_id int64) (err error) {
+	_, err = ordercheckpointservice.DbContext.Exec("delete from "+ordercheckpointservice.Table+" where order_id = ?", order_id)
+	if err != nil {
+		log.Error("Error during deleting order checkpoint object in database %v with value %v", err, order_id)
+		return err
+	}
+
+	return nil
+}
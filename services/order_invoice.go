@@ -35,7 +35,7 @@ func (orderinvoiceservice *OrderInvoiceService) Get(id int64) (orderinvoice *mod
 func (orderinvoiceservice *OrderInvoiceService) GetByOrder(order_id int64) (orderinvoices *[]models.DtoOrderInvoice, err error) {
 	orderinvoices = new([]models.DtoOrderInvoice)
 	_, err = orderinvoiceservice.DbContext.Select(orderinvoices,
-		"select i* from "+orderinvoiceservice.Table+" where order_id = ?", order_id)
+		"select * from "+orderinvoiceservice.Table+" where order_id = ?", order_id)
 	if err != nil {
 		log.Error("Error during getting all order invoice object from database %v with value %v", err, order_id)
 		return nil, err
@@ -0,0 +1,51 @@
+package services
+
+import (
+	"application/models"
+)
+
+type OrderRouteRepository interface {
+	GetByOrder(order_id int64) (routes *[]models.DtoOrderRoute, err error)
+	Create(route *models.DtoOrderRoute) (err error)
+	DeleteByOrder(order_id int64) (err error)
+}
+
+type OrderRouteService struct {
+	*Repository
+}
+
+func NewOrderRouteService(repository *Repository) *OrderRouteService {
+	repository.DbContext.AddTableWithName(models.DtoOrderRoute{}, repository.Table).SetKeys(true, "id")
+	return &OrderRouteService{Repository: repository}
+}
+
+func (orderrouteservice *OrderRouteService) GetByOrder(order_id int64) (routes *[]models.DtoOrderRoute, err error) {
+	routes = new([]models.DtoOrderRoute)
+	_, err = orderrouteservice.DbContext.Select(routes, "select * from "+orderrouteservice.Table+" where order_id = ? order by id", order_id)
+	if err != nil {
+		log.Error("Error during getting all order route object from database %v with value %v", err, order_id)
+		return nil, err
+	}
+
+	return routes, nil
+}
+
+func (orderrouteservice *OrderRouteService) Create(route *models.DtoOrderRoute) (err error) {
+	err = orderrouteservice.DbContext.Insert(route)
+	if err != nil {
+		log.Error("Error during creating order route object in database %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (orderrouteservice *OrderRouteService) DeleteByOrder(order_id int64) (err error) {
+	_, err = orderrouteservice.DbContext.Exec("delete from "+orderrouteservice.Table+" where order_id = ?", order_id)
+	if err != nil {
+		log.Error("Error during deleting order route object in database %v with value %v", err, order_id)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,48 @@
+package services
+
+import (
+	"application/models"
+	"time"
+)
+
+type OrderRoutingRepository interface {
+	Get(order_id int64) (routing *models.DtoOrderRouting, err error)
+	Save(routing *models.DtoOrderRouting) (err error)
+}
+
+type OrderRoutingService struct {
+	*Repository
+}
+
+func NewOrderRoutingService(repository *Repository) *OrderRoutingService {
+	repository.DbContext.AddTableWithName(models.DtoOrderRouting{}, repository.Table).SetKeys(false, "order_id")
+	return &OrderRoutingService{Repository: repository}
+}
+
+// Маршрутизация заказа, для которого она не задана, выполняется вручную выбранным поставщиком
+func (orderroutingservice *OrderRoutingService) Get(order_id int64) (routing *models.DtoOrderRouting, err error) {
+	routings := new([]models.DtoOrderRouting)
+	_, err = orderroutingservice.DbContext.Select(routings, "select * from "+orderroutingservice.Table+" where order_id = ?", order_id)
+	if err != nil {
+		log.Error("Error during getting order routing object from database %v with value %v", err, order_id)
+		return nil, err
+	}
+	if len(*routings) == 0 {
+		return models.NewDtoOrderRouting(order_id, models.ROUTING_POLICY_MANUAL, time.Time{}, time.Time{}), nil
+	}
+
+	return &(*routings)[0], nil
+}
+
+func (orderroutingservice *OrderRoutingService) Save(routing *models.DtoOrderRouting) (err error) {
+	_, err = orderroutingservice.DbContext.Exec("insert into "+orderroutingservice.Table+
+		" (order_id, policy, created, updated) values (?, ?, ?, ?)"+
+		" on duplicate key update policy = values(policy), updated = values(updated)",
+		routing.Order_ID, routing.Policy, routing.Created, routing.Updated)
+	if err != nil {
+		log.Error("Error during saving order routing object in database %v with value %v", err, routing.Order_ID)
+		return err
+	}
+
+	return nil
+}
@@ -14,13 +14,15 @@ type OrderStatusRepository interface {
 }
 
 type OrderStatusService struct {
+	WebhookDeliveryRepository WebhookDeliveryRepository
+
 	*Repository
 }
 
 func NewOrderStatusService(repository *Repository) *OrderStatusService {
 	repository.DbContext.AddTableWithName(models.DtoOrderStatus{}, repository.Table).SetKeys(false, "order_id", "status_id")
 	return &OrderStatusService{
-		repository,
+		Repository: repository,
 	}
 }
 
@@ -75,17 +77,24 @@ func (orderstatusservice *OrderStatusService) Update(orderstatus *models.DtoOrde
 	return nil
 }
 
+// Статус и событие вебхука о нем сохраняются в одной транзакции
 func (orderstatusservice *OrderStatusService) Save(orderstatus *models.DtoOrderStatus, trans *gorp.Transaction) (err error) {
-	var count int64
-	if trans != nil {
-		count, err = trans.SelectInt("select count(*) from "+orderstatusservice.Table+
-			" where order_id = ? and status_id = ?", orderstatus.Order_ID, orderstatus.Status_ID)
-	} else {
-		count, err = orderstatusservice.DbContext.SelectInt("select count(*) from "+orderstatusservice.Table+
-			" where order_id = ? and status_id = ?", orderstatus.Order_ID, orderstatus.Status_ID)
+	inTrans := trans == nil
+	if inTrans {
+		trans, err = orderstatusservice.DbContext.Begin()
+		if err != nil {
+			log.Error("Error during saving order status object in database %v with value %v, %v", err, orderstatus.Order_ID, orderstatus.Status_ID)
+			return err
+		}
 	}
+
+	count, err := trans.SelectInt("select count(*) from "+orderstatusservice.Table+
+		" where order_id = ? and status_id = ?", orderstatus.Order_ID, orderstatus.Status_ID)
 	if err != nil {
 		log.Error("Error during saving order status object in database %v with value %v, %v", err, orderstatus.Order_ID, orderstatus.Status_ID)
+		if inTrans {
+			_ = trans.Rollback()
+		}
 		return err
 	}
 	if count == 0 {
@@ -93,6 +102,25 @@ func (orderstatusservice *OrderStatusService) Save(orderstatus *models.DtoOrderS
 	} else {
 		err = orderstatusservice.Update(orderstatus, trans)
 	}
+	if err == nil && orderstatusservice.WebhookDeliveryRepository != nil {
+		err = orderstatusservice.WebhookDeliveryRepository.EnqueueByOrder(orderstatus.Order_ID, models.WEBHOOK_EVENT_ORDER_STATUS,
+			models.ApiOrderStatusEvent{Order_ID: orderstatus.Order_ID, Status_ID: orderstatus.Status_ID, Value: orderstatus.Value,
+				Comments: orderstatus.Comments}, trans)
+	}
+	if err != nil {
+		if inTrans {
+			_ = trans.Rollback()
+		}
+		return err
+	}
 
-	return err
+	if inTrans {
+		err = trans.Commit()
+		if err != nil {
+			log.Error("Error during saving order status object in database %v with value %v, %v", err, orderstatus.Order_ID, orderstatus.Status_ID)
+			return err
+		}
+	}
+
+	return nil
 }
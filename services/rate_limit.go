@@ -0,0 +1,110 @@
+package services
+
+import (
+	"application/models"
+	"sync"
+	"time"
+)
+
+type RateLimitRepository interface {
+	Take(bucket string, rate float64, burst int64, now time.Time) (ratelimit *models.DtoRateLimit, allowed bool, err error)
+	DeleteExpired(before time.Time) (err error)
+}
+
+// Хранение корзин в бд для нескольких экземпляров сервера
+type RateLimitService struct {
+	*Repository
+}
+
+func NewRateLimitService(repository *Repository) *RateLimitService {
+	repository.DbContext.AddTableWithName(models.DtoRateLimit{}, repository.Table).SetKeys(false, "bucket")
+	return &RateLimitService{Repository: repository}
+}
+
+// Списание маркера из корзины с блокировкой строки корзины до конца транзакции
+func (ratelimitservice *RateLimitService) Take(bucket string, rate float64, burst int64,
+	now time.Time) (ratelimit *models.DtoRateLimit, allowed bool, err error) {
+	trans, err := ratelimitservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during taking rate limit object in database %v with value %v", err, bucket)
+		return nil, false, err
+	}
+
+	_, err = trans.Exec("insert ignore into "+ratelimitservice.Table+" (bucket, tokens, updated, expires) values (?, ?, ?, ?)",
+		bucket, float64(burst), now, now)
+	if err != nil {
+		log.Error("Error during taking rate limit object in database %v with value %v", err, bucket)
+		_ = trans.Rollback()
+		return nil, false, err
+	}
+	ratelimit = new(models.DtoRateLimit)
+	err = trans.SelectOne(ratelimit, "select * from "+ratelimitservice.Table+" where bucket = ? for update", bucket)
+	if err != nil {
+		log.Error("Error during taking rate limit object in database %v with value %v", err, bucket)
+		_ = trans.Rollback()
+		return nil, false, err
+	}
+	allowed = ratelimit.Take(rate, burst, now)
+	_, err = trans.Update(ratelimit)
+	if err != nil {
+		log.Error("Error during taking rate limit object in database %v with value %v", err, bucket)
+		_ = trans.Rollback()
+		return nil, false, err
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during taking rate limit object in database %v with value %v", err, bucket)
+		return nil, false, err
+	}
+
+	return ratelimit, allowed, nil
+}
+
+func (ratelimitservice *RateLimitService) DeleteExpired(before time.Time) (err error) {
+	_, err = ratelimitservice.DbContext.Exec("delete from "+ratelimitservice.Table+" where expires < ?", before)
+	if err != nil {
+		log.Error("Error during deleting rate limit object in database %v with value %v", err, before)
+		return err
+	}
+
+	return nil
+}
+
+// Хранение корзин в памяти одного экземпляра сервера
+type RateLimitMemoryService struct {
+	sync.Mutex
+	buckets map[string]models.DtoRateLimit
+}
+
+func NewRateLimitMemoryService() *RateLimitMemoryService {
+	return &RateLimitMemoryService{buckets: make(map[string]models.DtoRateLimit)}
+}
+
+func (ratelimitmemoryservice *RateLimitMemoryService) Take(bucket string, rate float64, burst int64,
+	now time.Time) (ratelimit *models.DtoRateLimit, allowed bool, err error) {
+	ratelimitmemoryservice.Lock()
+	defer ratelimitmemoryservice.Unlock()
+
+	dtoratelimit, ok := ratelimitmemoryservice.buckets[bucket]
+	if !ok {
+		dtoratelimit = *models.NewDtoRateLimit(bucket, float64(burst), now, now)
+	}
+	allowed = dtoratelimit.Take(rate, burst, now)
+	ratelimitmemoryservice.buckets[bucket] = dtoratelimit
+
+	return &dtoratelimit, allowed, nil
+}
+
+func (ratelimitmemoryservice *RateLimitMemoryService) DeleteExpired(before time.Time) (err error) {
+	ratelimitmemoryservice.Lock()
+	defer ratelimitmemoryservice.Unlock()
+
+	for bucket, dtoratelimit := range ratelimitmemoryservice.buckets {
+		if dtoratelimit.Expires.Before(before) {
+			delete(ratelimitmemoryservice.buckets, bucket)
+		}
+	}
+
+	return nil
+}
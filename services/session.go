@@ -4,7 +4,9 @@ import (
 	"application/config"
 	"application/models"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"github.com/coopernurse/gorp"
 	"github.com/go-martini/martini"
@@ -19,10 +21,15 @@ type SessionRepository interface {
 	GetAndSaveSession(request *http.Request, r render.Render, params martini.Params,
 		updateSession bool, takeFromURI bool, quietMode bool) (session *models.DtoSession, token string, err error)
 	Get(token string) (session *models.DtoSession, err error)
+	GetByUser(userid int64) (sessions *[]models.DtoSession, err error)
 	Create(session *models.DtoSession, inTrans bool) (err error)
 	Update(session *models.DtoSession, briefly bool, inTrans bool) (err error)
-	Delete(token string, inTrans bool) (err error)
+	Delete(hash string, inTrans bool) (err error)
 	DeleteByUser(userid int64, trans *gorp.Transaction) (err error)
+	DeleteOthers(userid int64, hash string) (err error)
+	DeleteByUnit(unitid int64) (err error)
+	DeleteExpired() (err error)
+	DeleteUnhashed() (err error)
 }
 
 type SessionService struct {
@@ -36,8 +43,32 @@ const (
 	ACCESS_TOKEN_COOKIE_NAME = "Access-Token"
 	ACCESS_TOKEN_PARAM_NAME  = "token"
 	ACCESS_TOKEN_LENGTH      = 255
+
+	SESSION_LIFETIME_DEFAULT = 30 * 24 * time.Hour // Максимальное время жизни сессии по умолчанию
 )
 
+// В таблице сессий хранится только хэш токена, сам токен известен лишь клиенту
+func HashSessionToken(token string) string {
+	hash := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(hash[:])
+}
+
+func GetSessionLifetime() time.Duration {
+	if config.Configuration.Server.SessionLifetime > 0 {
+		return config.Configuration.Server.SessionLifetime
+	}
+	return SESSION_LIFETIME_DEFAULT
+}
+
+// Время окончания действия сессии по неактивности или по максимальному времени жизни
+func GetSessionExpires(session *models.DtoSession) time.Time {
+	expires := session.LastActivity.Add(config.Configuration.Server.SessionTimeout)
+	if lifetime := session.Created.Add(GetSessionLifetime()); lifetime.Before(expires) {
+		return lifetime
+	}
+	return expires
+}
+
 func NewSessionService(repository *Repository) *SessionService {
 	repository.DbContext.AddTableWithName(models.DtoSession{}, repository.Table).SetKeys(false, "token")
 	return &SessionService{Repository: repository}
@@ -74,14 +105,14 @@ func (sessionservice *SessionService) GetAndSaveSession(request *http.Request, r
 		if errCookie == nil {
 			token, err = url.QueryUnescape(cookie.Value)
 			if err != nil {
-				log.Error("Can't unescape session cookie %v with value %v", err, cookie.Value)
+				log.Error("Can't unescape session cookie %v", err)
 			}
 		}
 	}
 
 	if token == "" || len(token) > ACCESS_TOKEN_LENGTH {
 		if !quietMode {
-			log.Error("Can't find session token %v", token)
+			log.Error("Can't find session token with length %v", len(token))
 		}
 		return nil, "", errors.New("Missing token")
 	}
@@ -89,14 +120,14 @@ func (sessionservice *SessionService) GetAndSaveSession(request *http.Request, r
 	session, err = sessionservice.Get(token)
 	if err != nil {
 		if !quietMode {
-			log.Error("Can't find session object in database %v with token %v", err, token)
+			log.Error("Can't find session object in database %v with value %v", err, HashSessionToken(token))
 		}
 		return nil, token, err
 	}
 
-	if time.Now().Sub(session.LastActivity) > config.Configuration.Server.SessionTimeout {
+	if time.Now().After(GetSessionExpires(session)) {
 		if !quietMode {
-			log.Error("Session has been expired %v with value %v", session.LastActivity, token)
+			log.Error("Session has been expired %v with value %v", session.LastActivity, session.Hash)
 		}
 		return nil, token, errors.New("Expired session")
 	}
@@ -113,27 +144,49 @@ func (sessionservice *SessionService) GetAndSaveSession(request *http.Request, r
 }
 
 func (sessionservice *SessionService) Get(token string) (session *models.DtoSession, err error) {
+	hash := HashSessionToken(token)
 	session = new(models.DtoSession)
-	err = sessionservice.DbContext.SelectOne(session, "select * from "+sessionservice.Table+" where token = ?", token)
+	err = sessionservice.DbContext.SelectOne(session, "select * from "+sessionservice.Table+" where token = ?", hash)
 	if err != nil {
-		log.Error("Error during getting session object from database %v with value %v", err, token)
+		log.Error("Error during getting session object from database %v with value %v", err, hash)
 		return nil, err
 	}
 
 	var roles *[]models.UserRole
-	roles, err = sessionservice.GroupRepository.GetBySession(token)
+	roles, err = sessionservice.GroupRepository.GetBySession(hash)
 	if err != nil {
-		log.Error("Error during getting session object from database %v with value %v", err, token)
+		log.Error("Error during getting session object from database %v with value %v", err, hash)
 		return nil, err
 	}
 	session.Roles = *roles
+	session.AccessToken = token
 
 	return session, nil
 }
 
+// Действующие сессии пользователя, начиная с последней использованной
+func (sessionservice *SessionService) GetByUser(userid int64) (sessions *[]models.DtoSession, err error) {
+	sessions = new([]models.DtoSession)
+	now := time.Now()
+	_, err = sessionservice.DbContext.Select(sessions, "select * from "+sessionservice.Table+
+		" where user_id = ? and lastActivity > ? and created > ? order by lastActivity desc",
+		userid, now.Add(-config.Configuration.Server.SessionTimeout), now.Add(-GetSessionLifetime()))
+	if err != nil {
+		log.Error("Error during getting session objects for user from database %v with value %v", err, userid)
+		return nil, err
+	}
+
+	return sessions, nil
+}
+
 func (sessionservice *SessionService) Create(session *models.DtoSession, inTrans bool) (err error) {
 	var trans *gorp.Transaction
 
+	session.Hash = HashSessionToken(session.AccessToken)
+	if session.Created.IsZero() {
+		session.Created = time.Now()
+	}
+
 	if inTrans {
 		trans, err = sessionservice.DbContext.Begin()
 		if err != nil {
@@ -155,12 +208,12 @@ func (sessionservice *SessionService) Create(session *models.DtoSession, inTrans
 		return err
 	}
 
-	err = sessionservice.GroupRepository.SetBySession(session.AccessToken, &session.Roles, trans)
+	err = sessionservice.GroupRepository.SetBySession(session.Hash, &session.Roles, trans)
 	if err != nil {
 		if inTrans {
 			_ = trans.Rollback()
 		}
-		log.Error("Error during creating session object in database %v with value %v", err, session.AccessToken)
+		log.Error("Error during creating session object in database %v with value %v", err, session.Hash)
 		return err
 	}
 
@@ -195,17 +248,17 @@ func (sessionservice *SessionService) Update(session *models.DtoSession, briefly
 		if inTrans {
 			_ = trans.Rollback()
 		}
-		log.Error("Error during updating session object in database %v with value %v", err, session.AccessToken)
+		log.Error("Error during updating session object in database %v with value %v", err, session.Hash)
 		return err
 	}
 
 	if !briefly {
-		err = sessionservice.GroupRepository.SetBySession(session.AccessToken, &session.Roles, trans)
+		err = sessionservice.GroupRepository.SetBySession(session.Hash, &session.Roles, trans)
 		if err != nil {
 			if inTrans {
 				_ = trans.Rollback()
 			}
-			log.Error("Error during updating session object in database %v with value %v", err, session.AccessToken)
+			log.Error("Error during updating session object in database %v with value %v", err, session.Hash)
 			return err
 		}
 	}
@@ -221,7 +274,7 @@ func (sessionservice *SessionService) Update(session *models.DtoSession, briefly
 	return nil
 }
 
-func (sessionservice *SessionService) Delete(token string, inTrans bool) (err error) {
+func (sessionservice *SessionService) Delete(hash string, inTrans bool) (err error) {
 	var trans *gorp.Transaction
 
 	if inTrans {
@@ -232,25 +285,25 @@ func (sessionservice *SessionService) Delete(token string, inTrans bool) (err er
 		}
 	}
 
-	err = sessionservice.GroupRepository.SetBySession(token, &[]models.UserRole{}, trans)
+	err = sessionservice.GroupRepository.SetBySession(hash, &[]models.UserRole{}, trans)
 	if err != nil {
 		if inTrans {
 			_ = trans.Rollback()
 		}
-		log.Error("Error during deleting session object in database %v with value %v", err, token)
+		log.Error("Error during deleting session object in database %v with value %v", err, hash)
 		return err
 	}
 
 	if inTrans {
-		_, err = trans.Exec("delete from "+sessionservice.Table+" where token = ?", token)
+		_, err = trans.Exec("delete from "+sessionservice.Table+" where token = ?", hash)
 	} else {
-		_, err = sessionservice.DbContext.Exec("delete from "+sessionservice.Table+" where token = ?", token)
+		_, err = sessionservice.DbContext.Exec("delete from "+sessionservice.Table+" where token = ?", hash)
 	}
 	if err != nil {
 		if inTrans {
 			_ = trans.Rollback()
 		}
-		log.Error("Error during deleting session object in database %v with value %v", err, token)
+		log.Error("Error during deleting session object in database %v with value %v", err, hash)
 		return err
 	}
 
@@ -284,3 +337,129 @@ func (sessionservice *SessionService) DeleteByUser(userid int64, trans *gorp.Tra
 
 	return nil
 }
+
+// Завершение всех сессий пользователя, кроме указанной
+func (sessionservice *SessionService) DeleteOthers(userid int64, hash string) (err error) {
+	trans, err := sessionservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during deleting session object for user in database %v with value %v", err, userid)
+		return err
+	}
+
+	_, err = trans.Exec("delete from sessiongroups where session_token in (select token from "+sessionservice.Table+
+		" where user_id = ? and token <> ?)", userid, hash)
+	if err != nil {
+		log.Error("Error during deleting session object for user in database %v with value %v", err, userid)
+		_ = trans.Rollback()
+		return err
+	}
+	_, err = trans.Exec("delete from "+sessionservice.Table+" where user_id = ? and token <> ?", userid, hash)
+	if err != nil {
+		log.Error("Error during deleting session object for user in database %v with value %v", err, userid)
+		_ = trans.Rollback()
+		return err
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during deleting session object for user in database %v with value %v", err, userid)
+		return err
+	}
+
+	return nil
+}
+
+func (sessionservice *SessionService) DeleteByUnit(unitid int64) (err error) {
+	trans, err := sessionservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during deleting session object for unit in database %v with value %v", err, unitid)
+		return err
+	}
+
+	_, err = trans.Exec("delete from sessiongroups where session_token in (select s.token from "+sessionservice.Table+
+		" s inner join users u on u.id = s.user_id where u.unit_id = ?)", unitid)
+	if err != nil {
+		log.Error("Error during deleting session object for unit in database %v with value %v", err, unitid)
+		_ = trans.Rollback()
+		return err
+	}
+	_, err = trans.Exec("delete from "+sessionservice.Table+" where user_id in (select id from users where unit_id = ?)", unitid)
+	if err != nil {
+		log.Error("Error during deleting session object for unit in database %v with value %v", err, unitid)
+		_ = trans.Rollback()
+		return err
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during deleting session object for unit in database %v with value %v", err, unitid)
+		return err
+	}
+
+	return nil
+}
+
+// Удаление сессий, завершенных по неактивности или по максимальному времени жизни
+func (sessionservice *SessionService) DeleteExpired() (err error) {
+	now := time.Now()
+	idle := now.Add(-config.Configuration.Server.SessionTimeout)
+	lifetime := now.Add(-GetSessionLifetime())
+	trans, err := sessionservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during deleting expired session objects in database %v", err)
+		return err
+	}
+
+	_, err = trans.Exec("delete from sessiongroups where session_token in (select token from "+sessionservice.Table+
+		" where lastActivity < ? or created < ?)", idle, lifetime)
+	if err != nil {
+		log.Error("Error during deleting expired session objects in database %v", err)
+		_ = trans.Rollback()
+		return err
+	}
+	_, err = trans.Exec("delete from "+sessionservice.Table+" where lastActivity < ? or created < ?", idle, lifetime)
+	if err != nil {
+		log.Error("Error during deleting expired session objects in database %v", err)
+		_ = trans.Rollback()
+		return err
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during deleting expired session objects in database %v", err)
+		return err
+	}
+
+	return nil
+}
+
+// Удаление сессий, сохраненных до перехода на хэширование токенов: в них вместо хэша лежит сам токен
+func (sessionservice *SessionService) DeleteUnhashed() (err error) {
+	condition := " where char_length(token) <> 64 or token regexp '[^0-9a-f]'"
+	trans, err := sessionservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during deleting unhashed session objects in database %v", err)
+		return err
+	}
+
+	_, err = trans.Exec("delete from sessiongroups where session_token in (select token from " + sessionservice.Table + condition + ")")
+	if err != nil {
+		log.Error("Error during deleting unhashed session objects in database %v", err)
+		_ = trans.Rollback()
+		return err
+	}
+	_, err = trans.Exec("delete from " + sessionservice.Table + condition)
+	if err != nil {
+		log.Error("Error during deleting unhashed session objects in database %v", err)
+		_ = trans.Rollback()
+		return err
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during deleting unhashed session objects in database %v", err)
+		return err
+	}
+
+	return nil
+}
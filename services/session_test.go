@@ -1 +1,56 @@
 package services
+
+import (
+	"application/config"
+	"application/models"
+	"testing"
+	"time"
+)
+
+func TestGetSessionExpires(t *testing.T) {
+	var timeout = config.Configuration.Server.SessionTimeout
+	var lifetime = config.Configuration.Server.SessionLifetime
+	defer func() {
+		config.Configuration.Server.SessionTimeout = timeout
+		config.Configuration.Server.SessionLifetime = lifetime
+	}()
+
+	var now = time.Date(2016, 3, 1, 12, 0, 0, 0, time.UTC)
+	var cases = []struct {
+		timeout      time.Duration
+		lifetime     time.Duration
+		created      time.Time
+		lastactivity time.Time
+		expires      time.Time
+	}{
+		// Сессия завершается по неактивности
+		{time.Hour, 0, now.Add(-time.Hour), now, now.Add(time.Hour)},
+		{time.Hour, 24 * time.Hour, now.Add(-time.Hour), now, now.Add(time.Hour)},
+		// Активная сессия завершается по максимальному времени жизни
+		{time.Hour, 24 * time.Hour, now.Add(-24 * time.Hour), now, now},
+		{time.Hour, 24 * time.Hour, now.Add(-23*time.Hour - 30*time.Minute), now, now.Add(30 * time.Minute)},
+		{time.Hour, 0, now.Add(-SESSION_LIFETIME_DEFAULT), now, now},
+		{2 * SESSION_LIFETIME_DEFAULT, 0, now, now, now.Add(SESSION_LIFETIME_DEFAULT)},
+	}
+
+	for _, c := range cases {
+		config.Configuration.Server.SessionTimeout = c.timeout
+		config.Configuration.Server.SessionLifetime = c.lifetime
+		session := models.NewDtoSession("", 1, nil, c.lastactivity, "eng")
+		session.Created = c.created
+		if expires := GetSessionExpires(session); !expires.Equal(c.expires) {
+			t.Error("Session expiration of", c.created, c.lastactivity, "is not properly calculated", expires)
+		}
+	}
+}
+
+func TestHashSessionToken(t *testing.T) {
+	var hash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+	if value := HashSessionToken(""); value != hash {
+		t.Error("Session token hash is not properly calculated", value)
+	}
+	if value := HashSessionToken("token"); len(value) != 64 || value == "token" || value == hash {
+		t.Error("Session token should be stored as hash", value)
+	}
+}
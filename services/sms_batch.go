@@ -0,0 +1,59 @@
+package services
+
+import (
+	"application/models"
+)
+
+type SMSBatchRepository interface {
+	GetByOrder(order_id int64) (batches *[]models.DtoSMSBatch, err error)
+	Save(batch *models.DtoSMSBatch) (err error)
+	DeleteByOrder(order_id int64) (err error)
+}
+
+type SMSBatchService struct {
+	*Repository
+}
+
+func NewSMSBatchService(repository *Repository) *SMSBatchService {
+	repository.DbContext.AddTableWithName(models.DtoSMSBatch{}, repository.Table).SetKeys(false, "order_id", "offset")
+	return &SMSBatchService{Repository: repository}
+}
+
+func (smsbatchservice *SMSBatchService) GetByOrder(order_id int64) (batches *[]models.DtoSMSBatch, err error) {
+	batches = new([]models.DtoSMSBatch)
+	_, err = smsbatchservice.DbContext.Select(batches, "select * from "+smsbatchservice.Table+
+		" where order_id = ? order by send_at, `offset` desc", order_id)
+	if err != nil {
+		log.Error("Error during getting all sms batch object from database %v with value %v", err, order_id)
+		return nil, err
+	}
+
+	return batches, nil
+}
+
+func (smsbatchservice *SMSBatchService) Save(batch *models.DtoSMSBatch) (err error) {
+	_, err = smsbatchservice.DbContext.Exec("insert into "+smsbatchservice.Table+
+		" (order_id, `offset`, send_at, sent_at, status, recipients, `rows`, response, sent, failed, created, updated)"+
+		" values (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)"+
+		" on duplicate key update send_at = values(send_at), sent_at = values(sent_at), status = values(status),"+
+		" recipients = values(recipients), `rows` = values(`rows`), response = values(response), sent = values(sent),"+
+		" failed = values(failed), updated = values(updated)",
+		batch.Order_ID, batch.Offset, batch.Send_At, batch.Sent_At, batch.Status, batch.Recipients, batch.Rows, batch.Response,
+		batch.Sent, batch.Failed, batch.Created, batch.Updated)
+	if err != nil {
+		log.Error("Error during saving sms batch object in database %v with value %v, %v", err, batch.Order_ID, batch.Offset)
+		return err
+	}
+
+	return nil
+}
+
+func (smsbatchservice *SMSBatchService) DeleteByOrder(order_id int64) (err error) {
+	_, err = smsbatchservice.DbContext.Exec("delete from "+smsbatchservice.Table+" where order_id = ?", order_id)
+	if err != nil {
+		log.Error("Error during deleting sms batch object in database %v with value %v", err, order_id)
+		return err
+	}
+
+	return nil
+}
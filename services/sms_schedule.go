@@ -0,0 +1,64 @@
+package services
+
+import (
+	"application/models"
+)
+
+type SMSScheduleRepository interface {
+	Exists(order_id int64) (found bool, err error)
+	Get(order_id int64) (schedule *models.DtoSMSSchedule, err error)
+	Create(schedule *models.DtoSMSSchedule) (err error)
+	Update(schedule *models.DtoSMSSchedule) (err error)
+}
+
+type SMSScheduleService struct {
+	*Repository
+}
+
+func NewSMSScheduleService(repository *Repository) *SMSScheduleService {
+	repository.DbContext.AddTableWithName(models.DtoSMSSchedule{}, repository.Table).SetKeys(false, "order_id")
+	return &SMSScheduleService{Repository: repository}
+}
+
+func (smsscheduleservice *SMSScheduleService) Exists(order_id int64) (found bool, err error) {
+	var count int64
+	count, err = smsscheduleservice.DbContext.SelectInt("select count(*) from "+smsscheduleservice.Table+
+		" where order_id = ?", order_id)
+	if err != nil {
+		log.Error("Error during getting sms schedule object from database %v with value %v", err, order_id)
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+func (smsscheduleservice *SMSScheduleService) Get(order_id int64) (schedule *models.DtoSMSSchedule, err error) {
+	schedule = new(models.DtoSMSSchedule)
+	err = smsscheduleservice.DbContext.SelectOne(schedule, "select * from "+smsscheduleservice.Table+" where order_id = ?", order_id)
+	if err != nil {
+		log.Error("Error during getting sms schedule object from database %v with value %v", err, order_id)
+		return nil, err
+	}
+
+	return schedule, nil
+}
+
+func (smsscheduleservice *SMSScheduleService) Create(schedule *models.DtoSMSSchedule) (err error) {
+	err = smsscheduleservice.DbContext.Insert(schedule)
+	if err != nil {
+		log.Error("Error during creating sms schedule object in database %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (smsscheduleservice *SMSScheduleService) Update(schedule *models.DtoSMSSchedule) (err error) {
+	_, err = smsscheduleservice.DbContext.Update(schedule)
+	if err != nil {
+		log.Error("Error during updating sms schedule object in database %v with value %v", err, schedule.Order_ID)
+		return err
+	}
+
+	return nil
+}
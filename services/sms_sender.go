@@ -10,6 +10,7 @@ type SMSSenderRepository interface {
 	Exists(name string, supplier_id int64) (found bool, err error)
 	Belongs(dtotablecolumn *models.DtoTableColumn, unit_id int64, supplier_id int64) (found bool, err error)
 	Get(id int64) (smssender *models.DtoSMSSender, err error)
+	GetByName(name string) (smssenders *[]models.DtoSMSSender, err error)
 	GetMeta(user_id int64) (smssender *models.ApiMetaSMSSender, err error)
 	GetByUser(userid int64, filter string) (smssenders *[]models.ApiLongSMSSender, err error)
 	GetByUnit(unitid int64) (smssenders *[]models.ApiLongSMSSender, err error)
@@ -75,6 +76,19 @@ func (smssenderservice *SMSSenderService) Get(id int64) (smssender *models.DtoSM
 	return smssender, nil
 }
 
+// Активные отправители объединений с указанным именем
+func (smssenderservice *SMSSenderService) GetByName(name string) (smssenders *[]models.DtoSMSSender, err error) {
+	smssenders = new([]models.DtoSMSSender)
+	_, err = smssenderservice.DbContext.Select(smssenders, "select * from "+smssenderservice.Table+
+		" where name = ? and active = 1", name)
+	if err != nil {
+		log.Error("Error during getting all sms sender object from database %v with value %v", err, name)
+		return nil, err
+	}
+
+	return smssenders, nil
+}
+
 func (smssenderservice *SMSSenderService) GetMeta(user_id int64) (smssender *models.ApiMetaSMSSender, err error) {
 	smssender = new(models.ApiMetaSMSSender)
 	smssender.Total, err = smssenderservice.DbContext.SelectInt("select count(*) from "+smssenderservice.Table+
@@ -0,0 +1,140 @@
+package services
+
+import (
+	"application/models"
+	"strings"
+)
+
+const (
+	SUPPRESSION_CHUNK_SIZE = 1000
+)
+
+type SuppressionRepository interface {
+	Get(id int64) (suppression *models.DtoSuppression, err error)
+	GetByUnit(unit_id int64) (suppressions *[]models.DtoSuppression, err error)
+	FindSuppressed(unit_id int64, mobilephones []uint64) (reasons map[uint64]string, err error)
+	Create(suppression *models.DtoSuppression) (err error)
+	CreateAll(suppressions *[]models.DtoSuppression) (added int, err error)
+	Delete(id int64) (err error)
+}
+
+type SuppressionService struct {
+	*Repository
+}
+
+func NewSuppressionService(repository *Repository) *SuppressionService {
+	repository.DbContext.AddTableWithName(models.DtoSuppression{}, repository.Table).SetKeys(true, "id")
+	return &SuppressionService{Repository: repository}
+}
+
+func (suppressionservice *SuppressionService) Get(id int64) (suppression *models.DtoSuppression, err error) {
+	suppression = new(models.DtoSuppression)
+	err = suppressionservice.DbContext.SelectOne(suppression, "select * from "+suppressionservice.Table+" where id = ?", id)
+	if err != nil {
+		log.Error("Error during getting suppression object from database %v with value %v", err, id)
+		return nil, err
+	}
+
+	return suppression, nil
+}
+
+// Список запрета рассылок объединения, 0 - общий список
+func (suppressionservice *SuppressionService) GetByUnit(unit_id int64) (suppressions *[]models.DtoSuppression, err error) {
+	suppressions = new([]models.DtoSuppression)
+	_, err = suppressionservice.DbContext.Select(suppressions, "select * from "+suppressionservice.Table+
+		" where unit_id = ? order by mobilephone", unit_id)
+	if err != nil {
+		log.Error("Error during getting all suppression object from database %v with value %v", err, unit_id)
+		return nil, err
+	}
+
+	return suppressions, nil
+}
+
+// Номера из общего списка и списка объединения с причинами запрета, причина из списка объединения имеет приоритет
+func (suppressionservice *SuppressionService) FindSuppressed(unit_id int64, mobilephones []uint64) (reasons map[uint64]string, err error) {
+	reasons = make(map[uint64]string)
+	for begin := 0; begin < len(mobilephones); begin += SUPPRESSION_CHUNK_SIZE {
+		end := begin + SUPPRESSION_CHUNK_SIZE
+		if end > len(mobilephones) {
+			end = len(mobilephones)
+		}
+		marks := []string{}
+		args := []interface{}{unit_id}
+		for _, mobilephone := range mobilephones[begin:end] {
+			marks = append(marks, "?")
+			args = append(args, mobilephone)
+		}
+		suppressions := new([]models.DtoSuppression)
+		_, err = suppressionservice.DbContext.Select(suppressions, "select * from "+suppressionservice.Table+
+			" where unit_id in (0, ?) and mobilephone in ("+strings.Join(marks, ", ")+") order by unit_id", args...)
+		if err != nil {
+			log.Error("Error during finding suppression object in database %v with value %v", err, unit_id)
+			return nil, err
+		}
+		for _, suppression := range *suppressions {
+			reasons[suppression.MobilePhone] = suppression.Reason
+		}
+	}
+
+	return reasons, nil
+}
+
+// Добавление номера или изменение причины запрета уже имеющегося в списке номера
+func (suppressionservice *SuppressionService) Create(suppression *models.DtoSuppression) (err error) {
+	result, err := suppressionservice.DbContext.Exec("insert into "+suppressionservice.Table+
+		" (unit_id, mobilephone, reason, source, created) values (?, ?, ?, ?, ?)"+
+		" on duplicate key update id = last_insert_id(id), reason = values(reason)",
+		suppression.Unit_ID, suppression.MobilePhone, suppression.Reason, suppression.Source, suppression.Created)
+	if err != nil {
+		log.Error("Error during creating suppression object in database %v with value %v", err, suppression.MobilePhone)
+		return err
+	}
+	suppression.ID, err = result.LastInsertId()
+	if err != nil {
+		log.Error("Error during creating suppression object in database %v with value %v", err, suppression.MobilePhone)
+		return err
+	}
+
+	return nil
+}
+
+// Добавление номеров без повторов, уже имеющиеся в списке номера не изменяются
+func (suppressionservice *SuppressionService) CreateAll(suppressions *[]models.DtoSuppression) (added int, err error) {
+	for begin := 0; begin < len(*suppressions); begin += SUPPRESSION_CHUNK_SIZE {
+		end := begin + SUPPRESSION_CHUNK_SIZE
+		if end > len(*suppressions) {
+			end = len(*suppressions)
+		}
+		values := []string{}
+		args := []interface{}{}
+		for _, suppression := range (*suppressions)[begin:end] {
+			values = append(values, "(?, ?, ?, ?, ?)")
+			args = append(args, suppression.Unit_ID, suppression.MobilePhone, suppression.Reason, suppression.Source, suppression.Created)
+		}
+		result, err := suppressionservice.DbContext.Exec("insert ignore into "+suppressionservice.Table+
+			" (unit_id, mobilephone, reason, source, created) values "+strings.Join(values, ", "), args...)
+		if err != nil {
+			log.Error("Error during creating suppression object in database %v with value %v", err, (*suppressions)[begin].Unit_ID)
+			return added, err
+		}
+		count, err := result.RowsAffected()
+		if err != nil {
+			log.Error("Error during creating suppression object in database %v with value %v", err, (*suppressions)[begin].Unit_ID)
+			return added, err
+		}
+		added += int(count)
+	}
+
+	return added, nil
+}
+
+func (suppressionservice *SuppressionService) Delete(id int64) (err error) {
+	_, err = suppressionservice.DbContext.Exec("delete from "+suppressionservice.Table+" where id = ?", id)
+	if err != nil {
+		log.Error("Error during deleting suppression object in database %v with value %v", err, id)
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,100 @@
+package services
+
+import (
+	"application/models"
+	"strings"
+)
+
+const (
+	TABLE_DEDUP_CHUNK_SIZE = 1000
+)
+
+type TableDedupRepository interface {
+	Get(id int64) (tablededup *models.DtoTableDedup, err error)
+	Create(tablededup *models.DtoTableDedup) (err error)
+	Update(tablededup *models.DtoTableDedup) (err error)
+	GetRows(dedup_id int64, groups int64) (rows *[]models.DtoTableDedupRow, err error)
+	CreateRows(rows *[]models.DtoTableDedupRow) (err error)
+}
+
+// Поиск дубликатов в пользовательских таблицах. Строки групп дубликатов хранятся в таблице table_dedup_rows
+type TableDedupService struct {
+	*Repository
+}
+
+func NewTableDedupService(repository *Repository) *TableDedupService {
+	repository.DbContext.AddTableWithName(models.DtoTableDedup{}, repository.Table).SetKeys(true, "id")
+	return &TableDedupService{Repository: repository}
+}
+
+func (tablededupservice *TableDedupService) Get(id int64) (tablededup *models.DtoTableDedup, err error) {
+	tablededup = new(models.DtoTableDedup)
+	err = tablededupservice.DbContext.SelectOne(tablededup, "select * from "+tablededupservice.Table+" where id = ?", id)
+	if err != nil {
+		log.Error("Error during getting table dedup object from database %v with value %v", err, id)
+		return nil, err
+	}
+
+	return tablededup, nil
+}
+
+func (tablededupservice *TableDedupService) Create(tablededup *models.DtoTableDedup) (err error) {
+	err = tablededupservice.DbContext.Insert(tablededup)
+	if err != nil {
+		log.Error("Error during creating table dedup object in database %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (tablededupservice *TableDedupService) Update(tablededup *models.DtoTableDedup) (err error) {
+	_, err = tablededupservice.DbContext.Update(tablededup)
+	if err != nil {
+		log.Error("Error during updating table dedup object in database %v with value %v", err, tablededup.ID)
+		return err
+	}
+
+	return nil
+}
+
+// Строки первых групп дубликатов, 0 - всех групп
+func (tablededupservice *TableDedupService) GetRows(dedup_id int64, groups int64) (rows *[]models.DtoTableDedupRow, err error) {
+	rows = new([]models.DtoTableDedupRow)
+	query := "select * from table_dedup_rows where table_dedup_id = ?"
+	args := []interface{}{dedup_id}
+	if groups > 0 {
+		query += " and group_id <= ?"
+		args = append(args, groups)
+	}
+	_, err = tablededupservice.DbContext.Select(rows, query+" order by group_id, position", args...)
+	if err != nil {
+		log.Error("Error during getting table dedup row object from database %v with value %v", err, dedup_id)
+		return nil, err
+	}
+
+	return rows, nil
+}
+
+func (tablededupservice *TableDedupService) CreateRows(rows *[]models.DtoTableDedupRow) (err error) {
+	for begin := 0; begin < len(*rows); begin += TABLE_DEDUP_CHUNK_SIZE {
+		end := begin + TABLE_DEDUP_CHUNK_SIZE
+		if end > len(*rows) {
+			end = len(*rows)
+		}
+		marks := []string{}
+		args := []interface{}{}
+		for _, row := range (*rows)[begin:end] {
+			marks = append(marks, "(?, ?, ?, ?)")
+			args = append(args, row.Table_Dedup_ID, row.Group_ID, row.Table_Row_ID, row.Position)
+		}
+		_, err = tablededupservice.DbContext.Exec("insert into table_dedup_rows (table_dedup_id, group_id, table_row_id, position) values "+
+			strings.Join(marks, ", "), args...)
+		if err != nil {
+			log.Error("Error during creating table dedup row object in database %v", err)
+			return err
+		}
+	}
+
+	return nil
+}
@@ -22,6 +22,8 @@ type TableRowRepository interface {
 	Delete(tablerow *models.DtoTableRow, inTrans bool) (err error)
 	GetValidation(offset int64, count int64, tableid int64, tablecolumns *[]models.DtoTableColumn) (dtotablerows *[]models.DtoTableRow, err error)
 	SaveValidation(tablerows *[]models.DtoTableRow, tablecolumns *[]models.DtoTableColumn) (err error)
+	GetExport(offset int64, count int64, tableid int64, tablecolumns *[]models.DtoTableColumn, rowtype string) (dtotablerows *[]models.DtoTableRow, err error)
+	GetExportCount(tableid int64, tablecolumns *[]models.DtoTableColumn, rowtype string) (count int64, err error)
 }
 
 type TableRowService struct {
@@ -145,6 +147,56 @@ func (tablerowservice *TableRowService) GetValidation(offset int64, count int64,
 	return dtotablerows, nil
 }
 
+// Условие отбора выгружаемых строк по признаку правильности ячеек
+func GetExportCondition(tablecolumns *[]models.DtoTableColumn, rowtype string) (condition string) {
+	conditions := []string{}
+	for _, tablecolumn := range *tablecolumns {
+		switch rowtype {
+		case models.EXPORT_DATA_VALID:
+			conditions = append(conditions, fmt.Sprintf("valid%v = 1", tablecolumn.FieldNum))
+		case models.EXPORT_DATA_INVALID:
+			conditions = append(conditions, fmt.Sprintf("valid%v = 0", tablecolumn.FieldNum))
+		}
+	}
+	if len(conditions) == 0 {
+		return ""
+	}
+	if rowtype == models.EXPORT_DATA_VALID {
+		return " and (" + strings.Join(conditions, " and ") + ")"
+	}
+
+	return " and (" + strings.Join(conditions, " or ") + ")"
+}
+
+func (tablerowservice *TableRowService) GetExport(offset int64, count int64, tableid int64,
+	tablecolumns *[]models.DtoTableColumn, rowtype string) (dtotablerows *[]models.DtoTableRow, err error) {
+	dtotablerows = new([]models.DtoTableRow)
+	query := "id, position"
+	for _, tablecolumn := range *tablecolumns {
+		query += fmt.Sprintf(", field%v", tablecolumn.FieldNum)
+	}
+	_, err = tablerowservice.DbContext.Select(dtotablerows,
+		"select "+query+" from "+tablerowservice.Table+" where customer_table_id = ? and active = 1 and position >= ?"+
+			GetExportCondition(tablecolumns, rowtype)+" order by position asc limit "+fmt.Sprintf("%v", count), tableid, offset)
+	if err != nil {
+		log.Error("Error during getting export table row object from database %v with value %v", err, tableid)
+		return nil, err
+	}
+
+	return dtotablerows, nil
+}
+
+func (tablerowservice *TableRowService) GetExportCount(tableid int64, tablecolumns *[]models.DtoTableColumn, rowtype string) (count int64, err error) {
+	count, err = tablerowservice.DbContext.SelectInt("select count(*) from "+tablerowservice.Table+
+		" where customer_table_id = ? and active = 1"+GetExportCondition(tablecolumns, rowtype), tableid)
+	if err != nil {
+		log.Error("Error during getting export table row object from database %v with value %v", err, tableid)
+		return 0, err
+	}
+
+	return count, nil
+}
+
 func (tablerowservice *TableRowService) SaveValidation(tablerows *[]models.DtoTableRow, tablecolumns *[]models.DtoTableColumn) (err error) {
 	if len(*tablerows) == 0 || len(*tablecolumns) == 0 {
 		return nil
@@ -0,0 +1,194 @@
+package services
+
+import (
+	"application/models"
+	"time"
+)
+
+type TwoFactorRepository interface {
+	Exists(userid int64) (found bool, err error)
+	Get(userid int64) (twofactor *models.DtoTwoFactor, err error)
+	Save(twofactor *models.DtoTwoFactor) (err error)
+	Delete(userid int64) (err error)
+	UseCounter(userid int64, counter int64) (used bool, err error)
+	CountRecoveryCodes(userid int64) (count int64, err error)
+	SaveRecoveryCodes(userid int64, hashes []string) (err error)
+	UseRecoveryCode(userid int64, hash string) (used bool, err error)
+	IsRequiredByUnit(unitid int64) (required bool, err error)
+	SetRequiredByUnit(unitid int64, required bool) (err error)
+}
+
+type TwoFactorService struct {
+	*Repository
+}
+
+func NewTwoFactorService(repository *Repository) *TwoFactorService {
+	repository.DbContext.AddTableWithName(models.DtoTwoFactor{}, repository.Table).SetKeys(false, "user_id")
+	return &TwoFactorService{Repository: repository}
+}
+
+func (twofactorservice *TwoFactorService) Exists(userid int64) (found bool, err error) {
+	count, err := twofactorservice.DbContext.SelectInt("select count(*) from "+twofactorservice.Table+" where user_id = ?", userid)
+	if err != nil {
+		log.Error("Error during getting two factor object from database %v with value %v", err, userid)
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+func (twofactorservice *TwoFactorService) Get(userid int64) (twofactor *models.DtoTwoFactor, err error) {
+	twofactor = new(models.DtoTwoFactor)
+	err = twofactorservice.DbContext.SelectOne(twofactor, "select * from "+twofactorservice.Table+" where user_id = ?", userid)
+	if err != nil {
+		log.Error("Error during getting two factor object from database %v with value %v", err, userid)
+		return nil, err
+	}
+
+	return twofactor, nil
+}
+
+func (twofactorservice *TwoFactorService) Save(twofactor *models.DtoTwoFactor) (err error) {
+	found, err := twofactorservice.Exists(twofactor.User_ID)
+	if err != nil {
+		return err
+	}
+	if found {
+		_, err = twofactorservice.DbContext.Update(twofactor)
+	} else {
+		err = twofactorservice.DbContext.Insert(twofactor)
+	}
+	if err != nil {
+		log.Error("Error during saving two factor object in database %v with value %v", err, twofactor.User_ID)
+		return err
+	}
+
+	return nil
+}
+
+// Отключение второго фактора вместе с кодами восстановления
+func (twofactorservice *TwoFactorService) Delete(userid int64) (err error) {
+	trans, err := twofactorservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during deleting two factor object in database %v with value %v", err, userid)
+		return err
+	}
+
+	_, err = trans.Exec("delete from two_factor_recoveries where user_id = ?", userid)
+	if err != nil {
+		log.Error("Error during deleting two factor object in database %v with value %v", err, userid)
+		_ = trans.Rollback()
+		return err
+	}
+	_, err = trans.Exec("delete from "+twofactorservice.Table+" where user_id = ?", userid)
+	if err != nil {
+		log.Error("Error during deleting two factor object in database %v with value %v", err, userid)
+		_ = trans.Rollback()
+		return err
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during deleting two factor object in database %v with value %v", err, userid)
+		return err
+	}
+
+	return nil
+}
+
+// Фиксация шага принятого одноразового пароля. Шаг не старше последнего принятого не фиксируется,
+// что исключает повторный вход по тому же паролю с параллельных запросов
+func (twofactorservice *TwoFactorService) UseCounter(userid int64, counter int64) (used bool, err error) {
+	result, err := twofactorservice.DbContext.Exec("update "+twofactorservice.Table+" set counter = ? where user_id = ? and counter < ?",
+		counter, userid, counter)
+	if err != nil {
+		log.Error("Error during updating two factor object in database %v with value %v", err, userid)
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		log.Error("Error during updating two factor object in database %v with value %v", err, userid)
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+func (twofactorservice *TwoFactorService) CountRecoveryCodes(userid int64) (count int64, err error) {
+	count, err = twofactorservice.DbContext.SelectInt("select count(*) from two_factor_recoveries where user_id = ?", userid)
+	if err != nil {
+		log.Error("Error during getting two factor recovery object from database %v with value %v", err, userid)
+		return 0, err
+	}
+
+	return count, nil
+}
+
+// Замена всех кодов восстановления пользователя новыми
+func (twofactorservice *TwoFactorService) SaveRecoveryCodes(userid int64, hashes []string) (err error) {
+	trans, err := twofactorservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during saving two factor recovery object in database %v with value %v", err, userid)
+		return err
+	}
+
+	_, err = trans.Exec("delete from two_factor_recoveries where user_id = ?", userid)
+	if err != nil {
+		log.Error("Error during saving two factor recovery object in database %v with value %v", err, userid)
+		_ = trans.Rollback()
+		return err
+	}
+	for _, hash := range hashes {
+		_, err = trans.Exec("insert into two_factor_recoveries (user_id, hash, created) values (?, ?, ?)", userid, hash, time.Now())
+		if err != nil {
+			log.Error("Error during saving two factor recovery object in database %v with value %v", err, userid)
+			_ = trans.Rollback()
+			return err
+		}
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during saving two factor recovery object in database %v with value %v", err, userid)
+		return err
+	}
+
+	return nil
+}
+
+// Код восстановления действует один раз и удаляется при использовании
+func (twofactorservice *TwoFactorService) UseRecoveryCode(userid int64, hash string) (used bool, err error) {
+	result, err := twofactorservice.DbContext.Exec("delete from two_factor_recoveries where user_id = ? and hash = ?", userid, hash)
+	if err != nil {
+		log.Error("Error during deleting two factor recovery object in database %v with value %v", err, userid)
+		return false, err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		log.Error("Error during deleting two factor recovery object in database %v with value %v", err, userid)
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+func (twofactorservice *TwoFactorService) IsRequiredByUnit(unitid int64) (required bool, err error) {
+	count, err := twofactorservice.DbContext.SelectInt("select count(*) from two_factor_policies where unit_id = ? and required = 1", unitid)
+	if err != nil {
+		log.Error("Error during getting two factor policy object from database %v with value %v", err, unitid)
+		return false, err
+	}
+
+	return count != 0, nil
+}
+
+func (twofactorservice *TwoFactorService) SetRequiredByUnit(unitid int64, required bool) (err error) {
+	_, err = twofactorservice.DbContext.Exec("insert into two_factor_policies (unit_id, required) values (?, ?)"+
+		" on duplicate key update required = values(required)", unitid, required)
+	if err != nil {
+		log.Error("Error during saving two factor policy object in database %v with value %v", err, unitid)
+		return err
+	}
+
+	return nil
+}
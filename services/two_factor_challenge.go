@@ -0,0 +1,75 @@
+package services
+
+import (
+	"application/models"
+	"time"
+)
+
+type TwoFactorChallengeRepository interface {
+	Get(token string) (challenge *models.DtoTwoFactorChallenge, err error)
+	Create(challenge *models.DtoTwoFactorChallenge) (err error)
+	Update(challenge *models.DtoTwoFactorChallenge) (err error)
+	Delete(token string) (err error)
+	DeleteExpired(before time.Time) (err error)
+}
+
+type TwoFactorChallengeService struct {
+	*Repository
+}
+
+func NewTwoFactorChallengeService(repository *Repository) *TwoFactorChallengeService {
+	repository.DbContext.AddTableWithName(models.DtoTwoFactorChallenge{}, repository.Table).SetKeys(false, "token")
+	return &TwoFactorChallengeService{Repository: repository}
+}
+
+func (twofactorchallengeservice *TwoFactorChallengeService) Get(token string) (challenge *models.DtoTwoFactorChallenge, err error) {
+	challenge = new(models.DtoTwoFactorChallenge)
+	err = twofactorchallengeservice.DbContext.SelectOne(challenge, "select * from "+twofactorchallengeservice.Table+
+		" where token = ?", token)
+	if err != nil {
+		log.Error("Error during getting two factor challenge object from database %v with value %v", err, token)
+		return nil, err
+	}
+
+	return challenge, nil
+}
+
+func (twofactorchallengeservice *TwoFactorChallengeService) Create(challenge *models.DtoTwoFactorChallenge) (err error) {
+	err = twofactorchallengeservice.DbContext.Insert(challenge)
+	if err != nil {
+		log.Error("Error during creating two factor challenge object in database %v with value %v", err, challenge.User_ID)
+		return err
+	}
+
+	return nil
+}
+
+func (twofactorchallengeservice *TwoFactorChallengeService) Update(challenge *models.DtoTwoFactorChallenge) (err error) {
+	_, err = twofactorchallengeservice.DbContext.Update(challenge)
+	if err != nil {
+		log.Error("Error during updating two factor challenge object in database %v with value %v", err, challenge.User_ID)
+		return err
+	}
+
+	return nil
+}
+
+func (twofactorchallengeservice *TwoFactorChallengeService) Delete(token string) (err error) {
+	_, err = twofactorchallengeservice.DbContext.Exec("delete from "+twofactorchallengeservice.Table+" where token = ?", token)
+	if err != nil {
+		log.Error("Error during deleting two factor challenge object in database %v with value %v", err, token)
+		return err
+	}
+
+	return nil
+}
+
+func (twofactorchallengeservice *TwoFactorChallengeService) DeleteExpired(before time.Time) (err error) {
+	_, err = twofactorchallengeservice.DbContext.Exec("delete from "+twofactorchallengeservice.Table+" where expires < ?", before)
+	if err != nil {
+		log.Error("Error during deleting two factor challenge object in database %v with value %v", err, before)
+		return err
+	}
+
+	return nil
+}
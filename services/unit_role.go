@@ -0,0 +1,234 @@
+package services
+
+import (
+	"application/models"
+	"github.com/coopernurse/gorp"
+)
+
+type UnitRoleRepository interface {
+	Get(id int64) (role *models.DtoUnitRole, err error)
+	GetByUnit(unitid int64) (roles *[]models.DtoUnitRole, err error)
+	Create(role *models.DtoUnitRole) (err error)
+	Update(role *models.DtoUnitRole) (err error)
+	Delete(id int64) (err error)
+	GetByUser(userid int64) (roleids []int64, err error)
+	SetByUser(userid int64, roleids []int64) (err error)
+	GetPermissionsByUser(userid int64) (assigned bool, permissions []string, err error)
+}
+
+type UnitRoleService struct {
+	*Repository
+}
+
+func NewUnitRoleService(repository *Repository) *UnitRoleService {
+	repository.DbContext.AddTableWithName(models.DtoUnitRole{}, repository.Table).SetKeys(true, "id")
+	return &UnitRoleService{Repository: repository}
+}
+
+func (unitroleservice *UnitRoleService) Get(id int64) (role *models.DtoUnitRole, err error) {
+	role = new(models.DtoUnitRole)
+	err = unitroleservice.DbContext.SelectOne(role, "select * from "+unitroleservice.Table+" where id = ?", id)
+	if err != nil {
+		log.Error("Error during getting unit role object from database %v with value %v", err, id)
+		return nil, err
+	}
+
+	return unitroleservice.getPermissions(role)
+}
+
+func (unitroleservice *UnitRoleService) GetByUnit(unitid int64) (roles *[]models.DtoUnitRole, err error) {
+	roles = new([]models.DtoUnitRole)
+	_, err = unitroleservice.DbContext.Select(roles, "select * from "+unitroleservice.Table+" where unit_id = ? order by name", unitid)
+	if err != nil {
+		log.Error("Error during getting unit role objects from database %v with value %v", err, unitid)
+		return nil, err
+	}
+	for i := range *roles {
+		_, err = unitroleservice.getPermissions(&(*roles)[i])
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return roles, nil
+}
+
+func (unitroleservice *UnitRoleService) Create(role *models.DtoUnitRole) (err error) {
+	trans, err := unitroleservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during creating unit role object in database %v", err)
+		return err
+	}
+
+	err = trans.Insert(role)
+	if err != nil {
+		log.Error("Error during creating unit role object in database %v", err)
+		_ = trans.Rollback()
+		return err
+	}
+	err = unitroleservice.setPermissions(role, trans)
+	if err != nil {
+		_ = trans.Rollback()
+		return err
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during creating unit role object in database %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (unitroleservice *UnitRoleService) Update(role *models.DtoUnitRole) (err error) {
+	trans, err := unitroleservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during updating unit role object in database %v with value %v", err, role.ID)
+		return err
+	}
+
+	_, err = trans.Update(role)
+	if err != nil {
+		log.Error("Error during updating unit role object in database %v with value %v", err, role.ID)
+		_ = trans.Rollback()
+		return err
+	}
+	err = unitroleservice.setPermissions(role, trans)
+	if err != nil {
+		_ = trans.Rollback()
+		return err
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during updating unit role object in database %v with value %v", err, role.ID)
+		return err
+	}
+
+	return nil
+}
+
+// Удаление роли вместе с ее разрешениями и назначениями пользователям
+func (unitroleservice *UnitRoleService) Delete(id int64) (err error) {
+	trans, err := unitroleservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during deleting unit role object in database %v with value %v", err, id)
+		return err
+	}
+
+	for _, statement := range []string{
+		"delete from user_unit_roles where role_id = ?",
+		"delete from unit_role_permissions where role_id = ?",
+		"delete from " + unitroleservice.Table + " where id = ?",
+	} {
+		_, err = trans.Exec(statement, id)
+		if err != nil {
+			log.Error("Error during deleting unit role object in database %v with value %v", err, id)
+			_ = trans.Rollback()
+			return err
+		}
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during deleting unit role object in database %v with value %v", err, id)
+		return err
+	}
+
+	return nil
+}
+
+func (unitroleservice *UnitRoleService) GetByUser(userid int64) (roleids []int64, err error) {
+	_, err = unitroleservice.DbContext.Select(&roleids, "select role_id from user_unit_roles where user_id = ? order by role_id", userid)
+	if err != nil {
+		log.Error("Error during getting unit role objects for user from database %v with value %v", err, userid)
+		return nil, err
+	}
+	if roleids == nil {
+		roleids = []int64{}
+	}
+
+	return roleids, nil
+}
+
+func (unitroleservice *UnitRoleService) SetByUser(userid int64, roleids []int64) (err error) {
+	trans, err := unitroleservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during setting unit role objects for user in database %v with value %v", err, userid)
+		return err
+	}
+
+	_, err = trans.Exec("delete from user_unit_roles where user_id = ?", userid)
+	if err != nil {
+		log.Error("Error during setting unit role objects for user in database %v with value %v", err, userid)
+		_ = trans.Rollback()
+		return err
+	}
+	for _, roleid := range roleids {
+		_, err = trans.Exec("insert into user_unit_roles (user_id, role_id) values (?, ?)", userid, roleid)
+		if err != nil {
+			log.Error("Error during setting unit role objects for user in database %v with value %v", err, userid)
+			_ = trans.Rollback()
+			return err
+		}
+	}
+
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during setting unit role objects for user in database %v with value %v", err, userid)
+		return err
+	}
+
+	return nil
+}
+
+// Разрешения всех ролей объединения пользователя. Признак assigned сообщает, назначена ли пользователю хотя бы одна роль
+func (unitroleservice *UnitRoleService) GetPermissionsByUser(userid int64) (assigned bool, permissions []string, err error) {
+	count, err := unitroleservice.DbContext.SelectInt("select count(*) from user_unit_roles where user_id = ?", userid)
+	if err != nil {
+		log.Error("Error during getting unit role objects for user from database %v with value %v", err, userid)
+		return false, nil, err
+	}
+	if count == 0 {
+		return false, nil, nil
+	}
+	_, err = unitroleservice.DbContext.Select(&permissions, "select distinct p.permission from user_unit_roles u"+
+		" inner join unit_role_permissions p on p.role_id = u.role_id where u.user_id = ?", userid)
+	if err != nil {
+		log.Error("Error during getting unit role permissions for user from database %v with value %v", err, userid)
+		return false, nil, err
+	}
+
+	return true, permissions, nil
+}
+
+func (unitroleservice *UnitRoleService) getPermissions(role *models.DtoUnitRole) (*models.DtoUnitRole, error) {
+	permissions := []string{}
+	_, err := unitroleservice.DbContext.Select(&permissions, "select permission from unit_role_permissions where role_id = ?"+
+		" order by permission", role.ID)
+	if err != nil {
+		log.Error("Error during getting unit role permissions from database %v with value %v", err, role.ID)
+		return nil, err
+	}
+	role.Permissions = permissions
+
+	return role, nil
+}
+
+func (unitroleservice *UnitRoleService) setPermissions(role *models.DtoUnitRole, trans *gorp.Transaction) (err error) {
+	_, err = trans.Exec("delete from unit_role_permissions where role_id = ?", role.ID)
+	if err != nil {
+		log.Error("Error during setting unit role permissions in database %v with value %v", err, role.ID)
+		return err
+	}
+	for _, permission := range role.Permissions {
+		_, err = trans.Exec("insert into unit_role_permissions (role_id, permission) values (?, ?)", role.ID, permission)
+		if err != nil {
+			log.Error("Error during setting unit role permissions in database %v with value %v", err, role.ID)
+			return err
+		}
+	}
+
+	return nil
+}
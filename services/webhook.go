@@ -0,0 +1,89 @@
+package services
+
+import (
+	"application/models"
+)
+
+type WebhookRepository interface {
+	Get(id int64) (webhook *models.DtoWebhook, err error)
+	GetByUnit(unit_id int64) (webhooks *[]models.DtoWebhook, err error)
+	Create(webhook *models.DtoWebhook) (err error)
+	Update(webhook *models.DtoWebhook) (err error)
+	Delete(id int64) (err error)
+}
+
+type WebhookService struct {
+	*Repository
+}
+
+func NewWebhookService(repository *Repository) *WebhookService {
+	repository.DbContext.AddTableWithName(models.DtoWebhook{}, repository.Table).SetKeys(true, "id")
+	return &WebhookService{Repository: repository}
+}
+
+func (webhookservice *WebhookService) Get(id int64) (webhook *models.DtoWebhook, err error) {
+	webhook = new(models.DtoWebhook)
+	err = webhookservice.DbContext.SelectOne(webhook, "select * from "+webhookservice.Table+" where id = ?", id)
+	if err != nil {
+		log.Error("Error during getting webhook object from database %v with value %v", err, id)
+		return nil, err
+	}
+
+	return webhook, nil
+}
+
+func (webhookservice *WebhookService) GetByUnit(unit_id int64) (webhooks *[]models.DtoWebhook, err error) {
+	webhooks = new([]models.DtoWebhook)
+	_, err = webhookservice.DbContext.Select(webhooks, "select * from "+webhookservice.Table+" where unit_id = ? order by id", unit_id)
+	if err != nil {
+		log.Error("Error during getting all webhook object from database %v with value %v", err, unit_id)
+		return nil, err
+	}
+
+	return webhooks, nil
+}
+
+func (webhookservice *WebhookService) Create(webhook *models.DtoWebhook) (err error) {
+	err = webhookservice.DbContext.Insert(webhook)
+	if err != nil {
+		log.Error("Error during creating webhook object in database %v", err)
+		return err
+	}
+
+	return nil
+}
+
+func (webhookservice *WebhookService) Update(webhook *models.DtoWebhook) (err error) {
+	_, err = webhookservice.DbContext.Update(webhook)
+	if err != nil {
+		log.Error("Error during updating webhook object in database %v with value %v", err, webhook.ID)
+		return err
+	}
+
+	return nil
+}
+
+// Удаление адреса уведомлений вместе с журналом доставки
+func (webhookservice *WebhookService) Delete(id int64) (err error) {
+	trans, err := webhookservice.DbContext.Begin()
+	if err != nil {
+		log.Error("Error during deleting webhook object in database %v with value %v", err, id)
+		return err
+	}
+	_, err = trans.Exec("delete from webhook_deliveries where webhook_id = ?", id)
+	if err == nil {
+		_, err = trans.Exec("delete from "+webhookservice.Table+" where id = ?", id)
+	}
+	if err != nil {
+		_ = trans.Rollback()
+		log.Error("Error during deleting webhook object in database %v with value %v", err, id)
+		return err
+	}
+	err = trans.Commit()
+	if err != nil {
+		log.Error("Error during deleting webhook object in database %v with value %v", err, id)
+		return err
+	}
+
+	return nil
+}
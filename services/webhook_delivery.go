@@ -0,0 +1,150 @@
+package services
+
+import (
+	"application/models"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"github.com/coopernurse/gorp"
+	"time"
+)
+
+const (
+	WEBHOOK_DELIVERY_LOG_LIMIT    = 100
+	WEBHOOK_DELIVERY_LEASE_LENGTH = 16
+)
+
+type WebhookDeliveryRepository interface {
+	GetByWebhook(webhook_id int64) (deliveries *[]models.DtoWebhookDelivery, err error)
+	Claim(limit int, lease time.Duration) (deliveries *[]models.DtoWebhookDelivery, err error)
+	Enqueue(unit_id int64, event string, data interface{}, trans *gorp.Transaction) (err error)
+	EnqueueByOrder(order_id int64, event string, data interface{}, trans *gorp.Transaction) (err error)
+	EnqueueByCompany(company_id int64, event string, data interface{}, trans *gorp.Transaction) (err error)
+	Update(delivery *models.DtoWebhookDelivery) (err error)
+}
+
+type WebhookDeliveryService struct {
+	*Repository
+}
+
+func NewWebhookDeliveryService(repository *Repository) *WebhookDeliveryService {
+	repository.DbContext.AddTableWithName(models.DtoWebhookDelivery{}, repository.Table).SetKeys(true, "id")
+	return &WebhookDeliveryService{Repository: repository}
+}
+
+// Журнал доставки уведомлений, последние уведомления первыми
+func (webhookdeliveryservice *WebhookDeliveryService) GetByWebhook(webhook_id int64) (deliveries *[]models.DtoWebhookDelivery, err error) {
+	deliveries = new([]models.DtoWebhookDelivery)
+	_, err = webhookdeliveryservice.DbContext.Select(deliveries, "select * from "+webhookdeliveryservice.Table+
+		" where webhook_id = ? order by id desc limit ?", webhook_id, WEBHOOK_DELIVERY_LOG_LIMIT)
+	if err != nil {
+		log.Error("Error during getting all webhook delivery object from database %v with value %v", err, webhook_id)
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Захват обработчиком уведомлений, время очередной попытки доставки которых наступило. Захватываются уведомления
+// без аренды и уведомления, аренда которых истекла, поэтому одно уведомление не отправляется несколькими обработчиками
+func (webhookdeliveryservice *WebhookDeliveryService) Claim(limit int,
+	lease time.Duration) (deliveries *[]models.DtoWebhookDelivery, err error) {
+	tokenRaw := make([]byte, WEBHOOK_DELIVERY_LEASE_LENGTH)
+	if _, err = rand.Read(tokenRaw); err != nil {
+		log.Error("Error during lease token generation %v", err)
+		return nil, err
+	}
+	token := hex.EncodeToString(tokenRaw)
+
+	now := time.Now()
+	_, err = webhookdeliveryservice.DbContext.Exec("update "+webhookdeliveryservice.Table+" set lease = ?, lease_till = ?"+
+		" where status = ? and next_attempt <= ? and (lease = '' or lease_till < ?) order by next_attempt, id limit ?",
+		token, now.Add(lease), models.WEBHOOK_DELIVERY_STATUS_PENDING, now, now, limit)
+	if err != nil {
+		log.Error("Error during claiming webhook delivery object in database %v", err)
+		return nil, err
+	}
+
+	deliveries = new([]models.DtoWebhookDelivery)
+	_, err = webhookdeliveryservice.DbContext.Select(deliveries, "select * from "+webhookdeliveryservice.Table+
+		" where lease = ? order by next_attempt, id", token)
+	if err != nil {
+		log.Error("Error during getting pending webhook delivery object from database %v with value %v", err, token)
+		return nil, err
+	}
+
+	return deliveries, nil
+}
+
+// Постановка уведомления о событии в очередь для всех активных адресов объединения, подписанных на событие.
+// Объединение определяется условием from по идентификатору объекта события
+func (webhookdeliveryservice *WebhookDeliveryService) enqueue(event string, data interface{}, from string, id int64,
+	trans *gorp.Transaction) (err error) {
+	now := time.Now()
+	payload, err := json.Marshal(models.NewApiWebhookEvent(event, now, data))
+	if err != nil {
+		log.Error("Error during creating webhook delivery object in database %v with value %v", err, event)
+		return err
+	}
+
+	query := "insert into " + webhookdeliveryservice.Table +
+		" (webhook_id, event, payload, status, attempts, response_code, error, next_attempt, lease, lease_till, created, delivered)" +
+		" select w.id, ?, ?, ?, 0, 0, '', ?, '', ?, ?, ? from " + from +
+		" and w.active = 1 and (w.events = '' or find_in_set(?, w.events))"
+	args := []interface{}{event, string(payload), models.WEBHOOK_DELIVERY_STATUS_PENDING, now, time.Time{}, now, time.Time{}, id, event}
+	if trans != nil {
+		_, err = trans.Exec(query, args...)
+	} else {
+		_, err = webhookdeliveryservice.DbContext.Exec(query, args...)
+	}
+	if err != nil {
+		log.Error("Error during creating webhook delivery object in database %v with value %v, %v", err, event, id)
+		return err
+	}
+
+	return nil
+}
+
+func (webhookdeliveryservice *WebhookDeliveryService) Enqueue(unit_id int64, event string, data interface{},
+	trans *gorp.Transaction) (err error) {
+	return webhookdeliveryservice.enqueue(event, data, "webhooks w where w.unit_id = ?", unit_id, trans)
+}
+
+func (webhookdeliveryservice *WebhookDeliveryService) EnqueueByOrder(order_id int64, event string, data interface{},
+	trans *gorp.Transaction) (err error) {
+	return webhookdeliveryservice.enqueue(event, data, "webhooks w inner join orders o on o.unit_id = w.unit_id where o.id = ?",
+		order_id, trans)
+}
+
+func (webhookdeliveryservice *WebhookDeliveryService) EnqueueByCompany(company_id int64, event string, data interface{},
+	trans *gorp.Transaction) (err error) {
+	return webhookdeliveryservice.enqueue(event, data, "webhooks w inner join companies c on c.unit_id = w.unit_id where c.id = ?",
+		company_id, trans)
+}
+
+// Сохранение результата попытки доставки с освобождением аренды. Если аренда была перехвачена другим обработчиком,
+// то результат не сохраняется
+func (webhookdeliveryservice *WebhookDeliveryService) Update(delivery *models.DtoWebhookDelivery) (err error) {
+	result, err := webhookdeliveryservice.DbContext.Exec("update "+webhookdeliveryservice.Table+
+		" set status = ?, attempts = ?, response_code = ?, error = ?, next_attempt = ?, lease = '', lease_till = ?, delivered = ?"+
+		" where id = ? and lease = ?", delivery.Status, delivery.Attempts, delivery.ResponseCode, delivery.Error,
+		delivery.Next_Attempt, time.Time{}, delivery.Delivered, delivery.ID, delivery.Lease)
+	if err != nil {
+		log.Error("Error during updating webhook delivery object in database %v with value %v", err, delivery.ID)
+		return err
+	}
+	count, err := result.RowsAffected()
+	if err != nil {
+		log.Error("Error during updating webhook delivery object in database %v with value %v", err, delivery.ID)
+		return err
+	}
+	if count == 0 {
+		log.Error("Webhook delivery lease has been lost %v", delivery.ID)
+		return errors.New("Lease lost")
+	}
+	delivery.Lease = ""
+	delivery.Lease_Till = time.Time{}
+
+	return nil
+}
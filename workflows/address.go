@@ -0,0 +1,286 @@
+package workflows
+
+import (
+	"application/models"
+	"regexp"
+	"strings"
+)
+
+const (
+	ADDRESS_MAX_DEPTH = 10
+)
+
+var (
+	addressPostalCode = regexp.MustCompile(`^\d{6}$`)
+	addressCountry    = regexp.MustCompile(`^(россия|рф|российская федерация)$`)
+	addressHouse      = regexp.MustCompile(`^(?:д|дом|влд|владение)\.?\s*(\d[0-9а-я/\-]*)(?:\s*(корп|корпус|к|стр|строение)\.?\s*([0-9а-я]+))?$`)
+	addressBlock      = regexp.MustCompile(`^(корп|корпус|к|стр|строение)\.?\s*([0-9а-я]+)$`)
+	addressFlat       = regexp.MustCompile(`^(кв|квартира|оф|офис|пом|помещение)\.?\s*([0-9а-я/\-]+)$`)
+	addressNumber     = regexp.MustCompile(`^\d+[а-я]?(/\d+[а-я]?)?$`)
+
+	// Краткие типы адресных объектов по написанию в исходном адресе
+	addressObjectTypes = map[string]string{
+		"г": "г", "город": "г", "респ": "респ", "республика": "респ", "обл": "обл", "область": "обл", "край": "край",
+		"ао": "ао", "аобл": "аобл", "р-н": "р-н", "район": "р-н", "с": "с", "село": "с", "п": "п", "пос": "п", "поселок": "п",
+		"пгт": "пгт", "дер": "д", "деревня": "д", "ст-ца": "ст-ца", "станица": "ст-ца", "ул": "ул", "улица": "ул",
+		"пр-кт": "пр-кт", "пр": "пр-кт", "проспект": "пр-кт", "пер": "пер", "переулок": "пер", "ш": "ш", "шоссе": "ш",
+		"б-р": "б-р", "бульвар": "б-р", "наб": "наб", "набережная": "наб", "пл": "пл", "площадь": "пл", "проезд": "проезд",
+		"туп": "туп", "тупик": "туп", "мкр": "мкр", "микрорайон": "мкр", "тер": "тер", "территория": "тер",
+	}
+	// Типы, которые в стандартизованном адресе пишутся после названия
+	addressSuffixTypes = map[string]bool{"обл": true, "край": true, "р-н": true, "ао": true, "аобл": true, "м.р-н": true}
+	addressBlockTypes  = map[string]string{"корп": "к", "корпус": "к", "к": "к", "стр": "стр", "строение": "стр"}
+	addressFlatTypes   = map[string]string{"кв": "кв", "квартира": "кв", "оф": "офис", "офис": "офис", "пом": "пом",
+		"помещение": "пом"}
+)
+
+// Части исходного адреса
+type addressParts struct {
+	postalCode string
+	house      string
+	blockType  string
+	block      string
+	flatType   string
+	flat       string
+	objects    []addressPart
+	unparsed   []string
+}
+
+// Название адресного объекта и указанный в адресе тип
+type addressPart struct {
+	source   string
+	name     string
+	typeHint string
+}
+
+// Разбор адреса на части по запятым
+func parseAddress(source string) *addressParts {
+	parts := new(addressParts)
+	for _, value := range strings.Split(source, ",") {
+		value = strings.Join(strings.Fields(value), " ")
+		lower := strings.ToLower(value)
+		if lower == "" || addressCountry.MatchString(lower) {
+			continue
+		}
+		if matches := addressPostalCode.FindStringSubmatch(lower); matches != nil && parts.postalCode == "" {
+			parts.postalCode = matches[0]
+		} else if matches := addressHouse.FindStringSubmatch(lower); matches != nil && parts.house == "" {
+			parts.house = matches[1]
+			if matches[2] != "" {
+				parts.blockType, parts.block = addressBlockTypes[matches[2]], matches[3]
+			}
+		} else if matches := addressBlock.FindStringSubmatch(lower); matches != nil && parts.house != "" && parts.block == "" {
+			parts.blockType, parts.block = addressBlockTypes[matches[1]], matches[2]
+		} else if matches := addressFlat.FindStringSubmatch(lower); matches != nil && parts.flat == "" {
+			parts.flatType, parts.flat = addressFlatTypes[matches[1]], matches[2]
+		} else if addressNumber.MatchString(lower) && parts.house == "" && len(parts.objects) != 0 {
+			parts.house = lower
+		} else {
+			part := addressPart{source: value}
+			names := []string{}
+			for _, word := range strings.Fields(value) {
+				if hint, ok := addressObjectTypes[strings.TrimSuffix(strings.ToLower(word), ".")]; ok && part.typeHint == "" {
+					part.typeHint = hint
+				} else {
+					names = append(names, word)
+				}
+			}
+			part.name = strings.Join(names, " ")
+			if part.name == "" {
+				parts.unparsed = append(parts.unparsed, value)
+			} else {
+				parts.objects = append(parts.objects, part)
+			}
+		}
+	}
+
+	return parts
+}
+
+// Стандартизация адреса по справочнику ФИАС
+func (addressworkflow *AddressWorkflow) Standardize(source string) (apistandardaddress *models.ApiStandardAddress, err error) {
+	apistandardaddress = &models.ApiStandardAddress{
+		Source:      source,
+		QualityCode: models.ADDRESS_QUALITY_CODE_EMPTY,
+		QcComplete:  models.ADDRESS_QC_COMPLETE_NOREGION,
+		QcHouse:     models.ADDRESS_QC_HOUSE_NOTFOUND,
+	}
+	parts := parseAddress(source)
+	if len(parts.objects) == 0 {
+		apistandardaddress.UnparsedParts = strings.Join(parts.unparsed, ", ")
+		return apistandardaddress, nil
+	}
+
+	// Поиск объектов сверху вниз: каждый следующий объект ищется среди подчиненных найденному
+	var current *models.DtoAddressObject
+	ambiguous := false
+	for _, part := range parts.objects {
+		var candidates *[]models.DtoAddressObject
+		if current == nil {
+			candidates, err = addressworkflow.AddressRepository.FindObjects(part.name)
+		} else {
+			candidates, err = addressworkflow.AddressRepository.FindChildren(current.ID, part.name)
+		}
+		if err != nil {
+			return nil, err
+		}
+		matched := *candidates
+		if part.typeHint != "" {
+			typed := []models.DtoAddressObject{}
+			for _, candidate := range matched {
+				if strings.ToLower(strings.TrimSuffix(candidate.TypeShort, ".")) == part.typeHint {
+					typed = append(typed, candidate)
+				}
+			}
+			if len(typed) != 0 {
+				matched = typed
+			}
+		}
+		if len(matched) == 0 {
+			parts.unparsed = append(parts.unparsed, part.source)
+			continue
+		}
+		if len(matched) > 1 && matched[1].Level == matched[0].Level {
+			ambiguous = true
+		}
+		current = &matched[0]
+	}
+	if current == nil {
+		apistandardaddress.UnparsedParts = strings.Join(parts.unparsed, ", ")
+		return apistandardaddress, nil
+	}
+
+	// Цепочка объектов от найденного до региона
+	chain := []models.DtoAddressObject{*current}
+	for parent_id := current.ID; len(chain) < ADDRESS_MAX_DEPTH; {
+		parent_id, err = addressworkflow.AddressRepository.GetParent(parent_id)
+		if err != nil {
+			return nil, err
+		}
+		if parent_id == 0 {
+			break
+		}
+		parent, err := addressworkflow.AddressRepository.GetObject(parent_id)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, *parent)
+	}
+
+	ids := []int64{}
+	result := []string{}
+	for i := len(chain) - 1; i >= 0; i-- {
+		object := chain[i]
+		ids = append([]int64{object.ID}, ids...)
+		result = append(result, standardAddressName(&object))
+		switch object.Level {
+		case models.ADDRESS_LEVEL_REGION:
+			apistandardaddress.RegionType, apistandardaddress.RegionTypeFull, apistandardaddress.Region =
+				object.TypeShort, object.TypeFull, object.Name
+		case models.ADDRESS_LEVEL_AREA, models.ADDRESS_LEVEL_MUNICIPAL_AREA:
+			apistandardaddress.AreaType, apistandardaddress.AreaTypeFull, apistandardaddress.Area =
+				object.TypeShort, object.TypeFull, object.Name
+		case models.ADDRESS_LEVEL_CITY:
+			apistandardaddress.CityType, apistandardaddress.CityTypeFull, apistandardaddress.City =
+				object.TypeShort, object.TypeFull, object.Name
+		case models.ADDRESS_LEVEL_SETTLEMENT, models.ADDRESS_LEVEL_SETTLEMENT_DISTRICT:
+			if object.Level == models.ADDRESS_LEVEL_SETTLEMENT || apistandardaddress.Settlement == "" {
+				apistandardaddress.SettlementType, apistandardaddress.SettlementTypeFull, apistandardaddress.Settlement =
+					object.TypeShort, object.TypeFull, object.Name
+			}
+		case models.ADDRESS_LEVEL_STREET:
+			apistandardaddress.StreetType, apistandardaddress.StreetTypeFull, apistandardaddress.Street =
+				object.TypeShort, object.TypeFull, object.Name
+		}
+	}
+	apistandardaddress.FiasId = current.GUID
+
+	// Поиск дома среди подчиненных самому нижнему найденному объекту
+	if parts.house != "" {
+		apistandardaddress.HouseType, apistandardaddress.HouseTypeFull, apistandardaddress.House = "д", "дом", parts.house
+		apistandardaddress.BlockType, apistandardaddress.Block = parts.blockType, parts.block
+		houses, err := addressworkflow.AddressRepository.FindHouses(current.ID, parts.house)
+		if err != nil {
+			return nil, err
+		}
+		for i := range *houses {
+			house := &(*houses)[i]
+			if !strings.EqualFold(house.Block, parts.block) {
+				continue
+			}
+			apistandardaddress.HouseType, apistandardaddress.HouseTypeFull, apistandardaddress.House =
+				house.TypeShort, house.TypeFull, house.Number
+			apistandardaddress.BlockType, apistandardaddress.BlockTypeFull, apistandardaddress.Block =
+				house.BlockTypeShort, house.BlockTypeFull, house.Block
+			apistandardaddress.FiasId = house.GUID
+			apistandardaddress.QcHouse = models.ADDRESS_QC_HOUSE_FOUND
+			ids = append([]int64{house.ID}, ids...)
+			break
+		}
+		result = append(result, strings.TrimSpace(apistandardaddress.HouseType+" "+apistandardaddress.House))
+		if apistandardaddress.Block != "" {
+			result = append(result, strings.TrimSpace(apistandardaddress.BlockType+" "+apistandardaddress.Block))
+		}
+	}
+	if parts.flat != "" {
+		apistandardaddress.FlatType, apistandardaddress.Flat = parts.flatType, parts.flat
+		result = append(result, parts.flatType+" "+parts.flat)
+	}
+	apistandardaddress.Result = strings.Join(result, ", ")
+	apistandardaddress.Country = models.ADDRESS_COUNTRY
+
+	// Коды берутся у самого нижнего объекта, для которого они указаны
+	params, err := addressworkflow.AddressRepository.GetParams(ids)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range ids {
+		setAddressParam(&apistandardaddress.PostalCode, params[id][models.ADDRESS_PARAM_POSTALCODE])
+		setAddressParam(&apistandardaddress.Okato, params[id][models.ADDRESS_PARAM_OKATO])
+		setAddressParam(&apistandardaddress.Oktmo, params[id][models.ADDRESS_PARAM_OKTMO])
+		setAddressParam(&apistandardaddress.KladrId, params[id][models.ADDRESS_PARAM_KLADR])
+		setAddressParam(&apistandardaddress.TaxOffice, params[id][models.ADDRESS_PARAM_TAXOFFICE])
+		setAddressParam(&apistandardaddress.TaxOfficeLegal, params[id][models.ADDRESS_PARAM_TAXOFFICE_LEGAL])
+	}
+	setAddressParam(&apistandardaddress.PostalCode, parts.postalCode)
+
+	apistandardaddress.UnparsedParts = strings.Join(parts.unparsed, ", ")
+	switch {
+	case ambiguous:
+		apistandardaddress.QualityCode = models.ADDRESS_QUALITY_CODE_AMBIGUOUS
+	case len(parts.unparsed) != 0:
+		apistandardaddress.QualityCode = models.ADDRESS_QUALITY_CODE_UNPARSED
+	default:
+		apistandardaddress.QualityCode = models.ADDRESS_QUALITY_CODE_GOOD
+	}
+	switch {
+	case apistandardaddress.Region == "":
+		apistandardaddress.QcComplete = models.ADDRESS_QC_COMPLETE_NOREGION
+	case apistandardaddress.City == "" && apistandardaddress.Settlement == "":
+		apistandardaddress.QcComplete = models.ADDRESS_QC_COMPLETE_NOCITY
+	case apistandardaddress.Street == "" && current.Level != models.ADDRESS_LEVEL_PLANNING_STRUCTURE:
+		apistandardaddress.QcComplete = models.ADDRESS_QC_COMPLETE_NOSTREET
+	case apistandardaddress.House == "":
+		apistandardaddress.QcComplete = models.ADDRESS_QC_COMPLETE_NOHOUSE
+	case apistandardaddress.QcHouse != models.ADDRESS_QC_HOUSE_FOUND:
+		apistandardaddress.QcComplete = models.ADDRESS_QC_COMPLETE_HOUSEFIAS
+	default:
+		apistandardaddress.QcComplete = models.ADDRESS_QC_COMPLETE_GOOD
+	}
+
+	return apistandardaddress, nil
+}
+
+// Название объекта в стандартизованном адресе: "г Москва", "Московская обл"
+func standardAddressName(object *models.DtoAddressObject) string {
+	if addressSuffixTypes[strings.ToLower(object.TypeShort)] {
+		return object.Name + " " + object.TypeShort
+	}
+	return strings.TrimSpace(object.TypeShort + " " + object.Name)
+}
+
+func setAddressParam(field *string, value string) {
+	if *field == "" && value != "" {
+		*field = value
+	}
+}
@@ -0,0 +1,313 @@
+package workflows
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"archive/zip"
+	"encoding/xml"
+	"errors"
+	"io"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"time"
+)
+
+const (
+	ADDRESS_IMPORT_BATCH_SIZE   = 1000
+	ADDRESS_IMPORT_ERROR_LENGTH = 255
+	ADDRESS_IMPORT_DATE_LAYOUT  = "2006-01-02"
+)
+
+var (
+	// Файлы архива ГАР, загружаемые в справочник адресов
+	addressObjectTypesFile = regexp.MustCompile(`^AS_ADDR_OBJ_TYPES_\d`)
+	addressHouseTypesFile  = regexp.MustCompile(`^AS_(ADD)?HOUSE_TYPES_\d`)
+	addressObjectsFile     = regexp.MustCompile(`^AS_ADDR_OBJ_\d`)
+	addressHousesFile      = regexp.MustCompile(`^AS_HOUSES_\d`)
+	addressHierarchyFile   = regexp.MustCompile(`^AS_ADM_HIERARCHY_\d`)
+	addressParamsFile      = regexp.MustCompile(`^AS_(ADDR_OBJ|HOUSES)_PARAMS_\d`)
+
+	addressParamTypes = map[int]bool{
+		models.ADDRESS_PARAM_TAXOFFICE:       true,
+		models.ADDRESS_PARAM_TAXOFFICE_LEGAL: true,
+		models.ADDRESS_PARAM_POSTALCODE:      true,
+		models.ADDRESS_PARAM_OKATO:           true,
+		models.ADDRESS_PARAM_OKTMO:           true,
+		models.ADDRESS_PARAM_KLADR:           true,
+	}
+)
+
+// Краткое и полное название типа адресного объекта или дома
+type AddressType struct {
+	Short string
+	Full  string
+}
+
+// Справочники типов архива ГАР, заполняемые перед загрузкой объектов
+type addressTypes struct {
+	objects map[string]string      // Полное название типа объекта по уровню и краткому названию
+	houses  map[string]AddressType // Тип дома по идентификатору
+	blocks  map[string]AddressType // Тип корпуса по идентификатору
+}
+
+type AddressWorkflow struct {
+	AddressRepository       services.AddressRepository
+	AddressImportRepository services.AddressImportRepository
+}
+
+func NewAddressWorkflow(addressrepository services.AddressRepository,
+	addressimportrepository services.AddressImportRepository) *AddressWorkflow {
+	return &AddressWorkflow{
+		AddressRepository:       addressrepository,
+		AddressImportRepository: addressimportrepository,
+	}
+}
+
+// Запуск загрузки архива ГАР из директории справочника адресов
+func (addressworkflow *AddressWorkflow) StartImport(file string) (dtoaddressimport *models.DtoAddressImport, err error) {
+	dtoaddressimport = models.NewDtoAddressImport(0, file, models.ADDRESS_IMPORT_STATUS_RUNNING, 0, 0, "", time.Now(), time.Time{})
+	err = addressworkflow.AddressImportRepository.Create(dtoaddressimport)
+	if err != nil {
+		return nil, err
+	}
+
+	go addressworkflow.Import(dtoaddressimport)
+	return dtoaddressimport, nil
+}
+
+// Загрузка архива ГАР: сначала справочники типов, затем объекты, дома, иерархия и параметры
+func (addressworkflow *AddressWorkflow) Import(dtoaddressimport *models.DtoAddressImport) {
+	err := addressworkflow.importArchive(dtoaddressimport)
+	if err != nil {
+		log.Error("Error during importing address archive %v with value %v", err, dtoaddressimport.File)
+		dtoaddressimport.Status = models.ADDRESS_IMPORT_STATUS_FAILED
+		dtoaddressimport.Error = err.Error()
+		if len(dtoaddressimport.Error) > ADDRESS_IMPORT_ERROR_LENGTH {
+			dtoaddressimport.Error = dtoaddressimport.Error[:ADDRESS_IMPORT_ERROR_LENGTH]
+		}
+	} else {
+		dtoaddressimport.Status = models.ADDRESS_IMPORT_STATUS_COMPLETED
+	}
+	dtoaddressimport.Finished = time.Now()
+
+	_ = addressworkflow.AddressImportRepository.Update(dtoaddressimport)
+}
+
+func (addressworkflow *AddressWorkflow) importArchive(dtoaddressimport *models.DtoAddressImport) (err error) {
+	if config.Configuration.Address.Directory == "" {
+		return errors.New("Address directory is not configured")
+	}
+	archive, err := zip.OpenReader(filepath.Join(config.Configuration.Address.Directory, dtoaddressimport.File))
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	addresstypes := &addressTypes{
+		objects: make(map[string]string),
+		houses:  make(map[string]AddressType),
+		blocks:  make(map[string]AddressType),
+	}
+	for _, file := range archive.File {
+		name := path.Base(file.Name)
+		switch {
+		case addressObjectTypesFile.MatchString(name):
+			err = readAddressElements(file, "ADDRESSOBJECTTYPE", func(attrs map[string]string) error {
+				addresstypes.objects[attrs["LEVEL"]+":"+attrs["SHORTNAME"]] = attrs["NAME"]
+				return nil
+			})
+		case addressHouseTypesFile.MatchString(name):
+			target := addresstypes.houses
+			if name[3:6] == "ADD" {
+				target = addresstypes.blocks
+			}
+			err = readAddressElements(file, "HOUSETYPE", func(attrs map[string]string) error {
+				target[attrs["ID"]] = AddressType{Short: attrs["SHORTNAME"], Full: attrs["NAME"]}
+				return nil
+			})
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, file := range archive.File {
+		name := path.Base(file.Name)
+		switch {
+		case addressObjectsFile.MatchString(name):
+			err = addressworkflow.importObjects(file, addresstypes, dtoaddressimport)
+		case addressHousesFile.MatchString(name):
+			err = addressworkflow.importHouses(file, addresstypes, dtoaddressimport)
+		case addressHierarchyFile.MatchString(name):
+			err = addressworkflow.importHierarchy(file)
+		case addressParamsFile.MatchString(name):
+			err = addressworkflow.importParams(file)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (addressworkflow *AddressWorkflow) importObjects(file *zip.File, addresstypes *addressTypes,
+	dtoaddressimport *models.DtoAddressImport) (err error) {
+	objects := []models.DtoAddressObject{}
+	err = readAddressElements(file, "OBJECT", func(attrs map[string]string) error {
+		if attrs["ISACTUAL"] != "1" || attrs["ISACTIVE"] != "1" {
+			return nil
+		}
+		id, err := strconv.ParseInt(attrs["OBJECTID"], 10, 64)
+		if err != nil {
+			return err
+		}
+		level, err := strconv.Atoi(attrs["LEVEL"])
+		if err != nil {
+			return err
+		}
+		typefull, ok := addresstypes.objects[attrs["LEVEL"]+":"+attrs["TYPENAME"]]
+		if !ok {
+			typefull = attrs["TYPENAME"]
+		}
+		objects = append(objects, *models.NewDtoAddressObject(id, attrs["OBJECTGUID"], attrs["NAME"], attrs["TYPENAME"], typefull, level))
+		if len(objects) < ADDRESS_IMPORT_BATCH_SIZE {
+			return nil
+		}
+		dtoaddressimport.Objects += int64(len(objects))
+		err = addressworkflow.AddressRepository.SaveObjects(&objects)
+		objects = objects[:0]
+		return err
+	})
+	if err != nil || len(objects) == 0 {
+		return err
+	}
+
+	dtoaddressimport.Objects += int64(len(objects))
+	return addressworkflow.AddressRepository.SaveObjects(&objects)
+}
+
+func (addressworkflow *AddressWorkflow) importHouses(file *zip.File, addresstypes *addressTypes,
+	dtoaddressimport *models.DtoAddressImport) (err error) {
+	houses := []models.DtoAddressHouse{}
+	err = readAddressElements(file, "HOUSE", func(attrs map[string]string) error {
+		if attrs["ISACTUAL"] != "1" || attrs["ISACTIVE"] != "1" {
+			return nil
+		}
+		id, err := strconv.ParseInt(attrs["OBJECTID"], 10, 64)
+		if err != nil {
+			return err
+		}
+		housetype := addresstypes.houses[attrs["HOUSETYPE"]]
+		blocktype := addresstypes.blocks[attrs["ADDTYPE1"]]
+		houses = append(houses, *models.NewDtoAddressHouse(id, attrs["OBJECTGUID"], attrs["HOUSENUM"], housetype.Short,
+			housetype.Full, attrs["ADDNUM1"], blocktype.Short, blocktype.Full))
+		if len(houses) < ADDRESS_IMPORT_BATCH_SIZE {
+			return nil
+		}
+		dtoaddressimport.Houses += int64(len(houses))
+		err = addressworkflow.AddressRepository.SaveHouses(&houses)
+		houses = houses[:0]
+		return err
+	})
+	if err != nil || len(houses) == 0 {
+		return err
+	}
+
+	dtoaddressimport.Houses += int64(len(houses))
+	return addressworkflow.AddressRepository.SaveHouses(&houses)
+}
+
+func (addressworkflow *AddressWorkflow) importHierarchy(file *zip.File) (err error) {
+	hierarchy := []models.DtoAddressHierarchy{}
+	err = readAddressElements(file, "ITEM", func(attrs map[string]string) error {
+		if attrs["ISACTIVE"] != "1" {
+			return nil
+		}
+		object_id, err := strconv.ParseInt(attrs["OBJECTID"], 10, 64)
+		if err != nil {
+			return err
+		}
+		parent_id, err := strconv.ParseInt(attrs["PARENTOBJID"], 10, 64)
+		if err != nil {
+			return err
+		}
+		hierarchy = append(hierarchy, models.DtoAddressHierarchy{Object_ID: object_id, Parent_ID: parent_id})
+		if len(hierarchy) < ADDRESS_IMPORT_BATCH_SIZE {
+			return nil
+		}
+		err = addressworkflow.AddressRepository.SaveHierarchy(&hierarchy)
+		hierarchy = hierarchy[:0]
+		return err
+	})
+	if err != nil || len(hierarchy) == 0 {
+		return err
+	}
+
+	return addressworkflow.AddressRepository.SaveHierarchy(&hierarchy)
+}
+
+// Загружаются только используемые при стандартизации параметры, действующие на дату загрузки
+func (addressworkflow *AddressWorkflow) importParams(file *zip.File) (err error) {
+	today := time.Now().Format(ADDRESS_IMPORT_DATE_LAYOUT)
+	params := []models.DtoAddressParam{}
+	err = readAddressElements(file, "PARAM", func(attrs map[string]string) error {
+		type_id, err := strconv.Atoi(attrs["TYPEID"])
+		if err != nil {
+			return err
+		}
+		if !addressParamTypes[type_id] || attrs["ENDDATE"] <= today {
+			return nil
+		}
+		object_id, err := strconv.ParseInt(attrs["OBJECTID"], 10, 64)
+		if err != nil {
+			return err
+		}
+		params = append(params, models.DtoAddressParam{Object_ID: object_id, Type_ID: type_id, Value: attrs["VALUE"]})
+		if len(params) < ADDRESS_IMPORT_BATCH_SIZE {
+			return nil
+		}
+		err = addressworkflow.AddressRepository.SaveParams(&params)
+		params = params[:0]
+		return err
+	})
+	if err != nil || len(params) == 0 {
+		return err
+	}
+
+	return addressworkflow.AddressRepository.SaveParams(&params)
+}
+
+// Потоковое чтение элементов xml файла архива с передачей атрибутов каждого элемента обработчику
+func readAddressElements(file *zip.File, element string, handle func(attrs map[string]string) error) (err error) {
+	reader, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	decoder := xml.NewDecoder(reader)
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		start, ok := token.(xml.StartElement)
+		if !ok || start.Name.Local != element {
+			continue
+		}
+		attrs := make(map[string]string, len(start.Attr))
+		for _, attr := range start.Attr {
+			attrs[attr.Name.Local] = attr.Value
+		}
+		if err = handle(attrs); err != nil {
+			return err
+		}
+	}
+}
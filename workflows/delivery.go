@@ -0,0 +1,177 @@
+package workflows
+
+import (
+	"application/config"
+	"application/gateways"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"github.com/gocql/gocql"
+	libTypes "lib/suppliers/types"
+	"reflect"
+	"time"
+)
+
+const (
+	DELIVERY_POLL_INTERVAL     = time.Second
+	DELIVERY_POLL_MAX_INTERVAL = 5 * time.Minute
+	DELIVERY_DEADLINE          = 72 * time.Hour
+)
+
+func DeliveryPollInterval() time.Duration {
+	if config.Configuration.Delivery.PollInterval > 0 {
+		return config.Configuration.Delivery.PollInterval
+	}
+	return DELIVERY_POLL_INTERVAL
+}
+
+func DeliveryPollMaxInterval() time.Duration {
+	if config.Configuration.Delivery.PollMaxInterval > 0 {
+		return config.Configuration.Delivery.PollMaxInterval
+	}
+	return DELIVERY_POLL_MAX_INTERVAL
+}
+
+// Срок получения окончательных статусов запросов, отправленных сейчас
+func DeliveryDeadline() time.Time {
+	if config.Configuration.Delivery.Deadline > 0 {
+		return time.Now().Add(config.Configuration.Delivery.Deadline)
+	}
+	return time.Now().Add(DELIVERY_DEADLINE)
+}
+
+// Заполнение статуса поставщика по отчету о доставке
+func FillDeliveryStatus(status interface{}, id gocql.UUID, dtodeliveryreport *models.DtoDeliveryReport) {
+	value := reflect.ValueOf(status).Elem()
+	gateways.SetField(value, "Id", reflect.ValueOf(id))
+	gateways.SetField(value, "Final", reflect.ValueOf(dtodeliveryreport.Final))
+	gateways.SetField(value, "Status", reflect.ValueOf(dtodeliveryreport.Status))
+	gateways.SetField(value, "Error", reflect.ValueOf(dtodeliveryreport.Error))
+	gateways.SetField(value, "ResponseOrn", reflect.ValueOf(dtodeliveryreport.Orn))
+	gateways.SetField(value, "ResponseIsRoaming", reflect.ValueOf(dtodeliveryreport.Roaming))
+}
+
+// Регистрация отправленных запросов для приема отчетов о доставке от поставщиков. Номера абонентов, если переданы,
+// соответствуют запросам по индексу
+func RegisterDelivery(order_id int64, reporttype models.DeliveryReportType, ids []gocql.UUID, mobilephones []uint64,
+	suppliers map[gocql.UUID]int64, supplier_id int64, deliveryreportrepository services.DeliveryReportRepository) (err error) {
+	dtodeliveryreports := []models.DtoDeliveryReport{}
+	for index, id := range ids {
+		if id == (gocql.UUID{}) {
+			continue
+		}
+		reportsupplier_id, ok := suppliers[id]
+		if !ok {
+			reportsupplier_id = supplier_id
+		}
+		dtodeliveryreport := models.NewDtoDeliveryReport(id.String(), order_id, reportsupplier_id,
+			reporttype, "", "", "", false, false, time.Now(), time.Now())
+		if index < len(mobilephones) {
+			dtodeliveryreport.MobilePhone = mobilephones[index]
+		}
+		dtodeliveryreports = append(dtodeliveryreports, *dtodeliveryreport)
+	}
+
+	return deliveryreportrepository.CreateAll(&dtodeliveryreports)
+}
+
+// Получение окончательных статусов запросов. Статус берется из отчета о доставке, переданного поставщиком, а при его отсутствии
+// запрашивается у поставщика с удваивающимся интервалом. По истечении срока оставшиеся запросы считаются просроченными
+func PollDelivery(order_id int64, reporttype models.DeliveryReportType, ids []gocql.UUID, deadline time.Time,
+	deliveryreportrepository services.DeliveryReportRepository, poll func(id gocql.UUID) (final bool, err error),
+	report func(id gocql.UUID, dtodeliveryreport *models.DtoDeliveryReport)) (err error) {
+	pending := make(map[gocql.UUID]bool)
+	for _, id := range ids {
+		if id != (gocql.UUID{}) {
+			pending[id] = true
+		}
+	}
+
+	interval := DeliveryPollInterval()
+	for len(pending) != 0 {
+		dtodeliveryreports, err := deliveryreportrepository.GetByOrder(order_id, reporttype)
+		if err != nil {
+			return err
+		}
+		for i := range *dtodeliveryreports {
+			if !(*dtodeliveryreports)[i].Final {
+				continue
+			}
+			id, err := gocql.ParseUUID((*dtodeliveryreports)[i].Request_ID)
+			if err != nil || !pending[id] {
+				continue
+			}
+			report(id, &(*dtodeliveryreports)[i])
+			delete(pending, id)
+		}
+
+		for id := range pending {
+			final, err := poll(id)
+			if err != nil {
+				return err
+			}
+			if final {
+				delete(pending, id)
+			}
+		}
+		if len(pending) == 0 {
+			break
+		}
+
+		if time.Now().After(deadline) {
+			log.Error("Delivery deadline for order %v has expired, %v requests have no final status", order_id, len(pending))
+			request_ids := []string{}
+			for id := range pending {
+				request_ids = append(request_ids, id.String())
+				report(id, models.NewDtoDeliveryReport(id.String(), order_id, 0, reporttype, models.DELIVERY_STATUS_EXPIRED,
+					models.DELIVERY_ERROR_EXPIRED, "", false, true, time.Now(), time.Now()))
+			}
+			return deliveryreportrepository.Expire(order_id, reporttype, request_ids)
+		}
+		time.Sleep(interval)
+		interval *= 2
+		if interval > DeliveryPollMaxInterval() {
+			interval = DeliveryPollMaxInterval()
+		}
+	}
+
+	return nil
+}
+
+// Регистрация sms сообщений заказа с учетом поставщиков, которым они были отправлены при маршрутизации, и номеров
+// получателей, по которым принимаются ответы абонентов
+func (smsworkflow *SMSWorkflow) RegisterSMSDelivery(dtoorder *models.DtoOrder, smsresponse *libTypes.SmsResponse,
+	apitablerows *[]models.ApiInfoTableRow, columnmobilephone *models.DtoTableColumn) (err error) {
+	dtocolumntype, err := smsworkflow.ColumnTypeRepository.Get(columnmobilephone.Column_Type_ID)
+	if err != nil {
+		return err
+	}
+	mobilephones := make([]uint64, len(*apitablerows))
+	for index, apitablerow := range *apitablerows {
+		for _, apitablecell := range apitablerow.Cells {
+			if apitablecell.Table_Column_ID == columnmobilephone.ID {
+				mobilephones[index], _ = helpers.CheckMobilePhone(apitablecell.Value, dtocolumntype, smsworkflow.ColumnTypeRepository)
+			}
+		}
+	}
+	routes, err := smsworkflow.OrderRouteRepository.GetByOrder(dtoorder.ID)
+	if err != nil {
+		return err
+	}
+	suppliers := make(map[gocql.UUID]int64)
+	for _, route := range *routes {
+		if route.Status != models.ORDER_ROUTE_STATUS_SENT {
+			continue
+		}
+		routeresponse, err := DecodeSMSResponse(route.Response)
+		if err != nil {
+			return err
+		}
+		for _, id := range routeresponse.Ids {
+			suppliers[id] = route.Supplier_ID
+		}
+	}
+
+	return RegisterDelivery(dtoorder.ID, models.DELIVERY_REPORT_TYPE_SMS, smsresponse.Ids, mobilephones, suppliers,
+		dtoorder.Supplier_ID, smsworkflow.DeliveryReportRepository)
+}
@@ -16,7 +16,7 @@ type HeaderWorkflow struct {
 	OrderStatusRepository     services.OrderStatusRepository
 	InvoiceRepository         services.InvoiceRepository
 	CompanyRepository         services.CompanyRepository
-	OperationRepository       services.OperationRepository
+	LedgerRepository          services.LedgerRepository
 	TransactionTypeRepository services.TransactionTypeRepository
 	TableColumnRepository     services.TableColumnRepository
 	UnitRepository            services.UnitRepository
@@ -29,7 +29,7 @@ type HeaderWorkflow struct {
 
 func NewHeaderWorkflow(orderrepository services.OrderRepository, facilityrepository services.FacilityRepository,
 	headerfacilityrepository services.HeaderFacilityRepository, orderstatusrepository services.OrderStatusRepository,
-	invoicerepository services.InvoiceRepository, companyrepository services.CompanyRepository, operationrepository services.OperationRepository,
+	invoicerepository services.InvoiceRepository, companyrepository services.CompanyRepository, ledgerrepository services.LedgerRepository,
 	transactiontyperepository services.TransactionTypeRepository, tablecolumnrepository services.TableColumnRepository,
 	unitrepository services.UnitRepository, tablerowrepository services.TableRowRepository, pricerepository services.PriceRepository,
 	headerproductrepository services.HeaderProductRepository, templaterepository services.TemplateRepository,
@@ -41,7 +41,7 @@ func NewHeaderWorkflow(orderrepository services.OrderRepository, facilityreposit
 		OrderStatusRepository:     orderstatusrepository,
 		InvoiceRepository:         invoicerepository,
 		CompanyRepository:         companyrepository,
-		OperationRepository:       operationrepository,
+		LedgerRepository:          ledgerrepository,
 		TransactionTypeRepository: transactiontyperepository,
 		TableColumnRepository:     tablecolumnrepository,
 		UnitRepository:            unitrepository,
@@ -87,11 +87,7 @@ func (headerworkflow *HeaderWorkflow) CalculateCost(
 	return cost, nil
 }
 
-func (headerworkflow *HeaderWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtoheaderfacility *models.DtoHeaderFacility, balance float64) (err error) {
-	if balance < dtoheaderfacility.Cost {
-		log.Error("Not enough money at unit balance %v to pay for order %v execution", dtoorder.Unit_ID, dtoorder.ID)
-		return errors.New("Not enough money")
-	}
+func (headerworkflow *HeaderWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtoheaderfacility *models.DtoHeaderFacility) (err error) {
 	dtocompany, err := headerworkflow.CompanyRepository.GetPrimaryByUnit(dtoorder.Unit_ID)
 	if err != nil {
 		return
@@ -113,9 +109,9 @@ func (headerworkflow *HeaderWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, d
 	dtoinvoice := new(models.DtoInvoice)
 	dtoinvoice.Company_ID = dtocompany.ID
 	if dtocompany.VAT != 0 {
-		dtoinvoice.VAT = (dtoheaderfacility.Cost / (1 + float64(dtocompany.VAT)/100)) * float64(dtocompany.VAT) / 100
+		dtoinvoice.VAT = (dtoheaderfacility.CostFactual / (1 + float64(dtocompany.VAT)/100)) * float64(dtocompany.VAT) / 100
 	}
-	dtoinvoice.Total = dtoheaderfacility.Cost
+	dtoinvoice.Total = dtoheaderfacility.CostFactual
 	dtoinvoice.Paid = true
 	dtoinvoice.Created = time.Now()
 	dtoinvoice.Active = true
@@ -216,7 +212,6 @@ func (headerworkflow *HeaderWorkflow) ExecuteOrder(order_id int64) {
 			_ = headerworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-		dtoheaderfacility.CostFactual = dtoheaderfacility.Cost
 		err = headerworkflow.HeaderFacilityRepository.Update(dtoheaderfacility)
 		if err != nil {
 			_ = headerworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
@@ -228,30 +223,36 @@ func (headerworkflow *HeaderWorkflow) ExecuteOrder(order_id int64) {
 			_ = headerworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
+		log.Info("Holding order cost ...")
+		_, err = headerworkflow.LedgerRepository.Hold(dtoorder.Unit_ID, dtoorder.ID, dtoheaderfacility.Cost)
+		if err != nil {
+			_ = headerworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+			return
+		}
 		log.Info("Start order processing ...")
 		/* 5 */ dtoorder.Begin_Date = time.Now()
 		err = headerworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
 			*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_MODERATOR_BEGIN, true, "", time.Now())}, nil, true)
 		if err != nil {
+			_ = headerworkflow.LedgerRepository.Release(dtoorder.ID)
 			_ = headerworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-		log.Info("Calculating unit balance ...")
-		balance, err := headerworkflow.OperationRepository.CalculateBalance(dtoorder.Unit_ID)
+		log.Info("Order notification ...")
+		/* 6 */ err = headerworkflow.SendReuest(dtoorder, dtoheaderfacility)
 		if err != nil {
+			_ = headerworkflow.LedgerRepository.Release(dtoorder.ID)
 			_ = headerworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
 		log.Info("Order payment and invoicement ...")
-		/* 6 */ err = headerworkflow.PayAndInvoice(dtoorder, dtoheaderfacility, balance)
+		/* 7 */ dtoheaderfacility.CostFactual = dtoheaderfacility.Cost
+		err = headerworkflow.HeaderFacilityRepository.Update(dtoheaderfacility)
 		if err != nil {
-			_ = headerworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-		log.Info("Order notification ...")
-		/* 6 */ err = headerworkflow.SendReuest(dtoorder, dtoheaderfacility)
+		err = headerworkflow.PayAndInvoice(dtoorder, dtoheaderfacility)
 		if err != nil {
-			_ = headerworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
 		log.Info("Completing order execution %v", time.Now())
@@ -1,8 +1,8 @@
 package workflows
 
 import (
-	"application/communication/suppliers"
 	"application/config"
+	"application/gateways"
 	"application/helpers"
 	"application/models"
 	"application/services"
@@ -36,7 +36,7 @@ type HLRWorkflow struct {
 	WorkTableRepository       services.WorkTableRepository
 	InvoiceRepository         services.InvoiceRepository
 	CompanyRepository         services.CompanyRepository
-	OperationRepository       services.OperationRepository
+	LedgerRepository          services.LedgerRepository
 	TransactionTypeRepository services.TransactionTypeRepository
 	TableColumnRepository     services.TableColumnRepository
 	UnitRepository            services.UnitRepository
@@ -44,6 +44,8 @@ type HLRWorkflow struct {
 	PriceRepository           services.PriceRepository
 	MobileOperatorRepository  services.MobileOperatorRepository
 	ColumnTypeRepository      services.ColumnTypeRepository
+	DeliveryReportRepository  services.DeliveryReportRepository
+	HLRCacheRepository        services.HLRCacheRepository
 }
 
 func NewHLRWorkflow(orderrepository services.OrderRepository, facilityrepository services.FacilityRepository,
@@ -51,10 +53,11 @@ func NewHLRWorkflow(orderrepository services.OrderRepository, facilityrepository
 	customertablerepository services.CustomerTableRepository, hlrtablerepository services.HLRTableRepository,
 	resulttablerepository services.ResultTableRepository, worktablerepository services.WorkTableRepository,
 	invoicerepository services.InvoiceRepository, companyrepository services.CompanyRepository,
-	operationrepository services.OperationRepository, transactiontyperepository services.TransactionTypeRepository,
+	ledgerrepository services.LedgerRepository, transactiontyperepository services.TransactionTypeRepository,
 	tablecolumnrepository services.TableColumnRepository, unitrepository services.UnitRepository,
 	tablerowrepository services.TableRowRepository, pricerepository services.PriceRepository,
-	mobileoperatorrepository services.MobileOperatorRepository, columntyperepository services.ColumnTypeRepository) *HLRWorkflow {
+	mobileoperatorrepository services.MobileOperatorRepository, columntyperepository services.ColumnTypeRepository,
+	deliveryreportrepository services.DeliveryReportRepository, hlrcacherepository services.HLRCacheRepository) *HLRWorkflow {
 	return &HLRWorkflow{
 		OrderRepository:           orderrepository,
 		FacilityRepository:        facilityrepository,
@@ -66,7 +69,7 @@ func NewHLRWorkflow(orderrepository services.OrderRepository, facilityrepository
 		WorkTableRepository:       worktablerepository,
 		InvoiceRepository:         invoicerepository,
 		CompanyRepository:         companyrepository,
-		OperationRepository:       operationrepository,
+		LedgerRepository:          ledgerrepository,
 		TransactionTypeRepository: transactiontyperepository,
 		TableColumnRepository:     tablecolumnrepository,
 		UnitRepository:            unitrepository,
@@ -74,43 +77,46 @@ func NewHLRWorkflow(orderrepository services.OrderRepository, facilityrepository
 		PriceRepository:           pricerepository,
 		MobileOperatorRepository:  mobileoperatorrepository,
 		ColumnTypeRepository:      columntyperepository,
+		DeliveryReportRepository:  deliveryreportrepository,
+		HLRCacheRepository:        hlrcacherepository,
 	}
 }
 
-func SendHLR(supplier *libTypes.Supplier, hlr *[]libTypes.Hlr) (hlrresponse *libTypes.HlrResponse, err error) {
+func SendHLR(gateway gateways.Gateway, supplier *libTypes.Supplier, hlr *[]libTypes.Hlr) (hlrresponse *libTypes.HlrResponse, err error) {
 	// Отправка HLR
-	suppliers.WaitClientReady()                         // Функция блокируется до момента пока связь с сервером не будет установлена
-	response, err := suppliers.SendHlr(*supplier, *hlr) // Функция не блокируется. Если связи с сервером нет, то вернётся ошибка
+	hlrresponse, err = gateway.SendHlr(supplier, hlr)
 	if err != nil {
 		log.Error("Can't send HLR requests %v for supplier %v", err, supplier.Name)
 		return nil, err
 	}
 
-	return &response, nil
+	return hlrresponse, nil
 }
 
-func GetHLRStatus(hlrresponse *libTypes.HlrResponse) (hlrstatuses map[gocql.UUID]libTypes.HlrStatus, err error) {
+// Получение статусов hlr запросов из отчетов о доставке поставщика или опросом поставщика до истечения срока
+func GetHLRStatus(gateway gateways.Gateway, hlrresponse *libTypes.HlrResponse, order_id int64, deadline time.Time,
+	deliveryreportrepository services.DeliveryReportRepository) (hlrstatuses map[gocql.UUID]libTypes.HlrStatus, err error) {
 	hlrstatuses = make(map[gocql.UUID]libTypes.HlrStatus)
-	// Получение статуса по UUID запроса
-	for {
-		final := true
-		for i := range hlrresponse.Ids {
-			var status libTypes.HlrStatus
-			status, err = suppliers.StatusHlr(hlrresponse.Ids[i])
+	err = PollDelivery(order_id, models.DELIVERY_REPORT_TYPE_HLR, hlrresponse.Ids, deadline, deliveryreportrepository,
+		func(id gocql.UUID) (final bool, err error) {
+			// Получение статуса по UUID запроса
+			status, err := gateway.StatusHlr(id)
 			if err != nil {
 				log.Error("Can't get HLR statuses %v", err)
-				return map[gocql.UUID]libTypes.HlrStatus{}, err
+				return false, err
 			}
 			if status.Final {
 				hlrstatuses[status.Id] = status
-			} else {
-				final = false
 			}
-		}
-		if final {
-			break
-		}
-		time.Sleep(time.Second)
+			return status.Final, nil
+		},
+		func(id gocql.UUID, dtodeliveryreport *models.DtoDeliveryReport) {
+			var status libTypes.HlrStatus
+			FillDeliveryStatus(&status, id, dtodeliveryreport)
+			hlrstatuses[id] = status
+		})
+	if err != nil {
+		return map[gocql.UUID]libTypes.HlrStatus{}, err
 	}
 
 	return hlrstatuses, nil
@@ -159,112 +165,105 @@ func (hlrworkflow *HLRWorkflow) GetHLRTableColumns(dtohlrfacility *models.DtoHLR
 	return columnmobilephone, tablecolumns, nil
 }
 
-func (hlrworkflow *HLRWorkflow) CalculateCost(apitablerows *[]models.ApiInfoTableRow, columnmobilephone *models.DtoTableColumn,
-	dtoorder *models.DtoOrder, dtohlrfacility *models.DtoHLRFacility) (cost float64, err error) {
-	dtomobileoperators, err := hlrworkflow.MobileOperatorRepository.FindAll()
+func (hlrworkflow *HLRWorkflow) CalculateCost(apitablerows *[]models.ApiInfoTableRow, plan *HLRPlan,
+	dtoorder *models.DtoOrder, dtohlrfacility *models.DtoHLRFacility) (cost float64, savings float64, err error) {
+	quote, err := hlrworkflow.QuoteRows(apitablerows, plan, dtoorder, dtohlrfacility)
 	if err != nil {
-		return 0, err
-	}
-	mobileoperators_uuid := make(map[string]*models.DtoMobileOperator)
-	mobileoperators_id := make(map[int]*models.DtoMobileOperator)
-	for index := range *dtomobileoperators {
-		mobileoperators_uuid[(*dtomobileoperators)[index].UUID] = &(*dtomobileoperators)[index]
-		mobileoperators_id[(*dtomobileoperators)[index].ID] = &(*dtomobileoperators)[index]
+		return 0, 0, err
 	}
-	dtocolumntype, err := hlrworkflow.ColumnTypeRepository.Get(columnmobilephone.Column_Type_ID)
-	if err != nil {
-		return 0, err
+	if len(quote.Unpriced) != 0 {
+		log.Error("Can't calculate cost of %v rows for order %v, first reason %v", len(quote.Unpriced), dtoorder.ID, quote.Unpriced[0].Reason)
+		return 0, 0, errors.New("Unpriced rows")
 	}
 
-	mobilephones := []uint64{}
-	for _, apitablerow := range *apitablerows {
-		var mobilephone uint64 = 0
-		for _, apitablecell := range apitablerow.Cells {
-			if apitablecell.Table_Column_ID == columnmobilephone.ID {
-				mobilephone, err = helpers.CheckMobilePhone(apitablecell.Value, dtocolumntype, hlrworkflow.ColumnTypeRepository)
-				if err != nil {
-					return 0, err
-				}
+	return quote.Cost, quote.Savings, nil
+}
+
+// Расчет стоимости строк таблицы по мобильным операторам. Тарифицируются только номера, отправляемые поставщику,
+// экономия считается как разница со стоимостью всех строк таблицы
+func (hlrworkflow *HLRWorkflow) QuoteRows(apitablerows *[]models.ApiInfoTableRow, plan *HLRPlan,
+	dtoorder *models.DtoOrder, dtohlrfacility *models.DtoHLRFacility) (quote *models.ApiOrderQuote, err error) {
+	quote = models.NewApiOrderQuote(models.SERVICE_TYPE_HLR, int64(len(*apitablerows)))
+	allrecipients := []models.DtoQuoteRecipient{}
+	recipients := []models.DtoQuoteRecipient{}
+	found := make(map[uint64]bool)
+	for index, apitablerow := range *apitablerows {
+		recipient := models.NewDtoQuoteRecipient(apitablerow.ID, plan.Values[index], plan.MobilePhones[index], "", 1, "")
+		if recipient.MobilePhone == 0 {
+			recipient.Reason = models.QUOTE_REASON_WRONG_MOBILE_PHONE
+		}
+		allrecipients = append(allrecipients, *recipient)
+		if recipient.Reason == "" {
+			if _, ok := plan.Cached[recipient.MobilePhone]; ok {
+				quote.AddCached(recipient.Row_ID, recipient.Value)
+				continue
+			}
+			if found[recipient.MobilePhone] {
+				quote.AddDuplicate(recipient.Row_ID, recipient.Value)
+				continue
 			}
+			found[recipient.MobilePhone] = true
 		}
-		mobilephones = append(mobilephones, mobilephone)
+		recipients = append(recipients, *recipient)
 	}
 
-	mobileoperatoruuids, err := suppliers.MobileOperator(mobilephones)
+	err = QuoteMobileOperators(quote, recipients, dtoorder.Supplier_ID, hlrworkflow.UnitRepository, hlrworkflow.PriceRepository,
+		hlrworkflow.TableColumnRepository, hlrworkflow.TableRowRepository, hlrworkflow.MobileOperatorRepository)
 	if err != nil {
-		log.Error("Error during detecting mobile operators %v", err)
-		return 0, errors.New("Mobile operator detection error")
-	}
-	defaultmobileoperator, err := hlrworkflow.MobileOperatorRepository.GetDefault()
-	if err != nil {
-		return 0, err
+		return nil, err
 	}
 
-	mobileoperatorhlres := make(map[int]int)
-	for index := range mobileoperatoruuids {
-		if mobileoperatoruuids[index].Id.String() == models.MOBILE_OPERATOR_UUID_UNKNOWN {
-			log.Error("Not existed mobile operator for phone %v", mobilephones[index])
-			return 0, errors.New("Not existed mobile operator")
-		}
-		if mobileoperatoruuids[index].Id.String() == models.MOBILE_OPERATOR_UUID_MEGAFON {
-			log.Error("Not HLR enabled mobile operator for phone %v", mobilephones[index])
-			return 0, errors.New("Not HLR enabled mobile operator")
+	if len(quote.Duplicates) != 0 || len(quote.Cached) != 0 {
+		fullquote := models.NewApiOrderQuote(models.SERVICE_TYPE_HLR, int64(len(*apitablerows)))
+		err = QuoteMobileOperators(fullquote, allrecipients, dtoorder.Supplier_ID, hlrworkflow.UnitRepository, hlrworkflow.PriceRepository,
+			hlrworkflow.TableColumnRepository, hlrworkflow.TableRowRepository, hlrworkflow.MobileOperatorRepository)
+		if err != nil {
+			return nil, err
 		}
-		mobileoperator, ok := mobileoperators_uuid[mobileoperatoruuids[index].Id.String()]
-		if !ok {
-			mobileoperator = defaultmobileoperator
+		if fullquote.Cost > quote.Cost {
+			quote.Savings = models.Round(fullquote.Cost-quote.Cost, 0.5, 2)
 		}
-		mobileoperatorhlres[mobileoperator.ID]++
 	}
 
-	smshlrprices, err := helpers.GetSMSHLRPrices(models.SERVICE_TYPE_HLR, dtoorder.Supplier_ID, nil, hlrworkflow.PriceRepository,
-		hlrworkflow.TableColumnRepository, hlrworkflow.TableRowRepository,
-		hlrworkflow.MobileOperatorRepository, "", true)
+	return quote, nil
+}
+
+// Предварительный расчет стоимости заказа по всем строкам таблицы без изменения состояния заказа
+func (hlrworkflow *HLRWorkflow) Quote(dtoorder *models.DtoOrder) (quote *models.ApiOrderQuote, err error) {
+	dtohlrfacility, err := hlrworkflow.HLRFacilityRepository.Get(dtoorder.ID)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-
-	pricemobileoperators := make(map[int]int)
-	for _, smshlrprice := range *smshlrprices {
-		pricemobileoperators[smshlrprice.Mobile_Operator_ID] = smshlrprice.Mobile_Operator_ID
+	columnmobilephone_id, err := hlrworkflow.CheckHLROrder(dtohlrfacility)
+	if err != nil {
+		return nil, err
 	}
-
-	cost = 0
-	for mobileoperator_id, count := range mobileoperatorhlres {
-		pricemobileoperator_id, ok := pricemobileoperators[mobileoperator_id]
-		if !ok {
-			pricemobileoperator_id, ok = pricemobileoperators[defaultmobileoperator.ID]
-			if !ok {
-				log.Error("Can't find default mobile operator %v in price list for supplier %v", defaultmobileoperator.ID, dtoorder.Supplier_ID)
-				return 0, errors.New("Missed default mobile operator in price list")
-			}
-		}
-
-		found := false
-		if mobileoperators_id[pricemobileoperator_id].HLRBillingModel == models.BILLING_MODEL_RANGE {
-			for _, smshlrprice := range *smshlrprices {
-				if smshlrprice.Mobile_Operator_ID == pricemobileoperator_id &&
-					count >= smshlrprice.AmountRange.Begin && (count <= smshlrprice.AmountRange.End || smshlrprice.AmountRange.End == 0) {
-					cost += float64(count) * smshlrprice.Price
-					found = true
-					break
-				}
-			}
-		}
-		if !found {
-			log.Error("Can't find  mobile operator %v in price list for supplier %v", pricemobileoperator_id, dtoorder.Supplier_ID)
-			return 0, errors.New("Missed obile operator in price list")
-		}
+	columnmobilephone, tablecolumns, err := hlrworkflow.GetHLRTableColumns(dtohlrfacility, columnmobilephone_id)
+	if err != nil {
+		return nil, err
+	}
+	apitablerows, err := hlrworkflow.TableRowRepository.GetAll("", "", dtohlrfacility.DeliveryDataId, tablecolumns)
+	if err != nil {
+		return nil, err
+	}
+	plan, err := hlrworkflow.PlanHLR(apitablerows, columnmobilephone, dtoorder.Unit_ID)
+	if err != nil {
+		return nil, err
+	}
+	quote, err = hlrworkflow.QuoteRows(apitablerows, plan, dtoorder, dtohlrfacility)
+	if err != nil {
+		return nil, err
+	}
+	err = QuoteVAT(quote, dtoorder.Unit_ID, hlrworkflow.CompanyRepository)
+	if err != nil {
+		return nil, err
 	}
 
-	return cost, nil
+	return quote, nil
 }
 
-func (hlrworkflow *HLRWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtohlrfacility *models.DtoHLRFacility, balance float64) (err error) {
-	if balance < dtohlrfacility.Cost {
-		log.Error("Not enough money at unit balance %v to pay for order %v execution", dtoorder.Unit_ID, dtoorder.ID)
-		return errors.New("Not enough money")
-	}
+// Оплата заказа и выставление счета. Экономия на повторных и ранее проверенных номерах показывается в счете скидкой
+func (hlrworkflow *HLRWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtohlrfacility *models.DtoHLRFacility, savings float64) (err error) {
 	dtocompany, err := hlrworkflow.CompanyRepository.GetPrimaryByUnit(dtoorder.Unit_ID)
 	if err != nil {
 		return
@@ -286,14 +285,20 @@ func (hlrworkflow *HLRWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtohlrf
 	dtoinvoice := new(models.DtoInvoice)
 	dtoinvoice.Company_ID = dtocompany.ID
 	if dtocompany.VAT != 0 {
-		dtoinvoice.VAT = (dtohlrfacility.Cost / (1 + float64(dtocompany.VAT)/100)) * float64(dtocompany.VAT) / 100
+		dtoinvoice.VAT = (dtohlrfacility.CostFactual / (1 + float64(dtocompany.VAT)/100)) * float64(dtocompany.VAT) / 100
 	}
-	dtoinvoice.Total = dtohlrfacility.Cost
+	dtoinvoice.Total = dtohlrfacility.CostFactual
 	dtoinvoice.Paid = true
 	dtoinvoice.Created = time.Now()
 	dtoinvoice.Active = true
 	dtoinvoice.InvoiceItems = []models.DtoInvoiceItem{*models.NewDtoInvoiceItem(0, 0, dtotransactiontype.Name, models.INVOICE_ITEM_TYPE_ROUBLE,
 		1, dtoinvoice.Total, dtoinvoice.Total)}
+	if savings > 0 {
+		dtoinvoice.InvoiceItems = []models.DtoInvoiceItem{
+			*models.NewDtoInvoiceItem(0, 0, dtotransactiontype.Name, models.INVOICE_ITEM_TYPE_ROUBLE, 1, dtoinvoice.Total+savings,
+				dtoinvoice.Total+savings),
+			*models.NewDtoInvoiceItem(0, 0, models.INVOICE_ITEM_NAME_SAVINGS, models.INVOICE_ITEM_TYPE_ROUBLE, 1, -savings, -savings)}
+	}
 	dtoinvoice.PaidAt = time.Now()
 
 	err = hlrworkflow.InvoiceRepository.PayForOrder(dtoorder, dtoinvoice, dtotransaction, true)
@@ -304,36 +309,61 @@ func (hlrworkflow *HLRWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtohlrf
 	return nil
 }
 
-func (hlrworkflow *HLRWorkflow) SendHLR(apitablerows *[]models.ApiInfoTableRow, dtoorder *models.DtoOrder, dtohlrfacility *models.DtoHLRFacility,
-	columnmobilephone *models.DtoTableColumn) (hlrresponse *libTypes.HlrResponse, err error) {
-	dtosupplier, err := hlrworkflow.UnitRepository.Get(dtoorder.Supplier_ID)
+// Оплата фактической стоимости заказа при закрытии. Тарифицируются только номера, принятые поставщиком,
+// остаток резерва снимается
+func (hlrworkflow *HLRWorkflow) Capture(dtoorder *models.DtoOrder, dtohlrfacility *models.DtoHLRFacility,
+	apitablerows *[]models.ApiInfoTableRow, plan *HLRPlan, fullresponse *libTypes.HlrResponse) (err error) {
+	accepted := new([]models.ApiInfoTableRow)
+	acceptedplan := &HLRPlan{Requests: plan.Requests, Cached: plan.Cached}
+	for index, apitablerow := range *apitablerows {
+		if plan.MobilePhones[index] != 0 && index < len(fullresponse.Errors) && fullresponse.Errors[index] == nil {
+			*accepted = append(*accepted, apitablerow)
+			acceptedplan.Values = append(acceptedplan.Values, plan.Values[index])
+			acceptedplan.MobilePhones = append(acceptedplan.MobilePhones, plan.MobilePhones[index])
+		}
+	}
+	savings := float64(0)
+	dtohlrfacility.CostFactual = 0
+	if len(*accepted) != 0 {
+		dtohlrfacility.CostFactual, savings, err = hlrworkflow.CalculateCost(accepted, acceptedplan, dtoorder, dtohlrfacility)
+		if err != nil {
+			return err
+		}
+	}
+	err = hlrworkflow.HLRFacilityRepository.Update(dtohlrfacility, true, false)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	hlrsupplier, err := GetSupplier(dtosupplier.UUID)
+	if dtohlrfacility.CostFactual == 0 {
+		log.Info("Nothing has been checked for order %v, releasing held cost", dtoorder.ID)
+		return hlrworkflow.LedgerRepository.Release(dtoorder.ID)
+	}
+
+	return hlrworkflow.PayAndInvoice(dtoorder, dtohlrfacility, savings)
+}
+
+// Отправка поставщику уникальных номеров заказа, результат по которым отсутствует в кэше
+func (hlrworkflow *HLRWorkflow) SendHLR(plan *HLRPlan, dtoorder *models.DtoOrder) (hlrresponse *libTypes.HlrResponse, err error) {
+	if len(plan.Requests) == 0 {
+		return &libTypes.HlrResponse{Ids: []gocql.UUID{}, Errors: []error{}}, nil
+	}
+	dtosupplier, err := hlrworkflow.UnitRepository.Get(dtoorder.Supplier_ID)
 	if err != nil {
 		return nil, err
 	}
-
-	dtocolumntype, err := hlrworkflow.ColumnTypeRepository.Get(columnmobilephone.Column_Type_ID)
+	gateway := gateways.Get(dtosupplier.UUID)
+	hlrsupplier, err := gateway.Supplier(dtosupplier.UUID)
 	if err != nil {
 		return nil, err
 	}
 
 	hlr := new([]libTypes.Hlr)
-	for _, apitablerow := range *apitablerows {
+	for _, mobilephone := range plan.Requests {
 		obj := new(libTypes.Hlr)
-		for _, apitablecell := range apitablerow.Cells {
-			if apitablecell.Table_Column_ID == columnmobilephone.ID {
-				obj.Recipient, err = helpers.CheckMobilePhone(apitablecell.Value, dtocolumntype, hlrworkflow.ColumnTypeRepository)
-				if err != nil {
-					return nil, err
-				}
-			}
-		}
+		obj.Recipient = mobilephone
 		*hlr = append(*hlr, *obj)
 	}
-	hlrresponse, err = SendHLR(hlrsupplier, hlr)
+	hlrresponse, err = SendHLR(gateway, hlrsupplier, hlr)
 	if err != nil {
 		return nil, err
 	}
@@ -423,7 +453,9 @@ func (hlrworkflow *HLRWorkflow) SaveHLR(dtoworkdatatable *models.DtoCustomerTabl
 		for i, _ := range *tablecells {
 			for j, _ := range tablecolumns {
 				FillTableCell(&(*tablecells)[i], &tablecolumns[j], COLUMN_NAME_HLR_ID, hlrresponse.Ids[index].String())
-				FillTableCell(&(*tablecells)[i], &tablecolumns[j], COLUMN_NAME_HLR_ERROR, hlrresponse.Errors[index].Error())
+				if hlrresponse.Errors[index] != nil {
+					FillTableCell(&(*tablecells)[i], &tablecolumns[j], COLUMN_NAME_HLR_ERROR, hlrresponse.Errors[index].Error())
+				}
 			}
 		}
 
@@ -674,13 +706,19 @@ func (hlrworkflow *HLRWorkflow) ExecuteOrder(order_id int64) {
 			_ = hlrworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
+		log.Info("Checking duplicated and cached numbers ...")
+		plan, err := hlrworkflow.PlanHLR(apitablerows, columnmobilephone, dtoorder.Unit_ID)
+		if err != nil {
+			_ = hlrworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+			return
+		}
 		log.Info("Calculating order cost ...")
-		dtohlrfacility.Cost, err = hlrworkflow.CalculateCost(apitablerows, columnmobilephone, dtoorder, dtohlrfacility)
+		cost, _, err := hlrworkflow.CalculateCost(apitablerows, plan, dtoorder, dtohlrfacility)
 		if err != nil {
 			_ = hlrworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-		dtohlrfacility.CostFactual = dtohlrfacility.Cost
+		dtohlrfacility.Cost = cost
 		err = hlrworkflow.HLRFacilityRepository.Update(dtohlrfacility, true, false)
 		if err != nil {
 			_ = hlrworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
@@ -692,46 +730,59 @@ func (hlrworkflow *HLRWorkflow) ExecuteOrder(order_id int64) {
 			_ = hlrworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
+		log.Info("Holding order cost ...")
+		_, err = hlrworkflow.LedgerRepository.Hold(dtoorder.Unit_ID, dtoorder.ID, dtohlrfacility.Cost)
+		if err != nil {
+			_ = hlrworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+			return
+		}
 		log.Info("Start order processing ...")
 		/* 5 */ dtoorder.Begin_Date = time.Now()
 		err = hlrworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
 			*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_MODERATOR_BEGIN, true, "", time.Now())}, nil, true)
 		if err != nil {
+			_ = hlrworkflow.LedgerRepository.Release(dtoorder.ID)
 			_ = hlrworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-		log.Info("Calculating unit balance ...")
-		balance, err := hlrworkflow.OperationRepository.CalculateBalance(dtoorder.Unit_ID)
+		log.Info("Copying table data ...")
+		/* 7 */ dtoworkdatatable, err := hlrworkflow.CopyData(dtoorder, dtodatatable)
 		if err != nil {
-			_ = hlrworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-		log.Info("Order payment and invoicement ...")
-		/* 6 */ err = hlrworkflow.PayAndInvoice(dtoorder, dtohlrfacility, balance)
+		log.Info("Sending data to supplier ...")
+		/* 8 */ hlrresponse, err := hlrworkflow.SendHLR(plan, dtoorder)
 		if err != nil {
-			_ = hlrworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-		log.Info("Copying table data ...")
-		/* 7 */ dtoworkdatatable, err := hlrworkflow.CopyData(dtoorder, dtodatatable)
+		log.Info("Saving supplier response ...")
+		fullresponse, cachedstatuses, err := ExpandHLRResponse(plan, hlrresponse)
 		if err != nil {
 			return
 		}
-		log.Info("Sending data to supplier ...")
-		/* 8 */ hlrresponse, err := hlrworkflow.SendHLR(apitablerows, dtoorder, dtohlrfacility, columnmobilephone)
+		/* 9 */ err = hlrworkflow.SaveHLR(dtoworkdatatable, fullresponse)
 		if err != nil {
 			return
 		}
-		log.Info("Saving supplier response ...")
-		/* 9 */ err = hlrworkflow.SaveHLR(dtoworkdatatable, hlrresponse)
+		err = RegisterDelivery(dtoorder.ID, models.DELIVERY_REPORT_TYPE_HLR, hlrresponse.Ids, nil, nil, dtoorder.Supplier_ID,
+			hlrworkflow.DeliveryReportRepository)
 		if err != nil {
 			return
 		}
 		log.Info("Getting supplier results ...")
-		/* 10 */ hlrstatuses, err := GetHLRStatus(hlrresponse)
+		dtosupplier, err := hlrworkflow.UnitRepository.Get(dtoorder.Supplier_ID)
 		if err != nil {
 			return
 		}
+		/* 10 */ hlrstatuses, err := GetHLRStatus(gateways.Get(dtosupplier.UUID), hlrresponse, dtoorder.ID, DeliveryDeadline(),
+			hlrworkflow.DeliveryReportRepository)
+		if err != nil {
+			return
+		}
+		_ = hlrworkflow.SaveHLRCache(dtoorder.Unit_ID, plan, hlrresponse, hlrstatuses)
+		for id, status := range cachedstatuses {
+			hlrstatuses[id] = status
+		}
 		log.Info("Saving supplier results ...")
 		/* 11 */ err = hlrworkflow.SaveHLRStatus(dtoworkdatatable, hlrstatuses)
 		if err != nil {
@@ -742,8 +793,13 @@ func (hlrworkflow *HLRWorkflow) ExecuteOrder(order_id int64) {
 		if err != nil {
 			return
 		}
+		log.Info("Order payment and invoicement ...")
+		/* 13 */ err = hlrworkflow.Capture(dtoorder, dtohlrfacility, apitablerows, plan, fullresponse)
+		if err != nil {
+			return
+		}
 		log.Info("Completing order execution %v", time.Now())
-		/* 13 */ dtoorder.End_Date = time.Now()
+		dtoorder.End_Date = time.Now()
 		err = hlrworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
 			*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_SUPPLIER_CLOSE, true, "", time.Now())}, nil, true)
 		if err != nil {
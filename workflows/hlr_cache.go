@@ -0,0 +1,163 @@
+package workflows
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	libTypes "lib/suppliers/types"
+	"reflect"
+	"strconv"
+	"time"
+)
+
+const (
+	HLR_CACHE_TTL = 24 * time.Hour
+)
+
+// План hlr запросов заказа: повторяющиеся номера и номера с действующим результатом в кэше поставщику не отправляются
+type HLRPlan struct {
+	Values       []string                      // Значения номеров в строках таблицы
+	MobilePhones []uint64                      // Проверенные номера строк таблицы, 0 - неверный номер
+	Requests     []uint64                      // Уникальные номера, отправляемые поставщику
+	Cached       map[uint64]models.DtoHLRCache // Действующие результаты по номерам из кэша объединения
+}
+
+func HLRCacheTTL() time.Duration {
+	if config.Configuration.HLR.CacheTTL > 0 {
+		return config.Configuration.HLR.CacheTTL
+	}
+	return HLR_CACHE_TTL
+}
+
+// Пустое значение ошибки в статусе поставщика
+func IsEmptyStatusError(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	return reflect.DeepEqual(value, reflect.Zero(reflect.TypeOf(value)).Interface())
+}
+
+func (hlrworkflow *HLRWorkflow) PlanHLR(apitablerows *[]models.ApiInfoTableRow, columnmobilephone *models.DtoTableColumn,
+	unit_id int64) (plan *HLRPlan, err error) {
+	dtocolumntype, err := hlrworkflow.ColumnTypeRepository.Get(columnmobilephone.Column_Type_ID)
+	if err != nil {
+		return nil, err
+	}
+
+	plan = &HLRPlan{Values: []string{}, MobilePhones: []uint64{}, Requests: []uint64{}}
+	unique := []uint64{}
+	found := make(map[uint64]bool)
+	for _, apitablerow := range *apitablerows {
+		value := ""
+		var mobilephone uint64
+		for _, apitablecell := range apitablerow.Cells {
+			if apitablecell.Table_Column_ID == columnmobilephone.ID {
+				value = apitablecell.Value
+				var checkerr error
+				mobilephone, checkerr = helpers.CheckMobilePhone(apitablecell.Value, dtocolumntype, hlrworkflow.ColumnTypeRepository)
+				if checkerr != nil {
+					mobilephone = 0
+				}
+			}
+		}
+		plan.Values = append(plan.Values, value)
+		plan.MobilePhones = append(plan.MobilePhones, mobilephone)
+		if mobilephone != 0 && !found[mobilephone] {
+			found[mobilephone] = true
+			unique = append(unique, mobilephone)
+		}
+	}
+
+	plan.Cached, err = hlrworkflow.HLRCacheRepository.FindValid(unit_id, unique, time.Now().Add(-HLRCacheTTL()))
+	if err != nil {
+		return nil, err
+	}
+	for _, mobilephone := range unique {
+		if _, ok := plan.Cached[mobilephone]; !ok {
+			plan.Requests = append(plan.Requests, mobilephone)
+		}
+	}
+
+	return plan, nil
+}
+
+// Ответ поставщика по всем строкам таблицы. Повторяющиеся номера получают идентификатор отправленного запроса,
+// номера из кэша - новый идентификатор, статус по которому берется из кэша
+func ExpandHLRResponse(plan *HLRPlan, hlrresponse *libTypes.HlrResponse) (fullresponse *libTypes.HlrResponse,
+	cachedstatuses map[gocql.UUID]libTypes.HlrStatus, err error) {
+	if len(hlrresponse.Ids) != len(plan.Requests) {
+		log.Error("HLR response size %v doesn't match sent requests %v", len(hlrresponse.Ids), len(plan.Requests))
+		return nil, nil, errors.New("Wrong hlr response size")
+	}
+	requests := make(map[uint64]int)
+	for k, mobilephone := range plan.Requests {
+		requests[mobilephone] = k
+	}
+
+	fullresponse = new(libTypes.HlrResponse)
+	fullresponse.Ids = make([]gocql.UUID, len(plan.MobilePhones))
+	fullresponse.Errors = make([]error, len(plan.MobilePhones))
+	cachedstatuses = make(map[gocql.UUID]libTypes.HlrStatus)
+	cachedids := make(map[uint64]gocql.UUID)
+	for index, mobilephone := range plan.MobilePhones {
+		if k, ok := requests[mobilephone]; ok {
+			fullresponse.Ids[index] = hlrresponse.Ids[k]
+			if k < len(hlrresponse.Errors) {
+				fullresponse.Errors[index] = hlrresponse.Errors[k]
+			}
+			continue
+		}
+		dtohlrcache, ok := plan.Cached[mobilephone]
+		if !ok {
+			continue
+		}
+		id, ok := cachedids[mobilephone]
+		if !ok {
+			id, err = gocql.RandomUUID()
+			if err != nil {
+				log.Error("Can't generate hlr id %v", err)
+				return nil, nil, err
+			}
+			cachedids[mobilephone] = id
+			var status libTypes.HlrStatus
+			FillDeliveryStatus(&status, id, models.NewDtoDeliveryReport(id.String(), 0, 0, models.DELIVERY_REPORT_TYPE_HLR,
+				dtohlrcache.Status, "", dtohlrcache.Orn, dtohlrcache.Roaming, true, dtohlrcache.Created, dtohlrcache.Created))
+			cachedstatuses[id] = status
+		}
+		fullresponse.Ids[index] = id
+	}
+
+	return fullresponse, cachedstatuses, nil
+}
+
+// Сохранение в кэш объединения окончательных статусов отправленных запросов, полученных без ошибки
+func (hlrworkflow *HLRWorkflow) SaveHLRCache(unit_id int64, plan *HLRPlan, hlrresponse *libTypes.HlrResponse,
+	hlrstatuses map[gocql.UUID]libTypes.HlrStatus) (err error) {
+	dtohlrcaches := []models.DtoHLRCache{}
+	for k, mobilephone := range plan.Requests {
+		if k >= len(hlrresponse.Ids) {
+			break
+		}
+		if k < len(hlrresponse.Errors) && hlrresponse.Errors[k] != nil {
+			continue
+		}
+		status, ok := hlrstatuses[hlrresponse.Ids[k]]
+		if !ok || !IsEmptyStatusError(status.Error) || fmt.Sprintf("%v", status.Status) == models.DELIVERY_STATUS_EXPIRED {
+			continue
+		}
+		roaming, _ := strconv.ParseBool(fmt.Sprintf("%v", status.ResponseIsRoaming))
+		dtohlrcaches = append(dtohlrcaches, *models.NewDtoHLRCache(unit_id, mobilephone, fmt.Sprintf("%v", status.Status),
+			fmt.Sprintf("%v", status.ResponseOrn), roaming, time.Now()))
+	}
+	if len(dtohlrcaches) != 0 {
+		err = hlrworkflow.HLRCacheRepository.SaveAll(&dtohlrcaches)
+		if err != nil {
+			return err
+		}
+	}
+
+	return hlrworkflow.HLRCacheRepository.DeleteExpired(unit_id, time.Now().Add(-HLRCacheTTL()))
+}
@@ -0,0 +1,60 @@
+package workflows
+
+import (
+	"application/models"
+	"errors"
+	"fmt"
+	"github.com/gocql/gocql"
+	libTypes "lib/suppliers/types"
+	"testing"
+	"time"
+)
+
+func TestExpandHLRResponse(t *testing.T) {
+	var id1, _ = gocql.RandomUUID()
+	var id2, _ = gocql.RandomUUID()
+	var senderr = errors.New("Wrong number")
+	var plan = &HLRPlan{
+		MobilePhones: []uint64{79161111111, 0, 79162222222, 79161111111, 79163333333, 79163333333},
+		Requests:     []uint64{79161111111, 79162222222},
+		Cached: map[uint64]models.DtoHLRCache{
+			79163333333: *models.NewDtoHLRCache(1, 79163333333, "delivered", "MTS", true, time.Now()),
+		},
+	}
+	var hlrresponse = &libTypes.HlrResponse{Ids: []gocql.UUID{id1, id2}, Errors: []error{nil, senderr}}
+
+	fullresponse, cachedstatuses, err := ExpandHLRResponse(plan, hlrresponse)
+	if err != nil {
+		t.Error("Response is not properly expanded", err)
+		return
+	}
+	if len(fullresponse.Ids) != len(plan.MobilePhones) || len(fullresponse.Errors) != len(plan.MobilePhones) {
+		t.Error("Response size is not properly initialized")
+		return
+	}
+	if fullresponse.Ids[0] != id1 || fullresponse.Ids[3] != id1 || fullresponse.Ids[2] != id2 {
+		t.Error("Ids of sent requests are not properly assigned")
+	}
+	if fullresponse.Errors[0] != nil || fullresponse.Errors[3] != nil || fullresponse.Errors[2] != senderr {
+		t.Error("Errors of sent requests are not properly assigned")
+	}
+	if fullresponse.Ids[1] != (gocql.UUID{}) {
+		t.Error("Wrong number is not properly skipped")
+	}
+	cachedid := fullresponse.Ids[4]
+	if cachedid == (gocql.UUID{}) || cachedid == id1 || cachedid == id2 || fullresponse.Ids[5] != cachedid {
+		t.Error("Id of cached result is not properly generated")
+	}
+	status, ok := cachedstatuses[cachedid]
+	if len(cachedstatuses) != 1 || !ok {
+		t.Error("Cached statuses are not properly initialized")
+		return
+	}
+	if fmt.Sprintf("%v", status.Status) != "delivered" || fmt.Sprintf("%v", status.ResponseOrn) != "MTS" {
+		t.Error("Cached status is not properly filled")
+	}
+
+	if _, _, err = ExpandHLRResponse(plan, &libTypes.HlrResponse{Ids: []gocql.UUID{id1}}); err == nil {
+		t.Error("Response of wrong size is not properly rejected")
+	}
+}
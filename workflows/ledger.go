@@ -0,0 +1,71 @@
+package workflows
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type LedgerWorkflow struct {
+	LedgerRepository services.LedgerRepository
+	EmailRepository  services.EmailRepository
+	alerted          map[int64]float64
+}
+
+func NewLedgerWorkflow(ledgerrepository services.LedgerRepository, emailrepository services.EmailRepository) *LedgerWorkflow {
+	return &LedgerWorkflow{
+		LedgerRepository: ledgerrepository,
+		EmailRepository:  emailrepository,
+		alerted:          make(map[int64]float64),
+	}
+}
+
+// Периодическое снятие просроченных резервов и сверка остатков счетов с журналом операций
+func (ledgerworkflow *LedgerWorkflow) Reconcile() {
+	for {
+		if config.Configuration.Ledger.HoldTimeout > 0 {
+			_ = ledgerworkflow.LedgerRepository.ReleaseExpired(config.Configuration.Ledger.HoldTimeout)
+		}
+		drifted, err := ledgerworkflow.LedgerRepository.Reconcile()
+		if err == nil {
+			_ = ledgerworkflow.Alert(drifted)
+		}
+		interval := config.Configuration.Ledger.ReconcileInterval
+		if interval <= 0 {
+			interval = time.Minute
+		}
+		time.Sleep(interval)
+	}
+}
+
+// Оповещение службы поддержки о расхождениях остатков счетов с журналом операций. О неизменившемся расхождении
+// повторно не сообщается
+func (ledgerworkflow *LedgerWorkflow) Alert(drifted *[]models.DtoLedgerAccount) (err error) {
+	current := make(map[int64]float64)
+	lines := []string{}
+	for _, account := range *drifted {
+		current[account.Unit_ID] = account.Drift
+		if drift, ok := ledgerworkflow.alerted[account.Unit_ID]; ok && drift == account.Drift {
+			continue
+		}
+		lines = append(lines, fmt.Sprintf("%v: %v (%+v)", account.Unit_ID, account.Balance, account.Drift))
+	}
+	if len(lines) == 0 || ledgerworkflow.EmailRepository == nil || config.Configuration.Mail.Receiver == "" {
+		ledgerworkflow.alerted = current
+		return nil
+	}
+
+	language := config.Configuration.Server.DefaultLanguage
+	err = ledgerworkflow.EmailRepository.SendHTML(config.Configuration.Mail.Receiver, config.Localization[language].Messages.LedgerDrift,
+		strings.Join(lines, "<br>"), "", config.Configuration.Mail.Sender)
+	if err != nil {
+		log.Error("Can't send ledger drift alert %v", err)
+		return err
+	}
+	ledgerworkflow.alerted = current
+
+	return nil
+}
@@ -1,52 +1,328 @@
 package workflows
 
 import (
+	"application/config"
 	"application/models"
 	"application/services"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
 	"time"
 )
 
+const (
+	JOB_POLL_INTERVAL = time.Minute
+	JOB_LEASE_TIMEOUT = 10 * time.Minute
+	JOB_RETRY_DELAY   = 5 * time.Minute
+	JOB_MAX_ATTEMPTS  = 3
+	JOB_WORKERS       = 1
+)
+
+// Признаки потери аренды заданий, исполняемых этим процессом. Канал закрывается при потере аренды
+var (
+	leaseMutex sync.Mutex
+	leaseLost  = make(map[int64]chan bool)
+)
+
+// Проверка потери аренды задания заказа. Исполнитель прекращает работу на ближайшей контрольной точке
+func IsLeaseLost(order_id int64) bool {
+	leaseMutex.Lock()
+	lost, ok := leaseLost[order_id]
+	leaseMutex.Unlock()
+	if !ok {
+		return false
+	}
+	select {
+	case <-lost:
+		return true
+	default:
+		return false
+	}
+}
+
 type OrderWorkflow struct {
-	OrderRepository    services.OrderRepository
-	FacilityRepository services.FacilityRepository
-	HeaderWorkflow     Executor
-	SMSWorkflow        Executor
-	HLRWorkflow        Executor
-	VerifyWorkflow     Executor
+	OrderRepository       services.OrderRepository
+	FacilityRepository    services.FacilityRepository
+	OrderStatusRepository services.OrderStatusRepository
+	JobRepository         services.JobRepository
+	LedgerRepository      services.LedgerRepository
+	HeaderWorkflow        Executor
+	SMSWorkflow           Executor
+	HLRWorkflow           Executor
+	VerifyWorkflow        Executor
+	RecognizeWorkflow     Executor
+	Owner                 string
 }
 
 func NewOrderWorkflow(orderrepository services.OrderRepository, facilityrepository services.FacilityRepository,
-	headerworkflow, smsworkflow, hlrworkflow, verifyworkflow Executor) *OrderWorkflow {
+	orderstatusrepository services.OrderStatusRepository, jobrepository services.JobRepository, ledgerrepository services.LedgerRepository,
+	headerworkflow, smsworkflow, hlrworkflow, verifyworkflow, recognizeworkflow Executor) *OrderWorkflow {
+	hostname, err := os.Hostname()
+	if err != nil {
+		hostname = "localhost"
+	}
 	return &OrderWorkflow{
-		OrderRepository:    orderrepository,
-		FacilityRepository: facilityrepository,
-		HeaderWorkflow:     headerworkflow,
-		SMSWorkflow:        smsworkflow,
-		HLRWorkflow:        hlrworkflow,
-		VerifyWorkflow:     verifyworkflow,
+		OrderRepository:       orderrepository,
+		FacilityRepository:    facilityrepository,
+		OrderStatusRepository: orderstatusrepository,
+		JobRepository:         jobrepository,
+		LedgerRepository:      ledgerrepository,
+		HeaderWorkflow:        headerworkflow,
+		SMSWorkflow:           smsworkflow,
+		HLRWorkflow:           hlrworkflow,
+		VerifyWorkflow:        verifyworkflow,
+		RecognizeWorkflow:     recognizeworkflow,
+		Owner:                 fmt.Sprintf("%v:%v", hostname, os.Getpid()),
+	}
+}
+
+// Исполнители заказов по псевдониму услуги
+func (orderworkflow *OrderWorkflow) Executors() map[string]Executor {
+	return map[string]Executor{
+		models.SERVICE_TYPE_HEADER:    orderworkflow.HeaderWorkflow,
+		models.SERVICE_TYPE_SMS:       orderworkflow.SMSWorkflow,
+		models.SERVICE_TYPE_HLR:       orderworkflow.HLRWorkflow,
+		models.SERVICE_TYPE_VERIFY:    orderworkflow.VerifyWorkflow,
+		models.SERVICE_TYPE_RECOGNIZE: orderworkflow.RecognizeWorkflow,
+	}
+}
+
+// Предварительный расчет стоимости заказа исполнителем услуги заказа
+func (orderworkflow *OrderWorkflow) Quote(dtoorder *models.DtoOrder) (quote *models.ApiOrderQuote, err error) {
+	if dtoorder.Supplier_ID == 0 {
+		log.Error("Supplier is not selected for order %v", dtoorder.ID)
+		return nil, errors.New("Missed supplier")
+	}
+	dtofacility, err := orderworkflow.FacilityRepository.Get(dtoorder.Facility_ID)
+	if err != nil {
+		return nil, err
+	}
+	quoter, ok := orderworkflow.Executors()[dtofacility.Alias].(Quoter)
+	if !ok {
+		log.Error("Quote is not supported for service %v", dtofacility.Alias)
+		return nil, errors.New("Quote not supported")
+	}
+
+	return quoter.Quote(dtoorder)
+}
+
+func (orderworkflow *OrderWorkflow) PollInterval() time.Duration {
+	if config.Configuration.Jobs.PollInterval > 0 {
+		return config.Configuration.Jobs.PollInterval
 	}
+	return JOB_POLL_INTERVAL
+}
+
+func (orderworkflow *OrderWorkflow) LeaseTimeout() time.Duration {
+	if config.Configuration.Jobs.LeaseTimeout > 0 {
+		return config.Configuration.Jobs.LeaseTimeout
+	}
+	return JOB_LEASE_TIMEOUT
+}
+
+func (orderworkflow *OrderWorkflow) RetryDelay() time.Duration {
+	if config.Configuration.Jobs.RetryDelay > 0 {
+		return config.Configuration.Jobs.RetryDelay
+	}
+	return JOB_RETRY_DELAY
+}
+
+func (orderworkflow *OrderWorkflow) MaxAttempts() int {
+	if config.Configuration.Jobs.MaxAttempts > 0 {
+		return config.Configuration.Jobs.MaxAttempts
+	}
+	return JOB_MAX_ATTEMPTS
+}
+
+func (orderworkflow *OrderWorkflow) Workers(alias string) int {
+	if workers, ok := config.Configuration.Jobs.Workers[alias]; ok && workers > 0 {
+		return workers
+	}
+	return JOB_WORKERS
 }
 
 func (orderworkflow *OrderWorkflow) Execute() {
+	for alias, executor := range orderworkflow.Executors() {
+		for i := 0; i < orderworkflow.Workers(alias); i++ {
+			go orderworkflow.Work(alias, executor)
+		}
+	}
 	for {
-		orders, err := orderworkflow.OrderRepository.Get4Processing()
-		if err == nil {
-			for _, order := range *orders {
-				dtofacility, err := orderworkflow.FacilityRepository.Get(order.Facility_ID)
-				if err == nil {
-					switch dtofacility.Alias {
-					case models.SERVICE_TYPE_HEADER:
-						go orderworkflow.HeaderWorkflow.ExecuteOrder(order.ID)
-					case models.SERVICE_TYPE_SMS:
-						go orderworkflow.SMSWorkflow.ExecuteOrder(order.ID)
-					case models.SERVICE_TYPE_HLR:
-						go orderworkflow.HLRWorkflow.ExecuteOrder(order.ID)
-					case models.SERVICE_TYPE_VERIFY:
-						go orderworkflow.VerifyWorkflow.ExecuteOrder(order.ID)
-					}
-				}
+		orderworkflow.Schedule()
+		time.Sleep(orderworkflow.PollInterval())
+	}
+}
+
+// Постановка в очередь заказов, готовых к исполнению, и заказов, исполнение которых было прервано
+func (orderworkflow *OrderWorkflow) Schedule() {
+	orders, err := orderworkflow.OrderRepository.Get4Processing()
+	if err == nil {
+		orderworkflow.Enqueue(orders, true)
+	}
+	orders, err = orderworkflow.OrderRepository.Get4Resumption()
+	if err == nil {
+		orderworkflow.Enqueue(orders, false)
+	}
+}
+
+func (orderworkflow *OrderWorkflow) Enqueue(orders *[]models.ApiTinyOrder, rearm bool) {
+	for _, order := range *orders {
+		dtofacility, err := orderworkflow.FacilityRepository.Get(order.Facility_ID)
+		if err != nil {
+			continue
+		}
+		if _, ok := orderworkflow.Executors()[dtofacility.Alias]; !ok {
+			continue
+		}
+		_ = orderworkflow.JobRepository.Enqueue(order.ID, dtofacility.Alias, rearm)
+	}
+}
+
+// Обработчик заданий одной услуги
+func (orderworkflow *OrderWorkflow) Work(alias string, executor Executor) {
+	for {
+		job, err := orderworkflow.JobRepository.Claim(alias, orderworkflow.Owner, orderworkflow.LeaseTimeout())
+		if err != nil || job == nil {
+			time.Sleep(orderworkflow.PollInterval())
+			continue
+		}
+		orderworkflow.Process(job, executor)
+	}
+}
+
+func (orderworkflow *OrderWorkflow) Process(job *models.DtoJob, executor Executor) {
+	log.Info("Processing job for order %v by %v attempt %v", job.Order_ID, orderworkflow.Owner, job.Attempts)
+	done := make(chan bool)
+	lost := make(chan bool)
+	leaseMutex.Lock()
+	leaseLost[job.Order_ID] = lost
+	leaseMutex.Unlock()
+	defer func() {
+		leaseMutex.Lock()
+		delete(leaseLost, job.Order_ID)
+		leaseMutex.Unlock()
+	}()
+	go orderworkflow.Heartbeat(job, done, lost)
+
+	err := orderworkflow.Run(job, executor)
+	close(done)
+	select {
+	case <-lost:
+		log.Error("Job for order %v has been taken over by another worker", job.Order_ID)
+		return
+	default:
+	}
+
+	if err != nil {
+		log.Error("Error during processing job for order %v %v", job.Order_ID, err)
+		_ = orderworkflow.JobRepository.Fail(job, err.Error(), orderworkflow.RetryDelay(), orderworkflow.MaxAttempts())
+		return
+	}
+	dtoorder, err := orderworkflow.OrderRepository.Get(job.Order_ID)
+	if err != nil {
+		_ = orderworkflow.JobRepository.Fail(job, err.Error(), orderworkflow.RetryDelay(), orderworkflow.MaxAttempts())
+		return
+	}
+	dtoorderstatuses, err := orderworkflow.OrderStatusRepository.GetByOrder(job.Order_ID)
+	if err != nil {
+		_ = orderworkflow.JobRepository.Fail(job, err.Error(), orderworkflow.RetryDelay(), orderworkflow.MaxAttempts())
+		return
+	}
+	order := models.NewApiLongOrderFromDto(dtoorder, dtoorderstatuses)
+	if !order.IsAssembled {
+		// Исполнитель сбросил статус завершения сборки заказа, повторное исполнение не требуется
+		_ = orderworkflow.JobRepository.Fail(job, "Order execution has failed", 0, 0)
+		return
+	}
+	if order.IsOpen && !order.IsExecuted {
+		if deferrer, ok := executor.(Deferrer); ok {
+			next, deferred, err := deferrer.NextAttempt(job.Order_ID)
+			if err == nil && deferred {
+				log.Info("Job for order %v has been deferred till %v", job.Order_ID, next)
+				_ = orderworkflow.JobRepository.Defer(job, next)
+				return
 			}
 		}
-		time.Sleep(time.Minute)
+		_ = orderworkflow.JobRepository.Fail(job, "Order execution has not been finished", orderworkflow.RetryDelay(), orderworkflow.MaxAttempts())
+		return
 	}
+	_ = orderworkflow.JobRepository.Complete(job)
+}
+
+// Продление аренды задания на время исполнения. Ошибка продления повторяется на следующем такте,
+// аренда считается потерянной, если ее перехватили или она истекла без продления
+func (orderworkflow *OrderWorkflow) Heartbeat(job *models.DtoJob, done chan bool, lost chan bool) {
+	ticker := time.NewTicker(orderworkflow.LeaseTimeout() / 3)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			err := orderworkflow.JobRepository.Extend(job, orderworkflow.LeaseTimeout())
+			if err == services.ErrLeaseLost || (err != nil && time.Now().After(job.Lease_Till)) {
+				close(lost)
+				return
+			}
+			if err != nil {
+				log.Error("Job lease for order %v has not been extended, retrying till %v", job.Order_ID, job.Lease_Till)
+			}
+		}
+	}
+}
+
+func (orderworkflow *OrderWorkflow) Run(job *models.DtoJob, executor Executor) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("Order execution panic %v", r)
+		}
+	}()
+
+	resume, err := orderworkflow.Prepare(job, executor)
+	if err != nil {
+		return err
+	}
+	if resume {
+		return executor.(Resumer).ResumeOrder(job.Order_ID)
+	}
+	executor.ExecuteOrder(job.Order_ID)
+
+	return nil
+}
+
+// Подготовка заказа к повторному исполнению после прерванного запуска. Оплаченный заказ или заказ
+// с зарезервированной стоимостью продолжается с последней контрольной точки, если исполнитель это поддерживает
+func (orderworkflow *OrderWorkflow) Prepare(job *models.DtoJob, executor Executor) (resume bool, err error) {
+	dtoorder, err := orderworkflow.OrderRepository.Get(job.Order_ID)
+	if err != nil {
+		return false, err
+	}
+	dtoorderstatuses, err := orderworkflow.OrderStatusRepository.GetByOrder(job.Order_ID)
+	if err != nil {
+		return false, err
+	}
+	order := models.NewApiLongOrderFromDto(dtoorder, dtoorderstatuses)
+	if !order.IsOpen || order.IsExecuted {
+		return false, nil
+	}
+	held, err := orderworkflow.LedgerRepository.IsHeld(job.Order_ID)
+	if err != nil {
+		return false, err
+	}
+	if order.IsPaid || held {
+		if _, ok := executor.(Resumer); ok {
+			return true, nil
+		}
+		log.Error("Order %v has been paid or held and can't be restarted safely", job.Order_ID)
+		return false, errors.New("Paid order interrupted")
+	}
+	log.Info("Resuming interrupted order %v", job.Order_ID)
+	dtoorderstatus := models.NewDtoOrderStatus(job.Order_ID, models.ORDER_STATUS_OPEN, false, "", time.Now())
+	err = orderworkflow.OrderStatusRepository.Save(dtoorderstatus, nil)
+	if err != nil {
+		return false, err
+	}
+
+	return false, nil
 }
@@ -0,0 +1,231 @@
+package workflows
+
+import (
+	"application/gateways"
+	"application/helpers"
+	"application/models"
+	"application/numbering"
+	"application/services"
+	"errors"
+	"sort"
+)
+
+// Тарификация получателей по мобильным операторам и прайсу поставщика. Получатели, стоимость которых
+// не удалось определить, попадают в список нетарифицированных строк
+func QuoteMobileOperators(quote *models.ApiOrderQuote, recipients []models.DtoQuoteRecipient, supplier_id int64,
+	unitrepository services.UnitRepository, pricerepository services.PriceRepository, tablecolumnrepository services.TableColumnRepository,
+	tablerowrepository services.TableRowRepository, mobileoperatorrepository services.MobileOperatorRepository) (err error) {
+	dtomobileoperators, err := mobileoperatorrepository.FindAll()
+	if err != nil {
+		return err
+	}
+	mobileoperators_uuid := make(map[string]*models.DtoMobileOperator)
+	mobileoperators_id := make(map[int]*models.DtoMobileOperator)
+	for index := range *dtomobileoperators {
+		mobileoperators_uuid[(*dtomobileoperators)[index].UUID] = &(*dtomobileoperators)[index]
+		mobileoperators_id[(*dtomobileoperators)[index].ID] = &(*dtomobileoperators)[index]
+	}
+	defaultmobileoperator, err := mobileoperatorrepository.GetDefault()
+	if err != nil {
+		return err
+	}
+
+	mobilephones := []uint64{}
+	priceable := []int{}
+	for index, recipient := range recipients {
+		if recipient.Reason != "" {
+			quote.AddUnpriced(recipient.Row_ID, recipient.Value, recipient.Reason)
+			continue
+		}
+		mobilephones = append(mobilephones, recipient.MobilePhone)
+		priceable = append(priceable, index)
+	}
+	if len(mobilephones) == 0 {
+		return nil
+	}
+
+	dtosupplier, err := unitrepository.Get(supplier_id)
+	if err != nil {
+		return err
+	}
+	mobileoperatoruuids, err := DetectMobileOperators(gateways.Get(dtosupplier.UUID), mobilephones)
+	if err != nil {
+		return err
+	}
+	mobileoperatorrecipients := make(map[int]map[string][]models.DtoQuoteRecipient)
+	for index, recipientindex := range priceable {
+		recipient := recipients[recipientindex]
+		if mobileoperatoruuids[index] == models.MOBILE_OPERATOR_UUID_UNKNOWN {
+			quote.AddUnpriced(recipient.Row_ID, recipient.Value, models.QUOTE_REASON_UNKNOWN_MOBILE_OPERATOR)
+			continue
+		}
+		if quote.Service == models.SERVICE_TYPE_HLR && mobileoperatoruuids[index] == models.MOBILE_OPERATOR_UUID_MEGAFON {
+			quote.AddUnpriced(recipient.Row_ID, recipient.Value, models.QUOTE_REASON_NOT_HLR_MOBILE_OPERATOR)
+			continue
+		}
+		mobileoperator, ok := mobileoperators_uuid[mobileoperatoruuids[index]]
+		if !ok {
+			mobileoperator = defaultmobileoperator
+		}
+		senderrecipients, ok := mobileoperatorrecipients[mobileoperator.ID]
+		if !ok {
+			senderrecipients = make(map[string][]models.DtoQuoteRecipient)
+			mobileoperatorrecipients[mobileoperator.ID] = senderrecipients
+		}
+		senderrecipients[recipient.Sender] = append(senderrecipients[recipient.Sender], recipient)
+	}
+
+	smshlrprices, err := helpers.GetSMSHLRPrices(quote.Service, supplier_id, nil, pricerepository, tablecolumnrepository, tablerowrepository,
+		mobileoperatorrepository, "", true)
+	if err != nil {
+		return err
+	}
+	pricemobileoperators := make(map[int]int)
+	for _, smshlrprice := range *smshlrprices {
+		pricemobileoperators[smshlrprice.Mobile_Operator_ID] = smshlrprice.Mobile_Operator_ID
+	}
+
+	mobileoperator_ids := []int{}
+	for mobileoperator_id := range mobileoperatorrecipients {
+		mobileoperator_ids = append(mobileoperator_ids, mobileoperator_id)
+	}
+	sort.Ints(mobileoperator_ids)
+	for _, mobileoperator_id := range mobileoperator_ids {
+		senderrecipients := mobileoperatorrecipients[mobileoperator_id]
+		smssenders := []string{}
+		counts := make(map[string]int)
+		total := 0
+		for smssender, dtorecipients := range senderrecipients {
+			smssenders = append(smssenders, smssender)
+			for _, dtorecipient := range dtorecipients {
+				counts[smssender] += dtorecipient.Units
+				total += dtorecipient.Units
+			}
+		}
+		sort.Strings(smssenders)
+
+		pricemobileoperator_id, ok := pricemobileoperators[mobileoperator_id]
+		if !ok {
+			pricemobileoperator_id, ok = pricemobileoperators[defaultmobileoperator.ID]
+		}
+		if !ok {
+			log.Error("Can't find default mobile operator %v in price list for supplier %v", defaultmobileoperator.ID, supplier_id)
+			for _, smssender := range smssenders {
+				QuoteUnpriced(quote, senderrecipients[smssender], models.QUOTE_REASON_MISSED_PRICE)
+			}
+			continue
+		}
+		billingmodel := mobileoperators_id[pricemobileoperator_id].SMSBillingModel
+		if quote.Service == models.SERVICE_TYPE_HLR {
+			billingmodel = mobileoperators_id[pricemobileoperator_id].HLRBillingModel
+		}
+		mobileoperatorname := mobileoperators_id[mobileoperator_id].ShortName
+
+		if billingmodel == models.BILLING_MODEL_RANGE {
+			found := false
+			for _, smshlrprice := range *smshlrprices {
+				if smshlrprice.Mobile_Operator_ID == pricemobileoperator_id &&
+					total >= smshlrprice.AmountRange.Begin && (total <= smshlrprice.AmountRange.End || smshlrprice.AmountRange.End == 0) {
+					for _, smssender := range smssenders {
+						quote.AddItem(models.NewApiQuoteItem(mobileoperator_id, mobileoperatorname, smssender, 0, billingmodel.String(),
+							smshlrprice.AmountRange, counts[smssender], smshlrprice.Price, 0, float64(counts[smssender])*smshlrprice.Price))
+					}
+					found = true
+					break
+				}
+			}
+			if !found {
+				log.Error("Can't find mobile operator %v in price list for supplier %v", pricemobileoperator_id, supplier_id)
+				for _, smssender := range smssenders {
+					QuoteUnpriced(quote, senderrecipients[smssender], models.QUOTE_REASON_MISSED_PRICE)
+				}
+			}
+		}
+		if billingmodel == models.BILLING_MODEL_CUMULATIVE_PERHEADER {
+			for _, smssender := range smssenders {
+				count := counts[smssender]
+				found := false
+				items := []models.ApiQuoteItem{}
+				for _, smshlrprice := range *smshlrprices {
+					if smshlrprice.Mobile_Operator_ID != pricemobileoperator_id {
+						continue
+					}
+					amount := 0
+					if count >= smshlrprice.AmountRange.Begin && (count > smshlrprice.AmountRange.End && smshlrprice.AmountRange.End != 0) {
+						amount = smshlrprice.AmountRange.End - smshlrprice.AmountRange.Begin + 1
+					}
+					if count >= smshlrprice.AmountRange.Begin && (count <= smshlrprice.AmountRange.End || smshlrprice.AmountRange.End == 0) {
+						amount = count - smshlrprice.AmountRange.Begin + 1
+						found = true
+					}
+					if amount != 0 {
+						items = append(items, *models.NewApiQuoteItem(mobileoperator_id, mobileoperatorname, smssender, 0, billingmodel.String(),
+							smshlrprice.AmountRange, amount, smshlrprice.Price, 0, float64(amount)*smshlrprice.Price))
+					}
+				}
+				if !found {
+					log.Error("Can't find mobile operator %v in price list for supplier %v", pricemobileoperator_id, supplier_id)
+					QuoteUnpriced(quote, senderrecipients[smssender], models.QUOTE_REASON_MISSED_PRICE)
+					continue
+				}
+				for index := range items {
+					quote.AddItem(&items[index])
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// Определение мобильных операторов номеров по плану нумерации. У поставщика запрашиваются только номера,
+// оператор которых не удалось определить по плану
+func DetectMobileOperators(gateway gateways.Gateway, mobilephones []uint64) (mobileoperatoruuids []string, err error) {
+	mobileoperatoruuids = make([]string, len(mobilephones))
+	unknown := []uint64{}
+	indexes := []int{}
+	for index, mobilephone := range mobilephones {
+		uuid, _, found := numbering.Lookup(mobilephone)
+		if found && uuid != "" {
+			mobileoperatoruuids[index] = uuid
+			continue
+		}
+		unknown = append(unknown, mobilephone)
+		indexes = append(indexes, index)
+	}
+	if len(unknown) == 0 {
+		return mobileoperatoruuids, nil
+	}
+
+	uuids, err := gateway.MobileOperator(unknown)
+	if err != nil {
+		log.Error("Error during detecting mobile operators %v", err)
+		return nil, err
+	}
+	if len(uuids) != len(unknown) {
+		log.Error("Mobile operators size %v doesn't match mobile phones %v", len(uuids), len(unknown))
+		return nil, errors.New("Wrong mobile operators size")
+	}
+	for k, index := range indexes {
+		mobileoperatoruuids[index] = uuids[k]
+	}
+
+	return mobileoperatoruuids, nil
+}
+
+func QuoteUnpriced(quote *models.ApiOrderQuote, recipients []models.DtoQuoteRecipient, reason string) {
+	for _, recipient := range recipients {
+		quote.AddUnpriced(recipient.Row_ID, recipient.Value, reason)
+	}
+}
+
+// Расчет НДС по ставке основной компании объединения
+func QuoteVAT(quote *models.ApiOrderQuote, unit_id int64, companyrepository services.CompanyRepository) (err error) {
+	dtocompany, err := companyrepository.GetPrimaryByUnit(unit_id)
+	if err != nil {
+		return err
+	}
+	quote.SetVAT(dtocompany.VAT)
+
+	return nil
+}
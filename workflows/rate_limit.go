@@ -0,0 +1,24 @@
+package workflows
+
+import (
+	"application/services"
+	"time"
+)
+
+type RateLimitWorkflow struct {
+	RateLimitRepository services.RateLimitRepository
+}
+
+func NewRateLimitWorkflow(ratelimitrepository services.RateLimitRepository) *RateLimitWorkflow {
+	return &RateLimitWorkflow{
+		RateLimitRepository: ratelimitrepository,
+	}
+}
+
+// Удаление полностью пополненных корзин, они создаются заново при следующем запросе
+func (ratelimitworkflow *RateLimitWorkflow) ClearExpired() {
+	for {
+		_ = ratelimitworkflow.RateLimitRepository.DeleteExpired(time.Now())
+		time.Sleep(time.Minute)
+	}
+}
@@ -6,12 +6,16 @@ import (
 	"application/models"
 	"application/services"
 	"errors"
+	"fmt"
+	"strings"
 	"time"
 )
 
 const (
 	TABLE_WORK_NAME   = "Recognize work data"
 	TABLE_RESULT_NAME = "Recognize result data"
+
+	COLUMN_NAME_DEFECTIVE = "Defective"
 )
 
 type RecognizeWorkflow struct {
@@ -24,7 +28,7 @@ type RecognizeWorkflow struct {
 	WorkTableRepository         services.WorkTableRepository
 	InvoiceRepository           services.InvoiceRepository
 	CompanyRepository           services.CompanyRepository
-	OperationRepository         services.OperationRepository
+	LedgerRepository            services.LedgerRepository
 	TransactionTypeRepository   services.TransactionTypeRepository
 	TableColumnRepository       services.TableColumnRepository
 	UnitRepository              services.UnitRepository
@@ -34,17 +38,22 @@ type RecognizeWorkflow struct {
 	InputFieldRepository        services.InputFieldRepository
 	InputProductRepository      services.InputProductRepository
 	SupplierRequestRepository   services.SupplierRequestRepository
+	InputFileRepository         services.InputFileRepository
+	InputFtpRepository          services.InputFtpRepository
+	FileRepository              services.FileRepository
 }
 
 func NewRecognizeWorkflow(orderrepository services.OrderRepository, facilityrepository services.FacilityRepository,
 	recognizefacilityrepository services.RecognizeFacilityRepository, orderstatusrepository services.OrderStatusRepository,
 	customertablerepository services.CustomerTableRepository, resulttablerepository services.ResultTableRepository,
 	worktablerepository services.WorkTableRepository, invoicerepository services.InvoiceRepository, companyrepository services.CompanyRepository,
-	operationrepository services.OperationRepository, transactiontyperepository services.TransactionTypeRepository,
+	ledgerrepository services.LedgerRepository, transactiontyperepository services.TransactionTypeRepository,
 	tablecolumnrepository services.TableColumnRepository, unitrepository services.UnitRepository,
 	tablerowrepository services.TableRowRepository, pricerepository services.PriceRepository,
 	recognizeproductrepository services.RecognizeProductRepository, inputfieldrepository services.InputFieldRepository,
-	inputproductrepository services.InputProductRepository, supplierrequestrepository services.SupplierRequestRepository) *RecognizeWorkflow {
+	inputproductrepository services.InputProductRepository, supplierrequestrepository services.SupplierRequestRepository,
+	inputfilerepository services.InputFileRepository, inputftprepository services.InputFtpRepository,
+	filerepository services.FileRepository) *RecognizeWorkflow {
 	return &RecognizeWorkflow{
 		OrderRepository:             orderrepository,
 		FacilityRepository:          facilityrepository,
@@ -55,7 +64,7 @@ func NewRecognizeWorkflow(orderrepository services.OrderRepository, facilityrepo
 		WorkTableRepository:         worktablerepository,
 		InvoiceRepository:           invoicerepository,
 		CompanyRepository:           companyrepository,
-		OperationRepository:         operationrepository,
+		LedgerRepository:            ledgerrepository,
 		TransactionTypeRepository:   transactiontyperepository,
 		TableColumnRepository:       tablecolumnrepository,
 		UnitRepository:              unitrepository,
@@ -65,94 +74,209 @@ func NewRecognizeWorkflow(orderrepository services.OrderRepository, facilityrepo
 		InputFieldRepository:        inputfieldrepository,
 		InputProductRepository:      inputproductrepository,
 		SupplierRequestRepository:   supplierrequestrepository,
+		InputFileRepository:         inputfilerepository,
+		InputFtpRepository:          inputftprepository,
+		FileRepository:              filerepository,
 	}
 }
 
-func (recognizeworkflow *RecognizeWorkflow) CheckRecognizeOrder(dtorecognizefacility *models.DtoRecognizeFacility) (err error) {
-	return nil
+// Разбор перечня обязательных полей анкеты
+func GetRequiredFields(requiredfields string) (fields []string, err error) {
+	fields = []string{}
+	for _, field := range strings.Split(requiredfields, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		for _, value := range fields {
+			if value == field {
+				log.Error("Required field is duplicated %v", field)
+				return nil, errors.New("Duplicated required field")
+			}
+		}
+		fields = append(fields, field)
+	}
+
+	return fields, nil
+}
+
+func (recognizeworkflow *RecognizeWorkflow) CheckRecognizeOrder(dtoorder *models.DtoOrder, dtorecognizefacility *models.DtoRecognizeFacility,
+	dtoinputftp *models.DtoInputFtp) (requiredfields []string, err error) {
+	if dtorecognizefacility.EstimatedNumbersForm <= 0 {
+		log.Error("Estimated number of forms is not set for order %v", dtoorder.ID)
+		return nil, errors.New("Wrong number of forms")
+	}
+
+	if dtorecognizefacility.EstimatedCalculationOnFields {
+		inputfields, err := recognizeworkflow.InputFieldRepository.GetByOrder(dtoorder.ID)
+		if err != nil {
+			return nil, err
+		}
+		if len(*inputfields) == 0 {
+			log.Error("Estimated fields are not set for order %v", dtoorder.ID)
+			return nil, errors.New("Missed estimated fields")
+		}
+		for _, inputfield := range *inputfields {
+			if inputfield.Count <= 0 {
+				log.Error("Estimated field %v count is wrong for order %v", inputfield.Product_ID, dtoorder.ID)
+				return nil, errors.New("Wrong estimated field count")
+			}
+		}
+	}
+
+	inputfiles, err := recognizeworkflow.InputFileRepository.GetAll(dtoorder.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, inputfile := range *inputfiles {
+		_, err = recognizeworkflow.FileRepository.GetBriefly(inputfile.File_ID)
+		if err != nil {
+			log.Error("Form file %v is not available for order %v", inputfile.File_ID, dtoorder.ID)
+			return nil, err
+		}
+	}
+
+	requiredfields, err = GetRequiredFields(dtorecognizefacility.RequiredFields)
+	if err != nil {
+		return nil, err
+	}
+	dtodatatable, err := recognizeworkflow.CustomerTableRepository.Get(dtoinputftp.Customer_Table_ID)
+	if err != nil {
+		return nil, err
+	}
+	if !dtodatatable.Active {
+		log.Error("Form table is not active %v", dtodatatable.ID)
+		return nil, errors.New("Form table not active")
+	}
+	tablecolumns, err := recognizeworkflow.TableColumnRepository.GetByTable(dtodatatable.ID)
+	if err != nil {
+		return nil, err
+	}
+	for _, requiredfield := range requiredfields {
+		found := false
+		for _, tablecolumn := range *tablecolumns {
+			if tablecolumn.Name == requiredfield {
+				found = true
+				break
+			}
+		}
+		if !found {
+			log.Error("Can't find required field %v in table %v", requiredfield, dtodatatable.ID)
+			return nil, errors.New("Missed required field")
+		}
+	}
+
+	return requiredfields, nil
 }
 
 func (recognizeworkflow *RecognizeWorkflow) CalculateCost(
 	dtoorder *models.DtoOrder, dtorecognizefacility *models.DtoRecognizeFacility) (cost float64, err error) {
+	quote, err := recognizeworkflow.QuoteForms(dtoorder, dtorecognizefacility)
+	if err != nil {
+		return 0, err
+	}
+
+	return quote.Cost, nil
+}
+
+// Расчет стоимости ввода данных по полям форм и наценкам прайса либо по оценке выбранного поставщика
+func (recognizeworkflow *RecognizeWorkflow) QuoteForms(
+	dtoorder *models.DtoOrder, dtorecognizefacility *models.DtoRecognizeFacility) (quote *models.ApiOrderQuote, err error) {
 	recognizeprices, err := helpers.GetRecognizePrices(models.SERVICE_TYPE_RECOGNIZE, dtoorder.Supplier_ID, nil, recognizeworkflow.PriceRepository,
 		recognizeworkflow.TableColumnRepository, recognizeworkflow.TableRowRepository, recognizeworkflow.RecognizeProductRepository, "", true)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	cost = 0
+	quote = models.NewApiOrderQuote(models.SERVICE_TYPE_RECOGNIZE, int64(dtorecognizefacility.EstimatedNumbersForm))
 
 	if dtorecognizefacility.EstimatedCalculationOnFields {
 		inputfields, err := recognizeworkflow.InputFieldRepository.GetByOrder(dtoorder.ID)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 		inputproducts, err := recognizeworkflow.InputProductRepository.GetByOrder(dtoorder.ID)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 
 		for _, inputfield := range *inputfields {
 			count := 0
 			for _, recognizeprice := range *recognizeprices {
 				if inputfield.Product_ID == recognizeprice.Product_ID && !recognizeprice.Increase {
-					cost += float64(inputfield.Count) * recognizeprice.Price
+					amount := inputfield.Count * dtorecognizefacility.EstimatedNumbersForm
+					quote.AddItem(models.NewApiQuoteItem(0, "", "", recognizeprice.Product_ID, "", models.ApiRange{}, amount,
+						recognizeprice.Price, 0, float64(amount)*recognizeprice.Price))
 					count++
 				}
 			}
 			if count < 1 {
 				log.Error("Price list doesn't contain field position %v for supplier %v", inputfield.Product_ID, dtoorder.Supplier_ID)
-				return 0, errors.New("Empty field price list")
+				return nil, errors.New("Empty field price list")
 			}
 			if count > 1 {
 				log.Error("Price list contains multiple field position %v for supplier %v", inputfield.Product_ID, dtoorder.Supplier_ID)
-				return 0, errors.New("Multiple field price list")
+				return nil, errors.New("Multiple field price list")
 			}
 		}
 		for _, inputproduct := range *inputproducts {
 			count := 0
 			for _, recognizeprice := range *recognizeprices {
 				if inputproduct.Product_ID == recognizeprice.Product_ID && recognizeprice.Increase {
-					cost *= recognizeprice.PriceIncrease
+					quote.AddIncrease(recognizeprice.Product_ID, recognizeprice.PriceIncrease)
 					count++
 				}
 			}
 			if count < 1 {
 				log.Error("Price list doesn't contain discount position %v for supplier %v", inputproduct.Product_ID, dtoorder.Supplier_ID)
-				return 0, errors.New("Empty discount price list")
+				return nil, errors.New("Empty discount price list")
 			}
 			if count > 1 {
 				log.Error("Price list contains multiple discount position %v for supplier %v", inputproduct.Product_ID, dtoorder.Supplier_ID)
-				return 0, errors.New("Multiple discount price list")
+				return nil, errors.New("Multiple discount price list")
 			}
 		}
-		cost *= float64(dtorecognizefacility.EstimatedNumbersForm)
 	} else {
 		supplierrequests, err := recognizeworkflow.SupplierRequestRepository.GetByOrder(dtoorder.ID)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 		found := false
 		for _, supplierrequest := range *supplierrequests {
 			if supplierrequest.MyChoice && supplierrequest.Supplier_ID == dtoorder.Supplier_ID {
-				cost = supplierrequest.EstimatedCost
+				quote.AddItem(models.NewApiQuoteItem(0, "", "", 0, "", models.ApiRange{}, 1, supplierrequest.EstimatedCost, 0,
+					supplierrequest.EstimatedCost))
 				found = true
 				break
 			}
 		}
 		if !found {
 			log.Error("Can't find estimated supplier cost for order %v", dtoorder.ID)
-			return 0, errors.New("Not available cost")
+			return nil, errors.New("Not available cost")
 		}
 	}
 
-	return cost, nil
+	return quote, nil
 }
 
-func (recognizeworkflow *RecognizeWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtorecognizefacility *models.DtoRecognizeFacility, balance float64) (err error) {
-	if balance < dtorecognizefacility.Cost {
-		log.Error("Not enough money at unit balance %v to pay for order %v execution", dtoorder.Unit_ID, dtoorder.ID)
-		return errors.New("Not enough money")
+// Предварительный расчет стоимости заказа без изменения состояния заказа
+func (recognizeworkflow *RecognizeWorkflow) Quote(dtoorder *models.DtoOrder) (quote *models.ApiOrderQuote, err error) {
+	dtorecognizefacility, err := recognizeworkflow.RecognizeFacilityRepository.Get(dtoorder.ID)
+	if err != nil {
+		return nil, err
+	}
+	quote, err = recognizeworkflow.QuoteForms(dtoorder, dtorecognizefacility)
+	if err != nil {
+		return nil, err
+	}
+	err = QuoteVAT(quote, dtoorder.Unit_ID, recognizeworkflow.CompanyRepository)
+	if err != nil {
+		return nil, err
 	}
+
+	return quote, nil
+}
+
+func (recognizeworkflow *RecognizeWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtorecognizefacility *models.DtoRecognizeFacility) (err error) {
 	dtocompany, err := recognizeworkflow.CompanyRepository.GetPrimaryByUnit(dtoorder.Unit_ID)
 	if err != nil {
 		return
@@ -174,9 +298,9 @@ func (recognizeworkflow *RecognizeWorkflow) PayAndInvoice(dtoorder *models.DtoOr
 	dtoinvoice := new(models.DtoInvoice)
 	dtoinvoice.Company_ID = dtocompany.ID
 	if dtocompany.VAT != 0 {
-		dtoinvoice.VAT = (dtorecognizefacility.Cost / (1 + float64(dtocompany.VAT)/100)) * float64(dtocompany.VAT) / 100
+		dtoinvoice.VAT = (dtorecognizefacility.CostFactual / (1 + float64(dtocompany.VAT)/100)) * float64(dtocompany.VAT) / 100
 	}
-	dtoinvoice.Total = dtorecognizefacility.Cost
+	dtoinvoice.Total = dtorecognizefacility.CostFactual
 	dtoinvoice.Paid = true
 	dtoinvoice.Created = time.Now()
 	dtoinvoice.Active = true
@@ -192,46 +316,208 @@ func (recognizeworkflow *RecognizeWorkflow) PayAndInvoice(dtoorder *models.DtoOr
 	return nil
 }
 
-func (recognizeworkflow *RecognizeWorkflow) CopyData(dtoorder *models.DtoOrder) (err error) {
-	dtoworkdatatable := new(models.DtoCustomerTable)
+// Загрузка анкет, поступивших через ftp, в рабочую таблицу заказа
+func (recognizeworkflow *RecognizeWorkflow) CopyData(dtoorder *models.DtoOrder,
+	dtoinputftp *models.DtoInputFtp) (dtoworkdatatable *models.DtoCustomerTable, err error) {
+	worktables, err := recognizeworkflow.WorkTableRepository.GetByOrder(dtoorder.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(*worktables) != 0 {
+		return recognizeworkflow.CustomerTableRepository.Get((*worktables)[0].Customer_Table_ID)
+	}
+
+	dtodatatable, err := recognizeworkflow.CustomerTableRepository.Get(dtoinputftp.Customer_Table_ID)
+	if err != nil {
+		return nil, err
+	}
+	dtoworkdatatable, err = recognizeworkflow.CustomerTableRepository.Copy(dtodatatable, true)
+	if err != nil {
+		return nil, err
+	}
 	dtoworkdatatable.Name = TABLE_WORK_NAME
-	dtoworkdatatable.Created = time.Now()
 	dtoworkdatatable.TypeID = models.TABLE_TYPE_HIDDEN
-	dtoworkdatatable.UnitID = dtoorder.Unit_ID
-	dtoworkdatatable.Active = true
-	dtoworkdatatable.Permanent = true
-	dtoworkdatatable.Signature = models.CUSTOMER_TABLE_SIGNATURE_DEFAULT
-	err = recognizeworkflow.CustomerTableRepository.Create(dtoworkdatatable)
+	err = recognizeworkflow.CustomerTableRepository.Update(dtoworkdatatable)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	dtoworktable := models.NewDtoWorkTable(dtoorder.ID, dtoworkdatatable.ID)
 	err = recognizeworkflow.WorkTableRepository.Create(dtoworktable, nil)
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	dtoresultdatatable := new(models.DtoCustomerTable)
+	return dtoworkdatatable, nil
+}
+
+// Формирование таблицы результатов. Анкеты с незаполненными обязательными полями отмечаются как бракованные
+// и попадают в результат только при разрешении загрузки бракованных анкет
+func (recognizeworkflow *RecognizeWorkflow) SaveResult(dtoorder *models.DtoOrder, dtorecognizefacility *models.DtoRecognizeFacility,
+	dtoworkdatatable *models.DtoCustomerTable, requiredfields []string) (forms int, defective int, err error) {
+	resulttables, err := recognizeworkflow.ResultTableRepository.GetByOrder(dtoorder.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, resulttable := range *resulttables {
+		dtoresultdatatable, err := recognizeworkflow.CustomerTableRepository.Get(resulttable.Customer_Table_ID)
+		if err != nil {
+			return 0, 0, err
+		}
+		err = recognizeworkflow.CustomerTableRepository.Deactivate(dtoresultdatatable)
+		if err != nil {
+			return 0, 0, err
+		}
+	}
+	err = recognizeworkflow.ResultTableRepository.DeleteByOrder(dtoorder.ID, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	dtoresultdatatable, err := recognizeworkflow.CustomerTableRepository.Copy(dtoworkdatatable, true)
+	if err != nil {
+		return 0, 0, err
+	}
 	dtoresultdatatable.Name = TABLE_RESULT_NAME
-	dtoresultdatatable.Created = time.Now()
-	dtoresultdatatable.TypeID = models.TABLE_TYPE_HIDDEN
-	dtoresultdatatable.UnitID = dtoorder.Supplier_ID
-	dtoresultdatatable.Active = true
-	dtoresultdatatable.Permanent = true
-	dtoresultdatatable.Signature = models.CUSTOMER_TABLE_SIGNATURE_DEFAULT
-	err = recognizeworkflow.CustomerTableRepository.Create(dtoresultdatatable)
+	dtoresultdatatable.TypeID = models.TABLE_TYPE_DEFAULT
+	dtoresultdatatable.UnitID = dtoorder.Unit_ID
+	err = recognizeworkflow.CustomerTableRepository.Update(dtoresultdatatable)
 	if err != nil {
-		return err
+		return 0, 0, err
 	}
 	dtoresulttable := models.NewDtoResultTable(dtoorder.ID, dtoresultdatatable.ID)
 	err = recognizeworkflow.ResultTableRepository.Create(dtoresulttable, nil)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	tablecolumns, err := GetOrCreateColumns(dtoresultdatatable, []string{COLUMN_NAME_DEFECTIVE}, recognizeworkflow.TableColumnRepository)
+	if err != nil {
+		return 0, 0, err
+	}
+	resultdatatablecolumns, err := recognizeworkflow.TableColumnRepository.GetByTable(dtoresultdatatable.ID)
+	if err != nil {
+		return 0, 0, err
+	}
+	requiredcolumns := []int64{}
+	for _, resultdatatablecolumn := range *resultdatatablecolumns {
+		for _, requiredfield := range requiredfields {
+			if resultdatatablecolumn.Name == requiredfield && !resultdatatablecolumn.Prebuilt {
+				requiredcolumns = append(requiredcolumns, resultdatatablecolumn.ID)
+			}
+		}
+	}
+
+	apitablerows, err := recognizeworkflow.TableRowRepository.GetAll("", "", dtoresultdatatable.ID, resultdatatablecolumns)
+	if err != nil {
+		return 0, 0, err
+	}
+	forms = len(*apitablerows)
+	for _, apitablerow := range *apitablerows {
+		tablerow, err := recognizeworkflow.TableRowRepository.Get(apitablerow.ID)
+		if err != nil {
+			return 0, 0, err
+		}
+		tablecells, err := tablerow.TableRowToDtoTableCells(resultdatatablecolumns)
+		if err != nil {
+			return 0, 0, err
+		}
+
+		broken := false
+		for _, requiredcolumn := range requiredcolumns {
+			for _, tablecell := range *tablecells {
+				if tablecell.Table_Column_ID == requiredcolumn && strings.TrimSpace(tablecell.Value) == "" {
+					broken = true
+				}
+			}
+		}
+		for i := range *tablecells {
+			for j := range tablecolumns {
+				FillTableCell(&(*tablecells)[i], &tablecolumns[j], COLUMN_NAME_DEFECTIVE, fmt.Sprintf("%v", broken))
+			}
+		}
+
+		err = tablerow.TableCellsToTableRow(tablecells, resultdatatablecolumns)
+		if err != nil {
+			return 0, 0, err
+		}
+		err = recognizeworkflow.TableRowRepository.Update(tablerow, nil, true, false)
+		if err != nil {
+			return 0, 0, err
+		}
+		if broken {
+			defective++
+			if !dtorecognizefacility.LoadDefectiveForms {
+				err = recognizeworkflow.TableRowRepository.Deactivate(tablerow, true)
+				if err != nil {
+					return 0, 0, err
+				}
+			}
+		}
+	}
+
+	return forms, defective, nil
+}
+
+// Оплата фактической стоимости заказа при закрытии. При расчете по полям тарифицируются только распознанные
+// формы без брака, остаток резерва снимается
+func (recognizeworkflow *RecognizeWorkflow) Capture(dtoorder *models.DtoOrder, dtorecognizefacility *models.DtoRecognizeFacility,
+	forms int) (err error) {
+	dtorecognizefacility.CostFactual = dtorecognizefacility.Cost
+	if dtorecognizefacility.EstimatedCalculationOnFields {
+		dtorecognizefacility.CostFactual = 0
+		if forms > 0 {
+			recognized := *dtorecognizefacility
+			recognized.EstimatedNumbersForm = forms
+			dtorecognizefacility.CostFactual, err = recognizeworkflow.CalculateCost(dtoorder, &recognized)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	err = recognizeworkflow.RecognizeFacilityRepository.Update(dtorecognizefacility, true, false)
 	if err != nil {
 		return err
 	}
+	if dtorecognizefacility.CostFactual == 0 {
+		log.Info("Nothing has been recognized for order %v, releasing held cost", dtoorder.ID)
+		return recognizeworkflow.LedgerRepository.Release(dtoorder.ID)
+	}
+
+	return recognizeworkflow.PayAndInvoice(dtoorder, dtorecognizefacility)
+}
+
+func (recognizeworkflow *RecognizeWorkflow) ClearTables(dtoorder *models.DtoOrder) (err error) {
+	worktables, err := recognizeworkflow.WorkTableRepository.GetByOrder(dtoorder.ID)
+	if err != nil {
+		return err
+	}
+	for _, worktable := range *worktables {
+		dtocustomertable, err := recognizeworkflow.CustomerTableRepository.Get(worktable.Customer_Table_ID)
+		if err != nil {
+			return err
+		}
+		err = recognizeworkflow.CustomerTableRepository.Deactivate(dtocustomertable)
+		if err != nil {
+			return err
+		}
+	}
 
 	return nil
 }
 
+// Прекращение исполнения заказа со снятием резерва и возвратом оплаты
+func (recognizeworkflow *RecognizeWorkflow) Cancel(dtoorder *models.DtoOrder) {
+	err := recognizeworkflow.LedgerRepository.Release(dtoorder.ID)
+	if err != nil {
+		log.Error("Can't release order %v, %v", dtoorder.ID, err)
+	}
+	err = RefundOrder(dtoorder, recognizeworkflow.UnitRepository, recognizeworkflow.TransactionTypeRepository, recognizeworkflow.InvoiceRepository)
+	if err != nil {
+		log.Error("Can't refund order %v, %v", dtoorder.ID, err)
+	}
+	_ = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+}
+
 func (recognizeworkflow *RecognizeWorkflow) SetStatus(dtoorder *models.DtoOrder, orderstatus models.OrderStatus, active bool) (err error) {
 	dtoorderstatus := models.NewDtoOrderStatus(dtoorder.ID, orderstatus, active, "", time.Now())
 	err = recognizeworkflow.OrderStatusRepository.Save(dtoorderstatus, nil)
@@ -243,6 +529,8 @@ func (recognizeworkflow *RecognizeWorkflow) SetStatus(dtoorder *models.DtoOrder,
 }
 
 func (recognizeworkflow *RecognizeWorkflow) ExecuteOrder(order_id int64) {
+	log.Info("Starting order %v execution at %v", order_id, time.Now())
+	log.Info("Checking order type ...")
 	dtoorder, err := recognizeworkflow.OrderRepository.Get(order_id)
 	if err != nil {
 		return
@@ -255,10 +543,16 @@ func (recognizeworkflow *RecognizeWorkflow) ExecuteOrder(order_id int64) {
 		log.Error("Order service is not macthed to the service method %v", dtoorder.Facility_ID)
 		return
 	}
+	if !dtofacility.Active {
+		log.Error("Service is not active %v", dtofacility.ID)
+		return
+	}
+	log.Info("Checking service type ...")
 	dtorecognizefacility, err := recognizeworkflow.RecognizeFacilityRepository.Get(dtoorder.ID)
 	if err != nil {
 		return
 	}
+	log.Info("Checking order status ...")
 	dtoorderstatuses, err := recognizeworkflow.OrderStatusRepository.GetByOrder(dtoorder.ID)
 	if err != nil {
 		return
@@ -267,13 +561,40 @@ func (recognizeworkflow *RecognizeWorkflow) ExecuteOrder(order_id int64) {
 	order := models.NewApiLongOrderFromDto(dtoorder, dtoorderstatuses)
 	if order.IsAssembled && order.IsConfirmed && !order.IsOpen && !order.IsCancelled && !order.IsExecuted && !order.IsArchived && !order.IsDeleted {
 		/* 1 */
+		if !dtorecognizefacility.RequestsCancel {
+			log.Info("Supplier requests are not closed for order %v", dtoorder.ID)
+			return
+		}
+		if dtoorder.Supplier_ID == 0 {
+			log.Error("Supplier is not chosen for order %v", dtoorder.ID)
+			_ = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+			return
+		}
+		log.Info("Checking form data ...")
+		found, err := recognizeworkflow.InputFtpRepository.Exists(dtoorder.ID)
+		if err != nil {
+			return
+		}
+		if !found {
+			log.Info("Forms are not loaded for order %v", dtoorder.ID)
+			return
+		}
+		dtoinputftp, err := recognizeworkflow.InputFtpRepository.Get(dtoorder.ID)
+		if err != nil {
+			return
+		}
+		if !dtoinputftp.Ready || dtoinputftp.Customer_Table_ID == 0 {
+			log.Info("Forms are not ready for order %v", dtoorder.ID)
+			return
+		}
 
 		/* 2 */ err = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_OPEN, true)
 		if err != nil {
 			_ = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-		err = recognizeworkflow.CheckRecognizeOrder(dtorecognizefacility)
+		log.Info("Checking order data ...")
+		requiredfields, err := recognizeworkflow.CheckRecognizeOrder(dtoorder, dtorecognizefacility, dtoinputftp)
 		if err != nil {
 			_ = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
@@ -284,12 +605,12 @@ func (recognizeworkflow *RecognizeWorkflow) ExecuteOrder(order_id int64) {
 			_ = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
+		log.Info("Calculating order cost ...")
 		dtorecognizefacility.Cost, err = recognizeworkflow.CalculateCost(dtoorder, dtorecognizefacility)
 		if err != nil {
 			_ = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-		dtorecognizefacility.CostFactual = dtorecognizefacility.Cost
 		err = recognizeworkflow.RecognizeFacilityRepository.Update(dtorecognizefacility, true, false)
 		if err != nil {
 			_ = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
@@ -301,32 +622,47 @@ func (recognizeworkflow *RecognizeWorkflow) ExecuteOrder(order_id int64) {
 			_ = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-
+		log.Info("Holding order cost ...")
+		_, err = recognizeworkflow.LedgerRepository.Hold(dtoorder.Unit_ID, dtoorder.ID, dtorecognizefacility.Cost)
+		if err != nil {
+			_ = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+			return
+		}
+		log.Info("Start order processing ...")
 		/* 5 */ dtoorder.Begin_Date = time.Now()
 		err = recognizeworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
 			*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_MODERATOR_BEGIN, true, "", time.Now())}, nil, true)
 		if err != nil {
-			_ = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+			recognizeworkflow.Cancel(dtoorder)
 			return
 		}
-		balance, err := recognizeworkflow.OperationRepository.CalculateBalance(dtoorder.Unit_ID)
+		log.Info("Copying form data ...")
+		/* 7 */ dtoworkdatatable, err := recognizeworkflow.CopyData(dtoorder, dtoinputftp)
 		if err != nil {
-			_ = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+			recognizeworkflow.Cancel(dtoorder)
 			return
 		}
-
-		/* 6 */ err = recognizeworkflow.PayAndInvoice(dtoorder, dtorecognizefacility, balance)
+		log.Info("Saving recognition results ...")
+		/* 8 */ forms, defective, err := recognizeworkflow.SaveResult(dtoorder, dtorecognizefacility, dtoworkdatatable, requiredfields)
 		if err != nil {
-			_ = recognizeworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+			recognizeworkflow.Cancel(dtoorder)
 			return
 		}
-
-		/* 7 */ err = recognizeworkflow.CopyData(dtoorder)
+		if defective != 0 {
+			log.Info("Order %v contains %v defective forms", dtoorder.ID, defective)
+		}
+		log.Info("Finsing order processing and clearing data ...")
+		/* 9 */ err = recognizeworkflow.ClearTables(dtoorder)
 		if err != nil {
 			return
 		}
-
-		/* 8 */ dtoorder.End_Date = time.Now()
+		log.Info("Order payment and invoicement ...")
+		/* 10 */ err = recognizeworkflow.Capture(dtoorder, dtorecognizefacility, forms-defective)
+		if err != nil {
+			return
+		}
+		log.Info("Completing order execution %v", time.Now())
+		dtoorder.End_Date = time.Now()
 		err = recognizeworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
 			*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_SUPPLIER_CLOSE, true, "", time.Now())}, nil, true)
 		if err != nil {
@@ -0,0 +1,331 @@
+package workflows
+
+import (
+	"application/config"
+	"application/gateways"
+	"application/helpers"
+	"application/models"
+	"errors"
+	"github.com/gocql/gocql"
+	libTypes "lib/suppliers/types"
+	"sort"
+	"time"
+)
+
+// Поставщик, которому могут быть отправлены строки заказа по мобильному оператору
+type RouteCandidate struct {
+	Supplier_ID int64   // Идентификатор поставщика
+	Position    int64   // Позиция
+	Rating      int     // Рейтинг
+	Throughput  int     // Пропускная способность, 0 - без ограничения
+	Price       float64 // Цена сообщения для мобильного оператора
+	Score       float64 // Оценка поставщика во взвешенной политике выбора
+}
+
+// Упорядочивание поставщиков мобильного оператора по политике выбора
+func RankCandidates(candidates []RouteCandidate, policy models.RoutingPolicy) {
+	if policy == models.ROUTING_POLICY_WEIGHTED {
+		priceweight := config.Configuration.Routing.PriceWeight
+		ratingweight := config.Configuration.Routing.RatingWeight
+		if priceweight <= 0 && ratingweight <= 0 {
+			priceweight, ratingweight = 1, 1
+		}
+		minprice, maxrating := 0.0, 0
+		for index, candidate := range candidates {
+			if index == 0 || candidate.Price < minprice {
+				minprice = candidate.Price
+			}
+			if candidate.Rating > maxrating {
+				maxrating = candidate.Rating
+			}
+		}
+		for index := range candidates {
+			candidate := &candidates[index]
+			candidate.Score = 0
+			if candidate.Price > 0 {
+				candidate.Score += priceweight * minprice / candidate.Price
+			} else {
+				candidate.Score += priceweight
+			}
+			if maxrating > 0 {
+				candidate.Score += ratingweight * float64(candidate.Rating) / float64(maxrating)
+			}
+		}
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		switch policy {
+		case models.ROUTING_POLICY_CHEAPEST:
+			if a.Price != b.Price {
+				return a.Price < b.Price
+			}
+			if a.Rating != b.Rating {
+				return a.Rating > b.Rating
+			}
+		case models.ROUTING_POLICY_RATING:
+			if a.Rating != b.Rating {
+				return a.Rating > b.Rating
+			}
+			if a.Price != b.Price {
+				return a.Price < b.Price
+			}
+		case models.ROUTING_POLICY_WEIGHTED:
+			if a.Score != b.Score {
+				return a.Score > b.Score
+			}
+		}
+		return a.Position < b.Position
+	})
+}
+
+// Цена сообщения поставщика для мобильного оператора по диапазону количества сообщений
+func RoutePrice(smshlrprices *[]models.ApiSMSHLRPrice, supplier_id int64, mobileoperator_id int, count int) (price float64, ok bool) {
+	for _, smshlrprice := range *smshlrprices {
+		if smshlrprice.Supplier_ID != supplier_id || smshlrprice.Mobile_Operator_ID != mobileoperator_id {
+			continue
+		}
+		if count >= smshlrprice.AmountRange.Begin && (count <= smshlrprice.AmountRange.End || smshlrprice.AmountRange.End == 0) {
+			return smshlrprice.Price, true
+		}
+		if !ok {
+			price, ok = smshlrprice.Price, true
+		}
+	}
+
+	return price, ok
+}
+
+// Распределение сообщений по поставщикам в разрезе мобильных операторов с учетом политики выбора и пропускной способности.
+// Сообщения, не принятые поставщиком, передаются следующему поставщику мобильного оператора
+func (smsworkflow *SMSWorkflow) RouteSMS(dtoorder *models.DtoOrder, policy models.RoutingPolicy,
+	sms *[]libTypes.Sms) (smsresponse *libTypes.SmsResponse, err error) {
+	supplierfacilities, err := smsworkflow.SupplierFacilityRepository.GetByAlias(models.SERVICE_TYPE_SMS)
+	if err != nil {
+		return nil, err
+	}
+	if len(*supplierfacilities) == 0 {
+		log.Error("Can't find suppliers for routing order %v", dtoorder.ID)
+		return nil, errors.New("Missed suppliers")
+	}
+	smshlrprices, err := helpers.GetSMSHLRPrices(models.SERVICE_TYPE_SMS, 0, nil, smsworkflow.PriceRepository, smsworkflow.TableColumnRepository,
+		smsworkflow.TableRowRepository, smsworkflow.MobileOperatorRepository, "", true)
+	if err != nil {
+		return nil, err
+	}
+
+	mobileoperatorrows, err := smsworkflow.GetMobileOperatorRows(dtoorder, sms)
+	if err != nil {
+		return nil, err
+	}
+	defaultmobileoperator, err := smsworkflow.MobileOperatorRepository.GetDefault()
+	if err != nil {
+		return nil, err
+	}
+
+	smsresponse = new(libTypes.SmsResponse)
+	smsresponse.Ids = make([]gocql.UUID, len(*sms))
+	smsresponse.Errors = make([]error, len(*sms))
+	used := make(map[int64]int)
+	accepted := false
+	mobileoperator_ids := []int{}
+	for mobileoperator_id := range mobileoperatorrows {
+		mobileoperator_ids = append(mobileoperator_ids, mobileoperator_id)
+	}
+	sort.Ints(mobileoperator_ids)
+	for _, mobileoperator_id := range mobileoperator_ids {
+		pending := mobileoperatorrows[mobileoperator_id]
+		candidates := []RouteCandidate{}
+		for _, supplierfacility := range *supplierfacilities {
+			price, ok := RoutePrice(smshlrprices, supplierfacility.Supplier_ID, mobileoperator_id, len(pending))
+			if !ok {
+				price, ok = RoutePrice(smshlrprices, supplierfacility.Supplier_ID, defaultmobileoperator.ID, len(pending))
+			}
+			if !ok {
+				continue
+			}
+			candidates = append(candidates, RouteCandidate{Supplier_ID: supplierfacility.Supplier_ID, Position: supplierfacility.Position,
+				Rating: supplierfacility.Rating, Throughput: supplierfacility.Throughput, Price: price})
+		}
+		RankCandidates(candidates, policy)
+
+		var lasterr error = errors.New("Missed supplier price")
+		for attempt, candidate := range candidates {
+			if len(pending) == 0 {
+				break
+			}
+			rows := pending
+			if candidate.Throughput > 0 {
+				free := candidate.Throughput - used[candidate.Supplier_ID]
+				if free <= 0 {
+					continue
+				}
+				if len(rows) > free {
+					rows = rows[:free]
+				}
+			}
+			routesms := new([]libTypes.Sms)
+			for _, row := range rows {
+				*routesms = append(*routesms, (*sms)[row])
+			}
+			log.Info("Routing %v messages of mobile operator %v to supplier %v ...", len(rows), mobileoperator_id, candidate.Supplier_ID)
+			routeresponse, err := smsworkflow.SendRoute(dtoorder, candidate.Supplier_ID, mobileoperator_id, attempt+1, routesms)
+			if err != nil {
+				lasterr = err
+				continue
+			}
+			used[candidate.Supplier_ID] += len(rows)
+			unsent := append([]int{}, pending[len(rows):]...)
+			for k, row := range rows {
+				smsresponse.Ids[row] = routeresponse.Ids[k]
+				smsresponse.Errors[row] = routeresponse.Errors[k]
+				if routeresponse.Errors[k] != nil {
+					unsent = append(unsent, row)
+					lasterr = routeresponse.Errors[k]
+				} else {
+					accepted = true
+				}
+			}
+			sort.Ints(unsent)
+			pending = unsent
+		}
+		for _, row := range pending {
+			if smsresponse.Errors[row] == nil {
+				log.Error("Can't route message %v of order %v to any supplier %v", row, dtoorder.ID, lasterr)
+				smsresponse.Errors[row] = lasterr
+			}
+		}
+	}
+	if !accepted && len(*sms) != 0 {
+		log.Error("None of suppliers accepted messages of order %v", dtoorder.ID)
+		return nil, errors.New("Messages not routed")
+	}
+
+	return smsresponse, nil
+}
+
+// Номера сообщений заказа в разрезе мобильных операторов получателей
+func (smsworkflow *SMSWorkflow) GetMobileOperatorRows(dtoorder *models.DtoOrder, sms *[]libTypes.Sms) (mobileoperatorrows map[int][]int, err error) {
+	dtomobileoperators, err := smsworkflow.MobileOperatorRepository.FindAll()
+	if err != nil {
+		return nil, err
+	}
+	mobileoperators_uuid := make(map[string]int)
+	for _, dtomobileoperator := range *dtomobileoperators {
+		mobileoperators_uuid[dtomobileoperator.UUID] = dtomobileoperator.ID
+	}
+	defaultmobileoperator, err := smsworkflow.MobileOperatorRepository.GetDefault()
+	if err != nil {
+		return nil, err
+	}
+
+	mobilephones := []uint64{}
+	for _, obj := range *sms {
+		mobilephones = append(mobilephones, obj.Recipient)
+	}
+	dtosupplier, err := smsworkflow.UnitRepository.Get(dtoorder.Supplier_ID)
+	if err != nil {
+		return nil, err
+	}
+	mobileoperatoruuids, err := DetectMobileOperators(gateways.Get(dtosupplier.UUID), mobilephones)
+	if err != nil {
+		return nil, err
+	}
+
+	mobileoperatorrows = make(map[int][]int)
+	for index, mobileoperatoruuid := range mobileoperatoruuids {
+		mobileoperator_id, ok := mobileoperators_uuid[mobileoperatoruuid]
+		if !ok {
+			mobileoperator_id = defaultmobileoperator.ID
+		}
+		mobileoperatorrows[mobileoperator_id] = append(mobileoperatorrows[mobileoperator_id], index)
+	}
+
+	return mobileoperatorrows, nil
+}
+
+// Отправка сообщений поставщику с сохранением результата отправки в заказе
+func (smsworkflow *SMSWorkflow) SendRoute(dtoorder *models.DtoOrder, supplier_id int64, mobileoperator_id int, attempt int,
+	sms *[]libTypes.Sms) (smsresponse *libTypes.SmsResponse, err error) {
+	route := models.NewDtoOrderRoute(0, dtoorder.ID, supplier_id, mobileoperator_id, attempt, models.ORDER_ROUTE_STATUS_FAILED,
+		len(*sms), 0, 0, "", "", time.Now(), time.Now())
+	dtosupplier, err := smsworkflow.UnitRepository.Get(supplier_id)
+	if err == nil {
+		gateway := gateways.Get(dtosupplier.UUID)
+		var smssupplier *libTypes.Supplier
+		smssupplier, err = gateway.Supplier(dtosupplier.UUID)
+		if err == nil {
+			smsresponse, err = SendSMS(gateway, smssupplier, sms)
+		}
+	}
+	if err == nil && len(smsresponse.Ids) != len(*sms) {
+		log.Error("SMS response size %v doesn't match route size %v for order %v", len(smsresponse.Ids), len(*sms), dtoorder.ID)
+		err = errors.New("Wrong sms response size")
+	}
+	if err != nil {
+		route.Failed = len(*sms)
+		route.Error = err.Error()
+		_ = smsworkflow.OrderRouteRepository.Create(route)
+		return nil, err
+	}
+
+	route.Response, err = EncodeSMSResponse(smsresponse)
+	if err != nil {
+		return nil, err
+	}
+	route.Status = models.ORDER_ROUTE_STATUS_SENT
+	for _, smserror := range smsresponse.Errors {
+		if smserror != nil {
+			route.Failed++
+		} else {
+			route.Sent++
+		}
+	}
+	err = smsworkflow.OrderRouteRepository.Create(route)
+	if err != nil {
+		return nil, err
+	}
+
+	return smsresponse, nil
+}
+
+// Получение статусов сообщений у поставщиков, которым они были отправлены
+func (smsworkflow *SMSWorkflow) GetRoutedSMSStatus(dtoorder *models.DtoOrder, smsresponse *libTypes.SmsResponse,
+	deadline time.Time) (smsstatuses map[gocql.UUID]libTypes.SmsStatus, err error) {
+	routes, err := smsworkflow.OrderRouteRepository.GetByOrder(dtoorder.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(*routes) == 0 {
+		dtosupplier, err := smsworkflow.UnitRepository.Get(dtoorder.Supplier_ID)
+		if err != nil {
+			return nil, err
+		}
+		return GetSMSStatus(gateways.Get(dtosupplier.UUID), smsresponse, dtoorder.ID, deadline, smsworkflow.DeliveryReportRepository)
+	}
+
+	smsstatuses = make(map[gocql.UUID]libTypes.SmsStatus)
+	for _, route := range *routes {
+		if route.Status != models.ORDER_ROUTE_STATUS_SENT {
+			continue
+		}
+		routeresponse, err := DecodeSMSResponse(route.Response)
+		if err != nil {
+			return nil, err
+		}
+		dtosupplier, err := smsworkflow.UnitRepository.Get(route.Supplier_ID)
+		if err != nil {
+			return nil, err
+		}
+		routestatuses, err := GetSMSStatus(gateways.Get(dtosupplier.UUID), routeresponse, dtoorder.ID, deadline,
+			smsworkflow.DeliveryReportRepository)
+		if err != nil {
+			return nil, err
+		}
+		for id, status := range routestatuses {
+			smsstatuses[id] = status
+		}
+	}
+
+	return smsstatuses, nil
+}
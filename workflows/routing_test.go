@@ -0,0 +1,65 @@
+package workflows
+
+import (
+	"application/models"
+	"testing"
+)
+
+func TestRankCandidates(t *testing.T) {
+	var cases = []struct {
+		policy    models.RoutingPolicy
+		suppliers []int64
+	}{
+		{models.ROUTING_POLICY_MANUAL, []int64{4, 1, 2, 3}},
+		{models.ROUTING_POLICY_CHEAPEST, []int64{3, 1, 4, 2}},
+		{models.ROUTING_POLICY_RATING, []int64{4, 2, 3, 1}},
+		{models.ROUTING_POLICY_WEIGHTED, []int64{4, 2, 3, 1}},
+	}
+
+	for _, c := range cases {
+		candidates := []RouteCandidate{
+			{Supplier_ID: 1, Position: 1, Rating: 5, Price: 0.5},
+			{Supplier_ID: 2, Position: 2, Rating: 9, Price: 0.7},
+			{Supplier_ID: 3, Position: 3, Rating: 5, Price: 0.4},
+			{Supplier_ID: 4, Position: 0, Rating: 9, Price: 0.7},
+		}
+		RankCandidates(candidates, c.policy)
+		for index, candidate := range candidates {
+			if candidate.Supplier_ID != c.suppliers[index] {
+				t.Error("Candidates are not properly ranked by policy", c.policy, candidates)
+				break
+			}
+		}
+	}
+}
+
+func TestRoutePrice(t *testing.T) {
+	var smshlrprices = []models.ApiSMSHLRPrice{
+		{Supplier_ID: 1, Mobile_Operator_ID: 1, AmountRange: models.ApiRange{Begin: 1, End: 100}, Price: 0.5},
+		{Supplier_ID: 1, Mobile_Operator_ID: 1, AmountRange: models.ApiRange{Begin: 101, End: 0}, Price: 0.4},
+		{Supplier_ID: 1, Mobile_Operator_ID: 2, AmountRange: models.ApiRange{Begin: 1, End: 0}, Price: 0.6},
+		{Supplier_ID: 2, Mobile_Operator_ID: 1, AmountRange: models.ApiRange{Begin: 500, End: 1000}, Price: 0.3},
+	}
+	var cases = []struct {
+		supplier_id       int64
+		mobileoperator_id int
+		count             int
+		price             float64
+		ok                bool
+	}{
+		{1, 1, 50, 0.5, true},
+		{1, 1, 100, 0.5, true},
+		{1, 1, 1000, 0.4, true},
+		{1, 2, 5, 0.6, true},
+		{2, 1, 10, 0.3, true},
+		{3, 1, 10, 0, false},
+		{1, 3, 10, 0, false},
+	}
+
+	for _, c := range cases {
+		price, ok := RoutePrice(&smshlrprices, c.supplier_id, c.mobileoperator_id, c.count)
+		if ok != c.ok || price != c.price {
+			t.Error("Price of supplier", c.supplier_id, "for operator", c.mobileoperator_id, "is not properly found", price, ok)
+		}
+	}
+}
@@ -0,0 +1,26 @@
+package workflows
+
+import (
+	"application/services"
+	"time"
+)
+
+type SessionWorkflow struct {
+	SessionRepository services.SessionRepository
+}
+
+func NewSessionWorkflow(sessionrepository services.SessionRepository) *SessionWorkflow {
+	return &SessionWorkflow{
+		SessionRepository: sessionrepository,
+	}
+}
+
+// Удаление сессий, завершенных по неактивности или по времени жизни. Сессии с нехэшированными
+// токенами удаляются один раз при запуске
+func (sessionworkflow *SessionWorkflow) ClearExpired() {
+	_ = sessionworkflow.SessionRepository.DeleteUnhashed()
+	for {
+		_ = sessionworkflow.SessionRepository.DeleteExpired()
+		time.Sleep(time.Hour)
+	}
+}
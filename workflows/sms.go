@@ -1,50 +1,74 @@
 package workflows
 
 import (
-	"application/communication/suppliers"
 	"application/config"
+	"application/gateways"
 	"application/helpers"
 	"application/models"
+	"application/numbering"
 	"application/services"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"github.com/gocql/gocql"
 	libTypes "lib/suppliers/types"
-	"math"
+	"strconv"
 	"time"
-	"unicode"
 )
 
 const (
-	SMS_LENGTH_ASCII  = 153
-	SMS_LENGTH_UICODE = 67
-
 	COLUMN_NAME_SMS_ID           = "SmsId"
 	COLUMN_NAME_SMS_ERROR        = "SmsError"
 	COLUMN_NAME_SMS_STATUS_ID    = "SmsStatusId"
 	COLUMN_NAME_SMS_STATUS_ERROR = "SmsStatusError"
+
+	SMS_STEP_COPY_DATA       = 7
+	SMS_STEP_SEND_SMS        = 8
+	SMS_STEP_SAVE_SMS        = 9
+	SMS_STEP_GET_SMS_STATUS  = 10
+	SMS_STEP_SAVE_SMS_STATUS = 11
+	SMS_STEP_CLEAR_TABLES    = 12
+	SMS_STEP_CLOSE_ORDER     = 13
+
+	SMS_CATCHUP_WINDOW = 15 * time.Minute
 )
 
+// Структура для хранения ответа поставщика в контрольной точке
+type SMSResponseCheckpoint struct {
+	Ids    []string `json:"ids"`    // Идентификаторы сообщений
+	Errors []string `json:"errors"` // Ошибки отправки сообщений
+}
+
 type SMSWorkflow struct {
-	OrderRepository           services.OrderRepository
-	FacilityRepository        services.FacilityRepository
-	SMSFacilityRepository     services.SMSFacilityRepository
-	OrderStatusRepository     services.OrderStatusRepository
-	CustomerTableRepository   services.CustomerTableRepository
-	SMSTableRepository        services.SMSTableRepository
-	SMSSenderRepository       services.SMSSenderRepository
-	ResultTableRepository     services.ResultTableRepository
-	WorkTableRepository       services.WorkTableRepository
-	InvoiceRepository         services.InvoiceRepository
-	CompanyRepository         services.CompanyRepository
-	OperationRepository       services.OperationRepository
-	TransactionTypeRepository services.TransactionTypeRepository
-	TableColumnRepository     services.TableColumnRepository
-	UnitRepository            services.UnitRepository
-	TableRowRepository        services.TableRowRepository
-	PriceRepository           services.PriceRepository
-	MobileOperatorRepository  services.MobileOperatorRepository
-	ColumnTypeRepository      services.ColumnTypeRepository
+	OrderRepository            services.OrderRepository
+	FacilityRepository         services.FacilityRepository
+	SMSFacilityRepository      services.SMSFacilityRepository
+	OrderStatusRepository      services.OrderStatusRepository
+	CustomerTableRepository    services.CustomerTableRepository
+	SMSTableRepository         services.SMSTableRepository
+	SMSSenderRepository        services.SMSSenderRepository
+	ResultTableRepository      services.ResultTableRepository
+	WorkTableRepository        services.WorkTableRepository
+	InvoiceRepository          services.InvoiceRepository
+	CompanyRepository          services.CompanyRepository
+	LedgerRepository           services.LedgerRepository
+	TransactionTypeRepository  services.TransactionTypeRepository
+	TableColumnRepository      services.TableColumnRepository
+	UnitRepository             services.UnitRepository
+	TableRowRepository         services.TableRowRepository
+	PriceRepository            services.PriceRepository
+	MobileOperatorRepository   services.MobileOperatorRepository
+	ColumnTypeRepository       services.ColumnTypeRepository
+	OrderCheckpointRepository  services.OrderCheckpointRepository
+	SMSPeriodRepository        services.SMSPeriodRepository
+	SMSEventRepository         services.SMSEventRepository
+	SMSScheduleRepository      services.SMSScheduleRepository
+	SMSBatchRepository         services.SMSBatchRepository
+	SupplierFacilityRepository services.SupplierFacilityRepository
+	OrderRoutingRepository     services.OrderRoutingRepository
+	OrderRouteRepository       services.OrderRouteRepository
+	SuppressionRepository      services.SuppressionRepository
+	DeliveryReportRepository   services.DeliveryReportRepository
 }
 
 func NewSMSWorkflow(orderrepository services.OrderRepository, facilityrepository services.FacilityRepository,
@@ -52,99 +76,178 @@ func NewSMSWorkflow(orderrepository services.OrderRepository, facilityrepository
 	customertablerepository services.CustomerTableRepository, smstablerepository services.SMSTableRepository,
 	smssenderrepository services.SMSSenderRepository, resulttablerepository services.ResultTableRepository,
 	worktablerepository services.WorkTableRepository, invoicerepository services.InvoiceRepository,
-	companyrepository services.CompanyRepository, operationrepository services.OperationRepository,
+	companyrepository services.CompanyRepository, ledgerrepository services.LedgerRepository,
 	transactiontyperepository services.TransactionTypeRepository, tablecolumnrepository services.TableColumnRepository,
 	unitrepository services.UnitRepository, tablerowrepository services.TableRowRepository,
 	pricerepository services.PriceRepository, mobileoperatorrepository services.MobileOperatorRepository,
-	columntyperepository services.ColumnTypeRepository) *SMSWorkflow {
+	columntyperepository services.ColumnTypeRepository, ordercheckpointrepository services.OrderCheckpointRepository,
+	smsperiodrepository services.SMSPeriodRepository, smseventrepository services.SMSEventRepository,
+	smsschedulerepository services.SMSScheduleRepository, smsbatchrepository services.SMSBatchRepository,
+	supplierfacilityrepository services.SupplierFacilityRepository, orderroutingrepository services.OrderRoutingRepository,
+	orderrouterepository services.OrderRouteRepository, suppressionrepository services.SuppressionRepository,
+	deliveryreportrepository services.DeliveryReportRepository) *SMSWorkflow {
 	return &SMSWorkflow{
-		OrderRepository:           orderrepository,
-		FacilityRepository:        facilityrepository,
-		SMSFacilityRepository:     smsfacilityrepository,
-		OrderStatusRepository:     orderstatusrepository,
-		CustomerTableRepository:   customertablerepository,
-		SMSTableRepository:        smstablerepository,
-		SMSSenderRepository:       smssenderrepository,
-		ResultTableRepository:     resulttablerepository,
-		WorkTableRepository:       worktablerepository,
-		InvoiceRepository:         invoicerepository,
-		CompanyRepository:         companyrepository,
-		OperationRepository:       operationrepository,
-		TransactionTypeRepository: transactiontyperepository,
-		TableColumnRepository:     tablecolumnrepository,
-		UnitRepository:            unitrepository,
-		TableRowRepository:        tablerowrepository,
-		PriceRepository:           pricerepository,
-		MobileOperatorRepository:  mobileoperatorrepository,
-		ColumnTypeRepository:      columntyperepository,
-	}
-}
-
-func SendSMS(supplier *libTypes.Supplier, sms *[]libTypes.Sms) (smsresponse *libTypes.SmsResponse, err error) {
+		OrderRepository:            orderrepository,
+		FacilityRepository:         facilityrepository,
+		SMSFacilityRepository:      smsfacilityrepository,
+		OrderStatusRepository:      orderstatusrepository,
+		CustomerTableRepository:    customertablerepository,
+		SMSTableRepository:         smstablerepository,
+		SMSSenderRepository:        smssenderrepository,
+		ResultTableRepository:      resulttablerepository,
+		WorkTableRepository:        worktablerepository,
+		InvoiceRepository:          invoicerepository,
+		CompanyRepository:          companyrepository,
+		LedgerRepository:           ledgerrepository,
+		TransactionTypeRepository:  transactiontyperepository,
+		TableColumnRepository:      tablecolumnrepository,
+		UnitRepository:             unitrepository,
+		TableRowRepository:         tablerowrepository,
+		PriceRepository:            pricerepository,
+		MobileOperatorRepository:   mobileoperatorrepository,
+		ColumnTypeRepository:       columntyperepository,
+		OrderCheckpointRepository:  ordercheckpointrepository,
+		SMSPeriodRepository:        smsperiodrepository,
+		SMSEventRepository:         smseventrepository,
+		SMSScheduleRepository:      smsschedulerepository,
+		SMSBatchRepository:         smsbatchrepository,
+		SupplierFacilityRepository: supplierfacilityrepository,
+		OrderRoutingRepository:     orderroutingrepository,
+		OrderRouteRepository:       orderrouterepository,
+		SuppressionRepository:      suppressionrepository,
+		DeliveryReportRepository:   deliveryreportrepository,
+	}
+}
+
+func SendSMS(gateway gateways.Gateway, supplier *libTypes.Supplier, sms *[]libTypes.Sms) (smsresponse *libTypes.SmsResponse, err error) {
 	// Отправка SMS
-	suppliers.WaitClientReady()                         // Функция блокируется до момента пока связь с сервером не будет установлена
-	response, err := suppliers.SendSms(*supplier, *sms) // Функция не блокируется. Если связи с сервером нет, то вернётся ошибка
+	smsresponse, err = gateway.SendSms(supplier, sms)
 	if err != nil {
 		log.Error("Can't send SMS messages %v for supplier %v", err, supplier.Name)
 		return nil, err
 	}
 
-	return &response, nil
+	return smsresponse, nil
 }
 
-func GetSMSStatus(smsresponse *libTypes.SmsResponse) (smsstatuses map[gocql.UUID]libTypes.SmsStatus, err error) {
+// Получение статусов сообщений из отчетов о доставке поставщика или опросом поставщика до истечения срока
+func GetSMSStatus(gateway gateways.Gateway, smsresponse *libTypes.SmsResponse, order_id int64, deadline time.Time,
+	deliveryreportrepository services.DeliveryReportRepository) (smsstatuses map[gocql.UUID]libTypes.SmsStatus, err error) {
 	smsstatuses = make(map[gocql.UUID]libTypes.SmsStatus)
-	// Получение статуса по UUID запроса
-	for {
-		final := true
-		for i := range smsresponse.Ids {
-			var status libTypes.SmsStatus
-			status, err = suppliers.StatusSms(smsresponse.Ids[i])
+	err = PollDelivery(order_id, models.DELIVERY_REPORT_TYPE_SMS, smsresponse.Ids, deadline, deliveryreportrepository,
+		func(id gocql.UUID) (final bool, err error) {
+			// Получение статуса по UUID запроса
+			status, err := gateway.StatusSms(id)
 			if err != nil {
 				log.Error("Can't get SMS statuses %v", err)
-				return map[gocql.UUID]libTypes.SmsStatus{}, err
+				return false, err
 			}
 			if status.Final {
 				smsstatuses[status.Id] = status
-			} else {
-				final = false
 			}
-		}
-		if final {
-			break
-		}
-		time.Sleep(time.Second)
+			return status.Final, nil
+		},
+		func(id gocql.UUID, dtodeliveryreport *models.DtoDeliveryReport) {
+			var status libTypes.SmsStatus
+			FillDeliveryStatus(&status, id, dtodeliveryreport)
+			smsstatuses[id] = status
+		})
+	if err != nil {
+		return map[gocql.UUID]libTypes.SmsStatus{}, err
 	}
 
 	return smsstatuses, nil
 }
 
-func CalculateSMSQuantity(sms string) (count int) {
-	count = 0
-	runes := []rune(sms)
-	isASCII := true
-	for _, current_rune := range runes {
-		if current_rune > unicode.MaxASCII {
-			isASCII = false
-			break
+func EncodeSMSResponse(smsresponse *libTypes.SmsResponse) (data string, err error) {
+	checkpoint := new(SMSResponseCheckpoint)
+	for i := range smsresponse.Ids {
+		checkpoint.Ids = append(checkpoint.Ids, smsresponse.Ids[i].String())
+		message := ""
+		if i < len(smsresponse.Errors) && smsresponse.Errors[i] != nil {
+			message = smsresponse.Errors[i].Error()
 		}
+		checkpoint.Errors = append(checkpoint.Errors, message)
+	}
+	buf, err := json.Marshal(checkpoint)
+	if err != nil {
+		log.Error("Can't encode SMS response %v", err)
+		return "", err
 	}
-	length := 0
-	if isASCII {
-		length = SMS_LENGTH_ASCII
-		count = len(runes) / length
 
-	} else {
-		length = SMS_LENGTH_UICODE
-		count = len(runes) / length
+	return string(buf), nil
+}
+
+func DecodeSMSResponse(data string) (smsresponse *libTypes.SmsResponse, err error) {
+	checkpoint := new(SMSResponseCheckpoint)
+	err = json.Unmarshal([]byte(data), checkpoint)
+	if err != nil {
+		log.Error("Can't decode SMS response %v", err)
+		return nil, err
 	}
-	if math.Mod(float64(len(runes)), float64(length)) != 0 || sms == "" {
-		count++
+	smsresponse = new(libTypes.SmsResponse)
+	for i := range checkpoint.Ids {
+		id, err := gocql.ParseUUID(checkpoint.Ids[i])
+		if err != nil {
+			log.Error("Can't parse sms id %v, %v", err, checkpoint.Ids[i])
+			return nil, err
+		}
+		smsresponse.Ids = append(smsresponse.Ids, id)
+		if checkpoint.Errors[i] != "" {
+			smsresponse.Errors = append(smsresponse.Errors, errors.New(checkpoint.Errors[i]))
+		} else {
+			smsresponse.Errors = append(smsresponse.Errors, nil)
+		}
 	}
 
+	return smsresponse, nil
+}
+
+// Количество частей sms сообщения с учетом кодировки GSM-7 или UCS-2
+func CalculateSMSQuantity(sms string) (count int) {
+	_, _, count = helpers.CalculateSMSSegments(sms)
+
 	return count
 }
 
+// Колонки таблицы рассылки, значения которых подставляются в текст рассылки
+func (smsworkflow *SMSWorkflow) GetTemplateColumns(dtosmsfacility *models.DtoSMSFacility) (
+	templatecolumns map[string]models.DtoTableColumn, err error) {
+	templatecolumns = make(map[string]models.DtoTableColumn)
+	if dtosmsfacility.MessageBodyInColumnId != 0 || len(helpers.GetSMSPlaceholders(dtosmsfacility.MessageBody)) == 0 {
+		return templatecolumns, nil
+	}
+	tablecolumns, err := smsworkflow.TableColumnRepository.GetByTable(dtosmsfacility.DeliveryDataId)
+	if err != nil {
+		return nil, err
+	}
+	templatecolumns, missed := helpers.GetSMSTemplateColumns(dtosmsfacility.MessageBody, tablecolumns)
+	if len(missed) != 0 {
+		log.Error("Can't find columns %v of message template in table %v", missed, dtosmsfacility.DeliveryDataId)
+		return nil, errors.New("Wrong message template")
+	}
+
+	return templatecolumns, nil
+}
+
+// Текст рассылки с подставленными значениями колонок строки таблицы
+func RenderSMSMessage(apitablerow *models.ApiInfoTableRow, body string, templatecolumns map[string]models.DtoTableColumn) (message string) {
+	if len(templatecolumns) == 0 {
+		return body
+	}
+	values := make(map[string]string)
+	for name, templatecolumn := range templatecolumns {
+		for _, apitablecell := range apitablerow.Cells {
+			if apitablecell.Table_Column_ID == templatecolumn.ID {
+				values[name] = apitablecell.Value
+				break
+			}
+		}
+	}
+
+	return helpers.RenderSMSMessage(body, values)
+}
+
 func (smsworkflow *SMSWorkflow) CheckSMSOrder(dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility) (columnmobilephone_id int64, err error) {
 	smstable, err := smsworkflow.SMSTableRepository.Get(dtosmsfacility.DeliveryDataId)
 	if err != nil {
@@ -254,25 +357,50 @@ func (smsworkflow *SMSWorkflow) GetSMSTableColumns(dtosmsfacility *models.DtoSMS
 		return nil, nil, nil, nil, err
 	}
 	*tablecolumns = append(*tablecolumns, *columnmobilephone)
+	templatecolumns, err := smsworkflow.GetTemplateColumns(dtosmsfacility)
+	if err != nil {
+		return nil, nil, nil, nil, err
+	}
+	for _, templatecolumn := range templatecolumns {
+		found := false
+		for _, tablecolumn := range *tablecolumns {
+			if tablecolumn.ID == templatecolumn.ID {
+				found = true
+				break
+			}
+		}
+		if !found {
+			*tablecolumns = append(*tablecolumns, templatecolumn)
+		}
+	}
 
 	return columnmessage, columnmobilephone, columnsmssender, tablecolumns, nil
 }
 
 func (smsworkflow *SMSWorkflow) CalculateCost(apitablerows *[]models.ApiInfoTableRow, columnmessage, columnmobilephone, columnsender *models.DtoTableColumn,
 	dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility) (cost float64, err error) {
-	dtomobileoperators, err := smsworkflow.MobileOperatorRepository.FindAll()
+	quote, err := smsworkflow.QuoteRows(apitablerows, columnmessage, columnmobilephone, columnsender, dtoorder, dtosmsfacility)
 	if err != nil {
 		return 0, err
 	}
-	mobileoperators_uuid := make(map[string]*models.DtoMobileOperator)
-	mobileoperators_id := make(map[int]*models.DtoMobileOperator)
-	for index := range *dtomobileoperators {
-		mobileoperators_uuid[(*dtomobileoperators)[index].UUID] = &(*dtomobileoperators)[index]
-		mobileoperators_id[(*dtomobileoperators)[index].ID] = &(*dtomobileoperators)[index]
+	if len(quote.Unpriced) != 0 {
+		log.Error("Can't calculate cost of %v rows for order %v, first reason %v", len(quote.Unpriced), dtoorder.ID, quote.Unpriced[0].Reason)
+		return 0, errors.New("Unpriced rows")
 	}
+
+	return quote.Cost, nil
+}
+
+// Расчет стоимости строк таблицы по мобильным операторам и отправителям
+func (smsworkflow *SMSWorkflow) QuoteRows(apitablerows *[]models.ApiInfoTableRow, columnmessage, columnmobilephone, columnsender *models.DtoTableColumn,
+	dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility) (quote *models.ApiOrderQuote, err error) {
 	dtocolumntype, err := smsworkflow.ColumnTypeRepository.Get(columnmobilephone.Column_Type_ID)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	templatecolumns, err := smsworkflow.GetTemplateColumns(dtosmsfacility)
+	if err != nil {
+		return nil, err
 	}
 	unitedsmscount := 0
 	if columnmessage == nil {
@@ -282,146 +410,154 @@ func (smsworkflow *SMSWorkflow) CalculateCost(apitablerows *[]models.ApiInfoTabl
 	if columnsender == nil {
 		dtosmsender, err := smsworkflow.SMSSenderRepository.Get(dtosmsfacility.MessageFromId)
 		if err != nil {
-			return 0, err
+			return nil, err
 		}
 		unitedsmssender = dtosmsender.Name
 	}
 
-	mobilephones := []uint64{}
-	mobilesmses := []int{}
-	smssenders := []string{}
+	recipients := []models.DtoQuoteRecipient{}
 	for _, apitablerow := range *apitablerows {
-		var smscount int = 0
-		var mobilephone uint64 = 0
-		var smssender = ""
-		if columnmessage == nil {
-			smscount = unitedsmscount
-		}
-		if columnsender == nil {
-			smssender = unitedsmssender
+		recipient := models.NewDtoQuoteRecipient(apitablerow.ID, "", 0, unitedsmssender, unitedsmscount, "")
+		if columnmessage == nil && len(templatecolumns) != 0 {
+			recipient.Units = CalculateSMSQuantity(RenderSMSMessage(&apitablerow, dtosmsfacility.MessageBody, templatecolumns))
 		}
 		for _, apitablecell := range apitablerow.Cells {
 			if columnmessage != nil {
 				if apitablecell.Table_Column_ID == columnmessage.ID {
-					smscount = CalculateSMSQuantity(apitablecell.Value)
+					recipient.Units = CalculateSMSQuantity(apitablecell.Value)
 				}
 			}
 			if apitablecell.Table_Column_ID == columnmobilephone.ID {
-				mobilephone, err = helpers.CheckMobilePhone(apitablecell.Value, dtocolumntype, smsworkflow.ColumnTypeRepository)
+				recipient.Value = apitablecell.Value
+				recipient.MobilePhone, err = helpers.CheckMobilePhone(apitablecell.Value, dtocolumntype, smsworkflow.ColumnTypeRepository)
 				if err != nil {
-					return 0, err
+					recipient.Reason = models.QUOTE_REASON_WRONG_MOBILE_PHONE
 				}
 			}
 			if columnsender != nil {
 				if apitablecell.Table_Column_ID == columnsender.ID {
-					smssender = apitablecell.Value
+					recipient.Sender = apitablecell.Value
 				}
 			}
 		}
-		mobilephones = append(mobilephones, mobilephone)
-		mobilesmses = append(mobilesmses, smscount)
-		smssenders = append(smssenders, smssender)
+		recipients = append(recipients, *recipient)
 	}
 
-	mobileoperatoruuids, err := suppliers.MobileOperator(mobilephones)
+	quote = models.NewApiOrderQuote(models.SERVICE_TYPE_SMS, int64(len(*apitablerows)))
+	recipients, err = smsworkflow.QuoteSuppressed(quote, dtoorder.Unit_ID, recipients)
 	if err != nil {
-		log.Error("Error during detecting mobile operators %v", err)
-		return 0, err
+		return nil, err
 	}
-	defaultmobileoperator, err := smsworkflow.MobileOperatorRepository.GetDefault()
+	err = QuoteMobileOperators(quote, recipients, dtoorder.Supplier_ID, smsworkflow.UnitRepository, smsworkflow.PriceRepository,
+		smsworkflow.TableColumnRepository, smsworkflow.TableRowRepository, smsworkflow.MobileOperatorRepository)
 	if err != nil {
-		return 0, err
+		return nil, err
 	}
-	mobileoperatorsmses := make(map[int]map[string]int)
-	for index := range mobileoperatoruuids {
-		if mobileoperatoruuids[index].Id.String() == models.MOBILE_OPERATOR_UUID_UNKNOWN {
-			log.Error("Not existed mobile operator for phone %v", mobilephones[index])
-			return 0, errors.New("Not existed mobile operator")
-		}
-		mobileoperator, ok := mobileoperators_uuid[mobileoperatoruuids[index].Id.String()]
-		if !ok {
-			mobileoperator = defaultmobileoperator
+
+	return quote, nil
+}
+
+// Исключение из расчета получателей, номера которых находятся в списках запрета рассылок
+func (smsworkflow *SMSWorkflow) QuoteSuppressed(quote *models.ApiOrderQuote, unit_id int64,
+	recipients []models.DtoQuoteRecipient) (priceable []models.DtoQuoteRecipient, err error) {
+	mobilephones := []uint64{}
+	for _, recipient := range recipients {
+		if recipient.Reason == "" {
+			mobilephones = append(mobilephones, recipient.MobilePhone)
 		}
-		mobileoperatorsenders, ok := mobileoperatorsmses[mobileoperator.ID]
-		if !ok {
-			mobileoperatorsenders = make(map[string]int)
-			mobileoperatorsmses[mobileoperator.ID] = mobileoperatorsenders
+	}
+	reasons, err := smsworkflow.SuppressionRepository.FindSuppressed(unit_id, mobilephones)
+	if err != nil {
+		return nil, err
+	}
+	priceable = []models.DtoQuoteRecipient{}
+	for _, recipient := range recipients {
+		if _, ok := reasons[recipient.MobilePhone]; ok && recipient.Reason == "" {
+			quote.AddSuppressed(recipient.Row_ID, recipient.Value)
+			continue
 		}
-		mobileoperatorsenders[smssenders[index]] += mobilesmses[index]
+		priceable = append(priceable, recipient)
 	}
 
-	smshlrprices, err := helpers.GetSMSHLRPrices(models.SERVICE_TYPE_SMS, dtoorder.Supplier_ID, nil, smsworkflow.PriceRepository,
-		smsworkflow.TableColumnRepository, smsworkflow.TableRowRepository,
-		smsworkflow.MobileOperatorRepository, "", true)
+	return priceable, nil
+}
+
+// Предварительный расчет стоимости заказа по всем строкам таблицы рассылки без изменения состояния заказа
+func (smsworkflow *SMSWorkflow) Quote(dtoorder *models.DtoOrder) (quote *models.ApiOrderQuote, err error) {
+	dtosmsfacility, err := smsworkflow.SMSFacilityRepository.Get(dtoorder.ID)
 	if err != nil {
-		return 0, err
+		return nil, err
+	}
+	columnmobilephone_id, err := smsworkflow.CheckSMSOrder(dtoorder, dtosmsfacility)
+	if err != nil {
+		return nil, err
+	}
+	columnmessage, columnmobilephone, columnsmssender, tablecolumns, err := smsworkflow.GetSMSTableColumns(dtosmsfacility, columnmobilephone_id)
+	if err != nil {
+		return nil, err
+	}
+	apitablerows, err := smsworkflow.TableRowRepository.GetAll("", "", dtosmsfacility.DeliveryDataId, tablecolumns)
+	if err != nil {
+		return nil, err
+	}
+	quote, err = smsworkflow.QuoteRows(apitablerows, columnmessage, columnmobilephone, columnsmssender, dtoorder, dtosmsfacility)
+	if err != nil {
+		return nil, err
+	}
+	err = QuoteVAT(quote, dtoorder.Unit_ID, smsworkflow.CompanyRepository)
+	if err != nil {
+		return nil, err
 	}
 
-	pricemobileoperators := make(map[int]int)
-	for _, smshlrprice := range *smshlrprices {
-		pricemobileoperators[smshlrprice.Mobile_Operator_ID] = smshlrprice.Mobile_Operator_ID
-	}
-
-	cost = 0
-	for mobileoperator_id, mobileoperatorsenders := range mobileoperatorsmses {
-		pricemobileoperator_id, ok := pricemobileoperators[mobileoperator_id]
-		if !ok {
-			pricemobileoperator_id, ok = pricemobileoperators[defaultmobileoperator.ID]
-			if !ok {
-				log.Error("Can't find default mobile operator %v in price list for supplier %v", defaultmobileoperator.ID, dtoorder.Supplier_ID)
-				return 0, errors.New("Missed default mobile operator in price list")
-			}
-		}
-
-		foundprices := make(map[string]bool)
-		for smssender := range mobileoperatorsenders {
-			foundprices[smssender] = false
-		}
-		for _, smshlrprice := range *smshlrprices {
-			if smshlrprice.Mobile_Operator_ID == pricemobileoperator_id {
-				if mobileoperators_id[pricemobileoperator_id].SMSBillingModel == models.BILLING_MODEL_RANGE {
-					count := 0
-					for _, smscount := range mobileoperatorsenders {
-						count += smscount
-					}
-					if count >= smshlrprice.AmountRange.Begin && (count <= smshlrprice.AmountRange.End || smshlrprice.AmountRange.End == 0) {
-						cost += float64(count) * smshlrprice.Price
-						for smssender := range foundprices {
-							foundprices[smssender] = true
-						}
-						break
-					}
-				}
-				if mobileoperators_id[pricemobileoperator_id].SMSBillingModel == models.BILLING_MODEL_CUMULATIVE_PERHEADER {
-					for smssender, count := range mobileoperatorsenders {
-						if count >= smshlrprice.AmountRange.Begin && (count > smshlrprice.AmountRange.End && smshlrprice.AmountRange.End != 0) {
-							cost += float64(smshlrprice.AmountRange.End-smshlrprice.AmountRange.Begin+1) * smshlrprice.Price
-						}
-						if count >= smshlrprice.AmountRange.Begin && (count <= smshlrprice.AmountRange.End || smshlrprice.AmountRange.End == 0) {
-							cost += float64(count-smshlrprice.AmountRange.Begin+1) * smshlrprice.Price
-							foundprices[smssender] = true
-						}
-					}
+	return quote, nil
+}
+
+// Предварительный просмотр текстов рассылки по строкам таблицы с распределением сообщений по количеству частей
+func (smsworkflow *SMSWorkflow) Preview(dtoorder *models.DtoOrder, samples int) (preview *models.ApiSMSPreview, err error) {
+	dtosmsfacility, err := smsworkflow.SMSFacilityRepository.Get(dtoorder.ID)
+	if err != nil {
+		return nil, err
+	}
+	columnmobilephone_id, err := smsworkflow.CheckSMSOrder(dtoorder, dtosmsfacility)
+	if err != nil {
+		return nil, err
+	}
+	columnmessage, _, _, tablecolumns, err := smsworkflow.GetSMSTableColumns(dtosmsfacility, columnmobilephone_id)
+	if err != nil {
+		return nil, err
+	}
+	templatecolumns, err := smsworkflow.GetTemplateColumns(dtosmsfacility)
+	if err != nil {
+		return nil, err
+	}
+	apitablerows, err := smsworkflow.TableRowRepository.GetAll("", "", dtosmsfacility.DeliveryDataId, tablecolumns)
+	if err != nil {
+		return nil, err
+	}
+
+	preview = models.NewApiSMSPreview()
+	for _, apitablerow := range *apitablerows {
+		message := ""
+		if columnmessage != nil {
+			for _, apitablecell := range apitablerow.Cells {
+				if apitablecell.Table_Column_ID == columnmessage.ID {
+					message = apitablecell.Value
 				}
 			}
+		} else {
+			message = RenderSMSMessage(&apitablerow, dtosmsfacility.MessageBody, templatecolumns)
 		}
-
-		for _, found := range foundprices {
-			if !found {
-				log.Error("Can't find  mobile operator %v in price list for supplier %v", pricemobileoperator_id, dtoorder.Supplier_ID)
-				return 0, errors.New("Missed obile operator in price list")
-			}
+		encoding, length, segments := helpers.CalculateSMSSegments(message)
+		preview.AddMessage(encoding, segments)
+		if len(preview.Samples) < samples {
+			preview.Samples = append(preview.Samples, *models.NewApiSMSSample(apitablerow.ID, message, encoding, length, segments))
 		}
 	}
 
-	return cost, nil
+	return preview, nil
 }
 
-func (smsworkflow *SMSWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility, balance float64) (err error) {
-	if balance < dtosmsfacility.Cost {
-		log.Error("Not enough money at unit balance %v to pay for order %v execution", dtoorder.Unit_ID, dtoorder.ID)
-		return errors.New("Not enough money")
-	}
+func (smsworkflow *SMSWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility) (err error) {
 	dtocompany, err := smsworkflow.CompanyRepository.GetPrimaryByUnit(dtoorder.Unit_ID)
 	if err != nil {
 		return
@@ -443,9 +579,9 @@ func (smsworkflow *SMSWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtosmsf
 	dtoinvoice := new(models.DtoInvoice)
 	dtoinvoice.Company_ID = dtocompany.ID
 	if dtocompany.VAT != 0 {
-		dtoinvoice.VAT = (dtosmsfacility.Cost / (1 + float64(dtocompany.VAT)/100)) * float64(dtocompany.VAT) / 100
+		dtoinvoice.VAT = (dtosmsfacility.CostFactual / (1 + float64(dtocompany.VAT)/100)) * float64(dtocompany.VAT) / 100
 	}
-	dtoinvoice.Total = dtosmsfacility.Cost
+	dtoinvoice.Total = dtosmsfacility.CostFactual
 	dtoinvoice.Paid = true
 	dtoinvoice.Created = time.Now()
 	dtoinvoice.Active = true
@@ -461,13 +597,51 @@ func (smsworkflow *SMSWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtosmsf
 	return nil
 }
 
+// Оплата фактической стоимости рассылки при закрытии. Тарифицируются только сообщения, принятые поставщиком,
+// остаток резерва снимается. Повторно окно рассылки не оплачивается
+func (smsworkflow *SMSWorkflow) Capture(dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility,
+	apitablerows *[]models.ApiInfoTableRow, smsresponse *libTypes.SmsResponse,
+	columnmessage, columnmobilephone, columnsmssender *models.DtoTableColumn) (err error) {
+	paid, err := IsOrderPaid(dtoorder.ID, smsworkflow.OrderStatusRepository)
+	if err != nil || paid {
+		return err
+	}
+
+	accepted := new([]models.ApiInfoTableRow)
+	for index, apitablerow := range *apitablerows {
+		if index < len(smsresponse.Errors) && smsresponse.Errors[index] == nil {
+			*accepted = append(*accepted, apitablerow)
+		}
+	}
+	dtosmsfacility.CostFactual = 0
+	if len(*accepted) != 0 {
+		dtosmsfacility.CostFactual, err = smsworkflow.CalculateCost(accepted, columnmessage, columnmobilephone, columnsmssender,
+			dtoorder, dtosmsfacility)
+		if err != nil {
+			return err
+		}
+	}
+	err = smsworkflow.SMSFacilityRepository.Update(dtosmsfacility, true, false)
+	if err != nil {
+		return err
+	}
+	if dtosmsfacility.CostFactual == 0 {
+		log.Info("Nothing has been sent for order %v, releasing held cost", dtoorder.ID)
+		return smsworkflow.LedgerRepository.Release(dtoorder.ID)
+	}
+
+	log.Info("Order payment and invoicement ...")
+	return smsworkflow.PayAndInvoice(dtoorder, dtosmsfacility)
+}
+
 func (smsworkflow *SMSWorkflow) SendSMS(apitablerows *[]models.ApiInfoTableRow, dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility,
 	columnsmssender, columnmessage, columnmobilephone *models.DtoTableColumn) (smsresponse *libTypes.SmsResponse, err error) {
 	dtosupplier, err := smsworkflow.UnitRepository.Get(dtoorder.Supplier_ID)
 	if err != nil {
 		return nil, err
 	}
-	smssupplier, err := GetSupplier(dtosupplier.UUID)
+	gateway := gateways.Get(dtosupplier.UUID)
+	smssupplier, err := gateway.Supplier(dtosupplier.UUID)
 	if err != nil {
 		return nil, err
 	}
@@ -483,13 +657,17 @@ func (smsworkflow *SMSWorkflow) SendSMS(apitablerows *[]models.ApiInfoTableRow,
 	if err != nil {
 		return nil, err
 	}
+	templatecolumns, err := smsworkflow.GetTemplateColumns(dtosmsfacility)
+	if err != nil {
+		return nil, err
+	}
 
 	sms := new([]libTypes.Sms)
 	for _, apitablerow := range *apitablerows {
 		obj := new(libTypes.Sms)
 		obj.Flash = false
 		if columnmessage == nil {
-			obj.Message = []byte(dtosmsfacility.MessageBody)
+			obj.Message = []byte(RenderSMSMessage(&apitablerow, dtosmsfacility.MessageBody, templatecolumns))
 		}
 		if columnsmssender == nil {
 			obj.Sender = smssender
@@ -514,16 +692,82 @@ func (smsworkflow *SMSWorkflow) SendSMS(apitablerows *[]models.ApiInfoTableRow,
 		}
 		*sms = append(*sms, *obj)
 	}
-	smsresponse, err = SendSMS(smssupplier, sms)
+	mobilephones := []uint64{}
+	for _, obj := range *sms {
+		mobilephones = append(mobilephones, obj.Recipient)
+	}
+	reasons, err := smsworkflow.SuppressionRepository.FindSuppressed(dtoorder.Unit_ID, mobilephones)
 	if err != nil {
 		return nil, err
 	}
+	sendable := new([]libTypes.Sms)
+	indexes := []int{}
+	for index, obj := range *sms {
+		if _, ok := reasons[obj.Recipient]; !ok {
+			*sendable = append(*sendable, obj)
+			indexes = append(indexes, index)
+		}
+	}
+	if len(*sendable) == 0 {
+		log.Info("All %v recipients of order %v are suppressed", len(*sms), dtoorder.ID)
+		return SuppressSMS(sms, indexes, new(libTypes.SmsResponse), reasons)
+	}
 
-	return smsresponse, nil
+	dtoorderrouting, err := smsworkflow.OrderRoutingRepository.Get(dtoorder.ID)
+	if err != nil {
+		return nil, err
+	}
+	if dtoorderrouting.Policy != models.ROUTING_POLICY_MANUAL {
+		smsresponse, err = smsworkflow.RouteSMS(dtoorder, dtoorderrouting.Policy, sendable)
+	} else {
+		smsresponse, err = SendSMS(gateway, smssupplier, sendable)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return SuppressSMS(sms, indexes, smsresponse, reasons)
+}
+
+// Ответ поставщика по всем сообщениям, в котором сообщения получателей из списков запрета рассылок отмечены ошибкой
+func SuppressSMS(sms *[]libTypes.Sms, indexes []int, smsresponse *libTypes.SmsResponse,
+	reasons map[uint64]string) (fullresponse *libTypes.SmsResponse, err error) {
+	if len(smsresponse.Ids) != len(indexes) {
+		log.Error("SMS response size %v doesn't match sent messages %v", len(smsresponse.Ids), len(indexes))
+		return nil, errors.New("Wrong sms response size")
+	}
+	fullresponse = new(libTypes.SmsResponse)
+	fullresponse.Ids = make([]gocql.UUID, len(*sms))
+	fullresponse.Errors = make([]error, len(*sms))
+	for k, index := range indexes {
+		fullresponse.Ids[index] = smsresponse.Ids[k]
+		if k < len(smsresponse.Errors) {
+			fullresponse.Errors[index] = smsresponse.Errors[k]
+		}
+	}
+	for index, obj := range *sms {
+		if reason, ok := reasons[obj.Recipient]; ok {
+			message := models.SUPPRESSION_ERROR
+			if reason != "" {
+				message += ": " + reason
+			}
+			fullresponse.Errors[index] = errors.New(message)
+		}
+	}
+
+	return fullresponse, nil
 }
 
 func (smsworkflow *SMSWorkflow) CopyData(dtoorder *models.DtoOrder,
 	dtodatatable *models.DtoCustomerTable) (dtoworkdatatable *models.DtoCustomerTable, err error) {
+	worktables, err := smsworkflow.WorkTableRepository.GetByOrder(dtoorder.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(*worktables) != 0 {
+		return smsworkflow.CustomerTableRepository.Get((*worktables)[0].Customer_Table_ID)
+	}
+
 	dtoworkdatatable, err = smsworkflow.CustomerTableRepository.Copy(dtodatatable, true)
 	if err != nil {
 		return nil, err
@@ -548,42 +792,21 @@ func (smsworkflow *SMSWorkflow) CopyData(dtoorder *models.DtoOrder,
 	return dtoworkdatatable, nil
 }
 
-func (smsworkflow *SMSWorkflow) SaveSMS(dtoworkdatatable *models.DtoCustomerTable, smsresponse *libTypes.SmsResponse) (err error) {
-	position, err := smsworkflow.TableColumnRepository.GetDefaultPosition(dtoworkdatatable.ID)
+func (smsworkflow *SMSWorkflow) SaveSMS(dtoworkdatatable *models.DtoCustomerTable, smsresponse *libTypes.SmsResponse,
+	dtosmsfacility *models.DtoSMSFacility, window time.Time) (err error) {
+	tablecolumns, err := GetOrCreateColumns(dtoworkdatatable, []string{COLUMN_NAME_SMS_ID, COLUMN_NAME_SMS_ERROR}, smsworkflow.TableColumnRepository)
 	if err != nil {
 		return err
 	}
-	tablecolumns := []models.DtoTableColumn{}
-	columnnames := []string{COLUMN_NAME_SMS_ID, COLUMN_NAME_SMS_ERROR}
-	for _, columnname := range columnnames {
-		position++
-		fieldnum, err := helpers.FindFreeColumnInternal(dtoworkdatatable.ID, 0, smsworkflow.TableColumnRepository)
-		if err != nil {
-			return err
-		}
-
-		dtotablecolumn := new(models.DtoTableColumn)
-		dtotablecolumn.Created = time.Now()
-		dtotablecolumn.Position = position
-		dtotablecolumn.Name = columnname
-		dtotablecolumn.Customer_Table_ID = dtoworkdatatable.ID
-		dtotablecolumn.Column_Type_ID = models.COLUMN_TYPE_DEFAULT
-		dtotablecolumn.Prebuilt = true
-		dtotablecolumn.FieldNum = fieldnum
-		dtotablecolumn.Active = true
-		dtotablecolumn.Edition = 0
-
-		err = smsworkflow.TableColumnRepository.Create(dtotablecolumn, nil)
-		if err != nil {
-			return err
-		}
-		tablecolumns = append(tablecolumns, *dtotablecolumn)
-	}
 
-	apitablerows, err := smsworkflow.TableRowRepository.GetAll("", "", dtoworkdatatable.ID, &tablecolumns)
+	apitablerows, err := smsworkflow.GetSMSRows(dtoworkdatatable.ID, dtosmsfacility, &tablecolumns, window)
 	if err != nil {
 		return err
 	}
+	if len(*apitablerows) != len(smsresponse.Ids) {
+		log.Error("SMS response size %v doesn't match table %v rows %v", len(smsresponse.Ids), dtoworkdatatable.ID, len(*apitablerows))
+		return errors.New("Wrong sms response size")
+	}
 
 	workdatatablecolumns, err := smsworkflow.TableColumnRepository.GetByTable(dtoworkdatatable.ID)
 	if err != nil {
@@ -643,36 +866,12 @@ func (smsworkflow *SMSWorkflow) SaveSMSStatus(dtoworkdatatable *models.DtoCustom
 		return errors.New("Missed sms id column")
 	}
 
-	position, err := smsworkflow.TableColumnRepository.GetDefaultPosition(dtoworkdatatable.ID)
+	statuscolumns, err := GetOrCreateColumns(dtoworkdatatable, []string{COLUMN_NAME_SMS_STATUS_ID, COLUMN_NAME_SMS_STATUS_ERROR},
+		smsworkflow.TableColumnRepository)
 	if err != nil {
 		return err
 	}
-	tablecolumns := []models.DtoTableColumn{*columnsmsid}
-	columnnames := []string{COLUMN_NAME_SMS_STATUS_ID, COLUMN_NAME_SMS_STATUS_ERROR}
-	for _, columnname := range columnnames {
-		position++
-		fieldnum, err := helpers.FindFreeColumnInternal(dtoworkdatatable.ID, 0, smsworkflow.TableColumnRepository)
-		if err != nil {
-			return err
-		}
-
-		dtotablecolumn := new(models.DtoTableColumn)
-		dtotablecolumn.Created = time.Now()
-		dtotablecolumn.Position = position
-		dtotablecolumn.Name = columnname
-		dtotablecolumn.Customer_Table_ID = dtoworkdatatable.ID
-		dtotablecolumn.Column_Type_ID = models.COLUMN_TYPE_DEFAULT
-		dtotablecolumn.Prebuilt = true
-		dtotablecolumn.FieldNum = fieldnum
-		dtotablecolumn.Active = true
-		dtotablecolumn.Edition = 0
-
-		err = smsworkflow.TableColumnRepository.Create(dtotablecolumn, nil)
-		if err != nil {
-			return err
-		}
-		tablecolumns = append(tablecolumns, *dtotablecolumn)
-	}
+	tablecolumns := append([]models.DtoTableColumn{*columnsmsid}, statuscolumns...)
 
 	apitablerows, err := smsworkflow.TableRowRepository.GetAll("", "", dtoworkdatatable.ID, &tablecolumns)
 	if err != nil {
@@ -697,8 +896,14 @@ func (smsworkflow *SMSWorkflow) SaveSMSStatus(dtoworkdatatable *models.DtoCustom
 
 		var smsid gocql.UUID
 		found := false
+		sent := true
 		for i, _ := range *tablecells {
 			if (*tablecells)[i].Table_Column_ID == columnsmsid.ID {
+				if (*tablecells)[i].Value == "" {
+					// Строка не попала в рассылку текущего окна
+					sent = false
+					break
+				}
 				smsid, err = gocql.ParseUUID((*tablecells)[i].Value)
 				if err != nil {
 					log.Error("Can't parse sms id from column %v, %v", err, columnsmsid.ID)
@@ -708,6 +913,9 @@ func (smsworkflow *SMSWorkflow) SaveSMSStatus(dtoworkdatatable *models.DtoCustom
 				break
 			}
 		}
+		if !sent {
+			continue
+		}
 		if !found {
 			log.Error("Can't find sms id value for column %v", columnsmsid.ID)
 			return errors.New("Missed sms id value")
@@ -734,9 +942,10 @@ func (smsworkflow *SMSWorkflow) SaveSMSStatus(dtoworkdatatable *models.DtoCustom
 	return nil
 }
 
+// Освобождение таблиц после рассылки. Таблица данных удаляется только после последней рассылки
 func (smsworkflow *SMSWorkflow) ClearTables(dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility,
-	dtodatatable *models.DtoCustomerTable) (err error) {
-	if dtosmsfacility.DeliveryDataDelete {
+	dtodatatable *models.DtoCustomerTable, final bool) (err error) {
+	if final && dtosmsfacility.DeliveryDataDelete {
 		err = smsworkflow.CustomerTableRepository.Deactivate(dtodatatable)
 		if err != nil {
 			return err
@@ -767,178 +976,899 @@ func (smsworkflow *SMSWorkflow) ClearTables(dtoorder *models.DtoOrder, dtosmsfac
 	return nil
 }
 
-func (smsworkflow *SMSWorkflow) SetStatus(dtoorder *models.DtoOrder, orderstatus models.OrderStatus, active bool) (err error) {
-	dtoorderstatus := models.NewDtoOrderStatus(dtoorder.ID, orderstatus, active, "", time.Now())
-	err = smsworkflow.OrderStatusRepository.Save(dtoorderstatus, nil)
+func (smsworkflow *SMSWorkflow) GetCheckpoints(order_id int64) (checkpoints map[int]models.DtoOrderCheckpoint, err error) {
+	dtocheckpoints, err := smsworkflow.OrderCheckpointRepository.GetByOrder(order_id)
+	if err != nil {
+		return nil, err
+	}
+	checkpoints = make(map[int]models.DtoOrderCheckpoint)
+	for _, dtocheckpoint := range *dtocheckpoints {
+		checkpoints[dtocheckpoint.Step] = dtocheckpoint
+	}
+
+	return checkpoints, nil
+}
+
+// Сохранение контрольной точки. Если аренда задания заказа потеряна, то исполнение прекращается,
+// заказ продолжит обработчик, перехвативший задание
+func (smsworkflow *SMSWorkflow) CompleteStep(order_id int64, step int, data string) (err error) {
+	err = smsworkflow.OrderCheckpointRepository.Save(models.NewDtoOrderCheckpoint(order_id, step, true, data, time.Now(), time.Now()))
 	if err != nil {
 		return err
 	}
+	if IsLeaseLost(order_id) {
+		log.Error("Order %v execution has been stopped after step %v, job lease has been lost", order_id, step)
+		return services.ErrLeaseLost
+	}
 
 	return nil
 }
 
-func (smsworkflow *SMSWorkflow) ExecuteOrder(order_id int64) {
-	log.Info("Starting order %v execution at %v", order_id, time.Now())
-	log.Info("Checking order type ...")
-	dtoorder, err := smsworkflow.OrderRepository.Get(order_id)
-	if err != nil {
-		return
+func (smsworkflow *SMSWorkflow) CatchUpPolicy() models.CatchUpPolicy {
+	return models.ParseCatchUpPolicy(config.Configuration.Scheduler.CatchUpPolicy)
+}
+
+func (smsworkflow *SMSWorkflow) CatchUpWindow() time.Duration {
+	if config.Configuration.Scheduler.CatchUpWindow > 0 {
+		return config.Configuration.Scheduler.CatchUpWindow
 	}
-	dtofacility, err := smsworkflow.FacilityRepository.Get(dtoorder.Facility_ID)
+	return SMS_CATCHUP_WINDOW
+}
+
+func (smsworkflow *SMSWorkflow) GetSMSFacility(order_id int64) (dtosmsfacility *models.DtoSMSFacility, err error) {
+	dtosmsfacility, err = smsworkflow.SMSFacilityRepository.Get(order_id)
 	if err != nil {
-		return
+		return nil, err
 	}
-	if dtofacility.Alias != models.SERVICE_TYPE_SMS {
-		log.Error("Order service is not macthed to the service method %v", dtoorder.Facility_ID)
-		return
+	periods, err := smsworkflow.SMSPeriodRepository.GetAll(order_id)
+	if err != nil {
+		return nil, err
 	}
-	if !dtofacility.Active {
-		log.Error("Service is not active %v", dtofacility.ID)
-		return
+	dtosmsfacility.Periods = *periods
+	events, err := smsworkflow.SMSEventRepository.GetAll(order_id)
+	if err != nil {
+		return nil, err
 	}
-	log.Info("Checking service type ...")
-	dtosmsfacility, err := smsworkflow.SMSFacilityRepository.Get(dtoorder.ID)
+	dtosmsfacility.Events = *events
+
+	return dtosmsfacility, nil
+}
+
+// Получение расписания рассылки. Расписание создается при первом обращении
+func (smsworkflow *SMSWorkflow) GetSchedule(dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility) (dtosmsschedule *models.DtoSMSSchedule, err error) {
+	found, err := smsworkflow.SMSScheduleRepository.Exists(dtoorder.ID)
 	if err != nil {
-		return
+		return nil, err
 	}
-	log.Info("Checking order status ...")
-	dtoorderstatuses, err := smsworkflow.OrderStatusRepository.GetByOrder(dtoorder.ID)
+	if found {
+		return smsworkflow.SMSScheduleRepository.Get(dtoorder.ID)
+	}
+
+	next, ok := helpers.NextSMSWindow(dtosmsfacility, time.Now().Truncate(time.Minute))
+	dtosmsschedule = models.NewDtoSMSSchedule(dtoorder.ID, next, time.Time{}, 0, false, !ok, time.Now(), time.Now())
+	if !ok {
+		log.Error("SMS delivery windows are not found for order %v", dtoorder.ID)
+	}
+	err = smsworkflow.SMSScheduleRepository.Create(dtosmsschedule)
 	if err != nil {
-		return
+		return nil, err
 	}
 
-	order := models.NewApiLongOrderFromDto(dtoorder, dtoorderstatuses)
-	if order.IsAssembled && order.IsConfirmed && !order.IsOpen && !order.IsCancelled && !order.IsExecuted && !order.IsArchived && !order.IsDeleted {
-		/* 1 */
-		if (dtosmsfacility.DeliveryType == models.TYPE_DELIVERY_ONCE && !dtosmsfacility.DeliveryTime) ||
-			(dtosmsfacility.DeliveryTime && ((!dtosmsfacility.DeliveryTimeStart.IsZero() && dtosmsfacility.DeliveryTimeStart.Sub(time.Now()) <= 0) &&
-				(!dtosmsfacility.DeliveryTimeEnd.IsZero() && dtosmsfacility.DeliveryTimeEnd.Sub(time.Now()) > 0))) {
-			schhour, schmin, _ := dtosmsfacility.DeliveryBaseTime.Local().Clock()
-			hour, min, _ := time.Now().Clock()
-			if schhour == hour && schmin == min {
-				log.Info("Checking data table ...")
-				dtodatatable, err := smsworkflow.CustomerTableRepository.Get(dtosmsfacility.DeliveryDataId)
-				if err != nil {
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
-				}
-				if !dtodatatable.Active {
-					log.Error("Data table is not active %v", dtosmsfacility.DeliveryDataId)
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
-				}
-				if !dtodatatable.Permanent {
-					log.Error("Data table is not permanent %v", dtosmsfacility.DeliveryDataId)
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
-				}
-				/* 2 */ err = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_OPEN, true)
-				if err != nil {
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
-				}
-				log.Info("Checking order data ...")
-				columnmobilephone_id, err := smsworkflow.CheckSMSOrder(dtoorder, dtosmsfacility)
-				if err != nil {
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
-				}
+	return dtosmsschedule, nil
+}
 
-				/* 3 */ err = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_SUPPLIER_COST_NEW, true)
-				if err != nil {
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
-				}
-				log.Info("Checking table columns ...")
-				columnmessage, columnmobilephone, columnsmssender, tablecolumns, err := smsworkflow.GetSMSTableColumns(dtosmsfacility, columnmobilephone_id)
-				if err != nil {
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
-				}
-				log.Info("Checking table data ...")
-				apitablerows, err := smsworkflow.TableRowRepository.GetAll("", "", dtosmsfacility.DeliveryDataId, tablecolumns)
-				if err != nil {
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
-				}
-				log.Info("Calculating order cost ...")
-				dtosmsfacility.Cost, err = smsworkflow.CalculateCost(apitablerows, columnmessage, columnmobilephone, columnsmssender, dtoorder, dtosmsfacility)
-				if err != nil {
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
-				}
-				dtosmsfacility.CostFactual = dtosmsfacility.Cost
-				err = smsworkflow.SMSFacilityRepository.Update(dtosmsfacility, true, false)
-				if err != nil {
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
-				}
+// Проверка наступления времени рассылки. Пропущенная рассылка, опоздание которой превышает допустимое,
+// обрабатывается согласно политике: skip - переносится на ближайшее окно, once и all - выполняется
+func (smsworkflow *SMSWorkflow) CheckSchedule(dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility) (dtosmsschedule *models.DtoSMSSchedule,
+	due bool, err error) {
+	dtosmsschedule, err = smsworkflow.GetSchedule(dtoorder, dtosmsfacility)
+	if err != nil {
+		return nil, false, err
+	}
+	if dtosmsschedule.Paused || dtosmsschedule.Finished {
+		return dtosmsschedule, false, nil
+	}
+	now := time.Now()
+	if now.Before(dtosmsschedule.Next_Run.Add(-helpers.SMSWindowLead(dtosmsfacility, dtosmsschedule.Next_Run))) {
+		return dtosmsschedule, false, nil
+	}
+	if now.Sub(dtosmsschedule.Next_Run) > smsworkflow.CatchUpWindow() && smsworkflow.CatchUpPolicy() == models.CATCHUP_POLICY_SKIP {
+		log.Info("Skipping missed sms delivery window %v for order %v", dtosmsschedule.Next_Run, dtoorder.ID)
+		next, ok := helpers.NextSMSWindow(dtosmsfacility, now)
+		dtosmsschedule.Next_Run = next
+		dtosmsschedule.Finished = !ok
+		dtosmsschedule.Updated = now
+		return dtosmsschedule, false, smsworkflow.SMSScheduleRepository.Update(dtosmsschedule)
+	}
 
-				/* 4 */ err = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_CUSTOMER_NEW_COST_CONFIRMED, true)
-				if err != nil {
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
+	return dtosmsschedule, true, nil
+}
+
+// Время рассылки, следующей за обслуживаемым окном. При политике all следующим становится ближайшее
+// пропущенное окно, в остальных случаях пропущенные окна не выполняются
+func (smsworkflow *SMSWorkflow) NextRun(dtosmsfacility *models.DtoSMSFacility, window time.Time) (next time.Time, ok bool) {
+	if dtosmsfacility.DeliveryType == models.TYPE_DELIVERY_ONCE {
+		return time.Time{}, false
+	}
+	after := window.Add(time.Minute)
+	if smsworkflow.CatchUpPolicy() != models.CATCHUP_POLICY_ALL && time.Now().After(after) {
+		after = time.Now()
+	}
+
+	return helpers.NextSMSWindow(dtosmsfacility, after)
+}
+
+// Окно рассылки, обслуживаемое текущим запуском заказа
+func (smsworkflow *SMSWorkflow) GetWindow(dtosmsschedule *models.DtoSMSSchedule, checkpoints map[int]models.DtoOrderCheckpoint) (window time.Time) {
+	if checkpoint, ok := checkpoints[SMS_STEP_CLOSE_ORDER]; ok && checkpoint.Data != "" {
+		if unix, err := strconv.ParseInt(checkpoint.Data, 10, 64); err == nil {
+			return time.Unix(unix, 0)
+		}
+	}
+
+	return dtosmsschedule.Next_Run
+}
+
+// Перенос расписания на следующее окно после обслуживания текущего. Повторный перенос для того же окна не выполняется
+func (smsworkflow *SMSWorkflow) Reschedule(dtosmsschedule *models.DtoSMSSchedule, window time.Time, next time.Time, final bool) (err error) {
+	if dtosmsschedule.Runs != 0 && dtosmsschedule.Last_Run.Unix() == window.Unix() {
+		return nil
+	}
+	dtosmsschedule.Last_Run = window
+	dtosmsschedule.Runs++
+	if final {
+		dtosmsschedule.Finished = true
+	} else {
+		dtosmsschedule.Next_Run = next
+	}
+	dtosmsschedule.Updated = time.Now()
+
+	return smsworkflow.SMSScheduleRepository.Update(dtosmsschedule)
+}
+
+// Удаление следов предыдущего запуска заказа перед новой рассылкой
+func (smsworkflow *SMSWorkflow) Reset(dtoorder *models.DtoOrder) (err error) {
+	err = smsworkflow.OrderCheckpointRepository.DeleteByOrder(dtoorder.ID)
+	if err != nil {
+		return err
+	}
+	err = smsworkflow.SMSBatchRepository.DeleteByOrder(dtoorder.ID)
+	if err != nil {
+		return err
+	}
+	err = smsworkflow.OrderRouteRepository.DeleteByOrder(dtoorder.ID)
+	if err != nil {
+		return err
+	}
+	err = smsworkflow.DeliveryReportRepository.DeleteByOrder(dtoorder.ID)
+	if err != nil {
+		return err
+	}
+
+	return smsworkflow.WorkTableRepository.DeleteByOrder(dtoorder.ID, nil)
+}
+
+// Подготовка заказа к следующей рассылке по расписанию
+func (smsworkflow *SMSWorkflow) Rearm(dtoorder *models.DtoOrder) (err error) {
+	dtoorder.End_Date = time.Now()
+	err = smsworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
+		*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_OPEN, false, "", time.Now()),
+		*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_PAID, false, "", time.Now())}, nil, true)
+	if err != nil {
+		return err
+	}
+
+	return smsworkflow.Reset(dtoorder)
+}
+
+// Закрытие заказа после последней рассылки или подготовка к следующей
+func (smsworkflow *SMSWorkflow) Close(dtoorder *models.DtoOrder, final bool) (err error) {
+	if !final {
+		return smsworkflow.Rearm(dtoorder)
+	}
+	dtoorder.End_Date = time.Now()
+
+	return smsworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
+		*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_SUPPLIER_CLOSE, true, "", time.Now())}, nil, true)
+}
+
+// Пропуск окна рассылки, в которое не попал ни один получатель
+func (smsworkflow *SMSWorkflow) Skip(dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility,
+	dtosmsschedule *models.DtoSMSSchedule, window time.Time) (err error) {
+	next, ok := smsworkflow.NextRun(dtosmsfacility, window)
+	err = smsworkflow.Reschedule(dtosmsschedule, window, next, !ok)
+	if err != nil {
+		return err
+	}
+	if ok {
+		return smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_OPEN, false)
+	}
+
+	return smsworkflow.Close(dtoorder, true)
+}
+
+func (smsworkflow *SMSWorkflow) GetBirthdayColumn(table_id int64) (dtotablecolumn *models.DtoTableColumn, err error) {
+	tablecolumns, err := smsworkflow.TableColumnRepository.GetByTable(table_id)
+	if err != nil {
+		return nil, err
+	}
+	for i := range *tablecolumns {
+		if (*tablecolumns)[i].Column_Type_ID == models.COLUMN_TYPE_BIRTHDAY && (*tablecolumns)[i].Active {
+			return &(*tablecolumns)[i], nil
+		}
+	}
+	log.Error("Can't find birthday column in table %v", table_id)
+
+	return nil, errors.New("Missed birthday column")
+}
+
+// Получение строк таблицы, попадающих в окно рассылки. Для рассылки по дням рождения
+// отбираются строки, день рождения в которых совпадает с днем окна
+func (smsworkflow *SMSWorkflow) GetSMSRows(table_id int64, dtosmsfacility *models.DtoSMSFacility, tablecolumns *[]models.DtoTableColumn,
+	window time.Time) (apitablerows *[]models.ApiInfoTableRow, err error) {
+	if !helpers.IsBirthdayDelivery(dtosmsfacility) {
+		return smsworkflow.TableRowRepository.GetAll("", "", table_id, tablecolumns)
+	}
+
+	columnbirthday, err := smsworkflow.GetBirthdayColumn(table_id)
+	if err != nil {
+		return nil, err
+	}
+	columns := append(append([]models.DtoTableColumn{}, *tablecolumns...), *columnbirthday)
+	allrows, err := smsworkflow.TableRowRepository.GetAll("", "", table_id, &columns)
+	if err != nil {
+		return nil, err
+	}
+	apitablerows = new([]models.ApiInfoTableRow)
+	for _, apitablerow := range *allrows {
+		for _, apitablecell := range apitablerow.Cells {
+			if apitablecell.Table_Column_ID == columnbirthday.ID && helpers.IsBirthday(apitablecell.Value, window) {
+				*apitablerows = append(*apitablerows, apitablerow)
+				break
+			}
+		}
+	}
+
+	return apitablerows, nil
+}
+
+func (smsworkflow *SMSWorkflow) DefaultTimezone() int {
+	if offset, ok := helpers.ParseTimezone(config.Configuration.Scheduler.DefaultTimezone); ok {
+		return offset
+	}
+	return helpers.TIMEZONE_OFFSET_MSK
+}
+
+// Колонки таблицы данных, по которым определяется часовой пояс получателя, в порядке приоритета:
+// часовой пояс почтового адреса, затем часовой пояс региона телефона
+func (smsworkflow *SMSWorkflow) GetTimezoneColumns(table_id int64) (tablecolumns *[]models.DtoTableColumn, err error) {
+	alltablecolumns, err := smsworkflow.TableColumnRepository.GetByTable(table_id)
+	if err != nil {
+		return nil, err
+	}
+	tablecolumns = new([]models.DtoTableColumn)
+	for _, column_type_id := range []int{models.COLUMN_TYPE_ANSWER_POSTADDRESS_TIMEZONE, models.COLUMN_TYPE_ANSWER_PHONE_TIMEZONE} {
+		for _, tablecolumn := range *alltablecolumns {
+			if tablecolumn.Column_Type_ID == column_type_id && tablecolumn.Active {
+				*tablecolumns = append(*tablecolumns, tablecolumn)
+			}
+		}
+	}
+
+	return tablecolumns, nil
+}
+
+// Добавление колонок часового пояса к выбираемым колонкам таблицы данных при коррекции времени рассылки
+func (smsworkflow *SMSWorkflow) AddTimezoneColumns(dtosmsfacility *models.DtoSMSFacility,
+	tablecolumns *[]models.DtoTableColumn) (columns *[]models.DtoTableColumn, err error) {
+	if !dtosmsfacility.TimeCorrection {
+		return tablecolumns, nil
+	}
+	timezonecolumns, err := smsworkflow.GetTimezoneColumns(dtosmsfacility.DeliveryDataId)
+	if err != nil {
+		return nil, err
+	}
+	if len(*timezonecolumns) == 0 {
+		log.Info("Can't find timezone columns in table %v, timezone of mobile phone region is used", dtosmsfacility.DeliveryDataId)
+	}
+	columns = new([]models.DtoTableColumn)
+	*columns = append(append(*columns, *tablecolumns...), *timezonecolumns...)
+
+	return columns, nil
+}
+
+// Часовой пояс номера по региону из плана нумерации, а для стран с одним часовым поясом - по стране номера
+func PhoneTimezone(value string) (offset int, ok bool) {
+	number := numbering.Normalize(value)
+	mobilephone, err := strconv.ParseUint(number, 10, 64)
+	if err != nil {
+		return 0, false
+	}
+	if _, region, found := numbering.Lookup(mobilephone); found && region != "" {
+		if timezone, exists := config.Configuration.Scheduler.RegionTimezones[region]; exists {
+			if offset, ok = helpers.ParseTimezone(timezone); ok {
+				return offset, true
+			}
+		}
+	}
+	if country := numbering.CountryOf(number); country != nil && country.Timezone != "" {
+		return helpers.ParseTimezone(country.Timezone)
+	}
+
+	return 0, false
+}
+
+// Определение часового пояса получателя по первой заполненной колонке часового пояса. Если колонки не заполнены,
+// часовой пояс определяется по региону или стране номера получателя, и только затем используется пояс по умолчанию
+func RowTimezone(apitablerow *models.ApiInfoTableRow, timezonecolumns *[]models.DtoTableColumn,
+	columnmobilephone *models.DtoTableColumn, defaultoffset int) (offset int) {
+	for _, timezonecolumn := range *timezonecolumns {
+		for _, apitablecell := range apitablerow.Cells {
+			if apitablecell.Table_Column_ID == timezonecolumn.ID {
+				if offset, ok := helpers.ParseTimezone(apitablecell.Value); ok {
+					return offset
 				}
-				log.Info("Start order processing ...")
-				/* 5 */ dtoorder.Begin_Date = time.Now()
-				err = smsworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
-					*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_MODERATOR_BEGIN, true, "", time.Now())}, nil, true)
-				if err != nil {
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
+			}
+		}
+	}
+	if columnmobilephone != nil {
+		for _, apitablecell := range apitablerow.Cells {
+			if apitablecell.Table_Column_ID == columnmobilephone.ID {
+				if offset, ok := PhoneTimezone(apitablecell.Value); ok {
+					return offset
 				}
-				log.Info("Calculating unit balance ...")
-				balance, err := smsworkflow.OperationRepository.CalculateBalance(dtoorder.Unit_ID)
-				if err != nil {
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
+			}
+		}
+	}
+
+	return defaultoffset
+}
+
+// Разбиение рассылки на пакеты по часовым поясам получателей. Без коррекции времени вся рассылка составляет один пакет
+func (smsworkflow *SMSWorkflow) PlanBatches(dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility, window time.Time,
+	apitablerows *[]models.ApiInfoTableRow, columnmobilephone *models.DtoTableColumn) (batches *[]models.DtoSMSBatch, err error) {
+	positions := make(map[int][]int)
+	sendat := make(map[int]time.Time)
+	if dtosmsfacility.TimeCorrection {
+		timezonecolumns, err := smsworkflow.GetTimezoneColumns(dtosmsfacility.DeliveryDataId)
+		if err != nil {
+			return nil, err
+		}
+		defaultoffset := smsworkflow.DefaultTimezone()
+		for i := range *apitablerows {
+			offset := RowTimezone(&(*apitablerows)[i], timezonecolumns, columnmobilephone, defaultoffset)
+			positions[offset] = append(positions[offset], i)
+			sendat[offset] = helpers.LocalSMSTime(dtosmsfacility, window, offset)
+		}
+	} else {
+		_, offset := window.Local().Zone()
+		for i := range *apitablerows {
+			positions[offset/60] = append(positions[offset/60], i)
+		}
+		sendat[offset/60] = window
+	}
+
+	for offset, rows := range positions {
+		data, err := json.Marshal(rows)
+		if err != nil {
+			log.Error("Can't encode batch rows %v", err)
+			return nil, err
+		}
+		err = smsworkflow.SMSBatchRepository.Save(models.NewDtoSMSBatch(dtoorder.ID, offset, sendat[offset], time.Time{},
+			models.SMS_BATCH_STATUS_NEW, len(rows), string(data), "", 0, 0, time.Now(), time.Now()))
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return smsworkflow.SMSBatchRepository.GetByOrder(dtoorder.ID)
+}
+
+// Отправка пакетов, для которых наступило время рассылки. Если все пакеты отправлены, то возвращается объединенный
+// ответ поставщика в порядке строк таблицы данных, иначе пустой ответ без ошибки
+func (smsworkflow *SMSWorkflow) SendBatches(dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility, window time.Time,
+	apitablerows *[]models.ApiInfoTableRow, columnsmssender, columnmessage,
+	columnmobilephone *models.DtoTableColumn) (smsresponse *libTypes.SmsResponse, err error) {
+	batches, err := smsworkflow.SMSBatchRepository.GetByOrder(dtoorder.ID)
+	if err != nil {
+		return nil, err
+	}
+	if len(*batches) == 0 {
+		batches, err = smsworkflow.PlanBatches(dtoorder, dtosmsfacility, window, apitablerows, columnmobilephone)
+		if err != nil {
+			_ = smsworkflow.Cancel(dtoorder, true)
+			return nil, err
+		}
+	}
+	sent := false
+	for _, batch := range *batches {
+		if batch.Status != models.SMS_BATCH_STATUS_NEW {
+			sent = true
+		}
+	}
+
+	smsresponse = new(libTypes.SmsResponse)
+	smsresponse.Ids = make([]gocql.UUID, len(*apitablerows))
+	smsresponse.Errors = make([]error, len(*apitablerows))
+	pending := false
+	for i := range *batches {
+		batch := &(*batches)[i]
+		if batch.Status == models.SMS_BATCH_STATUS_SENDING {
+			log.Error("SMS sending for order %v timezone %v has been interrupted, delivery state is unknown", dtoorder.ID, batch.Offset)
+			_ = smsworkflow.Cancel(dtoorder, false)
+			return nil, errors.New("Unknown delivery state")
+		}
+		var rows []int
+		err = json.Unmarshal([]byte(batch.Rows), &rows)
+		if err != nil {
+			log.Error("Can't decode batch rows %v for order %v", err, dtoorder.ID)
+			return nil, err
+		}
+		if batch.Status == models.SMS_BATCH_STATUS_NEW {
+			if time.Now().Before(batch.Send_At) {
+				pending = true
+				continue
+			}
+			log.Info("Sending batch of %v messages for timezone %v ...", batch.Recipients, helpers.FormatTimezone(batch.Offset))
+			batch.Status = models.SMS_BATCH_STATUS_SENDING
+			batch.Updated = time.Now()
+			err = smsworkflow.SMSBatchRepository.Save(batch)
+			if err != nil {
+				return nil, err
+			}
+			batchrows := new([]models.ApiInfoTableRow)
+			for _, row := range rows {
+				*batchrows = append(*batchrows, (*apitablerows)[row])
+			}
+			batchresponse, err := smsworkflow.SendSMS(batchrows, dtoorder, dtosmsfacility, columnsmssender, columnmessage, columnmobilephone)
+			if err != nil {
+				if !sent {
+					_ = smsworkflow.Cancel(dtoorder, true)
+					return nil, err
 				}
-				log.Info("Order payment and invoicement ...")
-				/* 6 */ err = smsworkflow.PayAndInvoice(dtoorder, dtosmsfacility, balance)
-				if err != nil {
-					_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-					return
+				batch.Status = models.SMS_BATCH_STATUS_NEW
+				batch.Updated = time.Now()
+				_ = smsworkflow.SMSBatchRepository.Save(batch)
+				return nil, err
+			}
+			sent = true
+			batch.Response, err = EncodeSMSResponse(batchresponse)
+			if err != nil {
+				return nil, err
+			}
+			batch.Sent, batch.Failed = 0, 0
+			for _, batcherror := range batchresponse.Errors {
+				if batcherror != nil {
+					batch.Failed++
+				} else {
+					batch.Sent++
 				}
+			}
+			batch.Status = models.SMS_BATCH_STATUS_SENT
+			batch.Sent_At = time.Now()
+			batch.Updated = time.Now()
+			err = smsworkflow.SMSBatchRepository.Save(batch)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		batchresponse, err := DecodeSMSResponse(batch.Response)
+		if err != nil {
+			return nil, err
+		}
+		if len(batchresponse.Ids) != len(rows) {
+			log.Error("SMS response size %v doesn't match batch size %v for order %v", len(batchresponse.Ids), len(rows), dtoorder.ID)
+			return nil, errors.New("Wrong sms response size")
+		}
+		for k, row := range rows {
+			smsresponse.Ids[row] = batchresponse.Ids[k]
+			smsresponse.Errors[row] = batchresponse.Errors[k]
+		}
+	}
+	if pending {
+		return nil, nil
+	}
+
+	return smsresponse, nil
+}
+
+// Время следующей отправки пакета, ожидающего наступления местного времени получателей
+func (smsworkflow *SMSWorkflow) NextAttempt(order_id int64) (next time.Time, deferred bool, err error) {
+	batches, err := smsworkflow.SMSBatchRepository.GetByOrder(order_id)
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	for _, batch := range *batches {
+		if batch.Status == models.SMS_BATCH_STATUS_NEW && (!deferred || batch.Send_At.Before(next)) {
+			next = batch.Send_At
+			deferred = true
+		}
+	}
+	if deferred && next.Before(time.Now()) {
+		next = time.Now()
+	}
+
+	return next, deferred, nil
+}
+
+// Прекращение исполнения заказа со снятием резерва и возвратом оплаты при необходимости
+func (smsworkflow *SMSWorkflow) Cancel(dtoorder *models.DtoOrder, refund bool) (err error) {
+	if refund {
+		err = smsworkflow.LedgerRepository.Release(dtoorder.ID)
+		if err != nil {
+			log.Error("Can't release order %v, %v", dtoorder.ID, err)
+			return err
+		}
+		err = RefundOrder(dtoorder, smsworkflow.UnitRepository, smsworkflow.TransactionTypeRepository, smsworkflow.InvoiceRepository)
+		if err != nil {
+			log.Error("Can't refund order %v, %v", dtoorder.ID, err)
+			return err
+		}
+	}
+	_ = smsworkflow.OrderCheckpointRepository.DeleteByOrder(dtoorder.ID)
+
+	return smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+}
+
+func (smsworkflow *SMSWorkflow) SetStatus(dtoorder *models.DtoOrder, orderstatus models.OrderStatus, active bool) (err error) {
+	dtoorderstatus := models.NewDtoOrderStatus(dtoorder.ID, orderstatus, active, "", time.Now())
+	err = smsworkflow.OrderStatusRepository.Save(dtoorderstatus, nil)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (smsworkflow *SMSWorkflow) ExecuteOrder(order_id int64) {
+	log.Info("Starting order %v execution at %v", order_id, time.Now())
+	log.Info("Checking order type ...")
+	dtoorder, err := smsworkflow.OrderRepository.Get(order_id)
+	if err != nil {
+		return
+	}
+	dtofacility, err := smsworkflow.FacilityRepository.Get(dtoorder.Facility_ID)
+	if err != nil {
+		return
+	}
+	if dtofacility.Alias != models.SERVICE_TYPE_SMS {
+		log.Error("Order service is not macthed to the service method %v", dtoorder.Facility_ID)
+		return
+	}
+	if !dtofacility.Active {
+		log.Error("Service is not active %v", dtofacility.ID)
+		return
+	}
+	log.Info("Checking service type ...")
+	dtosmsfacility, err := smsworkflow.GetSMSFacility(dtoorder.ID)
+	if err != nil {
+		return
+	}
+	log.Info("Checking order status ...")
+	dtoorderstatuses, err := smsworkflow.OrderStatusRepository.GetByOrder(dtoorder.ID)
+	if err != nil {
+		return
+	}
+
+	order := models.NewApiLongOrderFromDto(dtoorder, dtoorderstatuses)
+	if order.IsAssembled && order.IsConfirmed && !order.IsOpen && !order.IsCancelled && !order.IsExecuted && !order.IsArchived && !order.IsDeleted {
+		log.Info("Checking delivery schedule ...")
+		/* 1 */ dtosmsschedule, due, err := smsworkflow.CheckSchedule(dtoorder, dtosmsfacility)
+		if err != nil {
+			return
+		}
+		if due {
+			window := dtosmsschedule.Next_Run
+			log.Info("Checking data table ...")
+			dtodatatable, err := smsworkflow.CustomerTableRepository.Get(dtosmsfacility.DeliveryDataId)
+			if err != nil {
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 				return
-				log.Info("Copying table data ...")
-				/* 7 */ dtoworkdatatable, err := smsworkflow.CopyData(dtoorder, dtodatatable)
-				if err != nil {
-					return
-				}
-				log.Info("Sending data to supplier ...")
-				/* 8 */ smsresponse, err := smsworkflow.SendSMS(apitablerows, dtoorder, dtosmsfacility, columnsmssender, columnmessage, columnmobilephone)
-				if err != nil {
-					return
-				}
-				log.Info("Saving supplier response ...")
-				/* 9 */ err = smsworkflow.SaveSMS(dtoworkdatatable, smsresponse)
-				if err != nil {
-					return
-				}
-				log.Info("Getting supplier results ...")
-				/* 10 */ smsstatuses, err := GetSMSStatus(smsresponse)
-				if err != nil {
-					return
-				}
-				log.Info("Saving supplier results ...")
-				/* 11 */ err = smsworkflow.SaveSMSStatus(dtoworkdatatable, smsstatuses)
-				if err != nil {
-					return
-				}
-				log.Info("Finsing order processing and clearing data ...")
-				/* 12 */ err = smsworkflow.ClearTables(dtoorder, dtosmsfacility, dtodatatable)
-				if err != nil {
-					return
-				}
-				log.Info("Completing order execution %v", time.Now())
-				/* 13 */ dtoorder.End_Date = time.Now()
-				err = smsworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
-					*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_SUPPLIER_CLOSE, true, "", time.Now())}, nil, true)
-				if err != nil {
-					return
-				}
+			}
+			if !dtodatatable.Active {
+				log.Error("Data table is not active %v", dtosmsfacility.DeliveryDataId)
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+				return
+			}
+			if !dtodatatable.Permanent {
+				log.Error("Data table is not permanent %v", dtosmsfacility.DeliveryDataId)
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+				return
+			}
+			err = smsworkflow.Reset(dtoorder)
+			if err != nil {
+				return
+			}
+			/* 2 */ err = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_OPEN, true)
+			if err != nil {
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+				return
+			}
+			log.Info("Checking order data ...")
+			columnmobilephone_id, err := smsworkflow.CheckSMSOrder(dtoorder, dtosmsfacility)
+			if err != nil {
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+				return
+			}
+
+			/* 3 */ err = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_SUPPLIER_COST_NEW, true)
+			if err != nil {
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+				return
+			}
+			log.Info("Checking table columns ...")
+			columnmessage, columnmobilephone, columnsmssender, tablecolumns, err := smsworkflow.GetSMSTableColumns(dtosmsfacility, columnmobilephone_id)
+			if err != nil {
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+				return
+			}
+			tablecolumns, err = smsworkflow.AddTimezoneColumns(dtosmsfacility, tablecolumns)
+			if err != nil {
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+				return
+			}
+			log.Info("Checking table data ...")
+			apitablerows, err := smsworkflow.GetSMSRows(dtosmsfacility.DeliveryDataId, dtosmsfacility, tablecolumns, window)
+			if err != nil {
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+				return
+			}
+			if len(*apitablerows) == 0 {
+				log.Info("No recipients for delivery window %v of order %v", window, dtoorder.ID)
+				_ = smsworkflow.Skip(dtoorder, dtosmsfacility, dtosmsschedule, window)
+				return
+			}
+			log.Info("Calculating order cost ...")
+			dtosmsfacility.Cost, err = smsworkflow.CalculateCost(apitablerows, columnmessage, columnmobilephone, columnsmssender, dtoorder, dtosmsfacility)
+			if err != nil {
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+				return
+			}
+			err = smsworkflow.SMSFacilityRepository.Update(dtosmsfacility, true, false)
+			if err != nil {
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+				return
+			}
 
+			/* 4 */ err = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_CUSTOMER_NEW_COST_CONFIRMED, true)
+			if err != nil {
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+				return
+			}
+			log.Info("Holding order cost ...")
+			_, err = smsworkflow.LedgerRepository.Hold(dtoorder.Unit_ID, dtoorder.ID, dtosmsfacility.Cost)
+			if err != nil {
+				_ = smsworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
+				return
+			}
+			log.Info("Start order processing ...")
+			/* 5 */ dtoorder.Begin_Date = time.Now()
+			err = smsworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
+				*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_MODERATOR_BEGIN, true, "", time.Now())}, nil, true)
+			if err != nil {
+				_ = smsworkflow.Cancel(dtoorder, true)
+				return
 			}
+			err = smsworkflow.Process(dtoorder, dtosmsfacility, dtosmsschedule, window, dtodatatable, apitablerows,
+				columnmessage, columnmobilephone, columnsmssender)
+			if err != nil {
+				log.Error("Can't process order %v, %v", dtoorder.ID, err)
+				return
+			}
+		}
+	}
+}
+
+// Продолжение исполнения оплаченного заказа с последней контрольной точки
+func (smsworkflow *SMSWorkflow) ResumeOrder(order_id int64) (err error) {
+	log.Info("Resuming order %v execution at %v", order_id, time.Now())
+	dtoorder, err := smsworkflow.OrderRepository.Get(order_id)
+	if err != nil {
+		return err
+	}
+	dtoorderstatuses, err := smsworkflow.OrderStatusRepository.GetByOrder(dtoorder.ID)
+	if err != nil {
+		return err
+	}
+	order := models.NewApiLongOrderFromDto(dtoorder, dtoorderstatuses)
+	held, err := smsworkflow.LedgerRepository.IsHeld(dtoorder.ID)
+	if err != nil {
+		return err
+	}
+	if !order.IsAssembled || !order.IsOpen || !(order.IsPaid || held) || order.IsCancelled || order.IsExecuted || order.IsArchived || order.IsDeleted {
+		log.Error("Order %v can't be resumed", dtoorder.ID)
+		return nil
+	}
+	dtosmsfacility, err := smsworkflow.GetSMSFacility(dtoorder.ID)
+	if err != nil {
+		return err
+	}
+	dtosmsschedule, err := smsworkflow.GetSchedule(dtoorder, dtosmsfacility)
+	if err != nil {
+		return err
+	}
+	checkpoints, err := smsworkflow.GetCheckpoints(dtoorder.ID)
+	if err != nil {
+		return err
+	}
+	window := smsworkflow.GetWindow(dtosmsschedule, checkpoints)
+	dtodatatable, err := smsworkflow.CustomerTableRepository.Get(dtosmsfacility.DeliveryDataId)
+	if err != nil {
+		return err
+	}
+	columnmobilephone_id, err := smsworkflow.CheckSMSOrder(dtoorder, dtosmsfacility)
+	if err != nil {
+		return err
+	}
+	columnmessage, columnmobilephone, columnsmssender, tablecolumns, err := smsworkflow.GetSMSTableColumns(dtosmsfacility, columnmobilephone_id)
+	if err != nil {
+		return err
+	}
+	tablecolumns, err = smsworkflow.AddTimezoneColumns(dtosmsfacility, tablecolumns)
+	if err != nil {
+		return err
+	}
+	apitablerows, err := smsworkflow.GetSMSRows(dtosmsfacility.DeliveryDataId, dtosmsfacility, tablecolumns, window)
+	if err != nil {
+		return err
+	}
+
+	return smsworkflow.Process(dtoorder, dtosmsfacility, dtosmsschedule, window, dtodatatable, apitablerows,
+		columnmessage, columnmobilephone, columnsmssender)
+}
+
+// Исполнение оплаченного заказа. Каждый шаг фиксируется контрольной точкой, при повторном запуске завершенные шаги пропускаются.
+// Ошибка до отправки сообщений поставщику приводит к снятию резерва, ошибка после отправки оставляет заказ для повторного запуска.
+// После рассылки периодический заказ возвращается в ожидание следующего окна
+func (smsworkflow *SMSWorkflow) Process(dtoorder *models.DtoOrder, dtosmsfacility *models.DtoSMSFacility, dtosmsschedule *models.DtoSMSSchedule,
+	window time.Time, dtodatatable *models.DtoCustomerTable, apitablerows *[]models.ApiInfoTableRow,
+	columnmessage, columnmobilephone, columnsmssender *models.DtoTableColumn) (err error) {
+	checkpoints, err := smsworkflow.GetCheckpoints(dtoorder.ID)
+	if err != nil {
+		return err
+	}
+
+	log.Info("Copying table data ...")
+	var dtoworkdatatable *models.DtoCustomerTable
+	if checkpoint, ok := checkpoints[SMS_STEP_COPY_DATA]; ok && checkpoint.Done {
+		work_table_id, err := strconv.ParseInt(checkpoint.Data, 10, 64)
+		if err != nil {
+			log.Error("Can't parse work table id %v for order %v", checkpoint.Data, dtoorder.ID)
+			_ = smsworkflow.Cancel(dtoorder, true)
+			return err
+		}
+		dtoworkdatatable, err = smsworkflow.CustomerTableRepository.Get(work_table_id)
+		if err != nil {
+			return err
+		}
+	} else {
+		/* 7 */ dtoworkdatatable, err = smsworkflow.CopyData(dtoorder, dtodatatable)
+		if err != nil {
+			_ = smsworkflow.Cancel(dtoorder, true)
+			return err
+		}
+		err = smsworkflow.CompleteStep(dtoorder.ID, SMS_STEP_COPY_DATA, fmt.Sprintf("%v", dtoworkdatatable.ID))
+		if err != nil {
+			return err
 		}
 	}
+
+	log.Info("Sending data to supplier ...")
+	var smsresponse *libTypes.SmsResponse
+	if checkpoint, ok := checkpoints[SMS_STEP_SEND_SMS]; ok && checkpoint.Done {
+		smsresponse, err = DecodeSMSResponse(checkpoint.Data)
+		if err != nil {
+			return err
+		}
+	} else {
+		/* 8 */ smsresponse, err = smsworkflow.SendBatches(dtoorder, dtosmsfacility, window, apitablerows, columnsmssender, columnmessage, columnmobilephone)
+		if err != nil {
+			return err
+		}
+		if smsresponse == nil {
+			log.Info("Order %v is waiting for recipients local delivery time", dtoorder.ID)
+			return nil
+		}
+		data, err := EncodeSMSResponse(smsresponse)
+		if err != nil {
+			return err
+		}
+		err = smsworkflow.CompleteStep(dtoorder.ID, SMS_STEP_SEND_SMS, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	if !IsStepDone(checkpoints, SMS_STEP_SAVE_SMS) {
+		log.Info("Saving supplier response ...")
+		/* 9 */ err = smsworkflow.SaveSMS(dtoworkdatatable, smsresponse, dtosmsfacility, window)
+		if err != nil {
+			return err
+		}
+		err = smsworkflow.RegisterSMSDelivery(dtoorder, smsresponse, apitablerows, columnmobilephone)
+		if err != nil {
+			return err
+		}
+		err = smsworkflow.CompleteStep(dtoorder.ID, SMS_STEP_SAVE_SMS, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	if !IsStepDone(checkpoints, SMS_STEP_SAVE_SMS_STATUS) {
+		log.Info("Getting supplier results ...")
+		/* 10 */ smsstatuses, err := smsworkflow.GetRoutedSMSStatus(dtoorder, smsresponse, DeliveryDeadline())
+		if err != nil {
+			return err
+		}
+		err = smsworkflow.CompleteStep(dtoorder.ID, SMS_STEP_GET_SMS_STATUS, "")
+		if err != nil {
+			return err
+		}
+		log.Info("Saving supplier results ...")
+		/* 11 */ err = smsworkflow.SaveSMSStatus(dtoworkdatatable, smsstatuses)
+		if err != nil {
+			return err
+		}
+		err = smsworkflow.CompleteStep(dtoorder.ID, SMS_STEP_SAVE_SMS_STATUS, "")
+		if err != nil {
+			return err
+		}
+	}
+
+	var next time.Time
+	final := false
+	if checkpoint, ok := checkpoints[SMS_STEP_CLEAR_TABLES]; ok && checkpoint.Done {
+		unix, err := strconv.ParseInt(checkpoint.Data, 10, 64)
+		if err != nil {
+			log.Error("Can't parse next delivery time %v for order %v", checkpoint.Data, dtoorder.ID)
+			return err
+		}
+		next = time.Unix(unix, 0)
+		final = unix == 0
+	} else {
+		var more bool
+		next, more = smsworkflow.NextRun(dtosmsfacility, window)
+		final = !more
+		log.Info("Finsing order processing and clearing data ...")
+		/* 12 */ err = smsworkflow.ClearTables(dtoorder, dtosmsfacility, dtodatatable, final)
+		if err != nil {
+			return err
+		}
+		data := "0"
+		if !final {
+			data = fmt.Sprintf("%v", next.Unix())
+		}
+		err = smsworkflow.CompleteStep(dtoorder.ID, SMS_STEP_CLEAR_TABLES, data)
+		if err != nil {
+			return err
+		}
+	}
+
+	log.Info("Completing order execution %v", time.Now())
+	if _, ok := checkpoints[SMS_STEP_CLOSE_ORDER]; !ok {
+		err = smsworkflow.OrderCheckpointRepository.Save(models.NewDtoOrderCheckpoint(dtoorder.ID, SMS_STEP_CLOSE_ORDER, false,
+			fmt.Sprintf("%v", window.Unix()), time.Now(), time.Now()))
+		if err != nil {
+			return err
+		}
+	}
+	/* 13 */ err = smsworkflow.Capture(dtoorder, dtosmsfacility, apitablerows, smsresponse, columnmessage, columnmobilephone, columnsmssender)
+	if err != nil {
+		return err
+	}
+	err = smsworkflow.Reschedule(dtosmsschedule, window, next, final)
+	if err != nil {
+		return err
+	}
+	err = smsworkflow.Close(dtoorder, final)
+	if err != nil {
+		return err
+	}
+	if final {
+		_ = smsworkflow.CompleteStep(dtoorder.ID, SMS_STEP_CLOSE_ORDER, fmt.Sprintf("%v", window.Unix()))
+	}
+
+	return nil
 }
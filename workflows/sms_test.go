@@ -1 +1,26 @@
 package workflows
+
+import (
+	"testing"
+)
+
+func TestPhoneTimezone(t *testing.T) {
+	var cases = []struct {
+		value  string
+		offset int
+		ok     bool
+	}{
+		{"+375291234567", 180, true},
+		{"+77011234567", 300, true},
+		{"+998901234567", 300, true},
+		{"+79161234567", 0, false},
+		{"phone", 0, false},
+	}
+
+	for _, c := range cases {
+		offset, ok := PhoneTimezone(c.value)
+		if ok != c.ok || offset != c.offset {
+			t.Error("Timezone of", c.value, "is not properly detected", offset, ok)
+		}
+	}
+}
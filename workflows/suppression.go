@@ -0,0 +1,104 @@
+package workflows
+
+import (
+	"application/config"
+	"application/models"
+	"application/services"
+	"errors"
+	"strings"
+	"time"
+)
+
+const (
+	SUPPRESSION_REPLY_PERIOD = 30 * 24 * time.Hour
+)
+
+var (
+	SUPPRESSION_STOP_WORDS = []string{"STOP", "СТОП", "ОТПИСАТЬСЯ", "UNSUBSCRIBE"}
+)
+
+type SuppressionWorkflow struct {
+	SuppressionRepository    services.SuppressionRepository
+	SMSSenderRepository      services.SMSSenderRepository
+	DeliveryReportRepository services.DeliveryReportRepository
+}
+
+func NewSuppressionWorkflow(suppressionrepository services.SuppressionRepository,
+	smssenderrepository services.SMSSenderRepository, deliveryreportrepository services.DeliveryReportRepository) *SuppressionWorkflow {
+	return &SuppressionWorkflow{
+		SuppressionRepository:    suppressionrepository,
+		SMSSenderRepository:      smssenderrepository,
+		DeliveryReportRepository: deliveryreportrepository,
+	}
+}
+
+func SuppressionReplyPeriod() time.Duration {
+	if config.Configuration.Suppression.ReplyPeriod > 0 {
+		return config.Configuration.Suppression.ReplyPeriod
+	}
+	return SUPPRESSION_REPLY_PERIOD
+}
+
+// Проверка ответа абонента на соответствие одному из стоп-слов без учета регистра и знаков препинания
+func IsStopReply(message string) bool {
+	stopwords := config.Configuration.Suppression.StopWords
+	if len(stopwords) == 0 {
+		stopwords = SUPPRESSION_STOP_WORDS
+	}
+	message = strings.Trim(strings.TrimSpace(message), ".!")
+	for _, stopword := range stopwords {
+		if strings.EqualFold(message, stopword) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Обработка ответа абонента, полученного поставщиком. Объединения определяются по сообщениям, которые этот поставщик
+// отправлял на номер абонента, а из них при совпадении выбираются владельцы имени отправителя. При стоп-слове номер
+// добавляется в списки запрета рассылок этих объединений. Ответ на номер, которому поставщик не отправлял сообщений,
+// отклоняется
+func (suppressionworkflow *SuppressionWorkflow) HandleReply(supplier_id int64, sender string, mobilephone uint64, message string) (
+	suppressed bool, unit_ids []int64, err error) {
+	unit_ids = []int64{}
+	if !IsStopReply(message) {
+		return false, unit_ids, nil
+	}
+
+	recipients, err := suppressionworkflow.DeliveryReportRepository.GetUnitsByMobilePhone(supplier_id, mobilephone,
+		time.Now().Add(-SuppressionReplyPeriod()))
+	if err != nil {
+		return false, nil, err
+	}
+	if len(recipients) == 0 {
+		log.Error("Supplier %v hasn't sent messages to mobile phone %v", supplier_id, mobilephone)
+		return false, nil, errors.New("Unknown reply")
+	}
+	smssenders, err := suppressionworkflow.SMSSenderRepository.GetByName(sender)
+	if err != nil {
+		return false, nil, err
+	}
+	owners := make(map[int64]bool)
+	for _, smssender := range *smssenders {
+		owners[smssender.Unit_ID] = true
+	}
+	for _, unit_id := range recipients {
+		if owners[unit_id] {
+			unit_ids = append(unit_ids, unit_id)
+		}
+	}
+	if len(unit_ids) == 0 {
+		unit_ids = recipients
+	}
+	for _, unit_id := range unit_ids {
+		err = suppressionworkflow.SuppressionRepository.Create(models.NewDtoSuppression(0, unit_id, mobilephone,
+			models.SUPPRESSION_REASON_STOP, models.SUPPRESSION_SOURCE_STOP, time.Now()))
+		if err != nil {
+			return false, nil, err
+		}
+	}
+	log.Info("Mobile phone %v has been suppressed by reply to sender %v", mobilephone, sender)
+
+	return true, unit_ids, nil
+}
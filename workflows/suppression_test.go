@@ -0,0 +1,43 @@
+package workflows
+
+import (
+	"application/config"
+	"testing"
+)
+
+func TestIsStopReply(t *testing.T) {
+	var cases = []struct {
+		message string
+		stop    bool
+	}{
+		{"STOP", true},
+		{" stop! ", true},
+		{"Стоп.", true},
+		{"отписаться", true},
+		{"Unsubscribe!!", true},
+		{"stop please", false},
+		{"STOPP", false},
+		{"", false},
+	}
+
+	for _, c := range cases {
+		if IsStopReply(c.message) != c.stop {
+			t.Error("Reply", c.message, "is not properly recognized")
+		}
+	}
+}
+
+func TestIsStopReplyConfigured(t *testing.T) {
+	var stopwords = config.Configuration.Suppression.StopWords
+	defer func() {
+		config.Configuration.Suppression.StopWords = stopwords
+	}()
+	config.Configuration.Suppression.StopWords = []string{"ХВАТИТ"}
+
+	if !IsStopReply("хватит!") {
+		t.Error("Configured stop word is not properly recognized")
+	}
+	if IsStopReply("STOP") {
+		t.Error("Default stop words should not be used with configured stop words")
+	}
+}
@@ -0,0 +1,24 @@
+package workflows
+
+import (
+	"application/services"
+	"time"
+)
+
+type TwoFactorWorkflow struct {
+	TwoFactorChallengeRepository services.TwoFactorChallengeRepository
+}
+
+func NewTwoFactorWorkflow(twofactorchallengerepository services.TwoFactorChallengeRepository) *TwoFactorWorkflow {
+	return &TwoFactorWorkflow{
+		TwoFactorChallengeRepository: twofactorchallengerepository,
+	}
+}
+
+// Удаление просроченных попыток входа и подключения второго фактора
+func (twofactorworkflow *TwoFactorWorkflow) ClearExpired() {
+	for {
+		_ = twofactorworkflow.TwoFactorChallengeRepository.DeleteExpired(time.Now())
+		time.Sleep(time.Minute)
+	}
+}
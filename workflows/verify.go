@@ -1,8 +1,8 @@
 package workflows
 
 import (
-	"application/communication/suppliers"
 	"application/config"
+	"application/gateways"
 	"application/helpers"
 	"application/models"
 	"application/services"
@@ -100,7 +100,7 @@ type VerifyWorkflow struct {
 	WorkTableRepository       services.WorkTableRepository
 	InvoiceRepository         services.InvoiceRepository
 	CompanyRepository         services.CompanyRepository
-	OperationRepository       services.OperationRepository
+	LedgerRepository          services.LedgerRepository
 	TransactionTypeRepository services.TransactionTypeRepository
 	TableColumnRepository     services.TableColumnRepository
 	UnitRepository            services.UnitRepository
@@ -115,7 +115,7 @@ func NewVerifyWorkflow(orderrepository services.OrderRepository, facilityreposit
 	customertablerepository services.CustomerTableRepository, verifytablerepository services.VerifyTableRepository,
 	resulttablerepository services.ResultTableRepository, worktablerepository services.WorkTableRepository,
 	invoicerepository services.InvoiceRepository, companyrepository services.CompanyRepository,
-	operationrepository services.OperationRepository, transactiontyperepository services.TransactionTypeRepository,
+	ledgerrepository services.LedgerRepository, transactiontyperepository services.TransactionTypeRepository,
 	tablecolumnrepository services.TableColumnRepository, unitrepository services.UnitRepository,
 	tablerowrepository services.TableRowRepository, pricerepository services.PriceRepository,
 	verifyproductrepository services.VerifyProductRepository, datacolumnrepository services.DataColumnRepository) *VerifyWorkflow {
@@ -130,7 +130,7 @@ func NewVerifyWorkflow(orderrepository services.OrderRepository, facilityreposit
 		WorkTableRepository:       worktablerepository,
 		InvoiceRepository:         invoicerepository,
 		CompanyRepository:         companyrepository,
-		OperationRepository:       operationrepository,
+		LedgerRepository:          ledgerrepository,
 		TransactionTypeRepository: transactiontyperepository,
 		TableColumnRepository:     tablecolumnrepository,
 		UnitRepository:            unitrepository,
@@ -141,25 +141,24 @@ func NewVerifyWorkflow(orderrepository services.OrderRepository, facilityreposit
 	}
 }
 
-func SendVerify(supplier *libTypes.Supplier, verify *[]libTypes.VerifyData) (verifyresponse *libTypes.VerifyDataResponse, err error) {
+func SendVerify(gateway gateways.Gateway, supplier *libTypes.Supplier, verify *[]libTypes.VerifyData) (verifyresponse *libTypes.VerifyDataResponse, err error) {
 	// Отправка Verify
-	suppliers.WaitClientReady()                                   // Функция блокируется до момента пока связь с сервером не будет установлена
-	response, err := suppliers.SendVerifyData(*supplier, *verify) // Функция не блокируется. Если связи с сервером нет, то вернётся ошибка
+	verifyresponse, err = gateway.SendVerifyData(supplier, verify)
 	if err != nil {
 		log.Error("Can't send verify requests %v for supplier %v", err, supplier.Name)
 		return nil, err
 	}
-	return &response, nil
+	return verifyresponse, nil
 }
 
-func GetVerifyStatus(verifyresponse *libTypes.VerifyDataResponse) (verifystatuses map[gocql.UUID]libTypes.VerifyDataStatus, err error) {
+func GetVerifyStatus(gateway gateways.Gateway, verifyresponse *libTypes.VerifyDataResponse) (verifystatuses map[gocql.UUID]libTypes.VerifyDataStatus, err error) {
 	verifystatuses = make(map[gocql.UUID]libTypes.VerifyDataStatus)
 	// Получение статуса по UUID запроса
 	for {
 		final := true
 		for i := range verifyresponse.Ids {
 			var status libTypes.VerifyDataStatus
-			status, err = suppliers.StatusVerifyData(verifyresponse.Ids[i])
+			status, err = gateway.StatusVerifyData(verifyresponse.Ids[i])
 			if err != nil {
 				log.Error("Can't get verify statuses %v", err)
 				return map[gocql.UUID]libTypes.VerifyDataStatus{}, err
@@ -240,34 +239,71 @@ func (verifyworkflow *VerifyWorkflow) GetVerifyTableColumns(dtoorder *models.Dto
 
 func (verifyworkflow *VerifyWorkflow) CalculateCost(apitablerows *[]models.ApiInfoTableRow,
 	dtoorder *models.DtoOrder, dtoverifyfacility *models.DtoVerifyFacility) (cost float64, err error) {
-	verifyprices, err := helpers.GetVerifyPrices(models.SERVICE_TYPE_VERIFY, dtoorder.Supplier_ID, nil, verifyworkflow.PriceRepository,
-		verifyworkflow.TableColumnRepository, verifyworkflow.TableRowRepository, verifyworkflow.VerifyProductRepository, "", true)
+	quote, err := verifyworkflow.QuoteRows(apitablerows, dtoorder, dtoverifyfacility)
 	if err != nil {
 		return 0, err
 	}
-	cost = 0
-	count := 0
-	for _, verifyprice := range *verifyprices {
-		cost = float64(len(*apitablerows)) * verifyprice.Price
-		count++
+
+	return quote.Cost, nil
+}
+
+// Расчет стоимости проверки строк таблицы по позиции прайса поставщика
+func (verifyworkflow *VerifyWorkflow) QuoteRows(apitablerows *[]models.ApiInfoTableRow,
+	dtoorder *models.DtoOrder, dtoverifyfacility *models.DtoVerifyFacility) (quote *models.ApiOrderQuote, err error) {
+	verifyprices, err := helpers.GetVerifyPrices(models.SERVICE_TYPE_VERIFY, dtoorder.Supplier_ID, nil, verifyworkflow.PriceRepository,
+		verifyworkflow.TableColumnRepository, verifyworkflow.TableRowRepository, verifyworkflow.VerifyProductRepository, "", true)
+	if err != nil {
+		return nil, err
 	}
-	if count < 1 {
+	if len(*verifyprices) < 1 {
 		log.Error("Price list doesn't contain positions for supplier %v", dtoorder.Supplier_ID)
-		return 0, errors.New("Empty price list")
+		return nil, errors.New("Empty price list")
 	}
-	if count > 1 {
+	if len(*verifyprices) > 1 {
 		log.Error("Price list contains multiple positions for supplier %v", dtoorder.Supplier_ID)
-		return 0, errors.New("Multiple price list")
+		return nil, errors.New("Multiple price list")
 	}
 
-	return cost, nil
+	verifyprice := (*verifyprices)[0]
+	count := len(*apitablerows)
+	quote = models.NewApiOrderQuote(models.SERVICE_TYPE_VERIFY, int64(count))
+	quote.AddItem(models.NewApiQuoteItem(0, "", "", verifyprice.Product_ID, "", models.ApiRange{}, count, verifyprice.Price, 0,
+		float64(count)*verifyprice.Price))
+
+	return quote, nil
 }
 
-func (verifyworkflow *VerifyWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtoverifyfacility *models.DtoVerifyFacility, balance float64) (err error) {
-	if balance < dtoverifyfacility.Cost {
-		log.Error("Not enough money at unit balance %v to pay for order %v execution", dtoorder.Unit_ID, dtoorder.ID)
-		return errors.New("Not enough money")
+// Предварительный расчет стоимости заказа по всем строкам таблицы без изменения состояния заказа
+func (verifyworkflow *VerifyWorkflow) Quote(dtoorder *models.DtoOrder) (quote *models.ApiOrderQuote, err error) {
+	dtoverifyfacility, err := verifyworkflow.VerifyFacilityRepository.Get(dtoorder.ID)
+	if err != nil {
+		return nil, err
+	}
+	_, err = verifyworkflow.CheckVerifyOrder(dtoorder, dtoverifyfacility)
+	if err != nil {
+		return nil, err
 	}
+	tablecolumns, _, err := verifyworkflow.GetVerifyTableColumns(dtoorder, dtoverifyfacility)
+	if err != nil {
+		return nil, err
+	}
+	apitablerows, err := verifyworkflow.TableRowRepository.GetAll("", "", dtoverifyfacility.TablesDataId, tablecolumns)
+	if err != nil {
+		return nil, err
+	}
+	quote, err = verifyworkflow.QuoteRows(apitablerows, dtoorder, dtoverifyfacility)
+	if err != nil {
+		return nil, err
+	}
+	err = QuoteVAT(quote, dtoorder.Unit_ID, verifyworkflow.CompanyRepository)
+	if err != nil {
+		return nil, err
+	}
+
+	return quote, nil
+}
+
+func (verifyworkflow *VerifyWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, dtoverifyfacility *models.DtoVerifyFacility) (err error) {
 	dtocompany, err := verifyworkflow.CompanyRepository.GetPrimaryByUnit(dtoorder.Unit_ID)
 	if err != nil {
 		return err
@@ -289,9 +325,9 @@ func (verifyworkflow *VerifyWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, d
 	dtoinvoice := new(models.DtoInvoice)
 	dtoinvoice.Company_ID = dtocompany.ID
 	if dtocompany.VAT != 0 {
-		dtoinvoice.VAT = (dtoverifyfacility.Cost / (1 + float64(dtocompany.VAT)/100)) * float64(dtocompany.VAT) / 100
+		dtoinvoice.VAT = (dtoverifyfacility.CostFactual / (1 + float64(dtocompany.VAT)/100)) * float64(dtocompany.VAT) / 100
 	}
-	dtoinvoice.Total = dtoverifyfacility.Cost
+	dtoinvoice.Total = dtoverifyfacility.CostFactual
 	dtoinvoice.Paid = true
 	dtoinvoice.Created = time.Now()
 	dtoinvoice.Active = true
@@ -307,13 +343,43 @@ func (verifyworkflow *VerifyWorkflow) PayAndInvoice(dtoorder *models.DtoOrder, d
 	return nil
 }
 
+// Оплата фактической стоимости заказа при закрытии. Тарифицируются только строки, принятые поставщиком,
+// остаток резерва снимается
+func (verifyworkflow *VerifyWorkflow) Capture(dtoorder *models.DtoOrder, dtoverifyfacility *models.DtoVerifyFacility,
+	apitablerows *[]models.ApiInfoTableRow, verifyresponse *libTypes.VerifyDataResponse) (err error) {
+	accepted := new([]models.ApiInfoTableRow)
+	for index, apitablerow := range *apitablerows {
+		if index < len(verifyresponse.Errors) && verifyresponse.Errors[index] == nil {
+			*accepted = append(*accepted, apitablerow)
+		}
+	}
+	dtoverifyfacility.CostFactual = 0
+	if len(*accepted) != 0 {
+		dtoverifyfacility.CostFactual, err = verifyworkflow.CalculateCost(accepted, dtoorder, dtoverifyfacility)
+		if err != nil {
+			return err
+		}
+	}
+	err = verifyworkflow.VerifyFacilityRepository.Update(dtoverifyfacility, true, false)
+	if err != nil {
+		return err
+	}
+	if dtoverifyfacility.CostFactual == 0 {
+		log.Info("Nothing has been verified for order %v, releasing held cost", dtoorder.ID)
+		return verifyworkflow.LedgerRepository.Release(dtoorder.ID)
+	}
+
+	return verifyworkflow.PayAndInvoice(dtoorder, dtoverifyfacility)
+}
+
 func (verifyworkflow *VerifyWorkflow) SendVerify(apitablerows *[]models.ApiInfoTableRow, dtoorder *models.DtoOrder,
 	dtoverifyfacility *models.DtoVerifyFacility) (verifyresponse *libTypes.VerifyDataResponse, err error) {
 	dtosupplier, err := verifyworkflow.UnitRepository.Get(dtoorder.Supplier_ID)
 	if err != nil {
 		return nil, err
 	}
-	verifysupplier, err := GetSupplier(dtosupplier.UUID)
+	gateway := gateways.Get(dtosupplier.UUID)
+	verifysupplier, err := gateway.Supplier(dtosupplier.UUID)
 	if err != nil {
 		return nil, err
 	}
@@ -351,7 +417,7 @@ func (verifyworkflow *VerifyWorkflow) SendVerify(apitablerows *[]models.ApiInfoT
 		}
 		*verify = append(*verify, *obj)
 	}
-	verifyresponse, err = SendVerify(verifysupplier, verify)
+	verifyresponse, err = SendVerify(gateway, verifysupplier, verify)
 	if err != nil {
 		return nil, err
 	}
@@ -858,7 +924,6 @@ func (verifyworkflow *VerifyWorkflow) ExecuteOrder(order_id int64) {
 			_ = verifyworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-		dtoverifyfacility.CostFactual = dtoverifyfacility.Cost
 		err = verifyworkflow.VerifyFacilityRepository.Update(dtoverifyfacility, true, false)
 		if err != nil {
 			_ = verifyworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
@@ -870,23 +935,18 @@ func (verifyworkflow *VerifyWorkflow) ExecuteOrder(order_id int64) {
 			_ = verifyworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-		log.Info("Start order processing ...")
-		/* 5 */ dtoorder.Begin_Date = time.Now()
-		err = verifyworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
-			*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_MODERATOR_BEGIN, true, "", time.Now())}, nil, true)
+		log.Info("Holding order cost ...")
+		_, err = verifyworkflow.LedgerRepository.Hold(dtoorder.Unit_ID, dtoorder.ID, dtoverifyfacility.Cost)
 		if err != nil {
 			_ = verifyworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
-		log.Info("Calculating unit balance ...")
-		balance, err := verifyworkflow.OperationRepository.CalculateBalance(dtoorder.Unit_ID)
-		if err != nil {
-			_ = verifyworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
-			return
-		}
-		log.Info("Order payment and invoicement ...")
-		/* 6 */ err = verifyworkflow.PayAndInvoice(dtoorder, dtoverifyfacility, balance)
+		log.Info("Start order processing ...")
+		/* 5 */ dtoorder.Begin_Date = time.Now()
+		err = verifyworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
+			*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_MODERATOR_BEGIN, true, "", time.Now())}, nil, true)
 		if err != nil {
+			_ = verifyworkflow.LedgerRepository.Release(dtoorder.ID)
 			_ = verifyworkflow.SetStatus(dtoorder, models.ORDER_STATUS_COMPLETED, false)
 			return
 		}
@@ -906,7 +966,11 @@ func (verifyworkflow *VerifyWorkflow) ExecuteOrder(order_id int64) {
 			return
 		}
 		log.Info("Getting supplier results ...")
-		/* 10 */ verifystatuses, err := GetVerifyStatus(verifyresponse)
+		dtosupplier, err := verifyworkflow.UnitRepository.Get(dtoorder.Supplier_ID)
+		if err != nil {
+			return
+		}
+		/* 10 */ verifystatuses, err := GetVerifyStatus(gateways.Get(dtosupplier.UUID), verifyresponse)
 		if err != nil {
 			return
 		}
@@ -920,8 +984,13 @@ func (verifyworkflow *VerifyWorkflow) ExecuteOrder(order_id int64) {
 		if err != nil {
 			return
 		}
+		log.Info("Order payment and invoicement ...")
+		/* 13 */ err = verifyworkflow.Capture(dtoorder, dtoverifyfacility, apitablerows, verifyresponse)
+		if err != nil {
+			return
+		}
 		log.Info("Completing order execution %v", time.Now())
-		/* 13 */ dtoorder.End_Date = time.Now()
+		dtoorder.End_Date = time.Now()
 		err = verifyworkflow.OrderRepository.Update(dtoorder, &[]models.DtoOrderStatus{
 			*models.NewDtoOrderStatus(dtoorder.ID, models.ORDER_STATUS_SUPPLIER_CLOSE, true, "", time.Now())}, nil, true)
 		if err != nil {
@@ -0,0 +1,185 @@
+package workflows
+
+import (
+	"application/config"
+	"application/helpers"
+	"application/models"
+	"application/services"
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	WEBHOOK_INTERVAL       = 10 * time.Second
+	WEBHOOK_TIMEOUT        = 10 * time.Second
+	WEBHOOK_RETRY_INTERVAL = time.Minute
+	WEBHOOK_MAX_ATTEMPTS   = 10
+	WEBHOOK_BATCH_SIZE     = 100
+	WEBHOOK_ERROR_LENGTH   = 255
+
+	WEBHOOK_HEADER_EVENT     = "X-Webhook-Event"
+	WEBHOOK_HEADER_DELIVERY  = "X-Webhook-Delivery"
+	WEBHOOK_HEADER_SIGNATURE = "X-Webhook-Signature"
+	WEBHOOK_SIGNATURE_PREFIX = "sha256="
+)
+
+type WebhookWorkflow struct {
+	WebhookRepository         services.WebhookRepository
+	WebhookDeliveryRepository services.WebhookDeliveryRepository
+	client                    *http.Client
+}
+
+func NewWebhookWorkflow(webhookrepository services.WebhookRepository,
+	webhookdeliveryrepository services.WebhookDeliveryRepository) *WebhookWorkflow {
+	timeout := config.Configuration.Webhooks.Timeout
+	if timeout <= 0 {
+		timeout = WEBHOOK_TIMEOUT
+	}
+	return &WebhookWorkflow{
+		WebhookRepository:         webhookrepository,
+		WebhookDeliveryRepository: webhookdeliveryrepository,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: &http.Transport{Dial: DialWebhook(timeout)},
+			CheckRedirect: func(request *http.Request, via []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+// Соединение с узлом уведомлений. Адреса узла проверяются при каждой отправке, соединение устанавливается только
+// с проверенным публичным адресом
+func DialWebhook(timeout time.Duration) func(network string, address string) (net.Conn, error) {
+	return func(network string, address string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, err
+		}
+		ips, err := helpers.ResolveWebhookHost(host)
+		if err != nil {
+			return nil, err
+		}
+
+		return net.DialTimeout(network, net.JoinHostPort(ips[0].String(), port), timeout)
+	}
+}
+
+// Подпись тела уведомления ключом адреса уведомлений
+func SignWebhook(secret string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write(payload)
+	return WEBHOOK_SIGNATURE_PREFIX + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Время следующей попытки доставки: интервал удваивается с каждой неудачной попыткой
+func WebhookRetryAt(attempts int) time.Time {
+	interval := config.Configuration.Webhooks.RetryInterval
+	if interval <= 0 {
+		interval = WEBHOOK_RETRY_INTERVAL
+	}
+	for i := 1; i < attempts && interval < 24*time.Hour; i++ {
+		interval *= 2
+	}
+	return time.Now().Add(interval)
+}
+
+// Время аренды пакета уведомлений, достаточное для отправки каждого из них с ожиданием ответа
+func (webhookworkflow *WebhookWorkflow) LeaseTimeout() time.Duration {
+	return webhookworkflow.client.Timeout * (WEBHOOK_BATCH_SIZE + 1)
+}
+
+// Периодическая доставка уведомлений из очереди. Уведомления захватываются перед отправкой, поэтому несколько
+// экземпляров сервера не доставляют одно уведомление повторно
+func (webhookworkflow *WebhookWorkflow) Deliver() {
+	for {
+		deliveries, err := webhookworkflow.WebhookDeliveryRepository.Claim(WEBHOOK_BATCH_SIZE, webhookworkflow.LeaseTimeout())
+		if err == nil {
+			for i := range *deliveries {
+				webhookworkflow.Send(&(*deliveries)[i])
+			}
+		}
+		if err != nil || len(*deliveries) < WEBHOOK_BATCH_SIZE {
+			interval := config.Configuration.Webhooks.Interval
+			if interval <= 0 {
+				interval = WEBHOOK_INTERVAL
+			}
+			time.Sleep(interval)
+		}
+	}
+}
+
+// Попытка доставки уведомления с записью результата в журнал доставки
+func (webhookworkflow *WebhookWorkflow) Send(dtowebhookdelivery *models.DtoWebhookDelivery) {
+	dtowebhookdelivery.Attempts++
+	dtowebhookdelivery.ResponseCode = 0
+	dtowebhookdelivery.Error = ""
+
+	dtowebhook, err := webhookworkflow.WebhookRepository.Get(dtowebhookdelivery.Webhook_ID)
+	if err == nil && !dtowebhook.Active {
+		dtowebhookdelivery.Status = models.WEBHOOK_DELIVERY_STATUS_FAILED
+		dtowebhookdelivery.Error = "Webhook is not active"
+		_ = webhookworkflow.WebhookDeliveryRepository.Update(dtowebhookdelivery)
+		return
+	}
+	if err == nil {
+		dtowebhookdelivery.ResponseCode, err = webhookworkflow.Post(dtowebhook, dtowebhookdelivery)
+	}
+	if err == nil {
+		dtowebhookdelivery.Status = models.WEBHOOK_DELIVERY_STATUS_DELIVERED
+		dtowebhookdelivery.Delivered = time.Now()
+	} else {
+		dtowebhookdelivery.Error = err.Error()
+		if len(dtowebhookdelivery.Error) > WEBHOOK_ERROR_LENGTH {
+			dtowebhookdelivery.Error = dtowebhookdelivery.Error[:WEBHOOK_ERROR_LENGTH]
+		}
+		maxattempts := config.Configuration.Webhooks.MaxAttempts
+		if maxattempts <= 0 {
+			maxattempts = WEBHOOK_MAX_ATTEMPTS
+		}
+		if dtowebhookdelivery.Attempts >= maxattempts {
+			log.Error("Webhook delivery %v has failed after %v attempts %v", dtowebhookdelivery.ID, dtowebhookdelivery.Attempts, err)
+			dtowebhookdelivery.Status = models.WEBHOOK_DELIVERY_STATUS_FAILED
+		} else {
+			dtowebhookdelivery.Next_Attempt = WebhookRetryAt(dtowebhookdelivery.Attempts)
+		}
+	}
+
+	_ = webhookworkflow.WebhookDeliveryRepository.Update(dtowebhookdelivery)
+}
+
+// Отправка уведомления, успешной считается доставка с кодом ответа 2xx
+func (webhookworkflow *WebhookWorkflow) Post(dtowebhook *models.DtoWebhook,
+	dtowebhookdelivery *models.DtoWebhookDelivery) (code int, err error) {
+	payload := []byte(dtowebhookdelivery.Payload)
+	request, err := http.NewRequest("POST", dtowebhook.URL, bytes.NewReader(payload))
+	if err != nil {
+		log.Error("Can't create webhook request %v for url %v", err, dtowebhook.URL)
+		return 0, err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set(WEBHOOK_HEADER_EVENT, dtowebhookdelivery.Event)
+	request.Header.Set(WEBHOOK_HEADER_DELIVERY, fmt.Sprintf("%v", dtowebhookdelivery.ID))
+	request.Header.Set(WEBHOOK_HEADER_SIGNATURE, SignWebhook(dtowebhook.Secret, payload))
+
+	response, err := webhookworkflow.client.Do(request)
+	if err != nil {
+		log.Error("Can't send webhook request %v for url %v", err, dtowebhook.URL)
+		return 0, err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(ioutil.Discard, response.Body)
+	if response.StatusCode < http.StatusOK || response.StatusCode >= http.StatusMultipleChoices {
+		return response.StatusCode, fmt.Errorf("Unexpected response status %v", response.Status)
+	}
+
+	return response.StatusCode, nil
+}
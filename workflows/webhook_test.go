@@ -0,0 +1,49 @@
+package workflows
+
+import (
+	"application/config"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	var signature = "sha256=f7bc83f430538424b13298e6aa6fb143ef4d59a14946175997479dbc2d1a3cd8"
+
+	if value := SignWebhook("key", []byte("The quick brown fox jumps over the lazy dog")); value != signature {
+		t.Error("Webhook signature is not properly calculated", value)
+	}
+	if value := SignWebhook("other", []byte("The quick brown fox jumps over the lazy dog")); value == signature {
+		t.Error("Webhook signature should depend on secret")
+	}
+}
+
+func TestWebhookRetryAt(t *testing.T) {
+	var retryinterval = config.Configuration.Webhooks.RetryInterval
+	defer func() {
+		config.Configuration.Webhooks.RetryInterval = retryinterval
+	}()
+
+	var cases = []struct {
+		configured time.Duration
+		attempts   int
+		interval   time.Duration
+	}{
+		{0, 0, WEBHOOK_RETRY_INTERVAL},
+		{0, 1, WEBHOOK_RETRY_INTERVAL},
+		{0, 2, 2 * WEBHOOK_RETRY_INTERVAL},
+		{0, 4, 8 * WEBHOOK_RETRY_INTERVAL},
+		{0, 100, 2048 * WEBHOOK_RETRY_INTERVAL},
+		{time.Hour, 2, 2 * time.Hour},
+		{time.Hour, 100, 32 * time.Hour},
+	}
+
+	for _, c := range cases {
+		config.Configuration.Webhooks.RetryInterval = c.configured
+		before := time.Now()
+		retryat := WebhookRetryAt(c.attempts)
+		after := time.Now()
+		if retryat.Before(before.Add(c.interval)) || retryat.After(after.Add(c.interval)) {
+			t.Error("Retry time of attempt", c.attempts, "is not properly calculated", retryat.Sub(before))
+		}
+	}
+}
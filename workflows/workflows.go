@@ -4,17 +4,32 @@ package workflows
 
 import (
 	"application/config"
+	"application/helpers"
 	"application/models"
+	"application/services"
 	logging "github.com/op/go-logging"
-	libSuppliers "lib/suppliers"
-	libTypes "lib/suppliers/types"
-	"lib/uuid"
+	"time"
 )
 
 type Executor interface {
 	ExecuteOrder(order_id int64)
 }
 
+// Исполнитель, способный продолжить оплаченный заказ с последней контрольной точки
+type Resumer interface {
+	ResumeOrder(order_id int64) (err error)
+}
+
+// Исполнитель, откладывающий часть работы по заказу на более позднее время
+type Deferrer interface {
+	NextAttempt(order_id int64) (next time.Time, deferred bool, err error)
+}
+
+// Исполнитель, выполняющий предварительный расчет стоимости заказа
+type Quoter interface {
+	Quote(dtoorder *models.DtoOrder) (quote *models.ApiOrderQuote, err error)
+}
+
 var (
 	log config.Logger = logging.MustGetLogger("workflows")
 )
@@ -23,30 +38,103 @@ func InitLogger(logger config.Logger) {
 	log = logger
 }
 
-// Получение поставщика из библиотеки по UUID в виде строки
-func GetSupplier(uuidstr string) (sp *libTypes.Supplier, err error) {
-	var supp *libSuppliers.Suppliers
-	var uuidObj uuid.UUID
+func FillTableCell(dtotablecell *models.DtoTableCell, dtotablecolumn *models.DtoTableColumn, column string, value string) {
+	if (dtotablecell.Table_Column_ID == dtotablecolumn.ID) && (dtotablecolumn.Name == column) && dtotablecolumn.Prebuilt {
+		dtotablecell.Value = value
+		dtotablecell.Checked = true
+		dtotablecell.Valid = true
+	}
+}
+
+// Проверка завершения шага исполнения заказа
+func IsStepDone(checkpoints map[int]models.DtoOrderCheckpoint, step int) bool {
+	checkpoint, ok := checkpoints[step]
+	return ok && checkpoint.Done
+}
 
-	uuidObj, err = uuid.ParseUUID(uuidstr)
+// Получение служебных колонок рабочей таблицы, отсутствующие колонки создаются
+func GetOrCreateColumns(dtoworkdatatable *models.DtoCustomerTable, columnnames []string,
+	tablecolumnrepository services.TableColumnRepository) (
+	tablecolumns []models.DtoTableColumn, err error) {
+	alltablecolumns, err := tablecolumnrepository.GetByTable(dtoworkdatatable.ID)
 	if err != nil {
-		log.Error("Can't parse UUID %v, %v", uuidstr, err)
 		return nil, err
 	}
-	supp = libSuppliers.New()
-	supplier, err := supp.SuppliersByUUID(uuidObj)
+	position, err := tablecolumnrepository.GetDefaultPosition(dtoworkdatatable.ID)
 	if err != nil {
-		log.Error("Can't find supplier by UUID %v, %v", uuidstr, err)
 		return nil, err
 	}
+	for _, columnname := range columnnames {
+		found := false
+		for i := range *alltablecolumns {
+			if (*alltablecolumns)[i].Name == columnname && (*alltablecolumns)[i].Prebuilt {
+				tablecolumns = append(tablecolumns, (*alltablecolumns)[i])
+				found = true
+				break
+			}
+		}
+		if found {
+			continue
+		}
+
+		position++
+		fieldnum, err := helpers.FindFreeColumnInternal(dtoworkdatatable.ID, 0, tablecolumnrepository)
+		if err != nil {
+			return nil, err
+		}
 
-	return &supplier, nil
+		dtotablecolumn := new(models.DtoTableColumn)
+		dtotablecolumn.Created = time.Now()
+		dtotablecolumn.Position = position
+		dtotablecolumn.Name = columnname
+		dtotablecolumn.Customer_Table_ID = dtoworkdatatable.ID
+		dtotablecolumn.Column_Type_ID = models.COLUMN_TYPE_DEFAULT
+		dtotablecolumn.Prebuilt = true
+		dtotablecolumn.FieldNum = fieldnum
+		dtotablecolumn.Active = true
+		dtotablecolumn.Edition = 0
+
+		err = tablecolumnrepository.Create(dtotablecolumn, nil)
+		if err != nil {
+			return nil, err
+		}
+		tablecolumns = append(tablecolumns, *dtotablecolumn)
+	}
+
+	return tablecolumns, nil
 }
 
-func FillTableCell(dtotablecell *models.DtoTableCell, dtotablecolumn *models.DtoTableColumn, column string, value string) {
-	if (dtotablecell.Table_Column_ID == dtotablecolumn.ID) && (dtotablecolumn.Name == column) && dtotablecolumn.Prebuilt {
-		dtotablecell.Value = value
-		dtotablecell.Checked = true
-		dtotablecell.Valid = true
+// Заказ оплачен по фактической стоимости. У периодического заказа признак снимается перед очередной рассылкой
+func IsOrderPaid(order_id int64, orderstatusrepository services.OrderStatusRepository) (paid bool, err error) {
+	dtoorderstatuses, err := orderstatusrepository.GetByOrder(order_id)
+	if err != nil {
+		return false, err
 	}
+	for _, dtoorderstatus := range *dtoorderstatuses {
+		if dtoorderstatus.Status_ID == models.ORDER_STATUS_PAID && dtoorderstatus.Value {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Возврат оплаты за неисполненный заказ
+func RefundOrder(dtoorder *models.DtoOrder, unitrepository services.UnitRepository,
+	transactiontyperepository services.TransactionTypeRepository, invoicerepository services.InvoiceRepository) (err error) {
+	dtounit, err := unitrepository.Get(config.Configuration.SystemAccount)
+	if err != nil {
+		return err
+	}
+	dtotransactiontype, err := transactiontyperepository.Get(models.TRANSACTION_TYPE_RETURNING_MONEY)
+	if err != nil {
+		return err
+	}
+
+	dtotransaction := new(models.DtoTransaction)
+	dtotransaction.Source_ID = dtounit.ID
+	dtotransaction.Destination_ID = dtoorder.Unit_ID
+	dtotransaction.Type_ID = dtotransactiontype.ID
+
+	return invoicerepository.RefundForOrder(dtoorder, dtotransaction, true)
 }